@@ -0,0 +1,146 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "gonum.org/v1/gonum/floats"
+
+// The operators returned by the functions in this file allocate their
+// temporary vectors on first use and reuse them afterwards. They must not
+// be used concurrently, and dst and x passed to their MatVec and
+// MatTransVec must not overlap. If an operand lacks MatTransVec, so does the
+// returned operator, its MatTransVec is nil.
+
+// ScaledOps returns MatrixOps for the matrix alpha*A.
+func ScaledOps(alpha float64, a MatrixOps) MatrixOps {
+	checkOps(a)
+	scaled := func(matVec func(dst, x []float64)) func(dst, x []float64) {
+		if matVec == nil {
+			return nil
+		}
+		return func(dst, x []float64) {
+			matVec(dst, x)
+			floats.Scale(alpha, dst)
+		}
+	}
+	return MatrixOps{
+		MatVec:      scaled(a.MatVec),
+		MatTransVec: scaled(a.MatTransVec),
+	}
+}
+
+// AddedOps returns MatrixOps for the matrix A+B.
+func AddedOps(a, b MatrixOps) MatrixOps {
+	checkOps(a)
+	checkOps(b)
+	added := func(matVecA, matVecB func(dst, x []float64)) func(dst, x []float64) {
+		if matVecA == nil || matVecB == nil {
+			return nil
+		}
+		var tmp []float64
+		return func(dst, x []float64) {
+			tmp = reuse(tmp, len(dst))
+			matVecA(dst, x)
+			matVecB(tmp, x)
+			floats.Add(dst, tmp)
+		}
+	}
+	return MatrixOps{
+		MatVec:      added(a.MatVec, b.MatVec),
+		MatTransVec: added(a.MatTransVec, b.MatTransVec),
+	}
+}
+
+// ComposedOps returns MatrixOps for the matrix A*B. The product with the
+// transpose is computed as
+//  (A*B)^T x = B^T (A^T x).
+func ComposedOps(a, b MatrixOps) MatrixOps {
+	checkOps(a)
+	checkOps(b)
+	composed := func(first, second func(dst, x []float64)) func(dst, x []float64) {
+		if first == nil || second == nil {
+			return nil
+		}
+		var tmp []float64
+		return func(dst, x []float64) {
+			tmp = reuse(tmp, len(dst))
+			first(tmp, x)
+			second(dst, tmp)
+		}
+	}
+	return MatrixOps{
+		MatVec:      composed(b.MatVec, a.MatVec),
+		MatTransVec: composed(a.MatTransVec, b.MatTransVec),
+	}
+}
+
+// ShiftedOps returns MatrixOps for the matrix A - sigma*I.
+func ShiftedOps(a MatrixOps, sigma float64) MatrixOps {
+	checkOps(a)
+	shifted := func(matVec func(dst, x []float64)) func(dst, x []float64) {
+		if matVec == nil {
+			return nil
+		}
+		return func(dst, x []float64) {
+			matVec(dst, x)
+			floats.AddScaled(dst, -sigma, x)
+		}
+	}
+	return MatrixOps{
+		MatVec:      shifted(a.MatVec),
+		MatTransVec: shifted(a.MatTransVec),
+	}
+}
+
+// EquilibratedOps returns MatrixOps for the matrix
+//  D_r A D_c,
+// where D_r = diag(rowScale) and D_c = diag(colScale), for example with the
+// scaling factors computed by RuizScale. The length of rowScale must be
+// equal to the number of rows of A and the length of colScale to the
+// number of its columns.
+func EquilibratedOps(a MatrixOps, rowScale, colScale []float64) MatrixOps {
+	checkOps(a)
+	equilibrated := func(matVec func(dst, x []float64), left, right []float64) func(dst, x []float64) {
+		if matVec == nil {
+			return nil
+		}
+		var tmp []float64
+		return func(dst, x []float64) {
+			if len(x) != len(right) || len(dst) != len(left) {
+				panic("iterative: mismatched length of scaling factors")
+			}
+			tmp = reuse(tmp, len(x))
+			floats.MulTo(tmp, right, x)
+			matVec(dst, tmp)
+			floats.Mul(dst, left)
+		}
+	}
+	return MatrixOps{
+		MatVec:      equilibrated(a.MatVec, rowScale, colScale),
+		MatTransVec: equilibrated(a.MatTransVec, colScale, rowScale),
+		Rows:        a.Rows,
+		Cols:        a.Cols,
+	}
+}
+
+// Transposed returns MatrixOps for the matrix A^T, its MatVec and
+// MatTransVec are those of a swapped. The transpose of the returned
+// operator is a again. Transposed panics if a lacks MatTransVec.
+func Transposed(a MatrixOps) MatrixOps {
+	checkOps(a)
+	if a.MatTransVec == nil {
+		panic("iterative: transposed operator needs MatTransVec")
+	}
+	return MatrixOps{
+		MatVec:      a.MatTransVec,
+		MatTransVec: a.MatVec,
+	}
+}
+
+// checkOps panics if a lacks MatVec.
+func checkOps(a MatrixOps) {
+	if a.MatVec == nil {
+		panic("iterative: nil matrix-vector multiplication")
+	}
+}
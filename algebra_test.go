@@ -0,0 +1,181 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func randomDense(n int, rnd *rand.Rand) *mat.Dense {
+	a := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			a.Set(i, j, rnd.NormFloat64())
+		}
+	}
+	return a
+}
+
+func TestOpsAlgebra(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 5, 10} {
+		a := randomDense(n, rnd)
+		b := randomDense(n, rnd)
+		opsA := DenseMatrixOps(a)
+		opsB := DenseMatrixOps(b)
+		const alpha, sigma = -1.5, 0.75
+
+		dr := make([]float64, n)
+		dc := make([]float64, n)
+		for i := range dr {
+			dr[i] = 1 + rnd.Float64()
+			dc[i] = 1 + rnd.Float64()
+		}
+
+		var scaled, added, composed, shifted, equilibrated mat.Dense
+		scaled.Scale(alpha, a)
+		added.Add(a, b)
+		composed.Mul(a, b)
+		shifted.Sub(a, mat.NewDiagDense(n, scaledOnes(n, sigma)))
+		equilibrated.Mul(mat.NewDiagDense(n, dr), a)
+		equilibrated.Mul(&equilibrated, mat.NewDiagDense(n, dc))
+
+		for _, test := range []struct {
+			name string
+			ops  MatrixOps
+			want mat.Matrix
+		}{
+			{"ScaledOps", ScaledOps(alpha, opsA), &scaled},
+			{"AddedOps", AddedOps(opsA, opsB), &added},
+			{"ComposedOps", ComposedOps(opsA, opsB), &composed},
+			{"ShiftedOps", ShiftedOps(opsA, sigma), &shifted},
+			{"EquilibratedOps", EquilibratedOps(opsA, dr, dc), &equilibrated},
+			{"nested", AddedOps(ComposedOps(opsA, opsB), ScaledOps(alpha, opsA)), nested(&composed, &scaled)},
+		} {
+			// Apply twice to exercise reuse of temporaries.
+			for k := 0; k < 2; k++ {
+				if diff := testOps(test.ops, test.want, rnd); diff > 1e-13 {
+					t.Errorf("%v,n=%v: unexpected products, |want-got|=%v", test.name, n, diff)
+				}
+			}
+			x := make([]float64, n)
+			dst := make([]float64, n)
+			allocs := testing.AllocsPerRun(10, func() {
+				test.ops.MatVec(dst, x)
+				test.ops.MatTransVec(dst, x)
+			})
+			if allocs != 0 {
+				t.Errorf("%v,n=%v: unexpected allocations %v", test.name, n, allocs)
+			}
+		}
+	}
+
+	noTrans := MatrixOps{MatVec: IdentityOps(3).MatVec}
+	for _, test := range []struct {
+		name string
+		ops  MatrixOps
+	}{
+		{"ScaledOps", ScaledOps(2, noTrans)},
+		{"AddedOps", AddedOps(IdentityOps(3), noTrans)},
+		{"ComposedOps", ComposedOps(noTrans, IdentityOps(3))},
+		{"ShiftedOps", ShiftedOps(noTrans, 1)},
+		{"EquilibratedOps", EquilibratedOps(noTrans, ones(3), ones(3))},
+	} {
+		if test.ops.MatVec == nil {
+			t.Errorf("%v: nil MatVec", test.name)
+		}
+		if test.ops.MatTransVec != nil {
+			t.Errorf("%v: non-nil MatTransVec from operand without transpose", test.name)
+		}
+	}
+	if !panics(func() { AddedOps(IdentityOps(3), MatrixOps{}) }) {
+		t.Errorf("nil MatVec did not panic")
+	}
+}
+
+func scaledOnes(n int, alpha float64) []float64 {
+	v := ones(n)
+	for i := range v {
+		v[i] *= alpha
+	}
+	return v
+}
+
+func nested(a, b mat.Matrix) mat.Matrix {
+	var c mat.Dense
+	c.Add(a, b)
+	return &c
+}
+
+// dotTest returns the relative difference between x^T (A y) and (A^T x)^T y
+// for random vectors x and y of length n. For a consistent pair of MatVec
+// and MatTransVec the difference is at the level of rounding errors.
+func dotTest(a MatrixOps, n int, rnd *rand.Rand) float64 {
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+		y[i] = rnd.NormFloat64()
+	}
+	ay := make([]float64, n)
+	atx := make([]float64, n)
+	a.MatVec(ay, y)
+	a.MatTransVec(atx, x)
+	lhs := floats.Dot(x, ay)
+	rhs := floats.Dot(atx, y)
+	return math.Abs(lhs-rhs) / math.Max(1, math.Abs(lhs))
+}
+
+func TestTransposed(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 5, 10, 50} {
+		a := randomDense(n, rnd)
+		for i := 0; i < n; i++ {
+			// Make A diagonally dominant so that BiCGSTAB converges.
+			a.Set(i, i, a.At(i, i)+float64(2*n))
+		}
+		ops := DenseMatrixOps(a)
+		at := Transposed(ops)
+		if diff := testOps(at, a.T(), rnd); diff > 0 {
+			t.Errorf("n=%v: unexpected products, |want-got|=%v", n, diff)
+		}
+		if diff := dotTest(at, n, rnd); diff > 1e-13 {
+			t.Errorf("n=%v: dot test failed, diff=%v", n, diff)
+		}
+		if diff := dotTest(AddedOps(at, ComposedOps(ops, at)), n, rnd); diff > 1e-13 {
+			t.Errorf("n=%v: dot test of composition failed, diff=%v", n, diff)
+		}
+		if diff := testOps(Transposed(at), a, rnd); diff > 0 {
+			t.Errorf("n=%v: transpose of transpose differs from original, |want-got|=%v", n, diff)
+		}
+
+		b := make([]float64, n)
+		for i := range b {
+			b[i] = rnd.NormFloat64()
+		}
+		var want mat.VecDense
+		err := want.SolveVec(a.T(), mat.NewVecDense(n, b))
+		if err != nil {
+			t.Fatalf("n=%v: unexpected dense solve error %v", n, err)
+		}
+		r, err := LinearSolve(at, b, &BiCGSTAB{}, Settings{Tolerance: 1e-12})
+		if err != nil {
+			t.Errorf("n=%v: unexpected error %v", n, err)
+			continue
+		}
+		if dist := floats.Distance(r.X, want.RawVector().Data, math.Inf(1)); dist > 1e-10 {
+			t.Errorf("n=%v: unexpected solution of transposed system, |want-got|=%v", n, dist)
+		}
+	}
+
+	if !panics(func() { Transposed(MatrixOps{MatVec: IdentityOps(2).MatVec}) }) {
+		t.Errorf("missing MatTransVec did not panic")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "testing"
+
+func TestAllocsPerIteration(t *testing.T) {
+	// The 1D Laplacian of order n converges slowly, so
+	// that all solves end by the iteration limit.
+	const n = 500
+	ab := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		ab[2*i] = 2
+		if i < n-1 {
+			ab[2*i+1] = -1
+		}
+	}
+	a := SymBandedOps(ab, n, 1)
+	b := make([]float64, n)
+	a.MatVec(b, ones(n))
+	p := DiagonalInverse(ones(n))
+	for _, test := range []struct {
+		name   string
+		method Method
+	}{
+		{"CG", &CG{}},
+		{"BiCG", &BiCG{}},
+		{"BiCGSTAB", &BiCGSTAB{}},
+		{"GMRES", &GMRES{}},
+		{"GMRES(8)", &GMRES{Restart: 8}},
+		{"GMRES(8) Householder", &GMRES{Restart: 8, Orthogonalization: Householder}},
+		{"GMRES(8) CGS2", &GMRES{Restart: 8, Orthogonalization: ClassicalGramSchmidt2}},
+	} {
+		for _, psolve := range []func(dst, rhs []float64) error{nil, p.Apply} {
+			allocs := func(iters int) float64 {
+				return testing.AllocsPerRun(5, func() {
+					LinearSolve(a, b, test.method, Settings{
+						Tolerance:     1e-15,
+						MaxIterations: iters,
+						PSolve:        psolve,
+						PSolveTrans:   psolve,
+					})
+				})
+			}
+			few, many := allocs(10), allocs(50)
+			if few != many {
+				t.Errorf("%v: %v allocations with 10 iterations and %v with 50", test.name, few, many)
+			}
+			// The Context, the solution and the
+			// residual.
+			if few > 3 {
+				t.Errorf("%v: %v allocations per solve, want 3", test.name, few)
+			}
+		}
+	}
+}
+
+// BenchmarkIterations measures the memory traffic of the iterations at a
+// large dimension, where every full pass over a vector counts.
+func BenchmarkIterations(b *testing.B) {
+	const n = 10000000
+	if testing.Short() {
+		b.Skip("skipping large system in short mode")
+	}
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	rhs := make([]float64, n)
+	a.MatVec(rhs, ones(n))
+	for _, test := range []struct {
+		name   string
+		method Method
+	}{
+		{"CG", &CG{}},
+		{"BiCG", &BiCG{}},
+		{"BiCGSTAB", &BiCGSTAB{}},
+		{"GMRES(10)", &GMRES{Restart: 10}},
+	} {
+		b.Run(test.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				LinearSolve(a, rhs, test.method, Settings{
+					Tolerance:     1e-15,
+					MaxIterations: 10,
+				})
+			}
+		})
+	}
+}
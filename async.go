@@ -0,0 +1,188 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrCanceled is the error of an asynchronous solve stopped by
+// AsyncSolve.Cancel.
+var ErrCanceled = errors.New("iterative: solve canceled")
+
+// Request is a matrix operation requested by an asynchronous solve.
+type Request struct {
+	// Op is MatVec or MatTransVec.
+	Op Operation
+	// Src is the vector to be multiplied and
+	// Dst is the vector where the result is
+	// to be stored. They are the vectors of
+	// the solve, not copies, and they are
+	// valid only until Reply is called. Src
+	// must not be modified.
+	Src, Dst []float64
+
+	reply chan<- error
+}
+
+// Reply reports that the operation is done and Dst holds the result. A
+// non-nil err stops the solve with that error. Reply must be called exactly
+// once for each request.
+func (r Request) Reply(err error) {
+	r.reply <- err
+}
+
+// AsyncResult is the result of an asynchronous solve.
+type AsyncResult struct {
+	Result
+	// Err is the error of the solve as
+	// returned by LinearSolve, ErrCanceled
+	// or the error of a Reply.
+	Err error
+}
+
+// AsyncSolve is a solve started by StartSolve. The products with the
+// matrix are requested on the channel returned by Requests and the final
+// result is sent on the channel returned by Result.
+//
+// The requests are sent one at a time in the order in which they are
+// needed. The next request is sent only after the previous one has been
+// replied to, so there is at most one outstanding request and the vectors
+// of a request are not accessed by the solve until Reply is called. The
+// requests channel is closed when the solve is done, after which the result
+// is sent and the result channel is closed.
+//
+// The caller must serve the requests until the requests channel is closed
+// or call Cancel, otherwise the goroutine of the solve is never released.
+type AsyncSolve struct {
+	requests chan Request
+	result   chan AsyncResult
+	reply    chan error
+
+	cancel chan struct{}
+	once   sync.Once
+
+	// err is the error of a Reply
+	// or ErrCanceled.
+	err error
+}
+
+// StartSolve starts solving the system of linear equations
+//  A*x = b
+// in a new goroutine and returns immediately. The products with A are
+// delegated to the caller through the requests of the returned AsyncSolve,
+// all other operations, including the preconditioner solves of settings,
+// are performed by the goroutine as in LinearSolve. ComputeResidual
+// operations are requested as MatVec with the approximate solution as Src.
+//
+// StartSolve returns an error for the settings for which LinearSolve
+// panics.
+func StartSolve(b []float64, method Method, settings Settings) (*AsyncSolve, error) {
+	dim := len(b)
+	if settings.X0 != nil && len(settings.X0) != dim {
+		return nil, errors.New("iterative: mismatched length of initial guess")
+	}
+	if settings.InPlace && settings.X0 == nil {
+		return nil, errors.New("iterative: nil initial guess for in-place solve")
+	}
+	if dim > 0 {
+		s := settings
+		defaultSettings(&s, dim)
+		if err := checkSettings(&s); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &AsyncSolve{
+		requests: make(chan Request),
+		result:   make(chan AsyncResult, 1),
+		reply:    make(chan error, 1),
+		cancel:   make(chan struct{}),
+	}
+	a := MatrixOps{
+		MatVec: func(dst, x []float64) {
+			s.request(MatVec, dst, x)
+		},
+		MatTransVec: func(dst, x []float64) {
+			s.request(MatTransVec, dst, x)
+		},
+	}
+	go s.run(a, b, method, settings)
+	return s, nil
+}
+
+// Requests returns the channel of the requested matrix operations.
+func (s *AsyncSolve) Requests() <-chan Request {
+	return s.requests
+}
+
+// Result returns the channel on which the result of the solve is sent
+// once.
+func (s *AsyncSolve) Result() <-chan AsyncResult {
+	return s.result
+}
+
+// Cancel stops the solve. The result is sent with the error ErrCanceled
+// unless the solve has already finished. No new request is sent after
+// Cancel returns. An outstanding request may still be replied to, but its
+// result is discarded. Cancel can be called more than once and
+// concurrently with the serving of the requests.
+func (s *AsyncSolve) Cancel() {
+	s.once.Do(func() { close(s.cancel) })
+}
+
+// run performs the solve in the goroutine started by StartSolve.
+func (s *AsyncSolve) run(a MatrixOps, b []float64, method Method, settings Settings) {
+	l := NewLoop(a, b, method, settings)
+	err := s.err
+	for err == nil {
+		var done bool
+		done, err = l.Step()
+		if s.err != nil {
+			err = s.err
+		}
+		if done {
+			break
+		}
+		select {
+		case <-s.cancel:
+			err = ErrCanceled
+		default:
+		}
+	}
+	close(s.requests)
+	s.result <- AsyncResult{Result: l.Result(), Err: err}
+	close(s.result)
+}
+
+// request sends the request for op and waits for the reply. After an
+// error, it does nothing and the Loop is stopped by run after the current
+// step.
+func (s *AsyncSolve) request(op Operation, dst, x []float64) {
+	if s.err != nil {
+		return
+	}
+	// Check the cancellation first, the select
+	// below chooses randomly if both are ready.
+	select {
+	case <-s.cancel:
+		s.err = ErrCanceled
+		return
+	default:
+	}
+	select {
+	case s.requests <- Request{Op: op, Src: x, Dst: dst, reply: s.reply}:
+	case <-s.cancel:
+		s.err = ErrCanceled
+		return
+	}
+	select {
+	case err := <-s.reply:
+		s.err = err
+	case <-s.cancel:
+		s.err = ErrCanceled
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// remote is a toy remote matrix server. It owns the matrix and multiplies
+// the copies of the vectors it receives in its own goroutine.
+type remote struct {
+	calls chan remoteCall
+}
+
+type remoteCall struct {
+	trans bool
+	x     []float64
+	out   chan []float64
+}
+
+func newRemote(a MatrixOps) *remote {
+	r := &remote{calls: make(chan remoteCall)}
+	go func() {
+		for c := range r.calls {
+			y := make([]float64, len(c.x))
+			if c.trans {
+				a.MatTransVec(y, c.x)
+			} else {
+				a.MatVec(y, c.x)
+			}
+			c.out <- y
+		}
+	}()
+	return r
+}
+
+// serve serves the requests of s with r until the solve is done and
+// returns its result.
+func (r *remote) serve(s *AsyncSolve) AsyncResult {
+	for req := range s.Requests() {
+		c := remoteCall{
+			trans: req.Op == MatTransVec,
+			x:     append([]float64(nil), req.Src...),
+			out:   make(chan []float64),
+		}
+		r.calls <- c
+		copy(req.Dst, <-c.out)
+		req.Reply(nil)
+	}
+	return <-s.Result()
+}
+
+func TestStartSolve(t *testing.T) {
+	const n = 100
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	r := newRemote(a)
+	defer close(r.calls)
+
+	b := make([]float64, n)
+	a.MatVec(b, ones(n))
+	x0 := make([]float64, n)
+	for i := range x0 {
+		x0[i] = math.Sin(float64(i))
+	}
+	for _, test := range []struct {
+		name     string
+		new      func() Method
+		settings Settings
+		fail     bool
+	}{
+		{"CG", func() Method { return &CG{} }, Settings{}, false},
+		{"CG", func() Method { return &CG{} }, Settings{X0: x0}, false},
+		{"BiCG", func() Method { return &BiCG{} }, Settings{X0: x0}, false},
+		{"BiCGSTAB", func() Method { return &BiCGSTAB{} }, Settings{Tolerance: 1e-10}, false},
+		{"GMRES(5)", func() Method { return &GMRES{Restart: 5} }, Settings{X0: x0}, false},
+		{"CG", func() Method { return &CG{} }, Settings{Tolerance: 1e-14, MaxIterations: 3}, true},
+	} {
+		want, wantErr := LinearSolve(a, b, test.new(), test.settings)
+		s, err := StartSolve(b, test.new(), test.settings)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.name, err)
+		}
+		got := r.serve(s)
+		if (got.Err != nil) != test.fail || (wantErr != nil) != test.fail {
+			t.Errorf("%v: unexpected errors %v and %v", test.name, wantErr, got.Err)
+		}
+		if !floats.Equal(got.X, want.X) {
+			t.Errorf("%v: solution differs from LinearSolve", test.name)
+		}
+		if got.Stats.Iterations != want.Stats.Iterations || got.Stats.MatVec != want.Stats.MatVec {
+			t.Errorf("%v: unexpected stats: want %+v, got %+v", test.name, want.Stats, got.Stats)
+		}
+		if _, ok := <-s.Result(); ok {
+			t.Errorf("%v: result channel not closed", test.name)
+		}
+	}
+}
+
+func TestStartSolveReplyError(t *testing.T) {
+	const n = 50
+	a := diagDominant(n)
+	s, err := StartSolve(ones(n), &CG{}, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errRemote := errors.New("remote failure")
+	var calls int
+	for req := range s.Requests() {
+		calls++
+		if calls == 3 {
+			req.Reply(errRemote)
+			continue
+		}
+		a.MatVec(req.Dst, req.Src)
+		req.Reply(nil)
+	}
+	res := <-s.Result()
+	if res.Err != errRemote {
+		t.Errorf("unexpected error: want %v, got %v", errRemote, res.Err)
+	}
+	if calls != 3 {
+		t.Errorf("unexpected number of requests after the error: %d", calls)
+	}
+	if res.Stats.Iterations != 2 {
+		t.Errorf("unexpected number of iterations: %d", res.Stats.Iterations)
+	}
+}
+
+func TestStartSolveCancel(t *testing.T) {
+	const n = 50
+	a := diagDominant(n)
+
+	// Cancel between requests.
+	s, err := StartSolve(ones(n), &CG{}, Settings{Tolerance: 1e-14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := <-s.Requests()
+	a.MatVec(req.Dst, req.Src)
+	req.Reply(nil)
+	s.Cancel()
+	s.Cancel()
+	for range s.Requests() {
+		t.Errorf("request after Cancel")
+	}
+	if res := <-s.Result(); res.Err != ErrCanceled {
+		t.Errorf("unexpected error: want %v, got %v", ErrCanceled, res.Err)
+	}
+
+	// Cancel with an outstanding request
+	// that is never replied to.
+	s, err = StartSolve(ones(n), &CG{}, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-s.Requests()
+	s.Cancel()
+	if res := <-s.Result(); res.Err != ErrCanceled {
+		t.Errorf("unexpected error: want %v, got %v", ErrCanceled, res.Err)
+	}
+}
+
+func TestStartSolveInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		b        []float64
+		settings Settings
+	}{
+		{"mismatched X0", ones(3), Settings{X0: ones(2)}},
+		{"in-place without X0", ones(3), Settings{InPlace: true}},
+		{"invalid tolerance", ones(3), Settings{Tolerance: 2}},
+		{"negative noise level", ones(3), Settings{NoiseLevel: -1}},
+		{"small discrepancy factor", ones(3), Settings{NoiseLevel: 1, DiscrepancyFactor: 0.5}},
+		{"negative rate window", ones(3), Settings{RateWindow: -1}},
+	} {
+		if _, err := StartSolve(test.b, &CG{}, test.settings); err == nil {
+			t.Errorf("%v: no error", test.name)
+		}
+	}
+}
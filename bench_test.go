@@ -0,0 +1,19 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"testing"
+
+	"github.com/vladimir-ch/iterative/internal/bench"
+)
+
+// BenchmarkSolve runs the solver benchmarks defined in internal/bench. Use
+// the iterbench command to print them as a comparison table.
+func BenchmarkSolve(b *testing.B) {
+	for _, c := range bench.Cases() {
+		b.Run(c.Name(), c.Run)
+	}
+}
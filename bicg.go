@@ -7,8 +7,6 @@ package iterative
 import (
 	"errors"
 	"math"
-
-	"github.com/gonum/floats"
 )
 
 // BiCG implements the biconjugate gradient iterative method with
@@ -51,7 +49,7 @@ func (b *BiCG) Iterate(ctx *Context) (Operation, error) {
 	switch b.resume {
 	case 1:
 		if b.first {
-			copy(b.rt, ctx.Residual)
+			ctx.vec.copy(b.rt, ctx.Residual)
 		}
 		ctx.Src = ctx.Residual
 		ctx.Dst = b.z
@@ -65,18 +63,22 @@ func (b *BiCG) Iterate(ctx *Context) (Operation, error) {
 		return PSolveTrans, nil
 		// Solve M^T zt = rt_{i-1}
 	case 3:
-		b.rho = floats.Dot(b.z, b.rt)
+		b.rho = ctx.vec.dot(b.z, b.rt)
 		if math.Abs(b.rho) < rhoBreakdownTol {
 			b.resume = 0 // Calling Iterate again without Init will panic.
 			return NoOperation, errors.New("BiCG: rho breakdown")
 		}
 		if !b.first {
 			beta := b.rho / b.rhoPrev
-			floats.AddScaled(b.z, beta, b.p)
-			floats.AddScaled(b.zt, beta, b.pt)
+			ctx.vec.addScaled(b.z, beta, b.p)
+			ctx.vec.addScaled(b.zt, beta, b.pt)
 		}
-		copy(b.p, b.z)
-		copy(b.pt, b.zt)
+		// p = z and pt = zt. The vectors are swapped
+		// instead of copied, z and zt are
+		// overwritten by the following MatVec and
+		// MatTransVec.
+		b.p, b.z = b.z, b.p
+		b.pt, b.zt = b.zt, b.pt
 		ctx.Src = b.p
 		ctx.Dst = b.z // == q
 		b.resume = 4
@@ -89,12 +91,12 @@ func (b *BiCG) Iterate(ctx *Context) (Operation, error) {
 		return MatTransVec, nil
 		// qt <- A^T pt
 	case 5:
-		b.alpha = b.rho / floats.Dot(b.pt, b.z)
-		floats.AddScaled(ctx.X, b.alpha, b.p)
-		floats.AddScaled(ctx.Residual, -b.alpha, b.z)
+		b.alpha = b.rho / ctx.vec.dot(b.pt, b.z)
+		ctx.vec.addScaled(ctx.X, b.alpha, b.p)
+		ctx.vec.addScaled(ctx.Residual, -b.alpha, b.z)
 		ctx.Src = nil
 		ctx.Dst = nil
-		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
+		ctx.ResidualNorm = ctx.vec.norm(ctx.Residual)
 		ctx.Converged = false
 		b.resume = 6
 		return CheckResidualNorm, nil
@@ -102,13 +104,15 @@ func (b *BiCG) Iterate(ctx *Context) (Operation, error) {
 		if ctx.Converged {
 			// Make sure calling Iterate again without Init will panic.
 			b.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
 			return EndIteration, nil
 		}
 		// Prepare for the next iteration.
-		floats.AddScaled(b.rt, -b.alpha, b.zt)
+		ctx.vec.addScaled(b.rt, -b.alpha, b.zt)
 		b.rhoPrev = b.rho
 		b.first = false
 		b.resume = 1
+		ctx.ResidualCurrent = true
 		return EndIteration, nil
 
 	default:
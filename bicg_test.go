@@ -2,30 +2,30 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package iterative
+package iterative_test
 
 import (
-	"math"
 	"math/rand"
 	"testing"
 
-	"github.com/gonum/floats"
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
 )
 
 func TestBiCG(t *testing.T) {
 	rnd := rand.New(rand.NewSource(1))
-	for _, tc := range []testCase{
-		randomSPD(1, rnd),
-		randomSPD(2, rnd),
-		randomSPD(3, rnd),
-		randomSPD(4, rnd),
-		randomSPD(5, rnd),
-		randomSPD(10, rnd),
-		randomSPD(20, rnd),
-		randomSPD(50, rnd),
-		randomSPD(100, rnd),
-		randomSPD(200, rnd),
-		randomSPD(500, rnd),
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(3, rnd),
+		problem.RandomSPD(4, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(10, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(50, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.RandomSPD(200, rnd),
+		problem.RandomSPD(500, rnd),
 		market("nos1", 1e-9),
 		market("nos4", 1e-12),
 		market("nos5", 1e-12),
@@ -70,28 +70,12 @@ func TestBiCG(t *testing.T) {
 		// market("nnc261", 1e-12),
 		market("arc130", 1e-4),
 	} {
-		n := tc.n
-		A := tc.a
-		// Compute the right-hand side b so that the vector [1,1,...,1]
-		// is the solution.
-		want := make([]float64, n)
-		for i := range want {
-			want[i] = 1
-		}
-		b := make([]float64, n)
-		A.MatVec(b, want)
-
-		r, err := LinearSolve(A, b, &BiCG{}, Settings{
-			MaxIterations: 10 * tc.iters,
+		_, err := p.Solve(&iterative.BiCG{}, iterative.Settings{
+			MaxIterations: 10 * p.MaxIterations,
 			Tolerance:     1e-14,
 		})
 		if err != nil {
-			t.Errorf("Case %v (n=%v): unexpected error %v", tc.name, n, err)
-			continue
-		}
-		dist := floats.Distance(r.X, want, math.Inf(1))
-		if dist > tc.tol {
-			t.Errorf("Case %v (n=%v): unexpected solution, |want-got|=%v", tc.name, n, dist)
+			t.Error(err)
 		}
 	}
 }
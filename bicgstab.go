@@ -7,8 +7,6 @@ package iterative
 import (
 	"errors"
 	"math"
-
-	"github.com/gonum/floats"
 )
 
 // BiCGSTAB implements the BiConjugate Gradient STABilized iterative method with
@@ -31,7 +29,6 @@ type BiCGSTAB struct {
 	v    []float64
 	t    []float64
 	phat []float64
-	s    []float64
 	shat []float64
 }
 
@@ -46,7 +43,6 @@ func (b *BiCGSTAB) Init(dim int) {
 	b.v = reuse(b.v, dim)
 	b.t = reuse(b.t, dim)
 	b.phat = reuse(b.phat, dim)
-	b.s = reuse(b.s, dim)
 	b.shat = reuse(b.shat, dim)
 	b.first = true
 	b.resume = 1
@@ -57,20 +53,20 @@ func (b *BiCGSTAB) Iterate(ctx *Context) (Operation, error) {
 	switch b.resume {
 	case 1:
 		if b.first {
-			copy(b.rt, ctx.Residual)
+			ctx.vec.copy(b.rt, ctx.Residual)
 		}
-		b.rho = floats.Dot(b.rt, ctx.Residual)
+		b.rho = ctx.vec.dot(b.rt, ctx.Residual)
 		if math.Abs(b.rho) < rhoBreakdownTol {
 			b.resume = 0 // Calling Iterate again without Init will panic.
 			return NoOperation, errors.New("BiCGSTAB: rho breakdown")
 		}
 		if b.first {
-			copy(b.p, ctx.Residual)
+			ctx.vec.copy(b.p, ctx.Residual)
 		} else {
 			beta := (b.rho / b.rhoPrev) * (b.alpha / b.omega)
-			floats.AddScaled(b.p, -b.omega, b.v) // p_i -= ω * v_i
-			floats.Scale(beta, b.p)              // p_i *= β
-			floats.Add(b.p, ctx.Residual)        // p_i += r_i
+			ctx.vec.addScaled(b.p, -b.omega, b.v) // p_i -= ω * v_i
+			ctx.vec.scale(beta, b.p)              // p_i *= β
+			ctx.vec.add(b.p, ctx.Residual)        // p_i += r_i
 		}
 		ctx.Src = b.p
 		ctx.Dst = b.phat
@@ -84,27 +80,30 @@ func (b *BiCGSTAB) Iterate(ctx *Context) (Operation, error) {
 		return MatVec, nil
 		// Compute Ap^_i -> v_i.
 	case 3:
-		b.alpha = b.rho / floats.Dot(b.rt, b.v)
+		b.alpha = b.rho / ctx.vec.dot(b.rt, b.v)
 		// Early check for tolerance.
-		floats.AddScaled(ctx.Residual, -b.alpha, b.v)
-		copy(b.s, ctx.Residual)
+		// The residual is updated in place to
+		// s_i = r_{i-1} - α v_i, there is no
+		// separate vector s.
+		rr := ctx.vec.axpyDot(-b.alpha, b.v, ctx.Residual)
 		ctx.Src = nil
 		ctx.Dst = nil
-		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
+		ctx.ResidualNorm = math.Sqrt(rr)
 		ctx.Converged = false
 		b.resume = 4
 		return CheckResidualNorm, nil
 	case 4:
 		if ctx.Converged {
-			floats.AddScaled(ctx.X, b.alpha, b.phat)
+			ctx.vec.addScaled(ctx.X, b.alpha, b.phat)
 			b.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
 			return EndIteration, nil
 		}
-		ctx.Src = b.s
+		ctx.Src = ctx.Residual
 		ctx.Dst = b.shat
 		b.resume = 5
 		return PSolve, nil
-		// Solve M s^_i = r_i.
+		// Solve M s^_i = s_i.
 	case 5:
 		ctx.Src = b.shat
 		ctx.Dst = b.t
@@ -112,19 +111,17 @@ func (b *BiCGSTAB) Iterate(ctx *Context) (Operation, error) {
 		return MatVec, nil
 		// Compute As^_i -> t_i.
 	case 6:
-		b.omega = floats.Dot(b.t, b.s) / floats.Dot(b.t, b.t)
-		floats.AddScaled(ctx.X, b.alpha, b.phat)
-		floats.AddScaled(ctx.X, b.omega, b.shat)
-		floats.AddScaled(ctx.Residual, -b.omega, b.t)
+		b.omega = ctx.vec.dot(b.t, ctx.Residual) / ctx.vec.dot(b.t, b.t)
+		ctx.ResidualNorm = ctx.vec.bicgstabUpdate(b.alpha, b.omega, ctx.X, b.phat, b.shat, ctx.Residual, b.t)
 		ctx.Src = nil
 		ctx.Dst = nil
-		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
 		ctx.Converged = false
 		b.resume = 7
 		return CheckResidualNorm, nil
 	case 7:
 		if ctx.Converged {
 			b.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
 			return EndIteration, nil
 		}
 		if math.Abs(b.omega) < omegaBreakdownTol {
@@ -133,6 +130,7 @@ func (b *BiCGSTAB) Iterate(ctx *Context) (Operation, error) {
 		b.rhoPrev = b.rho
 		b.first = false
 		b.resume = 1
+		ctx.ResidualCurrent = true
 		return EndIteration, nil
 
 	default:
@@ -0,0 +1,269 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// BiCGSTABL implements the BiCGSTAB(ℓ) iterative method with preconditioning
+// for solving the system of linear equations
+//  Ax = b,
+// where A is a non-symmetric matrix.
+//
+// BiCGSTAB(ℓ) combines ℓ steps of BiCG with a minimal residual polynomial
+// of degree ℓ instead of the degree one polynomial of BiCGSTAB, which is
+// the special case ℓ = 1. The higher-degree polynomials can approximate
+// the eigenvalues with large imaginary parts that make BiCGSTAB stagnate,
+// usually ℓ = 2 or 4 is enough. An iteration of BiCGSTABL does ℓ steps of
+// BiCG and needs 2ℓ products with A.
+//
+// BiCGSTABL is preconditioned from the right. If the recurrences break
+// down, Iterate returns a *BreakdownError.
+//
+// References:
+//  - Sleijpen, G.L.G., Fokkema, D.R.: BiCGstab(ℓ) for linear equations
+//    involving unsymmetric matrices with complex spectrum. Electron. Trans.
+//    Numer. Anal. 1, 11-32 (1993)
+//
+// BiCGSTABL needs MatVec and PSolve matrix operations.
+type BiCGSTABL struct {
+	// L is the degree ℓ of the minimal
+	// residual polynomials. If it is 0,
+	// 2 is used. It must not be negative.
+	L int
+
+	resume int
+	l      int
+	j      int // Counter for the BiCG steps.
+
+	rho, alpha, omega float64
+
+	rt []float64   // Shadow residual.
+	r  [][]float64 // r[0] is Context.Residual, r[j] = (AM^{-1})^j r[0].
+	u  [][]float64
+	xi []float64 // Correction of x before preconditioning.
+	z  []float64
+
+	tau    []float64 // ℓ×ℓ matrix τ.
+	sigma  []float64
+	gamma  []float64 // γ
+	gamma1 []float64 // γ'
+	gamma2 []float64 // γ''
+}
+
+// Init implements the Method interface.
+func (b *BiCGSTABL) Init(dim int) {
+	if dim <= 0 {
+		panic("BiCGSTABL: dimension not positive")
+	}
+	if b.L < 0 {
+		panic("BiCGSTABL: negative L")
+	}
+
+	b.l = b.L
+	if b.l == 0 {
+		b.l = 2
+	}
+	l := b.l
+
+	b.rt = reuse(b.rt, dim)
+	if cap(b.r) < l+1 {
+		b.r = make([][]float64, l+1)
+		b.u = make([][]float64, l+1)
+	} else {
+		b.r = b.r[:l+1]
+		b.u = b.u[:l+1]
+	}
+	for i := range b.u {
+		if i > 0 {
+			b.r[i] = reuse(b.r[i], dim)
+		}
+		b.u[i] = reuse(b.u[i], dim)
+	}
+	b.xi = reuse(b.xi, dim)
+	b.z = reuse(b.z, dim)
+
+	b.tau = reuse(b.tau, (l+1)*(l+1))
+	b.sigma = reuse(b.sigma, l+1)
+	b.gamma = reuse(b.gamma, l+1)
+	b.gamma1 = reuse(b.gamma1, l+1)
+	b.gamma2 = reuse(b.gamma2, l+1)
+
+	b.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (b *BiCGSTABL) Iterate(ctx *Context) (Operation, error) {
+	l := b.l
+	r, u := b.r, b.u
+	switch b.resume {
+	case 1:
+		// The residual is updated in place.
+		r[0] = ctx.Residual
+		ctx.vec.copy(b.rt, ctx.Residual)
+		for i := range u[0] {
+			u[0][i] = 0
+		}
+		b.rho = 1
+		b.alpha = 0
+		b.omega = 1
+		fallthrough
+	case 2:
+		// Start of an iteration.
+		for i := range b.xi {
+			b.xi[i] = 0
+		}
+		b.rho *= -b.omega
+
+		// The BiCG part.
+		// for j := 0; j < ℓ; j++ {
+		b.j = 0
+		fallthrough
+	case 3:
+		j := b.j
+		rho := ctx.vec.dot(r[j], b.rt)
+		if math.Abs(rho) < rhoBreakdownTol {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"BiCGSTABL", "rho"}
+		}
+		beta := b.alpha * rho / b.rho
+		b.rho = rho
+		for i := 0; i <= j; i++ {
+			// u_i = r_i - β u_i
+			ctx.vec.scale(-beta, u[i])
+			ctx.vec.add(u[i], r[i])
+		}
+		ctx.Src = u[j]
+		ctx.Dst = b.z
+		b.resume = 4
+		return PSolve, nil
+		// Solve M z = u_j.
+	case 4:
+		ctx.Src = b.z
+		ctx.Dst = u[b.j+1]
+		b.resume = 5
+		return MatVec, nil
+		// Compute u_{j+1} = Az.
+	case 5:
+		j := b.j
+		b.alpha = b.rho / ctx.vec.dot(u[j+1], b.rt)
+		for i := 0; i <= j; i++ {
+			ctx.vec.addScaled(r[i], -b.alpha, u[i+1]) // r_i -= α u_{i+1}
+		}
+		ctx.vec.addScaled(b.xi, b.alpha, u[0]) // ξ += α u_0
+		// Early check for tolerance.
+		ctx.ResidualNorm = ctx.vec.norm(r[0])
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		b.resume = 6
+		return CheckResidualNorm, nil
+	case 6:
+		if ctx.Converged {
+			ctx.Src = b.xi
+			ctx.Dst = b.z
+			b.resume = 11
+			return PSolve, nil
+			// Solve M z = ξ.
+		}
+		ctx.Src = r[b.j]
+		ctx.Dst = b.z
+		b.resume = 7
+		return PSolve, nil
+		// Solve M z = r_j.
+	case 7:
+		ctx.Src = b.z
+		ctx.Dst = r[b.j+1]
+		b.j++
+		if b.j < l {
+			b.resume = 3 // Continue the BiCG part.
+		} else {
+			b.resume = 8
+		}
+		return MatVec, nil
+		// Compute r_{j+1} = Az.
+	case 8:
+		// The minimal residual part. Orthogonalize r_1, ..., r_ℓ
+		// with the modified Gram-Schmidt process.
+		tau := func(i, j int) *float64 { return &b.tau[i*(l+1)+j] }
+		for j := 1; j <= l; j++ {
+			for i := 1; i < j; i++ {
+				t := ctx.vec.dot(r[j], r[i]) / b.sigma[i]
+				*tau(i, j) = t
+				ctx.vec.addScaled(r[j], -t, r[i])
+			}
+			b.sigma[j] = ctx.vec.dot(r[j], r[j])
+			if b.sigma[j] == 0 {
+				// For ℓ = 1, σ_1 is the denominator
+				// of ω in BiCGSTAB.
+				b.resume = 0 // Calling Iterate again without Init will panic.
+				return NoOperation, &BreakdownError{"BiCGSTABL", "omega"}
+			}
+			b.gamma1[j] = ctx.vec.dot(r[0], r[j]) / b.sigma[j]
+		}
+		b.gamma[l] = b.gamma1[l]
+		b.omega = b.gamma[l]
+		for j := l - 1; j >= 1; j-- {
+			g := b.gamma1[j]
+			for i := j + 1; i <= l; i++ {
+				g -= *tau(j, i) * b.gamma[i]
+			}
+			b.gamma[j] = g
+		}
+		for j := 1; j < l; j++ {
+			g := b.gamma[j+1]
+			for i := j + 1; i < l; i++ {
+				g += *tau(j, i) * b.gamma[i+1]
+			}
+			b.gamma2[j] = g
+		}
+
+		// Update the solution, the residual and u_0.
+		ctx.vec.addScaled(b.xi, b.gamma[1], r[0])
+		ctx.vec.addScaled(r[0], -b.gamma1[l], r[l])
+		ctx.vec.addScaled(u[0], -b.gamma[l], u[l])
+		for j := 1; j < l; j++ {
+			ctx.vec.addScaled(u[0], -b.gamma[j], u[j])
+			ctx.vec.addScaled(b.xi, b.gamma2[j], r[j])
+			ctx.vec.addScaled(r[0], -b.gamma1[j], r[j])
+		}
+		ctx.Src = b.xi
+		ctx.Dst = b.z
+		b.resume = 9
+		return PSolve, nil
+		// Solve M z = ξ.
+	case 9:
+		ctx.vec.add(ctx.X, b.z)
+		ctx.ResidualNorm = ctx.vec.norm(r[0])
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		b.resume = 10
+		return CheckResidualNorm, nil
+	case 10:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		// ω = γ_ℓ scales with the ℓ-th power of the
+		// inverse of the scale of A, so unlike in
+		// BiCGSTAB it is not compared with a fixed
+		// tolerance.
+		if b.omega == 0 {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"BiCGSTABL", "omega"}
+		}
+		b.resume = 2
+		return EndIteration, nil
+	case 11:
+		ctx.vec.add(ctx.X, b.z)
+		ctx.ResidualCurrent = true
+		b.resume = 0 // Calling Iterate again without Init will panic.
+		return EndIteration, nil
+
+	default:
+		panic("BiCGSTABL: Init not called")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestBiCGSTABL(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, l := range []int{1, 2, 4} {
+		for _, p := range []*problem.Problem{
+			problem.RandomSPD(1, rnd),
+			problem.RandomSPD(2, rnd),
+			problem.RandomSPD(5, rnd),
+			problem.RandomSPD(20, rnd),
+			problem.RandomSPD(100, rnd),
+			market("nos1", 1e-8),
+			market("nos4", 1e-11),
+			market("nos5", 1e-9),
+			market("bcsstm22", 1e-10),
+			market("e05r0000", 1e-10),
+			market("e05r0100", 1e-9),
+			market("gre__115", 1e-12),
+			market("gre__185", 1e-7),
+			market("arc130", 1e-4),
+		} {
+			_, err := p.Solve(&iterative.BiCGSTABL{L: l}, iterative.Settings{
+				MaxIterations: 10 * p.MaxIterations,
+				Tolerance:     1e-14,
+			})
+			if err != nil {
+				t.Errorf("L=%d: %v", l, err)
+			}
+		}
+	}
+}
+
+func TestBiCGSTABLComplexSpectrum(t *testing.T) {
+	// BiCGSTAB breaks down or stagnates on these
+	// matrices, BiCGSTAB(2) converges. fs_183_*
+	// and west0132 are out of reach of both.
+	for _, name := range []string{
+		"hor__131",
+		"impcol_b",
+		"impcol_c",
+		"west0067",
+	} {
+		settings := func(p *problem.Problem) iterative.Settings {
+			return iterative.Settings{
+				MaxIterations: 10 * p.MaxIterations,
+				Tolerance:     1e-14,
+			}
+		}
+		p := market(name, 1e-6)
+		if _, err := p.Solve(&iterative.BiCGSTAB{}, settings(p)); err == nil {
+			t.Errorf("%s: BiCGSTAB converged", name)
+		}
+		p = market(name, 1e-6)
+		if _, err := p.Solve(&iterative.BiCGSTABL{L: 2}, settings(p)); err != nil {
+			t.Errorf("BiCGSTAB(2): %v", err)
+		}
+	}
+}
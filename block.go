@@ -0,0 +1,468 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BlockMethod is an iterative method that solves a system of linear
+// equations with multiple right-hand sides
+//  A X = B,
+// where A is a non-singular dim×dim matrix, and X and B are dim×nrhs
+// matrices, by operating on blocks of vectors.
+//
+// BlockMethod uses the same reverse-communication interface as Method with
+// BlockContext in place of Context. MatVec and PSolve act on BlockContext.K
+// vectors at once, so that the caller can compute the products with A of
+// the whole block in one call to MatrixOps.MatMatVec.
+type BlockMethod interface {
+	// InitBlock initializes the method for
+	// solving a dim×dim linear system with
+	// nrhs right-hand sides.
+	InitBlock(dim, nrhs int)
+
+	// IterateBlock retrieves data from
+	// BlockContext, updates it, and returns
+	// the next operation. The caller must
+	// perform the Operation using data in
+	// BlockContext, and depending on the
+	// state call IterateBlock again.
+	IterateBlock(*BlockContext) (Operation, error)
+}
+
+// BlockContext mediates the communication between the BlockMethod and the
+// caller. It must not be modified or accessed apart from the commanded
+// Operations. The matrices in BlockContext are stored column-major, the
+// j-th column of the dim×k matrix X is X[j*dim:(j+1)*dim].
+type BlockContext struct {
+	// X is the current dim×nrhs approximate
+	// solution. On the first call to
+	// BlockMethod.IterateBlock, X must
+	// contain the initial estimate. Method
+	// must update X with the current
+	// estimate when it commands
+	// EndIteration.
+	X []float64
+	// Residual is the current dim×nrhs
+	// residual B-A*X. On the first call to
+	// BlockMethod.IterateBlock, Residual must
+	// contain the initial residual.
+	Residual []float64
+	// ResidualNorms are (estimates of) the
+	// norms of the columns of the current
+	// residual. Method must update the norms
+	// of the columns that are not converged
+	// when it commands CheckResidualNorm.
+	ResidualNorms []float64
+	// Converged indicates to Method which
+	// columns satisfy the stopping criterion.
+	// The caller sets it before the first
+	// call to BlockMethod.IterateBlock for
+	// the columns whose initial residual is
+	// small enough, and as a result of
+	// CheckResidualNorm for the others. A
+	// converged column stays converged and
+	// Method does not have to update it any
+	// more. If all columns are converged
+	// when Method commands EndIteration, the
+	// caller must not call IterateBlock again
+	// without calling InitBlock first.
+	Converged []bool
+	// Restarts is the number of restarts of
+	// a restarted method such as BlockGMRES.
+	// Method increments it when it restarts,
+	// and the caller reports it in Stats.
+	Restarts int
+
+	// K is the number of columns of Src and
+	// Dst, between 1 and nrhs.
+	K int
+	// Src and Dst are the dim×K source and
+	// destination matrices of MatVec and
+	// PSolve. For PSolve, the preconditioner
+	// is applied to each column of Src.
+	Src, Dst []float64
+
+	// vec does the vector operations of the
+	// methods in this package. It is set by
+	// LinearSolveBlock.
+	vec *vecOps
+}
+
+// LinearSolveBlock solves the system of n linear equations with nrhs
+// right-hand sides
+//  A*X = B,
+// where the n×n matrix A is represented by the matrix-vector operations
+// in a, and the n×nrhs matrix B is stored column-major in b. The dimension
+// of the problem n is determined by the length of b and nrhs.
+//
+// method is a block iterative method used for finding an approximate
+// solution. It must not be nil. LinearSolveBlock computes the products
+// with A of each block commanded by the method in one call to a.MatMatVec
+// if it is available and column by column with a.MatVec otherwise.
+//
+// settings have the same meaning as in LinearSolve applied to each column
+// of B, X0 is an n×nrhs matrix stored column-major. The iterations stop
+// when all columns satisfy the stopping criterion. Weight is not supported
+// and must be nil, and A must be square. The columns of B whose norm is
+// very large or very small are scaled as in LinearSolve.
+//
+// Result.X is the n×nrhs approximate solution stored column-major.
+// Stats.ResidualNorms holds the final residual norms of the columns and
+// Stats.ResidualNorm the largest of them. Stats.MatVec and Stats.PSolve
+// count the products with A and the preconditioner solves column by
+// column, a block product of k columns counts k.
+func LinearSolveBlock(a MatrixOps, b []float64, nrhs int, method BlockMethod, settings Settings) (Result, error) {
+	l := newBlockLoop(a, b, nrhs, method, settings)
+	if l.done {
+		return l.result(), l.err
+	}
+	method.InitBlock(l.n, l.nrhs)
+	for {
+		op, err := l.step()
+		if err != nil || (op == EndIteration && l.allConverged()) {
+			l.finish(err)
+			return l.result(), err
+		}
+	}
+}
+
+// blockLoop is the reverse-communication loop of LinearSolveBlock.
+type blockLoop struct {
+	a        MatrixOps
+	b        []float64
+	n, nrhs  int
+	method   BlockMethod
+	settings Settings
+
+	ctx   BlockContext
+	stats Stats
+
+	// bnorm and xnorm are the norms of the
+	// columns of B and X of the original
+	// system, xnorm is updated at the end of
+	// each iteration if NormA is set.
+	bnorm []float64
+	xnorm []float64
+	// scale are the factors by which the
+	// columns of the system are scaled, see
+	// scaleFor.
+	scale []float64
+
+	// rnorm0 is the largest initial residual
+	// norm of the columns and recent holds
+	// the largest residual norms of the last
+	// Settings.RateWindow+1 iterations as in
+	// Loop.
+	rnorm0 float64
+	recent []float64
+
+	done bool
+	err  error
+}
+
+func newBlockLoop(a MatrixOps, b []float64, nrhs int, method BlockMethod, settings Settings) *blockLoop {
+	if a.MatVec == nil {
+		panic("iterative: nil matrix-vector multiplication")
+	}
+	if nrhs <= 0 {
+		panic("iterative: number of right-hand sides not positive")
+	}
+	if len(b)%nrhs != 0 {
+		panic("iterative: length of right-hand sides not a multiple of nrhs")
+	}
+	n := len(b) / nrhs
+	if rows, cols := a.dims(n); rows != cols {
+		panic("iterative: block solve of rectangular system")
+	}
+	if settings.Weight != nil {
+		panic("iterative: weight not supported by block solve")
+	}
+	if settings.X0 != nil && len(settings.X0) != n*nrhs {
+		panic("iterative: mismatched length of initial guess")
+	}
+	if settings.InPlace && settings.X0 == nil {
+		panic("iterative: nil initial guess for in-place solve")
+	}
+
+	l := &blockLoop{
+		a:      a,
+		b:      b,
+		n:      n,
+		nrhs:   nrhs,
+		method: method,
+		stats:  Stats{StartTime: time.Now()},
+	}
+	vec := newVecOps(settings.Threads)
+	ctx := &l.ctx
+	ctx.vec = vec
+	if settings.InPlace {
+		ctx.X = settings.X0
+	} else {
+		ctx.X = make([]float64, n*nrhs)
+	}
+	ctx.ResidualNorms = make([]float64, nrhs)
+	ctx.Converged = make([]bool, nrhs)
+	l.scale = make([]float64, nrhs)
+	for j := range l.scale {
+		l.scale[j] = 1
+	}
+	if n == 0 {
+		l.settings = settings
+		l.finish(nil)
+		return l
+	}
+
+	defaultSettings(&settings, n)
+	if err := checkSettings(&settings); err != nil {
+		panic(err.Error())
+	}
+	l.settings = settings
+
+	if settings.Debug && settings.PSolve != nil && blockNeedsSPDPreconditioner(method) {
+		p := psolver{psolve: settings.PSolve, psolveTrans: settings.PSolveTrans}
+		err := CheckSymmetricPreconditioner(p, n, rand.New(rand.NewSource(1)))
+		if err != nil {
+			l.finish(err)
+			return l
+		}
+	}
+
+	if settings.X0 != nil && !settings.InPlace {
+		vec.copy(ctx.X, settings.X0)
+	}
+	l.bnorm = make([]float64, nrhs)
+	scaled := false
+	for j := range l.scale {
+		bj := b[j*n : (j+1)*n]
+		l.bnorm[j] = vec.norm(bj)
+		l.scale[j] = scaleFor(l.bnorm[j])
+		if l.bnorm[j] == 0 {
+			l.bnorm[j] = 1
+		}
+		if l.scale[j] == 1 {
+			continue
+		}
+		if !scaled {
+			// Solve A*(s*x) = s*b for each
+			// column as in Loop.
+			l.b = make([]float64, n*nrhs)
+			vec.copy(l.b, b)
+			scaled = true
+		}
+		vec.scale(l.scale[j], l.b[j*n:(j+1)*n])
+		vec.scale(l.scale[j], ctx.X[j*n:(j+1)*n])
+	}
+	b = l.b
+
+	ctx.Residual = make([]float64, n*nrhs)
+	if settings.X0 != nil {
+		matMatVec(a, ctx.Residual, ctx.X, n, nrhs)
+		l.stats.MatVec += nrhs
+		vec.addScaledTo(ctx.Residual, b, -1, ctx.Residual) // R = B - AX
+	} else {
+		vec.copy(ctx.Residual, b) // R = B
+	}
+
+	for j := range ctx.ResidualNorms {
+		rnorm := vec.norm(ctx.Residual[j*n : (j+1)*n])
+		ctx.ResidualNorms[j] = rnorm
+		l.rnorm0 = math.Max(l.rnorm0, rnorm/l.scale[j])
+		// The initial residual is compared in
+		// absolute terms after scaling as in
+		// Loop.
+		ctx.Converged[j] = l.settings.converged(rnorm/l.scale[j], 1/l.scale[j], 0)
+	}
+	l.stats.ResidualNorm = l.rnorm0
+	if settings.RateWindow > 0 {
+		l.recent = make([]float64, settings.RateWindow+1)
+		l.recent[0] = l.rnorm0
+	}
+	if l.allConverged() {
+		l.finish(nil)
+		return l
+	}
+
+	if l.settings.NormA == 0 && l.settings.EstimateNormA {
+		counted := MatrixOps{
+			MatVec: func(dst, x []float64) {
+				a.MatVec(dst, x)
+				l.stats.MatVec++
+			},
+		}
+		if a.MatTransVec != nil {
+			counted.MatTransVec = func(dst, x []float64) {
+				a.MatTransVec(dst, x)
+				l.stats.MatVec++
+			}
+		}
+		l.settings.NormA = EstimateTwoNorm(counted, n, 20, rand.New(rand.NewSource(1)))
+	}
+	l.xnorm = make([]float64, nrhs)
+	l.updateXNorm()
+	return l
+}
+
+// step calls BlockMethod.IterateBlock once and performs the returned
+// operation.
+func (l *blockLoop) step() (Operation, error) {
+	ctx := &l.ctx
+	n := l.n
+	settings := &l.settings
+	stats := &l.stats
+
+	op, err := l.method.IterateBlock(ctx)
+	if err != nil {
+		return op, err
+	}
+	if err := checkBlockOperation(op, ctx, n, l.nrhs); err != nil {
+		return op, fmt.Errorf("iterative: %T: %v", l.method, err)
+	}
+
+	switch op {
+	case NoOperation:
+
+	case MatVec:
+		matMatVec(l.a, ctx.Dst, ctx.Src, n, ctx.K)
+		stats.MatVec += ctx.K
+
+	case PSolve:
+		if settings.PSolve == nil {
+			ctx.vec.copy(ctx.Dst, ctx.Src)
+			return op, nil
+		}
+		for j := 0; j < ctx.K; j++ {
+			err := settings.PSolve(ctx.Dst[j*n:(j+1)*n], ctx.Src[j*n:(j+1)*n])
+			if err != nil {
+				return op, err
+			}
+		}
+		stats.PSolve += ctx.K
+
+	case CheckResidualNorm:
+		for j, rnorm := range ctx.ResidualNorms {
+			if !ctx.Converged[j] {
+				ctx.Converged[j] = settings.converged(rnorm/l.scale[j], l.bnorm[j], l.xnorm[j])
+			}
+		}
+
+	case EndIteration:
+		stats.Iterations++
+		stats.Restarts = ctx.Restarts
+		stats.ResidualNorm = 0
+		for j, rnorm := range ctx.ResidualNorms {
+			stats.ResidualNorm = math.Max(stats.ResidualNorm, rnorm/l.scale[j])
+		}
+		if l.recent != nil {
+			l.recent[stats.Iterations%len(l.recent)] = stats.ResidualNorm
+		}
+		l.updateXNorm()
+		if !l.allConverged() && stats.Iterations == settings.MaxIterations {
+			return op, errIterationLimit
+		}
+	}
+	return op, nil
+}
+
+// allConverged returns whether all columns of the system are converged.
+func (l *blockLoop) allConverged() bool {
+	for _, c := range l.ctx.Converged {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+// updateXNorm updates the norms of the columns of X that are not converged
+// if they are used in the stopping criterion.
+func (l *blockLoop) updateXNorm() {
+	if l.settings.NormA == 0 {
+		return
+	}
+	n := l.n
+	for j, c := range l.ctx.Converged {
+		if !c {
+			l.xnorm[j] = l.ctx.vec.norm(l.ctx.X[j*n:(j+1)*n]) / l.scale[j]
+		}
+	}
+}
+
+// finish marks the solve as done with the given error, undoes the scaling
+// and computes the convergence rates.
+func (l *blockLoop) finish(err error) {
+	l.done = true
+	l.err = err
+	l.stats.Runtime = time.Since(l.stats.StartTime)
+	ctx := &l.ctx
+	n := l.n
+	for j, s := range l.scale {
+		if s != 1 {
+			ctx.vec.scale(1/s, ctx.X[j*n:(j+1)*n])
+			ctx.vec.scale(1/s, ctx.Residual[j*n:(j+1)*n])
+			ctx.ResidualNorms[j] /= s
+		}
+	}
+	l.stats.ResidualNorms = ctx.ResidualNorms
+
+	k := l.stats.Iterations
+	l.stats.ConvergenceRate = rate(l.rnorm0, l.stats.ResidualNorm, k)
+	l.stats.RecentRate = math.NaN()
+	if l.recent != nil {
+		w := len(l.recent) - 1
+		if k <= w {
+			l.stats.RecentRate = l.stats.ConvergenceRate
+		} else {
+			l.stats.RecentRate = rate(l.recent[(k-w)%len(l.recent)], l.stats.ResidualNorm, w)
+		}
+	}
+}
+
+// result returns the result of the solve.
+func (l *blockLoop) result() Result {
+	return Result{
+		X:     l.ctx.X,
+		Stats: l.stats,
+	}
+}
+
+// blockNeedsSPDPreconditioner returns whether method requires the
+// preconditioner to be symmetric positive definite.
+func blockNeedsSPDPreconditioner(method BlockMethod) bool {
+	_, ok := method.(*BlockCG)
+	return ok
+}
+
+// checkBlockOperation returns an error if op is not supported by
+// LinearSolveBlock or if the blocks in ctx are not valid for it.
+func checkBlockOperation(op Operation, ctx *BlockContext, n, nrhs int) error {
+	switch op {
+	case NoOperation, CheckResidualNorm, EndIteration:
+		return nil
+	case MatVec, PSolve:
+	default:
+		return fmt.Errorf("%v not supported by block solve", op)
+	}
+	if ctx.K < 1 || nrhs < ctx.K {
+		return fmt.Errorf("%v with %d columns, want 1 to %d", op, ctx.K, nrhs)
+	}
+	if ctx.Src == nil {
+		return fmt.Errorf("%v with nil Src", op)
+	}
+	if ctx.Dst == nil {
+		return fmt.Errorf("%v with nil Dst", op)
+	}
+	if len(ctx.Src) != n*ctx.K {
+		return fmt.Errorf("%v with Src of length %d, want %d", op, len(ctx.Src), n*ctx.K)
+	}
+	if len(ctx.Dst) != n*ctx.K {
+		return fmt.Errorf("%v with Dst of length %d, want %d", op, len(ctx.Dst), n*ctx.K)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/lapack/lapack64"
+)
+
+// rankTol is the relative norm below which a new search direction of
+// BlockCG is considered linearly dependent on the others and dropped.
+const rankTol = 1e-10
+
+// BlockCG implements the block Conjugate Gradient method with
+// preconditioning for solving the system of linear equations with multiple
+// right-hand sides
+//  AX = B,
+// where A is a symmetric positive definite matrix. BlockCG is a
+// BlockMethod, it is used with LinearSolveBlock.
+//
+// BlockCG searches for the columns of X in the sum of the Krylov subspaces
+// of all columns of the initial residual, so it needs fewer iterations than
+// CG applied to each column separately, and the products with A of all
+// search directions of an iteration are done as one block product.
+//
+// The search directions of the columns become nearly linearly dependent as
+// the columns converge, which makes the original block CG by O'Leary
+// unstable. BlockCG therefore orthonormalizes the search directions in
+// each iteration and drops those that are linearly dependent on the others
+// as in the breakdown-free block CG by Ji and Li, so the number of search
+// directions can decrease. The columns that satisfy the stopping criterion
+// are deflated, they are no longer updated and their residuals no longer
+// contribute new search directions.
+//
+// If BlockCG encounters a block of search directions P such that P^T A P
+// is not positive definite, the matrix is not positive definite and
+// IterateBlock returns a *NotPositiveDefiniteError.
+//
+// References:
+//  - O'Leary, D.P.: The block conjugate gradient algorithm and related
+//    methods. Linear Algebra Appl. 29, 293-322 (1980)
+//  - Ji, H., Li, Y.: A breakdown-free block conjugate gradient method. BIT
+//    Numer. Math. 57(2), 379-403 (2017)
+//
+// BlockCG needs MatVec and PSolve matrix operations.
+type BlockCG struct {
+	resume int
+	first  bool
+
+	n, nrhs int
+	k       int   // Number of search directions.
+	active  []int // Columns that are not converged.
+
+	p []float64 // n×k orthonormal search directions.
+	q []float64 // AP.
+	z []float64 // M^{-1} R of the active columns.
+	t []float64 // Residuals of the active columns.
+
+	ptap []float64 // Cholesky factor of P^T A P, nrhs×nrhs row-major.
+	c    []float64 // Coefficients α and β, one row per active column.
+}
+
+// InitBlock implements the BlockMethod interface.
+func (cg *BlockCG) InitBlock(dim, nrhs int) {
+	if dim <= 0 {
+		panic("BlockCG: dimension not positive")
+	}
+	if nrhs <= 0 {
+		panic("BlockCG: number of right-hand sides not positive")
+	}
+
+	cg.n = dim
+	cg.nrhs = nrhs
+	cg.p = reuse(cg.p, dim*nrhs)
+	cg.q = reuse(cg.q, dim*nrhs)
+	cg.z = reuse(cg.z, dim*nrhs)
+	cg.t = reuse(cg.t, dim*nrhs)
+	cg.ptap = reuse(cg.ptap, nrhs*nrhs)
+	cg.c = reuse(cg.c, nrhs*nrhs)
+	if cap(cg.active) < nrhs {
+		cg.active = make([]int, 0, nrhs)
+	}
+	cg.first = true
+	cg.resume = 1
+}
+
+// IterateBlock implements the BlockMethod interface.
+func (cg *BlockCG) IterateBlock(ctx *BlockContext) (Operation, error) {
+	n := cg.n
+	switch cg.resume {
+	case 1:
+		// Deflate the converged columns.
+		cg.active = cg.active[:0]
+		for j, c := range ctx.Converged {
+			if !c {
+				cg.active = append(cg.active, j)
+			}
+		}
+		s := len(cg.active)
+		for jj, j := range cg.active {
+			ctx.vec.copy(cg.t[jj*n:(jj+1)*n], ctx.Residual[j*n:(j+1)*n])
+		}
+		ctx.K = s
+		ctx.Src = cg.t[:n*s]
+		ctx.Dst = cg.z[:n*s]
+		cg.resume = 2
+		return PSolve, nil
+		// Solve M Z = R
+	case 2:
+		s := len(cg.active)
+		if !cg.first {
+			// Z -= P β with β = (P^T A P)^{-1} (AP)^T Z
+			// makes Z A-orthogonal to P.
+			for jj := 0; jj < s; jj++ {
+				zj := cg.z[jj*n : (jj+1)*n]
+				cj := cg.c[jj*cg.nrhs : jj*cg.nrhs+cg.k]
+				for i := range cj {
+					cj[i] = ctx.vec.dot(cg.q[i*n:(i+1)*n], zj)
+				}
+			}
+			cg.solve(s)
+			for jj := 0; jj < s; jj++ {
+				cj := cg.c[jj*cg.nrhs : jj*cg.nrhs+cg.k]
+				floats.Scale(-1, cj)
+				ctx.vec.addMul(cg.z[jj*n:(jj+1)*n], cg.p, n, cj)
+			}
+		}
+		// Orthonormalize Z into the new P and drop the
+		// linearly dependent directions.
+		cg.k = 0
+		for jj := 0; jj < s; jj++ {
+			pk := cg.p[cg.k*n : (cg.k+1)*n]
+			ctx.vec.copy(pk, cg.z[jj*n:(jj+1)*n])
+			norm := ctx.vec.norm(pk)
+			pnorm := norm
+			if cg.k > 0 {
+				h := cg.c[:cg.k]
+				pnorm = ctx.vec.cgs(h, cg.p, n, pk)
+				if pnorm < reorthTol*norm {
+					pnorm = ctx.vec.cgs(h, cg.p, n, pk)
+				}
+			}
+			if pnorm <= rankTol*norm || pnorm == 0 {
+				continue
+			}
+			ctx.vec.scale(1/pnorm, pk)
+			cg.k++
+		}
+		if cg.k == 0 {
+			cg.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+			return NoOperation, &BreakdownError{"BlockCG", "P"}
+		}
+		ctx.K = cg.k
+		ctx.Src = cg.p[:n*cg.k]
+		ctx.Dst = cg.q[:n*cg.k]
+		cg.resume = 3
+		return MatVec, nil
+		// Compute Q = AP
+	case 3:
+		k := cg.k
+		ld := cg.nrhs
+		for i := 0; i < k; i++ {
+			for l := i; l < k; l++ {
+				cg.ptap[i*ld+l] = ctx.vec.dot(cg.p[i*n:(i+1)*n], cg.q[l*n:(l+1)*n])
+			}
+		}
+		_, ok := lapack64.Potrf(blas64.Symmetric{
+			Uplo:   blas.Upper,
+			N:      k,
+			Stride: ld,
+			Data:   cg.ptap,
+		})
+		if !ok {
+			cg.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "BlockCG"}
+		}
+		// α = (P^T A P)^{-1} P^T R
+		for jj, j := range cg.active {
+			rj := ctx.Residual[j*n : (j+1)*n]
+			cj := cg.c[jj*ld : jj*ld+k]
+			for i := range cj {
+				cj[i] = ctx.vec.dot(cg.p[i*n:(i+1)*n], rj)
+			}
+		}
+		cg.solve(len(cg.active))
+		for jj, j := range cg.active {
+			cj := cg.c[jj*ld : jj*ld+k]
+			// X += P α
+			ctx.vec.addMul(ctx.X[j*n:(j+1)*n], cg.p, n, cj)
+			// R -= Q α
+			floats.Scale(-1, cj)
+			rj := ctx.Residual[j*n : (j+1)*n]
+			ctx.vec.addMul(rj, cg.q, n, cj)
+			ctx.ResidualNorms[j] = ctx.vec.norm(rj)
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		cg.resume = 4
+		return CheckResidualNorm, nil
+	case 4:
+		for _, j := range cg.active {
+			if !ctx.Converged[j] {
+				cg.first = false
+				cg.resume = 1
+				return EndIteration, nil
+			}
+		}
+		cg.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+		return EndIteration, nil
+
+	default:
+		panic("BlockCG: InitBlock not called")
+	}
+}
+
+// solve overwrites the first s rows of the coefficients c with the
+// solution Y of
+//  Y (P^T A P) = C
+// using the Cholesky factorization P^T A P = U^T U. Since P^T A P is
+// symmetric, the rows of Y are the solutions for the rows of C.
+func (cg *BlockCG) solve(s int) {
+	u := blas64.Triangular{
+		Uplo:   blas.Upper,
+		Diag:   blas.NonUnit,
+		N:      cg.k,
+		Stride: cg.nrhs,
+		Data:   cg.ptap,
+	}
+	c := blas64.General{
+		Rows:   s,
+		Cols:   cg.k,
+		Stride: cg.nrhs,
+		Data:   cg.c,
+	}
+	blas64.Trsm(blas.Right, blas.NoTrans, 1, u, c) // C U^{-1}
+	blas64.Trsm(blas.Right, blas.Trans, 1, u, c)   // C U^{-1} U^{-T}
+}
@@ -0,0 +1,231 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+// blockRHS returns the column-major n×nrhs right-hand sides A*X of the
+// random n×nrhs solutions X.
+func blockRHS(a iterative.MatrixOps, n, nrhs int, rnd *rand.Rand) (b, x []float64) {
+	b = make([]float64, n*nrhs)
+	x = make([]float64, n*nrhs)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	for j := 0; j < nrhs; j++ {
+		a.MatVec(b[j*n:(j+1)*n], x[j*n:(j+1)*n])
+	}
+	return b, x
+}
+
+func TestBlockCG(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		p      *problem.Problem
+		jacobi bool
+	}{
+		{p: problem.RandomSPD(10, rnd)},
+		{p: problem.RandomSPD(50, rnd)},
+		{p: problem.RandomSPD(200, rnd)},
+		{p: problem.Poisson2D(10, 10)},
+		{p: problem.Poisson2D(20, 15)},
+		{p: market("nos4", 1e-8)},
+		{p: market("nos4", 1e-8), jacobi: true},
+		{p: market("bcsstm22", 1e-8), jacobi: true},
+	} {
+		p := test.p
+		n := p.Dim
+		settings := iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: p.MaxIterations,
+		}
+		if test.jacobi {
+			csr := marketCSR(p.Name)
+			settings.PSolve = iterative.DiagonalInverse(sparse.Diagonal(csr)).Apply
+		}
+		for _, nrhs := range []int{1, 3, 8} {
+			name := fmt.Sprintf("%s,nrhs=%d,jacobi=%t", p.Name, nrhs, test.jacobi)
+			b, want := blockRHS(p.A, n, nrhs, rnd)
+			res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockCG{}, settings)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+				continue
+			}
+			if len(res.X) != n*nrhs {
+				t.Errorf("%s: unexpected length of X %d, want %d", name, len(res.X), n*nrhs)
+				continue
+			}
+			if len(res.Stats.ResidualNorms) != nrhs {
+				t.Errorf("%s: unexpected number of residual norms %d, want %d", name, len(res.Stats.ResidualNorms), nrhs)
+				continue
+			}
+
+			maxIter := 0
+			var maxNorm float64
+			for j := 0; j < nrhs; j++ {
+				bj := b[j*n : (j+1)*n]
+				xj := res.X[j*n : (j+1)*n]
+				wantj := want[j*n : (j+1)*n]
+				cg, err := iterative.LinearSolve(p.A, bj, &iterative.CG{}, settings)
+				if err != nil {
+					t.Errorf("%s: unexpected error from CG for column %d: %v", name, j, err)
+					continue
+				}
+				maxIter = max(maxIter, cg.Stats.Iterations)
+
+				scale := floats.Norm(wantj, math.Inf(1))
+				if d := floats.Distance(xj, wantj, math.Inf(1)); d > p.Tolerance*scale {
+					t.Errorf("%s: error of column %d is %v, want at most %v", name, j, d, p.Tolerance*scale)
+				}
+				if d := floats.Distance(xj, cg.X, math.Inf(1)); d > p.Tolerance*scale {
+					t.Errorf("%s: column %d differs from the solution of CG by %v", name, j, d)
+				}
+				rnorm := res.Stats.ResidualNorms[j]
+				if bnorm := floats.Norm(bj, 2); rnorm >= settings.Tolerance*bnorm {
+					t.Errorf("%s: residual norm of column %d is %v, want below %v", name, j, rnorm, settings.Tolerance*bnorm)
+				}
+				maxNorm = math.Max(maxNorm, rnorm)
+			}
+			if res.Stats.ResidualNorm != maxNorm {
+				t.Errorf("%s: ResidualNorm %v is not the largest column norm %v", name, res.Stats.ResidualNorm, maxNorm)
+			}
+			// The search space of a column includes its
+			// own Krylov subspace.
+			if res.Stats.Iterations > maxIter {
+				t.Errorf("%s: BlockCG needs %d iterations, CG at most %d", name, res.Stats.Iterations, maxIter)
+			}
+		}
+	}
+}
+
+func TestBlockCGFewerIterations(t *testing.T) {
+	// The block iterations search in the sum of
+	// the Krylov subspaces of all columns, so
+	// they need substantially fewer iterations
+	// than CG on each column.
+	rnd := rand.New(rand.NewSource(1))
+	p := problem.Poisson2D(30, 30)
+	const nrhs = 8
+	settings := iterative.Settings{Tolerance: 1e-10}
+	b, _ := blockRHS(p.A, p.Dim, nrhs, rnd)
+	res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockCG{}, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	minIter := math.MaxInt
+	for j := 0; j < nrhs; j++ {
+		cg, err := iterative.LinearSolve(p.A, b[j*p.Dim:(j+1)*p.Dim], &iterative.CG{}, settings)
+		if err != nil {
+			t.Fatalf("unexpected error from CG: %v", err)
+		}
+		minIter = min(minIter, cg.Stats.Iterations)
+	}
+	if 4*res.Stats.Iterations > 3*minIter {
+		t.Errorf("BlockCG needs %d iterations, CG at least %d", res.Stats.Iterations, minIter)
+	}
+}
+
+func TestBlockCGDeflation(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	p := problem.Poisson2D(10, 10)
+	n := p.Dim
+	const nrhs = 6
+	b, _ := blockRHS(p.A, n, nrhs, rnd)
+	col := func(j int) []float64 { return b[j*n : (j+1)*n] }
+	// Make the initial block rank deficient by a
+	// duplicate column, a linear combination of
+	// other columns and a zero column.
+	copy(col(1), col(0))
+	floats.AddScaledTo(col(3), col(0), 2, col(2))
+	for i := range col(4) {
+		col(4)[i] = 0
+	}
+
+	settings := iterative.Settings{Tolerance: 1e-12}
+	res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockCG{}, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := make([]float64, n)
+	for j := 0; j < nrhs; j++ {
+		xj := res.X[j*n : (j+1)*n]
+		p.A.MatVec(r, xj)
+		floats.Sub(r, col(j))
+		if rnorm, bnorm := floats.Norm(r, 2), floats.Norm(col(j), 2); rnorm > 10*settings.Tolerance*bnorm {
+			t.Errorf("column %d: residual norm %v, want at most %v", j, rnorm, 10*settings.Tolerance*bnorm)
+		}
+	}
+	if d := floats.Distance(res.X[:n], res.X[n:2*n], math.Inf(1)); d > 1e-10 {
+		t.Errorf("solutions of duplicate columns differ by %v", d)
+	}
+	if floats.Norm(res.X[4*n:5*n], math.Inf(1)) != 0 {
+		t.Errorf("nonzero solution for zero right-hand side")
+	}
+}
+
+func TestBlockCGMatMatVec(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	p := problem.Poisson2D(10, 10)
+	n := p.Dim
+	const nrhs = 4
+	var blocks, cols int
+	ops := iterative.MatrixOps{
+		MatVec: func(dst, x []float64) {
+			panic("unexpected MatVec")
+		},
+		MatMatVec: func(dst, x []float64, k int) {
+			blocks++
+			cols += k
+			for j := 0; j < k; j++ {
+				p.A.MatVec(dst[j*n:(j+1)*n], x[j*n:(j+1)*n])
+			}
+		},
+	}
+	b, _ := blockRHS(p.A, n, nrhs, rnd)
+	res, err := iterative.LinearSolveBlock(ops, b, nrhs, &iterative.BlockCG{}, iterative.Settings{Tolerance: 1e-10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if blocks != res.Stats.Iterations {
+		t.Errorf("unexpected number of block products %d, want %d", blocks, res.Stats.Iterations)
+	}
+	if cols != res.Stats.MatVec {
+		t.Errorf("unexpected Stats.MatVec %d, want %d", res.Stats.MatVec, cols)
+	}
+	if cols > nrhs*res.Stats.Iterations {
+		t.Errorf("%d products with A in %d iterations with %d right-hand sides", cols, res.Stats.Iterations, nrhs)
+	}
+}
+
+func TestBlockCGNotPositiveDefinite(t *testing.T) {
+	const (
+		n    = 10
+		nrhs = 2
+	)
+	a := tridiagonalCSR(n, 1, -2, 1)
+	b := make([]float64, n*nrhs)
+	for i := range b {
+		b[i] = float64(i%n + 1)
+	}
+	b[n] = -1
+	ops := iterative.MatrixOps{MatVec: a.MulVec}
+	_, err := iterative.LinearSolveBlock(ops, b, nrhs, &iterative.BlockCG{}, iterative.Settings{})
+	var npd *iterative.NotPositiveDefiniteError
+	if !errors.As(err, &npd) || npd.Method != "BlockCG" {
+		t.Errorf("unexpected error %v, want BlockCG: matrix not positive definite", err)
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// BlockGMRES implements the block Generalized Minimum Residual method with
+// preconditioning for solving the system of linear equations with multiple
+// right-hand sides
+//  AX = B,
+// where A is a non-symmetric matrix. BlockGMRES is a BlockMethod, it is
+// used with LinearSolveBlock. It uses restarts to control storage
+// requirements.
+//
+// BlockGMRES minimizes the residual norm of each column of X over the sum
+// of the Krylov subspaces of all columns of the residual at the restart,
+// which is built by the block Arnoldi process. In each iteration the basis
+// is extended by as many vectors as there are columns in the block, their
+// products with A are done as one block product, and each new vector is
+// orthogonalized against the basis by the classical Gram-Schmidt process
+// with reorthogonalization as in GMRES with ClassicalGramSchmidt2. The
+// block upper Hessenberg matrix of the least-squares problems is reduced to
+// upper triangular form by Householder reflections, which give the
+// residual norm estimates of all columns in each iteration.
+//
+// The columns that satisfy the stopping criterion are deflated at the next
+// restart, the block of the new cycle is built only from the residuals of
+// the other columns. The residuals are orthogonalized in the same way as
+// the basis, and those that are linearly dependent on the others do not
+// contribute a column to the block. A new basis vector that is linearly
+// dependent on the basis is dropped and the block becomes smaller by one
+// column as in the band Arnoldi process with deflation. If all columns of
+// the block are dropped, the Krylov subspace is invariant and BlockGMRES
+// restarts early.
+//
+// BlockGMRES is preconditioned from the left as GMRES, so the residual
+// norms estimated within a cycle are the norms of M^{-1} R.
+//
+// References:
+//  - Vital, B.: Etude de quelques méthodes de résolution de problèmes
+//    linéaires de grande taille sur multiprocesseur. PhD thesis, Université
+//    de Rennes I (1990)
+//  - Saad, Y.: Iterative Methods for Sparse Linear Systems, 2nd edn.
+//    SIAM (2003), Section 6.12
+//  - Aliaga, J.I., Boley, D.L., Freund, R.W., Hernández, V.: A Lanczos-type
+//    method for multiple starting vectors. Math. Comp. 69(232), 1577-1601
+//    (2000)
+//
+// BlockGMRES needs MatVec and PSolve matrix operations.
+type BlockGMRES struct {
+	// Restart is the restart parameter. The
+	// basis has at most (Restart+1)*nrhs
+	// vectors, so BlockGMRES restarts after
+	// Restart iterations with the block of
+	// all nrhs columns, and after more
+	// iterations if the block is smaller. If
+	// it is 0, dim/nrhs rounded up is used,
+	// so that a cycle can build a basis of
+	// the whole space. It must not be
+	// negative.
+	Restart int
+	// MaxRestarts is the maximum number of
+	// restarts as in GMRES. If the solve has
+	// not converged by the end of the last
+	// cycle, IterateBlock returns
+	// ErrRestartLimit. If MaxRestarts is 0,
+	// the number of restarts is limited only
+	// by Settings.MaxIterations. It must not
+	// be negative.
+	MaxRestarts int
+
+	resume int
+	cycles int // Number of completed restart cycles.
+
+	n, nrhs int
+	maxk    int   // Maximum number of columns of H.
+	p       int   // Number of columns of the block at the restart.
+	active  []int // Columns that are not converged.
+
+	k  int // Number of columns of H reduced so far.
+	nv int // Number of basis vectors.
+	pc int // Current size of the block, nv-k.
+
+	v  []float64 // dim×(m+1)p basis V.
+	av []float64 // dim×nrhs products with A.
+	z  []float64 // dim×nrhs M^{-1} R, and V y at the end of a cycle.
+
+	h   []float64 // Block upper Hessenberg matrix H, column-major.
+	ldh int
+	g   []float64 // Right-hand sides of the least-squares problems, column-major.
+	w   []float64 // Householder vectors, one per column of H.
+	ldw int
+	wn  []int // Lengths of the Householder vectors.
+	y   []float64
+}
+
+// InitBlock implements the BlockMethod interface.
+func (g *BlockGMRES) InitBlock(dim, nrhs int) {
+	if dim <= 0 {
+		panic("BlockGMRES: dimension not positive")
+	}
+	if nrhs <= 0 {
+		panic("BlockGMRES: number of right-hand sides not positive")
+	}
+	if g.Restart < 0 {
+		panic("BlockGMRES: negative Restart")
+	}
+	if g.MaxRestarts < 0 {
+		panic("BlockGMRES: negative MaxRestarts")
+	}
+
+	m := g.Restart
+	if m == 0 {
+		m = (dim + nrhs - 1) / nrhs
+	}
+	g.n = dim
+	g.nrhs = nrhs
+	k := m * nrhs
+	g.maxk = k
+
+	g.v = reuse(g.v, dim*(k+nrhs))
+	g.av = reuse(g.av, dim*nrhs)
+	g.z = reuse(g.z, dim*nrhs)
+	g.ldh = k + nrhs
+	g.h = reuse(g.h, g.ldh*k)
+	g.g = reuse(g.g, g.ldh*nrhs)
+	g.ldw = nrhs + 1
+	g.w = reuse(g.w, g.ldw*k)
+	if cap(g.wn) < k {
+		g.wn = make([]int, k)
+	}
+	g.wn = g.wn[:k]
+	g.y = reuse(g.y, g.ldh)
+	if cap(g.active) < nrhs {
+		g.active = make([]int, 0, nrhs)
+	}
+
+	g.cycles = 0
+	g.resume = 1
+}
+
+// IterateBlock implements the BlockMethod interface.
+func (g *BlockGMRES) IterateBlock(ctx *BlockContext) (Operation, error) {
+	n := g.n
+	switch g.resume {
+	case 1:
+		if g.cycles > 0 {
+			ctx.Restarts++
+		}
+		// Deflate the converged columns.
+		g.active = g.active[:0]
+		for j, c := range ctx.Converged {
+			if !c {
+				g.active = append(g.active, j)
+			}
+		}
+		s := len(g.active)
+		for jj, j := range g.active {
+			ctx.vec.copy(g.av[jj*n:(jj+1)*n], ctx.Residual[j*n:(j+1)*n])
+		}
+		ctx.K = s
+		ctx.Src = g.av[:n*s]
+		ctx.Dst = g.z[:n*s]
+		g.resume = 2
+		return PSolve, nil
+		// Solve M Z = R.
+	case 2:
+		// Orthonormalize Z into the first block of V
+		// and drop the linearly dependent columns.
+		s := len(g.active)
+		g.p = 0
+		for jj := 0; jj < s; jj++ {
+			vp := g.v[g.p*n : (g.p+1)*n]
+			ctx.vec.copy(vp, g.z[jj*n:(jj+1)*n])
+			norm, vnorm := g.orthogonalize(ctx.vec, g.h[:g.p], vp)
+			if vnorm <= rankTol*norm || vnorm == 0 {
+				continue
+			}
+			ctx.vec.scale(1/vnorm, vp)
+			g.p++
+		}
+		if g.p == 0 {
+			g.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+			return NoOperation, &BreakdownError{"BlockGMRES", "V"}
+		}
+		// G = [V_0^T Z; 0].
+		for jj := 0; jj < s; jj++ {
+			gj := g.g[jj*g.ldh : (jj+1)*g.ldh]
+			for i := range gj {
+				gj[i] = 0
+			}
+			for i := 0; i < g.p; i++ {
+				gj[i] = ctx.vec.dot(g.v[i*n:(i+1)*n], g.z[jj*n:(jj+1)*n])
+			}
+		}
+
+		// for k := 0; k+pc <= maxk && pc > 0; k += pc {
+		g.k = 0
+		g.nv = g.p
+		g.pc = g.p
+		fallthrough
+	case 3:
+		// The columns k, ..., k+pc-1 of V are the basis
+		// vectors whose products with A have not been
+		// computed yet.
+		pc := g.pc
+		ctx.K = pc
+		ctx.Src = g.v[g.k*n : (g.k+pc)*n]
+		ctx.Dst = g.av[:pc*n]
+		g.resume = 4
+		return MatVec, nil
+		// Compute A V[:,k:k+pc].
+	case 4:
+		pc := g.pc
+		ctx.Src = g.av[:pc*n]
+		ctx.Dst = g.v[g.nv*n : (g.nv+pc)*n]
+		g.resume = 5
+		return PSolve, nil
+		// Solve M W = A V[:,k:k+pc].
+	case 5:
+		first := g.nv
+		for i, pc := 0, g.pc; i < pc; i++ {
+			// Orthogonalize the i-th column of W against
+			// the basis, which gives the k-th column of H,
+			// and append it to the basis unless it is
+			// linearly dependent on it.
+			k, nv := g.k, g.nv
+			w := g.v[nv*n : (nv+1)*n]
+			if first+i != nv {
+				ctx.vec.copy(w, g.v[(first+i)*n:(first+i+1)*n])
+			}
+			hk := g.h[k*g.ldh : k*g.ldh+nv+1]
+			norm, wnorm := g.orthogonalize(ctx.vec, hk[:nv], w)
+			if wnorm <= rankTol*norm || wnorm == 0 {
+				// Drop w, the block is smaller by one.
+				hk[nv] = 0
+				g.pc--
+				g.reduce(k, nv-k)
+			} else {
+				hk[nv] = wnorm
+				ctx.vec.scale(1/wnorm, w)
+				g.nv++
+				g.reduce(k, nv-k+1)
+			}
+			g.k++
+		}
+		// Estimate the residual norms of the active
+		// columns and check for convergence.
+		for jj, j := range g.active {
+			gj := g.g[jj*g.ldh : (jj+1)*g.ldh]
+			ctx.ResidualNorms[j] = floats.Norm(gj[g.k:g.nv], 2)
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		g.resume = 6
+		return CheckResidualNorm, nil
+	case 6:
+		if g.allConverged(ctx) {
+			// Compute final approximate solution X and finish.
+			for jj, j := range g.active {
+				g.update(ctx.vec, ctx.X[j*n:(j+1)*n], jj)
+			}
+			g.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+			return EndIteration, nil
+		}
+		if g.pc > 0 && g.k+g.pc <= g.maxk {
+			// Continue the inner for loop.
+			g.resume = 3
+			return EndIteration, nil
+		}
+		// End the inner for loop.
+		fallthrough
+	case 7:
+		// Update X with D = V Y, and compute A D for
+		// the update of the residual.
+		s := len(g.active)
+		for jj, j := range g.active {
+			dj := g.z[jj*n : (jj+1)*n]
+			for i := range dj {
+				dj[i] = 0
+			}
+			g.update(ctx.vec, dj, jj)
+			ctx.vec.add(ctx.X[j*n:(j+1)*n], dj)
+		}
+		ctx.K = s
+		ctx.Src = g.z[:n*s]
+		ctx.Dst = g.av[:n*s]
+		g.resume = 8
+		return MatVec, nil
+		// Compute A D.
+	case 8:
+		for jj, j := range g.active {
+			rj := ctx.Residual[j*n : (j+1)*n]
+			ctx.vec.addScaled(rj, -1, g.av[jj*n:(jj+1)*n]) // R -= A D
+			ctx.ResidualNorms[j] = ctx.vec.norm(rj)
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		g.resume = 9
+		return CheckResidualNorm, nil
+	case 9:
+		g.cycles++
+		switch {
+		case g.allConverged(ctx):
+			g.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+		case g.MaxRestarts > 0 && g.cycles > g.MaxRestarts:
+			g.resume = 10
+		default:
+			g.resume = 1 // Restart (continue the outer for loop).
+		}
+		return EndIteration, nil
+	case 10:
+		g.resume = 0 // Calling IterateBlock again without InitBlock will panic.
+		return NoOperation, ErrRestartLimit
+
+	default:
+		panic("BlockGMRES: InitBlock not called")
+	}
+}
+
+// orthogonalize orthogonalizes v against the first len(h) columns of V by
+// the classical Gram-Schmidt process with reorthogonalization, stores the
+// coefficients in h and returns the norms of v before and after.
+func (g *BlockGMRES) orthogonalize(vec *vecOps, h, v []float64) (norm, vnorm float64) {
+	norm = vec.norm(v)
+	if len(h) == 0 {
+		return norm, norm
+	}
+	vnorm = vec.cgs(h, g.v, g.n, v)
+	if vnorm < reorthTol*norm {
+		// Twice is enough.
+		hc := g.y[:len(h)]
+		vnorm = vec.cgs(hc, g.v, g.n, v)
+		floats.Add(h, hc)
+	}
+	return norm, vnorm
+}
+
+// reduce applies the previous Householder reflections to the k-th column
+// of H, computes the reflection that zeroes its l-1 elements below the
+// diagonal and applies it to the column and to the right-hand sides in G.
+func (g *BlockGMRES) reduce(k, l int) {
+	hk := g.h[k*g.ldh : k*g.ldh+k+l]
+	for i := 0; i < k; i++ {
+		li := g.wn[i]
+		applyReflection(g.w[i*g.ldw:i*g.ldw+li], hk[i:i+li])
+	}
+
+	g.wn[k] = l
+	w := g.w[k*g.ldw : k*g.ldw+l]
+	x := hk[k:]
+	norm := floats.Norm(x, 2)
+	if norm == 0 {
+		// The reflection is the identity.
+		for i := range w {
+			w[i] = 0
+		}
+		return
+	}
+	alpha := -math.Copysign(norm, x[0])
+	copy(w, x)
+	w[0] -= alpha
+	floats.Scale(1/floats.Norm(w, 2), w)
+	x[0] = alpha
+	for i := range x[1:] {
+		x[1+i] = 0
+	}
+	for jj := range g.active {
+		applyReflection(w, g.g[jj*g.ldh+k:jj*g.ldh+k+l])
+	}
+}
+
+// applyReflection applies the Householder reflection I - 2 w w^T to x.
+func applyReflection(w, x []float64) {
+	floats.AddScaled(x, -2*floats.Dot(w, x), w)
+}
+
+// update adds V y to x, where y solves the least-squares problem of the
+// jj-th active column over the first k columns of H.
+func (g *BlockGMRES) update(vec *vecOps, x []float64, jj int) {
+	y := g.y[:g.k]
+	hessenbergSolve(y, g.h, g.ldh, g.g[jj*g.ldh:(jj+1)*g.ldh])
+	vec.addMul(x, g.v, g.n, y)
+}
+
+// allConverged returns whether all active columns are converged.
+func (g *BlockGMRES) allConverged(ctx *BlockContext) bool {
+	for _, j := range g.active {
+		if !ctx.Converged[j] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+// checkBlockSolution reports the columns of the n×nrhs solution x whose
+// error with respect to want exceeds tol relative to the maximum norm of
+// the column of want, or whose residual norm exceeds rtol relative to the
+// norm of the column of b.
+func checkBlockSolution(t *testing.T, name string, p *problem.Problem, b, x, want []float64, nrhs int, tol, rtol float64) {
+	t.Helper()
+	n := p.Dim
+	r := make([]float64, n)
+	for j := 0; j < nrhs; j++ {
+		bj := b[j*n : (j+1)*n]
+		xj := x[j*n : (j+1)*n]
+		wantj := want[j*n : (j+1)*n]
+		scale := floats.Norm(wantj, math.Inf(1))
+		if d := floats.Distance(xj, wantj, math.Inf(1)); d > tol*scale {
+			t.Errorf("%s: error of column %d is %v, want at most %v", name, j, d, tol*scale)
+		}
+		if rtol == 0 {
+			continue
+		}
+		p.A.MatVec(r, xj)
+		floats.Sub(r, bj)
+		if rnorm, bnorm := floats.Norm(r, 2), floats.Norm(bj, 2); rnorm > rtol*bnorm {
+			t.Errorf("%s: residual norm of column %d is %v, want at most %v", name, j, rnorm, rtol*bnorm)
+		}
+	}
+}
+
+func TestBlockGMRES(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		p      *problem.Problem
+		jacobi bool
+	}{
+		{p: market("e05r0000", 1e-6)},
+		{p: market("e05r0100", 1e-6)},
+		{p: market("e05r0200", 1e-6)},
+		{p: market("e05r0300", 1e-5)},
+		{p: market("e05r0400", 1e-5)},
+		{p: market("e05r0500", 1e-5)},
+		{p: market("e05r0000", 1e-6), jacobi: true},
+		{p: market("e05r0500", 1e-5), jacobi: true},
+	} {
+		p := test.p
+		n := p.Dim
+		settings := iterative.Settings{
+			Tolerance:     1e-10,
+			MaxIterations: p.MaxIterations,
+		}
+		if test.jacobi {
+			// The zero diagonal elements of the e05r
+			// matrices are replaced by ones.
+			settings.PSolve = iterative.DiagonalInverse(diagonal(p.A, n)).Apply
+		}
+		for _, nrhs := range []int{1, 3, 8} {
+			name := fmt.Sprintf("%s,nrhs=%d,jacobi=%t", p.Name, nrhs, test.jacobi)
+			b, want := blockRHS(p.A, n, nrhs, rnd)
+			res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockGMRES{}, settings)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+				continue
+			}
+			if len(res.Stats.ResidualNorms) != nrhs {
+				t.Errorf("%s: unexpected number of residual norms %d, want %d", name, len(res.Stats.ResidualNorms), nrhs)
+				continue
+			}
+			// With the left preconditioner, the stopping
+			// criterion applies to M^{-1} r.
+			var rtol float64
+			if !test.jacobi {
+				rtol = 10 * settings.Tolerance
+			}
+			checkBlockSolution(t, name, p, b, res.X, want, nrhs, p.Tolerance, rtol)
+
+			maxIter := 0
+			for j := 0; j < nrhs; j++ {
+				gmres, err := iterative.LinearSolve(p.A, b[j*n:(j+1)*n], &iterative.GMRES{}, settings)
+				if err != nil {
+					t.Errorf("%s: unexpected error from GMRES for column %d: %v", name, j, err)
+					continue
+				}
+				maxIter = max(maxIter, gmres.Stats.Iterations)
+			}
+			switch {
+			case nrhs == 1 && res.Stats.Iterations != maxIter:
+				t.Errorf("%s: BlockGMRES needs %d iterations, GMRES %d", name, res.Stats.Iterations, maxIter)
+			case nrhs > 1 && res.Stats.Iterations >= maxIter:
+				t.Errorf("%s: BlockGMRES needs %d iterations, GMRES at most %d", name, res.Stats.Iterations, maxIter)
+			}
+		}
+	}
+}
+
+func TestBlockGMRESRestart(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	p := problem.ConvectionDiffusion2D(20, 20, 10, 5)
+	n := p.Dim
+	const nrhs = 3
+	settings := iterative.Settings{Tolerance: 1e-10}
+	for _, restart := range []int{5, 10, 20} {
+		name := fmt.Sprintf("Restart=%d", restart)
+		b, want := blockRHS(p.A, n, nrhs, rnd)
+		res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockGMRES{Restart: restart}, settings)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if res.Stats.Restarts == 0 {
+			t.Errorf("%s: no restarts", name)
+		}
+		if got, want := res.Stats.Iterations, (res.Stats.Restarts+1)*restart; got > want {
+			t.Errorf("%s: %d iterations with %d restarts", name, got, res.Stats.Restarts)
+		}
+		checkBlockSolution(t, name, p, b, res.X, want, nrhs, 1e-8, 10*settings.Tolerance)
+	}
+
+	// The restart limit.
+	p = market("e05r0000", 0)
+	b, _ := blockRHS(p.A, p.Dim, nrhs, rnd)
+	const maxRestarts = 2
+	res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockGMRES{Restart: 5, MaxRestarts: maxRestarts}, settings)
+	if err != iterative.ErrRestartLimit {
+		t.Errorf("unexpected error %v, want %v", err, iterative.ErrRestartLimit)
+	}
+	if res.Stats.Restarts != maxRestarts {
+		t.Errorf("unexpected number of restarts %d, want %d", res.Stats.Restarts, maxRestarts)
+	}
+}
+
+func TestBlockGMRESDeflation(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	p := market("e05r0000", 1e-5)
+	n := p.Dim
+	const nrhs = 6
+	b, want := blockRHS(p.A, n, nrhs, rnd)
+	col := func(v []float64, j int) []float64 { return v[j*n : (j+1)*n] }
+	// Make the initial block rank deficient by a
+	// duplicate column, a linear combination of
+	// other columns and a zero column.
+	copy(col(b, 1), col(b, 0))
+	copy(col(want, 1), col(want, 0))
+	floats.AddScaledTo(col(b, 3), col(b, 0), 2, col(b, 2))
+	floats.AddScaledTo(col(want, 3), col(want, 0), 2, col(want, 2))
+	for i := range col(b, 4) {
+		col(b, 4)[i] = 0
+		col(want, 4)[i] = 0
+	}
+
+	settings := iterative.Settings{Tolerance: 1e-10}
+	res, err := iterative.LinearSolveBlock(p.A, b, nrhs, &iterative.BlockGMRES{}, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkBlockSolution(t, "deflation", p, b, res.X, want, nrhs, p.Tolerance, 10*settings.Tolerance)
+	if floats.Norm(col(res.X, 4), math.Inf(1)) != 0 {
+		t.Errorf("nonzero solution for zero right-hand side")
+	}
+	// The block has rank three, so it needs about
+	// as many iterations as three independent
+	// columns.
+	b3, _ := blockRHS(p.A, n, 3, rnd)
+	res3, err := iterative.LinearSolveBlock(p.A, b3, 3, &iterative.BlockGMRES{}, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Stats.Iterations > res3.Stats.Iterations+1 {
+		t.Errorf("rank deficient block needs %d iterations, block of its rank %d", res.Stats.Iterations, res3.Stats.Iterations)
+	}
+}
+
+func TestBlockGMRESMatMatVec(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	p := market("e05r0000", 0)
+	n := p.Dim
+	const nrhs = 3
+	var blocks, cols int
+	ops := iterative.MatrixOps{
+		MatVec: func(dst, x []float64) {
+			panic("unexpected MatVec")
+		},
+		MatMatVec: func(dst, x []float64, k int) {
+			blocks++
+			cols += k
+			for j := 0; j < k; j++ {
+				p.A.MatVec(dst[j*n:(j+1)*n], x[j*n:(j+1)*n])
+			}
+		},
+	}
+	b, _ := blockRHS(p.A, n, nrhs, rnd)
+	res, err := iterative.LinearSolveBlock(ops, b, nrhs, &iterative.BlockGMRES{}, iterative.Settings{Tolerance: 1e-10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Stats.Restarts != 0 {
+		t.Fatalf("unexpected restarts")
+	}
+	if blocks != res.Stats.Iterations {
+		t.Errorf("unexpected number of block products %d, want %d", blocks, res.Stats.Iterations)
+	}
+	if cols != res.Stats.MatVec {
+		t.Errorf("unexpected Stats.MatVec %d, want %d", res.Stats.MatVec, cols)
+	}
+}
@@ -4,12 +4,12 @@
 
 package iterative
 
-import "github.com/gonum/floats"
-
 // CG implements the Conjugate Gradient iterative method with preconditioning
 // for solving the system of linear equations
 //  Ax = b,
-// where A is a symmetric positive definite matrix.
+// where A is a symmetric positive definite matrix. If CG encounters a search
+// direction p with p·Ap <= 0, the matrix is not positive definite and
+// Iterate returns a *NotPositiveDefiniteError.
 //
 // CG needs MatVec and PSolve matrix operations.
 type CG struct {
@@ -46,12 +46,14 @@ func (cg *CG) Iterate(ctx *Context) (Operation, error) {
 		return PSolve, nil
 		// Solve M z = r_{i-1}
 	case 2:
-		cg.rho = floats.Dot(ctx.Residual, cg.z) // ρ_i = r_{i-1} · z
+		cg.rho = ctx.vec.dot(ctx.Residual, cg.z) // ρ_i = r_{i-1} · z
 		if !cg.first {
-			beta := cg.rho / cg.rhoPrev        // β = ρ_i / ρ_{i-1}
-			floats.AddScaled(cg.z, beta, cg.p) // z = z + β p_{i-1}
+			beta := cg.rho / cg.rhoPrev         // β = ρ_i / ρ_{i-1}
+			ctx.vec.addScaled(cg.z, beta, cg.p) // z = z + β p_{i-1}
 		}
-		copy(cg.p, cg.z) // p_i = z
+		// p_i = z. The vectors are swapped instead
+		// of copied, the next PSolve overwrites z.
+		cg.p, cg.z = cg.z, cg.p
 
 		ctx.Src = cg.p
 		ctx.Dst = cg.ap
@@ -59,24 +61,31 @@ func (cg *CG) Iterate(ctx *Context) (Operation, error) {
 		return MatVec, nil
 		// Compute Ap_i
 	case 3:
-		alpha := cg.rho / floats.Dot(cg.p, cg.ap)     // α = ρ_i / (p_i · Ap_i)
-		floats.AddScaled(ctx.Residual, -alpha, cg.ap) // r_i = r_{i-1} - α Ap_i
-		floats.AddScaled(ctx.X, alpha, cg.p)          // x_i = x_{i-1} + α p_i
+		pap := ctx.vec.dot(cg.p, cg.ap)
+		if pap <= 0 {
+			cg.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "CG"}
+		}
+		alpha := cg.rho / pap // α = ρ_i / (p_i · Ap_i)
+		// r_i = r_{i-1} - α Ap_i
+		// x_i = x_{i-1} + α p_i
+		ctx.ResidualNorm = ctx.vec.cgUpdate(alpha, ctx.X, cg.p, ctx.Residual, cg.ap)
 
 		ctx.Src = nil
 		ctx.Dst = nil
-		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
 		ctx.Converged = false
 		cg.resume = 4
 		return CheckResidualNorm, nil
 	case 4:
 		if ctx.Converged {
 			cg.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
 			return EndIteration, nil
 		}
 		cg.rhoPrev = cg.rho
 		cg.first = false
 		cg.resume = 1
+		ctx.ResidualCurrent = true
 		return EndIteration, nil
 
 	default:
@@ -0,0 +1,126 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// CGNE implements Craig's method, the Conjugate Gradient method applied to
+// the system
+//  A A^T y = b, x = A^T y,
+// for solving the system of linear equations
+//  Ax = b,
+// where A is a general, possibly nonsymmetric or rectangular matrix and
+// the system is consistent. CGNE does not form A A^T, each iteration needs
+// one product with A and one with A^T.
+//
+// The iterates minimize the error |x - x_i| over a Krylov subspace. If the
+// initial guess is zero or, more generally, in the range of A^T, CGNE
+// converges to the solution of the minimum norm, which makes it suitable
+// for underdetermined systems. The condition number of A A^T is the square
+// of that of A, so CGNE converges slowly on ill-conditioned systems. If the
+// system is inconsistent, CGNE does not converge, use CGNR or LSMR for
+// least-squares problems.
+//
+// CGNE is a RectMethod. It also reports the norm of A^T r in
+// Context.NormalResidualNorm, which it computes anyway.
+//
+// References:
+//  - Craig, E. J.: The N-step iteration procedures. J. Math. Phys. 34,
+//    64-73 (1955)
+//  - Saad, Y.: Iterative Methods for Sparse Linear Systems. 2nd ed.
+//    SIAM (2003)
+//
+// CGNE needs MatVec and MatTransVec matrix operations, it does not use a
+// preconditioner.
+type CGNE struct {
+	resume int
+
+	// gamma is |r|^2 of the previous iterate,
+	// gammaNew that of the current one.
+	gamma, gammaNew float64
+	alpha           float64
+
+	// s and p have the length of x, q the
+	// length of b.
+	s []float64
+	p []float64
+	q []float64
+}
+
+// Init implements the Method interface.
+func (c *CGNE) Init(dim int) {
+	c.InitRect(dim, dim)
+}
+
+// InitRect implements the RectMethod interface.
+func (c *CGNE) InitRect(m, n int) {
+	if m <= 0 || n <= 0 {
+		panic("CGNE: dimension not positive")
+	}
+
+	c.s = reuse(c.s, n)
+	c.p = reuse(c.p, n)
+	c.q = reuse(c.q, m)
+	c.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (c *CGNE) Iterate(ctx *Context) (Operation, error) {
+	switch c.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = c.p
+		c.resume = 2
+		return MatTransVec, nil
+		// Compute p_1 = A^T r_0
+	case 2:
+		c.gamma = ctx.vec.dot(ctx.Residual, ctx.Residual)
+		fallthrough
+	case 3:
+		pp := ctx.vec.dot(c.p, c.p)
+		if pp == 0 {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"CGNE", "p"}
+		}
+		c.alpha = c.gamma / pp                 // α = |r_{i-1}|^2 / |p_i|^2
+		ctx.vec.addScaled(ctx.X, c.alpha, c.p) // x_i = x_{i-1} + α p_i
+		ctx.Src = c.p
+		ctx.Dst = c.q
+		c.resume = 4
+		return MatVec, nil
+		// Compute q_i = A p_i
+	case 4:
+		// r_i = r_{i-1} - α q_i
+		c.gammaNew = ctx.vec.axpyDot(-c.alpha, c.q, ctx.Residual)
+		ctx.Src = ctx.Residual
+		ctx.Dst = c.s
+		c.resume = 5
+		return MatTransVec, nil
+		// Compute s_i = A^T r_i
+	case 5:
+		ctx.ResidualNorm = math.Sqrt(c.gammaNew)
+		ctx.NormalResidualNorm = ctx.vec.norm(c.s)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		c.resume = 6
+		return CheckResidualNorm, nil
+	case 6:
+		if ctx.Converged {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
+			return EndIteration, nil
+		}
+		beta := c.gammaNew / c.gamma             // β = |r_i|^2 / |r_{i-1}|^2
+		ctx.vec.addScaledTo(c.p, c.s, beta, c.p) // p_{i+1} = s_i + β p_i
+		c.gamma = c.gammaNew
+		c.resume = 3
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+
+	default:
+		panic("CGNE: Init not called")
+	}
+}
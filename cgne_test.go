@@ -0,0 +1,80 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/vladimir-ch/iterative"
+)
+
+func TestCGNE(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		m, n int
+		// rank is the rank of A.
+		rank int
+	}{
+		{1, 1, 1},
+		{1, 5, 1},
+		{10, 10, 10},
+		{10, 40, 10},
+		{30, 100, 30},
+		{40, 10, 10},
+		{10, 40, 6},
+		{30, 30, 20},
+	} {
+		m, n := test.m, test.n
+		s := make([]float64, test.rank)
+		for i := range s {
+			s[i] = 1 + 9*float64(i)/float64(test.rank)
+		}
+		d := withSingularValues(m, n, s, rnd)
+		ops := rectOps(d)
+		ops.Rows, ops.Cols = m, n
+
+		// The system is consistent, b is in the
+		// range of A.
+		z := make([]float64, n)
+		for i := range z {
+			z[i] = rnd.NormFloat64()
+		}
+		b := make([]float64, m)
+		ops.MatVec(b, z)
+
+		// The minimum-norm solution computed
+		// with the pseudo-inverse of A.
+		var svd mat.SVD
+		if !svd.Factorize(d, mat.SVDThin) {
+			t.Fatal("SVD failed")
+		}
+		var want mat.VecDense
+		svd.SolveVecTo(&want, mat.NewVecDense(m, b), test.rank)
+
+		res, err := iterative.LinearSolve(ops, b, &iterative.CGNE{}, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 1000,
+		})
+		if err != nil {
+			t.Errorf("m=%d,n=%d,rank=%d: unexpected error: %v", m, n, test.rank, err)
+			continue
+		}
+		if dist := floats.Distance(res.X, want.RawVector().Data, math.Inf(1)); dist > 1e-10*floats.Norm(z, math.Inf(1)) {
+			t.Errorf("m=%d,n=%d,rank=%d: solution differs from the minimum-norm solution by %v", m, n, test.rank, dist)
+		}
+		// Every other solution, such as z, has a
+		// larger norm.
+		if test.rank < n {
+			if got, other := floats.Norm(res.X, 2), floats.Norm(z, 2); got >= other {
+				t.Errorf("m=%d,n=%d,rank=%d: norm of the solution %v not smaller than %v", m, n, test.rank, got, other)
+			}
+		}
+	}
+}
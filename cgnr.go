@@ -0,0 +1,132 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// CGNR implements the Conjugate Gradient method applied to the normal
+// equations
+//  A^T A x = A^T b
+// for solving the system of linear equations
+//  Ax = b,
+// where A is a general, possibly nonsymmetric matrix, or the least-squares
+// problem
+//  min |b - A x|
+// for a rectangular A. CGNR does not form A^T A, each iteration needs one
+// product with A and one with A^T. It is implemented in the CGLS variant,
+// which updates the residual r = b - A x of the original system instead of
+// that of the normal equations.
+//
+// The condition number of A^T A is the square of that of A, so CGNR
+// converges slowly on ill-conditioned systems. It is a cheap baseline for
+// systems where CG cannot be used and a robust fallback where the
+// Lanczos-type methods such as BiCG break down.
+//
+// CGNR is a RectMethod. It reports the norm of A^T r in
+// Context.NormalResidualNorm, and the solve stops when either |r| or
+// |A^T r| satisfies the stopping criterion of a rectangular system, see
+// Settings.Tolerance, also for a square A.
+//
+// References:
+//  - Hestenes, M. R., Stiefel, E.: Methods of conjugate gradients for
+//    solving linear systems. J. Res. Nat. Bur. Standards 49(6), 409-436
+//    (1952)
+//  - Björck, Å.: Numerical Methods for Least Squares Problems. SIAM (1996)
+//
+// CGNR needs MatVec and MatTransVec matrix operations, it does not use a
+// preconditioner.
+type CGNR struct {
+	resume int
+
+	// gamma is |A^T r|^2 of the previous
+	// iterate, gammaNew that of the current
+	// one.
+	gamma, gammaNew float64
+	rnorm           float64
+
+	// s and p have the length of x, q the
+	// length of b.
+	s []float64
+	p []float64
+	q []float64
+}
+
+// Init implements the Method interface.
+func (c *CGNR) Init(dim int) {
+	c.InitRect(dim, dim)
+}
+
+// InitRect implements the RectMethod interface.
+func (c *CGNR) InitRect(m, n int) {
+	if m <= 0 || n <= 0 {
+		panic("CGNR: dimension not positive")
+	}
+
+	c.s = reuse(c.s, n)
+	c.p = reuse(c.p, n)
+	c.q = reuse(c.q, m)
+	c.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (c *CGNR) Iterate(ctx *Context) (Operation, error) {
+	switch c.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = c.s
+		c.resume = 2
+		return MatTransVec, nil
+		// Compute s_0 = A^T r_0
+	case 2:
+		c.gamma = ctx.vec.dot(c.s, c.s)
+		ctx.vec.copy(c.p, c.s) // p_1 = s_0
+		fallthrough
+	case 3:
+		ctx.Src = c.p
+		ctx.Dst = c.q
+		c.resume = 4
+		return MatVec, nil
+		// Compute q_i = A p_i
+	case 4:
+		qq := ctx.vec.dot(c.q, c.q)
+		if qq == 0 {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"CGNR", "q"}
+		}
+		alpha := c.gamma / qq                            // α = |s_{i-1}|^2 / |q_i|^2
+		ctx.vec.addScaled(ctx.X, alpha, c.p)             // x_i = x_{i-1} + α p_i
+		rr := ctx.vec.axpyDot(-alpha, c.q, ctx.Residual) // r_i = r_{i-1} - α q_i
+		c.rnorm = math.Sqrt(rr)
+		ctx.Src = ctx.Residual
+		ctx.Dst = c.s
+		c.resume = 5
+		return MatTransVec, nil
+		// Compute s_i = A^T r_i
+	case 5:
+		c.gammaNew = ctx.vec.dot(c.s, c.s)
+		ctx.ResidualNorm = c.rnorm
+		ctx.NormalResidualNorm = math.Sqrt(c.gammaNew)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		c.resume = 6
+		return CheckResidualNorm, nil
+	case 6:
+		if ctx.Converged {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
+			return EndIteration, nil
+		}
+		beta := c.gammaNew / c.gamma             // β = |s_i|^2 / |s_{i-1}|^2
+		ctx.vec.addScaledTo(c.p, c.s, beta, c.p) // p_{i+1} = s_i + β p_i
+		c.gamma = c.gammaNew
+		c.resume = 3
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+
+	default:
+		panic("CGNR: Init not called")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestCGNR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		m, n int
+	}{
+		{1, 1},
+		{10, 10},
+		{50, 50},
+		{40, 10},
+		{100, 30},
+		{10, 40},
+	} {
+		m, n := test.m, test.n
+		a, d := rectangular(m, n, rnd)
+		ops := MatrixOps{MatVec: a.MulVec, MatTransVec: a.MulTransVec, Rows: m, Cols: n}
+		b := make([]float64, m)
+		for i := range b {
+			b[i] = rnd.NormFloat64()
+		}
+
+		// The least-squares solution, which for
+		// m < n and x_0 = 0 is the minimum-norm
+		// solution in the range of A^T.
+		var want mat.VecDense
+		if m >= n {
+			if err := want.SolveVec(d, mat.NewVecDense(m, b)); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			var aat mat.Dense
+			aat.Mul(d, d.T())
+			var y mat.VecDense
+			if err := y.SolveVec(&aat, mat.NewVecDense(m, b)); err != nil {
+				t.Fatal(err)
+			}
+			want.MulVec(d.T(), &y)
+		}
+
+		res, err := LinearSolve(ops, b, &CGNR{}, Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 1000,
+			NormA:         mat.Norm(d, 2),
+		})
+		if err != nil {
+			t.Errorf("m=%d,n=%d: unexpected error: %v", m, n, err)
+			continue
+		}
+		if dist := floats.Distance(res.X, want.RawVector().Data, math.Inf(1)); dist > 1e-9 {
+			t.Errorf("m=%d,n=%d: solution differs from the expected by %v", m, n, dist)
+		}
+		// A product with A and one with A^T in
+		// every iteration, and A^T r_0.
+		if res.Stats.MatVec != 2*res.Stats.Iterations+1 {
+			t.Errorf("m=%d,n=%d: %d products in %d iterations", m, n, res.Stats.MatVec, res.Stats.Iterations)
+		}
+	}
+
+	// A is the rotation by π/2, so b^T A b = 0
+	// and the Lanczos-type methods break down,
+	// but A^T A = I.
+	a := MatrixOps{
+		MatVec: func(dst, x []float64) {
+			dst[0], dst[1] = x[1], -x[0]
+		},
+		MatTransVec: func(dst, x []float64) {
+			dst[0], dst[1] = -x[1], x[0]
+		},
+	}
+	res, err := LinearSolve(a, []float64{1, 2}, &CGNR{}, Settings{})
+	if err != nil {
+		t.Fatalf("rotation: unexpected error: %v", err)
+	}
+	if res.Stats.Iterations != 1 || floats.Distance(res.X, []float64{-2, 1}, math.Inf(1)) > 1e-14 {
+		t.Errorf("rotation: unexpected solution %v after %d iterations", res.X, res.Stats.Iterations)
+	}
+}
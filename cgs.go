@@ -0,0 +1,137 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// CGS implements the Conjugate Gradient Squared iterative method with
+// preconditioning for solving the system of linear equations
+//  Ax = b,
+// where A is a non-symmetric matrix. Like BiCGSTAB, CGS does not need
+// products with A^T. Its residual polynomial is the square of that of
+// BiCG, so it typically converges about twice as fast as BiCG when BiCG
+// converges, but its convergence is often erratic.
+//
+// If the recurrences break down, Iterate returns a *BreakdownError.
+//
+// References:
+//  - Sonneveld, P.: CGS, a fast Lanczos-type solver for nonsymmetric
+//    linear systems. SIAM J. Sci. Stat. Comput. 10(1), 36-52 (1989)
+//
+// CGS needs MatVec and PSolve matrix operations.
+type CGS struct {
+	first  bool
+	resume int
+
+	rho, rhoPrev float64
+	alpha        float64
+
+	rt   []float64
+	p    []float64
+	q    []float64
+	u    []float64
+	phat []float64
+	v    []float64
+}
+
+// Init implements the Method interface.
+func (c *CGS) Init(dim int) {
+	if dim <= 0 {
+		panic("CGS: dimension not positive")
+	}
+
+	c.rt = reuse(c.rt, dim)
+	c.p = reuse(c.p, dim)
+	c.q = reuse(c.q, dim)
+	c.u = reuse(c.u, dim)
+	c.phat = reuse(c.phat, dim)
+	c.v = reuse(c.v, dim)
+	c.first = true
+	c.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (c *CGS) Iterate(ctx *Context) (Operation, error) {
+	switch c.resume {
+	case 1:
+		if c.first {
+			ctx.vec.copy(c.rt, ctx.Residual)
+		}
+		c.rho = ctx.vec.dot(c.rt, ctx.Residual)
+		if math.Abs(c.rho) < rhoBreakdownTol {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"CGS", "rho"}
+		}
+		if c.first {
+			ctx.vec.copy(c.u, ctx.Residual)
+			ctx.vec.copy(c.p, c.u)
+		} else {
+			beta := c.rho / c.rhoPrev
+			ctx.vec.addScaledTo(c.u, ctx.Residual, beta, c.q) // u_i = r_{i-1} + β q_{i-1}
+			// p_i = u_i + β (q_{i-1} + β p_{i-1})
+			ctx.vec.scale(beta, c.p)
+			ctx.vec.add(c.p, c.q)
+			ctx.vec.scale(beta, c.p)
+			ctx.vec.add(c.p, c.u)
+		}
+		ctx.Src = c.p
+		ctx.Dst = c.phat
+		c.resume = 2
+		return PSolve, nil
+		// Solve M p^_i = p_i.
+	case 2:
+		ctx.Src = c.phat
+		ctx.Dst = c.v
+		c.resume = 3
+		return MatVec, nil
+		// Compute Ap^_i -> v_i.
+	case 3:
+		sigma := ctx.vec.dot(c.rt, c.v)
+		if math.Abs(sigma) < rhoBreakdownTol {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"CGS", "sigma"}
+		}
+		c.alpha = c.rho / sigma
+		ctx.vec.addScaledTo(c.q, c.u, -c.alpha, c.v) // q_i = u_i - α v_i
+		// u_i + q_i overwrites u_i, which is not
+		// needed any more.
+		ctx.vec.add(c.u, c.q)
+		ctx.Src = c.u
+		ctx.Dst = c.phat
+		c.resume = 4
+		return PSolve, nil
+		// Solve M u^_i = u_i + q_i.
+	case 4:
+		ctx.vec.addScaled(ctx.X, c.alpha, c.phat) // x_i = x_{i-1} + α u^_i
+		ctx.Src = c.phat
+		ctx.Dst = c.v
+		c.resume = 5
+		return MatVec, nil
+		// Compute Au^_i -> v_i.
+	case 5:
+		// r_i = r_{i-1} - α Au^_i
+		rr := ctx.vec.axpyDot(-c.alpha, c.v, ctx.Residual)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.ResidualNorm = math.Sqrt(rr)
+		ctx.Converged = false
+		c.resume = 6
+		return CheckResidualNorm, nil
+	case 6:
+		if ctx.Converged {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
+			return EndIteration, nil
+		}
+		c.rhoPrev = c.rho
+		c.first = false
+		c.resume = 1
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+
+	default:
+		panic("CGS: Init not called")
+	}
+}
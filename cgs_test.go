@@ -0,0 +1,65 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestCGS(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(3, rnd),
+		problem.RandomSPD(4, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(10, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(50, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.RandomSPD(200, rnd),
+		problem.RandomSPD(500, rnd),
+		market("nos1", 1e-7),
+		market("nos4", 1e-12),
+		market("nos5", 1e-11),
+		market("bcsstm20", 1e-9),
+		market("bcsstm22", 1e-10),
+		// CGS breaks down on e05r0000.
+		// market("e05r0000", 1e-10),
+		market("e05r0100", 1e-9),
+		market("gre__115", 1e-12),
+		market("gre__185", 1e-6),
+		market("arc130", 1e-4),
+	} {
+		_, err := p.Solve(&iterative.CGS{}, iterative.Settings{
+			MaxIterations: 10 * p.MaxIterations,
+			Tolerance:     1e-14,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCGSBreakdown(t *testing.T) {
+	// A is the rotation by π/2, so b^T A b = 0
+	// for any b.
+	a := iterative.MatrixOps{
+		MatVec: func(dst, x []float64) {
+			dst[0], dst[1] = x[1], -x[0]
+		},
+	}
+	_, err := iterative.LinearSolve(a, []float64{1, 2}, &iterative.CGS{}, iterative.Settings{})
+	var breakdown *iterative.BreakdownError
+	if !errors.As(err, &breakdown) || breakdown.Method != "CGS" {
+		t.Errorf("unexpected error %v, want a CGS breakdown", err)
+	}
+}
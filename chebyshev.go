@@ -0,0 +1,126 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// Chebyshev implements the Chebyshev iteration with preconditioning for
+// solving the system of linear equations
+//  Ax = b,
+// where the eigenvalues of the preconditioned matrix M^{-1} A are real and
+// lie in the interval [EigMin, EigMax] with 0 < EigMin < EigMax, for
+// example if A and M are symmetric positive definite. The residual
+// polynomial of the iteration is the scaled and shifted Chebyshev
+// polynomial that is smallest on the interval, so the residual norm
+// decreases asymptotically by the factor
+//  (sqrt(κ) - 1) / (sqrt(κ) + 1),  κ = EigMax / EigMin,
+// per iteration, as for CG. Unlike CG, Chebyshev does not compute inner
+// products, the only reduction in an iteration is the residual norm for
+// CheckResidualNorm, which makes it attractive when reductions are
+// expensive, for example on distributed memory. Convergence depends on the
+// quality of the bounds, the iteration diverges if the spectrum is not in
+// the interval, and converges slowly if the interval is much larger than
+// the spectrum.
+//
+// References:
+//  - Gutknecht, M. H., Röllin, S.: The Chebyshev iteration revisited.
+//    Parallel Comput. 28(2), 263-283 (2002)
+//  - Saad, Y.: Iterative Methods for Sparse Linear Systems. 2nd ed.
+//    SIAM (2003)
+//
+// Chebyshev needs MatVec and PSolve matrix operations.
+type Chebyshev struct {
+	// EigMin and EigMax are the lower and the
+	// upper bound on the eigenvalues of the
+	// preconditioned matrix M^{-1} A. They
+	// must satisfy 0 < EigMin < EigMax.
+	EigMin, EigMax float64
+
+	first  bool
+	resume int
+
+	// theta and delta are the center and the
+	// half-width of the interval, and sigma
+	// their ratio.
+	theta, delta, sigma float64
+	rho                 float64
+
+	z  []float64
+	d  []float64
+	ad []float64
+}
+
+// Init implements the Method interface.
+func (c *Chebyshev) Init(dim int) {
+	if dim <= 0 {
+		panic("Chebyshev: dimension not positive")
+	}
+	if !(0 < c.EigMin && c.EigMin < c.EigMax) {
+		panic("Chebyshev: invalid eigenvalue bounds")
+	}
+
+	c.theta = (c.EigMax + c.EigMin) / 2
+	c.delta = (c.EigMax - c.EigMin) / 2
+	c.sigma = c.theta / c.delta
+
+	c.z = reuse(c.z, dim)
+	c.d = reuse(c.d, dim)
+	c.ad = reuse(c.ad, dim)
+	c.first = true
+	c.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (c *Chebyshev) Iterate(ctx *Context) (Operation, error) {
+	switch c.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = c.z
+		c.resume = 2
+		return PSolve, nil
+		// Solve M z = r_{i-1}
+	case 2:
+		if c.first {
+			// d_1 = z / θ
+			ctx.vec.copy(c.d, c.z)
+			ctx.vec.scale(1/c.theta, c.d)
+			c.rho = 1 / c.sigma
+		} else {
+			// d_i = ρ_i ρ_{i-1} d_{i-1} + (2 ρ_i / δ) z
+			rho := 1 / (2*c.sigma - c.rho)
+			ctx.vec.scale(rho*c.rho, c.d)
+			ctx.vec.addScaled(c.d, 2*rho/c.delta, c.z)
+			c.rho = rho
+		}
+		ctx.vec.add(ctx.X, c.d) // x_i = x_{i-1} + d_i
+		ctx.Src = c.d
+		ctx.Dst = c.ad
+		c.resume = 3
+		return MatVec, nil
+		// Compute Ad_i
+	case 3:
+		// r_i = r_{i-1} - Ad_i
+		rr := ctx.vec.axpyDot(-1, c.ad, ctx.Residual)
+		ctx.ResidualNorm = math.Sqrt(rr)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		c.resume = 4
+		return CheckResidualNorm, nil
+	case 4:
+		if ctx.Converged {
+			c.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
+			return EndIteration, nil
+		}
+		c.first = false
+		c.resume = 1
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+
+	default:
+		panic("Chebyshev: Init not called")
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+)
+
+// chebyshevBound returns the bound 1/T_k(σ) on the reduction of the
+// residual norm by k steps of the Chebyshev iteration on the interval
+// [lmin, lmax], where σ = (lmax+lmin)/(lmax-lmin).
+func chebyshevBound(k int, lmin, lmax float64) float64 {
+	sigma := (lmax + lmin) / (lmax - lmin)
+	return 1 / math.Cosh(float64(k)*math.Acosh(sigma))
+}
+
+func TestChebyshev(t *testing.T) {
+	const n = 200
+	// Evenly spread eigenvalues of a diagonal
+	// matrix.
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = 1 + 99*float64(i)/(n-1)
+	}
+	// Eigenvalues of the 1-D Laplacian.
+	lapMin := 2 - 2*math.Cos(math.Pi/(n+1))
+	lapMax := 2 - 2*math.Cos(n*math.Pi/(n+1))
+	lap := tridiagonalCSR(n, -1, 2, -1)
+
+	for _, test := range []struct {
+		name       string
+		a          iterative.MatrixOps
+		lmin, lmax float64
+		// exact indicates that the bounds are the
+		// extreme eigenvalues, which are present
+		// in b.
+		exact bool
+	}{
+		{"diagonal", iterative.DiagonalOps(d), 1, 100, true},
+		{"diagonal wide", iterative.DiagonalOps(d), 0.5, 200, false},
+		{"laplacian", iterative.MatrixOps{MatVec: lap.MulVec}, lapMin, lapMax, true},
+	} {
+		b := make([]float64, n)
+		for i := range b {
+			b[i] = 1 + float64(i%7)
+		}
+		const tol = 1e-8
+		l := iterative.NewLoop(test.a, b, &iterative.Chebyshev{EigMin: test.lmin, EigMax: test.lmax}, iterative.Settings{
+			Tolerance:     tol,
+			MaxIterations: 10000,
+		})
+		r0 := l.Context().ResidualNorm
+		var k int
+		for {
+			done, err := l.Step()
+			if l.Operation() == iterative.EndIteration {
+				k++
+				// For a symmetric A the bound holds
+				// in the Euclidean norm.
+				rnorm := l.Context().ResidualNorm
+				if bound := chebyshevBound(k, test.lmin, test.lmax) * r0; rnorm > (1+1e-6)*bound {
+					t.Errorf("%v: residual norm %v after %d iterations above the bound %v", test.name, rnorm, k, bound)
+					break
+				}
+			}
+			if done {
+				if err != nil {
+					t.Errorf("%v: unexpected error: %v", test.name, err)
+				}
+				break
+			}
+		}
+
+		// The predicted number of iterations.
+		want := 1
+		for chebyshevBound(want, test.lmin, test.lmax) >= tol*floats.Norm(b, 2)/r0 {
+			want++
+		}
+		if k > want {
+			t.Errorf("%v: %d iterations, predicted %d", test.name, k, want)
+		}
+		if test.exact && k < want-want/10 {
+			t.Errorf("%v: %d iterations, much faster than predicted %d", test.name, k, want)
+		}
+	}
+}
+
+func TestChebyshevPreconditioned(t *testing.T) {
+	// The spectrum of the 1-D mass matrix of
+	// linear elements preconditioned by its
+	// diagonal is in [1/2, 3/2].
+	const n = 100
+	a, b := L2Projector(0, 1, n, func(x float64) float64 {
+		return x * math.Sin(x)
+	})
+	h := 1 / float64(n)
+	d := make([]float64, n+1)
+	for i := range d {
+		d[i] = 2 * h / 3
+	}
+	d[0] = h / 3
+	d[n] = h / 3
+	p := iterative.DiagonalInverse(d)
+
+	// Count the operations.
+	ops := make(map[iterative.Operation]int)
+	const tol = 1e-10
+	l := iterative.NewLoop(a, b, &iterative.Chebyshev{EigMin: 0.5, EigMax: 1.5}, iterative.Settings{
+		Tolerance: tol,
+		PSolve:    p.Apply,
+	})
+	r0 := l.Context().ResidualNorm
+	for {
+		done, err := l.Step()
+		ops[l.Operation()]++
+		if done {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			break
+		}
+	}
+	for op := range ops {
+		switch op {
+		case iterative.MatVec, iterative.PSolve, iterative.CheckResidualNorm, iterative.EndIteration:
+		default:
+			t.Errorf("unexpected operation %v", op)
+		}
+	}
+
+	// The bound holds in the D^{-1}-norm, which
+	// differs from the scaled Euclidean norm by
+	// at most sqrt(2).
+	k := l.Result().Stats.Iterations
+	want := 1
+	for math.Sqrt2*chebyshevBound(want, 0.5, 1.5) >= tol*floats.Norm(b, 2)/r0 {
+		want++
+	}
+	if k > want {
+		t.Errorf("%d iterations, predicted %d", k, want)
+	}
+	for _, op := range []iterative.Operation{iterative.MatVec, iterative.PSolve, iterative.CheckResidualNorm, iterative.EndIteration} {
+		if ops[op] != k {
+			t.Errorf("%d %v operations in %d iterations", ops[op], op, k)
+		}
+	}
+}
+
+func TestChebyshevInvalidBounds(t *testing.T) {
+	for _, test := range []struct {
+		min, max float64
+	}{
+		{0, 0},
+		{0, 1},
+		{-1, 1},
+		{2, 1},
+		{1, 1},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic with EigMin=%v, EigMax=%v", test.min, test.max)
+				}
+			}()
+			c := &iterative.Chebyshev{EigMin: test.min, EigMax: test.max}
+			c.Init(10)
+		}()
+	}
+}
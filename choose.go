@@ -0,0 +1,247 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+const (
+	// strongDominance is the minimum ratio of the diagonal
+	// element to the sum of the off-diagonal elements in a
+	// row for which the Jacobi preconditioner is chosen.
+	strongDominance = 2
+	// symmetricTol is the relative asymmetry below which a
+	// matrix is treated as symmetric.
+	symmetricTol = 1e-12
+)
+
+// PrecondKind is the kind of a preconditioner chosen by ChoosePreconditioner.
+type PrecondKind int
+
+const (
+	// NoPrecond is the identity preconditioner.
+	NoPrecond PrecondKind = iota
+	// JacobiPrecond is the inverse of the diagonal.
+	JacobiPrecond
+	// SSORPrecond is the symmetric Gauss-Seidel
+	// preconditioner, SSOR with the relaxation
+	// parameter 1.
+	SSORPrecond
+	// IC0Precond is the incomplete Cholesky
+	// factorization without fill-in.
+	IC0Precond
+	// ILU0Precond is the incomplete LU factorization
+	// without fill-in of the matrix reordered by the
+	// reverse Cuthill-McKee algorithm.
+	ILU0Precond
+)
+
+// String returns the name of the preconditioner kind.
+func (k PrecondKind) String() string {
+	switch k {
+	case NoPrecond:
+		return "none"
+	case JacobiPrecond:
+		return "Jacobi"
+	case SSORPrecond:
+		return "SSOR"
+	case IC0Precond:
+		return "IC(0)"
+	case ILU0Precond:
+		return "ILU(0)"
+	}
+	return fmt.Sprintf("PrecondKind(%d)", int(k))
+}
+
+// PrecondBudget limits the resources used by the preconditioner chosen by
+// ChoosePreconditioner. A zero field means no limit.
+type PrecondBudget struct {
+	// MaxMemory is the maximum number of bytes
+	// of storage of the preconditioner in
+	// addition to the matrix.
+	MaxMemory int
+	// MaxTime is the maximum time spent by
+	// the construction of the preconditioner.
+	// It is checked during the factorizations
+	// so it can be exceeded slightly.
+	MaxTime time.Duration
+}
+
+// PrecondChoice is a preconditioner chosen by ChoosePreconditioner together
+// with the description of the choice.
+type PrecondChoice struct {
+	Preconditioner
+
+	// Kind is the kind of the preconditioner.
+	Kind PrecondKind
+	// Reason describes why the preconditioner
+	// was chosen, including the reasons for
+	// rejecting the preferred alternatives.
+	Reason string
+}
+
+// String returns the kind of the preconditioner and the reason for its
+// choice, suitable for logging.
+func (c *PrecondChoice) String() string {
+	return fmt.Sprintf("%v: %v", c.Kind, c.Reason)
+}
+
+// ChoosePreconditioner chooses and constructs a preconditioner for the
+// square matrix a with the properties props returned by sparse.Analyze(a).
+// The choice is made by the following rules applied in order:
+//  - no preconditioner if a diagonal element is zero or not stored,
+//    because neither the diagonal scaling nor the incomplete
+//    factorizations without pivoting are then defined,
+//  - Jacobi if a is strongly diagonally dominant by rows, that is, if
+//    props.MinDominance is at least 2,
+//  - IC(0) if a is symmetric with a positive diagonal,
+//  - ILU(0) of a reordered by sparse.RCM otherwise.
+// If an incomplete factorization breaks down on a pivot that is not
+// positive or is small relative to its row, or it exceeds the time budget,
+// Jacobi is used instead. If the factorization does not fit into the memory
+// budget, SSOR is used instead of IC(0) and Jacobi instead of ILU(0), and if
+// not even the diagonal fits, no preconditioner is used.
+//
+// The returned choice describes the chosen preconditioner and the reasons
+// for it. ChoosePreconditioner returns an error if props does not describe
+// a matrix of the dimension and number of elements of a. It panics if a is
+// not square or the budget is negative.
+func ChoosePreconditioner(a *sparse.CSR, props sparse.StructureReport, budget PrecondBudget) (*PrecondChoice, error) {
+	n, c := a.Dims()
+	if n != c {
+		panic("iterative: matrix not square")
+	}
+	if budget.MaxMemory < 0 || budget.MaxTime < 0 {
+		panic("iterative: negative budget")
+	}
+	if props.N != n || props.NNZ != a.NNZ() {
+		return nil, errors.New("iterative: structure report does not match the matrix")
+	}
+
+	fits := func(bytes int) bool {
+		return budget.MaxMemory == 0 || bytes <= budget.MaxMemory
+	}
+	var deadline time.Time
+	if budget.MaxTime > 0 {
+		deadline = time.Now().Add(budget.MaxTime)
+	}
+	none := func(reason string) (*PrecondChoice, error) {
+		return &PrecondChoice{identity(n), NoPrecond, reason}, nil
+	}
+	jacobi := func(reason string) (*PrecondChoice, error) {
+		if !fits(8 * n) {
+			return none(reason + "; the diagonal exceeds the memory budget")
+		}
+		return &PrecondChoice{DiagonalInverse(sparse.Diagonal(a)), JacobiPrecond, reason}, nil
+	}
+
+	switch {
+	case n == 0:
+		return none("empty matrix")
+	case props.ZeroDiagonals > 0:
+		return none(fmt.Sprintf("%d zero diagonal elements", props.ZeroDiagonals))
+	case props.MinDominance >= strongDominance:
+		return jacobi(fmt.Sprintf("strongly diagonally dominant with minimum ratio %.3g", props.MinDominance))
+	}
+
+	d := sparse.Diagonal(a)
+	positive := true
+	for _, v := range d {
+		if v <= 0 {
+			positive = false
+			break
+		}
+	}
+	if props.Symmetric(symmetricTol) && positive {
+		const reason = "symmetric with positive diagonal"
+		// L and the copy of a store the lower
+		// triangle including the full diagonal.
+		lower := (props.NNZ + n) / 2
+		if need := 8 * (3*lower + 3*n + 1); !fits(need) {
+			// The diagonal and its square root
+			// in the splitting and the workspace.
+			if !fits(8 * 3 * n) {
+				return none(fmt.Sprintf("%v; IC(0) and SSOR exceed the memory budget", reason))
+			}
+			f, err := (&SSOR{}).Splitting(a)
+			if err != nil {
+				return nil, err
+			}
+			p := &ssorPreconditioner{f: f, y: make([]float64, n)}
+			return &PrecondChoice{p, SSORPrecond, fmt.Sprintf("%v; IC(0) needs %d bytes over the memory budget", reason, need)}, nil
+		}
+		var p IC0
+		if err := p.refresh(a, deadline); err != nil {
+			return jacobi(fmt.Sprintf("%v; IC(0) failed: %v", reason, err))
+		}
+		return &PrecondChoice{&p, IC0Precond, reason}, nil
+	}
+
+	reason := "unsymmetric"
+	if props.Symmetric(symmetricTol) {
+		reason = "symmetric with non-positive diagonal elements"
+	}
+	// The factors, the copy of the reordered a,
+	// the permutation and the workspace for
+	// applying it.
+	if need := 8 * (3*props.NNZ + 5*n + 1); !fits(need) {
+		return jacobi(fmt.Sprintf("%v; ILU(0) needs %d bytes over the memory budget", reason, need))
+	}
+	perm, err := sparse.RCM(a)
+	if err != nil {
+		return nil, err
+	}
+	var p ILU0
+	if err := p.refresh(sparse.Permute(a, perm, perm), deadline); err != nil {
+		return jacobi(fmt.Sprintf("%v; ILU(0) failed: %v", reason, err))
+	}
+	return &PrecondChoice{NewPermutedPreconditioner(&p, perm), ILU0Precond, reason + " with nonzero diagonal, reordered by RCM"}, nil
+}
+
+// ssorPreconditioner is the SSOR preconditioner
+//  M = F F^T
+// with the factor F returned by SSOR.Splitting with the relaxation
+// parameter 1, so M = (D+L) D^{-1} (D+L^T) is the symmetric Gauss-Seidel
+// preconditioner of a symmetric A = L + D + L^T.
+type ssorPreconditioner struct {
+	f Preconditioner
+	y []float64
+}
+
+// Apply implements the Preconditioner interface.
+func (p *ssorPreconditioner) Apply(dst, rhs []float64) error {
+	checkLen(dst, rhs, len(p.y))
+	// Solve F y = rhs and F^T z = y.
+	if err := p.f.Apply(p.y, rhs); err != nil {
+		return err
+	}
+	return p.f.ApplyTrans(dst, p.y)
+}
+
+// ApplyTrans implements the Preconditioner interface. Since M is
+// symmetric, ApplyTrans is equivalent to Apply.
+func (p *ssorPreconditioner) ApplyTrans(dst, rhs []float64) error {
+	return p.Apply(dst, rhs)
+}
+
+// identity is the identity Preconditioner of the given dimension.
+type identity int
+
+// Apply implements the Preconditioner interface.
+func (p identity) Apply(dst, rhs []float64) error {
+	checkLen(dst, rhs, int(p))
+	copy(dst, rhs)
+	return nil
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p identity) ApplyTrans(dst, rhs []float64) error {
+	return p.Apply(dst, rhs)
+}
@@ -0,0 +1,236 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"compress/gzip"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// marketCSR returns the Matrix Market matrix name from the testdata
+// directory read in the same way as by market.
+func marketCSR(name string) *sparse.CSR {
+	f, err := os.Open("testdata/" + name + ".mtx.gz")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		panic(err)
+	}
+	m, err := mmarket.NewReader(gz).Read()
+	if err != nil {
+		panic(err)
+	}
+	return sparse.NewCSRFromTriplet(m)
+}
+
+// tridiagonalCSR returns the n×n tridiagonal matrix with the constant
+// diagonals lower, diag and upper.
+func tridiagonalCSR(n int, lower, diag, upper float64) *sparse.CSR {
+	t := sparse.NewTriplet(n, n)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			t.Append(i, i-1, lower)
+		}
+		t.Append(i, i, diag)
+		if i < n-1 {
+			t.Append(i, i+1, upper)
+		}
+	}
+	return sparse.NewCSRFromTriplet(t)
+}
+
+func TestChoosePreconditioner(t *testing.T) {
+	const maxIter = 2000
+	for _, test := range []struct {
+		name string
+		want iterative.PrecondKind
+	}{
+		{"bcsstm20", iterative.JacobiPrecond},
+		{"bcsstm22", iterative.JacobiPrecond},
+		// IC(0) breaks down on nos1.
+		{"nos1", iterative.JacobiPrecond},
+		{"nos4", iterative.IC0Precond},
+		{"nos5", iterative.IC0Precond},
+		{"arc130", iterative.ILU0Precond},
+		{"fs_183_1", iterative.ILU0Precond},
+		{"fs_183_6", iterative.ILU0Precond},
+		{"gre__115", iterative.ILU0Precond},
+		{"hor__131", iterative.ILU0Precond},
+		{"mcca", iterative.ILU0Precond},
+		{"steam1", iterative.ILU0Precond},
+		{"steam3", iterative.ILU0Precond},
+		{"e05r0000", iterative.NoPrecond},
+		{"impcol_c", iterative.NoPrecond},
+		{"west0067", iterative.NoPrecond},
+	} {
+		a := marketCSR(test.name)
+		c, err := iterative.ChoosePreconditioner(a, sparse.Analyze(a), iterative.PrecondBudget{})
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if c.Kind != test.want {
+			t.Errorf("%v: unexpected choice %v, want %v", test.name, c, test.want)
+		}
+		if c.Reason == "" {
+			t.Errorf("%v: no reason for the choice", test.name)
+		}
+
+		n, _ := a.Dims()
+		b := make([]float64, n)
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = 1
+		}
+		a.MulVec(b, x)
+		ops := iterative.MatrixOps{MatVec: a.MulVec, MatTransVec: a.MulTransVec}
+		iters := func(settings iterative.Settings) int {
+			settings.Tolerance = 1e-8
+			settings.MaxIterations = maxIter
+			res, err := iterative.LinearSolve(ops, b, &iterative.BiCGSTAB{}, settings)
+			if err != nil {
+				return maxIter
+			}
+			return res.Stats.Iterations
+		}
+		plain := iters(iterative.Settings{})
+		prec := iters(iterative.Settings{PSolve: c.Apply, PSolveTrans: c.ApplyTrans})
+		if prec > 2*plain {
+			t.Errorf("%v: %v preconditioned solve needs %d iterations, unpreconditioned %d", test.name, c.Kind, prec, plain)
+		}
+	}
+}
+
+func TestChoosePreconditionerExact(t *testing.T) {
+	// The incomplete factorizations of
+	// tridiagonal matrices have no fill-in
+	// so they are exact.
+	const n = 50
+	rnd := rand.New(rand.NewSource(1))
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	for _, test := range []struct {
+		a    *sparse.CSR
+		want iterative.PrecondKind
+	}{
+		{tridiagonalCSR(n, -1, 2, -1), iterative.IC0Precond},
+		{tridiagonalCSR(n, -1.5, 2, -0.5), iterative.ILU0Precond},
+	} {
+		c, err := iterative.ChoosePreconditioner(test.a, sparse.Analyze(test.a), iterative.PrecondBudget{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Kind != test.want {
+			t.Errorf("unexpected choice %v, want %v", c, test.want)
+			continue
+		}
+		ax := make([]float64, n)
+		got := make([]float64, n)
+		test.a.MulVec(ax, x)
+		if err := c.Apply(got, ax); err != nil || !floats.EqualApprox(got, x, 1e-10) {
+			t.Errorf("%v: Apply not the inverse", c.Kind)
+		}
+		sparse.Transpose(test.a).MulVec(ax, x)
+		if err := c.ApplyTrans(got, ax); err != nil || !floats.EqualApprox(got, x, 1e-10) {
+			t.Errorf("%v: ApplyTrans not the inverse", c.Kind)
+		}
+	}
+}
+
+func TestChoosePreconditionerFallback(t *testing.T) {
+	const n = 100
+	spd := tridiagonalCSR(n, -1, 2, -1)
+	unsym := tridiagonalCSR(n, -1.5, 2, -0.5)
+	// Symmetric with a positive diagonal
+	// but indefinite.
+	indef := tridiagonalCSR(n, -1.5, 1, -1.5)
+	// Unsymmetric and singular with a zero
+	// pivot in ILU(0).
+	tr := sparse.NewTriplet(2, 2)
+	tr.Append(0, 0, 1)
+	tr.Append(0, 1, 2)
+	tr.Append(1, 0, 3)
+	tr.Append(1, 1, 6)
+	singular := sparse.NewCSRFromTriplet(tr)
+
+	for _, test := range []struct {
+		name   string
+		a      *sparse.CSR
+		budget iterative.PrecondBudget
+		want   iterative.PrecondKind
+		reason string
+	}{
+		{"IC(0) breakdown", indef, iterative.PrecondBudget{}, iterative.JacobiPrecond, "IC(0) breakdown"},
+		{"ILU(0) breakdown", singular, iterative.PrecondBudget{}, iterative.JacobiPrecond, "ILU(0) breakdown"},
+		{"IC(0) memory", spd, iterative.PrecondBudget{MaxMemory: 24 * n}, iterative.SSORPrecond, "memory budget"},
+		{"SSOR memory", spd, iterative.PrecondBudget{MaxMemory: 8}, iterative.NoPrecond, "memory budget"},
+		{"ILU(0) memory", unsym, iterative.PrecondBudget{MaxMemory: 8 * n}, iterative.JacobiPrecond, "memory budget"},
+		{"IC(0) time", spd, iterative.PrecondBudget{MaxTime: time.Nanosecond}, iterative.JacobiPrecond, "time budget"},
+		{"ILU(0) time", unsym, iterative.PrecondBudget{MaxTime: time.Nanosecond}, iterative.JacobiPrecond, "time budget"},
+		{"IC(0) fits", spd, iterative.PrecondBudget{MaxMemory: 1 << 20, MaxTime: time.Minute}, iterative.IC0Precond, ""},
+	} {
+		c, err := iterative.ChoosePreconditioner(test.a, sparse.Analyze(test.a), test.budget)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if c.Kind != test.want || !strings.Contains(c.Reason, test.reason) {
+			t.Errorf("%v: unexpected choice %v", test.name, c)
+		}
+		if c.Kind == iterative.SSORPrecond || c.Kind == iterative.IC0Precond {
+			if err := iterative.CheckSymmetricPreconditioner(c, n, rand.New(rand.NewSource(1))); err != nil {
+				t.Errorf("%v: %v", test.name, err)
+			}
+		}
+	}
+
+	// The SSOR preconditioner of A = L + D + L^T
+	// is (D+L) D^{-1} (D+L^T).
+	c, _ := iterative.ChoosePreconditioner(spd, sparse.Analyze(spd), iterative.PrecondBudget{MaxMemory: 24 * n})
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = float64(i + 1)
+	}
+	v := make([]float64, n)
+	for i := range v {
+		// v = D^{-1} (D+L^T) x
+		v[i] = 2 * x[i]
+		if i < n-1 {
+			v[i] -= x[i+1]
+		}
+		v[i] /= 2
+	}
+	mx := make([]float64, n)
+	for i := range mx {
+		// mx = (D+L) v
+		mx[i] = 2 * v[i]
+		if i > 0 {
+			mx[i] -= v[i-1]
+		}
+	}
+	got := make([]float64, n)
+	if err := c.Apply(got, mx); err != nil || !floats.EqualApprox(got, x, 1e-12) {
+		t.Errorf("SSOR not the inverse of (D+L) D^{-1} (D+L^T)")
+	}
+
+	if _, err := iterative.ChoosePreconditioner(spd, sparse.Analyze(singular), iterative.PrecondBudget{}); err == nil {
+		t.Errorf("no error with a report of another matrix")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command iterbench runs the solver benchmarks of package iterative and
+// prints a comparison table.
+//
+// Usage:
+//  iterbench [-methods CG,BiCGSTAB,GMRES(30)] [-precond none,Jacobi]
+//            [-sizes 64,256,1024]
+//
+// Each row of the table shows the time and the allocations per solve and
+// the number of iterations and matrix-vector products. The same
+// benchmarks are run by
+//  go test -bench Solve github.com/vladimir-ch/iterative
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vladimir-ch/iterative/internal/bench"
+)
+
+func main() {
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Run runs iterbench with the command-line arguments args, without the
+// program name, writes the table to stdout and returns the exit status.
+func Run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("iterbench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		methods = fs.String("methods", strings.Join(bench.Methods, ","), "comma-separated `list` of methods")
+		precond = fs.String("precond", strings.Join(bench.Preconditioners, ","), "comma-separated `list` of preconditioners")
+		sizes   = fs.String("sizes", "64,256", "comma-separated `list` of grid sizes")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var ns []int
+	for _, s := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			fmt.Fprintf(stderr, "iterbench: bad grid size %q\n", s)
+			return 2
+		}
+		ns = append(ns, n)
+	}
+	ms := strings.Split(*methods, ",")
+	ps := strings.Split(*precond, ",")
+	if bad := unknown(ms, bench.Methods); bad != "" {
+		fmt.Fprintf(stderr, "iterbench: unknown method %q\n", bad)
+		return 2
+	}
+	if bad := unknown(ps, bench.Preconditioners); bad != "" {
+		fmt.Fprintf(stderr, "iterbench: unknown preconditioner %q\n", bad)
+		return 2
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "method\tprecond\tgrid\titers\tmatvecs\ttime/op\tallocs/op\tB/op\t")
+	status := 0
+	for _, m := range ms {
+		for _, p := range ps {
+			for _, n := range ns {
+				c := bench.Case{Method: m, Precond: p, N: n}
+				r := testing.Benchmark(c.Run)
+				if r.N == 0 {
+					fmt.Fprintf(w, "%s\t%s\t%dx%d\tfailed\t\t\t\t\t\n", m, p, n, n)
+					status = 1
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\t%dx%d\t%.0f\t%.0f\t%v\t%d\t%d\t\n", m, p, n, n,
+					r.Extra["iters/op"], r.Extra["matvecs/op"],
+					time.Duration(r.NsPerOp()), r.AllocsPerOp(), r.AllocedBytesPerOp())
+			}
+		}
+	}
+	w.Flush()
+	return status
+}
+
+// unknown returns the first element of list that is not in known, or an
+// empty string.
+func unknown(list, known []string) string {
+	for _, s := range list {
+		found := false
+		for _, k := range known {
+			if s == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return s
+		}
+	}
+	return ""
+}
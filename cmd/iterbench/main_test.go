@@ -0,0 +1,50 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping benchmark run in short mode")
+	}
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"-methods", "CG,BiCGSTAB", "-precond", "none", "-sizes", "8"}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("unexpected exit status %v\n%s", status, stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected table\n%s", stdout.String())
+	}
+	for i, want := range [][]string{
+		{"method", "precond", "grid", "iters", "matvecs", "time/op"},
+		{"CG", "none", "8x8"},
+		{"BiCGSTAB", "none", "8x8"},
+	} {
+		fields := strings.Fields(lines[i])
+		for j, f := range want {
+			if fields[j] != f {
+				t.Errorf("unexpected field %v in row %v: want %q, got %q", j, i, f, fields[j])
+			}
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-methods", "QMR"},
+		{"-precond", "ILU0"},
+		{"-sizes", "0"},
+		{"-sizes", "x"},
+	} {
+		stderr.Reset()
+		if status := Run(args, &stdout, &stderr); status != 2 {
+			t.Errorf("%v: unexpected exit status %v", args, status)
+		}
+	}
+}
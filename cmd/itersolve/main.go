@@ -0,0 +1,227 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command itersolve solves a linear system stored in Matrix Market files
+// with an iterative method.
+//
+// Usage:
+//  itersolve -A matrix.mtx[.gz] [-b rhs.mtx[.gz]|ones] [-method gmres]
+//            [-restart 30] [-tol 1e-8] [-maxiter 0] [-precond none]
+//            [-o x.mtx|x.csv|-]
+//
+// If -b is ones, the right-hand side is computed from the solution of all
+// ones. The statistics of the solve are printed to standard error and the
+// solution is written to the file given by -o, or to standard output if it
+// is "-". A file name ending in ".csv" selects the CSV format, otherwise the
+// Matrix Market array format is used.
+//
+// The exit status is 0 if the solve converged, 1 if it did not and 2 if
+// the arguments or the input files are invalid.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func main() {
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Exit statuses returned by Run.
+const (
+	exitConverged = 0
+	exitFailed    = 1
+	exitUsage     = 2
+)
+
+// methods holds the constructors of the iterative methods selected by the
+// -method flag.
+var methods = map[string]func(restart int) iterative.Method{
+	"bicg":      func(int) iterative.Method { return &iterative.BiCG{} },
+	"bicgstab":  func(int) iterative.Method { return &iterative.BiCGSTAB{} },
+	"bicgstabl": func(int) iterative.Method { return &iterative.BiCGSTABL{} },
+	"cg":        func(int) iterative.Method { return &iterative.CG{} },
+	"fgmres":    func(restart int) iterative.Method { return &iterative.FGMRES{Restart: restart} },
+	"gcrodr":    func(restart int) iterative.Method { return &iterative.GCRODR{Restart: restart} },
+	"gmres":     func(restart int) iterative.Method { return &iterative.GMRES{Restart: restart} },
+}
+
+// preconditioners holds the constructors of the preconditioners selected
+// by the -precond flag.
+var preconditioners = map[string]func(a *sparse.CSR) (iterative.Preconditioner, error){
+	"none": func(*sparse.CSR) (iterative.Preconditioner, error) { return nil, nil },
+	"jacobi": func(a *sparse.CSR) (iterative.Preconditioner, error) {
+		d := sparse.Diagonal(a)
+		for i, v := range d {
+			if v == 0 {
+				return nil, fmt.Errorf("zero diagonal element in row %d", i)
+			}
+		}
+		return iterative.DiagonalInverse(d), nil
+	},
+	"ic0": func(a *sparse.CSR) (iterative.Preconditioner, error) {
+		return iterative.NewIC0(a)
+	},
+	"ilu0": func(a *sparse.CSR) (iterative.Preconditioner, error) {
+		return iterative.NewILU0(a)
+	},
+}
+
+// Run runs itersolve with the command-line arguments args, without the
+// program name, and returns the exit status. The solution is written to
+// stdout if requested by -o, messages and the statistics are written to
+// stderr.
+func Run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("itersolve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		matrixPath = fs.String("A", "", "Matrix Market `file` with the matrix (required)")
+		rhsPath    = fs.String("b", "ones", "Matrix Market `file` with the right-hand side, or ones")
+		method     = fs.String("method", "gmres", "iterative method: "+keys(methods))
+		restart    = fs.Int("restart", 0, "restart parameter of GMRES, FGMRES and GCRODR, 0 means no restarts")
+		tol        = fs.Float64("tol", 1e-8, "relative residual tolerance")
+		maxIter    = fs.Int("maxiter", 0, "iteration limit, 0 means twice the dimension")
+		precond    = fs.String("precond", "none", "preconditioner: "+keys(preconditioners))
+		outPath    = fs.String("o", "", "output `file` for the solution, - for standard output")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	usage := func(format string, args ...interface{}) int {
+		fmt.Fprintf(stderr, "itersolve: "+format+"\n", args...)
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		return usage("unexpected arguments %v", fs.Args())
+	}
+	if *matrixPath == "" {
+		return usage("missing -A")
+	}
+	newMethod, ok := methods[*method]
+	if !ok {
+		return usage("unknown method %q, available: %s", *method, keys(methods))
+	}
+	newPrecond, ok := preconditioners[*precond]
+	if !ok {
+		return usage("unknown preconditioner %q, available: %s", *precond, keys(preconditioners))
+	}
+
+	a, err := mmarket.LoadMatrix(*matrixPath)
+	if err != nil {
+		return usage("%v", err)
+	}
+	r, c := a.Dims()
+	if r != c {
+		return usage("matrix is not square, it is %d×%d", r, c)
+	}
+	ops := iterative.NewMatrixOps(a)
+	var b []float64
+	if *rhsPath == "ones" {
+		x := make([]float64, r)
+		for i := range x {
+			x[i] = 1
+		}
+		b = make([]float64, r)
+		ops.MatVec(b, x)
+	} else {
+		b, err = mmarket.LoadVector(*rhsPath)
+		if err != nil {
+			return usage("%v", err)
+		}
+		if len(b) != r {
+			return usage("right-hand side has length %d, want %d", len(b), r)
+		}
+	}
+	if *restart < 0 || *restart > r {
+		return usage("restart %d out of range [0,%d]", *restart, r)
+	}
+	if *tol <= 0 || *tol >= 1 {
+		return usage("tolerance %g out of range (0,1)", *tol)
+	}
+	if *maxIter < 0 {
+		return usage("negative iteration limit")
+	}
+
+	settings := iterative.Settings{
+		Tolerance:     *tol,
+		MaxIterations: *maxIter,
+	}
+	p, err := newPrecond(a)
+	if err != nil {
+		return usage("preconditioner %s: %v", *precond, err)
+	}
+	if p != nil {
+		settings.PSolve = p.Apply
+		settings.PSolveTrans = p.ApplyTrans
+	}
+
+	res, solveErr := iterative.LinearSolve(ops, b, newMethod(*restart), settings)
+	reason := "converged"
+	if solveErr != nil {
+		reason = solveErr.Error()
+	}
+	fmt.Fprintf(stderr, "method:     %s\n", *method)
+	fmt.Fprintf(stderr, "iterations: %d\n", res.Stats.Iterations)
+	fmt.Fprintf(stderr, "matvecs:    %d\n", res.Stats.MatVec)
+	fmt.Fprintf(stderr, "psolves:    %d\n", res.Stats.PSolve)
+	fmt.Fprintf(stderr, "residual:   %g\n", res.Stats.ResidualNorm)
+	fmt.Fprintf(stderr, "runtime:    %v\n", res.Stats.Runtime)
+	fmt.Fprintf(stderr, "reason:     %s\n", reason)
+
+	if *outPath != "" {
+		if err := writeSolution(*outPath, res, stdout); err != nil {
+			fmt.Fprintf(stderr, "itersolve: %v\n", err)
+			return exitFailed
+		}
+	}
+	if solveErr != nil {
+		return exitFailed
+	}
+	return exitConverged
+}
+
+// writeSolution writes the solution to the file at path, or to stdout if
+// path is "-".
+func writeSolution(path string, res iterative.Result, stdout io.Writer) error {
+	format := iterative.MatrixMarket
+	if strings.HasSuffix(path, ".csv") {
+		format = iterative.CSV
+	}
+	if path == "-" {
+		return iterative.WriteSolution(stdout, res, format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = iterative.WriteSolution(f, res, format)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// keys returns the sorted keys of the map m with string keys separated by
+// commas.
+func keys(m interface{}) string {
+	var k []string
+	for _, name := range reflect.ValueOf(m).MapKeys() {
+		k = append(k, name.String())
+	}
+	sort.Strings(k)
+	return strings.Join(k, ", ")
+}
@@ -0,0 +1,162 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/mmarket"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	nos4 := filepath.Join("..", "..", "testdata", "nos4.mtx.gz")
+	rhs := filepath.Join("..", "..", "mmarket", "testdata", "symmetric_real_b.mtx")
+	for _, test := range []struct {
+		name   string
+		args   []string
+		status int
+		stderr []string // Substrings of the standard error.
+		output string   // Solution file compared with ones.
+	}{
+		{
+			name:   "cg",
+			args:   []string{"-A", nos4, "-method", "cg", "-tol", "1e-10", "-o", filepath.Join(dir, "x.mtx")},
+			status: 0,
+			stderr: []string{"method:     cg\n", "reason:     converged\n"},
+			output: filepath.Join(dir, "x.mtx"),
+		},
+		{
+			name:   "gmres jacobi",
+			args:   []string{"-A", nos4, "-b", "ones", "-method", "gmres", "-restart", "30", "-precond", "jacobi", "-o", filepath.Join(dir, "x.csv")},
+			status: 0,
+			stderr: []string{"method:     gmres\n", "psolves:", "reason:     converged\n"},
+		},
+		{
+			// The example from the package documentation.
+			name:   "gmres ilu0",
+			args:   []string{"-A", nos4, "-b", "ones", "-method", "gmres", "-restart", "30", "-tol", "1e-8", "-precond", "ilu0", "-o", filepath.Join(dir, "ilu0.mtx")},
+			status: 0,
+			stderr: []string{"method:     gmres\n", "psolves:", "reason:     converged\n"},
+			output: filepath.Join(dir, "ilu0.mtx"),
+		},
+		{
+			name:   "cg ic0",
+			args:   []string{"-A", nos4, "-method", "cg", "-tol", "1e-10", "-precond", "ic0", "-o", filepath.Join(dir, "ic0.mtx")},
+			status: 0,
+			stderr: []string{"method:     cg\n", "reason:     converged\n"},
+			output: filepath.Join(dir, "ic0.mtx"),
+		},
+		{
+			name:   "fgmres ilu0",
+			args:   []string{"-A", nos4, "-method", "fgmres", "-restart", "20", "-tol", "1e-10", "-precond", "ilu0", "-o", filepath.Join(dir, "fgmres.mtx")},
+			status: 0,
+			stderr: []string{"method:     fgmres\n", "reason:     converged\n"},
+			output: filepath.Join(dir, "fgmres.mtx"),
+		},
+		{
+			name:   "gcrodr jacobi",
+			args:   []string{"-A", nos4, "-method", "gcrodr", "-restart", "20", "-tol", "1e-10", "-precond", "jacobi", "-o", filepath.Join(dir, "gcrodr.mtx")},
+			status: 0,
+			stderr: []string{"method:     gcrodr\n", "reason:     converged\n"},
+			output: filepath.Join(dir, "gcrodr.mtx"),
+		},
+		{
+			name:   "bicgstabl ilu0",
+			args:   []string{"-A", nos4, "-method", "bicgstabl", "-tol", "1e-10", "-precond", "ilu0", "-o", filepath.Join(dir, "bicgstabl.mtx")},
+			status: 0,
+			stderr: []string{"method:     bicgstabl\n", "reason:     converged\n"},
+			output: filepath.Join(dir, "bicgstabl.mtx"),
+		},
+		{
+			name:   "iteration limit",
+			args:   []string{"-A", nos4, "-method", "bicgstab", "-maxiter", "2"},
+			status: 1,
+			stderr: []string{"iterations: 2\n", "reason:     iterative: iteration limit reached\n"},
+		},
+		{
+			name:   "missing matrix",
+			args:   []string{"-method", "cg"},
+			status: 2,
+			stderr: []string{"itersolve: missing -A\n"},
+		},
+		{
+			name:   "unknown method",
+			args:   []string{"-A", nos4, "-method", "qmr"},
+			status: 2,
+			stderr: []string{`unknown method "qmr", available: bicg, bicgstab, bicgstabl, cg, fgmres, gcrodr, gmres`},
+		},
+		{
+			name:   "unknown preconditioner",
+			args:   []string{"-A", nos4, "-precond", "ilu1"},
+			status: 2,
+			stderr: []string{`unknown preconditioner "ilu1", available: ic0, ilu0, jacobi, none`},
+		},
+		{
+			name:   "rhs length",
+			args:   []string{"-A", nos4, "-b", rhs},
+			status: 2,
+			stderr: []string{"right-hand side has length 3, want 100"},
+		},
+		{
+			name:   "missing file",
+			args:   []string{"-A", filepath.Join(dir, "missing.mtx")},
+			status: 2,
+			stderr: []string{"missing.mtx"},
+		},
+		{
+			name:   "bad flag",
+			args:   []string{"-A", nos4, "-tol", "x"},
+			status: 2,
+			stderr: []string{"invalid value"},
+		},
+	} {
+		var stdout, stderr bytes.Buffer
+		status := Run(test.args, &stdout, &stderr)
+		if status != test.status {
+			t.Errorf("%v: unexpected exit status %v, want %v\n%s", test.name, status, test.status, stderr.String())
+		}
+		for _, s := range test.stderr {
+			if !strings.Contains(stderr.String(), s) {
+				t.Errorf("%v: standard error does not contain %q\n%s", test.name, s, stderr.String())
+			}
+		}
+		if test.output == "" {
+			continue
+		}
+		x, err := mmarket.LoadVector(test.output)
+		if err != nil {
+			t.Errorf("%v: unexpected error reading the solution: %v", test.name, err)
+			continue
+		}
+		for i, v := range x {
+			if math.Abs(v-1) > 1e-6 {
+				t.Errorf("%v: unexpected solution element %v: %v", test.name, i, v)
+				break
+			}
+		}
+	}
+}
+
+func TestRunStdout(t *testing.T) {
+	a := filepath.Join("..", "..", "mmarket", "testdata", "symmetric_real.mtx")
+	b := filepath.Join("..", "..", "mmarket", "testdata", "symmetric_real_b.mtx")
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"-A", a, "-b", b, "-method", "gmres", "-o", "-"}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("unexpected exit status %v\n%s", status, stderr.String())
+	}
+	x, err := mmarket.NewReader(&stdout).ReadVector()
+	if err != nil {
+		t.Fatalf("unexpected error reading the solution: %v", err)
+	}
+	if len(x) != 3 {
+		t.Fatalf("unexpected solution length %v", len(x))
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+// CR implements the Conjugate Residual iterative method with preconditioning
+// for solving the system of linear equations
+//  Ax = b,
+// where A is a symmetric positive definite matrix. If CR encounters a search
+// direction p with Ap·M^{-1}Ap <= 0 or a preconditioned residual z with
+// z·Az <= 0, the matrix is not positive definite and Iterate returns a
+// *NotPositiveDefiniteError.
+//
+// CR builds the same Krylov subspaces as CG but instead of the A-norm of the
+// error it minimizes the M^{-1}-norm of the residual over them. Without a
+// preconditioner the residual norm therefore decreases monotonically and CR
+// reaches a tolerance on the residual norm at the latest when CG does. CR
+// needs two more vectors than CG.
+//
+// CR needs MatVec and PSolve matrix operations.
+type CR struct {
+	first  bool
+	resume int
+
+	rho, rhoPrev float64
+
+	z  []float64
+	az []float64
+	p  []float64
+	ap []float64
+	q  []float64
+}
+
+// Init implements the Method interface.
+func (cr *CR) Init(dim int) {
+	if dim <= 0 {
+		panic("CR: dimension not positive")
+	}
+
+	cr.z = reuse(cr.z, dim)
+	cr.az = reuse(cr.az, dim)
+	cr.p = reuse(cr.p, dim)
+	cr.ap = reuse(cr.ap, dim)
+	cr.q = reuse(cr.q, dim)
+	cr.first = true
+	cr.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (cr *CR) Iterate(ctx *Context) (Operation, error) {
+	switch cr.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = cr.z
+		cr.resume = 2
+		return PSolve, nil
+		// Solve M z_0 = r_0
+	case 2:
+		ctx.Src = cr.z
+		ctx.Dst = cr.az
+		cr.resume = 3
+		return MatVec, nil
+		// Compute Az_{i-1}
+	case 3:
+		cr.rho = ctx.vec.dot(cr.z, cr.az) // ρ_i = z_{i-1} · Az_{i-1}
+		if cr.rho <= 0 {
+			cr.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "CR"}
+		}
+		if cr.first {
+			ctx.vec.copy(cr.p, cr.z)
+			ctx.vec.copy(cr.ap, cr.az)
+		} else {
+			beta := cr.rho / cr.rhoPrev // β = ρ_i / ρ_{i-1}
+			// p_i = z_{i-1} + β p_{i-1}
+			ctx.vec.scale(beta, cr.p)
+			ctx.vec.add(cr.p, cr.z)
+			// Ap_i = Az_{i-1} + β Ap_{i-1}
+			ctx.vec.scale(beta, cr.ap)
+			ctx.vec.add(cr.ap, cr.az)
+		}
+		ctx.Src = cr.ap
+		ctx.Dst = cr.q
+		cr.resume = 4
+		return PSolve, nil
+		// Solve M q = Ap_i
+	case 4:
+		apq := ctx.vec.dot(cr.ap, cr.q)
+		if apq <= 0 {
+			cr.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "CR"}
+		}
+		alpha := cr.rho / apq // α = ρ_i / (Ap_i · q)
+		// r_i = r_{i-1} - α Ap_i
+		// x_i = x_{i-1} + α p_i
+		ctx.ResidualNorm = ctx.vec.cgUpdate(alpha, ctx.X, cr.p, ctx.Residual, cr.ap)
+		ctx.vec.addScaled(cr.z, -alpha, cr.q) // z_i = z_{i-1} - α q
+
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		cr.resume = 5
+		return CheckResidualNorm, nil
+	case 5:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			cr.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		cr.rhoPrev = cr.rho
+		cr.first = false
+		cr.resume = 2
+		return EndIteration, nil
+
+	default:
+		panic("CR: Init not called")
+	}
+}
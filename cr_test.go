@@ -0,0 +1,117 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func crProblems() []*problem.Problem {
+	rnd := rand.New(rand.NewSource(1))
+	return []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(3, rnd),
+		problem.RandomSPD(4, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(10, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(50, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.RandomSPD(200, rnd),
+		problem.RandomSPD(500, rnd),
+		market("nos1", 1e-7),
+		market("nos4", 1e-11),
+		market("nos5", 1e-9),
+		market("bcsstm20", 1e-7),
+		market("bcsstm22", 1e-11),
+	}
+}
+
+func TestCR(t *testing.T) {
+	for _, p := range crProblems() {
+		_, err := p.Solve(&iterative.CR{}, iterative.Settings{Tolerance: 1e-12})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// With the Jacobi preconditioner.
+	for _, name := range []string{"nos4", "bcsstm22"} {
+		p := market(name, 1e-10)
+		jacobi := iterative.DiagonalInverse(sparse.Diagonal(marketCSR(name)))
+		_, err := p.Solve(&iterative.CR{}, iterative.Settings{
+			Tolerance: 1e-12,
+			PSolve:    jacobi.Apply,
+		})
+		if err != nil {
+			t.Errorf("Jacobi: %v", err)
+		}
+	}
+}
+
+func TestCRMonotone(t *testing.T) {
+	for _, p := range crProblems() {
+		l := iterative.NewLoop(p.A, p.B, &iterative.CR{}, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: p.MaxIterations,
+		})
+		prev := math.Inf(1)
+		for {
+			done, err := l.Step()
+			if l.Operation() == iterative.EndIteration {
+				rnorm := l.Result().Stats.ResidualNorm
+				if rnorm > prev {
+					t.Errorf("%s: residual norm increased from %v to %v in iteration %d", p.Name, prev, rnorm, l.Result().Stats.Iterations)
+					break
+				}
+				prev = rnorm
+			}
+			if done {
+				if err != nil {
+					t.Errorf("%s: unexpected error: %v", p.Name, err)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestCRSameAsCG(t *testing.T) {
+	for _, p := range crProblems() {
+		settings := iterative.Settings{
+			Tolerance:     1e-14,
+			MaxIterations: p.MaxIterations,
+		}
+		cg, err := iterative.LinearSolve(p.A, p.B, &iterative.CG{}, settings)
+		if err != nil {
+			t.Errorf("%s: unexpected error from CG: %v", p.Name, err)
+			continue
+		}
+		cr, err := iterative.LinearSolve(p.A, p.B, &iterative.CR{}, settings)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", p.Name, err)
+			continue
+		}
+		if d := floats.Distance(cr.X, cg.X, math.Inf(1)); d > 10*p.Tolerance {
+			t.Errorf("%s: solutions of CR and CG differ by %v", p.Name, d)
+		}
+		// CR minimizes the residual norm over the
+		// same subspace, so up to rounding errors on
+		// ill-conditioned matrices such as nos1 it
+		// does not need more iterations.
+		if cr.Stats.Iterations > cg.Stats.Iterations+cg.Stats.Iterations/100 {
+			t.Errorf("%s: CR needs %d iterations, CG %d", p.Name, cr.Stats.Iterations, cg.Stats.Iterations)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"runtime"
+	"sync"
+
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/blas/gonum"
+)
+
+const (
+	// blockedGemvThreshold is the order of a dense matrix from which
+	// DenseOps uses blockedGemv with the native gonum BLAS.
+	blockedGemvThreshold = 2048
+
+	// l2Size is the assumed size of the L2 cache in bytes.
+	l2Size = 256 << 10
+
+	// minRowBlock is the minimum number of rows in a block of blockedGemv
+	// without transpose.
+	minRowBlock = 16
+
+	// colBlock is the number of columns in a block of blockedGemv with
+	// transpose. The corresponding part of dst then stays in L1 cache.
+	colBlock = 1024
+)
+
+// useBlockedGemv returns whether DenseOps should use blockedGemv for
+// matrices of order n. The blocked version is used only with the native
+// gonum BLAS, an optimized BLAS implementation has its own blocking.
+func useBlockedGemv(n int) bool {
+	if n < blockedGemvThreshold {
+		return false
+	}
+	_, native := blas64.Implementation().(gonum.Implementation)
+	return native
+}
+
+// blockedGemv computes
+//  dst = A * x    if trans == blas.NoTrans,
+//  dst = A^T * x  if trans == blas.Trans,
+// where A is an n×n matrix stored row-major in a with the leading dimension
+// lda. The matrix is split into panels of rows (or columns for the
+// transpose) sized to fit into the L2 cache and the panels are processed by
+// Dgemv using up to the given number of concurrent workers. Each element of
+// dst is computed by the same sequence of floating-point operations as by
+// a single Dgemv call of the native gonum BLAS.
+func blockedGemv(trans blas.Transpose, n int, a []float64, lda int, x, dst []float64, workers int) {
+	bi := blas64.Implementation()
+	var bs int
+	if trans == blas.NoTrans {
+		bs = l2Size / (8 * n)
+		if bs < minRowBlock {
+			bs = minRowBlock
+		}
+	} else {
+		bs = colBlock
+	}
+	nb := (n + bs - 1) / bs
+	if workers > nb {
+		workers = nb
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	panel := func(b int) {
+		i0 := b * bs
+		i1 := i0 + bs
+		if i1 > n {
+			i1 = n
+		}
+		if trans == blas.NoTrans {
+			bi.Dgemv(blas.NoTrans, i1-i0, n, 1, a[i0*lda:], lda, x, 1, 0, dst[i0:i1], 1)
+		} else {
+			bi.Dgemv(blas.Trans, n, i1-i0, 1, a[i0:], lda, x, 1, 0, dst[i0:i1], 1)
+		}
+	}
+	if workers == 1 {
+		for b := 0; b < nb; b++ {
+			panel(b)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for b := w * nb / workers; b < (w+1)*nb/workers; b++ {
+				panel(b)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// denseGemv returns a function that computes the product of the n×n matrix
+// stored in a with a vector, either by a single Dgemv call or by
+// blockedGemv for large matrices.
+func denseGemv(trans blas.Transpose, a []float64, n, lda int) func(dst, x []float64) {
+	if useBlockedGemv(n) {
+		return func(dst, x []float64) {
+			checkLen(dst, x, n)
+			blockedGemv(trans, n, a, lda, x, dst, runtime.GOMAXPROCS(0))
+		}
+	}
+	bi := blas64.Implementation()
+	return func(dst, x []float64) {
+		checkLen(dst, x, n)
+		bi.Dgemv(trans, n, n, 1, a, lda, x, 1, 0, dst, 1)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestBlockedGemv(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	bi := blas64.Implementation()
+	for _, n := range []int{1, 15, 16, 17, 100, 1500, 2100} {
+		for _, lda := range []int{n, n + 5} {
+			a := make([]float64, (n-1)*lda+n)
+			for i := range a {
+				a[i] = rnd.NormFloat64()
+			}
+			x := make([]float64, n)
+			for i := range x {
+				x[i] = rnd.NormFloat64()
+			}
+			want := make([]float64, n)
+			got := make([]float64, n)
+			for _, trans := range []blas.Transpose{blas.NoTrans, blas.Trans} {
+				bi.Dgemv(trans, n, n, 1, a, lda, x, 1, 0, want, 1)
+				for _, workers := range []int{1, 2, 3, 8} {
+					for i := range got {
+						got[i] = math.NaN()
+					}
+					blockedGemv(trans, n, a, lda, x, got, workers)
+					if dist := floats.Distance(got, want, math.Inf(1)); dist > 1e-12 {
+						t.Errorf("n=%v,lda=%v,trans=%v,workers=%v: unexpected result, |want-got|=%v",
+							n, lda, trans, workers, dist)
+					}
+				}
+			}
+		}
+	}
+}
+
+func benchmarkGemv(b *testing.B, n int, blocked bool, workers int) {
+	rnd := rand.New(rand.NewSource(1))
+	a := make([]float64, n*n)
+	for i := range a {
+		a[i] = rnd.NormFloat64()
+	}
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	dst := make([]float64, n)
+	bi := blas64.Implementation()
+	b.SetBytes(int64(8 * n * n))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if blocked {
+			blockedGemv(blas.NoTrans, n, a, n, x, dst, workers)
+		} else {
+			bi.Dgemv(blas.NoTrans, n, n, 1, a, n, x, 1, 0, dst, 1)
+		}
+	}
+}
+
+func BenchmarkBlockedGemv(b *testing.B) {
+	for _, n := range []int{1000, 4000, 10000} {
+		b.Run(fmt.Sprintf("n=%d/Dgemv", n), func(b *testing.B) { benchmarkGemv(b, n, false, 1) })
+		b.Run(fmt.Sprintf("n=%d/serial", n), func(b *testing.B) { benchmarkGemv(b, n, true, 1) })
+		b.Run(fmt.Sprintf("n=%d/parallel", n), func(b *testing.B) { benchmarkGemv(b, n, true, 4) })
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+var (
+	// ErrNotEquilibrated is returned by RuizScale when the scaled matrix
+	// is not equilibrated to the requested tolerance within the limit on
+	// the number of iterations.
+	ErrNotEquilibrated = errors.New("iterative: Ruiz scaling did not converge")
+
+	errZeroRowCol = errors.New("iterative: zero row or column")
+)
+
+// RuizScale computes the diagonal scaling of a by the Ruiz algorithm for
+// equilibration in the max-norm. It returns the scaling factors
+// D_r = diag(rowScale) and D_c = diag(colScale) such that the rows and
+// columns of
+//  D_r a D_c
+// have the max-norm within tol of one. Each iteration divides the rows and
+// columns by the square roots of their current max-norms, and the
+// iteration stops after maxIter iterations at the latest. The iteration
+// converges linearly for any matrix without zero rows and columns, often
+// in a few iterations, and it keeps the symmetry of a symmetric a.
+//
+// The scaled system
+//  (D_r a D_c) y = D_r b
+// with x = D_c y is solved by EquilibratedSolve. Equilibration helps the
+// methods and preconditioners on badly scaled matrices, for example those
+// whose rows come from equations in different physical units.
+//
+// RuizScale returns an error if a has a zero row or column. If the
+// iteration limit is reached, it returns the last scaling factors and
+// ErrNotEquilibrated. maxIter must be positive and tol must be in (0,1).
+func RuizScale(a *sparse.CSR, maxIter int, tol float64) (rowScale, colScale []float64, err error) {
+	if maxIter <= 0 {
+		panic("iterative: non-positive iteration limit")
+	}
+	if tol <= 0 || tol >= 1 {
+		panic("iterative: invalid tolerance")
+	}
+	r, c := a.Dims()
+	rowScale = make([]float64, r)
+	colScale = make([]float64, c)
+	for i := range rowScale {
+		rowScale[i] = 1
+	}
+	for j := range colScale {
+		colScale[j] = 1
+	}
+	rowMax := make([]float64, r)
+	colMax := make([]float64, c)
+	for k := 0; ; k++ {
+		// Compute the max-norms of the rows and
+		// columns of the scaled matrix.
+		for j := range colMax {
+			colMax[j] = 0
+		}
+		for i := 0; i < r; i++ {
+			rowMax[i] = 0
+			ind, data := a.RowView(i)
+			for p, j := range ind {
+				v := math.Abs(rowScale[i] * data[p] * colScale[j])
+				rowMax[i] = math.Max(rowMax[i], v)
+				colMax[j] = math.Max(colMax[j], v)
+			}
+		}
+		converged := true
+		for _, norms := range [][]float64{rowMax, colMax} {
+			for _, v := range norms {
+				if v == 0 {
+					return nil, nil, errZeroRowCol
+				}
+				if math.Abs(1-v) > tol {
+					converged = false
+				}
+			}
+		}
+		if converged {
+			return rowScale, colScale, nil
+		}
+		if k == maxIter {
+			return rowScale, colScale, ErrNotEquilibrated
+		}
+		for i, v := range rowMax {
+			rowScale[i] /= math.Sqrt(v)
+		}
+		for j, v := range colMax {
+			colScale[j] /= math.Sqrt(v)
+		}
+	}
+}
+
+// EquilibratedSolve solves the linear system
+//  A x = b
+// by solving the equilibrated system
+//  (D_r A D_c) y = D_r b
+// with LinearSolve and returning the solution x = D_c y, where
+// D_r = diag(rowScale) and D_c = diag(colScale), for example with the
+// scaling factors computed by RuizScale. b is not modified.
+//
+// settings.X0 and the returned Result.X are the initial guess and the
+// solution of the original system, and settings.InPlace applies to X0 as
+// in LinearSolve. The other settings, including Tolerance, NormA and
+// PSolve, and the returned Stats refer to the equilibrated system. The
+// elements of rowScale and colScale must not be zero.
+func EquilibratedSolve(a MatrixOps, b []float64, rowScale, colScale []float64, method Method, settings Settings) (Result, error) {
+	rows, cols := a.dims(len(b))
+	if len(rowScale) != rows || len(colScale) != cols {
+		panic("iterative: mismatched length of scaling factors")
+	}
+	if settings.X0 != nil && len(settings.X0) != cols {
+		panic("iterative: mismatched length of initial guess")
+	}
+	if settings.InPlace && settings.X0 == nil {
+		panic("iterative: nil initial guess for in-place solve")
+	}
+	bs := make([]float64, rows)
+	for i, v := range b {
+		bs[i] = rowScale[i] * v
+	}
+	x := settings.X0
+	inPlace := settings.InPlace
+	if x != nil {
+		// Solve for y in a new vector and
+		// transform it into x at the end.
+		y := make([]float64, cols)
+		for j, v := range x {
+			y[j] = v / colScale[j]
+		}
+		settings.X0 = y
+		settings.InPlace = true
+	}
+	res, err := LinearSolve(EquilibratedOps(a, rowScale, colScale), bs, method, settings)
+	y := res.X
+	if !inPlace {
+		x = y
+	}
+	for j, v := range y {
+		x[j] = colScale[j] * v
+	}
+	res.X = x
+	return res, err
+}
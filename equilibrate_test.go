@@ -0,0 +1,221 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat"
+)
+
+// badlyScaledPoisson returns the 2D Poisson matrix scaled symmetrically by
+// D = diag(d) with random powers of ten between 1e-4 and 1e4, as if its
+// unknowns and equations were in different units, and d.
+func badlyScaledPoisson(nx, ny int, rnd *rand.Rand) (*sparse.CSR, []float64) {
+	a := testmat.Poisson2D(nx, ny).CSR()
+	n, _ := a.Dims()
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = math.Pow(10, 8*rnd.Float64()-4)
+	}
+	sparse.ScaleRows(a, d)
+	sparse.ScaleCols(a, d)
+	return a, d
+}
+
+func TestRuizScale(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	poisson, _ := badlyScaledPoisson(20, 20, rnd)
+	for _, test := range []struct {
+		name string
+		a    *sparse.CSR
+	}{
+		{"arc130", marketCSR("arc130")},
+		{"gre__115", marketCSR("gre__115")},
+		{"badly scaled Poisson", poisson},
+	} {
+		for _, tol := range []float64{1e-2, 1e-8} {
+			r, c, err := iterative.RuizScale(test.a, 100, tol)
+			if err != nil {
+				t.Errorf("%s,tol=%v: unexpected error: %v", test.name, tol, err)
+				continue
+			}
+			m := sparse.Scale(1, test.a)
+			sparse.ScaleRows(m, r)
+			sparse.ScaleCols(m, c)
+			for _, norms := range [][]float64{sparse.RowNorms(m, math.Inf(1)), sparse.ColNorms(m, math.Inf(1))} {
+				for _, v := range norms {
+					if math.Abs(1-v) > tol {
+						t.Errorf("%s,tol=%v: max-norm %v of the scaled matrix not within tolerance of one", test.name, tol, v)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// The Poisson matrix is scaled symmetrically,
+	// so is the equilibrated matrix.
+	a, _ := badlyScaledPoisson(10, 10, rnd)
+	r, c, err := iterative.RuizScale(a, 100, 1e-8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !floats.EqualApprox(r, c, 1e-14) {
+		t.Errorf("unequal row and column scaling factors of a symmetric matrix")
+	}
+
+	_, _, err = iterative.RuizScale(marketCSR("arc130"), 1, 1e-8)
+	if err != iterative.ErrNotEquilibrated {
+		t.Errorf("unexpected error at the iteration limit: want %v, got %v", iterative.ErrNotEquilibrated, err)
+	}
+	zero := sparse.NewTriplet(3, 3)
+	zero.Append(0, 0, 1)
+	zero.Append(2, 2, 1)
+	if _, _, err := iterative.RuizScale(sparse.NewCSRFromTriplet(zero), 10, 1e-8); err == nil {
+		t.Errorf("no error for a matrix with a zero row")
+	}
+}
+
+func TestEquilibratedSolve(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	poisson, d := badlyScaledPoisson(20, 20, rnd)
+	for _, test := range []struct {
+		name string
+		a    *sparse.CSR
+		// units scales the elements of the
+		// solution like the unknowns of a if
+		// it is not nil.
+		units  []float64
+		method func() iterative.Method
+		// tol is the componentwise error of
+		// the solution required from the
+		// equilibrated solve.
+		tol float64
+	}{
+		// Without equilibration, arc130 is solved only
+		// to an error of 1e-4 in the method tests.
+		{"arc130", marketCSR("arc130"), nil, func() iterative.Method { return &iterative.GMRES{} }, 1e-8},
+		{"badly scaled Poisson", poisson, d, func() iterative.Method { return &iterative.CG{} }, 1e-8},
+	} {
+		r, c, err := iterative.RuizScale(test.a, 100, 1e-8)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		n, _ := test.a.Dims()
+		ops := iterative.MatrixOps{MatVec: test.a.MulVec, MatTransVec: test.a.MulTransVec}
+		want := make([]float64, n)
+		for i := range want {
+			want[i] = 1 + rnd.Float64()
+			if test.units != nil {
+				want[i] /= test.units[i]
+			}
+		}
+		b := make([]float64, n)
+		test.a.MulVec(b, want)
+		settings := iterative.Settings{Tolerance: 1e-10, MaxIterations: 10 * n}
+
+		plain, _ := iterative.LinearSolve(ops, b, test.method(), settings)
+		res, err := iterative.EquilibratedSolve(ops, b, r, c, test.method(), settings)
+		if err != nil {
+			t.Errorf("%s,%T: unexpected error: %v", test.name, test.method(), err)
+			continue
+		}
+		errPlain := solutionError(plain.X, want)
+		errEq := solutionError(res.X, want)
+		if errEq > test.tol {
+			t.Errorf("%s,%T: unexpected solution error %v, want below %v", test.name, test.method(), errEq, test.tol)
+		}
+		if errEq >= errPlain && res.Stats.Iterations >= plain.Stats.Iterations {
+			t.Errorf("%s,%T: equilibration did not help: error %v in %d iterations, without it %v in %d",
+				test.name, test.method(), errEq, res.Stats.Iterations, errPlain, plain.Stats.Iterations)
+		}
+
+		// The initial guess is scaled and the solution
+		// is unscaled into it in place.
+		x0 := make([]float64, n)
+		floats.AddScaled(x0, 0.5, want)
+		settings.X0 = make([]float64, n)
+		copy(settings.X0, x0)
+		res, err = iterative.EquilibratedSolve(ops, b, r, c, test.method(), settings)
+		if err != nil {
+			t.Errorf("%s,%T: unexpected error with initial guess: %v", test.name, test.method(), err)
+			continue
+		}
+		if !floats.Equal(settings.X0, x0) {
+			t.Errorf("%s,%T: initial guess modified", test.name, test.method())
+		}
+		settings.InPlace = true
+		resInPlace, err := iterative.EquilibratedSolve(ops, b, r, c, test.method(), settings)
+		if err != nil {
+			t.Errorf("%s,%T: unexpected error in place: %v", test.name, test.method(), err)
+			continue
+		}
+		if &resInPlace.X[0] != &settings.X0[0] {
+			t.Errorf("%s,%T: solution not stored in X0", test.name, test.method())
+		}
+		if !floats.Equal(resInPlace.X, res.X) {
+			t.Errorf("%s,%T: solution in place differs", test.name, test.method())
+		}
+	}
+
+	// Without an initial guess, the equilibrated
+	// system is solved from zero like by
+	// LinearSolve, with the same number of MatVecs.
+	r, c, err := iterative.RuizScale(poisson, 100, 1e-8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n, _ := poisson.Dims()
+	b := make([]float64, n)
+	poisson.MulVec(b, d)
+	var matVecs int
+	ops := iterative.MatrixOps{MatVec: func(dst, x []float64) {
+		matVecs++
+		poisson.MulVec(dst, x)
+	}}
+	bs := make([]float64, n)
+	floats.MulTo(bs, r, b)
+	settings := iterative.Settings{Tolerance: 1e-10}
+	_, err = iterative.LinearSolve(iterative.EquilibratedOps(ops, r, c), bs, &iterative.CG{}, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := matVecs
+	matVecs = 0
+	if _, err := iterative.EquilibratedSolve(ops, b, r, c, &iterative.CG{}, settings); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if matVecs != want {
+		t.Errorf("unexpected number of MatVecs without initial guess: want %d, got %d", want, matVecs)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("no panic for in-place solve without initial guess")
+			}
+		}()
+		iterative.EquilibratedSolve(ops, b, r, c, &iterative.CG{}, iterative.Settings{InPlace: true})
+	}()
+}
+
+// solutionError returns the largest relative error of the elements of x.
+func solutionError(x, want []float64) float64 {
+	if x == nil {
+		return math.Inf(1)
+	}
+	var e float64
+	for i, v := range want {
+		e = math.Max(e, math.Abs(x[i]-v)/math.Abs(v))
+	}
+	return e
+}
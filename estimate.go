@@ -0,0 +1,492 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// EstimateSingularValues estimates the largest and the smallest singular
+// values of the m×n matrix A represented by a, so that, for example,
+// smax/smin estimates the 2-norm condition number of A and smax can be
+// used as Settings.NormA. The MatVec of a maps vectors of length n to
+// vectors of length m, MatTransVec the other way, and both are needed.
+//
+// EstimateSingularValues performs iters steps of the Golub-Kahan
+// bidiagonalization
+//  A V_k = U_{k+1} B_k
+// started from a random vector generated by rnd, and returns the extreme
+// singular values of the (k+1)×k lower bidiagonal matrix B_k. The columns of
+// U_{k+1} and V_k are fully reorthogonalized, so the step costs O((m+n)k)
+// operations and the vectors take O((m+n)k) memory. If A is wider than
+// tall, A^T is bidiagonalized instead. iters is limited to min(m,n), the
+// iteration stops early if an invariant subspace is found.
+//
+// In exact arithmetic, smax is a lower bound of the largest singular value
+// and smin is an upper bound of the smallest one of the min(m,n) singular
+// values, so smax/smin is a lower bound of the condition number. The
+// estimate smax converges quickly, typically to a few digits in 10-20
+// steps. The estimate smin converges slowly unless the smallest singular
+// value is well separated from the others, and it can exceed the true value
+// by orders of magnitude for ill-conditioned matrices unless iters is close
+// to min(m,n). It should be treated as an indication, not as a bound to
+// rely on.
+//
+// EstimateSingularValues panics if a lacks MatTransVec, if m or n is not
+// positive or if iters is not positive. It returns an error if the SVD of
+// B_k fails.
+func EstimateSingularValues(a MatrixOps, m, n, iters int, rnd *rand.Rand) (smax, smin float64, err error) {
+	checkOps(a)
+	if a.MatTransVec == nil {
+		panic("iterative: singular value estimate needs MatTransVec")
+	}
+	if m <= 0 || n <= 0 {
+		panic("iterative: dimension not positive")
+	}
+	if iters <= 0 {
+		panic("iterative: number of iterations not positive")
+	}
+	matVec, matTransVec := a.MatVec, a.MatTransVec
+	if m < n {
+		m, n = n, m
+		matVec, matTransVec = matTransVec, matVec
+	}
+	if iters > n {
+		iters = n
+	}
+
+	// The columns of U and V are stored
+	// contiguously, u_j is u[j*m:(j+1)*m].
+	u := make([]float64, (iters+1)*m)
+	v := make([]float64, iters*n)
+	alpha := make([]float64, 0, iters)
+	beta := make([]float64, 0, iters)
+
+	// β_1 u_1 = random vector.
+	u1 := u[:m]
+	for i := range u1 {
+		u1[i] = rnd.NormFloat64()
+	}
+	floats.Scale(1/floats.Norm(u1, 2), u1)
+	for k := 0; k < iters; k++ {
+		// α_k v_k = A^T u_k - β_k v_{k-1}
+		uk := u[k*m : (k+1)*m]
+		vk := v[k*n : (k+1)*n]
+		matTransVec(vk, uk)
+		reorthogonalize(vk, v[:k*n], n)
+		ak := floats.Norm(vk, 2)
+		if ak <= eps*normEstimate(alpha, beta) {
+			break
+		}
+		floats.Scale(1/ak, vk)
+		alpha = append(alpha, ak)
+
+		// β_{k+1} u_{k+1} = A v_k - α_k u_k
+		uk1 := u[(k+1)*m : (k+2)*m]
+		matVec(uk1, vk)
+		reorthogonalize(uk1, u[:(k+1)*m], m)
+		bk := floats.Norm(uk1, 2)
+		if bk <= eps*normEstimate(alpha, beta) {
+			break
+		}
+		floats.Scale(1/bk, uk1)
+		beta = append(beta, bk)
+	}
+
+	k := len(alpha)
+	if k == 0 {
+		// A^T u_1 = 0, A is most
+		// likely the zero matrix.
+		return 0, 0, nil
+	}
+	// If the iteration stopped at β_{k+1} = 0,
+	// A V_k = U_k B_k with the square k×k B_k.
+	b := mat.NewDense(len(beta)+1, k, nil)
+	for j := 0; j < k; j++ {
+		b.Set(j, j, alpha[j])
+		if j < len(beta) {
+			b.Set(j+1, j, beta[j])
+		}
+	}
+	var svd mat.SVD
+	if !svd.Factorize(b, mat.SVDNone) {
+		return 0, 0, errors.New("iterative: SVD of the bidiagonal matrix failed")
+	}
+	s := svd.Values(nil)
+	return s[0], s[len(s)-1], nil
+}
+
+// reorthogonalize orthogonalizes x against the len(q)/n orthonormal
+// vectors of length n stored contiguously in q by the modified
+// Gram-Schmidt process done twice.
+func reorthogonalize(x, q []float64, n int) {
+	for pass := 0; pass < 2; pass++ {
+		for j := 0; j < len(q); j += n {
+			qj := q[j : j+n]
+			floats.AddScaled(x, -floats.Dot(qj, x), qj)
+		}
+	}
+}
+
+// normEstimate returns the largest of the elements of B_k computed so far,
+// a lower bound of |A| used as the scale of the breakdown test.
+func normEstimate(alpha, beta []float64) float64 {
+	var s float64
+	for _, a := range alpha {
+		if a > s {
+			s = a
+		}
+	}
+	for _, b := range beta {
+		if b > s {
+			s = b
+		}
+	}
+	return s
+}
+
+// twoNormTol is the relative change of the estimate of EstimateTwoNorm
+// below which the iteration stops.
+const twoNormTol = 1e-3
+
+// EstimateTwoNorm estimates the 2-norm of the dim×dim matrix A represented
+// by a with at most iters steps of the power method started from a random
+// vector generated by rnd. If a has MatTransVec, the power method is
+// applied to A^T A and each step costs a product with A and with A^T. If
+// a lacks MatTransVec, A is assumed to be symmetric and the power method is
+// applied to A itself with one product per step. If a.Rows and a.Cols
+// describe a rectangular matrix, dim must be a.Cols and a must have
+// MatTransVec.
+//
+// The estimate is |A v| for the last unit vector v, so it is a lower bound
+// of |A|_2. The iteration stops early when two successive estimates differ
+// by less than 0.1 percent. The estimate is then typically within 1 percent
+// of |A|_2, less accurate if the largest singular values are clustered.
+// Such accuracy suffices for Settings.NormA.
+//
+// EstimateTwoNorm panics if dim or iters is not positive.
+func EstimateTwoNorm(a MatrixOps, dim, iters int, rnd *rand.Rand) float64 {
+	checkOps(a)
+	if dim <= 0 {
+		panic("iterative: dimension not positive")
+	}
+	if iters <= 0 {
+		panic("iterative: number of iterations not positive")
+	}
+	rows := dim
+	if a.Rows != 0 || a.Cols != 0 {
+		if a.Cols != dim {
+			panic("iterative: mismatched dimension")
+		}
+		if a.Rows != a.Cols && a.MatTransVec == nil {
+			panic("iterative: nil transposed matrix-vector multiplication for rectangular matrix")
+		}
+		rows = a.Rows
+	}
+	v := make([]float64, dim)
+	w := make([]float64, rows)
+	for i := range v {
+		v[i] = rnd.NormFloat64()
+	}
+	floats.Scale(1/floats.Norm(v, 2), v)
+	var est float64
+	for k := 0; k < iters; k++ {
+		a.MatVec(w, v)
+		prev := est
+		est = floats.Norm(w, 2)
+		if est == 0 || math.Abs(est-prev) <= twoNormTol*est {
+			break
+		}
+		if a.MatTransVec != nil {
+			a.MatTransVec(v, w)
+		} else {
+			copy(v, w)
+		}
+		nrm := floats.Norm(v, 2)
+		if nrm == 0 {
+			break
+		}
+		floats.Scale(1/nrm, v)
+	}
+	return est
+}
+
+// oneNormMaxIter is the limit on the number of iterations of the 1-norm
+// estimator.
+const oneNormMaxIter = 5
+
+// EstimateOneNorm estimates the 1-norm of the dim×dim matrix A represented
+// by a with the block algorithm of Higham and Tisseur, which for t = 1 is
+// the algorithm of Hager and Higham used by LAPACK's xLACN2. The estimate
+// is a lower bound of |A|_1, it is almost always within a factor of 3 of
+// it and it is often exact. Larger t, the number of columns of the blocks,
+// improves the estimate at the cost of more products, typically about 4t
+// products with A and 4t with A^T. If t is at least dim, the norm is
+// computed exactly with dim products. The estimate can be used as
+// Settings.NormA.
+//
+// The random starting vectors of the blocks are generated by a fixed
+// source, so the estimate is reproducible. The estimate is also bounded
+// from below by |A x|_1/|x|_1 for the vector x with
+//  x_i = (-1)^i (1 + i/(dim-1))
+// which is exact for some matrices the iteration misses.
+//
+// EstimateOneNorm panics if a lacks MatTransVec or if dim or t is not
+// positive. It returns an error if the products produce non-finite values.
+//
+// References:
+//  - Higham, N.J., Tisseur, F.: A block algorithm for matrix 1-norm
+//    estimation, with an application to 1-norm pseudospectra. SIAM J.
+//    Matrix Anal. Appl. 21(4), 1185-1201 (2000)
+func EstimateOneNorm(a MatrixOps, dim, t int) (float64, error) {
+	checkOps(a)
+	if a.MatTransVec == nil {
+		panic("iterative: 1-norm estimate needs MatTransVec")
+	}
+	noErr := func(f func(dst, x []float64)) func(dst, x []float64) error {
+		return func(dst, x []float64) error {
+			f(dst, x)
+			return nil
+		}
+	}
+	return oneNorm(noErr(a.MatVec), noErr(a.MatTransVec), dim, t)
+}
+
+// EstimateInverseOneNorm estimates the 1-norm of the inverse of the dim×dim
+// matrix M using the solves of p as in EstimateOneNorm. For an exact solver,
+// for example an LUPreconditioner, the product of the estimate and |M|_1
+// estimates the 1-norm condition number of M. It returns the error of p.
+func EstimateInverseOneNorm(p Preconditioner, dim, t int) (float64, error) {
+	return oneNorm(p.Apply, p.ApplyTrans, dim, t)
+}
+
+// oneNorm implements EstimateOneNorm for the products in matVec and
+// matTransVec that can fail.
+func oneNorm(matVec, matTransVec func(dst, x []float64) error, dim, t int) (float64, error) {
+	if dim <= 0 {
+		panic("iterative: dimension not positive")
+	}
+	if t <= 0 {
+		panic("iterative: block size not positive")
+	}
+	n := dim
+	if t >= n {
+		return exactOneNorm(matVec, n)
+	}
+	rnd := rand.New(rand.NewSource(1))
+
+	// The blocks are stored column by column,
+	// the j-th column of X is x[j*n:(j+1)*n].
+	x := make([]float64, n*t)
+	y := make([]float64, n*t)
+	s := make([]float64, n*t)
+	sOld := make([]float64, n*t)
+	z := make([]float64, n)
+	h := make([]float64, n)
+	ind := make([]int, n)
+	hist := make(map[int]bool)
+
+	// X = [e, random ±1 columns]/n without
+	// parallel columns.
+	for i := 0; i < n; i++ {
+		x[i] = 1
+	}
+	for j := 1; j < t; j++ {
+		xj := x[j*n : (j+1)*n]
+		for {
+			randomSigns(xj, rnd)
+			if !parallelColumn(xj, x[:j*n], n) {
+				break
+			}
+		}
+	}
+	floats.Scale(1/float64(n), x)
+
+	var est, estOld float64
+	best := -1
+	for k := 1; ; k++ {
+		for j := 0; j < t; j++ {
+			if err := matVec(y[j*n:(j+1)*n], x[j*n:(j+1)*n]); err != nil {
+				return 0, err
+			}
+		}
+		est = 0
+		bestCol := 0
+		for j := 0; j < t; j++ {
+			if nrm := floats.Norm(y[j*n:(j+1)*n], 1); nrm > est {
+				est = nrm
+				bestCol = j
+			}
+		}
+		if math.IsNaN(est) || math.IsInf(est, 0) {
+			return 0, errors.New("iterative: non-finite value in the 1-norm estimate")
+		}
+		if k >= 2 && est <= estOld {
+			est = estOld
+			break
+		}
+		if k >= 2 {
+			// X holds unit vectors, the best column
+			// is the one of the index ind[bestCol].
+			best = ind[bestCol]
+		}
+		estOld = est
+		if k > oneNormMaxIter {
+			break
+		}
+
+		s, sOld = sOld, s
+		allParallel := true
+		for j := 0; j < t; j++ {
+			sj := s[j*n : (j+1)*n]
+			for i, v := range y[j*n : (j+1)*n] {
+				sj[i] = 1
+				if v < 0 {
+					sj[i] = -1
+				}
+			}
+			if k == 1 || !parallelColumn(sj, sOld, n) {
+				allParallel = false
+			}
+		}
+		if allParallel {
+			// Further iterations would
+			// repeat the same products.
+			break
+		}
+		if t > 1 {
+			// Replace the columns of S parallel to
+			// previous columns of S or S_old.
+			for j := 0; j < t; j++ {
+				sj := s[j*n : (j+1)*n]
+				for parallelColumn(sj, s[:j*n], n) || (k > 1 && parallelColumn(sj, sOld, n)) {
+					randomSigns(sj, rnd)
+				}
+			}
+		}
+
+		// h_i = max_j |(A^T S)_{ij}|
+		for i := range h {
+			h[i] = 0
+		}
+		for j := 0; j < t; j++ {
+			if err := matTransVec(z, s[j*n:(j+1)*n]); err != nil {
+				return 0, err
+			}
+			for i, v := range z {
+				h[i] = math.Max(h[i], math.Abs(v))
+			}
+		}
+		hmax := floats.Max(h)
+		if k >= 2 && best >= 0 && hmax == h[best] {
+			break
+		}
+		for i := range ind {
+			ind[i] = i
+		}
+		sort.SliceStable(ind, func(i, j int) bool { return h[ind[i]] > h[ind[j]] })
+		if t > 1 {
+			// Stop if the best t indices have all
+			// been used, otherwise use the first t
+			// indices not used before.
+			used := true
+			for _, i := range ind[:t] {
+				used = used && hist[i]
+			}
+			if used {
+				break
+			}
+			m := 0
+			for _, i := range ind {
+				if !hist[i] {
+					ind[m] = i
+					m++
+					if m == t {
+						break
+					}
+				}
+			}
+			for ; m < t; m++ {
+				ind[m] = ind[0]
+			}
+		}
+		for j := 0; j < t; j++ {
+			xj := x[j*n : (j+1)*n]
+			for i := range xj {
+				xj[i] = 0
+			}
+			xj[ind[j]] = 1
+			hist[ind[j]] = true
+		}
+	}
+
+	// The alternative estimate with the vector
+	// x_i = (-1)^i (1 + i/(n-1)).
+	alt := x[:n]
+	for i := range alt {
+		alt[i] = 1
+		if n > 1 {
+			alt[i] += float64(i) / float64(n-1)
+		}
+		if i%2 == 1 {
+			alt[i] *= -1
+		}
+	}
+	if err := matVec(y[:n], alt); err != nil {
+		return 0, err
+	}
+	altEst := floats.Norm(y[:n], 1) / floats.Norm(alt, 1)
+	if math.IsNaN(altEst) || math.IsInf(altEst, 0) {
+		return 0, errors.New("iterative: non-finite value in the 1-norm estimate")
+	}
+	return math.Max(est, altEst), nil
+}
+
+// exactOneNorm returns the 1-norm of the n×n matrix computed column by
+// column with n products.
+func exactOneNorm(matVec func(dst, x []float64) error, n int) (float64, error) {
+	e := make([]float64, n)
+	y := make([]float64, n)
+	var norm float64
+	for j := range e {
+		e[j] = 1
+		if err := matVec(y, e); err != nil {
+			return 0, err
+		}
+		e[j] = 0
+		norm = math.Max(norm, floats.Norm(y, 1))
+	}
+	if math.IsNaN(norm) || math.IsInf(norm, 0) {
+		return 0, errors.New("iterative: non-finite value in the 1-norm estimate")
+	}
+	return norm, nil
+}
+
+// randomSigns fills x with random ±1.
+func randomSigns(x []float64, rnd *rand.Rand) {
+	for i := range x {
+		x[i] = 1
+		if rnd.Intn(2) == 0 {
+			x[i] = -1
+		}
+	}
+}
+
+// parallelColumn returns whether the vector x of ±1 is parallel to one of
+// the vectors of length n stored contiguously in q.
+func parallelColumn(x, q []float64, n int) bool {
+	for j := 0; j < len(q); j += n {
+		if math.Abs(floats.Dot(x, q[j:j+n])) == float64(len(x)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/vladimir-ch/iterative"
+)
+
+// randomOrthogonal returns a random n×n orthogonal matrix.
+func randomOrthogonal(n int, rnd *rand.Rand) *mat.Dense {
+	a := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			a.Set(i, j, rnd.NormFloat64())
+		}
+	}
+	var qr mat.QR
+	qr.Factorize(a)
+	var q mat.Dense
+	qr.QTo(&q)
+	return &q
+}
+
+// withSingularValues returns a random m×n matrix with the given singular
+// values.
+func withSingularValues(m, n int, s []float64, rnd *rand.Rand) *mat.Dense {
+	d := mat.NewDense(m, n, nil)
+	for i, v := range s {
+		d.Set(i, i, v)
+	}
+	var a mat.Dense
+	a.Product(randomOrthogonal(m, rnd), d, randomOrthogonal(n, rnd).T())
+	return &a
+}
+
+// rectOps returns MatrixOps for the general m×n matrix a.
+func rectOps(a *mat.Dense) iterative.MatrixOps {
+	return iterative.MatrixOps{
+		MatVec: func(dst, x []float64) {
+			mat.NewVecDense(len(dst), dst).MulVec(a, mat.NewVecDense(len(x), x))
+		},
+		MatTransVec: func(dst, x []float64) {
+			mat.NewVecDense(len(dst), dst).MulVec(a.T(), mat.NewVecDense(len(x), x))
+		},
+	}
+}
+
+func TestEstimateSingularValues(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		m, n  int
+		cond  float64
+		iters int
+	}{
+		{1, 1, 1, 1},
+		{5, 5, 10, 5},
+		{30, 30, 1e3, 30},
+		{40, 25, 1e2, 25},
+		{25, 40, 1e2, 25},
+		{100, 100, 1e4, 100},
+		// Fewer iterations than min(m,n).
+		{100, 100, 1e4, 20},
+		{120, 80, 1e2, 20},
+	} {
+		name := fmt.Sprintf("m=%d,n=%d,cond=%v,iters=%d", test.m, test.n, test.cond, test.iters)
+		k := min(test.m, test.n)
+		// Geometrically distributed singular
+		// values from 2 down to 2/cond.
+		s := make([]float64, k)
+		for i := range s {
+			s[i] = 2
+			if k > 1 {
+				s[i] *= math.Pow(test.cond, -float64(i)/float64(k-1))
+			}
+		}
+		a := withSingularValues(test.m, test.n, s, rnd)
+
+		smax, smin, err := iterative.EstimateSingularValues(rectOps(a), test.m, test.n, test.iters, rnd)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", name, err)
+			continue
+		}
+		wantMax, wantMin := s[0], s[k-1]
+		if smax > wantMax*(1+1e-12) {
+			t.Errorf("%v: smax %v exceeds the largest singular value %v", name, smax, wantMax)
+		}
+		if math.Abs(smax-wantMax) > 1e-8*wantMax {
+			t.Errorf("%v: smax %v not close to %v", name, smax, wantMax)
+		}
+		if smin < wantMin*(1-1e-8) {
+			t.Errorf("%v: smin %v below the smallest singular value %v", name, smin, wantMin)
+		}
+		if test.iters == k && math.Abs(smin-wantMin) > 1e-6*wantMin {
+			t.Errorf("%v: smin %v not close to %v", name, smin, wantMin)
+		}
+	}
+}
+
+func TestEstimateSingularValuesRankDeficient(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	// The smallest singular value of a 50×50
+	// matrix of rank 3 is zero.
+	a := withSingularValues(50, 50, []float64{3, 2, 1}, rnd)
+	smax, smin, err := iterative.EstimateSingularValues(rectOps(a), 50, 50, 50, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(smax-3) > 1e-12 || smin > 1e-12 {
+		t.Errorf("unexpected estimates %v and %v, want 3 and 0", smax, smin)
+	}
+
+	smax, smin, err = iterative.EstimateSingularValues(iterative.ZeroOps(10), 10, 10, 5, rnd)
+	if err != nil || smax != 0 || smin != 0 {
+		t.Errorf("unexpected estimates for the zero matrix: %v, %v, %v", smax, smin, err)
+	}
+}
+
+func TestEstimateOneNorm(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func(n int) *mat.Dense {
+		a := mat.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a.Set(i, j, rnd.NormFloat64())
+			}
+		}
+		return a
+	}
+	structured := func(n int, f func(i, j int) float64) *mat.Dense {
+		a := mat.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a.Set(i, j, f(i, j))
+			}
+		}
+		return a
+	}
+	for _, test := range []struct {
+		name string
+		a    *mat.Dense
+	}{
+		{"random 1", random(1)},
+		{"random 2", random(2)},
+		{"random 10", random(10)},
+		{"random 50", random(50)},
+		{"random 200", random(200)},
+		{"tridiagonal", structured(100, func(i, j int) float64 {
+			switch i - j {
+			case 0:
+				return 2
+			case -1, 1:
+				return -1
+			}
+			return 0
+		})},
+		{"Hilbert", structured(30, func(i, j int) float64 { return 1 / float64(i+j+1) })},
+		{"upper triangular", structured(60, func(i, j int) float64 {
+			if i > j {
+				return 0
+			}
+			return float64(j - i + 1)
+		})},
+		{"single large column", structured(40, func(i, j int) float64 {
+			if j == 27 {
+				return 100
+			}
+			return math.Sin(float64(i*j + 1))
+		})},
+	} {
+		n, _ := test.a.Dims()
+		want := mat.Norm(test.a, 1)
+		for _, blk := range []int{1, 2, 4} {
+			got, err := iterative.EstimateOneNorm(iterative.DenseMatrixOps(test.a), n, blk)
+			if err != nil {
+				t.Errorf("%v, t=%d: unexpected error: %v", test.name, blk, err)
+				continue
+			}
+			if got > want*(1+1e-12) {
+				t.Errorf("%v, t=%d: estimate %v exceeds the norm %v", test.name, blk, got, want)
+			}
+			if got < want/3 {
+				t.Errorf("%v, t=%d: estimate %v far below the norm %v", test.name, blk, got, want)
+			}
+			if blk >= n && math.Abs(got-want) > 1e-12*want {
+				t.Errorf("%v, t=%d: estimate %v not exact, want %v", test.name, blk, got, want)
+			}
+		}
+	}
+}
+
+func TestEstimateInverseOneNorm(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 5, 50, 150} {
+		a := mat.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a.Set(i, j, rnd.NormFloat64())
+			}
+		}
+		var inv mat.Dense
+		if err := inv.Inverse(a); err != nil {
+			t.Fatalf("n=%d: %v", n, err)
+		}
+		want := mat.Norm(&inv, 1)
+
+		var lu mat.LU
+		lu.Factorize(a)
+		got, err := iterative.EstimateInverseOneNorm(iterative.NewLUPreconditioner(&lu), n, 2)
+		if err != nil {
+			t.Errorf("n=%d: unexpected error: %v", n, err)
+			continue
+		}
+		if got > want*(1+1e-8) || got < want/3 {
+			t.Errorf("n=%d: estimate %v of the inverse norm, want about %v", n, got, want)
+		}
+	}
+
+	errSolve := errors.New("solve failed")
+	_, err := iterative.EstimateInverseOneNorm(failingSolver{errSolve}, 10, 1)
+	if err != errSolve {
+		t.Errorf("unexpected error: want %v, got %v", errSolve, err)
+	}
+}
+
+// failingSolver is a Preconditioner whose solves fail.
+type failingSolver struct{ err error }
+
+func (p failingSolver) Apply(dst, rhs []float64) error      { return p.err }
+func (p failingSolver) ApplyTrans(dst, rhs []float64) error { return p.err }
+
+func TestEstimateTwoNorm(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name string
+		n    int
+		s    []float64
+	}{
+		{"n=1", 1, []float64{3}},
+		{"geometric", 50, geometric(50, 5, 1e3)},
+		{"clustered", 80, append([]float64{10, 9.5, 9}, geometric(77, 8, 1e2)...)},
+	} {
+		a := withSingularValues(test.n, test.n, test.s, rnd)
+		want := test.s[0]
+		got := iterative.EstimateTwoNorm(rectOps(a), test.n, 100, rnd)
+		if got > want*(1+1e-12) {
+			t.Errorf("%v: estimate %v exceeds the norm %v", test.name, got, want)
+		}
+		if got < 0.99*want {
+			t.Errorf("%v: estimate %v not within 1%% of the norm %v", test.name, got, want)
+		}
+	}
+
+	// Symmetric indefinite matrix without
+	// MatTransVec.
+	const n = 60
+	q := randomOrthogonal(n, rnd)
+	d := mat.NewDiagDense(n, geometric(n, 4, 1e2))
+	d.SetDiag(0, -4)
+	var tmp mat.Dense
+	tmp.Product(q, d, q.T())
+	sym := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			sym.SetSym(i, j, tmp.At(i, j))
+		}
+	}
+	ops := iterative.SymDenseMatrixOps(sym)
+	ops.MatTransVec = nil
+	got := iterative.EstimateTwoNorm(ops, n, 100, rnd)
+	if math.Abs(got-4) > 0.04 {
+		t.Errorf("symmetric: estimate %v, want about 4", got)
+	}
+}
+
+// geometric returns n values decreasing geometrically from max to max/cond.
+func geometric(n int, max, cond float64) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = max
+		if n > 1 {
+			s[i] *= math.Pow(cond, -float64(i)/float64(n-1))
+		}
+	}
+	return s
+}
@@ -0,0 +1,217 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// FGMRES implements the flexible Generalized Minimum Residual method with
+// the modified Gram-Schmidt orthogonalization. It uses restarts to control
+// storage requirements.
+//
+// FGMRES is preconditioned from the right and, unlike GMRES, it stores the
+// preconditioned vectors
+//  z_j = M_j^{-1} v_j
+// in addition to the Arnoldi vectors v_j and updates the solution with
+// them. The preconditioner M_j may therefore change from iteration to
+// iteration, so Settings.PSolve can be any operation that approximates
+// the solution of A z = v, for example an inner iterative solve with a
+// loose tolerance. FGMRES still minimizes the residual norm over the
+// subspace spanned by the z_j, and because of the right preconditioning
+// the residual norm that it reports is the norm of the unpreconditioned
+// residual b - A x.
+//
+// FGMRES needs twice the storage of GMRES with the same Restart.
+//
+// References:
+//  - Saad, Y.: A flexible inner-outer preconditioned GMRES algorithm. SIAM
+//    J. Sci. Comput. 14(2), 461-469 (1993)
+//
+// FGMRES needs MatVec, PSolve and ComputeResidual matrix operations.
+type FGMRES struct {
+	// Restart is the restart parameter.
+	// It must be 0 <= Restart <= dim.
+	// If it is 0, dim is used.
+	Restart int
+	// MaxRestarts is the maximum number of
+	// restarts, so at most MaxRestarts+1
+	// cycles of Restart iterations are done.
+	// If the solve has not converged by the
+	// end of the last cycle, Iterate returns
+	// ErrRestartLimit. If MaxRestarts is 0,
+	// the number of restarts is limited only
+	// by Settings.MaxIterations. It must not
+	// be negative.
+	MaxRestarts int
+
+	resume  int
+	restart int
+	cycles  int // Number of completed restart cycles.
+
+	s []float64
+	y []float64
+
+	j    int       // Counter for inner iterations.
+	v    []float64 // dim×(restart+1) matrix V.
+	z    []float64 // dim×restart matrix Z.
+	ldv  int
+	h    []float64 // (restart+1)×restart matrix H.
+	ldh  int
+	givs []givens // Givens rotations.
+}
+
+// Init implements the Method interface.
+func (g *FGMRES) Init(dim int) {
+	if dim <= 0 {
+		panic("FGMRES: dimension not positive")
+	}
+	if g.Restart < 0 || dim < g.Restart {
+		panic("FGMRES: invalid value of Restart")
+	}
+	if g.MaxRestarts < 0 {
+		panic("FGMRES: negative MaxRestarts")
+	}
+
+	g.restart = g.Restart
+	if g.restart == 0 {
+		g.restart = dim
+	}
+	k := g.restart
+
+	g.s = reuse(g.s, k+1)
+	g.y = reuse(g.y, k)
+
+	g.ldv = dim
+	g.v = reuse(g.v, g.ldv*(k+1))
+	g.z = reuse(g.z, g.ldv*k)
+	g.ldh = k + 1
+	g.h = reuse(g.h, g.ldh*k)
+
+	if cap(g.givs) < k {
+		g.givs = make([]givens, k)
+	} else {
+		g.givs = g.givs[:k]
+	}
+
+	g.cycles = 0
+	g.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (g *FGMRES) Iterate(ctx *Context) (Operation, error) {
+	n := len(ctx.X)
+
+	switch g.resume {
+	case 1:
+		if g.cycles > 0 {
+			ctx.Restarts++
+		}
+		// Construct the first column of V from the
+		// unpreconditioned residual.
+		v0 := g.v[:n]
+		norm := ctx.vec.norm(ctx.Residual)
+		ctx.vec.copy(v0, ctx.Residual)
+		ctx.vec.scale(1/norm, v0)
+		// Initialize s to the elementary vector e_1 scaled by norm.
+		for i := range g.s {
+			g.s[i] = 0
+		}
+		g.s[0] = norm
+
+		// for j := 0; j < restart; j++ {
+		g.j = 0
+		fallthrough
+	case 2:
+		ctx.Src = g.v[g.j*g.ldv : g.j*g.ldv+n] // j-th column of V
+		ctx.Dst = g.z[g.j*g.ldv : g.j*g.ldv+n] // j-th column of Z
+		g.resume = 3
+		return PSolve, nil
+		// Solve M_j Z[:,j] = V[:,j].
+	case 3:
+		ctx.Src = g.z[g.j*g.ldv : g.j*g.ldv+n]
+		ctx.Dst = g.v[(g.j+1)*g.ldv : (g.j+1)*g.ldv+n] // (j+1)-th column of V
+		g.resume = 4
+		return MatVec, nil
+		// Compute w = A Z[:,j].
+	case 4:
+		j := g.j
+		ldv := g.ldv
+		w := g.v[(j+1)*ldv : (j+1)*ldv+n]
+		Hj := g.h[j*g.ldh : j*g.ldh+g.restart+1] // j-th column of H.
+
+		// Construct j-th column of the upper Hessenberg matrix using
+		// the Gram-Schmidt process on V and w so that it is orthonormal
+		// to the previous j-1 columns.
+		wnorm := ctx.vec.mgs(Hj[:j+1], g.v, ldv, w)
+		Hj[j+1] = wnorm           // H[j+1,j] = |w|
+		ctx.vec.scale(1/wnorm, w) // Normalize V[:,j+1].
+
+		// Reduce the j-th column of H to upper triangular form
+		// and update s.
+		givensUpdate(g.givs, Hj, g.s, j)
+		// The residual norm is the norm of b - A x because
+		// the preconditioning is from the right.
+		ctx.ResidualNorm = math.Abs(g.s[j+1])
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		g.resume = 5
+		return CheckResidualNorm, nil
+	case 5:
+		if ctx.Converged {
+			// Compute final approximate solution x and finish.
+			g.update(ctx.vec, ctx.X)
+			ctx.ResidualCurrent = false
+			g.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		g.j++
+		if g.j < g.restart {
+			// Continue the inner for loop. Residual is the
+			// residual at the restart.
+			ctx.ResidualCurrent = false
+			g.resume = 2
+			return EndIteration, nil
+		}
+		// End the inner for loop.
+		g.j--
+		// We are going to restart, so we need to update the approximate
+		// solution vector x and the residual.
+		g.update(ctx.vec, ctx.X)
+		g.resume = 6
+		return ComputeResidual, nil
+	case 6:
+		ctx.Converged = false
+		ctx.ResidualNorm = ctx.vec.norm(ctx.Residual)
+		g.resume = 7
+		return CheckResidualNorm, nil
+	case 7:
+		g.cycles++
+		switch {
+		case ctx.Converged:
+			g.resume = 0 // Calling Iterate again without Init will panic.
+		case g.MaxRestarts > 0 && g.cycles > g.MaxRestarts:
+			g.resume = 8
+		default:
+			g.resume = 1 // Restart (continue the outer for loop).
+		}
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+	case 8:
+		g.resume = 0 // Calling Iterate again without Init will panic.
+		return NoOperation, ErrRestartLimit
+
+	default:
+		panic("FGMRES: Init not called")
+	}
+}
+
+// update computes the current solution vector and stores it in x.
+func (g *FGMRES) update(vec *vecOps, x []float64) {
+	k := g.j + 1 // Number of valid columns of Z.
+	y := g.y[:k]
+	hessenbergSolve(y, g.h, g.ldh, g.s)
+	// Compute current solution vector x += Z*y.
+	vec.addMul(x, g.z, g.ldv, y)
+}
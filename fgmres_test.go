@@ -0,0 +1,137 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// innerSolve returns a PSolve function that approximately solves A z = r
+// with a few iterations of method started from zero. The number of
+// iterations cycles through 1, ..., maxIter, so the preconditioner changes
+// from one call to the next.
+func innerSolve(a iterative.MatrixOps, method iterative.Method, psolve func(dst, rhs []float64) error, maxIter int) func(dst, rhs []float64) error {
+	var calls int
+	return func(dst, rhs []float64) error {
+		calls++
+		// The inner solve stops at the iteration
+		// limit with an error that is expected.
+		res, _ := iterative.LinearSolve(a, rhs, method, iterative.Settings{
+			Tolerance:     1e-2,
+			MaxIterations: 1 + calls%maxIter,
+			PSolve:        psolve,
+		})
+		copy(dst, res.X)
+		return nil
+	}
+}
+
+func TestFGMRES(t *testing.T) {
+	// Symmetric positive definite problems
+	// preconditioned with inner CG.
+	for _, test := range []struct {
+		name    string
+		tol     float64
+		restart int
+	}{
+		{"nos4", 1e-8, 0},
+		{"nos4", 1e-8, 10},
+		{"bcsstm22", 1e-10, 0},
+		{"bcsstm22", 1e-8, 5},
+		{"nos5", 1e-7, 20},
+	} {
+		p := market(test.name, test.tol)
+		res, err := p.Solve(&iterative.FGMRES{Restart: test.restart}, iterative.Settings{
+			Tolerance: 1e-12,
+			PSolve:    innerSolve(p.A, &iterative.CG{}, nil, 5),
+		})
+		if err != nil {
+			t.Errorf("%s,restart=%d: %v", test.name, test.restart, err)
+			continue
+		}
+		if test.restart != 0 && res.Stats.Restarts != (res.Stats.Iterations-1)/test.restart {
+			t.Errorf("%s,restart=%d: %d restarts in %d iterations", test.name, test.restart, res.Stats.Restarts, res.Stats.Iterations)
+		}
+	}
+
+	// Nonsymmetric problems preconditioned with
+	// a varying number of Jacobi sweeps.
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name    string
+		a       *sparse.CSR
+		restart int
+	}{
+		{"dominant", diagonallyDominant(100, 5, 0.9, rnd), 0},
+		{"dominant", diagonallyDominant(200, 10, 0.9, rnd), 10},
+		{"convection", tridiagonalCSR(100, -1.5, 2, -0.5), 0},
+		{"convection", tridiagonalCSR(100, -1.5, 2, -0.5), 20},
+	} {
+		n, _ := test.a.Dims()
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+		}
+		b := make([]float64, n)
+		test.a.MulVec(b, x)
+		ops := iterative.MatrixOps{MatVec: test.a.MulVec}
+
+		jacobi := iterative.DiagonalInverse(sparse.Diagonal(test.a))
+		res, err := iterative.LinearSolve(ops, b, &iterative.FGMRES{Restart: test.restart}, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 10 * n,
+			PSolve:        innerSolve(ops, &iterative.Jacobi{Damping: 1}, jacobi.Apply, 4),
+		})
+		if err != nil {
+			t.Errorf("%s,n=%d,restart=%d: unexpected error: %v", test.name, n, test.restart, err)
+			continue
+		}
+		if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-8*floats.Norm(x, math.Inf(1)) {
+			t.Errorf("%s,n=%d,restart=%d: solution differs by %v", test.name, n, test.restart, d)
+		}
+		// The reported residual norm is the norm of
+		// the unpreconditioned residual.
+		r := make([]float64, n)
+		test.a.MulVec(r, res.X)
+		floats.Sub(r, b)
+		if rnorm := floats.Norm(r, 2); rnorm > 1e-10*floats.Norm(b, 2) {
+			t.Errorf("%s,n=%d,restart=%d: residual norm %v", test.name, n, test.restart, rnorm)
+		}
+	}
+}
+
+func TestFGMRESUnpreconditioned(t *testing.T) {
+	// Without a preconditioner FGMRES and GMRES
+	// build the same Krylov subspace.
+	for _, restart := range []int{30, 60, 0} {
+		p := market("gre__115", 1e-8)
+		settings := iterative.Settings{
+			Tolerance:     1e-10,
+			MaxIterations: 10000,
+		}
+		want, err := iterative.LinearSolve(p.A, p.B, &iterative.GMRES{Restart: restart}, settings)
+		if err != nil {
+			t.Fatalf("restart=%d: GMRES: %v", restart, err)
+		}
+		got, err := iterative.LinearSolve(p.A, p.B, &iterative.FGMRES{Restart: restart}, settings)
+		if err != nil {
+			t.Errorf("restart=%d: FGMRES: %v", restart, err)
+			continue
+		}
+		if got.Stats.Iterations != want.Stats.Iterations {
+			t.Errorf("restart=%d: FGMRES needs %d iterations, GMRES %d", restart, got.Stats.Iterations, want.Stats.Iterations)
+		}
+		if d := floats.Distance(got.X, want.X, math.Inf(1)); d > 1e-8*floats.Norm(want.X, math.Inf(1)) {
+			t.Errorf("restart=%d: solutions differ by %v", restart, d)
+		}
+	}
+}
@@ -0,0 +1,557 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// GCRODR implements the Generalized Conjugate Residual method with inner
+// Orthogonalization and Deflated Restarting for solving sequences of linear
+// systems
+//  A_i x_i = b_i
+// with slowly varying matrices or right-hand sides.
+//
+// GCRODR is a restarted GMRES that at the end of each cycle of Restart
+// iterations keeps a subspace U of the dimension Recycle spanned by the
+// harmonic Ritz vectors of the smallest harmonic Ritz values, which
+// approximate the eigenvectors that slow down the convergence of restarted
+// GMRES. The subsequent cycles orthogonalize the Krylov basis against
+// C = AU and minimize the residual over U and the Krylov subspace.
+//
+// The subspace U is also recycled between solves. A GCRODR value keeps it
+// when Init is called with the same dimension, so a sequence of solves that
+// reuse the same GCRODR value starts each solve by minimizing the residual
+// over U, which is computed as C = AU with the current matrix. Reset clears
+// the subspace.
+//
+// GCRODR uses the preconditioner from the left, in the same way as GMRES.
+//
+// References:
+//  - Parks, M.L., de Sturler, E., Mackey, G., Johnson, D.D., Maiti, S.:
+//    Recycling Krylov subspaces for sequences of linear systems. SIAM J.
+//    Sci. Comput. 28(5), 1651-1674 (2006)
+//
+// GCRODR needs MatVec, PSolve and ComputeResidual matrix operations.
+type GCRODR struct {
+	// Restart is the restart parameter m, the
+	// dimension of the subspace over which
+	// the residual is minimized in a cycle.
+	// It must be 0 <= Restart <= dim. If it
+	// is 0, dim is used.
+	Restart int
+	// Recycle is the dimension k of the
+	// recycled subspace. It must be
+	// 0 <= Recycle < m. If it is 0,
+	// min(10, m/2) is used.
+	Recycle int
+	// MaxRestarts is the maximum number of
+	// restarts, so at most MaxRestarts+1
+	// cycles are done. If the solve has not
+	// converged by the end of the last cycle,
+	// Iterate returns ErrRestartLimit. If
+	// MaxRestarts is 0, the number of
+	// restarts is limited only by
+	// Settings.MaxIterations. It must not be
+	// negative.
+	MaxRestarts int
+
+	resume int
+	dim    int
+	m, k   int
+	kk     int // Current dimension of the recycled subspace.
+	cycles int // Number of completed restart cycles.
+	i      int // Counter for the columns of C.
+
+	r []float64 // Preconditioned residual.
+	w []float64
+	s []float64
+	y []float64
+
+	j    int       // Counter for inner iterations.
+	v    []float64 // dim×(m+1) matrix V.
+	ldv  int
+	h    []float64 // (m+1)×m matrix G reduced by Givens rotations.
+	g    []float64 // (m+1)×m matrix G.
+	ldh  int
+	givs []givens // Givens rotations.
+
+	u  []float64 // dim×k matrix U.
+	c  []float64 // dim×k matrix C.
+	d  []float64 // Reciprocal norms of the columns of U.
+	uu []float64 // Workspace for the next U.
+	cc []float64 // Workspace for the next C.
+}
+
+// Init implements the Method interface. If the dimension is the same as in
+// the previous call to Init, the recycled subspace is kept.
+func (g *GCRODR) Init(dim int) {
+	if dim <= 0 {
+		panic("GCRODR: dimension not positive")
+	}
+	if g.Restart < 0 || dim < g.Restart {
+		panic("GCRODR: invalid value of Restart")
+	}
+	m := g.Restart
+	if m == 0 {
+		m = dim
+	}
+	if g.Recycle < 0 || (g.Recycle > 0 && m <= g.Recycle) {
+		panic("GCRODR: invalid value of Recycle")
+	}
+	if g.MaxRestarts < 0 {
+		panic("GCRODR: negative MaxRestarts")
+	}
+	k := g.Recycle
+	if k == 0 {
+		k = m / 2
+		if k > 10 {
+			k = 10
+		}
+	}
+
+	if dim != g.dim || k < g.kk {
+		g.kk = 0
+	}
+	g.dim = dim
+	g.m = m
+	g.k = k
+
+	g.r = reuse(g.r, dim)
+	g.w = reuse(g.w, dim)
+	g.s = reuse(g.s, m+1)
+	g.y = reuse(g.y, m+1)
+
+	g.ldv = dim
+	g.v = reuse(g.v, g.ldv*(m+1))
+	g.ldh = m + 1
+	g.h = reuse(g.h, g.ldh*m)
+	g.g = reuse(g.g, g.ldh*m)
+	if cap(g.givs) < m {
+		g.givs = make([]givens, m)
+	} else {
+		g.givs = g.givs[:m]
+	}
+
+	if cap(g.u) < g.ldv*k {
+		u := make([]float64, g.ldv*k)
+		copy(u, g.u[:g.ldv*g.kk])
+		g.u = u
+	} else {
+		g.u = g.u[:g.ldv*k]
+	}
+	g.c = reuse(g.c, g.ldv*k)
+	g.d = reuse(g.d, k)
+	g.uu = reuse(g.uu, g.ldv*k)
+	g.cc = reuse(g.cc, g.ldv*k)
+
+	g.cycles = 0
+	g.resume = 1
+}
+
+// Reset clears the recycled subspace, so the next solve starts with
+// restarted GMRES.
+func (g *GCRODR) Reset() {
+	g.kk = 0
+}
+
+// Iterate implements the Method interface.
+func (g *GCRODR) Iterate(ctx *Context) (Operation, error) {
+	n := len(ctx.X)
+	ldv := g.ldv
+
+	switch g.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = g.r
+		g.resume = 2
+		return PSolve, nil
+		// Solve M r = b - A x_0.
+	case 2:
+		if g.kk == 0 {
+			return g.startCycle(ctx)
+		}
+		// Compute C = M^{-1} A U with the current
+		// matrix and preconditioner.
+		g.i = 0
+		fallthrough
+	case 3:
+		ctx.Src = g.u[g.i*ldv : g.i*ldv+n]
+		ctx.Dst = g.w
+		g.resume = 4
+		return MatVec, nil
+		// Compute A U[:,i].
+	case 4:
+		ctx.Src = g.w
+		ctx.Dst = g.c[g.i*ldv : g.i*ldv+n]
+		g.resume = 5
+		return PSolve, nil
+		// Solve M C[:,i] = A U[:,i].
+	case 5:
+		g.i++
+		if g.i < g.kk {
+			ctx.Src = g.u[g.i*ldv : g.i*ldv+n]
+			ctx.Dst = g.w
+			g.resume = 4
+			return MatVec, nil
+		}
+		// Orthonormalize C and update U so that
+		// C = M^{-1} A U still holds.
+		for i := 0; i < g.kk; i++ {
+			ci := g.c[i*ldv : i*ldv+n]
+			ui := g.u[i*ldv : i*ldv+n]
+			for l := 0; l < i; l++ {
+				rli := ctx.vec.dot(g.c[l*ldv:l*ldv+n], ci)
+				ctx.vec.addScaled(ci, -rli, g.c[l*ldv:l*ldv+n])
+				ctx.vec.addScaled(ui, -rli, g.u[l*ldv:l*ldv+n])
+			}
+			rii := ctx.vec.norm(ci)
+			if rii == 0 {
+				// The remaining columns of U are
+				// linearly dependent, drop them.
+				g.kk = i
+				break
+			}
+			ctx.vec.scale(1/rii, ci)
+			ctx.vec.scale(1/rii, ui)
+		}
+		g.scaleU(ctx.vec, n)
+		// Minimize the residual over U:
+		//  x = x + U C^T r, r = r - C C^T r.
+		y := g.y[:g.kk]
+		for i := range y {
+			y[i] = ctx.vec.dot(g.c[i*ldv:i*ldv+n], g.r)
+		}
+		ctx.vec.addMul(ctx.X, g.u, ldv, y)
+		for i := range y {
+			y[i] = -y[i]
+		}
+		ctx.vec.addMul(g.r, g.c, ldv, y)
+		ctx.ResidualNorm = ctx.vec.norm(g.r)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		g.resume = 6
+		return CheckResidualNorm, nil
+	case 6:
+		if ctx.Converged {
+			ctx.ResidualCurrent = false
+			g.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		return g.startCycle(ctx)
+	case 7:
+		ctx.Src = g.w
+		ctx.Dst = g.v[(g.j+1)*ldv : (g.j+1)*ldv+n] // (j+1)-th column of V
+		g.resume = 8
+		return PSolve, nil
+		// Solve M w = A V[:,j].
+	case 8:
+		j := g.j
+		kk := g.kk
+		col := kk + j
+		w := g.v[(j+1)*ldv : (j+1)*ldv+n]
+		Gj := g.h[col*g.ldh : (col+1)*g.ldh] // col-th column of G.
+
+		// Orthogonalize w against C and V. The coefficients form the
+		// col-th column of G.
+		ctx.vec.mgs(Gj[:kk], g.c, ldv, w)
+		wnorm := ctx.vec.mgs(Gj[kk:col+1], g.v, ldv, w)
+		Gj[col+1] = wnorm
+		if wnorm != 0 {
+			ctx.vec.scale(1/wnorm, w) // Normalize V[:,j+1].
+		}
+		copy(g.g[col*g.ldh:(col+1)*g.ldh], Gj)
+
+		// Reduce the col-th column of G to upper triangular form
+		// and update s.
+		givensUpdate(g.givs, Gj, g.s, col)
+		// Approximate the residual norm and check for convergence.
+		ctx.ResidualNorm = math.Abs(g.s[col+1])
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		g.resume = 9
+		return CheckResidualNorm, nil
+	case 9:
+		if ctx.Converged {
+			// Compute final approximate solution x and keep the
+			// recycled subspace for the next solve.
+			g.update(ctx.vec, ctx.X)
+			g.recycle(ctx.vec, n)
+			ctx.ResidualCurrent = false
+			g.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		g.j++
+		if g.kk+g.j < g.m {
+			// Continue the inner for loop.
+			ctx.ResidualCurrent = false
+			g.resume = 10
+			return EndIteration, nil
+		}
+		// End the inner for loop.
+		g.j--
+		g.update(ctx.vec, ctx.X)
+		g.recycle(ctx.vec, n)
+		g.resume = 11
+		return ComputeResidual, nil
+	case 10:
+		ctx.Src = g.v[g.j*ldv : g.j*ldv+n] // j-th column of V
+		ctx.Dst = g.w
+		g.resume = 7
+		return MatVec, nil
+		// Compute A V[:,j].
+	case 11:
+		ctx.Src = ctx.Residual
+		ctx.Dst = g.r
+		g.resume = 12
+		return PSolve, nil
+		// Solve M r = b - A x.
+	case 12:
+		ctx.Converged = false
+		ctx.ResidualNorm = ctx.vec.norm(g.r)
+		g.resume = 13
+		return CheckResidualNorm, nil
+	case 13:
+		g.cycles++
+		switch {
+		case ctx.Converged:
+			g.resume = 0 // Calling Iterate again without Init will panic.
+		case g.MaxRestarts > 0 && g.cycles > g.MaxRestarts:
+			g.resume = 14
+		default:
+			g.resume = 15 // Restart (continue the outer for loop).
+		}
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+	case 14:
+		g.resume = 0 // Calling Iterate again without Init will panic.
+		return NoOperation, ErrRestartLimit
+	case 15:
+		return g.startCycle(ctx)
+
+	default:
+		panic("GCRODR: Init not called")
+	}
+}
+
+// startCycle initializes a cycle from the preconditioned residual and
+// commands the first MatVec of the cycle.
+func (g *GCRODR) startCycle(ctx *Context) (Operation, error) {
+	if g.cycles > 0 {
+		ctx.Restarts++
+	}
+	n := len(ctx.X)
+	ldv := g.ldv
+	kk := g.kk
+
+	// Initialize s to [C^T r; |r| e_1].
+	for i := range g.s {
+		g.s[i] = 0
+	}
+	for i := 0; i < kk; i++ {
+		g.s[i] = ctx.vec.dot(g.c[i*ldv:i*ldv+n], g.r)
+	}
+	norm := ctx.vec.norm(g.r)
+	g.s[kk] = norm
+	// Construct the first column of V.
+	v0 := g.v[:n]
+	ctx.vec.copy(v0, g.r)
+	ctx.vec.scale(1/norm, v0)
+
+	// The leading k×k block of G is the diagonal
+	// matrix D such that M^{-1} A U D = C D, it
+	// is already upper triangular.
+	for i := range g.h {
+		g.h[i] = 0
+		g.g[i] = 0
+	}
+	for i := 0; i < kk; i++ {
+		g.h[i*g.ldh+i] = g.d[i]
+		g.g[i*g.ldh+i] = g.d[i]
+		g.givs[i] = givens{c: 1, s: 0}
+	}
+
+	// for j := 0; j < m-k; j++ {
+	g.j = 0
+	ctx.Src = v0
+	ctx.Dst = g.w
+	g.resume = 7
+	return MatVec, nil
+	// Compute A V[:,0].
+}
+
+// update computes the current solution vector and stores it in x.
+func (g *GCRODR) update(vec *vecOps, x []float64) {
+	kk := g.kk
+	p := kk + g.j + 1 // Number of valid columns of G.
+	y := g.y[:p]
+	hessenbergSolve(y, g.h, g.ldh, g.s)
+	// Compute current solution vector x += U D y[:k] + V y[k:].
+	for i := 0; i < kk; i++ {
+		y[i] *= g.d[i]
+	}
+	vec.addMul(x, g.u, g.ldv, y[:kk])
+	vec.addMul(x, g.v, g.ldv, y[kk:])
+}
+
+// recycle computes the next recycled subspace from the harmonic Ritz
+// vectors of the current cycle. If they cannot be computed, the current
+// subspace is kept.
+func (g *GCRODR) recycle(vec *vecOps, n int) {
+	kk := g.kk
+	p := kk + g.j + 1 // Number of valid columns of G.
+	k := g.k
+	if k > p {
+		k = p
+	}
+	if k == 0 {
+		return
+	}
+	ldv := g.ldv
+	ldh := g.ldh
+
+	// G is the (p+1)×p matrix such that
+	//  M^{-1} A Û = W G,
+	// where Û = [U D, V[:,:p-k]] and W = [C, V[:,:p-k+1]].
+	G := mat.NewDense(p+1, p, nil)
+	for j := 0; j < p; j++ {
+		for i := 0; i <= j+1; i++ {
+			G.Set(i, j, g.g[j*ldh+i])
+		}
+	}
+	// Compute W^T Û. The columns of V are
+	// orthonormal and orthogonal to C.
+	wu := mat.NewDense(p+1, p, nil)
+	for i := 0; i < kk; i++ {
+		ci := g.c[i*ldv : i*ldv+n]
+		for l := 0; l < kk; l++ {
+			wu.Set(i, l, g.d[l]*vec.dot(ci, g.u[l*ldv:l*ldv+n]))
+		}
+	}
+	for i := 0; i <= p-kk; i++ {
+		vi := g.v[i*ldv : i*ldv+n]
+		for l := 0; l < kk; l++ {
+			wu.Set(kk+i, l, g.d[l]*vec.dot(vi, g.u[l*ldv:l*ldv+n]))
+		}
+		if i < p-kk {
+			wu.Set(kk+i, kk+i, 1)
+		}
+	}
+
+	// The harmonic Ritz pairs (θ, z) solve
+	//  G^T G z = θ G^T W^T Û z.
+	// With the QR factorization G = QR they are the
+	// eigenpairs (1/θ, z) of R^{-1} Q^T W^T Û, and
+	// the smallest θ are the largest eigenvalues.
+	var qr mat.QR
+	qr.Factorize(G)
+	var b mat.Dense
+	if err := qr.SolveTo(&b, false, wu); err != nil {
+		return
+	}
+	var eig mat.Eigen
+	if !eig.Factorize(&b, mat.EigenRight) {
+		return
+	}
+	vals := eig.Values(nil)
+	var vecs mat.CDense
+	eig.VectorsTo(&vecs)
+	order := make([]int, p)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return cmplx.Abs(vals[order[i]]) > cmplx.Abs(vals[order[j]])
+	})
+	// A complex conjugate pair of eigenvectors
+	// contributes its real and imaginary parts.
+	P := mat.NewDense(p, k, nil)
+	var l int
+	for _, i := range order {
+		if l == k {
+			break
+		}
+		if imag(vals[i]) < 0 {
+			continue
+		}
+		for r := 0; r < p; r++ {
+			P.Set(r, l, real(vecs.At(r, i)))
+		}
+		l++
+		if imag(vals[i]) > 0 && l < k {
+			for r := 0; r < p; r++ {
+				P.Set(r, l, imag(vecs.At(r, i)))
+			}
+			l++
+		}
+	}
+
+	// With the QR factorization G P = Q R the next
+	// subspaces are
+	//  C = W Q, U = Û P R^{-1}.
+	var gp mat.Dense
+	gp.Mul(G, P)
+	qr.Factorize(&gp)
+	var q, r mat.Dense
+	qr.QTo(&q)
+	qr.RTo(&r)
+	t := mat.NewDense(p, k, nil)
+	for j := 0; j < k; j++ {
+		rjj := r.At(j, j)
+		if rjj == 0 {
+			return
+		}
+		for i := 0; i < p; i++ {
+			tij := P.At(i, j)
+			for l := 0; l < j; l++ {
+				tij -= t.At(i, l) * r.At(l, j)
+			}
+			t.Set(i, j, tij/rjj)
+		}
+	}
+	coef := g.y[:p]
+	for j := 0; j < k; j++ {
+		uj := g.uu[j*ldv : j*ldv+n]
+		for i := range uj {
+			uj[i] = 0
+		}
+		for i := 0; i < p; i++ {
+			coef[i] = t.At(i, j)
+		}
+		for i := 0; i < kk; i++ {
+			coef[i] *= g.d[i]
+		}
+		vec.addMul(uj, g.u, ldv, coef[:kk])
+		vec.addMul(uj, g.v, ldv, coef[kk:p])
+
+		cj := g.cc[j*ldv : j*ldv+n]
+		for i := range cj {
+			cj[i] = 0
+		}
+		qj := g.y[:p+1]
+		for i := range qj {
+			qj[i] = q.At(i, j)
+		}
+		vec.addMul(cj, g.c, ldv, qj[:kk])
+		vec.addMul(cj, g.v, ldv, qj[kk:])
+	}
+	g.u, g.uu = g.uu, g.u
+	g.c, g.cc = g.cc, g.c
+	g.kk = k
+	g.scaleU(vec, n)
+}
+
+// scaleU computes the reciprocal norms of the columns of U.
+func (g *GCRODR) scaleU(vec *vecOps, n int) {
+	for i := 0; i < g.kk; i++ {
+		g.d[i] = 1 / vec.norm(g.u[i*g.ldv:i*g.ldv+n])
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat"
+)
+
+func TestGCRODR(t *testing.T) {
+	for _, test := range []struct {
+		name             string
+		tol              float64
+		restart, recycle int
+	}{
+		{"nos4", 1e-8, 20, 5},
+		{"nos4", 1e-8, 0, 0},
+		{"bcsstm22", 1e-9, 10, 3},
+		{"gre__115", 1e-8, 30, 10},
+		{"e05r0000", 1e-8, 20, 10},
+		{"gre__115", 1e-8, 20, 0},
+	} {
+		p := market(test.name, test.tol)
+		_, err := p.Solve(&iterative.GCRODR{Restart: test.restart, Recycle: test.recycle}, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 10000,
+		})
+		if err != nil {
+			t.Errorf("restart=%d,recycle=%d: %v", test.restart, test.recycle, err)
+		}
+	}
+}
+
+// outliers returns an n×n upper bidiagonal matrix with the eigenvalues
+// 0.01, 0.02, ..., 0.05 and the rest random in [1, 10].
+func outliers(n int, rnd *rand.Rand) *sparse.CSR {
+	t := sparse.NewTriplet(n, n)
+	for i := 0; i < n; i++ {
+		d := 1 + 9*rnd.Float64()
+		if i%(n/5) == 0 {
+			d = 0.01 * float64(1+i/(n/5))
+		}
+		t.Append(i, i, d)
+		if i+1 < n {
+			t.Append(i, i+1, 0.5)
+		}
+	}
+	return sparse.NewCSRFromTriplet(t)
+}
+
+func TestGCRODRSequence(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name string
+		a    *sparse.CSR
+	}{
+		{"poisson", testmat.Poisson2D(20, 20).CSR()},
+		{"outliers", outliers(400, rnd)},
+	} {
+		n, _ := test.a.Dims()
+		ops := iterative.MatrixOps{MatVec: test.a.MulVec}
+		settings := iterative.Settings{
+			Tolerance:     1e-8,
+			MaxIterations: 10000,
+		}
+		solve := func(g *iterative.GCRODR, b []float64) int {
+			res, err := iterative.LinearSolve(ops, b, g, settings)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+				return 0
+			}
+			r := make([]float64, n)
+			test.a.MulVec(r, res.X)
+			floats.Sub(r, b)
+			if rnorm := floats.Norm(r, 2); rnorm > 1e-7*floats.Norm(b, 2) {
+				t.Errorf("%s: residual norm %v", test.name, rnorm)
+			}
+			return res.Stats.Iterations
+		}
+
+		// A sequence of systems with the same
+		// matrix and random right-hand sides.
+		const nrhs = 4
+		bs := make([][]float64, nrhs)
+		for i := range bs {
+			bs[i] = make([]float64, n)
+			for j := range bs[i] {
+				bs[i][j] = rnd.NormFloat64()
+			}
+		}
+		g := &iterative.GCRODR{Restart: 20, Recycle: 10}
+		first := solve(g, bs[0])
+		for i := 1; i < nrhs; i++ {
+			recycled := solve(g, bs[i])
+			fresh := solve(&iterative.GCRODR{Restart: 20, Recycle: 10}, bs[i])
+			if recycled >= fresh || recycled >= first {
+				t.Errorf("%s: solve %d needs %d iterations with the recycled subspace, %d without, %d in the first solve",
+					test.name, i, recycled, fresh, first)
+			}
+		}
+
+		// Reset clears the subspace, so the first
+		// solve is repeated exactly.
+		g.Reset()
+		if its := solve(g, bs[0]); its != first {
+			t.Errorf("%s: %d iterations after Reset, want %d", test.name, its, first)
+		}
+
+		// A problem of another dimension starts
+		// without the subspace.
+		a := tridiagonalCSR(50, -1, 2, -1)
+		b := make([]float64, 50)
+		for i := range b {
+			b[i] = 1
+		}
+		res, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: a.MulVec}, b, g, settings)
+		if err != nil {
+			t.Errorf("%s: unexpected error after a change of dimension: %v", test.name, err)
+			continue
+		}
+		want, _ := iterative.LinearSolve(iterative.MatrixOps{MatVec: a.MulVec}, b, &iterative.GCRODR{Restart: 20, Recycle: 10}, settings)
+		if res.Stats.Iterations != want.Stats.Iterations {
+			t.Errorf("%s: %d iterations after a change of dimension, want %d", test.name, res.Stats.Iterations, want.Stats.Iterations)
+		}
+	}
+}
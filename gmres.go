@@ -5,6 +5,7 @@
 package iterative
 
 import (
+	"errors"
 	"math"
 
 	"gonum.org/v1/gonum/blas"
@@ -12,23 +13,88 @@ import (
 	"gonum.org/v1/gonum/floats"
 )
 
-// GMRES implements the Generalized Minimum Residual method with the modified
-// Gram-Schmidt orthogonalization. It uses restarts to control storage
-// requirements.
+// ErrRestartLimit is returned by restarted methods when the solve has not
+// converged within the maximum number of restarts.
+var ErrRestartLimit = errors.New("iterative: restart limit reached")
+
+// Orthogonalization is the orthogonalization process by which GMRES builds
+// the orthonormal basis of the Krylov subspace.
+type Orthogonalization int
+
+const (
+	// ModifiedGramSchmidt orthogonalizes each
+	// new vector against the previous basis
+	// vectors one at a time. It is the
+	// cheapest process but the orthogonality
+	// of the basis is lost gradually on
+	// ill-conditioned problems, and with it
+	// the agreement of the residual norm
+	// estimated by GMRES with the true one.
+	ModifiedGramSchmidt Orthogonalization = iota
+	// Householder builds the basis from
+	// Householder reflections, which keep it
+	// orthogonal to machine precision. It
+	// needs about twice as many operations
+	// as ModifiedGramSchmidt and one more
+	// vector. Repeated solves with the same
+	// settings compute bit for bit the same
+	// basis.
+	Householder
+	// ClassicalGramSchmidt2 orthogonalizes
+	// each new vector against all previous
+	// basis vectors at once by the classical
+	// Gram-Schmidt process, which needs two
+	// matrix-vector products with the basis
+	// instead of a sequence of inner products
+	// and vector updates. If the norm of the
+	// vector drops by more than a factor of
+	// 1/√2, the projection is repeated once,
+	// which keeps the basis orthogonal to
+	// machine precision.
+	ClassicalGramSchmidt2
+)
+
+// reorthTol is the factor by which the norm of a vector orthogonalized by
+// the classical Gram-Schmidt process must drop to be reorthogonalized.
+const reorthTol = 1 / math.Sqrt2
+
+// GMRES implements the Generalized Minimum Residual method. It uses restarts
+// to control storage requirements.
+//
+// References:
+//  - Walker, H.F.: Implementation of the GMRES method using Householder
+//    transformations. SIAM J. Sci. Stat. Comput. 9(1), 152-163 (1988)
 type GMRES struct {
 	// Restart is the restart parameter.
 	// It must be 0 <= Restart <= dim.
 	// If it is 0, it will be set to dim.
 	Restart int
+	// Orthogonalization is the process by
+	// which the basis of the Krylov
+	// subspace is orthogonalized. The zero
+	// value is ModifiedGramSchmidt.
+	Orthogonalization Orthogonalization
+	// MaxRestarts is the maximum number of
+	// restarts, so at most MaxRestarts+1
+	// cycles of Restart iterations are done.
+	// If the solve has not converged by the
+	// end of the last cycle, Iterate returns
+	// ErrRestartLimit. If MaxRestarts is 0,
+	// the number of restarts is limited only
+	// by Settings.MaxIterations. It must not
+	// be negative.
+	MaxRestarts int
 
 	resume int
+	cycles int // Number of completed restart cycles.
 
 	s  []float64
 	y  []float64
 	av []float64
+	z  []float64 // Current basis vector for Householder.
 
 	j    int       // Counter for inner iterations.
-	v    []float64 // dim×(Restart+1) matrix V.
+	v    []float64 // dim×(Restart+1) matrix V, or W of the Householder vectors.
 	ldv  int
 	h    []float64 // (Restart+1)×Restart matrix H.
 	ldh  int
@@ -51,6 +117,16 @@ func (g *GMRES) Init(dim int) {
 	if g.Restart <= 0 || dim < g.Restart {
 		panic("GMRES: invalid value of Restart")
 	}
+	if g.MaxRestarts < 0 {
+		panic("GMRES: negative MaxRestarts")
+	}
+	switch g.Orthogonalization {
+	case ModifiedGramSchmidt, ClassicalGramSchmidt2:
+	case Householder:
+		g.z = reuse(g.z, dim)
+	default:
+		panic("GMRES: invalid Orthogonalization")
+	}
 	k := g.Restart
 
 	g.s = reuse(g.s, k+1)
@@ -68,6 +144,7 @@ func (g *GMRES) Init(dim int) {
 		g.givs = g.givs[:k]
 	}
 
+	g.cycles = 0
 	g.resume = 1
 }
 
@@ -77,28 +154,44 @@ func (g *GMRES) Iterate(ctx *Context) (Operation, error) {
 
 	switch g.resume {
 	case 1:
+		if g.cycles > 0 {
+			ctx.Restarts++
+		}
 		// Construct the first column of V.
 		ctx.Src = ctx.Residual
 		ctx.Dst = g.v[:n]
+		if g.Orthogonalization == Householder {
+			ctx.Dst = g.z
+		}
 		g.resume = 2
 		return PSolve, nil
 		// Solve M V[:,0] = r.
 	case 2:
-		// Normalize V[:,0].
-		v0 := g.v[:n]
-		norm := floats.Norm(v0, 2)
-		floats.Scale(1/norm, v0)
 		// Initialize s to the elementary vector e_1 scaled by norm.
 		for i := range g.s {
 			g.s[i] = 0
 		}
-		g.s[0] = norm
+		if g.Orthogonalization == Householder {
+			// The reflection P_0 maps z to ±|z| e_1
+			// and V[:,0] = P_0 e_1.
+			g.s[0] = g.reflector(ctx.vec, 0, g.z)
+			g.basisVector(ctx.vec, 0, g.z)
+		} else {
+			// Normalize V[:,0].
+			v0 := g.v[:n]
+			norm := ctx.vec.norm(v0)
+			ctx.vec.scale(1/norm, v0)
+			g.s[0] = norm
+		}
 
 		// for j := 0; j < Restart; j++ {
 		g.j = 0
 		fallthrough
 	case 3:
 		ctx.Src = g.v[g.j*g.ldv : g.j*g.ldv+n] // j-th column of V
+		if g.Orthogonalization == Householder {
+			ctx.Src = g.z
+		}
 		ctx.Dst = g.av
 		g.resume = 4
 		return MatVec, nil
@@ -106,45 +199,63 @@ func (g *GMRES) Iterate(ctx *Context) (Operation, error) {
 	case 4:
 		ctx.Src = g.av
 		ctx.Dst = g.v[(g.j+1)*g.ldv : (g.j+1)*g.ldv+n] // (j+1)-th column f V
+		if g.Orthogonalization == Householder {
+			ctx.Dst = g.z
+		}
 		g.resume = 5
 		return PSolve, nil
 		// Solve M w = A V[:,j].
 	case 5:
 		j := g.j
 		ldv := g.ldv
-		w := g.v[(j+1)*ldv : (j+1)*ldv+n]
 		H := g.h
 		ldh := g.ldh
 		Hj := H[j*ldh : j*ldh+g.Restart+1] // j-th column of H.
 
-		// Construct j-th column of the upper Hessenberg matrix using
-		// the Gram-Schmidt process on V and w so that it is orthonormal
-		// to the previous j-1 columns.
-		for k := 0; k <= j; k++ {
-			vk := g.v[k*ldv : k*ldv+n] // k-th column pf V.
-			hkj := floats.Dot(vk, w)
-			Hj[k] = hkj                   // H[k,j] = V[:,k]^T V[:,j+1]
-			floats.AddScaled(w, -hkj, vk) // w -= H[k,j] * V[:,k]
-		}
-		wnorm := floats.Norm(w, 2)
-		Hj[j+1] = wnorm          // H[j+1,j] = |w|
-		floats.Scale(1/wnorm, w) // Normalize V[:,j+1].
-
-		// Apply j Givens rotation matrices to the j-th
-		// column of H.
-		for i := 0; i < j; i++ {
-			Hj[i], Hj[i+1] = rotvec(g.givs[i], Hj[i], Hj[i+1])
+		if g.Orthogonalization == Householder {
+			// Apply P_j ... P_0 to w, its first j+1
+			// elements and the norm of the rest form
+			// the j-th column of H.
+			w := g.z
+			for i := 0; i <= j; i++ {
+				g.reflect(ctx.vec, i, w)
+			}
+			copy(Hj[:j+1], w[:j+1])
+			if j+1 < n {
+				Hj[j+1] = g.reflector(ctx.vec, j+1, w)
+				if j+1 < g.Restart {
+					g.basisVector(ctx.vec, j+1, w) // V[:,j+1] = P_0 ... P_{j+1} e_{j+1}
+				}
+			} else {
+				Hj[j+1] = 0
+			}
+		} else {
+			w := g.v[(j+1)*ldv : (j+1)*ldv+n]
+			// Construct j-th column of the upper Hessenberg matrix using
+			// the Gram-Schmidt process on V and w so that it is orthonormal
+			// to the previous j-1 columns.
+			var wnorm float64
+			if g.Orthogonalization == ClassicalGramSchmidt2 {
+				norm := ctx.vec.norm(w)
+				wnorm = ctx.vec.cgs(Hj[:j+1], g.v, ldv, w)
+				if wnorm < reorthTol*norm {
+					// Twice is enough.
+					hc := g.y[:j+1]
+					wnorm = ctx.vec.cgs(hc, g.v, ldv, w)
+					floats.Add(Hj[:j+1], hc)
+				}
+			} else {
+				wnorm = ctx.vec.mgs(Hj[:j+1], g.v, ldv, w)
+			}
+			Hj[j+1] = wnorm           // H[j+1,j] = |w|
+			ctx.vec.scale(1/wnorm, w) // Normalize V[:,j+1].
 		}
-		// Compute the (j+1)st Givens rotation that zeroes H[j+1,j].
-		g.givs[j] = drotg(Hj[j], Hj[j+1])
-		// Apply the (j+1)st Givens rotation.
-		Hj[j], Hj[j+1] = rotvec(g.givs[j], Hj[j], Hj[j+1])
-
-		// Apply the (j+1)st Givens rotation to (s[j], s[j+1]).
-		s := g.s
-		s[j], s[j+1] = rotvec(g.givs[j], s[j], s[j+1])
+
+		// Reduce the j-th column of H to upper triangular form
+		// and update s.
+		givensUpdate(g.givs, Hj, g.s, j)
 		// Approximate the residual norm and check for convergence.
-		ctx.ResidualNorm = math.Abs(s[j+1])
+		ctx.ResidualNorm = math.Abs(g.s[j+1])
 		ctx.Src = nil
 		ctx.Dst = nil
 		ctx.Converged = false
@@ -153,18 +264,19 @@ func (g *GMRES) Iterate(ctx *Context) (Operation, error) {
 	case 6:
 		if ctx.Converged {
 			// Compute final approximate solution x and finish.
-			g.update(ctx.X)
-			// TODO: Should we also call ComputeResidual? It depends
-			// on how we specify the reverse-communication protocol.
-			// If initially Context.Residual must be valid, then it
-			// makes sense to finish the iterations again with an
-			// up-to-date Residual.
+			g.update(ctx.vec, ctx.X)
+			// The residual is not computed, it would cost
+			// an additional MatVec. Callers that need it
+			// check Context.ResidualCurrent.
+			ctx.ResidualCurrent = false
 			g.resume = 0 // Calling Iterate again without Init will panic.
 			return EndIteration, nil
 		}
 		g.j++
 		if g.j < g.Restart {
-			// Continue the inner for loop.
+			// Continue the inner for loop. Residual is the
+			// residual at the restart.
+			ctx.ResidualCurrent = false
 			g.resume = 3
 			return EndIteration, nil
 		}
@@ -175,21 +287,29 @@ func (g *GMRES) Iterate(ctx *Context) (Operation, error) {
 		g.j--
 		// We are going to restart, so we need to update the approximate
 		// solution vector x and the residual.
-		g.update(ctx.X)
+		g.update(ctx.vec, ctx.X)
 		g.resume = 8
 		return ComputeResidual, nil
 	case 8:
 		ctx.Converged = false
-		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
+		ctx.ResidualNorm = ctx.vec.norm(ctx.Residual)
 		g.resume = 9
 		return CheckResidualNorm, nil
 	case 9:
-		if ctx.Converged {
+		g.cycles++
+		switch {
+		case ctx.Converged:
 			g.resume = 0 // Calling Iterate again without Init will panic.
-		} else {
+		case g.MaxRestarts > 0 && g.cycles > g.MaxRestarts:
+			g.resume = 10
+		default:
 			g.resume = 1 // Restart (continue the outer for loop).
 		}
+		ctx.ResidualCurrent = true
 		return EndIteration, nil
+	case 10:
+		g.resume = 0 // Calling Iterate again without Init will panic.
+		return NoOperation, ErrRestartLimit
 
 	default:
 		panic("GMRES: Init not called")
@@ -197,21 +317,105 @@ func (g *GMRES) Iterate(ctx *Context) (Operation, error) {
 }
 
 // update computes the current solution vector and stores it in x.
-func (g *GMRES) update(x []float64) {
+func (g *GMRES) update(vec *vecOps, x []float64) {
 	k := g.j + 1 // Number of valid columns of V.
 	y := g.y[:k]
-	copy(y, g.s[:k])
-	// Solve H*y = s for upper triangular H.
+	hessenbergSolve(y, g.h, g.ldh, g.s)
+	if g.Orthogonalization == Householder {
+		// The reflections P_i with i > j do not change e_j,
+		// so V*y = P_0 ... P_{k-1} [y; 0].
+		u := g.z
+		copy(u, y)
+		for i := k; i < len(u); i++ {
+			u[i] = 0
+		}
+		for i := k - 1; i >= 0; i-- {
+			g.reflect(vec, i, u)
+		}
+		vec.add(x, u)
+		return
+	}
+	// Compute current solution vector x += V*y.
+	vec.addMul(x, g.v, g.ldv, y)
+}
+
+// reflector computes the Householder reflection P_j = I - 2 w w^T that maps
+// z[j:] to a multiple of e_1 and leaves z[:j] unchanged, stores w in the
+// j-th column of W, applies P_j to z and returns z[j].
+func (g *GMRES) reflector(vec *vecOps, j int, z []float64) float64 {
+	n := len(z)
+	w := g.v[j*g.ldv : j*g.ldv+n]
+	for i := range w[:j] {
+		w[i] = 0
+	}
+	x := z[j:]
+	norm := vec.norm(x)
+	if norm == 0 {
+		// P_j is the identity.
+		for i := range w[j:] {
+			w[j+i] = 0
+		}
+		return 0
+	}
+	alpha := -math.Copysign(norm, x[0])
+	copy(w[j:], x)
+	w[j] -= alpha
+	vec.scale(1/vec.norm(w[j:]), w[j:])
+	x[0] = alpha
+	for i := range x[1:] {
+		x[1+i] = 0
+	}
+	return alpha
+}
+
+// reflect applies the Householder reflection P_i stored in the i-th column
+// of W to y.
+func (g *GMRES) reflect(vec *vecOps, i int, y []float64) {
+	w := g.v[i*g.ldv+i : i*g.ldv+len(y)]
+	d := vec.dot(w, y[i:])
+	vec.addScaled(y[i:], -2*d, w)
+}
+
+// basisVector stores the j-th basis vector P_0 ... P_j e_j in v.
+func (g *GMRES) basisVector(vec *vecOps, j int, v []float64) {
+	for i := range v {
+		v[i] = 0
+	}
+	v[j] = 1
+	for i := j; i >= 0; i-- {
+		g.reflect(vec, i, v)
+	}
+}
+
+// givensUpdate applies the first j Givens rotations in givs to the j-th
+// column hj of the upper Hessenberg matrix H, computes the (j+1)st rotation
+// that zeroes H[j+1,j], stores it in givs[j] and applies it to hj and to
+// (s[j], s[j+1]).
+func givensUpdate(givs []givens, hj, s []float64, j int) {
+	// Apply j Givens rotation matrices to the j-th
+	// column of H.
+	for i := 0; i < j; i++ {
+		hj[i], hj[i+1] = rotvec(givs[i], hj[i], hj[i+1])
+	}
+	// Compute the (j+1)st Givens rotation that zeroes H[j+1,j].
+	givs[j] = drotg(hj[j], hj[j+1])
+	// Apply the (j+1)st Givens rotation.
+	hj[j], hj[j+1] = rotvec(givs[j], hj[j], hj[j+1])
+
+	// Apply the (j+1)st Givens rotation to (s[j], s[j+1]).
+	s[j], s[j+1] = rotvec(givs[j], s[j], s[j+1])
+}
+
+// hessenbergSolve solves H*y = s for the leading len(y)×len(y) block of the
+// upper Hessenberg matrix h reduced to upper triangular form by
+// givensUpdate and stores the result in y.
+func hessenbergSolve(y, h []float64, ldh int, s []float64) {
+	k := len(y)
+	copy(y, s[:k])
 	// H is upper triangular but stored in column-major order while Dtrsv
 	// expects row-major.
 	bi := blas64.Implementation()
-	bi.Dtrsv(blas.Lower, blas.Trans, blas.NonUnit, k, g.h, g.ldh, y, 1)
-	// Compute current solution vector x.
-	n := len(x)
-	for j, yj := range y {
-		vj := g.v[j*g.ldv : j*g.ldv+n] // j-th column of V
-		floats.AddScaled(x, yj, vj)    // x += y_j * V_j
-	}
+	bi.Dtrsv(blas.Lower, blas.Trans, blas.NonUnit, k, h, ldh, y, 1)
 }
 
 // drotg returns Givens plane rotation.
@@ -2,30 +2,33 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package iterative
+package iterative_test
 
 import (
 	"math"
 	"math/rand"
 	"testing"
 
-	"github.com/gonum/floats"
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
 )
 
 func TestGMRES(t *testing.T) {
 	rnd := rand.New(rand.NewSource(1))
-	for _, tc := range []testCase{
-		randomSPD(1, rnd),
-		randomSPD(2, rnd),
-		randomSPD(3, rnd),
-		randomSPD(4, rnd),
-		randomSPD(5, rnd),
-		randomSPD(10, rnd),
-		randomSPD(20, rnd),
-		randomSPD(50, rnd),
-		randomSPD(100, rnd),
-		randomSPD(200, rnd),
-		randomSPD(500, rnd),
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(3, rnd),
+		problem.RandomSPD(4, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(10, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(50, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.RandomSPD(200, rnd),
+		problem.RandomSPD(500, rnd),
 		market("nos1", 1e-10),
 		market("nos4", 1e-12),
 		market("nos5", 1e-12),
@@ -45,16 +48,17 @@ func TestGMRES(t *testing.T) {
 		market("impcol_c", 1e-12),
 		market("impcol_d", 1e-12),
 		market("impcol_e", 1e-11),
-		// market("fs_183_1", 1e-12),
-		// market("fs_183_3", 1e-12),
+		// fs_183_1, fs_183_3 and west0156 are replaced by random
+		// matrices in TestGMRESRandomSparse.
 		market("fs_183_4", 1e-5),
 		market("fs_183_6", 1e-4),
+		// The commented out matrices are solved with the
+		// Householder orthogonalization in TestGMRESHouseholder.
 		// market("mbeacxc", 1e-12),
 		// market("mbeaflw", 1e-12),
 		// market("mbeause", 1e-12),
 		market("west0067", 1e-12),
 		market("west0132", 1e-6),
-		// market("west0156", 1e-12),
 		market("west0167", 1e-8),
 		market("west0381", 1e-11),
 		market("west0479", 1e-6),
@@ -70,30 +74,182 @@ func TestGMRES(t *testing.T) {
 		// market("nnc261", 1e-12),
 		market("arc130", 1e-4),
 	} {
-		n := tc.n
-		A := tc.a
-		// Compute the right-hand side b so that the vector [1,1,...,1]
-		// is the solution.
-		want := make([]float64, n)
-		for i := range want {
-			want[i] = 1
-		}
-		b := make([]float64, n)
-		A.MatVec(b, want)
-
 		// TODO(vladimir-ch): Add tests with non-default Restart. For
 		// that we probably need to generate nicer matrices.
-		r, err := LinearSolve(A, b, &GMRES{}, Settings{
-			MaxIterations: tc.iters,
-			Tolerance:     1e-15,
+		_, err := p.Solve(&iterative.GMRES{}, iterative.Settings{Tolerance: 1e-15})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestGMRESMaxRestarts(t *testing.T) {
+	const (
+		restart     = 5
+		maxRestarts = 3
+	)
+	p := market("gre__115", 1e-12)
+	// The restart limit is reached before
+	// the iteration limit.
+	res, err := iterative.LinearSolve(p.A, p.B, &iterative.GMRES{Restart: restart, MaxRestarts: maxRestarts}, iterative.Settings{
+		Tolerance:     1e-12,
+		MaxIterations: 1000,
+	})
+	if err != iterative.ErrRestartLimit {
+		t.Errorf("unexpected error: want %v, got %v", iterative.ErrRestartLimit, err)
+	}
+	if res.Stats.Restarts != maxRestarts {
+		t.Errorf("unexpected number of restarts: want %d, got %d", maxRestarts, res.Stats.Restarts)
+	}
+	if want := (maxRestarts + 1) * restart; res.Stats.Iterations != want {
+		t.Errorf("unexpected number of iterations: want %d, got %d", want, res.Stats.Iterations)
+	}
+
+	// Without MaxRestarts the same solve
+	// stops at the iteration limit.
+	res, err = iterative.LinearSolve(p.A, p.B, &iterative.GMRES{Restart: restart}, iterative.Settings{
+		Tolerance:     1e-12,
+		MaxIterations: (maxRestarts + 1) * restart,
+	})
+	if err == nil || err == iterative.ErrRestartLimit {
+		t.Errorf("unexpected error at the iteration limit: %v", err)
+	}
+	if res.Stats.Restarts != maxRestarts {
+		t.Errorf("unexpected number of restarts at the iteration limit: want %d, got %d", maxRestarts, res.Stats.Restarts)
+	}
+
+	// A solve that converges within the limit.
+	p = market("nos4", 1e-8)
+	res, err = iterative.LinearSolve(p.A, p.B, &iterative.GMRES{Restart: 30, MaxRestarts: 20}, iterative.Settings{
+		Tolerance:     1e-8,
+		MaxIterations: 1000,
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if res.Stats.Restarts == 0 || res.Stats.Restarts > 20 {
+		t.Errorf("unexpected number of restarts: %d", res.Stats.Restarts)
+	}
+}
+
+// relativeResidual returns |b - A*x| / |b|.
+func relativeResidual(p *problem.Problem, x []float64) float64 {
+	r := make([]float64, p.Dim)
+	p.A.MatVec(r, x)
+	floats.Sub(r, p.B)
+	return floats.Norm(r, 2) / floats.Norm(p.B, 2)
+}
+
+func TestGMRESHouseholder(t *testing.T) {
+	// The solution errors on these matrices are
+	// too large to be checked, with the modified
+	// Gram-Schmidt process the residual norm
+	// estimated by GMRES drifts away from the
+	// true residual norm or the solve does not
+	// converge at all. mbeause is singular.
+	for _, name := range []string{
+		"mbeacxc",
+		"mbeaflw",
+		"gre_216b",
+		"lns__131",
+		"lnsp_131",
+		"nnc261",
+	} {
+		const tol = 1e-10
+		p := market(name, 0)
+		res, err := iterative.LinearSolve(p.A, p.B, &iterative.GMRES{Orthogonalization: iterative.Householder}, iterative.Settings{
+			Tolerance:     tol,
+			MaxIterations: p.MaxIterations,
 		})
 		if err != nil {
-			t.Errorf("Case %v (n=%v): unexpected error %v", tc.name, n, err)
+			t.Errorf("%s: unexpected error: %v", name, err)
 			continue
 		}
-		dist := floats.Distance(r.X, want, math.Inf(1))
-		if dist > tc.tol {
-			t.Errorf("Case %v (n=%v): unexpected solution, |want-got|=%v", tc.name, n, dist)
+		if r := relativeResidual(p, res.X); r > 10*tol {
+			t.Errorf("%s: relative residual norm %v exceeds %v", name, r, 10*tol)
 		}
 	}
 }
+
+func TestGMRESHouseholderReproducible(t *testing.T) {
+	p := market("gre__185", 0)
+	settings := iterative.Settings{Tolerance: 1e-12}
+	reused := &iterative.GMRES{Orthogonalization: iterative.Householder}
+	var want iterative.Result
+	for i := 0; i < 3; i++ {
+		method := reused
+		if i == 2 {
+			method = &iterative.GMRES{Orthogonalization: iterative.Householder}
+		}
+		res, err := iterative.LinearSolve(p.A, p.B, method, settings)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if i == 0 {
+			want = res
+			continue
+		}
+		if res.Stats.Iterations != want.Stats.Iterations {
+			t.Errorf("solve %d: unexpected number of iterations: want %d, got %d", i, want.Stats.Iterations, res.Stats.Iterations)
+		}
+		if math.Float64bits(res.Stats.ResidualNorm) != math.Float64bits(want.Stats.ResidualNorm) {
+			t.Errorf("solve %d: unexpected residual norm: want %v, got %v", i, want.Stats.ResidualNorm, res.Stats.ResidualNorm)
+		}
+		for j, v := range res.X {
+			if math.Float64bits(v) != math.Float64bits(want.X[j]) {
+				t.Errorf("solve %d: solution differs at %d: want %v, got %v", i, j, want.X[j], v)
+				break
+			}
+		}
+	}
+}
+
+func TestGMRESHouseholderRestart(t *testing.T) {
+	for _, test := range []struct {
+		p       *problem.Problem
+		restart int
+	}{
+		{market("nos4", 1e-8), 20},
+		{market("gre__115", 1e-8), 30},
+		{market("e05r0000", 1e-7), 50},
+	} {
+		p := test.p
+		settings := iterative.Settings{Tolerance: 1e-12}
+		want, err := p.Solve(&iterative.GMRES{Restart: test.restart}, settings)
+		if err != nil {
+			t.Errorf("ModifiedGramSchmidt: %v", err)
+			continue
+		}
+		got, err := p.Solve(&iterative.GMRES{Restart: test.restart, Orthogonalization: iterative.Householder}, settings)
+		if err != nil {
+			t.Errorf("Householder: %v", err)
+			continue
+		}
+		// On these well-conditioned matrices the
+		// two processes compute the same iterates
+		// up to rounding.
+		if got.Stats.Iterations != want.Stats.Iterations || got.Stats.Restarts != want.Stats.Restarts {
+			t.Errorf("%s: Householder needs %d iterations and %d restarts, ModifiedGramSchmidt %d and %d",
+				p.Name, got.Stats.Iterations, got.Stats.Restarts, want.Stats.Iterations, want.Stats.Restarts)
+		}
+	}
+
+	// The restart limit is reached after the
+	// same number of iterations.
+	const (
+		restart     = 5
+		maxRestarts = 3
+	)
+	p := market("gre__115", 1e-12)
+	res, err := iterative.LinearSolve(p.A, p.B, &iterative.GMRES{
+		Restart:           restart,
+		MaxRestarts:       maxRestarts,
+		Orthogonalization: iterative.Householder,
+	}, iterative.Settings{Tolerance: 1e-12})
+	if err != iterative.ErrRestartLimit {
+		t.Errorf("unexpected error: want %v, got %v", iterative.ErrRestartLimit, err)
+	}
+	if want := (maxRestarts + 1) * restart; res.Stats.Iterations != want {
+		t.Errorf("unexpected number of iterations: want %d, got %d", want, res.Stats.Iterations)
+	}
+}
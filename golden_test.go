@@ -0,0 +1,233 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// goldenFile holds the recorded convergence of the goldenCases.
+const goldenFile = "testdata/convergence.golden"
+
+// residualSlack is the relative difference from the recorded final
+// residual norm allowed in TestConvergenceGolden. The iteration and
+// MatVec counts must match exactly.
+const residualSlack = 1e-6
+
+// goldenCase is a fixed linear system solved by a method with fixed
+// settings whose convergence is recorded in goldenFile.
+type goldenCase struct {
+	name   string
+	method func() iterative.Method
+	a      iterative.MatrixOps
+	b      []float64
+	// diag is the diagonal of the matrix if
+	// the solve uses the Jacobi
+	// preconditioner.
+	diag []float64
+}
+
+// golden is the recorded convergence of a goldenCase.
+type golden struct {
+	converged          bool
+	iterations, matVec int
+	residual           float64
+}
+
+func goldenCases(t *testing.T) []goldenCase {
+	methods := []struct {
+		name string
+		new  func() iterative.Method
+		spd  bool // Method requires an SPD matrix.
+	}{
+		{"CG", func() iterative.Method { return &iterative.CG{} }, true},
+		{"BiCG", func() iterative.Method { return &iterative.BiCG{} }, false},
+		{"BiCGSTAB", func() iterative.Method { return &iterative.BiCGSTAB{} }, false},
+		{"GMRES", func() iterative.Method { return &iterative.GMRES{} }, false},
+		{"GMRES(20)", func() iterative.Method { return &iterative.GMRES{Restart: 20} }, false},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	systems := []struct {
+		p   *problem.Problem
+		spd bool
+	}{
+		{problem.Poisson2D(16, 16), true},
+		{problem.Poisson3D(8, 8, 8), true},
+		{problem.ConvectionDiffusion2D(16, 16, 5, 5), false},
+		{fromMatrixMarket(t, "nos4"), true},
+		{fromMatrixMarket(t, "bcsstm22"), true},
+		{fromMatrixMarket(t, "gre__115"), false},
+		{fromMatrixMarket(t, "west0067"), false},
+		{problem.RandomSparse(200, 5, 1e3, 0.5, rnd), false},
+	}
+
+	var cases []goldenCase
+	for _, s := range systems {
+		diag := diagonal(s.p.A, s.p.Dim)
+		for _, m := range methods {
+			if m.spd && !s.spd {
+				continue
+			}
+			cases = append(cases, goldenCase{
+				name:   m.name + "/" + s.p.Name,
+				method: m.new,
+				a:      s.p.A,
+				b:      s.p.B,
+			})
+			cases = append(cases, goldenCase{
+				name:   m.name + "/" + s.p.Name + "/Jacobi",
+				method: m.new,
+				a:      s.p.A,
+				b:      s.p.B,
+				diag:   diag,
+			})
+		}
+	}
+	return cases
+}
+
+// TestConvergenceGolden compares the number of iterations, the number of
+// MatVec operations and the final residual norm of a fixed set of solves
+// with the values recorded in testdata/convergence.golden. After an
+// intentional change of the behavior of a method, the file is updated by
+//  go test -run ConvergenceGolden -update
+func TestConvergenceGolden(t *testing.T) {
+	cases := goldenCases(t)
+	got := make(map[string]golden)
+	var buf bytes.Buffer
+	for _, tc := range cases {
+		settings := iterative.Settings{
+			Tolerance:     1e-8,
+			MaxIterations: 4 * len(tc.b),
+		}
+		if tc.diag != nil {
+			p := iterative.DiagonalInverse(tc.diag)
+			settings.PSolve = p.Apply
+			settings.PSolveTrans = p.ApplyTrans
+		}
+		// Failures to converge are recorded
+		// as well, a change of the error is
+		// a change of the behavior.
+		res, err := iterative.LinearSolve(tc.a, tc.b, tc.method(), settings)
+		g := golden{err == nil, res.Stats.Iterations, res.Stats.MatVec, res.Stats.ResidualNorm}
+		got[tc.name] = g
+		status := "converged"
+		if !g.converged {
+			status = "failed"
+		}
+		fmt.Fprintf(&buf, "%s %s %d %d %s\n", tc.name, status, g.iterations, g.matVec, strconv.FormatFloat(g.residual, 'g', -1, 64))
+	}
+
+	if *update {
+		err := os.WriteFile(goldenFile, buf.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := readGolden(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range cases {
+		g := got[tc.name]
+		w, ok := want[tc.name]
+		if !ok {
+			t.Errorf("%v: missing in %v, run with -update", tc.name, goldenFile)
+			continue
+		}
+		if g.converged != w.converged {
+			t.Errorf("%v: unexpected convergence: want %v, got %v", tc.name, w.converged, g.converged)
+		}
+		if g.iterations != w.iterations || g.matVec != w.matVec {
+			t.Errorf("%v: unexpected counts: want %v iterations and %v MatVecs, got %v and %v",
+				tc.name, w.iterations, w.matVec, g.iterations, g.matVec)
+		}
+		if !(math.Abs(g.residual-w.residual) <= residualSlack*w.residual) && !(math.IsNaN(g.residual) && math.IsNaN(w.residual)) {
+			t.Errorf("%v: unexpected residual norm: want %v, got %v", tc.name, w.residual, g.residual)
+		}
+		delete(want, tc.name)
+	}
+	for name := range want {
+		t.Errorf("%v: stale entry in %v, run with -update", name, goldenFile)
+	}
+}
+
+// readGolden reads the golden file at path. Each line holds the name of a
+// case, whether the solve converged or failed, the number of iterations,
+// the number of MatVec operations and the final residual norm separated by
+// spaces.
+func readGolden(path string) (map[string]golden, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]golden)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		var g golden
+		var err1, err2, err3 error
+		if len(f) == 5 {
+			g.converged = f[1] == "converged"
+			g.iterations, err1 = strconv.Atoi(f[2])
+			g.matVec, err2 = strconv.Atoi(f[3])
+			g.residual, err3 = strconv.ParseFloat(f[4], 64)
+		}
+		if len(f) != 5 || (f[1] != "converged" && f[1] != "failed") || err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("%s:%d: malformed line", path, line)
+		}
+		m[f[0]] = g
+	}
+	return m, s.Err()
+}
+
+// fromMatrixMarket returns the problem with the Matrix Market matrix name
+// from the testdata directory.
+func fromMatrixMarket(t *testing.T, name string) *problem.Problem {
+	p, err := problem.FromMatrixMarket("testdata/" + name + ".mtx.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// diagonal returns the diagonal of the n×n matrix a computed with MatVec.
+// Zero elements are replaced by one so that the diagonal can be used as
+// the Jacobi preconditioner.
+func diagonal(a iterative.MatrixOps, n int) []float64 {
+	d := make([]float64, n)
+	e := make([]float64, n)
+	col := make([]float64, n)
+	for j := range d {
+		e[j] = 1
+		a.MatVec(col, e)
+		e[j] = 0
+		d[j] = col[j]
+		if d[j] == 0 {
+			d[j] = 1
+		}
+	}
+	return d
+}
@@ -0,0 +1,390 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hb reads sparse matrices stored in the Harwell-Boeing and
+// Rutherford-Boeing exchange formats.
+package hb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+var (
+	errComplex     = errors.New("hb: complex matrices not supported")
+	errElemental   = errors.New("hb: elemental matrices not supported")
+	errUnsupported = errors.New("hb: matrix type not supported")
+	errRHS         = errors.New("hb: only full right-hand sides are supported")
+)
+
+// Header describes the matrix stored in a Harwell-Boeing file.
+type Header struct {
+	Title string
+	Key   string
+
+	// Type is the three letter matrix
+	// type, for example "RUA" for a real
+	// unsymmetric assembled matrix or "RSA"
+	// for a real symmetric one.
+	Type string
+
+	Rows, Cols int
+
+	// NNZ is the number of entries stored
+	// in the file. For symmetric and
+	// skew-symmetric matrices only the
+	// lower triangle is stored.
+	NNZ int
+
+	// NRHS is the number of right-hand
+	// sides stored after the matrix.
+	NRHS int
+
+	ptrFmt, indFmt, valFmt, rhsFmt format
+	rhsLines                       int
+}
+
+// Reader reads a matrix in the Harwell-Boeing format from an io.Reader.
+// Only real and pattern assembled matrices are supported. The Rutherford-
+// Boeing format, which has the same layout without the right-hand side
+// information, is also accepted.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+
+	h   Header
+	rhs [][]float64
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		s: bufio.NewScanner(r),
+	}
+}
+
+// Header returns the header of the file. It is valid only after Read has
+// been called.
+func (r *Reader) Header() Header {
+	return r.h
+}
+
+// RHS returns the right-hand sides stored in the file, if any. It is valid
+// only after Read has been called.
+func (r *Reader) RHS() [][]float64 {
+	return r.rhs
+}
+
+// Read reads the matrix. The elements of a pattern matrix are set to 1.
+// The lower triangle stored for a symmetric or skew-symmetric matrix is
+// mirrored, with the sign flipped in the skew-symmetric case. Full
+// right-hand sides that follow the matrix are read as well and returned by
+// RHS, initial guesses and exact solutions stored after them are ignored.
+func (r *Reader) Read() (*sparse.Triplet, error) {
+	err := r.header()
+	if err != nil {
+		return nil, err
+	}
+	h := &r.h
+
+	ptr, err := r.ints(h.Cols+1, h.ptrFmt)
+	if err != nil {
+		return nil, err
+	}
+	ind, err := r.ints(h.NNZ, h.indFmt)
+	if err != nil {
+		return nil, err
+	}
+	pattern := h.Type[0] == 'P'
+	var val []float64
+	if !pattern {
+		val, err = r.floats(h.NNZ, h.valFmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := sparse.NewTriplet(h.Rows, h.Cols)
+	if ptr[0] != 1 || ptr[h.Cols] != h.NNZ+1 {
+		return nil, fmt.Errorf("hb: bad column pointers")
+	}
+	for j := 0; j < h.Cols; j++ {
+		if ptr[j+1] < ptr[j] {
+			return nil, fmt.Errorf("hb: column pointers not increasing at column %d", j+1)
+		}
+		for k := ptr[j] - 1; k < ptr[j+1]-1; k++ {
+			i := ind[k] - 1
+			if i < 0 || h.Rows <= i {
+				return nil, fmt.Errorf("hb: row index %d out of range in column %d", ind[k], j+1)
+			}
+			v := 1.0
+			if !pattern {
+				v = val[k]
+			}
+			m.Append(i, j, v)
+			switch h.Type[1] {
+			case 'S':
+				if i != j {
+					m.Append(j, i, v)
+				}
+			case 'Z':
+				if i == j {
+					return nil, fmt.Errorf("hb: diagonal element in skew-symmetric matrix in column %d", j+1)
+				}
+				m.Append(j, i, -v)
+			}
+		}
+	}
+
+	r.rhs = make([][]float64, h.NRHS)
+	for k := range r.rhs {
+		r.rhs[k], err = r.floats(h.Rows, h.rhsFmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// header reads the four or five header lines.
+func (r *Reader) header() error {
+	h := &r.h
+	line, err := r.next()
+	if err != nil {
+		return err
+	}
+	h.Title = strings.TrimSpace(field(line, 0, 72))
+	h.Key = strings.TrimSpace(field(line, 72, 80))
+
+	line, err = r.next()
+	if err != nil {
+		return err
+	}
+	cards, err := r.lineInts(line, 5, 14)
+	if err != nil {
+		return err
+	}
+	h.rhsLines = cards[4]
+
+	line, err = r.next()
+	if err != nil {
+		return err
+	}
+	h.Type = strings.ToUpper(strings.TrimSpace(field(line, 0, 3)))
+	if len(h.Type) != 3 {
+		return r.errorf("bad matrix type %q", h.Type)
+	}
+	switch h.Type[0] {
+	case 'R', 'P':
+	case 'C':
+		return errComplex
+	default:
+		return r.errorf("bad matrix type %q", h.Type)
+	}
+	switch h.Type[1] {
+	case 'U', 'R', 'S', 'Z':
+	case 'H':
+		return errComplex
+	default:
+		return r.errorf("bad matrix type %q", h.Type)
+	}
+	switch h.Type[2] {
+	case 'A':
+	case 'E':
+		return errElemental
+	default:
+		return r.errorf("bad matrix type %q", h.Type)
+	}
+	dims, err := r.lineInts(field(line, 14, len(line)), 3, 14)
+	if err != nil {
+		return err
+	}
+	h.Rows, h.Cols, h.NNZ = dims[0], dims[1], dims[2]
+	if h.Rows < 0 || h.Cols < 0 || h.NNZ < 0 {
+		return r.errorf("negative dimension")
+	}
+	if (h.Type[1] == 'S' || h.Type[1] == 'Z') && h.Rows != h.Cols {
+		return r.errorf("symmetric matrix not square")
+	}
+
+	line, err = r.next()
+	if err != nil {
+		return err
+	}
+	fmts := []*format{&h.ptrFmt, &h.indFmt, &h.valFmt, &h.rhsFmt}
+	widths := []int{16, 16, 20, 20}
+	var start int
+	for k, f := range fmts {
+		s := strings.TrimSpace(field(line, start, start+widths[k]))
+		start += widths[k]
+		if s == "" && (k == 3 || (k == 2 && h.Type[0] == 'P')) {
+			continue
+		}
+		*f, err = parseFormat(s)
+		if err != nil {
+			return r.errorf("%v", err)
+		}
+	}
+
+	if h.rhsLines > 0 {
+		line, err = r.next()
+		if err != nil {
+			return err
+		}
+		typ := strings.ToUpper(strings.TrimSpace(field(line, 0, 3)))
+		if typ == "" || typ[0] != 'F' {
+			return errRHS
+		}
+		n, err := r.lineInts(field(line, 14, len(line)), 1, 14)
+		if err != nil {
+			return err
+		}
+		h.NRHS = n[0]
+	}
+	return nil
+}
+
+// format is a Fortran edit descriptor such as (10I8) or (1P,4D20.12),
+// describing count fields of the given width on each line.
+type format struct {
+	count, width int
+}
+
+var formatRE = regexp.MustCompile(`^\(\s*(?:[+-]?\d+P\s*,?\s*)?(\d*)\s*([IEDFG])\s*(\d+)(?:\.\d+)?(?:E\d+)?\s*\)$`)
+
+func parseFormat(s string) (format, error) {
+	m := formatRE.FindStringSubmatch(strings.ToUpper(s))
+	if m == nil {
+		return format{}, fmt.Errorf("unsupported format %q", s)
+	}
+	f := format{count: 1}
+	if m[1] != "" {
+		f.count, _ = strconv.Atoi(m[1])
+	}
+	f.width, _ = strconv.Atoi(m[3])
+	if f.count < 1 || f.width < 1 {
+		return format{}, fmt.Errorf("unsupported format %q", s)
+	}
+	return f, nil
+}
+
+// ints reads n integers stored in the format f.
+func (r *Reader) ints(n int, f format) ([]int, error) {
+	v := make([]int, 0, n)
+	err := r.fields(n, f, func(tok string) error {
+		i, err := strconv.Atoi(tok)
+		if err != nil {
+			return r.errorf("bad integer %q", tok)
+		}
+		v = append(v, i)
+		return nil
+	})
+	return v, err
+}
+
+// floats reads n real numbers stored in the format f.
+func (r *Reader) floats(n int, f format) ([]float64, error) {
+	v := make([]float64, 0, n)
+	err := r.fields(n, f, func(tok string) error {
+		x, err := parseFloat(tok)
+		if err != nil {
+			return r.errorf("bad number %q", tok)
+		}
+		v = append(v, x)
+		return nil
+	})
+	return v, err
+}
+
+// fields reads n fixed-width fields in the format f from consecutive lines
+// and calls fn for each of them.
+func (r *Reader) fields(n int, f format, fn func(tok string) error) error {
+	for n > 0 {
+		line, err := r.next()
+		if err != nil {
+			return err
+		}
+		for k := 0; k < f.count && n > 0; k++ {
+			tok := strings.TrimSpace(field(line, k*f.width, (k+1)*f.width))
+			if tok == "" {
+				// Blank fields are zero in Fortran input.
+				tok = "0"
+			}
+			if err := fn(tok); err != nil {
+				return err
+			}
+			n--
+		}
+	}
+	return nil
+}
+
+// parseFloat parses a Fortran real number which can have the exponent
+// marked by D instead of E or have no exponent letter at all, as in
+// 1.5-300.
+func parseFloat(s string) (float64, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == 'D' || r == 'd' {
+			return 'E'
+		}
+		return r
+	}, s)
+	if !strings.ContainsAny(s, "Ee") {
+		if k := strings.LastIndexAny(s, "+-"); k > 0 {
+			s = s[:k] + "E" + s[k:]
+		}
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// lineInts parses n integers of the given width from the start of line.
+// Missing trailing fields are zero.
+func (r *Reader) lineInts(line string, n, width int) ([]int, error) {
+	v := make([]int, n)
+	for k := range v {
+		tok := strings.TrimSpace(field(line, k*width, (k+1)*width))
+		if tok == "" {
+			continue
+		}
+		i, err := strconv.Atoi(tok)
+		if err != nil {
+			return nil, r.errorf("bad integer %q", tok)
+		}
+		v[k] = i
+	}
+	return v, nil
+}
+
+// field returns line[start:end] clipped to the length of line.
+func field(line string, start, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	return line[start:end]
+}
+
+func (r *Reader) next() (string, error) {
+	if !r.s.Scan() {
+		if err := r.s.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("hb: unexpected end of file after line %d", r.line)
+	}
+	r.line++
+	return r.s.Text(), nil
+}
+
+func (r *Reader) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("hb: line %d: %s", r.line, fmt.Sprintf(format, args...))
+}
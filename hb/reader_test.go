@@ -0,0 +1,177 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hb
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func readFile(t *testing.T, name string) (*sparse.CSR, *Reader) {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := NewReader(f)
+	m, err := r.Read()
+	if err != nil {
+		t.Fatalf("%v: unexpected error %v", name, err)
+	}
+	return sparse.NewCSRFromTriplet(m), r
+}
+
+func TestReadAgainstMarket(t *testing.T) {
+	for _, test := range []struct {
+		hb, mtx string
+		typ     string
+		nrhs    int
+	}{
+		{"unsym.rua", "unsym.mtx", "RUA", 1},
+		{"sym.rsa", "sym.mtx", "RSA", 0},
+	} {
+		got, r := readFile(t, test.hb)
+		want, err := mmarket.LoadMatrix(filepath.Join("testdata", test.mtx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := r.Header()
+		if h.Type != test.typ || h.NRHS != test.nrhs || len(r.RHS()) != test.nrhs {
+			t.Errorf("%v: unexpected header %+v", test.hb, h)
+		}
+		if got.NNZ() != want.NNZ() {
+			t.Errorf("%v: unexpected NNZ, want %v, got %v", test.hb, want.NNZ(), got.NNZ())
+		}
+		rows, cols := want.Dims()
+		if r, c := got.Dims(); r != rows || c != cols {
+			t.Errorf("%v: unexpected dimensions %v×%v", test.hb, r, c)
+			continue
+		}
+		for i := 0; i < rows; i++ {
+			for j := 0; j < cols; j++ {
+				if got.At(i, j) != want.At(i, j) {
+					t.Errorf("%v: unexpected element at (%v,%v), want %v, got %v", test.hb, i, j, want.At(i, j), got.At(i, j))
+				}
+			}
+		}
+	}
+
+	// The right-hand side of unsym.rua is A*[1 2 3 4 5].
+	a, r := readFile(t, "unsym.rua")
+	want := make([]float64, 5)
+	a.MulVec(want, []float64{1, 2, 3, 4, 5})
+	for i, v := range r.RHS()[0] {
+		if math.Abs(v-want[i]) > 1e-15*math.Max(1, math.Abs(want[i])) {
+			t.Errorf("unexpected right-hand side element %v, want %v, got %v", i, want[i], v)
+		}
+	}
+}
+
+func TestReadPattern(t *testing.T) {
+	got, r := readFile(t, "pattern.rb")
+	if r.Header().Title != "Rectangular pattern matrix" || r.Header().Key != "PAT" {
+		t.Errorf("unexpected title or key in %+v", r.Header())
+	}
+	want := [][]float64{
+		{1, 0, 0, 1},
+		{0, 1, 0, 0},
+		{1, 0, 1, 0},
+	}
+	for i, row := range want {
+		for j, v := range row {
+			if got.At(i, j) != v {
+				t.Errorf("unexpected element at (%v,%v), want %v, got %v", i, j, v, got.At(i, j))
+			}
+		}
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want float64
+	}{
+		{"1.5", 1.5},
+		{"1.5E+02", 150},
+		{"1.5D+02", 150},
+		{"-2.5d-3", -2.5e-3},
+		{"1.5+002", 150},
+		{"-1.5-002", -1.5e-2},
+		{".5", 0.5},
+	} {
+		got, err := parseFloat(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("%q: want %v, got %v (err %v)", test.s, test.want, got, err)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want format
+	}{
+		{"(10I8)", format{10, 8}},
+		{"(16I5)", format{16, 5}},
+		{"(1P,4D20.12)", format{4, 20}},
+		{"(1P4E20.12)", format{4, 20}},
+		{"(3e26.16)", format{3, 26}},
+		{"(E16.8)", format{1, 16}},
+		{"(5E15.8E3)", format{5, 15}},
+	} {
+		got, err := parseFormat(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("%q: want %+v, got %+v (err %v)", test.s, test.want, got, err)
+		}
+	}
+	if _, err := parseFormat("(4A20)"); err == nil {
+		t.Errorf("missing error for unsupported format")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	const (
+		title = "Title                                                                   KEY\n"
+		cards = "             3             1             1             1             0\n"
+		fmts  = "(3I8)           (3I8)           (3E20.12)\n"
+	)
+	for _, test := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", "hb: unexpected end of file after line 0"},
+		{"complex", title + cards + "CUA                        2             2             2\n", "hb: complex matrices not supported"},
+		{"elemental", title + cards + "RUE                        2             2             2\n", "hb: elemental matrices not supported"},
+		{"bad type", title + cards + "RXA                        2             2             2\n", `hb: line 3: bad matrix type "RXA"`},
+		{"bad format", title + cards + "RUA                        2             2             2\n(3A8)\n", `hb: line 4: unsupported format "(3A8)"`},
+		{
+			"truncated",
+			title + cards + "RUA                        2             2             2\n" + fmts + "       1       2       3\n",
+			"hb: unexpected end of file after line 5",
+		},
+		{
+			"bad index",
+			title + cards + "RUA                        2             2             2\n" + fmts + "       1       2       3\n       1       x\n",
+			`hb: line 6: bad integer "x"`,
+		},
+		{
+			"index out of range",
+			title + cards + "RUA                        2             2             2\n" + fmts + "       1       2       3\n       1       3\n                 1.0                 2.0\n",
+			"hb: row index 3 out of range in column 2",
+		},
+	} {
+		_, err := NewReader(strings.NewReader(test.input)).Read()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%v: unexpected error, want %v, got %v", test.name, test.want, err)
+		}
+	}
+}
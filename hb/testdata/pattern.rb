@@ -0,0 +1,6 @@
+Rectangular pattern matrix                                              PAT     
+             2             1             1             0
+PRA                        3             4             5             0
+(8I3)           (8I3)
+  1  3  4  5  6
+  1  3  2  3  1
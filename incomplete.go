@@ -0,0 +1,478 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// errTimeBudget is returned by the incomplete factorizations when they do
+// not finish before their deadline.
+var errTimeBudget = errors.New("iterative: time budget exceeded")
+
+// pivotTol is the relative size of the smallest pivot accepted by the
+// incomplete factorizations. A pivot smaller than pivotTol times the
+// largest element of its row is a breakdown.
+const pivotTol = 1e-10
+
+// deadlineCheck is the number of rows factorized between the checks of the
+// deadline.
+const deadlineCheck = 64
+
+// expired returns whether the non-zero deadline has passed. It is checked
+// only every deadlineCheck rows to keep the cost of time.Now low.
+func expired(i int, deadline time.Time) bool {
+	return i%deadlineCheck == 0 && !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// PivotError is returned by the incomplete factorizations when a pivot is
+// small relative to the largest element of its row or, for IC0, when it is
+// not positive. Shifting the diagonal of the matrix, for example with the
+// Perturb method of the factorization, may avoid the breakdown.
+type PivotError struct {
+	// Factorization is the name of
+	// the factorization.
+	Factorization string
+	// Row is the row of the pivot.
+	Row int
+	// Pivot is the value of the pivot.
+	Pivot float64
+}
+
+func (e *PivotError) Error() string {
+	return fmt.Sprintf("%s breakdown: pivot %v in row %d", e.Factorization, e.Pivot, e.Row)
+}
+
+// factorCSR is the storage of an incomplete factorization without fill-in
+// of a square matrix A. The factors overwrite a copy of the elements of A
+// in its sparsity pattern, and the original values are kept so that the
+// factorization can be recomputed with a shifted diagonal.
+type factorCSR struct {
+	name  string
+	lower bool
+	n     int
+
+	indptr []int
+	ind    []int
+	// data holds the factors.
+	data []float64
+	// a holds the elements of A.
+	a []float64
+	// diag[i] is the position of the diagonal
+	// element in row i.
+	diag []int
+	// pos is the workspace of the factorization
+	// mapping the columns of the current row to
+	// the positions of its elements or -1.
+	pos []int
+
+	// m is the matrix of the factors as a
+	// sparse.CSR for the triangular solves. It
+	// shares the storage of ind and data.
+	m *sparse.CSR
+}
+
+// init sets the sparsity pattern of the factorization to that of a, or of
+// its lower triangle if lower is true, and copies the elements of a. It
+// returns an error if a diagonal element of a is not stored.
+func (f *factorCSR) init(a *sparse.CSR) error {
+	n, c := a.Dims()
+	if n != c {
+		panic(f.name + ": matrix not square")
+	}
+	*f = factorCSR{
+		name:   f.name,
+		lower:  f.lower,
+		n:      n,
+		indptr: make([]int, n+1),
+		diag:   make([]int, n),
+		pos:    make([]int, n),
+	}
+	var rows []int
+	for i := 0; i < n; i++ {
+		ind, data := a.RowView(i)
+		f.diag[i] = -1
+		f.pos[i] = -1
+		for k, j := range ind {
+			if f.lower && j > i {
+				break
+			}
+			if j == i {
+				f.diag[i] = len(f.ind)
+			}
+			rows = append(rows, i)
+			f.ind = append(f.ind, j)
+			f.a = append(f.a, data[k])
+		}
+		if f.diag[i] < 0 {
+			f.m = nil
+			return fmt.Errorf("%s: diagonal element %d not stored", f.name, i)
+		}
+		f.indptr[i+1] = len(f.ind)
+	}
+	f.data = make([]float64, len(f.a))
+	// The elements are already sorted, so ind and
+	// data become the storage of m unchanged.
+	f.m = sparse.NewCSRInPlace(n, n, rows, f.ind, f.data)
+	return nil
+}
+
+// load copies the elements of a into the factorization. It returns an
+// error and leaves the factorization unchanged if the sparsity pattern of a
+// differs from that of the factorization.
+func (f *factorCSR) load(a *sparse.CSR) error {
+	if r, c := a.Dims(); r != f.n || c != f.n {
+		panic(f.name + ": dimension mismatch")
+	}
+	for i := 0; i < f.n; i++ {
+		ind, _ := f.row(a, i)
+		start, end := f.indptr[i], f.indptr[i+1]
+		if len(ind) != end-start {
+			return fmt.Errorf("%s: sparsity pattern of row %d changed", f.name, i)
+		}
+		for k, j := range ind {
+			if f.ind[start+k] != j {
+				return fmt.Errorf("%s: sparsity pattern of row %d changed", f.name, i)
+			}
+		}
+	}
+	for i := 0; i < f.n; i++ {
+		_, data := f.row(a, i)
+		copy(f.a[f.indptr[i]:f.indptr[i+1]], data)
+	}
+	return nil
+}
+
+// row returns the elements of row i of a in the factorization, only those
+// in the lower triangle if it is lower triangular.
+func (f *factorCSR) row(a *sparse.CSR, i int) (ind []int, data []float64) {
+	ind, data = a.RowView(i)
+	if f.lower {
+		k := 0
+		for k < len(ind) && ind[k] <= i {
+			k++
+		}
+		ind, data = ind[:k], data[:k]
+	}
+	return ind, data
+}
+
+// reset copies the elements of A into the factors and adds shift to the
+// diagonal if it is not nil.
+func (f *factorCSR) reset(shift []float64) {
+	copy(f.data, f.a)
+	if shift == nil {
+		return
+	}
+	if len(shift) != f.n {
+		panic(f.name + ": dimension mismatch")
+	}
+	for i, s := range shift {
+		f.data[f.diag[i]] += s
+	}
+}
+
+// rowMax returns the largest magnitude of the elements of row i of the
+// factors and sets the positions of its columns in pos.
+func (f *factorCSR) rowMax(i int) float64 {
+	var rmax float64
+	for p := f.indptr[i]; p < f.indptr[i+1]; p++ {
+		f.pos[f.ind[p]] = p
+		rmax = math.Max(rmax, math.Abs(f.data[p]))
+	}
+	return rmax
+}
+
+// clearRow resets the positions of the columns of row i in pos.
+func (f *factorCSR) clearRow(i int) {
+	for p := f.indptr[i]; p < f.indptr[i+1]; p++ {
+		f.pos[f.ind[p]] = -1
+	}
+}
+
+// IC0 is the incomplete Cholesky factorization
+//  A ≈ L L^T
+// without fill-in of a symmetric positive definite matrix A, where L has
+// the sparsity pattern of the lower triangle of A. IC0 is a symmetric
+// positive definite Preconditioner for CG and the other methods for
+// symmetric positive definite systems.
+//
+// The zero value is an empty factorization whose first call to Refresh
+// sets its sparsity pattern. Refresh and Perturb recompute the numerical
+// factorization in the same pattern, which avoids most of the allocation
+// and setup when a sequence of matrices with the same pattern is
+// factorized, for example in a time-dependent simulation.
+type IC0 struct {
+	l factorCSR
+}
+
+// NewIC0 returns the incomplete Cholesky factorization of the symmetric
+// matrix a computed from its lower triangle. It returns an error if a
+// diagonal element of a is not stored, and a *PivotError if a pivot is not
+// positive, which happens if a is not positive definite and may happen
+// also if it is. NewIC0 panics if a is not square.
+func NewIC0(a *sparse.CSR) (*IC0, error) {
+	var p IC0
+	if err := p.Refresh(a); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Refresh recomputes the factorization for the matrix a. If p is not empty,
+// a must have the sparsity pattern of the matrix for which p was computed
+// and only the numerical factorization is redone, otherwise Refresh returns
+// an error. If the factorization breaks down, Refresh returns a
+// *PivotError, p keeps the elements of a and can be recomputed with a
+// shifted diagonal by Perturb.
+func (p *IC0) Refresh(a *sparse.CSR) error {
+	return p.refresh(a, time.Time{})
+}
+
+// refresh is Refresh that returns errTimeBudget if the non-zero deadline
+// passes before the factorization is finished.
+func (p *IC0) refresh(a *sparse.CSR, deadline time.Time) error {
+	var err error
+	if p.l.m == nil {
+		p.l.name = "IC(0)"
+		p.l.lower = true
+		err = p.l.init(a)
+	} else {
+		err = p.l.load(a)
+	}
+	if err != nil {
+		return err
+	}
+	return p.factorize(nil, deadline)
+}
+
+// Perturb recomputes the factorization for the matrix
+//  A + diag(diagShift),
+// where A is the matrix of the last call to Refresh. It is cheaper than
+// Refresh for changes of the diagonal only, and a positive shift is the
+// usual remedy for a breakdown on small or negative pivots. It returns a
+// *PivotError if the factorization breaks down. Perturb panics if p is
+// empty or the length of diagShift is not the dimension of A.
+func (p *IC0) Perturb(diagShift []float64) error {
+	if p.l.m == nil {
+		panic("IC(0): empty factorization")
+	}
+	return p.factorize(diagShift, time.Time{})
+}
+
+func (p *IC0) factorize(shift []float64, deadline time.Time) error {
+	l := &p.l
+	l.reset(shift)
+	for i := 0; i < l.n; i++ {
+		if expired(i, deadline) {
+			return errTimeBudget
+		}
+		start := l.indptr[i]
+		rowMax := l.rowMax(i)
+		// l[i,k] = (a[i,k] - sum_{j<k} l[i,j] l[k,j]) / l[k,k]
+		for q := start; q < l.diag[i]; q++ {
+			k := l.ind[q]
+			s := l.data[q]
+			for r := l.indptr[k]; r < l.diag[k]; r++ {
+				if t := l.pos[l.ind[r]]; t >= 0 {
+					s -= l.data[t] * l.data[r]
+				}
+			}
+			l.data[q] = s / l.data[l.diag[k]]
+		}
+		// l[i,i] = sqrt(a[i,i] - sum_{j<i} l[i,j]^2)
+		d := l.data[l.diag[i]]
+		for q := start; q < l.diag[i]; q++ {
+			d -= l.data[q] * l.data[q]
+		}
+		l.clearRow(i)
+		if d <= pivotTol*rowMax || math.IsNaN(d) {
+			return &PivotError{Factorization: l.name, Row: i, Pivot: d}
+		}
+		l.data[l.diag[i]] = math.Sqrt(d)
+	}
+	return nil
+}
+
+// Apply implements the Preconditioner interface.
+func (p *IC0) Apply(dst, rhs []float64) error {
+	checkLen(dst, rhs, p.l.n)
+	// Solve L y = rhs.
+	if err := sparse.SolveLower(dst, rhs, p.l.m, false); err != nil {
+		return err
+	}
+	// Solve L^T z = y.
+	return sparse.SolveLowerTrans(dst, dst, p.l.m, false)
+}
+
+// ApplyTrans implements the Preconditioner interface. Since L L^T is
+// symmetric, ApplyTrans is equivalent to Apply.
+func (p *IC0) ApplyTrans(dst, rhs []float64) error {
+	return p.Apply(dst, rhs)
+}
+
+// ILU0 is the incomplete LU factorization
+//  A ≈ L U
+// without fill-in of a square matrix A, where L is unit lower triangular
+// and L and U have the sparsity pattern of the lower and the upper triangle
+// of A, respectively. The factorization does not pivot, so the diagonal of
+// A must be stored and the quality of the factorization depends on the
+// ordering of A, see sparse.RCM.
+//
+// The zero value is an empty factorization whose first call to Refresh
+// sets its sparsity pattern. Refresh and Perturb recompute the numerical
+// factorization in the same pattern, which avoids most of the allocation
+// and setup when a sequence of matrices with the same pattern is
+// factorized, for example in a time-dependent simulation.
+type ILU0 struct {
+	lu factorCSR
+}
+
+// NewILU0 returns the incomplete LU factorization of a. It returns an
+// error if a diagonal element of a is not stored, and a *PivotError if a
+// pivot is small relative to its row. NewILU0 panics if a is not square.
+func NewILU0(a *sparse.CSR) (*ILU0, error) {
+	var p ILU0
+	if err := p.Refresh(a); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Refresh recomputes the factorization for the matrix a. If p is not empty,
+// a must have the sparsity pattern of the matrix for which p was computed
+// and only the numerical factorization is redone, otherwise Refresh returns
+// an error. If the factorization breaks down, Refresh returns a
+// *PivotError, p keeps the elements of a and can be recomputed with a
+// shifted diagonal by Perturb.
+func (p *ILU0) Refresh(a *sparse.CSR) error {
+	return p.refresh(a, time.Time{})
+}
+
+// refresh is Refresh that returns errTimeBudget if the non-zero deadline
+// passes before the factorization is finished.
+func (p *ILU0) refresh(a *sparse.CSR, deadline time.Time) error {
+	var err error
+	if p.lu.m == nil {
+		p.lu.name = "ILU(0)"
+		err = p.lu.init(a)
+	} else {
+		err = p.lu.load(a)
+	}
+	if err != nil {
+		return err
+	}
+	return p.factorize(nil, deadline)
+}
+
+// Perturb recomputes the factorization for the matrix
+//  A + diag(diagShift),
+// where A is the matrix of the last call to Refresh. It is cheaper than
+// Refresh for changes of the diagonal only, and a shift of zero or small
+// pivots is the usual remedy for a breakdown. It returns a *PivotError if
+// the factorization breaks down. Perturb panics if p is empty or the length
+// of diagShift is not the dimension of A.
+func (p *ILU0) Perturb(diagShift []float64) error {
+	if p.lu.m == nil {
+		panic("ILU(0): empty factorization")
+	}
+	return p.factorize(diagShift, time.Time{})
+}
+
+func (p *ILU0) factorize(shift []float64, deadline time.Time) error {
+	lu := &p.lu
+	lu.reset(shift)
+	for i := 0; i < lu.n; i++ {
+		if expired(i, deadline) {
+			return errTimeBudget
+		}
+		rowMax := lu.rowMax(i)
+		for q := lu.indptr[i]; q < lu.diag[i]; q++ {
+			// l[i,k] = a[i,k] / u[k,k]
+			k := lu.ind[q]
+			lik := lu.data[q] / lu.data[lu.diag[k]]
+			lu.data[q] = lik
+			// a[i,j] -= l[i,k] u[k,j] for j>k
+			// in the pattern of row i.
+			for r := lu.diag[k] + 1; r < lu.indptr[k+1]; r++ {
+				if t := lu.pos[lu.ind[r]]; t >= 0 {
+					lu.data[t] -= lik * lu.data[r]
+				}
+			}
+		}
+		lu.clearRow(i)
+		if d := lu.data[lu.diag[i]]; !(math.Abs(d) > pivotTol*rowMax) {
+			return &PivotError{Factorization: lu.name, Row: i, Pivot: d}
+		}
+	}
+	return nil
+}
+
+// Apply implements the Preconditioner interface.
+func (p *ILU0) Apply(dst, rhs []float64) error {
+	checkLen(dst, rhs, p.lu.n)
+	// Solve L y = rhs.
+	if err := sparse.SolveLower(dst, rhs, p.lu.m, true); err != nil {
+		return err
+	}
+	// Solve U z = y.
+	return sparse.SolveUpper(dst, dst, p.lu.m, false)
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p *ILU0) ApplyTrans(dst, rhs []float64) error {
+	checkLen(dst, rhs, p.lu.n)
+	// Solve U^T y = rhs.
+	if err := sparse.SolveUpperTrans(dst, rhs, p.lu.m, false); err != nil {
+		return err
+	}
+	// Solve L^T z = y.
+	return sparse.SolveLowerTrans(dst, dst, p.lu.m, true)
+}
+
+// Staleness is a heuristic for deciding when a preconditioner that is
+// reused for a sequence of slowly varying matrices should be recomputed. It
+// compares the number of iterations of the latest solve with the number of
+// iterations of the first solve after the preconditioner was computed, the
+// growth of which measures how much the preconditioner has deteriorated
+// for the current matrix.
+type Staleness struct {
+	// Ratio is the growth of the number of
+	// iterations above which the
+	// preconditioner is stale. If it is
+	// zero, 1.5 is used.
+	Ratio float64
+
+	base int
+}
+
+// Refreshed records the number of iterations of the first solve with a
+// newly computed preconditioner.
+func (s *Staleness) Refreshed(iterations int) {
+	if iterations < 0 {
+		panic("iterative: negative number of iterations")
+	}
+	s.base = iterations
+}
+
+// Stale returns whether a solve that needed the given number of iterations
+// indicates that the preconditioner should be recomputed, that is, whether
+// the number of iterations exceeds Ratio times the number recorded by
+// Refreshed.
+func (s *Staleness) Stale(iterations int) bool {
+	ratio := s.Ratio
+	if ratio == 0 {
+		ratio = 1.5
+	}
+	if ratio < 1 {
+		panic("iterative: staleness ratio less than 1")
+	}
+	return float64(iterations) > ratio*float64(max(s.base, 1))
+}
@@ -0,0 +1,388 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat"
+)
+
+// incomplete is an incomplete factorization with an update path.
+type incomplete interface {
+	iterative.Preconditioner
+	Refresh(a *sparse.CSR) error
+	Perturb(diagShift []float64) error
+}
+
+func newIncomplete(a *sparse.CSR, chol bool) (incomplete, error) {
+	if chol {
+		return iterative.NewIC0(a)
+	}
+	return iterative.NewILU0(a)
+}
+
+// applyEqual reports whether p and q give identical results for the
+// vectors x.
+func applyEqual(p, q iterative.Preconditioner, x [][]float64) bool {
+	n := len(x[0])
+	px := make([]float64, n)
+	qx := make([]float64, n)
+	for _, v := range x {
+		p.Apply(px, v)
+		q.Apply(qx, v)
+		if !floats.Equal(px, qx) {
+			return false
+		}
+		p.ApplyTrans(px, v)
+		q.ApplyTrans(qx, v)
+		if !floats.Equal(px, qx) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIncompleteExact(t *testing.T) {
+	// The incomplete factorizations of
+	// tridiagonal matrices have no fill-in
+	// so they are exact.
+	const n = 50
+	rnd := rand.New(rand.NewSource(1))
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	for _, test := range []struct {
+		a    *sparse.CSR
+		chol bool
+	}{
+		{tridiagonalCSR(n, -1, 2, -1), true},
+		{tridiagonalCSR(n, -1, 2, -1), false},
+		{tridiagonalCSR(n, -1.5, 2, -0.5), false},
+	} {
+		p, err := newIncomplete(test.a, test.chol)
+		if err != nil {
+			t.Fatalf("chol=%t: unexpected error: %v", test.chol, err)
+		}
+		ax := make([]float64, n)
+		got := make([]float64, n)
+		test.a.MulVec(ax, x)
+		if err := p.Apply(got, ax); err != nil || !floats.EqualApprox(got, x, 1e-10) {
+			t.Errorf("chol=%t: Apply not the inverse", test.chol)
+		}
+		test.a.MulTransVec(ax, x)
+		if err := p.ApplyTrans(got, ax); err != nil || !floats.EqualApprox(got, x, 1e-10) {
+			t.Errorf("chol=%t: ApplyTrans not the inverse", test.chol)
+		}
+	}
+
+	ic, err := iterative.NewIC0(testmat.Poisson2D(20, 20).CSR())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := iterative.CheckSymmetricPreconditioner(ic, 400, rnd); err != nil {
+		t.Errorf("IC0: %v", err)
+	}
+}
+
+func TestIncompleteRefresh(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, chol := range []bool{true, false} {
+		name := fmt.Sprintf("chol=%t", chol)
+		// A sequence of matrices with the same
+		// pattern: a diffusion with a growing
+		// wind, or with a shift decreasing like
+		// the inverse of a growing time step.
+		const steps = 6
+		seq := make([]*sparse.CSR, steps)
+		base := testmat.Poisson2D(30, 30).CSR()
+		n, _ := base.Dims()
+		for k := range seq {
+			if chol {
+				shift := make([]float64, n)
+				for i := range shift {
+					shift[i] = 2 / math.Pow(2, float64(k))
+				}
+				seq[k] = sparse.AddDiagonal(base, shift)
+			} else {
+				seq[k] = testmat.ConvectionDiffusion2D(30, 30, float64(k), 0.5).CSR()
+			}
+		}
+		x := make([][]float64, 3)
+		for i := range x {
+			x[i] = make([]float64, n)
+			for j := range x[i] {
+				x[i][j] = rnd.NormFloat64()
+			}
+		}
+
+		p, err := newIncomplete(seq[0], chol)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		stale, _ := newIncomplete(seq[0], chol)
+		var staleness iterative.Staleness
+		var refreshed, kept int
+		for k, a := range seq {
+			if err := p.Refresh(a); err != nil {
+				t.Fatalf("%s,step=%d: unexpected error from Refresh: %v", name, k, err)
+			}
+			fresh, err := newIncomplete(a, chol)
+			if err != nil {
+				t.Fatalf("%s,step=%d: unexpected error: %v", name, k, err)
+			}
+			if !applyEqual(p, fresh, x) {
+				t.Errorf("%s,step=%d: refreshed factorization differs from a new one", name, k)
+			}
+
+			b := make([]float64, n)
+			a.MulVec(b, x[0])
+			iters := func(m iterative.Preconditioner) int {
+				ops := iterative.MatrixOps{MatVec: a.MulVec}
+				settings := iterative.Settings{Tolerance: 1e-8, PSolve: m.Apply}
+				var method iterative.Method = &iterative.GMRES{}
+				if chol {
+					method = &iterative.CG{}
+				}
+				res, err := iterative.LinearSolve(ops, b, method, settings)
+				if err != nil {
+					t.Fatalf("%s,step=%d: unexpected error from solve: %v", name, k, err)
+				}
+				return res.Stats.Iterations
+			}
+			refreshed = iters(p)
+			kept = iters(stale)
+			if refreshed > kept {
+				t.Errorf("%s,step=%d: refreshed preconditioner needs %d iterations, stale %d", name, k, refreshed, kept)
+			}
+			if k == 0 {
+				staleness.Refreshed(kept)
+			}
+		}
+		if kept <= refreshed {
+			t.Errorf("%s: stale preconditioner needs %d iterations in the last step, refreshed %d", name, kept, refreshed)
+		}
+		if !staleness.Stale(kept) {
+			t.Errorf("%s: preconditioner not stale with %d iterations", name, kept)
+		}
+	}
+}
+
+func TestIncompletePerturb(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, chol := range []bool{true, false} {
+		name := fmt.Sprintf("chol=%t", chol)
+		a := testmat.ConvectionDiffusion2D(15, 10, 1, 0.5).CSR()
+		if chol {
+			a = testmat.Poisson2D(15, 10).CSR()
+		}
+		n, _ := a.Dims()
+		shift := make([]float64, n)
+		for i := range shift {
+			shift[i] = rnd.Float64()
+		}
+		p, err := newIncomplete(a, chol)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if err := p.Perturb(shift); err != nil {
+			t.Fatalf("%s: unexpected error from Perturb: %v", name, err)
+		}
+		want, err := newIncomplete(sparse.AddDiagonal(a, shift), chol)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		x := [][]float64{make([]float64, n)}
+		for i := range x[0] {
+			x[0][i] = rnd.NormFloat64()
+		}
+		if !applyEqual(p, want, x) {
+			t.Errorf("%s: perturbed factorization differs from that of the shifted matrix", name)
+		}
+
+		// A matrix with another pattern is
+		// rejected.
+		other := testmat.Poisson2D(10, 15).CSR()
+		if err := p.Refresh(other); err == nil {
+			t.Errorf("%s: no error from Refresh with another pattern", name)
+		}
+		if !applyEqual(p, want, x) {
+			t.Errorf("%s: factorization changed by a failed Refresh", name)
+		}
+	}
+}
+
+func TestIncompleteBreakdown(t *testing.T) {
+	// Symmetric with a positive diagonal
+	// but indefinite.
+	indef := tridiagonalCSR(10, -1.5, 1, -1.5)
+	// Unsymmetric and singular with a zero
+	// pivot in ILU(0).
+	tr := sparse.NewTriplet(2, 2)
+	tr.Append(0, 0, 1)
+	tr.Append(0, 1, 2)
+	tr.Append(1, 0, 3)
+	tr.Append(1, 1, 6)
+	singular := sparse.NewCSRFromTriplet(tr)
+
+	for _, test := range []struct {
+		name  string
+		p     interface{ Refresh(*sparse.CSR) error }
+		a     *sparse.CSR
+		row   int
+		shift float64
+	}{
+		{"IC(0)", &iterative.IC0{}, indef, 1, 2},
+		{"ILU(0)", &iterative.ILU0{}, singular, 1, 1},
+	} {
+		err := test.p.Refresh(test.a)
+		var pe *iterative.PivotError
+		if !errors.As(err, &pe) || pe.Factorization != test.name || pe.Row != test.row {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		// Shifting the diagonal avoids the
+		// breakdown.
+		n, _ := test.a.Dims()
+		shift := make([]float64, n)
+		for i := range shift {
+			shift[i] = test.shift
+		}
+		if err := test.p.(incomplete).Perturb(shift); err != nil {
+			t.Errorf("%s: unexpected error from Perturb: %v", test.name, err)
+		}
+	}
+
+	tr = sparse.NewTriplet(2, 2)
+	tr.Append(0, 0, 1)
+	tr.Append(1, 0, 1)
+	if _, err := iterative.NewILU0(sparse.NewCSRFromTriplet(tr)); err == nil {
+		t.Errorf("no error with a diagonal element not stored")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Perturb of an empty factorization did not panic")
+			}
+		}()
+		(&iterative.ILU0{}).Perturb(nil)
+	}()
+}
+
+func TestStaleness(t *testing.T) {
+	var s iterative.Staleness
+	s.Refreshed(20)
+	for _, test := range []struct {
+		iters int
+		want  bool
+	}{
+		{10, false},
+		{20, false},
+		{30, false},
+		{31, true},
+	} {
+		if got := s.Stale(test.iters); got != test.want {
+			t.Errorf("Stale(%d) = %t, want %t", test.iters, got, test.want)
+		}
+	}
+	s.Ratio = 3
+	if s.Stale(60) || !s.Stale(61) {
+		t.Errorf("Ratio not respected")
+	}
+}
+
+func benchmarkILU0(b *testing.B, refresh bool) {
+	a := testmat.ConvectionDiffusion2D(200, 200, 1, 0.5).CSR()
+	p, err := iterative.NewILU0(a)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if refresh {
+			err = p.Refresh(a)
+		} else {
+			_, err = iterative.NewILU0(a)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkILU0New(b *testing.B)     { benchmarkILU0(b, false) }
+func BenchmarkILU0Refresh(b *testing.B) { benchmarkILU0(b, true) }
+
+func TestILU0RCM(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name  string
+		a     *sparse.CSR
+		exact bool
+	}{
+		// RCM recovers the tridiagonal ordering
+		// in which ILU(0) is exact.
+		{"tridiagonal", tridiagonalCSR(100, -1.5, 2, -0.5), true},
+		{"convection-diffusion", testmat.ConvectionDiffusion2D(30, 30, 1, 0.5).CSR(), false},
+		{"gre__115", marketCSR("gre__115"), false},
+	} {
+		n, _ := test.a.Dims()
+		shuffle := rnd.Perm(n)
+		a := sparse.Permute(test.a, shuffle, shuffle)
+		perm, err := sparse.RCM(a)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		ilu, err := iterative.NewILU0(sparse.Permute(a, perm, perm))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		rcm := iterative.NewPermutedPreconditioner(ilu, perm)
+
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+		}
+		b := make([]float64, n)
+		a.MulVec(b, x)
+		if test.exact {
+			got := make([]float64, n)
+			if err := rcm.Apply(got, b); err != nil || !floats.EqualApprox(got, x, 1e-10) {
+				t.Errorf("%s: Apply not the inverse", test.name)
+			}
+			a.MulTransVec(b, x)
+			if err := rcm.ApplyTrans(got, b); err != nil || !floats.EqualApprox(got, x, 1e-10) {
+				t.Errorf("%s: ApplyTrans not the inverse", test.name)
+			}
+			continue
+		}
+
+		iters := func(p iterative.Preconditioner) int {
+			ops := iterative.MatrixOps{MatVec: a.MulVec}
+			settings := iterative.Settings{Tolerance: 1e-8, PSolve: p.Apply}
+			res, err := iterative.LinearSolve(ops, b, &iterative.GMRES{}, settings)
+			if err != nil {
+				t.Fatalf("%s: unexpected error from GMRES: %v", test.name, err)
+			}
+			return res.Stats.Iterations
+		}
+		plain, err := iterative.NewILU0(a)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if got, want := iters(rcm), iters(plain); got >= want {
+			t.Errorf("%s: ILU(0) after RCM needs %d iterations, without %d", test.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// InstrumentedOps is a matrix that counts and times the calls to its
+// MatVec and MatTransVec. The counters accumulate over all uses of the
+// embedded MatrixOps, by LinearSolve or directly, until Reset is called.
+// They are updated atomically, so the operator can be shared by concurrent
+// solves if the wrapped operator allows it.
+type InstrumentedOps struct {
+	// The counters are accessed atomically
+	// and are first in the struct to be
+	// 64-bit aligned.
+	matVec          int64
+	matTransVec     int64
+	matVecTime      int64
+	matTransVecTime int64
+
+	MatrixOps
+}
+
+// OpsStats holds the counts and the cumulative durations of the calls to
+// the MatVec and MatTransVec of an InstrumentedOps.
+type OpsStats struct {
+	MatVec          int
+	MatTransVec     int
+	MatVecTime      time.Duration
+	MatTransVecTime time.Duration
+}
+
+// Instrumented returns a new InstrumentedOps that delegates to a. If a
+// lacks MatTransVec, so does the returned operator.
+func Instrumented(a MatrixOps) *InstrumentedOps {
+	checkOps(a)
+	ins := &InstrumentedOps{}
+	ins.MatVec = instrument(a.MatVec, &ins.matVec, &ins.matVecTime)
+	ins.MatTransVec = instrument(a.MatTransVec, &ins.matTransVec, &ins.matTransVecTime)
+	return ins
+}
+
+// instrument returns matVec that increments count and adds its duration
+// in nanoseconds to dur.
+func instrument(matVec func(dst, x []float64), count, dur *int64) func(dst, x []float64) {
+	if matVec == nil {
+		return nil
+	}
+	return func(dst, x []float64) {
+		start := time.Now()
+		matVec(dst, x)
+		atomic.AddInt64(dur, int64(time.Since(start)))
+		atomic.AddInt64(count, 1)
+	}
+}
+
+// Snapshot returns the current counts and durations. Each value is read
+// atomically, but the values are not read as a whole, so calls that end
+// during Snapshot may be included only partially.
+func (ins *InstrumentedOps) Snapshot() OpsStats {
+	return OpsStats{
+		MatVec:          int(atomic.LoadInt64(&ins.matVec)),
+		MatTransVec:     int(atomic.LoadInt64(&ins.matTransVec)),
+		MatVecTime:      time.Duration(atomic.LoadInt64(&ins.matVecTime)),
+		MatTransVecTime: time.Duration(atomic.LoadInt64(&ins.matTransVecTime)),
+	}
+}
+
+// Reset sets the counts and durations to zero.
+func (ins *InstrumentedOps) Reset() {
+	atomic.StoreInt64(&ins.matVec, 0)
+	atomic.StoreInt64(&ins.matTransVec, 0)
+	atomic.StoreInt64(&ins.matVecTime, 0)
+	atomic.StoreInt64(&ins.matTransVecTime, 0)
+}
@@ -0,0 +1,86 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+// TestInstrumented checks that the counts of an operator shared by a batch
+// of solves match the sum of their statistics.
+func TestInstrumented(t *testing.T) {
+	const n = 100
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	ins := Instrumented(a)
+
+	newMethods := []func() Method{
+		func() Method { return &CG{} },
+		func() Method { return &BiCG{} },
+		func() Method { return &BiCGSTAB{} },
+		func() Method { return &GMRES{Restart: 5} },
+	}
+	const solves = 3
+	var (
+		mu    sync.Mutex
+		total int
+		wg    sync.WaitGroup
+	)
+	for k := 0; k < solves; k++ {
+		for _, newMethod := range newMethods {
+			wg.Add(1)
+			go func(k int, method Method) {
+				defer wg.Done()
+				b := make([]float64, n)
+				for i := range b {
+					b[i] = math.Sin(float64(i + k))
+				}
+				res, err := LinearSolve(ins.MatrixOps, b, method, Settings{X0: ones(n)})
+				if err != nil {
+					t.Errorf("%T: %v", method, err)
+				}
+				mu.Lock()
+				total += res.Stats.MatVec
+				mu.Unlock()
+			}(k, newMethod())
+		}
+	}
+	wg.Wait()
+
+	s := ins.Snapshot()
+	if s.MatVec+s.MatTransVec != total {
+		t.Errorf("unexpected number of calls: want %d, got %d+%d", total, s.MatVec, s.MatTransVec)
+	}
+	if s.MatTransVec == 0 {
+		t.Errorf("MatTransVec of BiCG not counted")
+	}
+	if s.MatVecTime <= 0 {
+		t.Errorf("MatVec time not recorded")
+	}
+
+	// Calls outside the driver are counted too.
+	dst := make([]float64, n)
+	ins.MatVec(dst, ones(n))
+	if got := ins.Snapshot().MatVec; got != s.MatVec+1 {
+		t.Errorf("direct call not counted: want %d, got %d", s.MatVec+1, got)
+	}
+
+	ins.Reset()
+	if got := ins.Snapshot(); got != (OpsStats{}) {
+		t.Errorf("unexpected stats after Reset: %+v", got)
+	}
+}
+
+func TestInstrumentedNoTrans(t *testing.T) {
+	ins := Instrumented(MatrixOps{MatVec: IdentityOps(3).MatVec})
+	if ins.MatTransVec != nil {
+		t.Errorf("unexpected MatTransVec")
+	}
+	if !panics(func() { Instrumented(MatrixOps{}) }) {
+		t.Errorf("no panic for nil MatVec")
+	}
+}
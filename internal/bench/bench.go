@@ -0,0 +1,113 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package bench defines the solver benchmarks shared by the benchmark
+// functions of package iterative and the iterbench command.
+package bench
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat"
+)
+
+// Methods, preconditioners and grid sizes of the benchmarks.
+var (
+	Methods         = []string{"CG", "BiCGSTAB", "GMRES(30)", "SteepestDescent"}
+	Preconditioners = []string{"none", "Jacobi"}
+	Sizes           = []int{64, 256, 1024}
+)
+
+// Case is a benchmark that solves the 2D Poisson problem on an N×N grid.
+type Case struct {
+	Method  string
+	Precond string
+	N       int
+}
+
+// Cases returns the combinations of Methods, Preconditioners and Sizes.
+// GMRES(30) on the largest grid is excluded because it needs tens of
+// thousands of iterations and a single solve takes hours. SteepestDescent,
+// the slow reference, is run only on the smallest grid for the same reason.
+func Cases() []Case {
+	var cases []Case
+	for _, m := range Methods {
+		for _, p := range Preconditioners {
+			for _, n := range Sizes {
+				if m == "GMRES(30)" && n >= 1024 {
+					continue
+				}
+				if m == "SteepestDescent" && n > Sizes[0] {
+					continue
+				}
+				cases = append(cases, Case{m, p, n})
+			}
+		}
+	}
+	return cases
+}
+
+// Name returns the name of the benchmark in the form method/precond/NxN.
+func (c Case) Name() string {
+	return fmt.Sprintf("%s/%s/%dx%d", c.Method, c.Precond, c.N, c.N)
+}
+
+// Tolerance is the tolerance of the benchmarked solves.
+const Tolerance = 1e-6
+
+// Run runs the benchmark. In addition to the time and the allocations it
+// reports the number of iterations and matrix-vector products per solve as
+// the custom metrics "iters/op" and "matvecs/op". Run fails if the solve
+// does not converge.
+func (c Case) Run(b *testing.B) {
+	p := testmat.Poisson2D(c.N, c.N)
+	_, rhs := p.Manufactured()
+	settings := iterative.Settings{
+		Tolerance:     Tolerance,
+		MaxIterations: 100 * p.Dim,
+	}
+	switch c.Precond {
+	case "none":
+	case "Jacobi":
+		d := make([]float64, p.Dim)
+		for i := range d {
+			d[i] = 4 // The diagonal of Poisson2D.
+		}
+		m := iterative.DiagonalInverse(d)
+		settings.PSolve = m.Apply
+		settings.PSolveTrans = m.ApplyTrans
+	default:
+		b.Fatalf("unknown preconditioner %q", c.Precond)
+	}
+	newMethod := func() iterative.Method {
+		switch c.Method {
+		case "CG":
+			return &iterative.CG{}
+		case "BiCGSTAB":
+			return &iterative.BiCGSTAB{}
+		case "GMRES(30)":
+			return &iterative.GMRES{Restart: 30}
+		case "SteepestDescent":
+			return &iterative.SteepestDescent{}
+		}
+		b.Fatalf("unknown method %q", c.Method)
+		return nil
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var iters, matvecs int
+	for i := 0; i < b.N; i++ {
+		res, err := iterative.LinearSolve(p.Ops, rhs, newMethod(), settings)
+		if err != nil {
+			b.Fatal(err)
+		}
+		iters += res.Stats.Iterations
+		matvecs += res.Stats.MatVec
+	}
+	b.ReportMetric(float64(iters)/float64(b.N), "iters/op")
+	b.ReportMetric(float64(matvecs)/float64(b.N), "matvecs/op")
+}
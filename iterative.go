@@ -5,6 +5,8 @@
 // Package iterative provides iterative algorithms for solving linear systems.
 package iterative
 
+import "fmt"
+
 // Method is an iterative method that produces a sequence of vectors converging
 // to the vector x satisfying a system of linear equations
 //  A x = b,
@@ -31,6 +33,24 @@ type Method interface {
 	Iterate(*Context) (Operation, error)
 }
 
+// RectMethod is a Method that also solves the least-squares problem
+//  min |b - A x|
+// for a rectangular m×n matrix A, where x has dimension n and b dimension
+// m. Its Context holds X of length n and Residual of length m, MatVec
+// maps vectors of length n to length m and MatTransVec vice versa, and
+// PSolve and PSolveTrans act on vectors of length n. When it commands
+// CheckResidualNorm, the method must also update
+// Context.NormalResidualNorm.
+type RectMethod interface {
+	Method
+
+	// InitRect initializes the method for
+	// solving an m×n least-squares problem.
+	// Init(dim) is equivalent to
+	// InitRect(dim, dim).
+	InitRect(m, n int)
+}
+
 // Context mediates the communication between the Method and the caller. It must
 // not be modified or accessed apart from the commanded Operations.
 type Context struct {
@@ -44,13 +64,21 @@ type Context struct {
 	// Residual is the current residual b-A*x.
 	// On the first call to Method.Iterate,
 	// Residual must contain the initial
-	// residual.
-	// TODO(vladimir-ch): Consider whether the
-	// behavior should also include: Method
-	// must update Residual with the current
-	// value of b-A*x when it commands
-	// EndIteration.
+	// residual. When Method commands
+	// EndIteration, Residual is the residual
+	// of X only if ResidualCurrent is true.
 	Residual []float64
+	// ResidualCurrent indicates whether
+	// Residual is the residual of X, up to
+	// the rounding errors of its updates.
+	// Method must set it when it commands
+	// EndIteration. Methods that do not form
+	// the residual in every iteration, such
+	// as GMRES between restarts, set it to
+	// false, and so callers that read
+	// Residual at the end of an iteration
+	// must check it.
+	ResidualCurrent bool
 	// ResidualNorm is (an estimate of) the
 	// norm of the current residual. Method
 	// must update it when it commands
@@ -62,6 +90,14 @@ type Context struct {
 	// TODO(vladimir-ch): Actually this is
 	// something that should be discussed.
 	ResidualNorm float64
+	// NormalResidualNorm is (an estimate of)
+	// the norm of A^T r, the residual of the
+	// normal equations, for a rectangular A.
+	// RectMethod must update it together with
+	// ResidualNorm when it commands
+	// CheckResidualNorm on a rectangular
+	// system.
+	NormalResidualNorm float64
 	// Converged indicates to Method that the
 	// ResidualNorm satisfies the stopping
 	// criterion as a result of
@@ -71,11 +107,28 @@ type Context struct {
 	// call Method.Iterate again without
 	// calling Method.Init first.
 	Converged bool
+	// Restarts is the number of restarts of
+	// a restarted method such as GMRES.
+	// Method increments it when it restarts,
+	// and the caller reports it in Stats.
+	Restarts int
 
 	// Src and Dst are the source and
 	// destination vectors for various
-	// Operations.
+	// Operations. Src may alias Residual
+	// or X, Dst does not alias Src. Methods
+	// avoid copies by swapping and aliasing
+	// their vectors, so Src and Dst can
+	// refer to different vectors in each
+	// operation and the caller must not
+	// retain them after performing the
+	// operation.
 	Src, Dst []float64
+
+	// vec does the vector operations of the
+	// methods in this package. It is set by
+	// LinearSolve, nil means serial.
+	vec *vecOps
 }
 
 // Operation specifies the type of operation.
@@ -129,8 +182,39 @@ const (
 	// called before calling Method.Iterate
 	// again.
 	EndIteration
+
+	// Multiply B*x where B is the weight
+	// matrix of Settings.Weight, x is stored
+	// in Context.Src and the result will be
+	// stored in Context.Dst.
+	WeightVec
 )
 
+// String returns the name of the operation.
+func (op Operation) String() string {
+	switch op {
+	case NoOperation:
+		return "NoOperation"
+	case MatVec:
+		return "MatVec"
+	case MatTransVec:
+		return "MatTransVec"
+	case PSolve:
+		return "PSolve"
+	case PSolveTrans:
+		return "PSolveTrans"
+	case ComputeResidual:
+		return "ComputeResidual"
+	case CheckResidualNorm:
+		return "CheckResidualNorm"
+	case EndIteration:
+		return "EndIteration"
+	case WeightVec:
+		return "WeightVec"
+	}
+	return fmt.Sprintf("Operation(%d)", uint64(op))
+}
+
 func reuse(v []float64, n int) []float64 {
 	if cap(v) < n {
 		return make([]float64, n)
@@ -142,7 +226,45 @@ const (
 	// Machine epsilon.
 	eps = 1.0 / (1 << 53)
 
-	// Tolerances for BiCG and BiCGSTAB methods.
+	// Tolerances for BiCG, BiCGSTAB and CGS methods.
 	rhoBreakdownTol   = eps * eps
 	omegaBreakdownTol = eps * eps
 )
+
+// BreakdownError is returned by Method.Iterate when a recurrence of the
+// method breaks down because a quantity by which it divides vanishes.
+// Restarting the method from the current approximate solution, for
+// example with AutoRestart, or switching to another method may help. The
+// stationary iterations also return it when they diverge and a quantity
+// overflows, restarting them then does not help.
+type BreakdownError struct {
+	// Method is the name of the method.
+	Method string
+	// Quantity is the name of the vanishing
+	// quantity.
+	Quantity string
+}
+
+func (e *BreakdownError) Error() string {
+	return e.Method + ": " + e.Quantity + " breakdown"
+}
+
+// NotPositiveDefiniteError is returned by Method.Iterate when a method for
+// symmetric positive definite matrices encounters a vector x with x·Ax <= 0,
+// so the matrix is not positive definite, or, for a method that needs a
+// positive definite preconditioner M, a vector x with x·M^{-1}x < 0.
+type NotPositiveDefiniteError struct {
+	// Method is the name of the method.
+	Method string
+	// Preconditioner is true if the
+	// preconditioner instead of the matrix
+	// is not positive definite.
+	Preconditioner bool
+}
+
+func (e *NotPositiveDefiniteError) Error() string {
+	if e.Preconditioner {
+		return e.Method + ": preconditioner not positive definite"
+	}
+	return e.Method + ": matrix not positive definite"
+}
@@ -0,0 +1,104 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+)
+
+// Jacobi implements the weighted Jacobi iteration
+//  x_i = x_{i-1} + ω D^{-1} (b - A x_{i-1})
+// for solving the system of linear equations
+//  Ax = b,
+// where D is the diagonal of A and ω is Damping. Jacobi applies D^{-1}
+// with the PSolve operation, so Settings.PSolve must apply the inverse of
+// the diagonal of A, for example
+//  p := iterative.DiagonalInverse(sparse.Diagonal(a))
+//  settings.PSolve = p.Apply
+// With another preconditioner M the method is the damped iteration
+// x_i = x_{i-1} + ω M^{-1} r_{i-1}, without a preconditioner it is the
+// Richardson iteration.
+//
+// The undamped iteration converges for strictly diagonally dominant
+// matrices, the damped one with 0 < ω <= 2/(λ_max(D^{-1}A)) for symmetric
+// positive definite matrices. It converges slowly, its main use is as a
+// smoother in multigrid methods, where the default ω = 2/3 damps the
+// oscillatory error components of the discrete Laplacian the most.
+//
+// Jacobi needs MatVec and PSolve matrix operations.
+type Jacobi struct {
+	// Damping is the weight ω of the update.
+	// If it is zero, 2/3 is used. It must not
+	// be negative.
+	Damping float64
+
+	resume int
+	omega  float64
+
+	z  []float64
+	az []float64
+}
+
+// Init implements the Method interface.
+func (j *Jacobi) Init(dim int) {
+	if dim <= 0 {
+		panic("Jacobi: dimension not positive")
+	}
+	if j.Damping < 0 {
+		panic("Jacobi: negative damping")
+	}
+
+	j.omega = j.Damping
+	if j.omega == 0 {
+		j.omega = 2.0 / 3
+	}
+	j.z = reuse(j.z, dim)
+	j.az = reuse(j.az, dim)
+	j.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (j *Jacobi) Iterate(ctx *Context) (Operation, error) {
+	switch j.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = j.z
+		j.resume = 2
+		return PSolve, nil
+		// Solve D z = r_{i-1}
+	case 2:
+		ctx.vec.addScaled(ctx.X, j.omega, j.z) // x_i = x_{i-1} + ω z
+		ctx.Src = j.z
+		ctx.Dst = j.az
+		j.resume = 3
+		return MatVec, nil
+		// Compute Az
+	case 3:
+		// r_i = r_{i-1} - ω Az
+		rr := ctx.vec.axpyDot(-j.omega, j.az, ctx.Residual)
+		ctx.ResidualNorm = math.Sqrt(rr)
+		if math.IsNaN(ctx.ResidualNorm) || math.IsInf(ctx.ResidualNorm, 0) {
+			j.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, errors.New("Jacobi: divergence")
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		j.resume = 4
+		return CheckResidualNorm, nil
+	case 4:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			j.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		j.resume = 1
+		return EndIteration, nil
+
+	default:
+		panic("Jacobi: Init not called")
+	}
+}
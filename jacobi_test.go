@@ -0,0 +1,119 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// diagonallyDominant returns a random n×n matrix with nnz off-diagonal
+// elements per row that is strictly diagonally dominant by rows with the
+// ratio of the off-diagonal row sum to the diagonal element at most ratio.
+func diagonallyDominant(n, nnz int, ratio float64, rnd *rand.Rand) *sparse.CSR {
+	t := sparse.NewTriplet(n, n)
+	for i := 0; i < n; i++ {
+		var sum float64
+		for k := 0; k < nnz; k++ {
+			j := rnd.Intn(n)
+			if j == i {
+				continue
+			}
+			v := rnd.NormFloat64()
+			t.Append(i, j, v)
+			sum += math.Abs(v)
+		}
+		d := sum/ratio + 0.1
+		if rnd.Intn(2) == 0 {
+			d = -d
+		}
+		t.Append(i, i, d)
+	}
+	return sparse.NewCSRFromTriplet(t)
+}
+
+func TestJacobi(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		n, nnz  int
+		ratio   float64
+		damping float64
+	}{
+		{1, 0, 0.5, 0},
+		{10, 3, 0.5, 1},
+		{100, 5, 0.9, 1},
+		{100, 5, 0.5, 0},
+		{1000, 10, 0.7, 0.8},
+	} {
+		a := diagonallyDominant(test.n, test.nnz, test.ratio, rnd)
+		x := make([]float64, test.n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+		}
+		b := make([]float64, test.n)
+		a.MulVec(b, x)
+
+		p := iterative.DiagonalInverse(sparse.Diagonal(a))
+		res, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: a.MulVec}, b, &iterative.Jacobi{Damping: test.damping}, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 10000,
+			PSolve:        p.Apply,
+		})
+		if err != nil {
+			t.Errorf("n=%d,damping=%v: unexpected error: %v", test.n, test.damping, err)
+			continue
+		}
+		if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-10*floats.Norm(x, math.Inf(1)) {
+			t.Errorf("n=%d,damping=%v: solution differs by %v", test.n, test.damping, d)
+		}
+		// One product with A per sweep.
+		if res.Stats.MatVec != res.Stats.Iterations {
+			t.Errorf("n=%d,damping=%v: %d products in %d iterations", test.n, test.damping, res.Stats.MatVec, res.Stats.Iterations)
+		}
+	}
+}
+
+func TestJacobiSmoothing(t *testing.T) {
+	// The error of the most oscillatory
+	// eigenvector of the 1-D Laplacian is
+	// reduced by the factor 1 - ω(1 - cos θ)
+	// by a sweep, by about 1/3 for the
+	// default ω = 2/3.
+	const n = 50
+	a := tridiagonalCSR(n, -1, 2, -1)
+	p := iterative.DiagonalInverse(sparse.Diagonal(a))
+	theta := n * math.Pi / (n + 1)
+	e := make([]float64, n)
+	for i := range e {
+		e[i] = math.Sin(float64(i+1) * theta)
+	}
+
+	// The solution of the system with b = 0 is
+	// zero, so x is the error.
+	l := iterative.NewLoop(iterative.MatrixOps{MatVec: a.MulVec}, make([]float64, n), &iterative.Jacobi{}, iterative.Settings{
+		X0:            e,
+		PSolve:        p.Apply,
+		MaxIterations: 1,
+	})
+	for {
+		done, _ := l.Step()
+		if done {
+			break
+		}
+	}
+	got := floats.Norm(l.Result().X, 2) / floats.Norm(e, 2)
+	if want := math.Abs(1 - 2.0/3*(1-math.Cos(theta))); math.Abs(got-want) > 1e-12 {
+		t.Errorf("unexpected error reduction %v, want %v", got, want)
+	}
+	if got > 0.34 {
+		t.Errorf("error reduction %v larger than 1/3", got)
+	}
+}
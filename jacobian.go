@@ -0,0 +1,102 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// JacobianSettings holds settings for the finite-difference approximation
+// of Jacobian-vector products.
+type JacobianSettings struct {
+	// Step is the relative perturbation δ used
+	// to compute the step size
+	//  ε = δ (1 + ‖x‖) / ‖v‖
+	// for the product J*v. If it is zero,
+	// the square root of machine epsilon is
+	// used for forward differences and its
+	// cube root for central differences.
+	Step float64
+
+	// Central specifies that central
+	// differences
+	//  J*v ≈ (F(x+εv) - F(x-εv)) / 2ε
+	// are used instead of forward
+	// differences. Central differences are
+	// more accurate but need two evaluations
+	// of F per product instead of one.
+	Central bool
+}
+
+// NewJacobianOps returns MatrixOps that approximate the product of the
+// Jacobian of F at x with a vector v by the forward difference
+//  J*v ≈ (F(x+εv) - F(x)) / ε,
+// as used in Jacobian-free Newton-Krylov methods. The function f stores
+// F(x) into dst. fx must hold F(x), it is used only for forward differences
+// and can be nil in which case F(x) is evaluated by NewJacobianOps. If
+// settings is nil, the default settings are used.
+//
+// The returned MatrixOps reference x and fx, they must not be modified while
+// the operator is in use. The work vectors are allocated once by
+// NewJacobianOps. MatTransVec of the returned MatrixOps is nil because the
+// product with the transpose of J cannot be approximated by evaluations of F.
+func NewJacobianOps(f func(dst, x []float64), x, fx []float64, settings *JacobianSettings) MatrixOps {
+	n := len(x)
+	var s JacobianSettings
+	if settings != nil {
+		s = *settings
+	}
+	if s.Step < 0 {
+		panic("iterative: negative step")
+	}
+	if s.Step == 0 {
+		if s.Central {
+			s.Step = math.Cbrt(eps)
+		} else {
+			s.Step = math.Sqrt(eps)
+		}
+	}
+	if !s.Central {
+		if fx == nil {
+			fx = make([]float64, n)
+			f(fx, x)
+		}
+		if len(fx) != n {
+			panic("iterative: mismatched vector length")
+		}
+	}
+	xnorm := floats.Norm(x, 2)
+	xp := make([]float64, n)
+	fp := make([]float64, n)
+	var fm []float64
+	if s.Central {
+		fm = make([]float64, n)
+	}
+	matVec := func(dst, v []float64) {
+		checkLen(dst, v, n)
+		vnorm := floats.Norm(v, 2)
+		if vnorm == 0 {
+			for i := range dst {
+				dst[i] = 0
+			}
+			return
+		}
+		h := s.Step * (1 + xnorm) / vnorm
+		floats.AddScaledTo(xp, x, h, v)
+		f(fp, xp)
+		if !s.Central {
+			floats.SubTo(dst, fp, fx)
+			floats.Scale(1/h, dst)
+			return
+		}
+		floats.AddScaledTo(xp, x, -h, v)
+		f(fm, xp)
+		floats.SubTo(dst, fp, fm)
+		floats.Scale(1/(2*h), dst)
+	}
+	return MatrixOps{MatVec: matVec}
+}
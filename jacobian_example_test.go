@@ -0,0 +1,65 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vladimir-ch/iterative"
+	"gonum.org/v1/gonum/floats"
+)
+
+func ExampleNewJacobianOps() {
+	// Solve the Bratu problem
+	//  -u'' = λ exp(u) on (0,1), u(0) = u(1) = 0,
+	// discretized by finite differences with Newton's method, where the
+	// Newton systems are solved by GMRES with Jacobian-vector products
+	// approximated by finite differences.
+	const (
+		n      = 31
+		lambda = 1.0
+	)
+	h := 1.0 / (n + 1)
+	F := func(dst, u []float64) {
+		for i := range dst {
+			var left, right float64
+			if i > 0 {
+				left = u[i-1]
+			}
+			if i < n-1 {
+				right = u[i+1]
+			}
+			dst[i] = (2*u[i]-left-right)/(h*h) - lambda*math.Exp(u[i])
+		}
+	}
+
+	u := make([]float64, n)
+	fu := make([]float64, n)
+	rhs := make([]float64, n)
+	var iter int
+	for ; iter < 10; iter++ {
+		F(fu, u)
+		if floats.Norm(fu, 2) < 1e-8 {
+			break
+		}
+		J := iterative.NewJacobianOps(F, u, fu, nil)
+		floats.ScaleTo(rhs, -1, fu)
+		res, err := iterative.LinearSolve(J, rhs, &iterative.GMRES{}, iterative.Settings{
+			Tolerance: 1e-10,
+		})
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		floats.Add(u, res.X)
+	}
+	fmt.Printf("Newton iterations: %d\n", iter)
+	fmt.Printf("u(1/2)=%.6f\n", u[n/2])
+
+	// Output:
+	// Newton iterations: 3
+	// u(1/2)=0.140553
+}
@@ -0,0 +1,103 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// chemical returns F and its Jacobian for a small nonlinear system with
+// F_i(x) = x_i^3 + sin(x_{i+1}) * exp(x_{i-1}) - i.
+func chemical(n int) (f func(dst, x []float64), jac func(x []float64) *mat.Dense) {
+	at := func(x []float64, i int) float64 {
+		if i < 0 || n <= i {
+			return 0
+		}
+		return x[i]
+	}
+	f = func(dst, x []float64) {
+		for i := range dst {
+			dst[i] = x[i]*x[i]*x[i] + math.Sin(at(x, i+1))*math.Exp(at(x, i-1)) - float64(i)
+		}
+	}
+	jac = func(x []float64) *mat.Dense {
+		j := mat.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			j.Set(i, i, 3*x[i]*x[i])
+			if i+1 < n {
+				j.Set(i, i+1, math.Cos(x[i+1])*math.Exp(at(x, i-1)))
+			}
+			if i > 0 {
+				j.Set(i, i-1, math.Sin(at(x, i+1))*math.Exp(x[i-1]))
+			}
+		}
+		return j
+	}
+	return f, jac
+}
+
+func TestJacobianOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 5, 20} {
+		f, jac := chemical(n)
+		x := make([]float64, n)
+		v := make([]float64, n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+			v[i] = rnd.NormFloat64()
+		}
+		var want mat.VecDense
+		want.MulVec(jac(x), mat.NewVecDense(n, v))
+		scale := math.Max(1, floats.Norm(want.RawVector().Data, math.Inf(1)))
+
+		fx := make([]float64, n)
+		f(fx, x)
+		for _, test := range []struct {
+			name     string
+			fx       []float64
+			settings *JacobianSettings
+			tol      float64
+		}{
+			{"forward", fx, nil, 1e-6},
+			{"forward without F(x)", nil, nil, 1e-6},
+			{"forward with step", fx, &JacobianSettings{Step: 1e-7}, 1e-5},
+			{"central", nil, &JacobianSettings{Central: true}, 1e-9},
+		} {
+			a := NewJacobianOps(f, x, test.fx, test.settings)
+			if a.MatTransVec != nil {
+				t.Errorf("%v,n=%v: non-nil MatTransVec", test.name, n)
+			}
+			got := make([]float64, n)
+			a.MatVec(got, v)
+			if dist := floats.Distance(got, want.RawVector().Data, math.Inf(1)) / scale; dist > test.tol {
+				t.Errorf("%v,n=%v: unexpected directional derivative, |want-got|=%v", test.name, n, dist)
+			}
+			// Scaling v must not change the accuracy.
+			vs := make([]float64, n)
+			floats.ScaleTo(vs, 1e6, v)
+			a.MatVec(got, vs)
+			floats.Scale(1e-6, got)
+			if dist := floats.Distance(got, want.RawVector().Data, math.Inf(1)) / scale; dist > test.tol {
+				t.Errorf("%v,n=%v: unexpected directional derivative of scaled v, |want-got|=%v", test.name, n, dist)
+			}
+			a.MatVec(got, make([]float64, n))
+			for _, g := range got {
+				if g != 0 {
+					t.Errorf("%v,n=%v: non-zero product with zero vector", test.name, n)
+					break
+				}
+			}
+			allocs := testing.AllocsPerRun(10, func() { a.MatVec(got, v) })
+			if allocs != 0 {
+				t.Errorf("%v,n=%v: unexpected allocations %v", test.name, n, allocs)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// The fused kernels in this file replace chains of level 1 operations in
+// the hot paths of the methods. Each kernel makes a single pass over its
+// vectors instead of one pass per operation, which matters because the
+// vector updates are bound by memory bandwidth. The updated vectors are
+// bit-for-bit identical to those computed by the floats package (unless
+// the compiler fuses multiply-adds on the target architecture), the
+// returned inner products and norms are accumulated in a different order
+// and without scaling, so they differ from floats.Dot and floats.Norm by
+// rounding errors only. Such differences are harmless in residual norms,
+// which only decide convergence, but they change the iterates when they
+// enter the recurrence coefficients, so those are still computed by
+// floats.Dot.
+
+// axpyDot computes
+//  y += alpha * x
+// and returns y·y of the updated y.
+func axpyDot(alpha float64, x, y []float64) float64 {
+	if len(x) != len(y) {
+		panic("iterative: mismatched vector length")
+	}
+	var dot float64
+	for i, v := range x {
+		yi := y[i] + alpha*v
+		y[i] = yi
+		dot += yi * yi
+	}
+	return dot
+}
+
+// cgUpdate computes the CG updates
+//  x += alpha * p
+//  r -= alpha * ap
+// and returns the 2-norm of the updated r.
+func cgUpdate(alpha float64, x, p, r, ap []float64) float64 {
+	n := len(x)
+	if len(p) != n || len(r) != n || len(ap) != n {
+		panic("iterative: mismatched vector length")
+	}
+	var rr float64
+	for i := range x {
+		x[i] += alpha * p[i]
+		ri := r[i] - alpha*ap[i]
+		r[i] = ri
+		rr += ri * ri
+	}
+	return math.Sqrt(rr)
+}
+
+// bicgstabUpdate computes the BiCGSTAB updates
+//  x += alpha * phat
+//  x += omega * shat
+//  r -= omega * t
+// and returns the 2-norm of the updated r.
+func bicgstabUpdate(alpha, omega float64, x, phat, shat, r, t []float64) float64 {
+	n := len(x)
+	if len(phat) != n || len(shat) != n || len(r) != n || len(t) != n {
+		panic("iterative: mismatched vector length")
+	}
+	var rr float64
+	for i := range x {
+		xi := x[i] + alpha*phat[i]
+		x[i] = xi + omega*shat[i]
+		ri := r[i] - omega*t[i]
+		r[i] = ri
+		rr += ri * ri
+	}
+	return math.Sqrt(rr)
+}
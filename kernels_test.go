@@ -0,0 +1,122 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func randomVecs(n, k int, rnd *rand.Rand) [][]float64 {
+	v := make([][]float64, k)
+	for i := range v {
+		v[i] = make([]float64, n)
+		for j := range v[i] {
+			v[i][j] = rnd.NormFloat64()
+		}
+	}
+	return v
+}
+
+func copyVecs(v [][]float64) [][]float64 {
+	c := make([][]float64, len(v))
+	for i := range v {
+		c[i] = make([]float64, len(v[i]))
+		copy(c[i], v[i])
+	}
+	return c
+}
+
+func TestFusedKernels(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 7, 100, 1001} {
+		const alpha, omega = 0.7, -1.3
+		tol := 1e-14 * float64(n+1)
+
+		v := randomVecs(n, 2, rnd)
+		want := copyVecs(v)
+		got := axpyDot(alpha, v[0], v[1])
+		floats.AddScaled(want[1], alpha, want[0])
+		if !floats.Equal(v[1], want[1]) {
+			t.Errorf("n=%v: axpyDot: unexpected y", n)
+		}
+		if dot := floats.Dot(want[1], want[1]); math.Abs(got-dot) > tol*math.Max(1, dot) {
+			t.Errorf("n=%v: axpyDot: unexpected dot, want %v got %v", n, dot, got)
+		}
+
+		// x, p, r, ap.
+		v = randomVecs(n, 4, rnd)
+		want = copyVecs(v)
+		gotNorm := cgUpdate(alpha, v[0], v[1], v[2], v[3])
+		floats.AddScaled(want[2], -alpha, want[3])
+		floats.AddScaled(want[0], alpha, want[1])
+		if !floats.Equal(v[0], want[0]) || !floats.Equal(v[2], want[2]) {
+			t.Errorf("n=%v: cgUpdate: unexpected x or r", n)
+		}
+		if norm := floats.Norm(want[2], 2); math.Abs(gotNorm-norm) > tol*math.Max(1, norm) {
+			t.Errorf("n=%v: cgUpdate: unexpected norm, want %v got %v", n, norm, gotNorm)
+		}
+
+		// x, phat, shat, r, t.
+		v = randomVecs(n, 5, rnd)
+		want = copyVecs(v)
+		gotNorm = bicgstabUpdate(alpha, omega, v[0], v[1], v[2], v[3], v[4])
+		floats.AddScaled(want[0], alpha, want[1])
+		floats.AddScaled(want[0], omega, want[2])
+		floats.AddScaled(want[3], -omega, want[4])
+		if !floats.Equal(v[0], want[0]) || !floats.Equal(v[3], want[3]) {
+			t.Errorf("n=%v: bicgstabUpdate: unexpected x or r", n)
+		}
+		if norm := floats.Norm(want[3], 2); math.Abs(gotNorm-norm) > tol*math.Max(1, norm) {
+			t.Errorf("n=%v: bicgstabUpdate: unexpected norm, want %v got %v", n, norm, gotNorm)
+		}
+	}
+
+	if !panics(func() { cgUpdate(1, make([]float64, 2), make([]float64, 2), make([]float64, 1), make([]float64, 2)) }) {
+		t.Errorf("mismatched lengths did not panic")
+	}
+}
+
+const benchKernelN = 1000000
+
+func BenchmarkCGUpdateFused(b *testing.B) {
+	v := randomVecs(benchKernelN, 4, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cgUpdate(1e-3, v[0], v[1], v[2], v[3])
+	}
+}
+
+func BenchmarkCGUpdateUnfused(b *testing.B) {
+	v := randomVecs(benchKernelN, 4, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		floats.AddScaled(v[2], -1e-3, v[3])
+		floats.AddScaled(v[0], 1e-3, v[1])
+		floats.Norm(v[2], 2)
+	}
+}
+
+func BenchmarkBiCGSTABUpdateFused(b *testing.B) {
+	v := randomVecs(benchKernelN, 5, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bicgstabUpdate(1e-3, 1e-3, v[0], v[1], v[2], v[3], v[4])
+	}
+}
+
+func BenchmarkBiCGSTABUpdateUnfused(b *testing.B) {
+	v := randomVecs(benchKernelN, 5, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		floats.AddScaled(v[0], 1e-3, v[1])
+		floats.AddScaled(v[0], 1e-3, v[2])
+		floats.AddScaled(v[3], -1e-3, v[4])
+		floats.Norm(v[3], 2)
+	}
+}
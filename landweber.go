@@ -0,0 +1,172 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+)
+
+// landweberPower is the number of power iterations used by Landweber to
+// estimate |A|^2.
+const landweberPower = 20
+
+// Landweber implements the Landweber iteration
+//  x_{i} = x_{i-1} + Tau * A^T (b - A x_{i-1})
+// for the system of linear equations
+//  Ax = b.
+// It converges to the least-squares solution of minimum distance from the
+// initial guess for 0 < Tau < 2/|A|^2, but slowly. It is used for
+// ill-posed problems with noisy right-hand sides, where the iterates first
+// approach the exact solution and then diverge from it by fitting the noise,
+// so the number of iterations acts as the regularization parameter. The
+// iteration is then stopped by the discrepancy principle with
+// Settings.NoiseLevel.
+//
+// Landweber is a RectMethod, for a rectangular A it converges to a
+// least-squares solution. The norm of A^T r it reports for the stopping
+// criterion is that of the previous iterate, which it computes anyway.
+//
+// Landweber needs MatVec, MatTransVec and ComputeResidual matrix
+// operations, it does not use a preconditioner.
+type Landweber struct {
+	// Tau is the step length. If it is zero,
+	// 1/|A|^2 is used with |A|^2 estimated by
+	// 20 steps of the power method for A^T A
+	// started from the initial residual, or
+	// from A^T r_0 for a rectangular A. The
+	// MatVec and MatTransVec operations of
+	// the estimate are counted in Stats. Tau
+	// must not be negative.
+	Tau float64
+
+	resume int
+	tau    float64
+	k      int
+
+	v  []float64
+	av []float64
+	s  []float64
+}
+
+// Init implements the Method interface.
+func (lw *Landweber) Init(dim int) {
+	lw.InitRect(dim, dim)
+}
+
+// InitRect implements the RectMethod interface.
+func (lw *Landweber) InitRect(m, n int) {
+	if m <= 0 || n <= 0 {
+		panic("Landweber: dimension not positive")
+	}
+	if lw.Tau < 0 {
+		panic("Landweber: negative step length")
+	}
+
+	lw.s = reuse(lw.s, n)
+	lw.tau = lw.Tau
+	if lw.tau == 0 {
+		lw.v = reuse(lw.v, n)
+		lw.av = reuse(lw.av, m)
+		lw.k = 0
+		lw.resume = 1
+		return
+	}
+	lw.resume = 4
+}
+
+// Iterate implements the Method interface.
+func (lw *Landweber) Iterate(ctx *Context) (Operation, error) {
+	switch lw.resume {
+	case 1:
+		if len(lw.v) != len(ctx.Residual) {
+			// For a rectangular A the power
+			// method starts from A^T r_0.
+			ctx.Src = ctx.Residual
+			ctx.Dst = lw.v
+			lw.resume = 8
+			return MatTransVec, nil
+		}
+		ctx.vec.copy(lw.v, ctx.Residual)
+		return lw.startPower(ctx)
+	case 2:
+		ctx.Src = lw.av
+		ctx.Dst = lw.s
+		lw.resume = 3
+		return MatTransVec, nil
+		// Compute s = A^T A v
+	case 3:
+		lw.k++
+		lambda := ctx.vec.dot(lw.v, lw.s) // λ = v^T A^T A v
+		if lw.k < landweberPower && lambda > 0 {
+			// v = s / |s|
+			lw.v, lw.s = lw.s, lw.v
+			ctx.vec.scale(1/ctx.vec.norm(lw.v), lw.v)
+			ctx.Src = lw.v
+			ctx.Dst = lw.av
+			lw.resume = 2
+			return MatVec, nil
+			// Compute A v
+		}
+		if lambda <= 0 {
+			lw.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, errors.New("Landweber: zero norm estimate")
+		}
+		lw.tau = 1 / lambda
+		fallthrough
+	case 4:
+		ctx.Src = ctx.Residual
+		ctx.Dst = lw.s
+		lw.resume = 5
+		return MatTransVec, nil
+		// Compute s = A^T r_{i-1}
+	case 5:
+		ctx.vec.addScaled(ctx.X, lw.tau, lw.s) // x_i = x_{i-1} + τ s
+		ctx.Src = nil
+		ctx.Dst = nil
+		lw.resume = 6
+		return ComputeResidual, nil
+		// Compute r_i = b - A x_i
+	case 6:
+		ctx.ResidualNorm = ctx.vec.norm(ctx.Residual)
+		ctx.NormalResidualNorm = ctx.vec.norm(lw.s) // |A^T r_{i-1}|
+		if math.IsNaN(ctx.ResidualNorm) || math.IsInf(ctx.ResidualNorm, 0) {
+			lw.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, errors.New("Landweber: divergence, step length too large")
+		}
+		ctx.Converged = false
+		lw.resume = 7
+		return CheckResidualNorm, nil
+	case 7:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			lw.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		lw.resume = 4
+		return EndIteration, nil
+	case 8:
+		return lw.startPower(ctx)
+
+	default:
+		panic("Landweber: Init not called")
+	}
+}
+
+// startPower normalizes the starting vector of the power method and
+// commands its first product.
+func (lw *Landweber) startPower(ctx *Context) (Operation, error) {
+	norm := ctx.vec.norm(lw.v)
+	if norm == 0 {
+		lw.resume = 0 // Calling Iterate again without Init will panic.
+		return NoOperation, errors.New("Landweber: zero norm estimate")
+	}
+	ctx.vec.scale(1/norm, lw.v) // v = v / |v|
+	ctx.Src = lw.v
+	ctx.Dst = lw.av
+	lw.resume = 2
+	return MatVec, nil
+	// Compute A v
+}
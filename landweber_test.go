@@ -0,0 +1,144 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+)
+
+// deconvolution returns the n×n matrix of the Gaussian blur with the width
+// sigma on a uniform grid of [0,1], the exact solution and the right-hand
+// side with noise of the norm noise*|b_exact|, and the norm of the noise.
+func deconvolution(n int, sigma, noise float64, rnd *rand.Rand) (a iterative.MatrixOps, x, b []float64, delta float64) {
+	h := 1 / float64(n)
+	m := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			d := float64(i-j) * h
+			m[i*n+j] = h / (sigma * math.Sqrt(2*math.Pi)) * math.Exp(-d*d/(2*sigma*sigma))
+		}
+	}
+	a = iterative.DenseOps(m, n, n)
+
+	x = make([]float64, n)
+	for i := range x {
+		t := (float64(i) + 0.5) * h
+		x[i] = math.Exp(-60*(t-0.35)*(t-0.35)) + 0.6*math.Exp(-100*(t-0.7)*(t-0.7))
+	}
+	b = make([]float64, n)
+	a.MatVec(b, x)
+	e := make([]float64, n)
+	for i := range e {
+		e[i] = rnd.NormFloat64()
+	}
+	delta = noise * floats.Norm(b, 2)
+	floats.Scale(delta/floats.Norm(e, 2), e)
+	floats.Add(b, e)
+	return a, x, b, delta
+}
+
+// TestLandweberSemiconvergence checks that the error of the Landweber
+// iterates for a noisy deconvolution problem first decreases and then
+// increases, and that the discrepancy principle stops near the minimum.
+func TestLandweberSemiconvergence(t *testing.T) {
+	const (
+		n     = 100
+		iters = 3000
+	)
+	a, xTrue, b, delta := deconvolution(n, 0.05, 0.01, rand.New(rand.NewSource(1)))
+
+	// Record the errors of all iterates.
+	l := iterative.NewLoop(a, b, &iterative.Landweber{}, iterative.Settings{
+		Tolerance:     1e-15,
+		MaxIterations: iters,
+	})
+	errs := []float64{floats.Distance(l.Context().X, xTrue, 2)}
+	for {
+		done, err := l.Step()
+		if l.Operation() == iterative.EndIteration {
+			errs = append(errs, floats.Distance(l.Context().X, xTrue, 2))
+		}
+		if done {
+			if err == nil {
+				t.Fatalf("unexpected convergence")
+			}
+			break
+		}
+	}
+	best := floats.MinIdx(errs)
+	if best == 0 || best == len(errs)-1 {
+		t.Fatalf("no semiconvergence: minimum error at iteration %d of %d", best, len(errs)-1)
+	}
+	if errs[len(errs)-1] < 5*errs[best] {
+		t.Errorf("error at iteration %d not growing: %v, minimum %v", len(errs)-1, errs[len(errs)-1], errs[best])
+	}
+
+	res, err := iterative.LinearSolve(a, b, &iterative.Landweber{}, iterative.Settings{
+		MaxIterations: iters,
+		NoiseLevel:    delta,
+	})
+	if err != nil {
+		t.Fatalf("discrepancy stop: %v", err)
+	}
+	stop := res.Stats.Iterations
+	if res.Stats.ResidualNorm > 1.1*delta {
+		t.Errorf("residual norm %v above the discrepancy bound %v", res.Stats.ResidualNorm, 1.1*delta)
+	}
+	if got := floats.Distance(res.X, xTrue, 2); got != errs[stop] {
+		t.Errorf("error at the stop %v differs from the recorded %v", got, errs[stop])
+	}
+	// The discrepancy principle tends to stop
+	// early, but the error there is of the
+	// order of the minimum, far below the
+	// initial and the final errors.
+	if errs[stop] > 2*errs[best] || errs[stop] > 0.05*errs[0] {
+		t.Errorf("discrepancy stop at iteration %d with error %v, minimum %v at iteration %d", stop, errs[stop], errs[best], best)
+	}
+}
+
+// TestLandweberTau checks that the estimated and an explicit step length
+// give convergent iterations on a well-posed problem.
+func TestLandweberTau(t *testing.T) {
+	const n = 20
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = 1 + float64(i)/n
+	}
+	ops := iterative.DiagonalOps(d)
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = 1
+	}
+	for _, tau := range []float64{0, 0.3} {
+		res, err := iterative.LinearSolve(ops, b, &iterative.Landweber{Tau: tau}, iterative.Settings{
+			Tolerance:     1e-10,
+			MaxIterations: 1000,
+		})
+		if err != nil {
+			t.Errorf("Tau=%v: %v", tau, err)
+			continue
+		}
+		for i, v := range res.X {
+			if math.Abs(v-1/d[i]) > 1e-9 {
+				t.Errorf("Tau=%v: unexpected solution %v at %d, want %v", tau, v, i, 1/d[i])
+				break
+			}
+		}
+	}
+	// With Tau larger than 2/|A|^2 the
+	// iteration diverges.
+	_, err := iterative.LinearSolve(ops, b, &iterative.Landweber{Tau: 3}, iterative.Settings{
+		MaxIterations: 1000,
+	})
+	if err == nil {
+		t.Errorf("no error with too large a step length")
+	}
+}
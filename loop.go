@@ -0,0 +1,437 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+var errIterationLimit = errors.New("iterative: iteration limit reached")
+
+// Loop is the reverse-communication loop of LinearSolve split into steps.
+// Each call to Step calls Method.Iterate once and performs the commanded
+// operation with the matrix operations and the settings of the Loop,
+// including the convergence check and the update of the statistics.
+// Between the steps the caller can inspect the state of the solve, which
+// allows embedding it in an outer iteration. LinearSolve is equivalent to
+// calling Step until it reports that the solve is done.
+type Loop struct {
+	a        MatrixOps
+	b        []float64
+	method   Method
+	settings Settings
+
+	// rows and cols are the dimensions of A.
+	rows, cols int
+	// leastSquares indicates whether the
+	// stopping criterion of a rectangular
+	// system is used.
+	leastSquares bool
+
+	ctx   Context
+	stats Stats
+	bnorm float64
+	xnorm float64 // |x| at the last EndIteration if NormA is set.
+	op    Operation
+
+	// rnorm0 is the initial residual norm.
+	rnorm0 float64
+	// recent holds the residual norms of the
+	// last Settings.RateWindow+1 iterations,
+	// the norm of the iteration i at
+	// i%len(recent) with r_0 at 0.
+	recent []float64
+
+	// bx is the workspace of the B-norms
+	// with Settings.Weight.
+	bx []float64
+
+	// scale is the factor by which b, x and
+	// the residual of the system are scaled,
+	// see scaleFor.
+	scale float64
+
+	started bool
+	done    bool
+	err     error
+}
+
+// NewLoop returns a new Loop for solving the system of linear equations
+//  A*x = b
+// with the given method and settings. The arguments have the same meaning
+// and requirements as in LinearSolve, and NewLoop panics in the same
+// cases, including those of a rectangular system. The initial residual is
+// computed by NewLoop, the method is initialized by the first call to Step.
+//
+// If the norm of b is very large or very small, the system is scaled by a
+// power of two close to 1/|b| so that the method operates on data of
+// moderate magnitude, see LinearSolve. The Context then holds the
+// approximate solution and the residual of the scaled system until the
+// solve is done.
+func NewLoop(a MatrixOps, b []float64, method Method, settings Settings) *Loop {
+	l := &Loop{
+		a:      a,
+		b:      b,
+		method: method,
+		stats:  Stats{StartTime: time.Now()},
+		scale:  1,
+	}
+
+	if a.MatVec == nil {
+		panic("iterative: nil matrix-vector multiplication")
+	}
+	rows, cols := a.dims(len(b))
+	l.rows, l.cols = rows, cols
+	l.leastSquares = rows != cols || solvesLeastSquares(method)
+	if rows != cols {
+		if _, ok := method.(RectMethod); !ok {
+			panic("iterative: method does not support rectangular matrices")
+		}
+		if a.MatTransVec == nil {
+			panic("iterative: nil transposed matrix-vector multiplication for rectangular matrix")
+		}
+	}
+	if settings.X0 != nil && len(settings.X0) != cols {
+		panic("iterative: mismatched length of initial guess")
+	}
+	if settings.InPlace && settings.X0 == nil {
+		panic("iterative: nil initial guess for in-place solve")
+	}
+
+	if rows == 0 || cols == 0 {
+		l.settings = settings
+		l.finish(nil)
+		return l
+	}
+
+	defaultSettings(&settings, cols)
+	if err := checkSettings(&settings); err != nil {
+		panic(err.Error())
+	}
+	l.settings = settings
+
+	if settings.Debug && settings.PSolve != nil && needsSPDPreconditioner(method) {
+		p := psolver{psolve: settings.PSolve, psolveTrans: settings.PSolveTrans}
+		err := CheckSymmetricPreconditioner(p, cols, rand.New(rand.NewSource(1)))
+		if err != nil {
+			l.finish(err)
+			return l
+		}
+	}
+
+	vec := newVecOps(settings.Threads)
+	ctx := &l.ctx
+	ctx.Residual = make([]float64, rows)
+	ctx.vec = vec
+	if settings.InPlace {
+		ctx.X = settings.X0
+	} else {
+		ctx.X = make([]float64, cols)
+	}
+	if settings.X0 != nil && !settings.InPlace {
+		vec.copy(ctx.X, settings.X0)
+	}
+	l.scale = scaleFor(vec.norm(b))
+	if l.scale != 1 {
+		// Solve A*(s*x) = s*b. Since s is a
+		// power of two, the scaling is exact.
+		l.b = make([]float64, rows)
+		vec.copy(l.b, b)
+		vec.scale(l.scale, l.b)
+		vec.scale(l.scale, ctx.X)
+		l.settings.NoiseLevel *= l.scale
+		b = l.b
+	}
+	if settings.X0 != nil {
+		a.MatVec(ctx.Residual, ctx.X)
+		l.stats.MatVec++
+		vec.addScaledTo(ctx.Residual, b, -1, ctx.Residual) // r = b - Ax
+	} else {
+		vec.copy(ctx.Residual, b) // r = b
+	}
+
+	if settings.Weight != nil {
+		l.bx = make([]float64, rows)
+	}
+	var err error
+	ctx.ResidualNorm, err = l.norm(ctx.Residual)
+	// The rates are computed from the norms
+	// of the residual of the unscaled system.
+	l.rnorm0 = ctx.ResidualNorm / l.scale
+	if settings.RateWindow > 0 {
+		l.recent = make([]float64, settings.RateWindow+1)
+		l.recent[0] = l.rnorm0
+	}
+	// The initial residual is compared
+	// with the tolerance in absolute terms,
+	// after scaling, so that a system with
+	// a tiny b is not solved trivially.
+	if err != nil || l.settings.converged(ctx.ResidualNorm, 1, 0) {
+		l.finish(err)
+	}
+	return l
+}
+
+// Step calls Method.Iterate once and performs the returned operation. It
+// returns whether the solve is done, either because the method converged
+// or because of an error. After the solve is done, Step does nothing and
+// returns true and the same error.
+func (l *Loop) Step() (done bool, err error) {
+	if l.done {
+		return true, l.err
+	}
+	ctx := &l.ctx
+	if !l.started {
+		l.started = true
+		l.bnorm, err = l.norm(l.b)
+		if err != nil {
+			l.finish(err)
+			return true, err
+		}
+		if l.bnorm == 0 {
+			l.bnorm = 1
+		}
+		if l.settings.NormA == 0 && (l.settings.EstimateNormA || l.leastSquares) {
+			l.settings.NormA = l.estimateNormA()
+		}
+		if l.settings.NormA != 0 {
+			l.xnorm = ctx.vec.norm(ctx.X)
+		}
+		if l.rows != l.cols {
+			l.method.(RectMethod).InitRect(l.rows, l.cols)
+		} else {
+			l.method.Init(l.rows)
+		}
+	}
+	err = l.step()
+	if err != nil || (l.op == EndIteration && ctx.Converged) {
+		l.finish(err)
+	}
+	return l.done, l.err
+}
+
+// step performs one operation.
+func (l *Loop) step() error {
+	ctx := &l.ctx
+	a, b := l.a, l.b
+	vec := ctx.vec
+	settings := &l.settings
+	stats := &l.stats
+
+	// unset marks ResidualNorm in debug mode
+	// as not updated by the method.
+	unset := math.Float64frombits(unsetNormBits)
+	var norm float64
+	if settings.Debug {
+		norm = ctx.ResidualNorm
+		ctx.ResidualNorm = unset
+	}
+	op, err := l.method.Iterate(ctx)
+	l.op = op
+	if err != nil {
+		return err
+	}
+	err = checkOperation(op, ctx, l.rows, l.cols)
+	if err == nil && settings.Debug {
+		err = checkResidualNorm(op, ctx)
+		if math.Float64bits(ctx.ResidualNorm) == unsetNormBits {
+			ctx.ResidualNorm = norm
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("iterative: %T: %v", l.method, err)
+	}
+
+	switch op {
+	case NoOperation:
+
+	case ComputeResidual:
+		a.MatVec(ctx.Residual, ctx.X)
+		stats.MatVec++
+		vec.addScaledTo(ctx.Residual, b, -1, ctx.Residual)
+
+	case MatVec, MatTransVec:
+		if op == MatVec {
+			a.MatVec(ctx.Dst, ctx.Src)
+		} else {
+			a.MatTransVec(ctx.Dst, ctx.Src)
+		}
+		stats.MatVec++
+
+	case PSolve, PSolveTrans:
+		if settings.PSolve == nil {
+			vec.copy(ctx.Dst, ctx.Src)
+			return nil
+		}
+		if op == PSolve {
+			err = settings.PSolve(ctx.Dst, ctx.Src)
+		} else {
+			err = settings.PSolveTrans(ctx.Dst, ctx.Src)
+		}
+		if err != nil {
+			return err
+		}
+		stats.PSolve++
+
+	case WeightVec:
+		if settings.Weight == nil {
+			vec.copy(ctx.Dst, ctx.Src)
+			return nil
+		}
+		settings.Weight(ctx.Dst, ctx.Src)
+		stats.WeightVec++
+
+	case CheckResidualNorm:
+		if l.leastSquares {
+			ctx.Converged = settings.convergedLS(ctx.ResidualNorm, ctx.NormalResidualNorm, l.bnorm, l.xnorm)
+		} else {
+			ctx.Converged = settings.converged(ctx.ResidualNorm, l.bnorm, l.xnorm)
+		}
+
+	case EndIteration:
+		stats.Iterations++
+		stats.Restarts = ctx.Restarts
+		stats.ResidualNorm = ctx.ResidualNorm / l.scale
+		if l.recent != nil {
+			l.recent[stats.Iterations%len(l.recent)] = stats.ResidualNorm
+		}
+		if settings.NormA != 0 {
+			l.xnorm = vec.norm(ctx.X)
+		}
+		if !ctx.Converged && stats.Iterations == settings.MaxIterations {
+			return errIterationLimit
+		}
+
+	default:
+		panic("iterate: invalid operation")
+	}
+	return nil
+}
+
+// estimateNormA returns the estimate of |A|_2 for Settings.EstimateNormA
+// or a least-squares problem and counts its products in the statistics.
+func (l *Loop) estimateNormA() float64 {
+	counted := func(matVec func(dst, x []float64)) func(dst, x []float64) {
+		if matVec == nil {
+			return nil
+		}
+		return func(dst, x []float64) {
+			matVec(dst, x)
+			l.stats.MatVec++
+		}
+	}
+	a := MatrixOps{
+		MatVec:      counted(l.a.MatVec),
+		MatTransVec: counted(l.a.MatTransVec),
+		Rows:        l.a.Rows,
+		Cols:        l.a.Cols,
+	}
+	return EstimateTwoNorm(a, l.cols, 20, rand.New(rand.NewSource(1)))
+}
+
+// norm returns the Euclidean norm of x, or its B-norm if Settings.Weight
+// is set.
+func (l *Loop) norm(x []float64) (float64, error) {
+	vec := l.ctx.vec
+	if l.settings.Weight == nil {
+		return vec.norm(x), nil
+	}
+	l.settings.Weight(l.bx, x)
+	l.stats.WeightVec++
+	xbx := vec.dot(x, l.bx)
+	if xbx < 0 {
+		return 0, errors.New("iterative: weight not positive definite")
+	}
+	return math.Sqrt(xbx), nil
+}
+
+// finish marks the solve as done with the given error and computes the
+// convergence rates.
+func (l *Loop) finish(err error) {
+	l.done = true
+	l.err = err
+	l.stats.Runtime = time.Since(l.stats.StartTime)
+	if l.scale != 1 {
+		l.ctx.vec.scale(1/l.scale, l.ctx.X)
+		l.ctx.vec.scale(1/l.scale, l.ctx.Residual)
+		l.ctx.ResidualNorm /= l.scale
+	}
+
+	k := l.stats.Iterations
+	l.stats.ConvergenceRate = rate(l.rnorm0, l.stats.ResidualNorm, k)
+	l.stats.RecentRate = math.NaN()
+	if l.recent != nil {
+		w := len(l.recent) - 1
+		if k <= w {
+			l.stats.RecentRate = l.stats.ConvergenceRate
+		} else {
+			l.stats.RecentRate = rate(l.recent[(k-w)%len(l.recent)], l.stats.ResidualNorm, w)
+		}
+	}
+}
+
+// scaleFor returns the power of two close to 1/bnorm by which the system
+// with the right-hand side of norm bnorm is scaled. It returns 1 if bnorm
+// is between minUnscaled and maxUnscaled, where the products of the
+// vectors neither overflow nor underflow the breakdown thresholds of the
+// methods, and if bnorm is zero or not finite.
+func scaleFor(bnorm float64) float64 {
+	if minUnscaled <= bnorm && bnorm <= maxUnscaled || bnorm == 0 || math.IsInf(bnorm, 0) || math.IsNaN(bnorm) {
+		return 1
+	}
+	_, e := math.Frexp(bnorm)
+	return math.Ldexp(1, -e)
+}
+
+// minUnscaled and maxUnscaled are the bounds of the norm of b of the
+// systems that are not scaled.
+const (
+	minUnscaled = 0x1p-32
+	maxUnscaled = 0x1p32
+)
+
+// rate returns the mean reduction factor (to/from)^(1/k) of the residual
+// norm over k iterations. It returns NaN if k is zero or if a norm is zero
+// or not finite, except that it returns zero for to equal to zero.
+func rate(from, to float64, k int) float64 {
+	switch {
+	case k == 0, from == 0, math.IsNaN(from), math.IsInf(from, 0), math.IsNaN(to), math.IsInf(to, 0):
+		return math.NaN()
+	case to == 0:
+		return 0
+	}
+	return math.Pow(to/from, 1/float64(k))
+}
+
+// Context returns the Context of the solve. It is valid between the steps
+// and after the solve is done, and it must not be modified.
+func (l *Loop) Context() *Context {
+	return &l.ctx
+}
+
+// Operation returns the operation performed by the last call to Step, or
+// NoOperation before the first call.
+func (l *Loop) Operation() Operation {
+	return l.op
+}
+
+// Stats returns the statistics of the solve so far. Runtime and the
+// convergence rates are set only after the solve is done.
+func (l *Loop) Stats() Stats {
+	return l.stats
+}
+
+// Result returns the result of the solve. The approximate solution is the
+// current one if the solve is not done.
+func (l *Loop) Result() Result {
+	return Result{
+		X:     l.ctx.X,
+		Stats: l.stats,
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// TestLoop drives a CG solve by Loop.Step and checks the operations and
+// the iterates against the textbook CG iteration.
+func TestLoop(t *testing.T) {
+	const n = 50
+	a := diagDominant(n)
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = float64(i%7) - 3
+	}
+	settings := Settings{Tolerance: 1e-12}
+
+	// Textbook CG started from zero.
+	x := make([]float64, n)
+	r := append([]float64(nil), b...)
+	p := append([]float64(nil), b...)
+	ap := make([]float64, n)
+	rr := floats.Dot(r, r)
+
+	l := NewLoop(a, b, &CG{}, settings)
+	if l.Operation() != NoOperation {
+		t.Errorf("unexpected operation before the first step: %v", l.Operation())
+	}
+	want := []Operation{PSolve, MatVec, CheckResidualNorm, EndIteration}
+	var (
+		ops   int
+		iters int
+		done  bool
+		err   error
+	)
+	for !done {
+		done, err = l.Step()
+		op := l.Operation()
+		if op != want[ops%len(want)] {
+			t.Fatalf("step %d: unexpected operation %v, want %v", ops, op, want[ops%len(want)])
+		}
+		ops++
+		if op != EndIteration {
+			continue
+		}
+		iters++
+		a.MatVec(ap, p)
+		alpha := rr / floats.Dot(p, ap)
+		floats.AddScaled(x, alpha, p)
+		floats.AddScaled(r, -alpha, ap)
+		rrNew := floats.Dot(r, r)
+		floats.AddScaledTo(p, r, rrNew/rr, p)
+		rr = rrNew
+
+		ctx := l.Context()
+		if !floats.EqualApprox(ctx.X, x, 1e-12) {
+			t.Errorf("iteration %d: X differs from textbook CG", iters)
+		}
+		if !floats.EqualApprox(ctx.Residual, r, 1e-12) {
+			t.Errorf("iteration %d: Residual differs from textbook CG", iters)
+		}
+		if s := l.Stats(); s.Iterations != iters || s.MatVec != iters {
+			t.Errorf("iteration %d: unexpected stats %+v", iters, s)
+		}
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !l.Context().Converged {
+		t.Errorf("Loop done without convergence")
+	}
+	if done, err := l.Step(); !done || err != nil {
+		t.Errorf("unexpected step after the end: %v, %v", done, err)
+	}
+
+	got := l.Result()
+	res, err := LinearSolve(a, b, &CG{}, settings)
+	if err != nil {
+		t.Fatalf("LinearSolve: %v", err)
+	}
+	if !floats.Equal(got.X, res.X) {
+		t.Errorf("solution differs from LinearSolve")
+	}
+	if got.Stats.Iterations != res.Stats.Iterations || got.Stats.MatVec != res.Stats.MatVec ||
+		got.Stats.PSolve != res.Stats.PSolve || got.Stats.ResidualNorm != res.Stats.ResidualNorm {
+		t.Errorf("unexpected stats: want %+v, got %+v", res.Stats, got.Stats)
+	}
+	if got.Stats.Runtime == 0 {
+		t.Errorf("Runtime not set")
+	}
+}
+
+func TestLoopIterationLimit(t *testing.T) {
+	const n = 50
+	a := diagDominant(n)
+	l := NewLoop(a, ones(n), &CG{}, Settings{Tolerance: 1e-12, MaxIterations: 3})
+	var steps int
+	for {
+		done, err := l.Step()
+		steps++
+		if done {
+			if err != errIterationLimit {
+				t.Errorf("unexpected error: %v", err)
+			}
+			break
+		}
+	}
+	if steps != 3*4 {
+		t.Errorf("unexpected number of steps: want 12, got %d", steps)
+	}
+	if l.Stats().Iterations != 3 {
+		t.Errorf("unexpected number of iterations: %d", l.Stats().Iterations)
+	}
+}
+
+func TestLoopZeroResidual(t *testing.T) {
+	l := NewLoop(diagDominant(10), make([]float64, 10), &CG{}, Settings{})
+	done, err := l.Step()
+	if !done || err != nil {
+		t.Errorf("unexpected step for zero right-hand side: %v, %v", done, err)
+	}
+	if l.Operation() != NoOperation {
+		t.Errorf("unexpected operation %v", l.Operation())
+	}
+}
+
+// linearMethod is a mock Method whose residual norm decreases by the factor
+// q in each of the first k iterations and by q2 afterwards.
+type linearMethod struct {
+	q, q2  float64
+	k      int
+	i      int
+	resume int
+}
+
+func (m *linearMethod) Init(dim int) {
+	m.i = 0
+	m.resume = 1
+}
+
+func (m *linearMethod) Iterate(ctx *Context) (Operation, error) {
+	switch m.resume {
+	case 1:
+		m.i++
+		if m.i <= m.k {
+			ctx.ResidualNorm *= m.q
+		} else {
+			ctx.ResidualNorm *= m.q2
+		}
+		m.resume = 2
+		return CheckResidualNorm, nil
+	case 2:
+		m.resume = 1
+		return EndIteration, nil
+	}
+	panic("unreachable")
+}
+
+func TestLoopConvergenceRate(t *testing.T) {
+	const n = 16
+	b := ones(n)
+	a := diagDominant(n)
+	// The residual norm decreases by 0.5 in
+	// 10 iterations and then by 0.9, so the
+	// relative tolerance 1e-6 is reached in
+	// the 66th iteration with the factor 0.9.
+	const k = 76
+	want := math.Pow(math.Pow(0.5, 10)*math.Pow(0.9, 66), 1.0/k)
+	for _, test := range []struct {
+		window int
+		recent float64
+	}{
+		{0, math.NaN()},
+		{1, 0.9},
+		{20, 0.9},
+		{66, 0.9},
+		{67, math.Pow(0.5*math.Pow(0.9, 66), 1.0/67)},
+		{k, want},
+		{200, want},
+	} {
+		res, err := LinearSolve(a, b, &linearMethod{q: 0.5, q2: 0.9, k: 10}, Settings{
+			Tolerance:     1e-6,
+			MaxIterations: 1000,
+			RateWindow:    test.window,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Stats.Iterations != k {
+			t.Fatalf("unexpected number of iterations: %d", res.Stats.Iterations)
+		}
+		if math.Abs(res.Stats.ConvergenceRate-want) > 1e-12 {
+			t.Errorf("window=%d: unexpected rate %v, want %v", test.window, res.Stats.ConvergenceRate, want)
+		}
+		got := res.Stats.RecentRate
+		if math.IsNaN(test.recent) != math.IsNaN(got) || math.Abs(got-test.recent) > 1e-12 {
+			t.Errorf("window=%d: unexpected recent rate %v, want %v", test.window, got, test.recent)
+		}
+	}
+
+	// Zero initial residual.
+	res, err := LinearSolve(a, make([]float64, n), &CG{}, Settings{RateWindow: 5})
+	if err != nil || res.Stats.Iterations != 0 {
+		t.Fatalf("unexpected result with zero b: %v, %d iterations", err, res.Stats.Iterations)
+	}
+	if !math.IsNaN(res.Stats.ConvergenceRate) || !math.IsNaN(res.Stats.RecentRate) {
+		t.Errorf("rates %v and %v with zero initial residual, want NaN", res.Stats.ConvergenceRate, res.Stats.RecentRate)
+	}
+	// Zero final residual.
+	res, err = LinearSolve(a, b, &linearMethod{q: 0.5, q2: 0, k: 2}, Settings{RateWindow: 5})
+	if err != nil || res.Stats.Iterations != 3 {
+		t.Fatalf("unexpected result with zero final residual: %v, %d iterations", err, res.Stats.Iterations)
+	}
+	if res.Stats.ConvergenceRate != 0 || res.Stats.RecentRate != 0 {
+		t.Errorf("rates %v and %v with zero final residual, want 0", res.Stats.ConvergenceRate, res.Stats.RecentRate)
+	}
+
+	// CG reduces the residual norm in each
+	// iteration of the well-conditioned
+	// problem by a similar factor.
+	const m = 200
+	a = diagDominant(m)
+	b = make([]float64, m)
+	a.MatVec(b, ones(m))
+	res, err = LinearSolve(a, b, &CG{}, Settings{Tolerance: 1e-10, RateWindow: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r0 := floats.Norm(b, 2)
+	rate := res.Stats.ConvergenceRate
+	if rate <= 0 || rate >= 1 {
+		t.Errorf("CG rate %v not in (0,1)", rate)
+	}
+	if got := r0 * math.Pow(rate, float64(res.Stats.Iterations)); math.Abs(got-res.Stats.ResidualNorm) > 1e-8*res.Stats.ResidualNorm {
+		t.Errorf("CG rate %v inconsistent with the final residual norm %v", rate, res.Stats.ResidualNorm)
+	}
+	if recent := res.Stats.RecentRate; recent <= 0 || recent >= 1 {
+		t.Errorf("CG recent rate %v not in (0,1)", recent)
+	}
+}
+
+func TestLoopConvergenceRateScaling(t *testing.T) {
+	// The system with a very large or very small b
+	// is solved scaled, the rates must be those of
+	// the unscaled system.
+	const n = 200
+	a := diagDominant(n)
+	b1 := make([]float64, n)
+	a.MatVec(b1, ones(n))
+	settings := Settings{Tolerance: 1e-10, RateWindow: 5}
+	want, err := LinearSolve(a, b1, &CG{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mag := range []float64{1e40, 1e-40} {
+		b := make([]float64, n)
+		floats.ScaleTo(b, mag, b1)
+		got, err := LinearSolve(a, b, &CG{}, settings)
+		if err != nil {
+			t.Errorf("b scaled by %v: unexpected error: %v", mag, err)
+			continue
+		}
+		if got.Stats.Iterations != want.Stats.Iterations {
+			t.Errorf("b scaled by %v: %d iterations, want %d", mag, got.Stats.Iterations, want.Stats.Iterations)
+		}
+		if r := got.Stats.ConvergenceRate; math.Abs(r-want.Stats.ConvergenceRate) > 1e-8 {
+			t.Errorf("b scaled by %v: unexpected rate %v, want %v", mag, r, want.Stats.ConvergenceRate)
+		}
+		if r := got.Stats.RecentRate; math.Abs(r-want.Stats.RecentRate) > 1e-8 {
+			t.Errorf("b scaled by %v: unexpected recent rate %v, want %v", mag, r, want.Stats.RecentRate)
+		}
+	}
+}
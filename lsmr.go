@@ -0,0 +1,275 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// LSMR implements the LSMR iterative method for solving the damped
+// least-squares problem
+//  min |b - A x|^2 + Damp^2 |x|^2,
+// which for Damp equal to zero and a square nonsingular A is the system of
+// linear equations
+//  Ax = b.
+// LSMR is based on the Golub-Kahan bidiagonalization of A and is
+// mathematically equivalent to MINRES applied to the normal equations
+//  (A^T A + Damp^2 I) x = A^T b,
+// so the norm of the residual of the normal equations decreases
+// monotonically. This makes LSMR suitable for stopping early, for example
+// on problems with noisy data.
+//
+// LSMR is a RectMethod. It does not form the residual, it estimates both
+// the norm of the residual
+//  [b; 0] - [A; Damp I] x,
+// and the norm of the residual of the normal equations
+//  A^T (b - A x) - Damp^2 x,
+// which it reports in Context.NormalResidualNorm. With a nonzero Damp the
+// solution does not satisfy Ax = b, so the solve stops when either
+// estimate satisfies the stopping criterion of a rectangular system, see
+// Settings.Tolerance, also for a square A.
+//
+// If the bidiagonalization terminates at an iterate that is not a
+// solution, which happens for a singular square A when b is not in its
+// range, Iterate returns a *BreakdownError.
+//
+// References:
+//  - Fong, D. C.-L., Saunders, M. A.: LSMR: An iterative algorithm for
+//    sparse least-squares problems. SIAM J. Sci. Comput. 33(5),
+//    2950-2971 (2011)
+//
+// LSMR needs MatVec and MatTransVec matrix operations, it does not use a
+// preconditioner.
+type LSMR struct {
+	// Damp is the damping parameter. It must
+	// not be negative.
+	Damp float64
+
+	resume int
+
+	// alpha and beta are the last diagonal and
+	// subdiagonal elements of the lower
+	// bidiagonal matrix.
+	alpha, beta float64
+
+	// Variables of the QR factorizations of the
+	// bidiagonal and of the upper bidiagonal
+	// matrix R_k^T.
+	alphabar, rho, rhobar float64
+	cbar, sbar            float64
+	zeta, zetabar         float64
+
+	// Variables of the estimate of |r|.
+	betadd, betad           float64
+	rhodold                 float64
+	tautildeold, thetatilde float64
+	d                       float64
+
+	// u has the length of b, the other vectors
+	// the length of x.
+	u, au   []float64
+	v, atu  []float64
+	h, hbar []float64
+}
+
+// Init implements the Method interface.
+func (l *LSMR) Init(dim int) {
+	l.InitRect(dim, dim)
+}
+
+// InitRect implements the RectMethod interface.
+func (l *LSMR) InitRect(m, n int) {
+	if m <= 0 || n <= 0 {
+		panic("LSMR: dimension not positive")
+	}
+	if l.Damp < 0 {
+		panic("LSMR: negative damping parameter")
+	}
+
+	l.u = reuse(l.u, m)
+	l.au = reuse(l.au, m)
+	l.v = reuse(l.v, n)
+	l.atu = reuse(l.atu, n)
+	l.h = reuse(l.h, n)
+	l.hbar = reuse(l.hbar, n)
+	l.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (l *LSMR) Iterate(ctx *Context) (Operation, error) {
+	switch l.resume {
+	case 1:
+		// β_1 u_1 = r_0
+		ctx.vec.copy(l.u, ctx.Residual)
+		l.beta = ctx.vec.norm(l.u)
+		ctx.vec.scale(1/l.beta, l.u)
+		ctx.Src = l.u
+		ctx.Dst = l.v
+		l.resume = 2
+		return MatTransVec, nil
+		// Compute A^T u_1.
+	case 2:
+		// α_1 v_1 = A^T u_1
+		l.alpha = ctx.vec.norm(l.v)
+		l.alphabar = l.alpha
+		l.zetabar = l.alpha * l.beta
+		l.rho = 1
+		l.rhobar = 1
+		l.cbar = 1
+		l.sbar = 0
+		l.zeta = 0
+		l.betadd = l.beta
+		l.betad = 0
+		l.rhodold = 1
+		l.tautildeold = 0
+		l.thetatilde = 0
+		l.d = 0
+		if l.alpha == 0 {
+			// A^T r_0 is zero, x_0 is a
+			// least-squares solution.
+			ctx.ResidualNorm = l.beta
+			ctx.NormalResidualNorm = 0
+			ctx.Src = nil
+			ctx.Dst = nil
+			ctx.Converged = false
+			l.resume = 6
+			return CheckResidualNorm, nil
+		}
+		ctx.vec.scale(1/l.alpha, l.v)
+		ctx.vec.copy(l.h, l.v)
+		for i := range l.hbar {
+			l.hbar[i] = 0
+		}
+		fallthrough
+	case 3:
+		ctx.Src = l.v
+		ctx.Dst = l.au
+		l.resume = 4
+		return MatVec, nil
+		// Compute A v_k.
+	case 4:
+		// β_{k+1} u_{k+1} = A v_k - α_k u_k
+		ctx.vec.addScaledTo(l.u, l.au, -l.alpha, l.u)
+		l.beta = ctx.vec.norm(l.u)
+		if l.beta == 0 {
+			return l.update(ctx)
+		}
+		ctx.vec.scale(1/l.beta, l.u)
+		ctx.Src = l.u
+		ctx.Dst = l.atu
+		l.resume = 5
+		return MatTransVec, nil
+		// Compute A^T u_{k+1}.
+	case 5:
+		// α_{k+1} v_{k+1} = A^T u_{k+1} - β_{k+1} v_k
+		ctx.vec.addScaledTo(l.v, l.atu, -l.beta, l.v)
+		l.alpha = ctx.vec.norm(l.v)
+		if l.alpha != 0 {
+			ctx.vec.scale(1/l.alpha, l.v)
+		}
+		return l.update(ctx)
+	case 6:
+		if ctx.Converged {
+			l.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = false
+			return EndIteration, nil
+		}
+		switch {
+		case l.alpha == 0:
+			l.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"LSMR", "alpha"}
+		case l.beta == 0:
+			l.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"LSMR", "beta"}
+		}
+		l.resume = 3
+		ctx.ResidualCurrent = false
+		return EndIteration, nil
+
+	default:
+		panic("LSMR: Init not called")
+	}
+}
+
+// update applies the plane rotations of the iteration to the
+// bidiagonal matrix, updates X, and commands CheckResidualNorm with the
+// estimates of the residual norms.
+func (l *LSMR) update(ctx *Context) (Operation, error) {
+	// Eliminate the damping parameter.
+	chat, shat, alphahat := symOrtho(l.alphabar, l.Damp)
+
+	// Rotation Q_{k,k+1} of the bidiagonal
+	// matrix.
+	rhoold := l.rho
+	c, s, rho := symOrtho(alphahat, l.beta)
+	l.rho = rho
+	thetanew := s * l.alpha
+	l.alphabar = c * l.alpha
+
+	// Rotation Qbar_{k,k+1} of R_k^T.
+	rhobarold := l.rhobar
+	zetaold := l.zeta
+	thetabar := l.sbar * l.rho
+	l.cbar, l.sbar, l.rhobar = symOrtho(l.cbar*l.rho, thetanew)
+	l.zeta = l.cbar * l.zetabar
+	l.zetabar = -l.sbar * l.zetabar
+
+	// hbar_k = h_k - (θbar_k ρ_k / (ρ_{k-1} ρbar_{k-1})) hbar_{k-1}
+	ctx.vec.scale(-thetabar*l.rho/(rhoold*rhobarold), l.hbar)
+	ctx.vec.add(l.hbar, l.h)
+	// x_k = x_{k-1} + (ζ_k / (ρ_k ρbar_k)) hbar_k
+	ctx.vec.addScaled(ctx.X, l.zeta/(l.rho*l.rhobar), l.hbar)
+	// h_{k+1} = v_{k+1} - (θ_{k+1} / ρ_k) h_k
+	ctx.vec.addScaledTo(l.h, l.v, -thetanew/l.rho, l.h)
+
+	// Estimate |r| from the QR factorization
+	// of the bidiagonal matrix applied to
+	// β_1 e_1.
+	betaacute := chat * l.betadd
+	betacheck := -shat * l.betadd
+	betahat := c * betaacute
+	l.betadd = -s * betaacute
+
+	thetatildeold := l.thetatilde
+	ctildeold, stildeold, rhotildeold := symOrtho(l.rhodold, thetabar)
+	l.thetatilde = stildeold * l.rhobar
+	l.rhodold = ctildeold * l.rhobar
+	l.betad = -stildeold*l.betad + ctildeold*betahat
+
+	l.tautildeold = (zetaold - thetatildeold*l.tautildeold) / rhotildeold
+	taud := (l.zeta - l.thetatilde*l.tautildeold) / l.rhodold
+	l.d += betacheck * betacheck
+	rd := l.betad - taud
+
+	ctx.ResidualNorm = math.Sqrt(l.d + rd*rd + l.betadd*l.betadd)
+	ctx.NormalResidualNorm = math.Abs(l.zetabar)
+	ctx.Src = nil
+	ctx.Dst = nil
+	ctx.Converged = false
+	l.resume = 6
+	return CheckResidualNorm, nil
+}
+
+// symOrtho returns the plane rotation that eliminates b from [a; b] as
+//  [ c s] [a]   [r]
+//  [-s c] [b] = [0]
+// with r non-negative.
+func symOrtho(a, b float64) (c, s, r float64) {
+	switch {
+	case b == 0:
+		if a == 0 {
+			return 1, 0, 0
+		}
+		return math.Copysign(1, a), 0, math.Abs(a)
+	case a == 0:
+		return 0, math.Copysign(1, b), math.Abs(b)
+	case math.Abs(b) > math.Abs(a):
+		tau := a / b
+		s = math.Copysign(1, b) / math.Sqrt(1+tau*tau)
+		return s * tau, s, b / s
+	default:
+		tau := b / a
+		c = math.Copysign(1, a) / math.Sqrt(1+tau*tau)
+		return c, c * tau, a / c
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestLSMR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		m, n int
+	}{
+		{1, 1},
+		{10, 10},
+		{50, 50},
+		{40, 10},
+		{100, 30},
+		{10, 40},
+	} {
+		m, n := test.m, test.n
+		a, _ := rectangular(m, n, rnd)
+		ops := MatrixOps{MatVec: a.MulVec, MatTransVec: a.MulTransVec, Rows: m, Cols: n}
+
+		// On a consistent system LSMR converges
+		// to the solution like Landweber, which
+		// also solves least-squares problems.
+		xWant := make([]float64, n)
+		for i := range xWant {
+			xWant[i] = rnd.NormFloat64()
+		}
+		if m < n {
+			// The minimum-norm solution is in
+			// the range of A^T.
+			y := make([]float64, m)
+			for i := range y {
+				y[i] = rnd.NormFloat64()
+			}
+			a.MulTransVec(xWant, y)
+		}
+		b := make([]float64, m)
+		a.MulVec(b, xWant)
+
+		settings := Settings{Tolerance: 1e-12, MaxIterations: 10000}
+		res, err := LinearSolve(ops, b, &LSMR{}, settings)
+		if err != nil {
+			t.Errorf("m=%d,n=%d: unexpected error: %v", m, n, err)
+			continue
+		}
+		lw, err := LinearSolve(ops, b, &Landweber{}, settings)
+		if err != nil {
+			t.Errorf("m=%d,n=%d: Landweber: unexpected error: %v", m, n, err)
+			continue
+		}
+		if dist := floats.Distance(res.X, xWant, math.Inf(1)); dist > 1e-9 {
+			t.Errorf("m=%d,n=%d: solution differs from the expected by %v", m, n, dist)
+		}
+		if dist := floats.Distance(res.X, lw.X, math.Inf(1)); dist > 1e-9 {
+			t.Errorf("m=%d,n=%d: solution differs from Landweber by %v", m, n, dist)
+		}
+		if res.Stats.Iterations > lw.Stats.Iterations {
+			t.Errorf("m=%d,n=%d: %d iterations, Landweber needs %d", m, n, res.Stats.Iterations, lw.Stats.Iterations)
+		}
+	}
+}
+
+func TestLSMRLeastSquares(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		m, n int
+		damp float64
+	}{
+		{40, 10, 0},
+		{40, 10, 0.5},
+		{10, 40, 0.5},
+		{30, 30, 0.1},
+		{30, 30, 2},
+	} {
+		m, n := test.m, test.n
+		a, d := rectangular(m, n, rnd)
+		ops := MatrixOps{MatVec: a.MulVec, MatTransVec: a.MulTransVec, Rows: m, Cols: n}
+		b := make([]float64, m)
+		for i := range b {
+			b[i] = rnd.NormFloat64()
+		}
+
+		// The solution of the damped normal
+		// equations
+		//  (A^T A + damp^2 I) x = A^T b.
+		var ata mat.Dense
+		ata.Mul(d.T(), d)
+		for i := 0; i < n; i++ {
+			ata.Set(i, i, ata.At(i, i)+test.damp*test.damp)
+		}
+		var atb, want mat.VecDense
+		atb.MulVec(d.T(), mat.NewVecDense(m, b))
+		if err := want.SolveVec(&ata, &atb); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := LinearSolve(ops, b, &LSMR{Damp: test.damp}, Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 1000,
+		})
+		if err != nil {
+			t.Errorf("m=%d,n=%d,damp=%v: unexpected error: %v", m, n, test.damp, err)
+			continue
+		}
+		if dist := floats.Distance(res.X, want.RawVector().Data, math.Inf(1)); dist > 1e-8 {
+			t.Errorf("m=%d,n=%d,damp=%v: solution differs from the expected by %v", m, n, test.damp, dist)
+		}
+
+		// The estimate of |r| is that of the
+		// damped system.
+		r := make([]float64, m)
+		a.MulVec(r, res.X)
+		floats.Sub(r, b)
+		xnorm := floats.Norm(res.X, 2)
+		rnorm := math.Hypot(floats.Norm(r, 2), test.damp*xnorm)
+		if math.Abs(res.Stats.ResidualNorm-rnorm) > 1e-8*rnorm {
+			t.Errorf("m=%d,n=%d,damp=%v: estimated residual norm %v, true %v", m, n, test.damp, res.Stats.ResidualNorm, rnorm)
+		}
+	}
+
+	if !panics(func() { (&LSMR{Damp: -1}).Init(10) }) {
+		t.Errorf("no panic with negative Damp")
+	}
+}
+
+func TestLSMRBreakdown(t *testing.T) {
+	// A is singular and A^T b is zero, the
+	// least-squares solution x = 0 does not
+	// solve the system.
+	ops := DiagonalOps([]float64{1, 0})
+	_, err := LinearSolve(ops, []float64{0, 1}, &LSMR{}, Settings{})
+	var breakdown *BreakdownError
+	if !errors.As(err, &breakdown) || breakdown.Method != "LSMR" {
+		t.Errorf("unexpected error %v, want an LSMR breakdown", err)
+	}
+}
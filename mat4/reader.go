@@ -0,0 +1,209 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mat4 reads sparse matrices stored in MATLAB Level 4 MAT-files,
+// as written by MATLAB's
+//  save -v4 file.mat A
+package mat4
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+var (
+	errV5      = errors.New("mat4: MATLAB v5 or later MAT-file, save the matrix with the -v4 option")
+	errComplex = errors.New("mat4: complex matrices not supported")
+)
+
+// Reader reads sparse matrices from a Level 4 MAT-file.
+type Reader struct {
+	r     *bufio.Reader
+	first bool
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r:     bufio.NewReader(r),
+		first: true,
+	}
+}
+
+// header is the fixed part of the header of a variable.
+type header struct {
+	typ, mrows, ncols, imagf, namlen int32
+}
+
+// Read returns the name and the value of the next sparse matrix stored in
+// the file. Full matrices and text variables are skipped. Read returns
+// io.EOF if there are no more sparse matrices in the file. Both little- and
+// big-endian files are supported. A MAT-file in the format of MATLAB v5 or
+// later is reported by an error, such files must be saved again with the
+// -v4 option.
+func (r *Reader) Read() (name string, m *sparse.Triplet, err error) {
+	for {
+		if r.first {
+			r.first = false
+			prefix, err := r.r.Peek(6)
+			if err == nil && string(prefix) == "MATLAB" {
+				return "", nil, errV5
+			}
+		}
+		name, rows, cols, isSparse, data, err := r.variable()
+		if err != nil {
+			return "", nil, err
+		}
+		if !isSparse {
+			continue
+		}
+		m, err := toTriplet(rows, cols, data)
+		if err != nil {
+			return "", nil, fmt.Errorf("mat4: variable %s: %v", name, err)
+		}
+		return name, m, nil
+	}
+}
+
+// variable reads the next variable and returns its name, dimensions,
+// whether it is a sparse matrix and its real part stored column-major.
+func (r *Reader) variable() (name string, rows, cols int, isSparse bool, data []float64, err error) {
+	var buf [20]byte
+	_, err = io.ReadFull(r.r, buf[:])
+	if err == io.ErrUnexpectedEOF {
+		err = errors.New("mat4: truncated header")
+	}
+	if err != nil {
+		return "", 0, 0, false, nil, err
+	}
+	order, h, err := decodeHeader(buf[:])
+	if err != nil {
+		return "", 0, 0, false, nil, err
+	}
+	if h.mrows < 0 || h.ncols < 0 || h.namlen < 1 {
+		return "", 0, 0, false, nil, errors.New("mat4: bad header")
+	}
+	prec := int(h.typ / 10 % 10)
+	class := int(h.typ % 10)
+
+	nameBuf := make([]byte, h.namlen)
+	if _, err = io.ReadFull(r.r, nameBuf); err != nil {
+		return "", 0, 0, false, nil, truncated(err)
+	}
+	name = string(bytes.TrimRight(nameBuf, "\x00"))
+
+	size := [...]int{8, 4, 4, 2, 2, 1}[prec]
+	n := int(h.mrows) * int(h.ncols)
+	raw := make([]byte, n*size)
+	if _, err = io.ReadFull(r.r, raw); err != nil {
+		return "", 0, 0, false, nil, truncated(err)
+	}
+	if h.imagf != 0 {
+		if class == 2 {
+			return "", 0, 0, false, nil, errComplex
+		}
+		// Skip the imaginary part of a full matrix.
+		if _, err = io.CopyN(io.Discard, r.r, int64(n*size)); err != nil {
+			return "", 0, 0, false, nil, truncated(err)
+		}
+	}
+	if class != 2 {
+		return name, int(h.mrows), int(h.ncols), false, nil, nil
+	}
+	data = make([]float64, n)
+	for k := range data {
+		b := raw[k*size:]
+		switch prec {
+		case 0:
+			data[k] = math.Float64frombits(order.Uint64(b))
+		case 1:
+			data[k] = float64(math.Float32frombits(order.Uint32(b)))
+		case 2:
+			data[k] = float64(int32(order.Uint32(b)))
+		case 3:
+			data[k] = float64(int16(order.Uint16(b)))
+		case 4:
+			data[k] = float64(order.Uint16(b))
+		case 5:
+			data[k] = float64(b[0])
+		}
+	}
+	return name, int(h.mrows), int(h.ncols), true, data, nil
+}
+
+// decodeHeader detects the byte order of the header in buf and decodes it.
+func decodeHeader(buf []byte) (binary.ByteOrder, header, error) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		typ := int32(order.Uint32(buf))
+		if typ < 0 || typ >= 10000 {
+			continue
+		}
+		m := typ / 1000
+		o := typ / 100 % 10
+		p := typ / 10 % 10
+		t := typ % 10
+		if o != 0 || p > 5 || t > 2 {
+			continue
+		}
+		if (m == 0 && order != binary.LittleEndian) || (m == 1 && order != binary.BigEndian) {
+			continue
+		}
+		if m > 1 {
+			return nil, header{}, errors.New("mat4: VAX and Cray number formats not supported")
+		}
+		h := header{
+			typ:    typ,
+			mrows:  int32(order.Uint32(buf[4:])),
+			ncols:  int32(order.Uint32(buf[8:])),
+			imagf:  int32(order.Uint32(buf[12:])),
+			namlen: int32(order.Uint32(buf[16:])),
+		}
+		return order, h, nil
+	}
+	return nil, header{}, errors.New("mat4: not a Level 4 MAT-file")
+}
+
+// toTriplet converts the MATLAB sparse encoding, an n×3 array of 1-based
+// row indices, column indices and values whose last row holds the
+// dimensions of the matrix, into a Triplet.
+func toTriplet(rows, cols int, data []float64) (*sparse.Triplet, error) {
+	if cols == 4 {
+		return nil, errComplex
+	}
+	if cols != 3 || rows < 1 {
+		return nil, fmt.Errorf("bad sparse array dimensions %d×%d", rows, cols)
+	}
+	nnz := rows - 1
+	ri, ci, v := data[:rows], data[rows:2*rows], data[2*rows:]
+	r, c := ri[nnz], ci[nnz]
+	if r < 0 || c < 0 || r != math.Trunc(r) || c != math.Trunc(c) {
+		return nil, fmt.Errorf("bad matrix dimensions %v×%v", r, c)
+	}
+	m := sparse.NewTriplet(int(r), int(c))
+	for k := 0; k < nnz; k++ {
+		i, j := ri[k], ci[k]
+		if i != math.Trunc(i) || i < 1 || r < i {
+			return nil, fmt.Errorf("row index %v out of range", i)
+		}
+		if j != math.Trunc(j) || j < 1 || c < j {
+			return nil, fmt.Errorf("column index %v out of range", j)
+		}
+		m.Append(int(i)-1, int(j)-1, v[k])
+	}
+	return m, nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("mat4: truncated variable")
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mat4
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestRead(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		name string
+		want [][]float64
+	}{
+		{
+			file: "square",
+			name: "A",
+			want: [][]float64{
+				{4, -1, 0, 0},
+				{-1, 4, -1, 0},
+				{0, -1, 4, 0},
+				{0, 0, 0, 2.5},
+			},
+		},
+		{
+			file: "rect",
+			name: "B",
+			want: [][]float64{
+				{1.5, 0, 0, 0, 0},
+				{0, 0, 0, 3.25, 0},
+				{0, -2, 0, 0, 1e-300},
+			},
+		},
+	} {
+		for _, endian := range []string{"le", "be"} {
+			file := test.file + "_" + endian + ".mat"
+			f, err := os.Open(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := NewReader(f)
+			name, m, err := r.Read()
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", file, err)
+				f.Close()
+				continue
+			}
+			if name != test.name {
+				t.Errorf("%v: unexpected name %q", file, name)
+			}
+			a := sparse.NewCSRFromTriplet(m)
+			rows, cols := a.Dims()
+			if rows != len(test.want) || cols != len(test.want[0]) {
+				t.Errorf("%v: unexpected dimensions %v×%v", file, rows, cols)
+			} else {
+				for i, row := range test.want {
+					for j, v := range row {
+						if a.At(i, j) != v {
+							t.Errorf("%v: unexpected element at (%v,%v), want %v, got %v", file, i, j, v, a.At(i, j))
+						}
+					}
+				}
+			}
+			if _, _, err := r.Read(); err != io.EOF {
+				t.Errorf("%v: want io.EOF after last matrix, got %v", file, err)
+			}
+			f.Close()
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		want string
+	}{
+		{"v5.mat", "mat4: MATLAB v5 or later MAT-file, save the matrix with the -v4 option"},
+		{"complex.mat", "mat4: variable C: mat4: complex matrices not supported"},
+	} {
+		f, err := os.Open(filepath.Join("testdata", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = NewReader(f).Read()
+		f.Close()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%v: unexpected error, want %q, got %v", test.file, test.want, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "square_le.mat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"empty", nil, "EOF"},
+		{"short header", data[:10], "mat4: truncated header"},
+		{"truncated data", data[:len(data)-4], "mat4: truncated variable"},
+		{"garbage", bytes.Repeat([]byte{0xff}, 20), "mat4: not a Level 4 MAT-file"},
+	} {
+		_, _, err := NewReader(bytes.NewReader(test.input)).Read()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: unexpected error, want %q, got %v", test.name, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package matload loads sparse matrices from files in any of the formats
+// supported by this module. The format is detected from the contents of the
+// file, not from its name.
+package matload
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/vladimir-ch/iterative/hb"
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/petscio"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// Format is a file format of a sparse matrix.
+type Format string
+
+// Formats detected by LoadMatrix.
+const (
+	MatrixMarket  Format = "Matrix Market"
+	HarwellBoeing Format = "Harwell-Boeing"
+	Cache         Format = "Matrix Market cache"
+	BinaryCSR     Format = "binary CSR"
+	PETSc         Format = "PETSc binary"
+)
+
+// Info describes a matrix loaded by LoadMatrix.
+type Info struct {
+	// Format is the detected format of the
+	// file.
+	Format Format
+	// Compressed is whether the file was
+	// compressed with gzip.
+	Compressed bool
+
+	// Rows and Cols are the dimensions of
+	// the matrix and NNZ the number of its
+	// stored elements. For a symmetric
+	// matrix both triangles are counted.
+	Rows, Cols, NNZ int
+
+	// Symmetric is whether the matrix is
+	// symmetric. It is taken from the header
+	// for formats that record the symmetry,
+	// otherwise it is computed from the
+	// values.
+	Symmetric bool
+}
+
+// sniffLen is the number of bytes examined to detect the format.
+const sniffLen = 512
+
+var (
+	gzipMagic     = []byte{0x1f, 0x8b}
+	mmarketMagic  = []byte("%%MatrixMarket")
+	cacheMagic    = []byte("MMCACHE\x00")
+	csrMagic      = []byte("GCSR")
+	matlabV5Magic = []byte("MATLAB")
+
+	// hbType matches the third line of a
+	// Harwell-Boeing header that starts with
+	// the matrix type.
+	hbType = regexp.MustCompile(`^[RPCrpc][SUHZRsuhzr][AEae] `)
+)
+
+const petscMatClassID = 1211216
+
+// LoadMatrix loads the matrix stored in the file at path. The format is
+// detected from the contents, a file compressed with gzip is decompressed
+// first. The supported formats are Matrix Market, Harwell-Boeing and
+// Rutherford-Boeing, the cache written by mmarket.LoadMatrixCached, the
+// binary form of sparse.CSR and the PETSc binary format. An error
+// describing the contents is returned for a file in another format.
+func LoadMatrix(path string) (*sparse.CSR, Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	defer f.Close()
+	a, info, err := load(f)
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, info, nil
+}
+
+func load(r io.Reader) (*sparse.CSR, Info, error) {
+	var info Info
+	br := bufio.NewReader(r)
+	prefix, _ := br.Peek(sniffLen)
+	if bytes.HasPrefix(prefix, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, Info{}, err
+		}
+		defer gz.Close()
+		info.Compressed = true
+		br = bufio.NewReader(gz)
+		prefix, _ = br.Peek(sniffLen)
+	}
+
+	var (
+		a   *sparse.CSR
+		err error
+	)
+	info.Format = detect(prefix)
+	switch info.Format {
+	case MatrixMarket:
+		mr := mmarket.NewReader(br)
+		var h mmarket.Header
+		h, err = mr.Header()
+		if err != nil {
+			break
+		}
+		info.Symmetric = h.Symmetric
+		a, err = mr.ReadCSR()
+	case HarwellBoeing:
+		hr := hb.NewReader(br)
+		var t *sparse.Triplet
+		t, err = hr.Read()
+		if err != nil {
+			break
+		}
+		info.Symmetric = hr.Header().Type[1] == 'S'
+		a = sparse.NewCSRFromTriplet(t)
+	case Cache:
+		a, _, err = mmarket.ReadCache(br)
+	case BinaryCSR:
+		a = &sparse.CSR{}
+		_, err = a.UnmarshalBinaryFrom(br)
+	case PETSc:
+		a, err = petscio.ReadMat(br)
+	default:
+		what := describe(prefix)
+		if info.Compressed {
+			what = "gzip-compressed " + what
+		}
+		return nil, Info{}, fmt.Errorf("matload: unsupported format, detected %s", what)
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	info.Rows, info.Cols = a.Dims()
+	info.NNZ = a.NNZ()
+	switch info.Format {
+	case Cache, BinaryCSR, PETSc:
+		info.Symmetric = info.Rows == info.Cols && sparse.Analyze(a).Symmetric(0)
+	}
+	return a, info, nil
+}
+
+// detect returns the format of a file that starts with prefix, or an empty
+// Format if it is not recognized.
+func detect(prefix []byte) Format {
+	switch {
+	case bytes.HasPrefix(prefix, mmarketMagic):
+		return MatrixMarket
+	case bytes.HasPrefix(prefix, cacheMagic):
+		return Cache
+	case bytes.HasPrefix(prefix, csrMagic):
+		return BinaryCSR
+	case len(prefix) >= 4 && binary.BigEndian.Uint32(prefix) == petscMatClassID,
+		len(prefix) >= 8 && binary.BigEndian.Uint64(prefix) == petscMatClassID:
+		return PETSc
+	}
+	lines := bytes.SplitN(prefix, []byte("\n"), 4)
+	if len(lines) == 4 && hbType.Match(lines[2]) {
+		return HarwellBoeing
+	}
+	return ""
+}
+
+// describe returns a short description of the contents of a file that
+// starts with prefix.
+func describe(prefix []byte) string {
+	switch {
+	case len(prefix) == 0:
+		return "empty file"
+	case bytes.HasPrefix(prefix, matlabV5Magic):
+		return "MATLAB v5 or later MAT-file"
+	case utf8.Valid(prefix) && bytes.IndexByte(prefix, 0) < 0:
+		line := prefix
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		if len(line) > 40 {
+			line = line[:40]
+		}
+		return fmt.Sprintf("text starting with %q", line)
+	}
+	if len(prefix) > 8 {
+		prefix = prefix[:8]
+	}
+	return fmt.Sprintf("binary data starting with % x", prefix)
+}
@@ -0,0 +1,164 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package matload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/petscio"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestLoadMatrix(t *testing.T) {
+	dir := t.TempDir()
+	unsym, err := mmarket.LoadMatrix(filepath.Join("..", "hb", "testdata", "unsym.mtx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sym, err := mmarket.LoadMatrix(filepath.Join("..", "hb", "testdata", "sym.mtx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files in the other formats are written from the Matrix Market ones.
+	write := func(name string, data []byte, compress bool) string {
+		if compress {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write(data)
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+			data = buf.Bytes()
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	read := func(path string) []byte {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	var cache, petsc bytes.Buffer
+	if err := mmarket.WriteCache(&cache, unsym, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := petscio.WriteMat(&petsc, sym, petscio.Int64); err != nil {
+		t.Fatal(err)
+	}
+	csr, err := sym.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtx := read(filepath.Join("..", "hb", "testdata", "unsym.mtx"))
+	rua := read(filepath.Join("..", "hb", "testdata", "unsym.rua"))
+	rsa := read(filepath.Join("..", "hb", "testdata", "sym.rsa"))
+
+	for _, test := range []struct {
+		path string
+		want *sparse.CSR
+		info Info
+	}{
+		{
+			path: write("a.mtx", mtx, false),
+			want: unsym,
+			info: Info{Format: MatrixMarket, Rows: 5, Cols: 5, NNZ: unsym.NNZ()},
+		},
+		{
+			path: write("a.mtx.gz", mtx, true),
+			want: unsym,
+			info: Info{Format: MatrixMarket, Compressed: true, Rows: 5, Cols: 5, NNZ: unsym.NNZ()},
+		},
+		{
+			path: write("a.rua", rua, false),
+			want: unsym,
+			info: Info{Format: HarwellBoeing, Rows: 5, Cols: 5, NNZ: unsym.NNZ()},
+		},
+		{
+			path: write("s.rsa.gz", rsa, true),
+			want: sym,
+			info: Info{Format: HarwellBoeing, Compressed: true, Rows: 4, Cols: 4, NNZ: sym.NNZ(), Symmetric: true},
+		},
+		{
+			path: write("a.csr", cache.Bytes(), false),
+			want: unsym,
+			info: Info{Format: Cache, Rows: 5, Cols: 5, NNZ: unsym.NNZ()},
+		},
+		{
+			path: write("s.bin", csr, true),
+			want: sym,
+			info: Info{Format: BinaryCSR, Compressed: true, Rows: 4, Cols: 4, NNZ: sym.NNZ(), Symmetric: true},
+		},
+		{
+			path: write("s.petsc", petsc.Bytes(), false),
+			want: sym,
+			info: Info{Format: PETSc, Rows: 4, Cols: 4, NNZ: sym.NNZ(), Symmetric: true},
+		},
+		{
+			// Matrix Market contents under a Rutherford-Boeing name.
+			path: write("misnamed.rb", mtx, false),
+			want: unsym,
+			info: Info{Format: MatrixMarket, Rows: 5, Cols: 5, NNZ: unsym.NNZ()},
+		},
+	} {
+		a, info, err := LoadMatrix(test.path)
+		name := filepath.Base(test.path)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", name, err)
+			continue
+		}
+		if info != test.info {
+			t.Errorf("%v: unexpected info\nwant %+v\ngot  %+v", name, test.info, info)
+		}
+		if !reflect.DeepEqual(a, test.want) {
+			t.Errorf("%v: unexpected matrix", name)
+		}
+	}
+}
+
+func TestLoadMatrixErrors(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("hello, world\n"))
+	w.Close()
+	for _, test := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "unsupported format, detected empty file"},
+		{"text", []byte("1 2 3\n4 5 6\n"), `unsupported format, detected text starting with "1 2 3"`},
+		{"gzip text", gz.Bytes(), `unsupported format, detected gzip-compressed text starting with "hello, world"`},
+		{"binary", []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, "unsupported format, detected binary data starting with 00 01 02 03 04 05 06 07"},
+		{"matlab", append([]byte("MATLAB 5.0 MAT-file"), 0, 1), "unsupported format, detected MATLAB v5 or later MAT-file"},
+		{"bad gzip", []byte{0x1f, 0x8b, 0}, "unexpected EOF"},
+		{"bad mtx", []byte("%%MatrixMarket matrix coordinate real general\n2 2 1\n3 1 1\n"), "mmarket"},
+	} {
+		path := filepath.Join(dir, test.name)
+		if err := os.WriteFile(path, test.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := LoadMatrix(path)
+		if err == nil || !strings.Contains(err.Error(), test.want) || !strings.HasPrefix(err.Error(), path+": ") {
+			t.Errorf("%v: unexpected error, want %q, got %v", test.name, test.want, err)
+		}
+	}
+	if _, _, err := LoadMatrix(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected error for a missing file")
+	}
+}
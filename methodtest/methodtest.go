@@ -0,0 +1,232 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package methodtest provides checks of the convergence properties of
+// iterative methods for use in tests. The checks solve a problem while
+// recording the history of the iteration and report the violations of a
+// property that the method must have in exact arithmetic, allowing for a
+// small floating-point slack.
+package methodtest
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+	"gonum.org/v1/gonum/floats"
+)
+
+// History is the convergence history of a solve recorded by Record.
+type History struct {
+	// ResidualNorms are the residual norms
+	// reported by the Method with each
+	// CheckResidualNorm operation.
+	ResidualNorms []float64
+
+	// X and Residual are the approximate
+	// solutions and the residuals at the
+	// start and at the end of each
+	// iteration. Methods that do not update
+	// them in every iteration, such as
+	// GMRES, leave them unchanged.
+	X, Residual [][]float64
+
+	// ResidualCurrent holds the value of
+	// Context.ResidualCurrent for each
+	// element of Residual. It is true for
+	// the initial residual.
+	ResidualCurrent []bool
+}
+
+// Record solves the problem p by LinearSolve with the given method and
+// settings and returns the result of the solve together with its history.
+// If settings.MaxIterations is zero, p.MaxIterations is used. Unlike
+// p.Solve, Record does not check the error of the computed solution, the
+// properties are independent of the accuracy.
+func Record(p *problem.Problem, method iterative.Method, settings iterative.Settings) (iterative.Result, History, error) {
+	if settings.MaxIterations == 0 {
+		settings.MaxIterations = p.MaxIterations
+	}
+	r := &recorder{method: method}
+	res, err := iterative.LinearSolve(p.A, p.B, r, settings)
+	return res, r.h, err
+}
+
+// recorder is a Method that records the history of the Method it wraps.
+type recorder struct {
+	method iterative.Method
+	first  bool
+	h      History
+}
+
+func (r *recorder) Init(dim int) {
+	r.method.Init(dim)
+	r.first = true
+}
+
+func (r *recorder) Iterate(ctx *iterative.Context) (iterative.Operation, error) {
+	if r.first {
+		r.first = false
+		r.snapshot(ctx, true)
+	}
+	op, err := r.method.Iterate(ctx)
+	if op&iterative.CheckResidualNorm != 0 {
+		r.h.ResidualNorms = append(r.h.ResidualNorms, ctx.ResidualNorm)
+	}
+	if op&iterative.EndIteration != 0 {
+		r.snapshot(ctx, ctx.ResidualCurrent)
+	}
+	return op, err
+}
+
+func (r *recorder) snapshot(ctx *iterative.Context, current bool) {
+	r.h.X = append(r.h.X, append([]float64(nil), ctx.X...))
+	r.h.Residual = append(r.h.Residual, append([]float64(nil), ctx.Residual...))
+	r.h.ResidualCurrent = append(r.h.ResidualCurrent, current)
+}
+
+// ResidualNonIncreasing checks that the residual norms reported by the
+// method while solving p are non-increasing, as they are for GMRES, also
+// restarted, and for other methods that minimize the residual norm over
+// nested subspaces. With a preconditioner the method may report the norms
+// of the preconditioned and the true residual, so the check is meaningful
+// only without one. A norm can exceed the previous one by 1e-10 times the
+// initial residual norm to allow for rounding, in particular when the true
+// residual replaces the estimate after a restart.
+func ResidualNonIncreasing(t testing.TB, p *problem.Problem, method iterative.Method, settings iterative.Settings) {
+	t.Helper()
+	_, h, err := Record(p, method, settings)
+	if err != nil {
+		t.Errorf("%v: %v", p.Name, err)
+		return
+	}
+	for k := 1; k < len(h.ResidualNorms); k++ {
+		prev, cur := h.ResidualNorms[k-1], h.ResidualNorms[k]
+		if cur > prev+1e-10*h.ResidualNorms[0] {
+			t.Errorf("%v: residual norm increased from %v to %v at check %v", p.Name, prev, cur, k)
+			return
+		}
+	}
+}
+
+// ErrorANormNonIncreasing checks that the A-norm of the error of the
+// approximate solutions computed by the method while solving p is
+// non-increasing, as it is for CG on a symmetric positive definite matrix.
+// The error can increase by 1e-10 times the initial error to allow for
+// rounding.
+func ErrorANormNonIncreasing(t testing.TB, p *problem.Problem, method iterative.Method, settings iterative.Settings) {
+	t.Helper()
+	_, h, err := Record(p, method, settings)
+	if err != nil {
+		t.Errorf("%v: %v", p.Name, err)
+		return
+	}
+	e := make([]float64, p.Dim)
+	ae := make([]float64, p.Dim)
+	norms := make([]float64, len(h.X))
+	for k, x := range h.X {
+		floats.SubTo(e, p.X, x)
+		p.A.MatVec(ae, e)
+		norms[k] = math.Sqrt(floats.Dot(e, ae))
+	}
+	for k := 1; k < len(norms); k++ {
+		if norms[k] > norms[k-1]+1e-10*norms[0] {
+			t.Errorf("%v: A-norm of the error increased from %v to %v in iteration %v", p.Name, norms[k-1], norms[k], k)
+			return
+		}
+	}
+}
+
+// ResidualsOrthogonal checks that the residuals computed by the method
+// while solving p are mutually orthogonal in the inner product given by the
+// preconditioner,
+//  r_i · M^{-1} r_j = 0,  i != j,
+// as they are for CG. The cosine of the angle between two residuals can be
+// at most 1e-6 in absolute value. Orthogonality is gradually lost in
+// floating-point arithmetic, so the check should be done on problems that
+// converge in a modest number of iterations. Residuals with the norm below
+// 1e-10 times the initial one are dominated by rounding errors and they
+// are not checked.
+func ResidualsOrthogonal(t testing.TB, p *problem.Problem, method iterative.Method, settings iterative.Settings) {
+	t.Helper()
+	_, h, err := Record(p, method, settings)
+	if err != nil {
+		t.Errorf("%v: %v", p.Name, err)
+		return
+	}
+	z := make([][]float64, len(h.Residual))
+	for k, r := range h.Residual {
+		z[k] = r
+		if settings.PSolve != nil {
+			z[k] = make([]float64, p.Dim)
+			if err := settings.PSolve(z[k], r); err != nil {
+				t.Errorf("%v: %v", p.Name, err)
+				return
+			}
+		}
+	}
+	norms := make([]float64, len(h.Residual))
+	for k, r := range h.Residual {
+		norms[k] = math.Sqrt(floats.Dot(r, z[k]))
+	}
+	for i := range h.Residual {
+		if norms[i] < 1e-10*norms[0] {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			ri, rj := norms[i], norms[j]
+			cos := floats.Dot(h.Residual[i], z[j]) / (ri * rj)
+			if math.Abs(cos) > 1e-6 {
+				t.Errorf("%v: residuals %v and %v not orthogonal, cosine %v", p.Name, j, i, cos)
+				return
+			}
+		}
+	}
+}
+
+// FiniteTermination checks that the method converges on p in at most
+// p.Dim iterations, as full, unrestarted GMRES does in exact arithmetic.
+// The iteration limit of settings is ignored.
+func FiniteTermination(t testing.TB, p *problem.Problem, method iterative.Method, settings iterative.Settings) {
+	t.Helper()
+	settings.MaxIterations = p.Dim
+	res, _, err := Record(p, method, settings)
+	if err != nil {
+		t.Errorf("%v: %v", p.Name, err)
+		return
+	}
+	if res.Stats.Iterations > p.Dim {
+		t.Errorf("%v: %v iterations, want at most %v", p.Name, res.Stats.Iterations, p.Dim)
+	}
+}
+
+// ResidualCurrent checks the contract of Context.ResidualCurrent: at the
+// end of each iteration in which the method reports the residual as
+// current, Residual must be equal to b - A*x for the approximate solution
+// x. Recursively updated residuals drift from the true ones by rounding
+// errors, so the difference can be 1e-8 times |b| + |A*x|.
+func ResidualCurrent(t testing.TB, p *problem.Problem, method iterative.Method, settings iterative.Settings) {
+	t.Helper()
+	_, h, err := Record(p, method, settings)
+	if err != nil {
+		t.Errorf("%v: %v", p.Name, err)
+		return
+	}
+	bnorm := floats.Norm(p.B, 2)
+	ax := make([]float64, p.Dim)
+	r := make([]float64, p.Dim)
+	for k, x := range h.X {
+		if !h.ResidualCurrent[k] {
+			continue
+		}
+		p.A.MatVec(ax, x)
+		floats.SubTo(r, p.B, ax)
+		d := floats.Distance(r, h.Residual[k], 2)
+		if d > 1e-8*(bnorm+floats.Norm(ax, 2)) {
+			t.Errorf("%v: residual reported as current in iteration %v differs from b-A*x by %v", p.Name, k, d)
+			return
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package methodtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+	"gonum.org/v1/gonum/floats"
+)
+
+// errorT records the errors reported by the checks.
+type errorT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *errorT) Helper() {}
+
+func (t *errorT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+// richardson is the Richardson iteration
+//  x_{i+1} = x_i + ω r_i
+// which violates all the checked convergence properties if ω is too large.
+// If stale is true, it does not update the residual but reports it as
+// current.
+type richardson struct {
+	omega  float64
+	stale  bool
+	resume int
+	ar     []float64
+}
+
+func (m *richardson) Init(dim int) {
+	m.ar = make([]float64, dim)
+	m.resume = 1
+}
+
+func (m *richardson) Iterate(ctx *iterative.Context) (iterative.Operation, error) {
+	switch m.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = m.ar
+		m.resume = 2
+		return iterative.MatVec, nil
+	case 2:
+		floats.AddScaled(ctx.X, m.omega, ctx.Residual)
+		if !m.stale {
+			floats.AddScaled(ctx.Residual, -m.omega, m.ar)
+		}
+		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
+		ctx.Converged = false
+		m.resume = 3
+		return iterative.CheckResidualNorm, nil
+	default:
+		m.resume = 1
+		ctx.ResidualCurrent = true
+		return iterative.EndIteration, nil
+	}
+}
+
+func TestChecks(t *testing.T) {
+	// The eigenvalues of Poisson2D lie in (0,8), so the
+	// Richardson iteration converges for ω < 1/4 and the
+	// error increases for larger ω.
+	p := problem.Poisson2D(4, 4)
+	settings := iterative.Settings{Tolerance: 1e-8, MaxIterations: 20}
+	for _, test := range []struct {
+		name  string
+		check func(testing.TB, *problem.Problem, iterative.Method, iterative.Settings)
+	}{
+		{"ResidualNonIncreasing", ResidualNonIncreasing},
+		{"ErrorANormNonIncreasing", ErrorANormNonIncreasing},
+		{"ResidualsOrthogonal", ResidualsOrthogonal},
+		{"FiniteTermination", FiniteTermination},
+	} {
+		var et errorT
+		test.check(&et, p, &richardson{omega: 0.3}, settings)
+		if len(et.errors) == 0 {
+			t.Errorf("%v: violation not detected", test.name)
+		}
+
+		et.errors = nil
+		test.check(&et, p, &iterative.CG{}, settings)
+		if len(et.errors) != 0 {
+			t.Errorf("%v: unexpected errors for CG: %v", test.name, et.errors)
+		}
+	}
+}
+
+func TestResidualCurrent(t *testing.T) {
+	p := problem.Poisson2D(4, 4)
+	settings := iterative.Settings{Tolerance: 1e-8, MaxIterations: 200}
+	var et errorT
+	ResidualCurrent(&et, p, &richardson{omega: 0.2, stale: true}, settings)
+	if len(et.errors) == 0 {
+		t.Errorf("stale residual not detected")
+	}
+	for _, method := range []iterative.Method{
+		&richardson{omega: 0.2},
+		&iterative.CG{},
+		&iterative.BiCG{},
+		&iterative.BiCGSTAB{},
+		&iterative.PipelinedBiCGSTAB{},
+		&iterative.GMRES{Restart: 5},
+	} {
+		et.errors = nil
+		ResidualCurrent(&et, p, method, settings)
+		if len(et.errors) != 0 {
+			t.Errorf("%T: unexpected errors: %v", method, et.errors)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	p := problem.Poisson2D(5, 5)
+	res, h, err := Record(p, &iterative.CG{}, iterative.Settings{Tolerance: 1e-10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := res.Stats.Iterations
+	if len(h.ResidualNorms) != n {
+		t.Errorf("unexpected number of residual norms: want %v, got %v", n, len(h.ResidualNorms))
+	}
+	if len(h.X) != n+1 || len(h.Residual) != n+1 {
+		t.Errorf("unexpected number of iterates: want %v, got %v and %v", n+1, len(h.X), len(h.Residual))
+	}
+	if !floats.Equal(h.X[n], res.X) {
+		t.Errorf("last iterate is not the solution")
+	}
+	for _, v := range h.X[0] {
+		if v != 0 {
+			t.Errorf("first iterate is not the initial guess")
+			break
+		}
+	}
+}
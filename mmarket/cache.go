@@ -0,0 +1,138 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mmarket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc64"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// A cache file stores, in little-endian byte order,
+//  magic    [8]byte "MMCACHE\x00"
+//  version  uint32
+//  checksum uint64
+// followed by the matrix in the binary format of sparse.CSR.
+const (
+	cacheMagic   = "MMCACHE\x00"
+	cacheVersion = 1
+
+	cacheHeaderSize = 8 + 4 + 8
+
+	// CacheSuffix is appended to the name
+	// of a matrix file to obtain the name of
+	// its cache file.
+	CacheSuffix = ".csr"
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// WriteCache writes m into w in a binary form that is much faster to read
+// than the Matrix Market format. checksum identifies the source of the
+// matrix and is returned by ReadCache.
+func WriteCache(w io.Writer, m *sparse.CSR, checksum uint64) error {
+	var hdr [cacheHeaderSize]byte
+	copy(hdr[:], cacheMagic)
+	binary.LittleEndian.PutUint32(hdr[8:], cacheVersion)
+	binary.LittleEndian.PutUint64(hdr[12:], checksum)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := m.MarshalBinaryTo(w)
+	return err
+}
+
+// ReadCache reads a matrix written by WriteCache from r and returns it
+// together with the checksum of its source.
+func ReadCache(r io.Reader) (m *sparse.CSR, checksum uint64, err error) {
+	var hdr [cacheHeaderSize]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	if string(hdr[:8]) != cacheMagic {
+		return nil, 0, errors.New("mmarket: not a cache file")
+	}
+	if binary.LittleEndian.Uint32(hdr[8:]) != cacheVersion {
+		return nil, 0, errors.New("mmarket: unsupported cache version")
+	}
+	m = &sparse.CSR{}
+	if _, err = m.UnmarshalBinaryFrom(r); err != nil {
+		return nil, 0, err
+	}
+	return m, binary.LittleEndian.Uint64(hdr[12:]), nil
+}
+
+// Checksum returns the checksum of the contents of a matrix file that
+// LoadMatrixCached stores in the cache.
+func Checksum(data []byte) uint64 {
+	return crc64.Checksum(data, crcTable)
+}
+
+// LoadMatrixCached reads the matrix from the file at path like LoadMatrix
+// but uses a cache file stored next to it with the name path+CacheSuffix.
+// If the cache exists and its checksum matches the contents of the file,
+// the matrix is read from the cache. Otherwise the file is parsed and the
+// cache is written if the directory is writable. A corrupted or stale
+// cache is never an error, the file is parsed instead.
+func LoadMatrixCached(path string) (*sparse.CSR, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := Checksum(src)
+	cachePath := path + CacheSuffix
+	if f, err := os.Open(cachePath); err == nil {
+		m, s, err := ReadCache(bufio.NewReader(f))
+		f.Close()
+		if err == nil && s == sum {
+			return m, nil
+		}
+	}
+
+	var a *sparse.CSR
+	err = decode(path, bytes.NewReader(src), func(r *Reader) (err error) {
+		a, err = r.ReadCSR()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	writeCache(cachePath, a, sum)
+	return a, nil
+}
+
+// writeCache writes the cache of m to path. The cache is written to a
+// temporary file that is renamed, so a concurrent reader never sees a
+// partial cache. Errors are ignored, the cache is only an optimization.
+func writeCache(path string, m *sparse.CSR, checksum uint64) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	err = WriteCache(w, m, checksum)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mmarket
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestCache(t *testing.T) {
+	want, err := LoadMatrix(filepath.Join("..", "testdata", "west0067.mtx.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCache(&buf, want, 42); err != nil {
+		t.Fatal(err)
+	}
+	got, sum, err := ReadCache(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if sum != 42 {
+		t.Errorf("unexpected checksum %v", sum)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the matrix")
+	}
+
+	if _, _, err := ReadCache(bytes.NewReader([]byte("%%MatrixMarket matrix"))); err == nil {
+		t.Errorf("expected error for a file that is not a cache")
+	}
+}
+
+func TestLoadMatrixCached(t *testing.T) {
+	dir := t.TempDir()
+	src, err := os.ReadFile(filepath.Join("..", "testdata", "nos4.mtx.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "nos4.mtx.gz")
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := LoadMatrix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachePath := path + CacheSuffix
+
+	// The first load parses the file and writes the cache.
+	got, err := LoadMatrixCached(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected matrix from the first load")
+	}
+	cached, sum, err := readCacheFile(cachePath)
+	if err != nil {
+		t.Fatalf("cache not written: %v", err)
+	}
+	if sum != Checksum(src) || !reflect.DeepEqual(cached, want) {
+		t.Errorf("unexpected cache contents")
+	}
+
+	// A valid cache is used without parsing the file. Replace it by a
+	// different matrix with the right checksum to observe that.
+	marker := sparse.NewCSRFromTriplet(sparse.NewTriplet(1, 1))
+	writeCacheFile(t, cachePath, marker, sum)
+	got, err = LoadMatrixCached(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, marker) {
+		t.Errorf("valid cache not used")
+	}
+
+	// A stale cache is replaced.
+	writeCacheFile(t, cachePath, marker, sum+1)
+	got, err = LoadMatrixCached(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stale cache used")
+	}
+	if _, sum, err := readCacheFile(cachePath); err != nil || sum != Checksum(src) {
+		t.Errorf("stale cache not rewritten")
+	}
+
+	// A corrupted cache is replaced.
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, data[:len(data)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = LoadMatrixCached(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected matrix with a corrupted cache")
+	}
+	if _, _, err := readCacheFile(cachePath); err != nil {
+		t.Errorf("corrupted cache not rewritten: %v", err)
+	}
+
+	// No temporary files are left behind.
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("unexpected number of files %v in the directory", len(files))
+	}
+
+	if _, err := LoadMatrixCached(filepath.Join(dir, "missing.mtx")); err == nil {
+		t.Errorf("expected error for a missing file")
+	}
+}
+
+func readCacheFile(path string) (*sparse.CSR, uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	return ReadCache(f)
+}
+
+func writeCacheFile(t *testing.T, path string, m *sparse.CSR, sum uint64) {
+	var buf bytes.Buffer
+	if err := WriteCache(&buf, m, sum); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const benchMatrix = "mbeacxc.mtx.gz"
+
+func BenchmarkLoadMatrix(b *testing.B) {
+	path := filepath.Join("..", "testdata", benchMatrix)
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadMatrix(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadMatrixCached(b *testing.B) {
+	src, err := os.ReadFile(filepath.Join("..", "testdata", benchMatrix))
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(b.TempDir(), benchMatrix)
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := LoadMatrixCached(path); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LoadMatrixCached(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
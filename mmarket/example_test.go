@@ -0,0 +1,28 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mmarket_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/mmarket"
+)
+
+func ExampleLoadProblem() {
+	a, b, err := mmarket.LoadProblem("testdata/symmetric_real.mtx", "testdata/symmetric_real_b.mtx")
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := iterative.LinearSolve(iterative.NewMatrixOps(a), b, &iterative.GMRES{}, iterative.Settings{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%.6f\n", res.X)
+
+	// Output:
+	// [1.000000 1.000000 1.000000]
+}
@@ -0,0 +1,91 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mmarket
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// LoadMatrix reads the matrix in the coordinate format from the file at
+// path. Files whose name ends in ".gz" are decompressed transparently.
+func LoadMatrix(path string) (*sparse.CSR, error) {
+	var a *sparse.CSR
+	err := load(path, func(r *Reader) (err error) {
+		a, err = r.ReadCSR()
+		return err
+	})
+	return a, err
+}
+
+// LoadVector reads the vector in the array format from the file at path.
+// Files whose name ends in ".gz" are decompressed transparently.
+func LoadVector(path string) ([]float64, error) {
+	var v []float64
+	err := load(path, func(r *Reader) (err error) {
+		v, err = r.ReadVector()
+		return err
+	})
+	return v, err
+}
+
+// LoadProblem reads the matrix A of a linear system from the file at
+// matrixPath and the right-hand side b from the file at rhsPath. It
+// returns an error if the dimensions of A and b do not match. The matrix
+// can be passed to iterative.NewMatrixOps, so a downloaded problem is
+// solved by
+//  a, b, err := mmarket.LoadProblem("A.mtx.gz", "b.mtx.gz")
+//  // handle err
+//  res, err := iterative.LinearSolve(iterative.NewMatrixOps(a), b, &iterative.GMRES{}, iterative.Settings{})
+func LoadProblem(matrixPath, rhsPath string) (a *sparse.CSR, b []float64, err error) {
+	a, err = LoadMatrix(matrixPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err = LoadVector(rhsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r, c := a.Dims(); r != c || r != len(b) {
+		return nil, nil, fmt.Errorf("mmarket: dimension mismatch, matrix is %d×%d, right-hand side has length %d", r, c, len(b))
+	}
+	return a, b, nil
+}
+
+// load opens the file at path and calls read with a Reader of its
+// contents, decompressing them if the name ends in ".gz". Errors are
+// prefixed by the path.
+func load(path string, read func(*Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return decode(path, f, read)
+}
+
+// decode calls read with a Reader of the contents of the file at path
+// read from r. The contents are decompressed if the name ends in ".gz".
+// Errors are prefixed by the path.
+func decode(path string, r io.Reader, read func(*Reader) error) error {
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	err := read(NewReader(r))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
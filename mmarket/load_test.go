@@ -0,0 +1,122 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mmarket
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMatrix(t *testing.T) {
+	for _, name := range []string{"nos4", "west0067"} {
+		path := filepath.Join("..", "testdata", name+".mtx.gz")
+		got, err := LoadMatrix(path)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := NewReader(gz).ReadCSR()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: unexpected matrix", name)
+		}
+	}
+
+	a, err := LoadMatrix(filepath.Join("testdata", "general_real.mtx"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if r, c := a.Dims(); r != 3 || c != 4 || a.At(2, 0) != 0.4 {
+		t.Errorf("unexpected uncompressed matrix")
+	}
+
+	_, err = LoadMatrix(filepath.Join("testdata", "general_complex.mtx"))
+	if !errors.Is(err, errComplex) || !strings.HasPrefix(err.Error(), filepath.Join("testdata", "general_complex.mtx")+": ") {
+		t.Errorf("unexpected error %v", err)
+	}
+	_, err = LoadMatrix(filepath.Join("testdata", "missing.mtx"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unexpected error for missing file %v", err)
+	}
+}
+
+func TestLoadProblem(t *testing.T) {
+	a, b, err := LoadProblem(filepath.Join("testdata", "symmetric_real.mtx"), filepath.Join("testdata", "symmetric_real_b.mtx"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(b, []float64{1, -2, 1}) {
+		t.Errorf("unexpected right-hand side %v", b)
+	}
+	ax := make([]float64, 3)
+	a.MulVec(ax, []float64{1, 1, 1})
+	if !reflect.DeepEqual(ax, b) {
+		t.Errorf("[1 1 1] is not the solution, A*x = %v", ax)
+	}
+
+	_, _, err = LoadProblem(filepath.Join("testdata", "symmetric_real.mtx"), filepath.Join("testdata", "short_b.mtx"))
+	if err == nil || !strings.Contains(err.Error(), "dimension mismatch") {
+		t.Errorf("unexpected error for mismatched dimensions: %v", err)
+	}
+	_, _, err = LoadProblem(filepath.Join("testdata", "symmetric_real.mtx"), filepath.Join("testdata", "general_real.mtx"))
+	if !errors.Is(err, errNotVector) {
+		t.Errorf("unexpected error for a matrix as right-hand side: %v", err)
+	}
+}
+
+func TestReadVector(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		want  []float64
+		err   string
+	}{
+		{
+			input: "%%MatrixMarket matrix array real general\n% comment\n3 1\n1.5\n-2e3\n0\n",
+			want:  []float64{1.5, -2000, 0},
+		},
+		{
+			input: "%%MatrixMarket matrix array real general\n3 2\n",
+			err:   "mmarket: not a vector in the array format on line 1",
+		},
+		{
+			input: "%%MatrixMarket matrix array real general\n3 1\n1\n2\n",
+			err:   "mmarket: unexpected end of file on line 5",
+		},
+		{
+			input: "%%MatrixMarket matrix array real general\n2 1\n1\nx\n",
+			err:   `mmarket: bad number on line 4: "x"`,
+		},
+	} {
+		got, err := NewReader(strings.NewReader(test.input)).ReadVector()
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("unexpected error, want %v, got %v", test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("unexpected vector, want %v, got %v", test.want, got)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mmarket reads sparse matrices and dense vectors stored in the
+// Matrix Market exchange format.
+package mmarket
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+var (
+	errBadFormat    = errors.New("mmarket: bad file format")
+	errUnsupported  = errors.New("mmarket: matrix type not supported")
+	errComplex      = errors.New("mmarket: complex matrices not supported")
+	errNotSymmetric = errors.New("mmarket: matrix not symmetric")
+	errSkewDiagonal = errors.New("mmarket: diagonal element in skew-symmetric matrix")
+	errBadNumber    = errors.New("mmarket: bad number")
+	errIndexRange   = errors.New("mmarket: index out of range")
+	errShortFile    = errors.New("mmarket: unexpected end of file")
+	errNotVector    = errors.New("mmarket: not a vector in the array format")
+)
+
+// ParseError is the error returned for malformed input. It carries the
+// 1-based number of the line where the error occurred and the offending
+// token, if any. The underlying error can be obtained with errors.Unwrap.
+type ParseError struct {
+	Line  int
+	Token string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%v on line %d", e.Err, e.Line)
+	}
+	return fmt.Sprintf("%v on line %d: %q", e.Err, e.Line, e.Token)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Header describes the matrix stored in a Matrix Market file.
+type Header struct {
+	Rows, Cols int
+
+	// NNZ is the number of entries
+	// stored in the file. For symmetric
+	// and skew-symmetric matrices only one
+	// triangle is stored.
+	NNZ int
+
+	// Array is true for the dense array
+	// format, in which case NNZ is
+	// Rows*Cols.
+	Array bool
+
+	Pattern       bool // Entries have no values.
+	Symmetric     bool
+	SkewSymmetric bool
+}
+
+// Reader reads a matrix or a vector in the Matrix Market format from an
+// io.Reader. A Reader reads a single object, only one of its Read methods
+// can be called.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+
+	h    *Header
+	herr error
+}
+
+// NewReader returns a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		s: bufio.NewScanner(r),
+	}
+}
+
+// Header reads the header of the file if it has not been read yet and
+// returns it.
+func (r *Reader) Header() (Header, error) {
+	if r.h == nil && r.herr == nil {
+		var h Header
+		h, r.herr = r.header()
+		r.h = &h
+	}
+	return *r.h, r.herr
+}
+
+// Read reads a matrix in the coordinate format. Real and integer matrices
+// are supported, the elements of a pattern matrix are set to 1. The
+// triangle stored in a symmetric or skew-symmetric file is mirrored, with
+// the sign flipped in the skew-symmetric case. Complex and Hermitian
+// matrices are rejected with an error.
+func (r *Reader) Read() (*sparse.Triplet, error) {
+	h, err := r.coordHeader()
+	if err != nil {
+		return nil, err
+	}
+	m := sparse.NewTriplet(h.Rows, h.Cols)
+	err = r.entries(h, func(i, j int, v float64) error {
+		m.Append(i, j, v)
+		switch {
+		case h.Symmetric && i != j:
+			m.Append(j, i, v)
+		case h.SkewSymmetric:
+			m.Append(j, i, -v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadEach reads a matrix in the coordinate format and calls fn with the
+// zero-based indices and the value of each element without storing the
+// matrix. The elements of a symmetric or skew-symmetric matrix that are
+// not stored in the file are passed to fn after the mirrored stored
+// element. If fn returns an error, ReadEach stops and returns it.
+func (r *Reader) ReadEach(fn func(i, j int, v float64) error) error {
+	h, err := r.coordHeader()
+	if err != nil {
+		return err
+	}
+	return r.entries(h, func(i, j int, v float64) error {
+		if err := fn(i, j, v); err != nil {
+			return err
+		}
+		switch {
+		case h.Symmetric && i != j:
+			return fn(j, i, v)
+		case h.SkewSymmetric:
+			return fn(j, i, -v)
+		}
+		return nil
+	})
+}
+
+// ReadCSR reads a matrix in the coordinate format directly into a CSR
+// matrix. The storage is allocated once using the number of entries given
+// in the header and the matrix is assembled in place by NewCSRInPlace, so
+// the peak memory is much lower than with Read followed by a conversion.
+func (r *Reader) ReadCSR() (*sparse.CSR, error) {
+	h, err := r.coordHeader()
+	if err != nil {
+		return nil, err
+	}
+	nnz := h.NNZ
+	if h.Symmetric || h.SkewSymmetric {
+		nnz *= 2
+	}
+	rows := make([]int, 0, nnz)
+	cols := make([]int, 0, nnz)
+	vals := make([]float64, 0, nnz)
+	err = r.ReadEach(func(i, j int, v float64) error {
+		rows = append(rows, i)
+		cols = append(cols, j)
+		vals = append(vals, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sparse.NewCSRInPlace(h.Rows, h.Cols, rows, cols, vals), nil
+}
+
+// ReadSymCSR reads a symmetric matrix in the coordinate format directly
+// into a SymCSR matrix without expanding the stored triangle. It returns
+// an error if the file does not contain a symmetric matrix.
+func (r *Reader) ReadSymCSR() (*sparse.SymCSR, error) {
+	h, err := r.coordHeader()
+	if err != nil {
+		return nil, err
+	}
+	if !h.Symmetric {
+		return nil, errNotSymmetric
+	}
+	m := sparse.NewTriplet(h.Rows, h.Cols)
+	err = r.entries(h, func(i, j int, v float64) error {
+		m.Append(i, j, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sparse.NewSymCSRFromHalfTriplet(m), nil
+}
+
+// ReadVector reads a vector stored as a real general matrix with a single
+// column in the array format, as right-hand sides are usually distributed.
+func (r *Reader) ReadVector() ([]float64, error) {
+	h, err := r.Header()
+	if err != nil {
+		return nil, err
+	}
+	if !h.Array || h.Cols != 1 || h.Symmetric || h.SkewSymmetric {
+		return nil, &ParseError{Line: 1, Err: errNotVector}
+	}
+	v := make([]float64, h.Rows)
+	for i := range v {
+		if !r.scan() {
+			return nil, r.eof()
+		}
+		line := r.s.Text()
+		fields := strings.Fields(line)
+		if len(fields) != 1 {
+			return nil, r.errorf(line, errBadFormat)
+		}
+		v[i], err = strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, r.errorf(fields[0], errBadNumber)
+		}
+	}
+	return v, nil
+}
+
+// coordHeader returns the header of a file that must contain a matrix in
+// the coordinate format.
+func (r *Reader) coordHeader() (Header, error) {
+	h, err := r.Header()
+	if err == nil && h.Array {
+		err = &ParseError{Line: 1, Token: "array", Err: errUnsupported}
+	}
+	return h, err
+}
+
+// scan advances to the next line and keeps track of the line number.
+func (r *Reader) scan() bool {
+	if !r.s.Scan() {
+		return false
+	}
+	r.line++
+	return true
+}
+
+func (r *Reader) errorf(token string, err error) error {
+	return &ParseError{Line: r.line, Token: token, Err: err}
+}
+
+// eof returns the error of the scanner or, if the input has ended, an
+// error reporting the unexpected end of file.
+func (r *Reader) eof() error {
+	if err := r.s.Err(); err != nil {
+		return err
+	}
+	return &ParseError{Line: r.line + 1, Err: errShortFile}
+}
+
+func (r *Reader) header() (Header, error) {
+	var h Header
+	if !r.scan() {
+		return h, r.eof()
+	}
+	line := r.s.Text()
+	fields := strings.Fields(strings.ToLower(line))
+	if len(fields) != 5 || fields[0] != "%%matrixmarket" || fields[1] != "matrix" {
+		return h, r.errorf(line, errBadFormat)
+	}
+	switch fields[2] {
+	case "coordinate":
+	case "array":
+		h.Array = true
+	default:
+		return h, r.errorf(fields[2], errUnsupported)
+	}
+	switch fields[3] {
+	case "real", "integer":
+	case "pattern":
+		h.Pattern = true
+	case "complex":
+		return h, r.errorf(fields[3], errComplex)
+	default:
+		return h, r.errorf(fields[3], errBadFormat)
+	}
+	switch fields[4] {
+	case "general":
+	case "symmetric":
+		h.Symmetric = true
+	case "skew-symmetric":
+		if h.Pattern {
+			return h, r.errorf(fields[4], errBadFormat)
+		}
+		h.SkewSymmetric = true
+	case "hermitian":
+		return h, r.errorf(fields[4], errComplex)
+	default:
+		return h, r.errorf(fields[4], errBadFormat)
+	}
+
+	for {
+		if !r.scan() {
+			return h, r.eof()
+		}
+		line = r.s.Text()
+		if line == "" || line[0] == '%' {
+			continue
+		}
+		break
+	}
+	fields = strings.Fields(line)
+	sizes := []*int{&h.Rows, &h.Cols, &h.NNZ}
+	if h.Array {
+		sizes = sizes[:2]
+	}
+	if len(fields) != len(sizes) {
+		return h, r.errorf(line, errBadFormat)
+	}
+	for k, p := range sizes {
+		n, err := strconv.Atoi(fields[k])
+		if err != nil || n < 0 {
+			return h, r.errorf(fields[k], errBadNumber)
+		}
+		*p = n
+	}
+	if (h.Symmetric || h.SkewSymmetric) && h.Rows != h.Cols {
+		return h, r.errorf(line, errBadFormat)
+	}
+	if h.Array {
+		h.NNZ = h.Rows * h.Cols
+	}
+	return h, nil
+}
+
+// entries reads the h.NNZ entries of the matrix and calls fn with their
+// zero-based indices and values.
+func (r *Reader) entries(h Header, fn func(i, j int, v float64) error) error {
+	want := 3
+	if h.Pattern {
+		want = 2
+	}
+	for k := 0; k < h.NNZ; k++ {
+		if !r.scan() {
+			return r.eof()
+		}
+		line := r.s.Text()
+		fields := strings.Fields(line)
+		if len(fields) != want {
+			return r.errorf(line, errBadFormat)
+		}
+		i, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return r.errorf(fields[0], errBadNumber)
+		}
+		if i < 1 || h.Rows < i {
+			return r.errorf(fields[0], errIndexRange)
+		}
+		j, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return r.errorf(fields[1], errBadNumber)
+		}
+		if j < 1 || h.Cols < j {
+			return r.errorf(fields[1], errIndexRange)
+		}
+		if h.SkewSymmetric && i == j {
+			return r.errorf(line, errSkewDiagonal)
+		}
+		v := 1.0
+		if !h.Pattern {
+			v, err = strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return r.errorf(fields[2], errBadNumber)
+			}
+		}
+		if err := fn(i-1, j-1, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
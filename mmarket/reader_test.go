@@ -0,0 +1,285 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mmarket
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestReadSymCSR(t *testing.T) {
+	const sym = `%%MatrixMarket matrix coordinate real symmetric
+% comment
+3 3 4
+1 1 4
+2 1 -1
+3 2 -2
+3 3 5
+`
+	m, err := NewReader(strings.NewReader(sym)).ReadSymCSR()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.NNZ() != 4 {
+		t.Errorf("unexpected NNZ, want 4, got %v", m.NNZ())
+	}
+	want := [][]float64{
+		{4, -1, 0},
+		{-1, 0, -2},
+		{0, -2, 5},
+	}
+	for i, row := range want {
+		for j, v := range row {
+			if m.At(i, j) != v {
+				t.Errorf("unexpected element at (%v,%v), want %v, got %v", i, j, v, m.At(i, j))
+			}
+		}
+	}
+
+	const general = `%%MatrixMarket matrix coordinate real general
+2 2 1
+1 2 1
+`
+	_, err = NewReader(strings.NewReader(general)).ReadSymCSR()
+	if err != errNotSymmetric {
+		t.Errorf("unexpected error for a general matrix: %v", err)
+	}
+}
+
+func TestReadQualifiers(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		want [][]float64
+		err  error
+	}{
+		{
+			file: "general_real.mtx",
+			want: [][]float64{
+				{1.5, 0, 0, -2},
+				{0, 3, 0, 0},
+				{0.4, 0, -5, 0},
+			},
+		},
+		{
+			file: "general_pattern.mtx",
+			want: [][]float64{
+				{1, 0, 0, 1},
+				{0, 1, 0, 0},
+				{1, 0, 1, 0},
+			},
+		},
+		{file: "general_complex.mtx", err: errComplex},
+		{
+			file: "symmetric_real.mtx",
+			want: [][]float64{
+				{2, -1, 0},
+				{-1, 0, -1},
+				{0, -1, 2},
+			},
+		},
+		{
+			file: "symmetric_pattern.mtx",
+			want: [][]float64{
+				{1, 1, 0},
+				{1, 0, 1},
+				{0, 1, 0},
+			},
+		},
+		{file: "symmetric_complex.mtx", err: errComplex},
+		{
+			file: "skew_real.mtx",
+			want: [][]float64{
+				{0, -3, 0.5},
+				{3, 0, 0},
+				{-0.5, 0, 0},
+			},
+		},
+		{file: "skew_pattern.mtx", err: errBadFormat},
+		{file: "skew_complex.mtx", err: errComplex},
+		{file: "skew_diagonal.mtx", err: errSkewDiagonal},
+	} {
+		f, err := os.Open(filepath.Join("testdata", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := NewReader(f).Read()
+		f.Close()
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: unexpected error, want %v, got %v", test.file, test.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		a := sparse.NewCSRFromTriplet(m)
+		r, c := a.Dims()
+		if r != len(test.want) || c != len(test.want[0]) {
+			t.Errorf("%v: unexpected dimensions %v×%v", test.file, r, c)
+			continue
+		}
+		for i, row := range test.want {
+			for j, v := range row {
+				if a.At(i, j) != v {
+					t.Errorf("%v: unexpected element at (%v,%v), want %v, got %v", test.file, i, j, v, a.At(i, j))
+				}
+			}
+		}
+	}
+}
+
+func TestReadEach(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		want [][]float64
+	}{
+		{
+			file: "symmetric_real.mtx",
+			want: [][]float64{
+				{2, -1, 0},
+				{-1, 0, -1},
+				{0, -1, 2},
+			},
+		},
+		{
+			file: "skew_real.mtx",
+			want: [][]float64{
+				{0, -3, 0.5},
+				{3, 0, 0},
+				{-0.5, 0, 0},
+			},
+		},
+	} {
+		f, err := os.Open(filepath.Join("testdata", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([][]float64, len(test.want))
+		for i := range got {
+			got[i] = make([]float64, len(test.want[0]))
+		}
+		err = NewReader(f).ReadEach(func(i, j int, v float64) error {
+			got[i][j] += v
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.file, err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%v: unexpected matrix, want %v, got %v", test.file, test.want, got)
+		}
+	}
+
+	stop := errors.New("stop")
+	var n int
+	err := NewReader(strings.NewReader(`%%MatrixMarket matrix coordinate real general
+2 2 2
+1 1 1
+2 2 1
+`)).ReadEach(func(i, j int, v float64) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("error from callback not returned")
+	}
+}
+
+func TestReadCSR(t *testing.T) {
+	for _, name := range []string{"west0067", "gre__115", "steam1"} {
+		open := func() *Reader {
+			f, err := os.Open(filepath.Join("..", "testdata", name+".mtx.gz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return NewReader(gz)
+		}
+		tr, err := open().Read()
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+		want := sparse.NewCSRFromTriplet(tr)
+		got, err := open().ReadCSR()
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", name, err)
+		}
+		if got.NNZ() != want.NNZ() {
+			t.Errorf("%v: unexpected NNZ, want %v, got %v", name, want.NNZ(), got.NNZ())
+		}
+		r, c := want.Dims()
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if got.At(i, j) != want.At(i, j) {
+					t.Errorf("%v: unexpected element at (%v,%v)", name, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	const header = "%%MatrixMarket matrix coordinate real general\n"
+	for _, test := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", "mmarket: unexpected end of file on line 1"},
+		{"truncated header", "%%MatrixMarket matrix coordinate\n", `mmarket: bad file format on line 1: "%%MatrixMarket matrix coordinate"`},
+		{"array format", "%%MatrixMarket matrix array real general\n2 1\n1\n1\n", `mmarket: matrix type not supported on line 1: "array"`},
+		{"unknown format", "%%MatrixMarket matrix sparse real general\n", `mmarket: matrix type not supported on line 1: "sparse"`},
+		{"bad field", "%%MatrixMarket matrix coordinate double general\n", `mmarket: bad file format on line 1: "double"`},
+		{"hermitian", "%%MatrixMarket matrix coordinate real hermitian\n", `mmarket: complex matrices not supported on line 1: "hermitian"`},
+		{"missing size", header + "% comment\n", "mmarket: unexpected end of file on line 3"},
+		{"short size", header + "% comment\n\n3 3\n", `mmarket: bad file format on line 4: "3 3"`},
+		{"bad size", header + "3 x 1\n", `mmarket: bad number on line 2: "x"`},
+		{"negative size", header + "3 -3 1\n", `mmarket: bad number on line 2: "-3"`},
+		{"non-square symmetric", "%%MatrixMarket matrix coordinate real symmetric\n2 3 1\n", `mmarket: bad file format on line 2: "2 3 1"`},
+		{"too few entries", header + "2 2 3\n1 1 1\n2 2 1\n", "mmarket: unexpected end of file on line 5"},
+		{"missing value", header + "2 2 2\n1 1 1\n2 2\n", `mmarket: bad file format on line 4: "2 2"`},
+		{"extra value", header + "2 2 1\n1 1 1 1\n", `mmarket: bad file format on line 3: "1 1 1 1"`},
+		{"non-numeric row", header + "2 2 1\na 1 1\n", `mmarket: bad number on line 3: "a"`},
+		{"non-numeric column", header + "2 2 1\n1 1.5 1\n", `mmarket: bad number on line 3: "1.5"`},
+		{"non-numeric value", header + "2 2 2\n1 1 1\n2 2 1,5\n", `mmarket: bad number on line 4: "1,5"`},
+		{"row out of range", header + "2 2 1\n3 1 1\n", `mmarket: index out of range on line 3: "3"`},
+		{"column out of range", header + "2 2 1\n1 0 1\n", `mmarket: index out of range on line 3: "0"`},
+		{"skew diagonal", "%%MatrixMarket matrix coordinate real skew-symmetric\n2 2 1\n1 1 1\n", `mmarket: diagonal element in skew-symmetric matrix on line 3: "1 1 1"`},
+	} {
+		for _, read := range []struct {
+			name string
+			f    func(*Reader) error
+		}{
+			{"Read", func(r *Reader) error { _, err := r.Read(); return err }},
+			{"ReadCSR", func(r *Reader) error { _, err := r.ReadCSR(); return err }},
+			{"ReadEach", func(r *Reader) error { return r.ReadEach(func(int, int, float64) error { return nil }) }},
+		} {
+			err := read.f(NewReader(strings.NewReader(test.input)))
+			if err == nil {
+				t.Errorf("%v,%v: missing error", test.name, read.name)
+				continue
+			}
+			if err.Error() != test.want {
+				t.Errorf("%v,%v: unexpected error\nwant %v\ngot  %v", test.name, read.name, test.want, err)
+			}
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("%v,%v: error not a *ParseError", test.name, read.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/mat"
+)
+
+// DenseOps returns MatrixOps for the n×n general matrix A stored row-major
+// in a with the leading dimension lda, the element A[i][j] is stored in
+// a[i*lda+j]. The products are computed by Dgemv, the products with
+// several vectors by Dgemm. For large matrices and
+// the native gonum BLAS, the matrix is processed in cache-sized panels by
+// concurrent goroutines.
+func DenseOps(a []float64, n, lda int) MatrixOps {
+	checkDense(a, n, lda)
+	bi := blas64.Implementation()
+	return MatrixOps{
+		MatVec:      denseGemv(blas.NoTrans, a, n, lda),
+		MatTransVec: denseGemv(blas.Trans, a, n, lda),
+		MatMatVec: func(dst, x []float64, k int) {
+			checkLenK(dst, x, n, k)
+			if k == 0 || n == 0 {
+				return
+			}
+			// The column-major n×k matrices are row-major k×n
+			// matrices, dst^T = X^T * A^T.
+			bi.Dgemm(blas.NoTrans, blas.Trans, k, n, n, 1, x, n, a, lda, 0, dst, n)
+		},
+	}
+}
+
+// SymDenseOps returns MatrixOps for the n×n symmetric matrix A stored
+// row-major in a with the leading dimension lda. Only the triangle of A
+// specified by uplo is referenced. The products are computed by Dsymv, the
+// products with several vectors by Dsymm.
+func SymDenseOps(a []float64, n, lda int, uplo blas.Uplo) MatrixOps {
+	checkDense(a, n, lda)
+	if uplo != blas.Upper && uplo != blas.Lower {
+		panic("iterative: bad uplo")
+	}
+	bi := blas64.Implementation()
+	matVec := func(dst, x []float64) {
+		checkLen(dst, x, n)
+		bi.Dsymv(uplo, n, 1, a, lda, x, 1, 0, dst, 1)
+	}
+	return MatrixOps{
+		MatVec:      matVec,
+		MatTransVec: matVec,
+		MatMatVec: func(dst, x []float64, k int) {
+			checkLenK(dst, x, n, k)
+			if k == 0 || n == 0 {
+				return
+			}
+			// dst^T = X^T * A for the row-major k×n matrices.
+			bi.Dsymm(blas.Right, uplo, k, n, 1, a, lda, x, n, 0, dst, n)
+		},
+	}
+}
+
+// DenseMatrixOps returns MatrixOps for the square matrix a. The returned
+// MatrixOps reference the data of a, subsequent changes to a are reflected
+// in the products.
+func DenseMatrixOps(a *mat.Dense) MatrixOps {
+	r, c := a.Dims()
+	if r != c {
+		panic("iterative: matrix not square")
+	}
+	raw := a.RawMatrix()
+	return DenseOps(raw.Data, raw.Rows, raw.Stride)
+}
+
+// SymDenseMatrixOps returns MatrixOps for the symmetric matrix a. The
+// returned MatrixOps reference the data of a, subsequent changes to a are
+// reflected in the products.
+func SymDenseMatrixOps(a *mat.SymDense) MatrixOps {
+	raw := a.RawSymmetric()
+	return SymDenseOps(raw.Data, raw.N, raw.Stride, raw.Uplo)
+}
+
+// BandedOps returns MatrixOps for the n×n band matrix A with kl
+// sub-diagonals and ku super-diagonals stored in ab. The band storage is
+// row-major as in gonum's blas64.Band, the element A[i][j] with
+//  -kl <= j-i <= ku
+// is stored in ab[i*(kl+ku+1)+j-i+kl]. The length of ab must be
+// n*(kl+ku+1). The products are computed by Dgbmv.
+func BandedOps(ab []float64, n, kl, ku int) MatrixOps {
+	if n < 0 {
+		panic("iterative: negative dimension")
+	}
+	if kl < 0 || ku < 0 {
+		panic("iterative: negative bandwidth")
+	}
+	lda := kl + ku + 1
+	if len(ab) != n*lda {
+		panic("iterative: bad band storage length")
+	}
+	bi := blas64.Implementation()
+	return MatrixOps{
+		MatVec: func(dst, x []float64) {
+			checkLen(dst, x, n)
+			bi.Dgbmv(blas.NoTrans, n, n, kl, ku, 1, ab, lda, x, 1, 0, dst, 1)
+		},
+		MatTransVec: func(dst, x []float64) {
+			checkLen(dst, x, n)
+			bi.Dgbmv(blas.Trans, n, n, kl, ku, 1, ab, lda, x, 1, 0, dst, 1)
+		},
+	}
+}
+
+// SymBandedOps returns MatrixOps for the n×n symmetric band matrix A with k
+// super-diagonals. The upper triangle of the band is stored in ab row-major
+// as in gonum's blas64.SymmetricBand, the element A[i][j] with
+//  0 <= j-i <= k
+// is stored in ab[i*(k+1)+j-i]. The length of ab must be n*(k+1). The
+// products are computed by Dsbmv.
+func SymBandedOps(ab []float64, n, k int) MatrixOps {
+	if n < 0 {
+		panic("iterative: negative dimension")
+	}
+	if k < 0 {
+		panic("iterative: negative bandwidth")
+	}
+	lda := k + 1
+	if len(ab) != n*lda {
+		panic("iterative: bad band storage length")
+	}
+	bi := blas64.Implementation()
+	matVec := func(dst, x []float64) {
+		checkLen(dst, x, n)
+		bi.Dsbmv(blas.Upper, n, k, 1, ab, lda, x, 1, 0, dst, 1)
+	}
+	return MatrixOps{
+		MatVec:      matVec,
+		MatTransVec: matVec,
+	}
+}
+
+// NewMatrixOps returns MatrixOps for the square matrix m. The products are
+// computed, depending on the concrete type of m, by
+//  - Dgemv, Dsymv or Dgbmv if m provides its raw BLAS storage,
+//  - the MulVec and MulTransVec methods of m if it has them,
+//  - the MulVecTo method of m if it has it,
+//  - element access by At otherwise.
+// Transposed matrices that implement mat.Untransposer are unwrapped first.
+// The At fallback calls At n² times for each product, which is usually
+// much slower than the other paths.
+func NewMatrixOps(m mat.Matrix) MatrixOps {
+	r, c := m.Dims()
+	if r != c {
+		panic("iterative: matrix not square")
+	}
+	switch m := m.(type) {
+	case mat.RawMatrixer:
+		raw := m.RawMatrix()
+		return DenseOps(raw.Data, raw.Rows, raw.Stride)
+	case mat.RawSymmetricer:
+		raw := m.RawSymmetric()
+		return SymDenseOps(raw.Data, raw.N, raw.Stride, raw.Uplo)
+	case mat.RawBander:
+		raw := m.RawBand()
+		return bandOps(raw)
+	case mat.Untransposer:
+		ops := NewMatrixOps(m.Untranspose())
+		return MatrixOps{
+			MatVec:      ops.MatTransVec,
+			MatTransVec: ops.MatVec,
+		}
+	case vecMuler:
+		ops := MatrixOps{
+			MatVec: func(dst, x []float64) {
+				checkLen(dst, x, r)
+				m.MulVec(dst, x)
+			},
+			MatTransVec: func(dst, x []float64) {
+				checkLen(dst, x, r)
+				m.MulTransVec(dst, x)
+			},
+		}
+		if mm, ok := m.(vecsMuler); ok {
+			ops.MatMatVec = func(dst, x []float64, k int) {
+				checkLenK(dst, x, r, k)
+				mm.MulVecs(dst, x, k)
+			}
+		}
+		return ops
+	case mulVecToer:
+		var vdst, vx mat.VecDense
+		mulVec := func(dst, x []float64, trans bool) {
+			checkLen(dst, x, r)
+			vdst.SetRawVector(blas64.Vector{N: r, Data: dst, Inc: 1})
+			vx.SetRawVector(blas64.Vector{N: r, Data: x, Inc: 1})
+			m.MulVecTo(&vdst, trans, &vx)
+		}
+		return MatrixOps{
+			MatVec: func(dst, x []float64) {
+				mulVec(dst, x, false)
+			},
+			MatTransVec: func(dst, x []float64) {
+				mulVec(dst, x, true)
+			},
+		}
+	}
+	return MatrixOps{
+		MatVec: func(dst, x []float64) {
+			checkLen(dst, x, r)
+			for i := range dst {
+				var s float64
+				for j, xj := range x {
+					s += m.At(i, j) * xj
+				}
+				dst[i] = s
+			}
+		},
+		MatTransVec: func(dst, x []float64) {
+			checkLen(dst, x, r)
+			for j := range dst {
+				var s float64
+				for i, xi := range x {
+					s += m.At(i, j) * xi
+				}
+				dst[j] = s
+			}
+		},
+	}
+}
+
+// vecMuler is implemented by matrix types, such as those in the sparse
+// package, that compute products with slices directly.
+type vecMuler interface {
+	MulVec(dst, x []float64)
+	MulTransVec(dst, x []float64)
+}
+
+// vecsMuler is implemented by matrix types, such as sparse.CSR, that compute
+// products with several vectors stored column-major at once.
+type vecsMuler interface {
+	MulVecs(dst, x []float64, k int)
+}
+
+// mulVecToer is implemented by gonum matrix types, such as mat.BandDense,
+// that compute products with vectors.
+type mulVecToer interface {
+	MulVecTo(dst *mat.VecDense, trans bool, x mat.Vector)
+}
+
+// bandOps returns MatrixOps for the square band matrix a.
+func bandOps(a blas64.Band) MatrixOps {
+	n := a.Rows
+	bi := blas64.Implementation()
+	return MatrixOps{
+		MatVec: func(dst, x []float64) {
+			checkLen(dst, x, n)
+			bi.Dgbmv(blas.NoTrans, n, n, a.KL, a.KU, 1, a.Data, a.Stride, x, 1, 0, dst, 1)
+		},
+		MatTransVec: func(dst, x []float64) {
+			checkLen(dst, x, n)
+			bi.Dgbmv(blas.Trans, n, n, a.KL, a.KU, 1, a.Data, a.Stride, x, 1, 0, dst, 1)
+		},
+	}
+}
+
+// IdentityOps returns MatrixOps for the n×n identity matrix.
+func IdentityOps(n int) MatrixOps {
+	if n < 0 {
+		panic("iterative: negative dimension")
+	}
+	matVec := func(dst, x []float64) {
+		checkLen(dst, x, n)
+		copy(dst, x)
+	}
+	return MatrixOps{
+		MatVec:      matVec,
+		MatTransVec: matVec,
+	}
+}
+
+// ZeroOps returns MatrixOps for the n×n zero matrix.
+func ZeroOps(n int) MatrixOps {
+	if n < 0 {
+		panic("iterative: negative dimension")
+	}
+	matVec := func(dst, x []float64) {
+		checkLen(dst, x, n)
+		for i := range dst {
+			dst[i] = 0
+		}
+	}
+	return MatrixOps{
+		MatVec:      matVec,
+		MatTransVec: matVec,
+	}
+}
+
+// DiagonalOps returns MatrixOps for the diagonal matrix with the diagonal d.
+// The returned MatrixOps reference d, subsequent changes to d are reflected
+// in the products.
+func DiagonalOps(d []float64) MatrixOps {
+	n := len(d)
+	matVec := func(dst, x []float64) {
+		checkLen(dst, x, n)
+		for i, v := range d {
+			dst[i] = v * x[i]
+		}
+	}
+	return MatrixOps{
+		MatVec:      matVec,
+		MatTransVec: matVec,
+	}
+}
+
+// DiagonalInverse returns a Preconditioner that applies the inverse of the
+// diagonal matrix with the diagonal d. Together with DiagonalOps it can be
+// used as the Jacobi preconditioner of a matrix with the diagonal d.
+// DiagonalInverse panics if an element of d is zero. The reciprocals are
+// computed once, d is not referenced by the returned Preconditioner.
+func DiagonalInverse(d []float64) Preconditioner {
+	inv := make([]float64, len(d))
+	for i, v := range d {
+		if v == 0 {
+			panic("iterative: zero diagonal element")
+		}
+		inv[i] = 1 / v
+	}
+	return diagonalInverse(inv)
+}
+
+// diagonalInverse is a Preconditioner given by the reciprocals of the
+// diagonal of a matrix.
+type diagonalInverse []float64
+
+// Apply implements the Preconditioner interface.
+func (p diagonalInverse) Apply(dst, rhs []float64) error {
+	checkLen(dst, rhs, len(p))
+	for i, v := range p {
+		dst[i] = v * rhs[i]
+	}
+	return nil
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p diagonalInverse) ApplyTrans(dst, rhs []float64) error {
+	return p.Apply(dst, rhs)
+}
+
+// checkDense panics if a is not a valid storage of an n×n matrix with the
+// leading dimension lda.
+func checkDense(a []float64, n, lda int) {
+	if n < 0 {
+		panic("iterative: negative dimension")
+	}
+	if lda < 1 || lda < n {
+		panic("iterative: bad leading dimension")
+	}
+	if n > 0 && len(a) < (n-1)*lda+n {
+		panic("iterative: insufficient matrix storage")
+	}
+}
+
+// matMatVec computes A*X for the column-major n×k matrix X using
+// a.MatMatVec if it is available and column by column otherwise.
+func matMatVec(a MatrixOps, dst, x []float64, n, k int) {
+	checkLenK(dst, x, n, k)
+	if a.MatMatVec != nil {
+		a.MatMatVec(dst, x, k)
+		return
+	}
+	for j := 0; j < k; j++ {
+		a.MatVec(dst[j*n:(j+1)*n], x[j*n:(j+1)*n])
+	}
+}
+
+// checkLenK panics if the length of dst or x is not n*k.
+func checkLenK(dst, x []float64, n, k int) {
+	if k < 0 {
+		panic("iterative: negative number of vectors")
+	}
+	if len(dst) != n*k || len(x) != n*k {
+		panic("iterative: mismatched vector length")
+	}
+}
+
+// checkLen panics if the length of dst or x is not n.
+func checkLen(dst, x []float64, n int) {
+	if len(dst) != n || len(x) != n {
+		panic("iterative: mismatched vector length")
+	}
+}
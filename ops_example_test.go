@@ -0,0 +1,87 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vladimir-ch/iterative"
+)
+
+func ExampleDiagonalInverse() {
+	A, b := L2Projector(0, 1, 10, func(x float64) float64 {
+		return x * math.Sin(x)
+	})
+
+	// Jacobi preconditioner given by the diagonal of the mass matrix.
+	n := len(b)
+	h := 1 / float64(n-1)
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = 2 * h / 3
+	}
+	d[0] = h / 3
+	d[n-1] = h / 3
+	p := iterative.DiagonalInverse(d)
+
+	res, err := iterative.LinearSolve(A, b, &iterative.CG{}, iterative.Settings{
+		PSolve:      p.Apply,
+		PSolveTrans: p.ApplyTrans,
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	} else {
+		fmt.Printf("# iterations: %v\n", res.Stats.Iterations)
+		fmt.Printf("Solution: %.6f\n", res.X)
+	}
+
+	// Output:
+	// # iterations: 9
+	// Solution: [-0.003338 0.006678 0.036528 0.085607 0.152981 0.237072 0.337006 0.447616 0.578244 0.682719 0.920847]
+}
+
+func ExampleShiftedOps() {
+	// Discrete 1D Laplacian with Dirichlet boundary conditions. Its smallest
+	// eigenvalue is 2-2*cos(pi/(n+1)).
+	const n = 50
+	ab := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		ab[2*i] = 2
+		if i < n-1 {
+			ab[2*i+1] = -1
+		}
+	}
+	A := iterative.SymBandedOps(ab, n, 1)
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = 1
+	}
+
+	// Follow the solution of (A - sigma*I) x = b as the shift approaches
+	// the smallest eigenvalue from below, the norm of x grows without bound.
+	lambda := 2 - 2*math.Cos(math.Pi/(n+1))
+	for _, frac := range []float64{0, 0.5, 0.9, 0.99} {
+		sigma := frac * lambda
+		res, err := iterative.LinearSolve(iterative.ShiftedOps(A, sigma), b, &iterative.CG{}, iterative.Settings{
+			Tolerance: 1e-10,
+		})
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		var norm float64
+		for _, v := range res.X {
+			norm = math.Max(norm, math.Abs(v))
+		}
+		fmt.Printf("sigma=%.6f |x|=%.4e\n", sigma, norm)
+	}
+
+	// Output:
+	// sigma=0.000000 |x|=3.2500e+02
+	// sigma=0.001897 |x|=6.5970e+02
+	// sigma=0.003414 |x|=3.3422e+03
+	// sigma=0.003755 |x|=3.3527e+04
+}
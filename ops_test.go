@@ -0,0 +1,429 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/sparse"
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// testOps returns the maximum difference between the products computed by
+// a and the products with the dense matrix want for random vectors.
+func testOps(a MatrixOps, want mat.Matrix, rnd *rand.Rand) float64 {
+	n, _ := want.Dims()
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	got := make([]float64, n)
+	var ref mat.VecDense
+	var diff float64
+	a.MatVec(got, x)
+	ref.MulVec(want, mat.NewVecDense(n, x))
+	diff = math.Max(diff, floats.Distance(got, ref.RawVector().Data, math.Inf(1)))
+	a.MatTransVec(got, x)
+	ref.MulVec(want.T(), mat.NewVecDense(n, x))
+	diff = math.Max(diff, floats.Distance(got, ref.RawVector().Data, math.Inf(1)))
+	return diff
+}
+
+func TestBandedOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		n, kl, ku int
+	}{
+		{1, 0, 0},
+		{3, 1, 1},
+		{5, 0, 2},
+		{5, 2, 0},
+		{10, 3, 1},
+		{10, 1, 4},
+		{4, 5, 5},
+	} {
+		n, kl, ku := test.n, test.kl, test.ku
+		tr := sparse.NewTriplet(n, n)
+		dense := mat.NewDense(n, n, nil)
+		sym := mat.NewSymDense(n, nil)
+		for i := 0; i < n; i++ {
+			for j := i - kl; j <= i+ku; j++ {
+				if j < 0 || n <= j {
+					continue
+				}
+				v := rnd.NormFloat64()
+				tr.Append(i, j, v)
+				dense.Set(i, j, v)
+				if j >= i {
+					sym.SetSym(i, j, v)
+				}
+			}
+		}
+		a := BandedOps(tr.ToBand(kl, ku), n, kl, ku)
+		if diff := testOps(a, dense, rnd); diff > 1e-14 {
+			t.Errorf("n=%v,kl=%v,ku=%v: unexpected products, |want-got|=%v", n, kl, ku, diff)
+		}
+
+		upper := sparse.NewTriplet(n, n)
+		for i := 0; i < n; i++ {
+			for j := i; j <= i+ku && j < n; j++ {
+				upper.Append(i, j, sym.At(i, j))
+			}
+		}
+		s := SymBandedOps(upper.ToBand(0, ku), n, ku)
+		if diff := testOps(s, sym, rnd); diff > 1e-14 {
+			t.Errorf("n=%v,k=%v: unexpected symmetric products, |want-got|=%v", n, ku, diff)
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"negative dimension", func() { BandedOps(nil, -1, 0, 0) }},
+		{"negative bandwidth", func() { BandedOps(nil, 1, -1, 0) }},
+		{"short storage", func() { BandedOps(make([]float64, 5), 2, 1, 1) }},
+		{"short vector", func() { BandedOps(make([]float64, 6), 2, 1, 1).MatVec(make([]float64, 2), make([]float64, 1)) }},
+		{"symmetric negative bandwidth", func() { SymBandedOps(nil, 1, -1) }},
+		{"symmetric short storage", func() { SymBandedOps(make([]float64, 3), 2, 1) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+func TestSymBandedOpsCG(t *testing.T) {
+	// 1D Poisson equation with Dirichlet boundary conditions.
+	const n = 100
+	ab := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		ab[2*i] = 2
+		if i < n-1 {
+			ab[2*i+1] = -1
+		}
+	}
+	a := SymBandedOps(ab, n, 1)
+	want := make([]float64, n)
+	for i := range want {
+		want[i] = 1
+	}
+	b := make([]float64, n)
+	a.MatVec(b, want)
+	r, err := LinearSolve(a, b, &CG{}, Settings{Tolerance: 1e-12})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if dist := floats.Distance(r.X, want, math.Inf(1)); dist > 1e-8 {
+		t.Errorf("unexpected solution, |want-got|=%v", dist)
+	}
+}
+
+func TestDenseOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 5, 10, 31} {
+		for _, lda := range []int{n, n + 3} {
+			a := make([]float64, (n-1)*lda+n)
+			for i := range a {
+				a[i] = rnd.NormFloat64()
+			}
+			dense := mat.NewDense(n, n, nil)
+			upper := mat.NewSymDense(n, nil)
+			lower := mat.NewSymDense(n, nil)
+			for i := 0; i < n; i++ {
+				for j := 0; j < n; j++ {
+					dense.Set(i, j, a[i*lda+j])
+					if j >= i {
+						upper.SetSym(i, j, a[i*lda+j])
+					}
+					if j <= i {
+						lower.SetSym(i, j, a[i*lda+j])
+					}
+				}
+			}
+			if diff := testOps(DenseOps(a, n, lda), dense, rnd); diff > 1e-13 {
+				t.Errorf("n=%v,lda=%v: unexpected products, |want-got|=%v", n, lda, diff)
+			}
+			if diff := testOps(SymDenseOps(a, n, lda, blas.Upper), upper, rnd); diff > 1e-13 {
+				t.Errorf("n=%v,lda=%v: unexpected upper symmetric products, |want-got|=%v", n, lda, diff)
+			}
+			if diff := testOps(SymDenseOps(a, n, lda, blas.Lower), lower, rnd); diff > 1e-13 {
+				t.Errorf("n=%v,lda=%v: unexpected lower symmetric products, |want-got|=%v", n, lda, diff)
+			}
+			if diff := testOps(DenseMatrixOps(dense), dense, rnd); diff > 1e-13 {
+				t.Errorf("n=%v,lda=%v: unexpected *mat.Dense products, |want-got|=%v", n, lda, diff)
+			}
+			if diff := testOps(SymDenseMatrixOps(upper), upper, rnd); diff > 1e-13 {
+				t.Errorf("n=%v,lda=%v: unexpected *mat.SymDense products, |want-got|=%v", n, lda, diff)
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"negative dimension", func() { DenseOps(nil, -1, 1) }},
+		{"bad leading dimension", func() { DenseOps(make([]float64, 4), 2, 1) }},
+		{"short storage", func() { DenseOps(make([]float64, 4), 2, 3) }},
+		{"short vector", func() { DenseOps(make([]float64, 4), 2, 2).MatVec(make([]float64, 2), make([]float64, 1)) }},
+		{"symmetric bad uplo", func() { SymDenseOps(make([]float64, 4), 2, 2, blas.Uplo(0)) }},
+		{"symmetric short storage", func() { SymDenseOps(make([]float64, 3), 2, 2, blas.Upper) }},
+		{"non-square", func() { DenseMatrixOps(mat.NewDense(2, 3, nil)) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+func benchmarkDenseOps(b *testing.B, n int, naive bool) {
+	rnd := rand.New(rand.NewSource(1))
+	a := make([]float64, n*n)
+	for i := range a {
+		a[i] = rnd.NormFloat64()
+	}
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	dst := make([]float64, n)
+	matVec := DenseOps(a, n, n).MatVec
+	if naive {
+		matVec = func(dst, x []float64) {
+			for i := 0; i < n; i++ {
+				var s float64
+				for j, v := range a[i*n : i*n+n] {
+					s += v * x[j]
+				}
+				dst[i] = s
+			}
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matVec(dst, x)
+	}
+}
+
+func BenchmarkDenseOps100(b *testing.B)       { benchmarkDenseOps(b, 100, false) }
+func BenchmarkDenseOpsNaive100(b *testing.B)  { benchmarkDenseOps(b, 100, true) }
+func BenchmarkDenseOps1000(b *testing.B)      { benchmarkDenseOps(b, 1000, false) }
+func BenchmarkDenseOpsNaive1000(b *testing.B) { benchmarkDenseOps(b, 1000, true) }
+
+// atOnly is a matrix that implements only the mat.Matrix interface.
+type atOnly struct {
+	mat.Matrix
+}
+
+func (m atOnly) T() mat.Matrix { return mat.Transpose{Matrix: m} }
+
+func TestNewMatrixOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{3, 5, 10, 23} {
+		dense := mat.NewDense(n, n, nil)
+		sym := mat.NewSymDense(n, nil)
+		band := mat.NewBandDense(n, n, 2, 1, nil)
+		symBand := mat.NewSymBandDense(n, 2, nil)
+		tr := sparse.NewTriplet(n, n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				v := rnd.NormFloat64()
+				dense.Set(i, j, v)
+				if j >= i {
+					sym.SetSym(i, j, v)
+					if j-i <= 2 {
+						symBand.SetSymBand(i, j, v)
+					}
+				}
+				if -2 <= j-i && j-i <= 1 {
+					band.SetBand(i, j, v)
+				}
+				if rnd.Intn(3) == 0 {
+					tr.Append(i, j, v)
+				}
+			}
+		}
+		csr := sparse.NewCSRFromTriplet(tr)
+		for _, test := range []struct {
+			name string
+			m    mat.Matrix
+		}{
+			{"Dense", dense},
+			{"SymDense", sym},
+			{"BandDense", band},
+			{"SymBandDense", symBand},
+			{"Transpose", dense.T()},
+			{"CSR", csr},
+			{"At only", atOnly{dense}},
+			{"At only band", atOnly{band}},
+		} {
+			if diff := testOps(NewMatrixOps(test.m), test.m, rnd); diff > 1e-13 {
+				t.Errorf("%v,n=%v: unexpected products, |want-got|=%v", test.name, n, diff)
+			}
+		}
+	}
+
+	if !panics(func() { NewMatrixOps(mat.NewDense(2, 3, nil)) }) {
+		t.Errorf("non-square matrix did not panic")
+	}
+	if !panics(func() { NewMatrixOps(atOnly{mat.NewDense(2, 2, nil)}).MatVec(make([]float64, 2), make([]float64, 3)) }) {
+		t.Errorf("mismatched vector length did not panic")
+	}
+}
+
+func TestDiagonalOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 5, 10} {
+		d := make([]float64, n)
+		for i := range d {
+			d[i] = rnd.NormFloat64()
+		}
+		if n > 0 {
+			if diff := testOps(DiagonalOps(d), mat.NewDiagDense(n, d), rnd); diff > 0 {
+				t.Errorf("n=%v: unexpected diagonal products, |want-got|=%v", n, diff)
+			}
+			if diff := testOps(IdentityOps(n), mat.NewDiagDense(n, ones(n)), rnd); diff > 0 {
+				t.Errorf("n=%v: unexpected identity products, |want-got|=%v", n, diff)
+			}
+			if diff := testOps(ZeroOps(n), mat.NewDiagDense(n, make([]float64, n)), rnd); diff > 0 {
+				t.Errorf("n=%v: unexpected zero products, |want-got|=%v", n, diff)
+			}
+		}
+
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+		}
+		ax := make([]float64, n)
+		a := DiagonalOps(d)
+		a.MatVec(ax, x)
+		p := DiagonalInverse(d)
+		got := make([]float64, n)
+		if err := p.Apply(got, ax); err != nil {
+			t.Fatalf("n=%v: unexpected error %v", n, err)
+		}
+		if dist := floats.Distance(got, x, math.Inf(1)); dist > 1e-14 {
+			t.Errorf("n=%v: unexpected inverse, |want-got|=%v", n, dist)
+		}
+		allocs := testing.AllocsPerRun(10, func() {
+			a.MatTransVec(ax, x)
+			_ = p.ApplyTrans(got, ax)
+		})
+		if allocs != 0 {
+			t.Errorf("n=%v: unexpected allocations %v", n, allocs)
+		}
+	}
+
+	if !panics(func() { DiagonalInverse([]float64{1, 0}) }) {
+		t.Errorf("zero diagonal element did not panic")
+	}
+	if !panics(func() { IdentityOps(-1) }) {
+		t.Errorf("negative dimension did not panic")
+	}
+	if !panics(func() { ZeroOps(2).MatVec(make([]float64, 1), make([]float64, 2)) }) {
+		t.Errorf("short vector did not panic")
+	}
+}
+
+func ones(n int) []float64 {
+	v := make([]float64, n)
+	for i := range v {
+		v[i] = 1
+	}
+	return v
+}
+
+func TestMatMatVec(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 3, 10, 25} {
+		dense := mat.NewDense(n, n, nil)
+		sym := mat.NewSymDense(n, nil)
+		tr := sparse.NewTriplet(n, n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				v := rnd.NormFloat64()
+				dense.Set(i, j, v)
+				if j >= i {
+					sym.SetSym(i, j, v)
+				}
+				if rnd.Intn(3) == 0 {
+					tr.Append(i, j, v)
+				}
+			}
+		}
+		csr := sparse.NewCSRFromTriplet(tr)
+		for _, test := range []struct {
+			name string
+			ops  MatrixOps
+			m    mat.Matrix
+		}{
+			{"DenseOps", DenseMatrixOps(dense), dense},
+			{"SymDenseOps", SymDenseMatrixOps(sym), sym},
+			{"CSR", NewMatrixOps(csr), csr},
+			{"fallback", MatrixOps{MatVec: DenseMatrixOps(dense).MatVec}, dense},
+		} {
+			if test.name != "fallback" && test.ops.MatMatVec == nil {
+				t.Errorf("%v,n=%v: nil MatMatVec", test.name, n)
+			}
+			for _, k := range []int{0, 1, 4, 7} {
+				x := make([]float64, n*k)
+				for i := range x {
+					x[i] = rnd.NormFloat64()
+				}
+				got := make([]float64, n*k)
+				for i := range got {
+					got[i] = math.NaN()
+				}
+				matMatVec(test.ops, got, x, n, k)
+				if k == 0 {
+					continue
+				}
+				// The column-major n×k matrices are k×n row-major.
+				var want mat.Dense
+				want.Mul(mat.NewDense(k, n, x), test.m.T())
+				if dist := floats.Distance(got, want.RawMatrix().Data, math.Inf(1)); dist > 1e-13 {
+					t.Errorf("%v,n=%v,k=%v: unexpected products, |want-got|=%v", test.name, n, k, dist)
+				}
+			}
+		}
+	}
+	if !panics(func() { matMatVec(IdentityOps(2), make([]float64, 4), make([]float64, 3), 2, 2) }) {
+		t.Errorf("mismatched length did not panic")
+	}
+}
+
+func benchmarkMatMatVec(b *testing.B, n, k int, batched bool) {
+	rnd := rand.New(rand.NewSource(1))
+	a := make([]float64, n*n)
+	for i := range a {
+		a[i] = rnd.NormFloat64()
+	}
+	x := make([]float64, n*k)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	dst := make([]float64, n*k)
+	ops := DenseOps(a, n, n)
+	if !batched {
+		ops.MatMatVec = nil
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matMatVec(ops, dst, x, n, k)
+	}
+}
+
+func BenchmarkDenseMatMatVec(b *testing.B) {
+	for _, k := range []int{4, 8, 16} {
+		b.Run(fmt.Sprintf("k=%d/batched", k), func(b *testing.B) { benchmarkMatMatVec(b, 1000, k, true) })
+		b.Run(fmt.Sprintf("k=%d/columns", k), func(b *testing.B) { benchmarkMatMatVec(b, 1000, k, false) })
+	}
+}
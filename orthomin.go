@@ -0,0 +1,152 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+// Orthomin implements the ORTHOMIN(k) iterative method with preconditioning
+// for solving the system of linear equations
+//  Ax = b,
+// where A is a non-symmetric matrix.
+//
+// ORTHOMIN(k) minimizes the residual norm along a search direction that is
+// A^T A-orthogonal only to the last k directions. Unlike GMRES it does not
+// need to restart, its storage does not grow with the number of iterations
+// and the residual norm does not increase, but the truncation can make the
+// convergence slow or stagnate if the symmetric part of AM^{-1} is not
+// positive definite. ORTHOMIN(k) is mathematically equivalent to GCR and
+// GMRES if k is at least the number of iterations. If the new direction is
+// in the span of the previous ones, Iterate returns a *BreakdownError.
+//
+// Orthomin is preconditioned from the right.
+//
+// References:
+//  - Vinsome, P.K.W.: Orthomin, an iterative method for solving sparse sets
+//    of simultaneous linear equations. In: Proceedings of the Fourth
+//    Symposium on Reservoir Simulation, pp. 149-159. Society of Petroleum
+//    Engineers (1976)
+//  - Eisenstat, S.C., Elman, H.C., Schultz, M.H.: Variational iterative
+//    methods for nonsymmetric systems of linear equations. SIAM J. Numer.
+//    Anal. 20(2), 345-357 (1983)
+//
+// Orthomin needs MatVec and PSolve matrix operations.
+type Orthomin struct {
+	// K is the number of previous search
+	// directions against which a new direction
+	// is orthogonalized. If it is 0, 1 is
+	// used. It must not be negative.
+	K int
+
+	resume int
+	k      int
+	i      int // Number of completed iterations.
+
+	z  []float64
+	az []float64
+
+	// Ring buffers of the last k directions p_j,
+	// the products Ap_j and Ap_j·Ap_j. The
+	// direction from the iteration i is stored
+	// at i%k.
+	p    [][]float64
+	ap   [][]float64
+	apap []float64
+}
+
+// Init implements the Method interface.
+func (o *Orthomin) Init(dim int) {
+	if dim <= 0 {
+		panic("Orthomin: dimension not positive")
+	}
+	if o.K < 0 {
+		panic("Orthomin: negative K")
+	}
+
+	o.k = o.K
+	if o.k == 0 {
+		o.k = 1
+	}
+	k := o.k
+
+	o.z = reuse(o.z, dim)
+	o.az = reuse(o.az, dim)
+	if cap(o.p) < k {
+		o.p = make([][]float64, k)
+		o.ap = make([][]float64, k)
+	} else {
+		o.p = o.p[:k]
+		o.ap = o.ap[:k]
+	}
+	for j := range o.p {
+		o.p[j] = reuse(o.p[j], dim)
+		o.ap[j] = reuse(o.ap[j], dim)
+	}
+	o.apap = reuse(o.apap, k)
+
+	o.i = 0
+	o.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (o *Orthomin) Iterate(ctx *Context) (Operation, error) {
+	switch o.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = o.z
+		o.resume = 2
+		return PSolve, nil
+		// Solve M z = r_i.
+	case 2:
+		ctx.Src = o.z
+		ctx.Dst = o.az
+		o.resume = 3
+		return MatVec, nil
+		// Compute Az.
+	case 3:
+		// Orthogonalize Az against the last k products
+		// Ap_j and update z in the same way.
+		m := o.i
+		if m > o.k {
+			m = o.k
+		}
+		for l := o.i - m; l < o.i; l++ {
+			j := l % o.k
+			beta := ctx.vec.dot(o.az, o.ap[j]) / o.apap[j]
+			ctx.vec.addScaled(o.z, -beta, o.p[j])   // p_i = z - Σ β_j p_j
+			ctx.vec.addScaled(o.az, -beta, o.ap[j]) // Ap_i = Az - Σ β_j Ap_j
+		}
+		apap := ctx.vec.dot(o.az, o.az)
+		if apap == 0 {
+			o.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"Orthomin", "Ap"}
+		}
+		alpha := ctx.vec.dot(ctx.Residual, o.az) / apap // α = r_i·Ap_i / Ap_i·Ap_i
+		// r_{i+1} = r_i - α Ap_i
+		// x_{i+1} = x_i + α p_i
+		ctx.ResidualNorm = ctx.vec.cgUpdate(alpha, ctx.X, o.z, ctx.Residual, o.az)
+
+		// Store p_i and Ap_i in place of the oldest direction.
+		j := o.i % o.k
+		ctx.vec.copy(o.p[j], o.z)
+		ctx.vec.copy(o.ap[j], o.az)
+		o.apap[j] = apap
+		o.i++
+
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		o.resume = 4
+		return CheckResidualNorm, nil
+	case 4:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			o.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		o.resume = 1
+		return EndIteration, nil
+
+	default:
+		panic("Orthomin: Init not called")
+	}
+}
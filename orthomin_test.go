@@ -0,0 +1,75 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestOrthomin(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, k := range []int{1, 5, 10} {
+		for _, p := range []*problem.Problem{
+			problem.RandomSPD(1, rnd),
+			problem.RandomSPD(2, rnd),
+			problem.RandomSPD(5, rnd),
+			problem.RandomSPD(20, rnd),
+			problem.RandomSPD(100, rnd),
+			market("nos4", 1e-12),
+			market("bcsstm22", 1e-10),
+			market("e05r0000", 1e-10),
+		} {
+			_, err := p.Solve(&iterative.Orthomin{K: k}, iterative.Settings{Tolerance: 1e-14})
+			if err != nil {
+				t.Errorf("K=%d: %v", k, err)
+			}
+		}
+	}
+
+	// With K at least the number of iterations
+	// ORTHOMIN(k) is equivalent to GMRES.
+	p := market("gre__185", 1e-6)
+	settings := iterative.Settings{Tolerance: 1e-12}
+	res, err := p.Solve(&iterative.Orthomin{K: p.Dim}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = market("gre__185", 1e-6)
+	want, err := p.Solve(&iterative.GMRES{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stats.Iterations != want.Stats.Iterations {
+		t.Errorf("ORTHOMIN(%d) needs %d iterations, GMRES %d", p.Dim, res.Stats.Iterations, want.Stats.Iterations)
+	}
+}
+
+func TestOrthominTruncation(t *testing.T) {
+	// The symmetric part of gre__185 is indefinite
+	// so ORTHOMIN(k) with small k stagnates. The
+	// stagnation level decreases with k and only
+	// ORTHOMIN(k) with k >= 4 reaches the tolerance.
+	const tol = 1.5e-2
+	prev := -1
+	for k := 1; k <= 10; k++ {
+		p := market("gre__185", 1)
+		iters := p.MaxIterations
+		res, err := p.Solve(&iterative.Orthomin{K: k}, iterative.Settings{Tolerance: tol})
+		if err == nil {
+			iters = res.Stats.Iterations
+		}
+		if prev >= 0 && iters > prev {
+			t.Errorf("ORTHOMIN(%d) needs %d iterations, ORTHOMIN(%d) %d", k, iters, k-1, prev)
+		}
+		prev = iters
+	}
+	if prev == market("gre__185", 1).MaxIterations {
+		t.Errorf("ORTHOMIN(10) did not converge")
+	}
+}
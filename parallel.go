@@ -0,0 +1,353 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"runtime"
+	"sync"
+
+	"gonum.org/v1/gonum/blas"
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/floats"
+)
+
+// ParallelThreshold is the vector length below which the vector operations
+// are done serially even if Settings.Threads is greater than one. For
+// shorter vectors the cost of starting the goroutines exceeds the gain.
+const ParallelThreshold = 1 << 16
+
+// vecOps performs the vector operations of LinearSolve and of the methods.
+// Vectors of length at least ParallelThreshold are split into threads
+// contiguous chunks, each processed by its own goroutine. The elementwise
+// updates are identical to the serial ones. The reductions combine the
+// partial results of the chunks in the order of the chunks, so they are
+// reproducible from run to run for a fixed number of threads, but they
+// differ from the serial results by rounding errors.
+//
+// A nil *vecOps does all operations serially by calling the floats
+// package and the fused kernels, so methods driven without LinearSolve
+// behave as before.
+type vecOps struct {
+	threads int
+	partial []float64 // Partial results of the chunks.
+
+	// spare is the second buffer of partial
+	// results used by mgs, bar synchronizes
+	// its goroutines.
+	spare []float64
+	bar   *barrier
+}
+
+// newVecOps returns the vector operations for the given number of threads
+// as specified by Settings.Threads. It returns nil for serial operations.
+func newVecOps(threads int) *vecOps {
+	if threads < 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	if threads <= 1 {
+		return nil
+	}
+	return &vecOps{
+		threads: threads,
+		partial: make([]float64, threads),
+		spare:   make([]float64, threads),
+		bar:     newBarrier(threads),
+	}
+}
+
+// parallel returns whether the operations on vectors of length n are done
+// in parallel.
+func (v *vecOps) parallel(n int) bool {
+	return v != nil && n >= ParallelThreshold
+}
+
+// run calls fn for each of the v.threads chunks of [0,n) in separate
+// goroutines and waits for all of them to finish. fn receives the index of
+// the chunk and its bounds.
+func (v *vecOps) run(n int, fn func(w, lo, hi int)) {
+	var wg sync.WaitGroup
+	wg.Add(v.threads)
+	for w := 0; w < v.threads; w++ {
+		go func(w int) {
+			fn(w, w*n/v.threads, (w+1)*n/v.threads)
+			wg.Done()
+		}(w)
+	}
+	wg.Wait()
+}
+
+// sum returns the sum of the partial results in the order of the chunks.
+func sum(partial []float64) float64 {
+	var s float64
+	for _, p := range partial {
+		s += p
+	}
+	return s
+}
+
+// hypot returns the square root of the sum of squares of the partial
+// results, scaled to avoid overflow.
+func hypot(partial []float64) float64 {
+	var scale float64
+	for _, p := range partial {
+		scale = math.Max(scale, math.Abs(p))
+	}
+	if scale == 0 || math.IsInf(scale, 1) {
+		return scale
+	}
+	var s float64
+	for _, p := range partial {
+		p /= scale
+		s += p * p
+	}
+	return scale * math.Sqrt(s)
+}
+
+func (v *vecOps) dot(x, y []float64) float64 {
+	if !v.parallel(len(x)) {
+		return floats.Dot(x, y)
+	}
+	if len(x) != len(y) {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(len(x), func(w, lo, hi int) {
+		v.partial[w] = floats.Dot(x[lo:hi], y[lo:hi])
+	})
+	return sum(v.partial)
+}
+
+func (v *vecOps) norm(x []float64) float64 {
+	if !v.parallel(len(x)) {
+		return floats.Norm(x, 2)
+	}
+	v.run(len(x), func(w, lo, hi int) {
+		v.partial[w] = floats.Norm(x[lo:hi], 2)
+	})
+	return hypot(v.partial)
+}
+
+// addScaled computes dst += alpha * s.
+func (v *vecOps) addScaled(dst []float64, alpha float64, s []float64) {
+	if !v.parallel(len(dst)) {
+		floats.AddScaled(dst, alpha, s)
+		return
+	}
+	if len(dst) != len(s) {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(len(dst), func(_, lo, hi int) {
+		floats.AddScaled(dst[lo:hi], alpha, s[lo:hi])
+	})
+}
+
+// addScaledTo computes dst = y + alpha * s.
+func (v *vecOps) addScaledTo(dst, y []float64, alpha float64, s []float64) {
+	if !v.parallel(len(dst)) {
+		floats.AddScaledTo(dst, y, alpha, s)
+		return
+	}
+	if len(dst) != len(y) || len(dst) != len(s) {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(len(dst), func(_, lo, hi int) {
+		floats.AddScaledTo(dst[lo:hi], y[lo:hi], alpha, s[lo:hi])
+	})
+}
+
+// add computes dst += s.
+func (v *vecOps) add(dst, s []float64) {
+	if !v.parallel(len(dst)) {
+		floats.Add(dst, s)
+		return
+	}
+	if len(dst) != len(s) {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(len(dst), func(_, lo, hi int) {
+		floats.Add(dst[lo:hi], s[lo:hi])
+	})
+}
+
+// scale computes dst *= c.
+func (v *vecOps) scale(c float64, dst []float64) {
+	if !v.parallel(len(dst)) {
+		floats.Scale(c, dst)
+		return
+	}
+	v.run(len(dst), func(_, lo, hi int) {
+		floats.Scale(c, dst[lo:hi])
+	})
+}
+
+// copy copies src into dst, which must have the same length.
+func (v *vecOps) copy(dst, src []float64) {
+	if !v.parallel(len(dst)) {
+		copy(dst, src)
+		return
+	}
+	v.run(len(dst), func(_, lo, hi int) {
+		copy(dst[lo:hi], src[lo:hi])
+	})
+}
+
+// axpyDot is the parallel version of the axpyDot kernel.
+func (v *vecOps) axpyDot(alpha float64, x, y []float64) float64 {
+	if !v.parallel(len(x)) {
+		return axpyDot(alpha, x, y)
+	}
+	if len(x) != len(y) {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(len(x), func(w, lo, hi int) {
+		v.partial[w] = axpyDot(alpha, x[lo:hi], y[lo:hi])
+	})
+	return sum(v.partial)
+}
+
+// cgUpdate is the parallel version of the cgUpdate kernel.
+func (v *vecOps) cgUpdate(alpha float64, x, p, r, ap []float64) float64 {
+	n := len(x)
+	if !v.parallel(n) {
+		return cgUpdate(alpha, x, p, r, ap)
+	}
+	if len(p) != n || len(r) != n || len(ap) != n {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(n, func(w, lo, hi int) {
+		v.partial[w] = cgUpdate(alpha, x[lo:hi], p[lo:hi], r[lo:hi], ap[lo:hi])
+	})
+	return hypot(v.partial)
+}
+
+// bicgstabUpdate is the parallel version of the bicgstabUpdate kernel.
+func (v *vecOps) bicgstabUpdate(alpha, omega float64, x, phat, shat, r, t []float64) float64 {
+	n := len(x)
+	if !v.parallel(n) {
+		return bicgstabUpdate(alpha, omega, x, phat, shat, r, t)
+	}
+	if len(phat) != n || len(shat) != n || len(r) != n || len(t) != n {
+		panic("iterative: mismatched vector length")
+	}
+	v.run(n, func(w, lo, hi int) {
+		v.partial[w] = bicgstabUpdate(alpha, omega, x[lo:hi], phat[lo:hi], shat[lo:hi], r[lo:hi], t[lo:hi])
+	})
+	return hypot(v.partial)
+}
+
+// mgs orthogonalizes w against the first len(h) columns of the column-major
+// matrix V with the leading dimension ldv by the modified Gram-Schmidt
+// process. It stores the coefficients V[:,i]^T w in h and returns the norm
+// of the orthogonalized w.
+//
+// In parallel, each goroutine processes its chunk of rows of V and w for
+// all columns and the goroutines meet at a barrier after each inner
+// product. The result is the same as of the sequence of dot and addScaled
+// calls but the goroutines are started only once.
+func (v *vecOps) mgs(h, V []float64, ldv int, w []float64) float64 {
+	n := len(w)
+	if !v.parallel(n) {
+		for i := range h {
+			vi := V[i*ldv : i*ldv+n]
+			h[i] = floats.Dot(vi, w)
+			floats.AddScaled(w, -h[i], vi)
+		}
+		return floats.Norm(w, 2)
+	}
+	// The partial results alternate between
+	// two buffers, so a goroutine can store
+	// the next one while others still sum
+	// the current one.
+	buf := [2][]float64{v.partial, v.spare}
+	v.run(n, func(t, lo, hi int) {
+		for i := range h {
+			vi := V[i*ldv+lo : i*ldv+hi]
+			p := buf[i%2]
+			p[t] = floats.Dot(vi, w[lo:hi])
+			v.bar.wait()
+			hiw := sum(p)
+			if t == 0 {
+				h[i] = hiw
+			}
+			floats.AddScaled(w[lo:hi], -hiw, vi)
+		}
+		buf[len(h)%2][t] = floats.Norm(w[lo:hi], 2)
+	})
+	return hypot(buf[len(h)%2])
+}
+
+// cgs orthogonalizes w against the first len(h) columns of the column-major
+// matrix V with the leading dimension ldv by the classical Gram-Schmidt
+// process, stores the coefficients V^T w in h and returns the norm of the
+// orthogonalized w.
+func (v *vecOps) cgs(h, V []float64, ldv int, w []float64) float64 {
+	n := len(w)
+	if !v.parallel(n) {
+		// V is stored column-major while Dgemv expects
+		// row-major, so V^T is the len(h)×n matrix.
+		bi := blas64.Implementation()
+		bi.Dgemv(blas.NoTrans, len(h), n, 1, V, ldv, w, 1, 0, h, 1) // h = V^T w
+		bi.Dgemv(blas.Trans, len(h), n, -1, V, ldv, h, 1, 1, w, 1)  // w -= V h
+		return floats.Norm(w, 2)
+	}
+	for i := range h {
+		h[i] = v.dot(V[i*ldv:i*ldv+n], w)
+	}
+	// Negation is exact, so h is restored.
+	floats.Scale(-1, h)
+	v.addMul(w, V, ldv, h)
+	floats.Scale(-1, h)
+	return v.norm(w)
+}
+
+// addMul computes x += V*y where V is the column-major matrix with
+// len(y) columns and the leading dimension ldv. In parallel, each goroutine
+// updates its chunk of x with all columns.
+func (v *vecOps) addMul(x, V []float64, ldv int, y []float64) {
+	n := len(x)
+	if !v.parallel(n) {
+		for j, yj := range y {
+			floats.AddScaled(x, yj, V[j*ldv:j*ldv+n])
+		}
+		return
+	}
+	v.run(n, func(_, lo, hi int) {
+		for j, yj := range y {
+			floats.AddScaled(x[lo:hi], yj, V[j*ldv+lo:j*ldv+hi])
+		}
+	})
+}
+
+// barrier blocks goroutines until all n of them have called wait. It can
+// be used repeatedly.
+type barrier struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	n     int
+	count int
+	gen   int // Incremented when all goroutines have arrived.
+}
+
+func newBarrier(n int) *barrier {
+	b := &barrier{n: n}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *barrier) wait() {
+	b.mu.Lock()
+	gen := b.gen
+	b.count++
+	if b.count == b.n {
+		b.count = 0
+		b.gen++
+		b.cond.Broadcast()
+	} else {
+		for gen == b.gen {
+			b.cond.Wait()
+		}
+	}
+	b.mu.Unlock()
+}
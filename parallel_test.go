@@ -0,0 +1,412 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"compress/gzip"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"runtime"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestVecOps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{10, ParallelThreshold - 1, ParallelThreshold + 123} {
+		for _, threads := range []int{2, 3, 8} {
+			const alpha, omega = 0.7, -1.3
+			serial := (*vecOps)(nil)
+			par := newVecOps(threads)
+			name := fmt.Sprintf("n=%v,threads=%v", n, threads)
+
+			v := randomVecs(n, 5, rnd)
+			want := copyVecs(v)
+			got := copyVecs(v)
+			checkClose := func(op string, got, want float64) {
+				if math.Abs(got-want) > 1e-12*math.Abs(want) {
+					t.Errorf("%v: %v: want %v, got %v", name, op, want, got)
+				}
+			}
+			checkEqual := func(op string, got, want []float64) {
+				if !floats.Equal(got, want) {
+					t.Errorf("%v: %v: vectors differ", name, op)
+				}
+			}
+
+			checkClose("dot", par.dot(got[0], got[1]), serial.dot(want[0], want[1]))
+			checkClose("norm", par.norm(got[0]), serial.norm(want[0]))
+
+			par.addScaled(got[0], alpha, got[1])
+			serial.addScaled(want[0], alpha, want[1])
+			checkEqual("addScaled", got[0], want[0])
+			par.addScaledTo(got[2], got[0], alpha, got[1])
+			serial.addScaledTo(want[2], want[0], alpha, want[1])
+			checkEqual("addScaledTo", got[2], want[2])
+			par.add(got[0], got[1])
+			serial.add(want[0], want[1])
+			checkEqual("add", got[0], want[0])
+			par.scale(omega, got[0])
+			serial.scale(omega, want[0])
+			checkEqual("scale", got[0], want[0])
+			par.copy(got[3], got[0])
+			serial.copy(want[3], want[0])
+			checkEqual("copy", got[3], want[3])
+
+			checkClose("axpyDot", par.axpyDot(alpha, got[0], got[1]), serial.axpyDot(alpha, want[0], want[1]))
+			checkEqual("axpyDot", got[1], want[1])
+			checkClose("cgUpdate", par.cgUpdate(alpha, got[0], got[1], got[2], got[3]), serial.cgUpdate(alpha, want[0], want[1], want[2], want[3]))
+			checkEqual("cgUpdate", got[0], want[0])
+			checkEqual("cgUpdate", got[2], want[2])
+			checkClose("bicgstabUpdate", par.bicgstabUpdate(alpha, omega, got[0], got[1], got[2], got[3], got[4]), serial.bicgstabUpdate(alpha, omega, want[0], want[1], want[2], want[3], want[4]))
+			checkEqual("bicgstabUpdate", got[0], want[0])
+			checkEqual("bicgstabUpdate", got[3], want[3])
+
+			// mgs must be the same as the sequence
+			// of dot and addScaled.
+			const k = 3
+			V := make([]float64, 0, k*n)
+			for i := 0; i < k; i++ {
+				V = append(V, v[i]...)
+			}
+			w := append([]float64(nil), v[4]...)
+			h := make([]float64, k)
+			wnorm := par.mgs(h, V, n, w)
+			want[4] = append(want[4][:0], v[4]...)
+			for i := 0; i < k; i++ {
+				hi := par.dot(V[i*n:(i+1)*n], want[4])
+				if h[i] != hi {
+					t.Errorf("%v: mgs: unexpected coefficient %d: want %v, got %v", name, i, hi, h[i])
+				}
+				par.addScaled(want[4], -hi, V[i*n:(i+1)*n])
+			}
+			checkEqual("mgs", w, want[4])
+			if wn := par.norm(want[4]); wnorm != wn {
+				t.Errorf("%v: mgs: unexpected norm: want %v, got %v", name, wn, wnorm)
+			}
+			serialNorm := serial.mgs(make([]float64, k), V, n, append([]float64(nil), v[4]...))
+			checkClose("mgs", wnorm, serialNorm)
+
+			par.addMul(got[0], V, n, h)
+			for i := 0; i < k; i++ {
+				serial.addScaled(want[0], h[i], V[i*n:(i+1)*n])
+			}
+			checkEqual("addMul", got[0], want[0])
+
+			// cgs must agree with the serial cgs,
+			// which uses Dgemv, up to rounding.
+			gotW := append([]float64(nil), v[4]...)
+			wantW := append([]float64(nil), v[4]...)
+			gotH := make([]float64, k)
+			wantH := make([]float64, k)
+			checkClose("cgs", par.cgs(gotH, V, n, gotW), serial.cgs(wantH, V, n, wantW))
+			for i := range gotH {
+				checkClose("cgs", gotH[i], wantH[i])
+			}
+			tol := 1e-12 * (floats.Norm(v[4], math.Inf(1)) + floats.Norm(wantH, 1)*floats.Norm(V, math.Inf(1)))
+			if !floats.EqualApprox(gotW, wantW, tol) {
+				t.Errorf("%v: cgs: vectors differ", name)
+			}
+
+			// Reductions must be reproducible.
+			dot := par.dot(got[0], got[1])
+			norm := par.norm(got[0])
+			for i := 0; i < 10; i++ {
+				if d := par.dot(got[0], got[1]); d != dot {
+					t.Errorf("%v: dot not reproducible: %v != %v", name, d, dot)
+				}
+				if d := par.norm(got[0]); d != norm {
+					t.Errorf("%v: norm not reproducible: %v != %v", name, d, norm)
+				}
+			}
+		}
+	}
+
+	if newVecOps(0) != nil || newVecOps(1) != nil {
+		t.Errorf("unexpected non-nil vecOps for serial operations")
+	}
+	if v := newVecOps(-1); runtime.GOMAXPROCS(0) > 1 && (v == nil || v.threads != runtime.GOMAXPROCS(0)) {
+		t.Errorf("unexpected vecOps for negative threads")
+	}
+	if h := hypot([]float64{3e300, 4e300}); math.Abs(h-5e300) > 1e286 {
+		t.Errorf("unexpected hypot %v, want 5e300", h)
+	}
+}
+
+// diagDominant returns the n×n symmetric tridiagonal matrix with 4 on the
+// diagonal and -1 off the diagonal.
+func diagDominant(n int) MatrixOps {
+	ab := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		ab[2*i] = 4
+		if i < n-1 {
+			ab[2*i+1] = -1
+		}
+	}
+	return SymBandedOps(ab, n, 1)
+}
+
+func TestLinearSolveThreads(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large solves in short mode")
+	}
+	n := 2*ParallelThreshold + 1
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	b := make([]float64, n)
+	a.MatVec(b, ones(n))
+	for _, method := range []struct {
+		name string
+		new  func() Method
+	}{
+		{"CG", func() Method { return &CG{} }},
+		{"BiCG", func() Method { return &BiCG{} }},
+		{"BiCGSTAB", func() Method { return &BiCGSTAB{} }},
+		{"GMRES(10)", func() Method { return &GMRES{Restart: 10} }},
+	} {
+		var first []float64
+		for i := 0; i < 3; i++ {
+			res, err := LinearSolve(a, b, method.new(), Settings{
+				Tolerance: 1e-10,
+				Threads:   4,
+				X0:        make([]float64, n),
+			})
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", method.name, err)
+				break
+			}
+			if d := floats.Distance(res.X, ones(n), math.Inf(1)); d > 1e-7 {
+				t.Errorf("%v: unexpected solution, |want-got|=%v", method.name, d)
+			}
+			if first == nil {
+				first = res.X
+			} else if !floats.Equal(res.X, first) {
+				t.Errorf("%v: solution not reproducible", method.name)
+			}
+		}
+	}
+}
+
+// TestGMRESReorthogonalization checks that the classical Gram-Schmidt
+// process with reorthogonalization keeps the columns of V orthonormal to
+// machine precision on an ill-conditioned matrix, where the modified
+// Gram-Schmidt process loses orthogonality.
+func TestGMRESReorthogonalization(t *testing.T) {
+	f, err := os.Open("testdata/west0479.mtx.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := mmarket.NewReader(gz).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := sparse.NewCSRFromTriplet(m)
+	n, _ := a.Dims()
+	b := make([]float64, n)
+	a.MulVec(b, ones(n))
+
+	const iters = 300
+	for _, test := range []struct {
+		orth Orthogonalization
+		tol  float64
+	}{
+		// The loss of orthogonality of the modified
+		// Gram-Schmidt process shows that the matrix
+		// is ill-conditioned enough.
+		{ModifiedGramSchmidt, 1e-11},
+		{ClassicalGramSchmidt2, 1e-12},
+	} {
+		g := &GMRES{Restart: iters, Orthogonalization: test.orth}
+		_, err := LinearSolve(MatrixOps{MatVec: a.MulVec}, b, g, Settings{
+			Tolerance:     1e-15,
+			MaxIterations: iters,
+		})
+		if err == nil {
+			t.Fatalf("%v: unexpected convergence", test.orth)
+		}
+		o := orthogonality(g.v, g.ldv, iters)
+		switch test.orth {
+		case ModifiedGramSchmidt:
+			if o < test.tol {
+				t.Errorf("ModifiedGramSchmidt: unexpected orthogonality |V^T V - I| = %v", o)
+			}
+		default:
+			if o > test.tol {
+				t.Errorf("ClassicalGramSchmidt2: loss of orthogonality |V^T V - I| = %v", o)
+			}
+		}
+	}
+}
+
+// orthogonality returns the maximum norm of V^T V - I for the first k
+// columns of the column-major matrix V with the leading dimension ldv.
+func orthogonality(V []float64, ldv, k int) float64 {
+	var d float64
+	for i := 0; i < k; i++ {
+		for j := 0; j <= i; j++ {
+			vij := floats.Dot(V[i*ldv:(i+1)*ldv], V[j*ldv:(j+1)*ldv])
+			if i == j {
+				vij--
+			}
+			d = math.Max(d, math.Abs(vij))
+		}
+	}
+	return d
+}
+
+// TestGMRESOrthogonality checks that the columns of V computed by GMRES
+// with the parallel orthogonalization are as orthonormal as with the
+// serial one. The modified Gram-Schmidt process loses orthogonality
+// gradually, so the parallel result is compared with the serial one.
+func TestGMRESOrthogonality(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large solves in short mode")
+	}
+	const iters = 40
+	n := 2*ParallelThreshold + 1
+	rnd := rand.New(rand.NewSource(1))
+	// A non-symmetric matrix, so that the
+	// Arnoldi process does not reduce to
+	// the Lanczos process.
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = 1 + 10*rnd.Float64()
+	}
+	a := MatrixOps{
+		MatVec: func(dst, x []float64) {
+			for i := range dst {
+				dst[i] = d[i] * x[i]
+				if i > 0 {
+					dst[i] += x[i-1]
+				}
+				if i < n-2 {
+					dst[i] -= 0.5 * x[i+2]
+				}
+			}
+		},
+	}
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = rnd.NormFloat64()
+	}
+	var (
+		serial float64
+		first  []float64
+	)
+	for _, threads := range []int{1, 4, 4} {
+		g := &GMRES{Restart: 50}
+		_, err := LinearSolve(a, b, g, Settings{
+			Tolerance:     1e-15,
+			MaxIterations: iters,
+			Threads:       threads,
+		})
+		if err == nil {
+			t.Fatalf("threads=%v: unexpected convergence", threads)
+		}
+		o := orthogonality(g.v, g.ldv, iters+1)
+		if o > 1e-8 {
+			t.Errorf("threads=%v: loss of orthogonality |V^T V - I| = %v", threads, o)
+		}
+		if threads == 1 {
+			serial = o
+			continue
+		}
+		if o > 10*serial {
+			t.Errorf("threads=%v: orthogonality degraded: |V^T V - I| = %v, serial %v", threads, o, serial)
+		}
+		if first == nil {
+			first = append([]float64(nil), g.v...)
+		} else if !floats.Equal(g.v, first) {
+			t.Errorf("threads=%v: V not reproducible", threads)
+		}
+	}
+}
+
+func BenchmarkVecOps(b *testing.B) {
+	const n = 10000000
+	if testing.Short() {
+		b.Skip("skipping large vectors in short mode")
+	}
+	rnd := rand.New(rand.NewSource(1))
+	v := randomVecs(n, 4, rnd)
+	for _, threads := range []int{1, 2, 4, 8} {
+		ops := newVecOps(threads)
+		b.Run(fmt.Sprintf("Dot/threads=%d", threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ops.dot(v[0], v[1])
+			}
+		})
+		b.Run(fmt.Sprintf("AddScaled/threads=%d", threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ops.addScaled(v[0], 1e-3, v[1])
+			}
+		})
+		b.Run(fmt.Sprintf("CGUpdate/threads=%d", threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ops.cgUpdate(1e-3, v[0], v[1], v[2], v[3])
+			}
+		})
+	}
+}
+
+func BenchmarkCGThreads(b *testing.B) {
+	const n = 10000000
+	if testing.Short() {
+		b.Skip("skipping large system in short mode")
+	}
+	a := diagDominant(n)
+	rhs := make([]float64, n)
+	a.MatVec(rhs, ones(n))
+	for _, threads := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := LinearSolve(a, rhs, &CG{}, Settings{
+					Tolerance:     1e-8,
+					MaxIterations: 100,
+					Threads:       threads,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGMRESThreads(b *testing.B) {
+	const n = 1000000
+	if testing.Short() {
+		b.Skip("skipping large system in short mode")
+	}
+	a := diagDominant(n)
+	rhs := make([]float64, n)
+	a.MatVec(rhs, ones(n))
+	for _, threads := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// One restart cycle.
+				_, err := LinearSolve(a, rhs, &GMRES{Restart: 50}, Settings{
+					Tolerance:     1e-15,
+					MaxIterations: 50,
+					Threads:       threads,
+				})
+				if err == nil {
+					b.Fatal("unexpected convergence")
+				}
+			}
+		})
+	}
+}
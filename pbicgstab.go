@@ -0,0 +1,373 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// defaultReplace is the default number of iterations between residual
+// replacements in PipelinedBiCGSTAB.
+const defaultReplace = 50
+
+// PipelinedBiCGSTAB implements the pipelined variant of the BiConjugate
+// Gradient STABilized method with right preconditioning by Cools and
+// Vanroose for solving the system of linear equations
+//  Ax = b,
+// where A is a non-symmetric matrix.
+//
+// In exact arithmetic PipelinedBiCGSTAB computes the same iterates as
+// BiCGSTAB. Its recurrences are rearranged so that the inner products of
+// each iteration are computed in two groups and each group is followed by
+// a preconditioner solve and a matrix-vector product that do not depend on
+// its result. A caller that distributes the vectors can thus start the
+// global reduction of a group and overlap it with the MatVec that follows.
+// BiCGSTAB, on the other hand, needs four separate reductions and two
+// CheckResidualNorm operations in each iteration while PipelinedBiCGSTAB
+// commands only one. The residual norm of the half-step is checked only
+// if it cannot be continued, for example on A = 2*I where the half-step
+// solves the system exactly.
+//
+// The price is a larger number of vectors and more vector updates per
+// iteration, and an accumulation of rounding errors in the recursively
+// updated residual, which drifts away from the true residual b - A*x. The
+// drift is mitigated by periodically replacing the residual and the
+// auxiliary vectors by explicitly computed ones, and convergence detected
+// with the updated residual is confirmed with the true residual.
+//
+// Reference:
+//  Cools, S., Vanroose, W.: The communication-hiding pipelined BiCGStab
+//  method for the parallel solution of large unsymmetric linear systems.
+//  Parallel Computing 65, 1-20 (2017)
+//
+// PipelinedBiCGSTAB needs MatVec, PSolve and ComputeResidual matrix
+// operations.
+type PipelinedBiCGSTAB struct {
+	// Replace is the number of iterations
+	// between residual replacements. If it
+	// is zero, the residual is replaced
+	// every 50 iterations. If it is
+	// negative, the residual is never
+	// replaced.
+	Replace int
+
+	first    bool
+	resume   int
+	iter     int
+	replaced bool // The residual has been computed explicitly.
+
+	rho, rhoNext float64
+	alpha, omega float64
+	qy, yy       float64 // Inner products of the first group.
+	rw, rs, rz   float64 // Inner products of the second group.
+	rnorm        float64
+
+	rt   []float64
+	rhat []float64
+	w    []float64
+	what []float64
+	t    []float64
+	phat []float64
+	s    []float64
+	shat []float64
+	z    []float64
+	zhat []float64
+	v    []float64
+	q    []float64
+	qhat []float64
+	y    []float64
+}
+
+// Init implements the Method interface.
+func (b *PipelinedBiCGSTAB) Init(dim int) {
+	if dim <= 0 {
+		panic("PipelinedBiCGSTAB: dimension not positive")
+	}
+
+	b.rt = reuse(b.rt, dim)
+	b.rhat = reuse(b.rhat, dim)
+	b.w = reuse(b.w, dim)
+	b.what = reuse(b.what, dim)
+	b.t = reuse(b.t, dim)
+	b.phat = reuse(b.phat, dim)
+	b.s = reuse(b.s, dim)
+	b.shat = reuse(b.shat, dim)
+	b.z = reuse(b.z, dim)
+	b.zhat = reuse(b.zhat, dim)
+	b.v = reuse(b.v, dim)
+	b.q = reuse(b.q, dim)
+	b.qhat = reuse(b.qhat, dim)
+	b.y = reuse(b.y, dim)
+	b.first = true
+	b.iter = 0
+	b.resume = 1
+}
+
+// Iterate implements the Method interface.
+//
+// The vectors with a hat are the preconditioned counterparts of the
+// vectors without it, for example r^ = M^{-1} r, and the vectors without a
+// hat satisfy w = A r^, s = A p^, z = A s^, t = A w^ and v = A z^.
+func (b *PipelinedBiCGSTAB) Iterate(ctx *Context) (Operation, error) {
+	switch b.resume {
+	case 1:
+		ctx.vec.copy(b.rt, ctx.Residual)
+		ctx.Src = ctx.Residual
+		ctx.Dst = b.rhat
+		b.resume = 2
+		return PSolve, nil
+		// Solve M r^_0 = r_0.
+	case 2:
+		ctx.Src = b.rhat
+		ctx.Dst = b.w
+		b.resume = 3
+		return MatVec, nil
+		// Compute Ar^_0 -> w_0.
+	case 3:
+		ctx.Src = b.w
+		ctx.Dst = b.what
+		b.resume = 4
+		return PSolve, nil
+		// Solve M w^_0 = w_0.
+	case 4:
+		ctx.Src = b.what
+		ctx.Dst = b.t
+		b.resume = 5
+		return MatVec, nil
+		// Compute Aw^_0 -> t_0.
+	case 5:
+		if b.first {
+			b.rho = ctx.vec.dot(b.rt, ctx.Residual)
+			if math.Abs(b.rho) < rhoBreakdownTol || math.IsNaN(b.rho) || math.IsInf(b.rho, 0) {
+				b.resume = 0 // Calling Iterate again without Init will panic.
+				return NoOperation, &BreakdownError{"PipelinedBiCGSTAB", "rho"}
+			}
+			b.alpha = b.rho / ctx.vec.dot(b.rt, b.w)
+			ctx.vec.copy(b.phat, b.rhat)
+			ctx.vec.copy(b.s, b.w)
+			ctx.vec.copy(b.shat, b.what)
+			ctx.vec.copy(b.z, b.t)
+		} else {
+			beta := (b.rhoNext / b.rho) * (b.alpha / b.omega)
+			b.alpha = b.rhoNext / (b.rw + beta*b.rs - beta*b.omega*b.rz)
+			b.rho = b.rhoNext
+			recur(ctx.vec, b.phat, b.rhat, beta, b.omega, b.shat) // p^_i = r^_i + β(p^_{i-1} - ω s^_{i-1})
+			recur(ctx.vec, b.s, b.w, beta, b.omega, b.z)          // s_i = w_i + β(s_{i-1} - ω z_{i-1})
+			recur(ctx.vec, b.shat, b.what, beta, b.omega, b.zhat) // s^_i = w^_i + β(s^_{i-1} - ω z^_{i-1})
+			recur(ctx.vec, b.z, b.t, beta, b.omega, b.v)          // z_i = t_i + β(z_{i-1} - ω v_{i-1})
+		}
+		ctx.vec.addScaledTo(b.q, ctx.Residual, -b.alpha, b.s) // q_i = r_i - α s_i
+		ctx.vec.addScaledTo(b.qhat, b.rhat, -b.alpha, b.shat) // q^_i = r^_i - α s^_i
+		ctx.vec.addScaledTo(b.y, b.w, -b.alpha, b.z)          // y_i = w_i - α z_i
+		// First group of inner products, its
+		// reduction can overlap with the
+		// following PSolve and MatVec.
+		b.qy = ctx.vec.dot(b.q, b.y)
+		b.yy = ctx.vec.dot(b.y, b.y)
+		ctx.Src = b.z
+		ctx.Dst = b.zhat
+		b.resume = 6
+		return PSolve, nil
+		// Solve M z^_i = z_i.
+	case 6:
+		ctx.Src = b.zhat
+		ctx.Dst = b.v
+		b.resume = 7
+		return MatVec, nil
+		// Compute Az^_i -> v_i.
+	case 7:
+		b.omega = b.qy / b.yy
+		if b.yy == 0 || math.IsNaN(b.omega) || math.IsInf(b.omega, 0) {
+			// y_i = A q^_i vanishes, so the half-step
+			// has solved the system or the method
+			// breaks down. Check the residual norm of
+			// the half-step like BiCGSTAB.
+			qnorm := ctx.vec.norm(b.q)
+			if math.IsNaN(qnorm) || math.IsInf(qnorm, 0) {
+				b.resume = 0 // Calling Iterate again without Init will panic.
+				return NoOperation, &BreakdownError{"PipelinedBiCGSTAB", "omega"}
+			}
+			ctx.ResidualNorm = qnorm
+			ctx.Src = nil
+			ctx.Dst = nil
+			ctx.Converged = false
+			b.resume = 21
+			return CheckResidualNorm, nil
+		}
+		return b.update(ctx)
+	case 8:
+		ctx.Src = b.what
+		ctx.Dst = b.t
+		b.resume = 9
+		return MatVec, nil
+		// Compute Aw^_{i+1} -> t_{i+1}.
+	case 9:
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.ResidualNorm = b.rnorm
+		ctx.Converged = false
+		b.resume = 10
+		return CheckResidualNorm, nil
+	case 10:
+		if ctx.Converged && b.replaced {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
+			return EndIteration, nil
+		}
+		if ctx.Converged {
+			// The updated residual can be much
+			// smaller than the true residual,
+			// convergence is confirmed with the
+			// latter.
+			ctx.Src = nil
+			ctx.Dst = nil
+			b.replaced = true
+			b.resume = 19
+			return ComputeResidual, nil
+			// Compute b - Ax_{i+1} -> r_{i+1}.
+		}
+		if math.Abs(b.omega) < omegaBreakdownTol || math.IsNaN(b.omega) || math.IsInf(b.omega, 0) {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"PipelinedBiCGSTAB", "omega"}
+		}
+		if math.Abs(b.rhoNext) < rhoBreakdownTol || math.IsNaN(b.rhoNext) || math.IsInf(b.rhoNext, 0) {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"PipelinedBiCGSTAB", "rho"}
+		}
+		b.first = false
+		b.resume = 5
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+
+	// Residual replacement. The residual
+	// has been computed explicitly, the
+	// vectors that enter the recurrences of
+	// the next iteration are recomputed from
+	// it and from p^_i.
+	case 11:
+		ctx.Src = ctx.Residual
+		ctx.Dst = b.rhat
+		b.resume = 12
+		return PSolve, nil
+		// Solve M r^_{i+1} = r_{i+1}.
+	case 12:
+		ctx.Src = b.rhat
+		ctx.Dst = b.w
+		b.resume = 13
+		return MatVec, nil
+		// Compute Ar^_{i+1} -> w_{i+1}.
+	case 13:
+		ctx.Src = b.phat
+		ctx.Dst = b.s
+		b.resume = 14
+		return MatVec, nil
+		// Compute Ap^_i -> s_i.
+	case 14:
+		ctx.Src = b.s
+		ctx.Dst = b.shat
+		b.resume = 15
+		return PSolve, nil
+		// Solve M s^_i = s_i.
+	case 15:
+		ctx.Src = b.shat
+		ctx.Dst = b.z
+		b.resume = 16
+		return MatVec, nil
+		// Compute As^_i -> z_i.
+	case 16:
+		ctx.Src = b.z
+		ctx.Dst = b.zhat
+		b.resume = 17
+		return PSolve, nil
+		// Solve M z^_i = z_i.
+	case 17:
+		ctx.Src = b.zhat
+		ctx.Dst = b.v
+		b.resume = 18
+		return MatVec, nil
+		// Compute Az^_i -> v_i.
+	case 18:
+		return b.reduce(ctx)
+	case 19:
+		ctx.ResidualNorm = ctx.vec.norm(ctx.Residual)
+		ctx.Converged = false
+		b.resume = 20
+		return CheckResidualNorm, nil
+	case 20:
+		if ctx.Converged {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = true
+			return EndIteration, nil
+		}
+		// Continue with the replaced residual.
+		ctx.Src = ctx.Residual
+		ctx.Dst = b.rhat
+		b.resume = 12
+		return PSolve, nil
+		// Solve M r^_{i+1} = r_{i+1}.
+
+	// The half-step with a vanishing y_i.
+	case 21:
+		if !ctx.Converged {
+			b.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"PipelinedBiCGSTAB", "omega"}
+		}
+		ctx.vec.addScaled(ctx.X, b.alpha, b.phat)
+		ctx.vec.copy(ctx.Residual, b.q)
+		b.resume = 0 // Calling Iterate again without Init will panic.
+		ctx.ResidualCurrent = true
+		return EndIteration, nil
+
+	default:
+		panic("PipelinedBiCGSTAB: Init not called")
+	}
+}
+
+// update completes the iteration with the step length ω and commands the
+// residual replacement or the PSolve of the second reduction.
+func (b *PipelinedBiCGSTAB) update(ctx *Context) (Operation, error) {
+	ctx.vec.addScaled(ctx.X, b.alpha, b.phat)
+	ctx.vec.addScaled(ctx.X, b.omega, b.qhat)
+	ctx.vec.addScaledTo(ctx.Residual, b.q, -b.omega, b.y) // r_{i+1} = q_i - ω y_i
+	ctx.vec.addScaledTo(b.rhat, b.qhat, -b.omega, b.what) // r^_{i+1} = q^_i - ω(w^_i - α z^_i)
+	ctx.vec.addScaled(b.rhat, b.alpha*b.omega, b.zhat)
+	ctx.vec.addScaledTo(b.w, b.y, -b.omega, b.t) // w_{i+1} = y_i - ω(t_i - α v_i)
+	ctx.vec.addScaled(b.w, b.alpha*b.omega, b.v)
+	b.iter++
+	replace := b.Replace
+	if replace == 0 {
+		replace = defaultReplace
+	}
+	b.replaced = replace > 0 && b.iter%replace == 0
+	if b.replaced {
+		ctx.Src = nil
+		ctx.Dst = nil
+		b.resume = 11
+		return ComputeResidual, nil
+		// Compute b - Ax_{i+1} -> r_{i+1}.
+	}
+	return b.reduce(ctx)
+}
+
+// reduce computes the second group of inner products of the iteration,
+// whose reduction can overlap with the following PSolve and MatVec, and
+// commands the PSolve.
+func (b *PipelinedBiCGSTAB) reduce(ctx *Context) (Operation, error) {
+	b.rhoNext = ctx.vec.dot(b.rt, ctx.Residual)
+	b.rw = ctx.vec.dot(b.rt, b.w)
+	b.rs = ctx.vec.dot(b.rt, b.s)
+	b.rz = ctx.vec.dot(b.rt, b.z)
+	b.rnorm = ctx.vec.norm(ctx.Residual)
+	ctx.Src = b.w
+	ctx.Dst = b.what
+	b.resume = 8
+	return PSolve, nil
+	// Solve M w^_{i+1} = w_{i+1}.
+}
+
+// recur computes dst = x + beta*(dst - omega*y).
+func recur(vec *vecOps, dst, x []float64, beta, omega float64, y []float64) {
+	vec.addScaled(dst, -omega, y)
+	vec.scale(beta, dst)
+	vec.add(dst, x)
+}
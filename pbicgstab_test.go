@@ -0,0 +1,177 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+// TestPipelinedBiCGSTAB checks that PipelinedBiCGSTAB and BiCGSTAB
+// compute the same solutions. The tolerance is larger than in TestBiCGSTAB
+// because the attainable accuracy of the pipelined variant is lower.
+func TestPipelinedBiCGSTAB(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	spd := func(n int) *problem.Problem {
+		p := problem.RandomSPD(n, rnd)
+		p.Tolerance = 1e-8
+		return p
+	}
+	for _, p := range []*problem.Problem{
+		spd(1),
+		spd(2),
+		spd(5),
+		spd(50),
+		spd(500),
+		market("nos1", 1e-4),
+		market("nos4", 1e-7),
+		market("nos5", 1e-5),
+		market("bcsstm20", 1e-4),
+		market("bcsstm22", 1e-6),
+		market("e05r0000", 1e-6),
+		market("e05r0100", 1e-5),
+		market("gre__115", 1e-8),
+		market("gre__185", 1e-7),
+		market("arc130", 1e-4),
+	} {
+		settings := iterative.Settings{
+			MaxIterations: 10 * p.MaxIterations,
+			Tolerance:     1e-10,
+		}
+		want, err := p.Solve(&iterative.BiCGSTAB{}, settings)
+		if err != nil {
+			t.Errorf("BiCGSTAB: %v", err)
+			continue
+		}
+		got, err := p.Solve(&iterative.PipelinedBiCGSTAB{}, settings)
+		if err != nil {
+			t.Errorf("PipelinedBiCGSTAB: %v", err)
+			continue
+		}
+		var d float64
+		for i, v := range got.X {
+			d = math.Max(d, math.Abs(v-want.X[i]))
+		}
+		if d > p.Tolerance {
+			t.Errorf("%v: solutions of BiCGSTAB and PipelinedBiCGSTAB differ by %v", p.Name, d)
+		}
+	}
+}
+
+func TestPipelinedBiCGSTABReplace(t *testing.T) {
+	p := problem.ConvectionDiffusion2D(32, 32, 20, 10)
+	for _, replace := range []int{-1, 0, 1, 7} {
+		_, err := p.Solve(&iterative.PipelinedBiCGSTAB{Replace: replace}, iterative.Settings{
+			Tolerance: 1e-10,
+		})
+		if err != nil {
+			t.Errorf("Replace=%d: %v", replace, err)
+		}
+	}
+}
+
+// opCounter counts the operations commanded by a Method.
+type opCounter struct {
+	iterative.Method
+	ops map[iterative.Operation]int
+}
+
+func (c *opCounter) Iterate(ctx *iterative.Context) (iterative.Operation, error) {
+	op, err := c.Method.Iterate(ctx)
+	c.ops[op]++
+	return op, err
+}
+
+// TestPipelinedBiCGSTABOperations checks that PipelinedBiCGSTAB commands
+// one CheckResidualNorm per iteration, half as many as BiCGSTAB, for the
+// same number of MatVec and PSolve operations.
+func TestPipelinedBiCGSTABOperations(t *testing.T) {
+	p := problem.ConvectionDiffusion2D(16, 16, 5, 5)
+	const iters = 20
+	for _, tc := range []struct {
+		method     iterative.Method
+		checks     int
+		matVec     int
+		psolve     int
+		computeRes int
+	}{
+		{&iterative.BiCGSTAB{}, 2 * iters, 2 * iters, 2 * iters, 0},
+		// Two more MatVec and PSolve operations
+		// start the recurrences.
+		{&iterative.PipelinedBiCGSTAB{Replace: -1}, iters, 2*iters + 2, 2*iters + 2, 0},
+		// Each replacement costs a ComputeResidual,
+		// four MatVecs and three PSolves.
+		{&iterative.PipelinedBiCGSTAB{Replace: 5}, iters, 2*iters + 2 + 4*4, 2*iters + 2 + 3*4, 4},
+	} {
+		c := &opCounter{Method: tc.method, ops: make(map[iterative.Operation]int)}
+		_, err := iterative.LinearSolve(p.A, p.B, c, iterative.Settings{
+			Tolerance:     1e-15,
+			MaxIterations: iters,
+		})
+		if err == nil {
+			t.Fatalf("%T: unexpected convergence", tc.method)
+		}
+		if c.ops[iterative.EndIteration] != iters {
+			t.Errorf("%T: unexpected number of iterations: want %d, got %d", tc.method, iters, c.ops[iterative.EndIteration])
+		}
+		if c.ops[iterative.CheckResidualNorm] != tc.checks {
+			t.Errorf("%T: unexpected number of CheckResidualNorm: want %d, got %d", tc.method, tc.checks, c.ops[iterative.CheckResidualNorm])
+		}
+		if c.ops[iterative.MatVec] != tc.matVec {
+			t.Errorf("%T: unexpected number of MatVec: want %d, got %d", tc.method, tc.matVec, c.ops[iterative.MatVec])
+		}
+		if c.ops[iterative.PSolve] != tc.psolve {
+			t.Errorf("%T: unexpected number of PSolve: want %d, got %d", tc.method, tc.psolve, c.ops[iterative.PSolve])
+		}
+		if c.ops[iterative.ComputeResidual] != tc.computeRes {
+			t.Errorf("%T: unexpected number of ComputeResidual: want %d, got %d", tc.method, tc.computeRes, c.ops[iterative.ComputeResidual])
+		}
+	}
+}
+
+// TestPipelinedBiCGSTABHalfStep checks that PipelinedBiCGSTAB converges
+// when the first half-step of an iteration solves the system exactly and
+// ω cannot be computed.
+func TestPipelinedBiCGSTABHalfStep(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		a    func(dst, x []float64)
+		b    []float64
+		want []float64
+	}{
+		{
+			name: "2*I",
+			a:    func(dst, x []float64) { floats.ScaleTo(dst, 2, x) },
+			b:    []float64{2, 4, 6, 8, 10, 12, 14, 16, 18, 20},
+			want: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{
+			name: "n=1",
+			a:    func(dst, x []float64) { dst[0] = 3 * x[0] },
+			b:    []float64{6},
+			want: []float64{2},
+		},
+	} {
+		for _, debug := range []bool{false, true} {
+			res, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: test.a}, test.b, &iterative.PipelinedBiCGSTAB{}, iterative.Settings{
+				Tolerance: 1e-10,
+				Debug:     debug,
+			})
+			if err != nil {
+				t.Errorf("%s,debug=%t: unexpected error: %v", test.name, debug, err)
+				continue
+			}
+			if !floats.EqualApprox(res.X, test.want, 1e-14) {
+				t.Errorf("%s,debug=%t: unexpected solution: want %v, got %v", test.name, debug, test.want, res.X)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+// PipelinedCG implements the variant of the Conjugate Gradient method with
+// preconditioning by Chronopoulos and Gear for solving the system of linear
+// equations
+//  Ax = b,
+// where A is a symmetric positive definite matrix.
+//
+// In exact arithmetic PipelinedCG computes the same iterates as CG. CG
+// computes the inner product p·Ap after the MatVec and r·M^{-1}r after the
+// PSolve of each iteration, so it needs two global reductions that cannot
+// be overlapped with anything. PipelinedCG updates Ap by a recurrence,
+// issues the PSolve and the MatVec of the next iteration right after
+// updating the residual and computes all inner products of an iteration,
+// r·u and u·Au with u = M^{-1}r, and the residual norm together after the
+// MatVec. A caller that distributes the vectors thus needs only one
+// reduction per iteration. The price is one more vector and vector update
+// than CG and a somewhat lower attainable accuracy.
+//
+// If PipelinedCG encounters a search direction p with p·Ap <= 0, the matrix
+// is not positive definite and Iterate returns a *NotPositiveDefiniteError.
+//
+// References:
+//  - Chronopoulos, A.T., Gear, C.W.: s-step iterative methods for symmetric
+//    linear systems. J. Comput. Appl. Math. 25(2), 153-168 (1989)
+//
+// PipelinedCG needs MatVec and PSolve matrix operations.
+type PipelinedCG struct {
+	first  bool
+	resume int
+
+	alpha          float64
+	gamma, gammaPr float64 // γ_i = r_i·u_i and γ_{i-1}.
+	delta          float64 // δ_i = u_i·Au_i.
+
+	u []float64 // M^{-1} r.
+	w []float64 // Au.
+	p []float64
+	s []float64 // Ap.
+}
+
+// Init implements the Method interface.
+func (cg *PipelinedCG) Init(dim int) {
+	if dim <= 0 {
+		panic("PipelinedCG: dimension not positive")
+	}
+
+	cg.u = reuse(cg.u, dim)
+	cg.w = reuse(cg.w, dim)
+	cg.p = reuse(cg.p, dim)
+	cg.s = reuse(cg.s, dim)
+	cg.first = true
+	cg.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (cg *PipelinedCG) Iterate(ctx *Context) (Operation, error) {
+	switch cg.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = cg.u
+		cg.resume = 2
+		return PSolve, nil
+		// Solve M u_0 = r_0
+	case 2:
+		ctx.Src = cg.u
+		ctx.Dst = cg.w
+		cg.resume = 3
+		return MatVec, nil
+		// Compute Au_0 -> w_0
+	case 3:
+		cg.gamma = ctx.vec.dot(ctx.Residual, cg.u)
+		cg.delta = ctx.vec.dot(cg.w, cg.u)
+		fallthrough
+	case 4:
+		// Start of an iteration.
+		var pap float64 // p_i·Ap_i
+		if cg.first {
+			pap = cg.delta
+			ctx.vec.copy(cg.p, cg.u)
+			ctx.vec.copy(cg.s, cg.w)
+		} else {
+			beta := cg.gamma / cg.gammaPr // β_i = γ_i / γ_{i-1}
+			pap = cg.delta - beta*cg.gamma/cg.alpha
+			// p_i = u_i + β p_{i-1}
+			ctx.vec.scale(beta, cg.p)
+			ctx.vec.add(cg.p, cg.u)
+			// s_i = w_i + β s_{i-1}
+			ctx.vec.scale(beta, cg.s)
+			ctx.vec.add(cg.s, cg.w)
+		}
+		if pap <= 0 {
+			cg.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "PipelinedCG"}
+		}
+		cg.alpha = cg.gamma / pap // α_i = γ_i / (p_i·Ap_i)
+		// x_{i+1} = x_i + α p_i
+		ctx.vec.addScaled(ctx.X, cg.alpha, cg.p)
+		// r_{i+1} = r_i - α s_i
+		ctx.vec.addScaled(ctx.Residual, -cg.alpha, cg.s)
+		ctx.Src = ctx.Residual
+		ctx.Dst = cg.u
+		cg.resume = 5
+		return PSolve, nil
+		// Solve M u_{i+1} = r_{i+1}
+	case 5:
+		ctx.Src = cg.u
+		ctx.Dst = cg.w
+		cg.resume = 6
+		return MatVec, nil
+		// Compute Au_{i+1} -> w_{i+1}
+	case 6:
+		// The only reduction of the iteration.
+		cg.gammaPr = cg.gamma
+		cg.gamma = ctx.vec.dot(ctx.Residual, cg.u)
+		cg.delta = ctx.vec.dot(cg.w, cg.u)
+		ctx.ResidualNorm = ctx.vec.norm(ctx.Residual)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		cg.resume = 7
+		return CheckResidualNorm, nil
+	case 7:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			cg.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		cg.first = false
+		cg.resume = 4
+		return EndIteration, nil
+
+	default:
+		panic("PipelinedCG: Init not called")
+	}
+}
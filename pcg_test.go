@@ -0,0 +1,113 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+// TestPipelinedCG checks that PipelinedCG and CG compute the same
+// solutions in the same number of iterations.
+func TestPipelinedCG(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		p *problem.Problem
+		// On ill-conditioned matrices the
+		// rounding errors make the number of
+		// iterations of the two methods differ.
+		sameIters bool
+	}{
+		{problem.RandomSPD(1, rnd), true},
+		{problem.RandomSPD(2, rnd), true},
+		{problem.RandomSPD(3, rnd), true},
+		{problem.RandomSPD(4, rnd), true},
+		{problem.RandomSPD(5, rnd), true},
+		{problem.RandomSPD(10, rnd), true},
+		{problem.RandomSPD(20, rnd), true},
+		{problem.RandomSPD(50, rnd), true},
+		{problem.RandomSPD(100, rnd), true},
+		{problem.RandomSPD(200, rnd), true},
+		{problem.RandomSPD(500, rnd), true},
+		{market("nos1", 1e-8), false},
+		{market("nos4", 1e-11), true},
+		{market("nos5", 1e-9), false},
+		{market("bcsstm20", 1e-7), false},
+		{market("bcsstm22", 1e-11), true},
+	} {
+		p := test.p
+		m := iterative.DiagonalInverse(diagonal(p.A, p.Dim))
+		for _, psolve := range []func(dst, rhs []float64) error{nil, m.Apply} {
+			settings := iterative.Settings{
+				Tolerance: 1e-12,
+				PSolve:    psolve,
+			}
+			want, err := p.Solve(&iterative.CG{}, settings)
+			if err != nil {
+				t.Errorf("CG: %v", err)
+				continue
+			}
+			got, err := p.Solve(&iterative.PipelinedCG{}, settings)
+			if err != nil {
+				t.Errorf("PipelinedCG: %v", err)
+				continue
+			}
+			var d float64
+			for i, v := range got.X {
+				d = math.Max(d, math.Abs(v-want.X[i]))
+			}
+			if d > p.Tolerance {
+				t.Errorf("%v: solutions of CG and PipelinedCG differ by %v", p.Name, d)
+			}
+			if test.sameIters && got.Stats.Iterations != want.Stats.Iterations {
+				t.Errorf("%v: PipelinedCG needs %d iterations, CG %d", p.Name, got.Stats.Iterations, want.Stats.Iterations)
+			}
+		}
+	}
+}
+
+// opRecorder records the operations commanded by a Method.
+type opRecorder struct {
+	iterative.Method
+	ops []iterative.Operation
+}
+
+func (r *opRecorder) Iterate(ctx *iterative.Context) (iterative.Operation, error) {
+	op, err := r.Method.Iterate(ctx)
+	r.ops = append(r.ops, op)
+	return op, err
+}
+
+// TestPipelinedCGOperations checks that after the PSolve and MatVec that
+// start the recurrences each iteration of PipelinedCG commands exactly one
+// PSolve, one MatVec and one CheckResidualNorm, in this order.
+func TestPipelinedCGOperations(t *testing.T) {
+	p := problem.Poisson2D(16, 16)
+	const iters = 20
+	r := &opRecorder{Method: &iterative.PipelinedCG{}}
+	_, err := iterative.LinearSolve(p.A, p.B, r, iterative.Settings{
+		Tolerance:     1e-15,
+		MaxIterations: iters,
+	})
+	if err == nil {
+		t.Fatal("unexpected convergence")
+	}
+	want := []iterative.Operation{iterative.PSolve, iterative.MatVec}
+	for i := 0; i < iters; i++ {
+		want = append(want, iterative.PSolve, iterative.MatVec, iterative.CheckResidualNorm, iterative.EndIteration)
+	}
+	if len(r.ops) != len(want) {
+		t.Fatalf("unexpected number of operations: want %d, got %d", len(want), len(r.ops))
+	}
+	for i, op := range r.ops {
+		if op != want[i] {
+			t.Errorf("unexpected operation %d: want %v, got %v", i, want[i], op)
+		}
+	}
+}
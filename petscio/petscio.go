@@ -0,0 +1,321 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package petscio reads and writes sparse matrices and vectors in the binary
+// format of the PETSc binary viewer.
+//
+// The format is big-endian. A matrix is stored as its class id, the number
+// of rows and columns, the number of nonzero elements, the number of
+// nonzero elements in each row, the column indices and the values. A vector
+// is stored as its class id, its length and its elements. The integers are
+// 32-bit, or 64-bit if PETSc was configured with 64-bit indices, the values
+// are 64-bit floating-point numbers.
+package petscio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// Class ids of PETSc objects.
+const (
+	matClassID = 1211216
+	vecClassID = 1211214
+)
+
+// IntSize is the size in bytes of the integers stored in a file.
+type IntSize int
+
+const (
+	// Int32 is the size of integers in the
+	// default PETSc build.
+	Int32 IntSize = 4
+	// Int64 is the size of integers in PETSc
+	// configured with 64-bit indices.
+	Int64 IntSize = 8
+)
+
+var (
+	errDense     = errors.New("petscio: dense matrices not supported")
+	errTruncated = errors.New("petscio: truncated file")
+)
+
+// ReadMat reads a sparse matrix written by the PETSc binary viewer from r.
+// The size of the integers in the file is detected from the class id. Text
+// lines starting with '#' that precede the data, such as the MATLAB loading
+// instructions that PETSc writes when the info file is merged into the
+// binary file, are skipped.
+func ReadMat(r io.Reader) (*sparse.CSR, error) {
+	d, err := newDecoder(r, matClassID)
+	if err != nil {
+		return nil, err
+	}
+	rows := d.readInt()
+	cols := d.readInt()
+	nnz := d.readInt()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if nnz == -1 {
+		return nil, errDense
+	}
+	if rows < 0 || cols < 0 || nnz < 0 {
+		return nil, fmt.Errorf("petscio: bad matrix header %d×%d with %d nonzeros", rows, cols, nnz)
+	}
+
+	ri := make([]int, 0, nnz)
+	for i := 0; i < rows; i++ {
+		n := d.readInt()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if n < 0 || n > cols || n > nnz-len(ri) {
+			return nil, fmt.Errorf("petscio: bad number of nonzeros %d in row %d", n, i)
+		}
+		for ; n > 0; n-- {
+			ri = append(ri, i)
+		}
+	}
+	if len(ri) != nnz {
+		return nil, fmt.Errorf("petscio: row lengths sum to %d, want %d", len(ri), nnz)
+	}
+	ci := make([]int, nnz)
+	for k := range ci {
+		j := d.readInt()
+		if d.err != nil {
+			return nil, d.err
+		}
+		if j < 0 || cols <= j {
+			return nil, fmt.Errorf("petscio: column index %d out of range in row %d", j, ri[k])
+		}
+		ci[k] = j
+	}
+	vals := make([]float64, nnz)
+	for k := range vals {
+		vals[k] = d.readFloat()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return sparse.NewCSRInPlace(rows, cols, ri, ci, vals), nil
+}
+
+// ReadVec reads a vector written by the PETSc binary viewer from r. The
+// size of the integers and leading text lines are handled as in ReadMat.
+func ReadVec(r io.Reader) ([]float64, error) {
+	d, err := newDecoder(r, vecClassID)
+	if err != nil {
+		return nil, err
+	}
+	n := d.readInt()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("petscio: bad vector length %d", n)
+	}
+	x := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		v := d.readFloat()
+		if d.err != nil {
+			return nil, d.err
+		}
+		x = append(x, v)
+	}
+	return x, nil
+}
+
+// WriteMat writes m to w in the PETSc binary format with integers of the
+// given size.
+func WriteMat(w io.Writer, m *sparse.CSR, size IntSize) error {
+	r, c := m.Dims()
+	nnz := m.NNZ()
+	e, err := newEncoder(w, size)
+	if err != nil {
+		return err
+	}
+	if size == Int32 && (r > math.MaxInt32 || c > math.MaxInt32 || nnz > math.MaxInt32) {
+		return errors.New("petscio: matrix too large for 32-bit indices")
+	}
+	e.writeInt(matClassID)
+	e.writeInt(r)
+	e.writeInt(c)
+	e.writeInt(nnz)
+	for i := 0; i < r; i++ {
+		ind, _ := m.RowView(i)
+		e.writeInt(len(ind))
+	}
+	for i := 0; i < r; i++ {
+		ind, _ := m.RowView(i)
+		for _, j := range ind {
+			e.writeInt(j)
+		}
+	}
+	for i := 0; i < r; i++ {
+		_, data := m.RowView(i)
+		for _, v := range data {
+			e.writeFloat(v)
+		}
+	}
+	return e.flush()
+}
+
+// WriteVec writes x to w in the PETSc binary format with integers of the
+// given size.
+func WriteVec(w io.Writer, x []float64, size IntSize) error {
+	e, err := newEncoder(w, size)
+	if err != nil {
+		return err
+	}
+	if size == Int32 && len(x) > math.MaxInt32 {
+		return errors.New("petscio: vector too long for 32-bit indices")
+	}
+	e.writeInt(vecClassID)
+	e.writeInt(len(x))
+	for _, v := range x {
+		e.writeFloat(v)
+	}
+	return e.flush()
+}
+
+// decoder reads big-endian integers and floating-point numbers. The first
+// error is retained in err and subsequent reads return zero.
+type decoder struct {
+	r    *bufio.Reader
+	size IntSize
+	buf  [8]byte
+	err  error
+}
+
+// newDecoder skips leading text lines, checks that the class id in r is
+// equal to id and detects the size of the integers.
+func newDecoder(r io.Reader, id int) (*decoder, error) {
+	d := &decoder{r: bufio.NewReader(r)}
+	for {
+		b, err := d.r.Peek(1)
+		if err != nil {
+			if err == io.EOF {
+				return nil, errTruncated
+			}
+			return nil, err
+		}
+		if b[0] != '#' {
+			break
+		}
+		if _, err := d.r.ReadSlice('\n'); err != nil && err != bufio.ErrBufferFull {
+			return nil, errTruncated
+		}
+	}
+	b, err := d.r.Peek(8)
+	if len(b) < 4 {
+		if err == io.EOF {
+			err = errTruncated
+		}
+		return nil, err
+	}
+	switch {
+	case binary.BigEndian.Uint32(b) == uint32(id):
+		d.size = Int32
+	case len(b) == 8 && binary.BigEndian.Uint64(b) == uint64(id):
+		d.size = Int64
+	default:
+		got := uint64(binary.BigEndian.Uint32(b))
+		if got == 0 && len(b) == 8 {
+			got = binary.BigEndian.Uint64(b)
+		}
+		switch got {
+		case matClassID:
+			return nil, errors.New("petscio: file holds a matrix, not a vector")
+		case vecClassID:
+			return nil, errors.New("petscio: file holds a vector, not a matrix")
+		}
+		return nil, fmt.Errorf("petscio: unexpected class id %d", got)
+	}
+	d.r.Discard(int(d.size))
+	return d, nil
+}
+
+func (d *decoder) readInt() int {
+	if d.err != nil {
+		return 0
+	}
+	b := d.buf[:d.size]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.setErr(err)
+		return 0
+	}
+	if d.size == Int32 {
+		return int(int32(binary.BigEndian.Uint32(b)))
+	}
+	return int(int64(binary.BigEndian.Uint64(b)))
+}
+
+func (d *decoder) readFloat() float64 {
+	if d.err != nil {
+		return 0
+	}
+	b := d.buf[:]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.setErr(err)
+		return 0
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+func (d *decoder) setErr(err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = errTruncated
+	}
+	d.err = err
+}
+
+// encoder writes big-endian integers and floating-point numbers. The first
+// error is retained in err and subsequent writes are ignored.
+type encoder struct {
+	w    *bufio.Writer
+	size IntSize
+	buf  [8]byte
+	err  error
+}
+
+func newEncoder(w io.Writer, size IntSize) (*encoder, error) {
+	if size != Int32 && size != Int64 {
+		return nil, fmt.Errorf("petscio: bad integer size %d", size)
+	}
+	return &encoder{w: bufio.NewWriter(w), size: size}, nil
+}
+
+func (e *encoder) writeInt(v int) {
+	if e.err != nil {
+		return
+	}
+	b := e.buf[:e.size]
+	if e.size == Int32 {
+		binary.BigEndian.PutUint32(b, uint32(v))
+	} else {
+		binary.BigEndian.PutUint64(b, uint64(v))
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) writeFloat(v float64) {
+	if e.err != nil {
+		return
+	}
+	binary.BigEndian.PutUint64(e.buf[:], math.Float64bits(v))
+	_, e.err = e.w.Write(e.buf[:])
+}
+
+func (e *encoder) flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
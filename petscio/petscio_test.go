@@ -0,0 +1,194 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package petscio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+var (
+	wantMat = [][]float64{
+		{2, 0, 0, -1.5},
+		{0, 0, 0, 0},
+		{0, 1e-300, 7.25, -3},
+	}
+	wantVec = []float64{1, -2.5, 3e10}
+)
+
+func TestReadMat(t *testing.T) {
+	for _, file := range []string{"mat32.petsc", "mat64.petsc", "matlab.petsc"} {
+		f, err := os.Open(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ReadMat(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", file, err)
+			continue
+		}
+		checkMat(t, file, m, wantMat)
+	}
+}
+
+func TestReadVec(t *testing.T) {
+	for _, file := range []string{"vec32.petsc", "vec64.petsc"} {
+		f, err := os.Open(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := ReadVec(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", file, err)
+			continue
+		}
+		checkVec(t, file, x, wantVec)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	a := sparse.NewTriplet(4, 3)
+	a.Append(3, 0, 1)
+	a.Append(0, 2, -2)
+	a.Append(0, 1, 0.5)
+	a.Append(2, 2, 1e100)
+	m := sparse.NewCSRFromTriplet(a)
+	want := [][]float64{
+		{0, 0.5, -2},
+		{0, 0, 0},
+		{0, 0, 1e100},
+		{1, 0, 0},
+	}
+	x := []float64{0.1, 0, -7}
+	for _, size := range []IntSize{Int32, Int64} {
+		var buf bytes.Buffer
+		if err := WriteMat(&buf, m, size); err != nil {
+			t.Fatalf("size %d: unexpected error writing matrix: %v", size, err)
+		}
+		got, err := ReadMat(&buf)
+		if err != nil {
+			t.Errorf("size %d: unexpected error reading matrix: %v", size, err)
+		} else {
+			checkMat(t, "round trip", got, want)
+		}
+
+		buf.Reset()
+		if err := WriteVec(&buf, x, size); err != nil {
+			t.Fatalf("size %d: unexpected error writing vector: %v", size, err)
+		}
+		gotx, err := ReadVec(&buf)
+		if err != nil {
+			t.Errorf("size %d: unexpected error reading vector: %v", size, err)
+		} else {
+			checkVec(t, "round trip", gotx, x)
+		}
+	}
+
+	// The writers must reproduce the fixture files exactly.
+	for _, test := range []struct {
+		file string
+		size IntSize
+	}{
+		{"mat32.petsc", Int32},
+		{"mat64.petsc", Int64},
+	} {
+		want, err := os.ReadFile(filepath.Join("testdata", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ReadMat(bytes.NewReader(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := WriteMat(&buf, m, test.size); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%v: written file differs from fixture", test.file)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	mat, err := os.ReadFile(filepath.Join("testdata", "mat32.petsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vec, err := os.ReadFile(filepath.Join("testdata", "vec64.petsc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badRow := append([]byte(nil), mat...)
+	badRow[19] = 9 // Length of row 0.
+	badCol := append([]byte(nil), mat...)
+	badCol[35] = 4 // Column index of the second element.
+	dense := append([]byte(nil), mat[:16]...)
+	for i := 12; i < 16; i++ {
+		dense[i] = 0xff
+	}
+
+	for _, test := range []struct {
+		name  string
+		input []byte
+		vec   bool
+		want  string
+	}{
+		{"empty", nil, false, "petscio: truncated file"},
+		{"truncated", mat[:len(mat)-1], false, "petscio: truncated file"},
+		{"vector as matrix", vec, false, "petscio: file holds a vector, not a matrix"},
+		{"matrix as vector", mat, true, "petscio: file holds a matrix, not a vector"},
+		{"garbage", []byte("\x01\x02\x03\x04\x05\x06\x07\x08"), false, "petscio: unexpected class id 16909060"},
+		{"dense", dense, false, "petscio: dense matrices not supported"},
+		{"bad row length", badRow, false, "petscio: bad number of nonzeros 9 in row 0"},
+		{"bad column index", badCol, false, "petscio: column index 4 out of range in row 0"},
+		{"truncated vector", vec[:len(vec)-8], true, "petscio: truncated file"},
+	} {
+		var err error
+		if test.vec {
+			_, err = ReadVec(bytes.NewReader(test.input))
+		} else {
+			_, err = ReadMat(bytes.NewReader(test.input))
+		}
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%v: unexpected error, want %q, got %v", test.name, test.want, err)
+		}
+	}
+}
+
+func checkMat(t *testing.T, name string, m *sparse.CSR, want [][]float64) {
+	t.Helper()
+	r, c := m.Dims()
+	if r != len(want) || c != len(want[0]) {
+		t.Errorf("%v: unexpected dimensions %v×%v", name, r, c)
+		return
+	}
+	for i, row := range want {
+		for j, v := range row {
+			if m.At(i, j) != v {
+				t.Errorf("%v: unexpected element at (%v,%v), want %v, got %v", name, i, j, v, m.At(i, j))
+			}
+		}
+	}
+}
+
+func checkVec(t *testing.T, name string, x, want []float64) {
+	t.Helper()
+	if len(x) != len(want) {
+		t.Errorf("%v: unexpected length %v", name, len(x))
+		return
+	}
+	for i, v := range want {
+		if x[i] != v {
+			t.Errorf("%v: unexpected element %v, want %v, got %v", name, i, v, x[i])
+		}
+	}
+}
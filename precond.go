@@ -0,0 +1,220 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/blas/blas64"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// Preconditioner represents a preconditioner M of a linear system. Its
+// methods have the signature of Settings.PSolve and Settings.PSolveTrans,
+// so a Preconditioner p is used in a solve by setting
+//  settings.PSolve = p.Apply
+//  settings.PSolveTrans = p.ApplyTrans
+type Preconditioner interface {
+	// Apply stores into dst the solution
+	// of the system
+	//  M z = rhs.
+	Apply(dst, rhs []float64) error
+
+	// ApplyTrans stores into dst the
+	// solution of the system
+	//  M^T z = rhs.
+	ApplyTrans(dst, rhs []float64) error
+}
+
+// CholeskyPreconditioner is a Preconditioner given by a Cholesky
+// factorization of a symmetric positive definite matrix M.
+type CholeskyPreconditioner struct {
+	chol     *mat.Cholesky
+	dst, rhs mat.VecDense
+}
+
+// NewCholeskyPreconditioner returns a Preconditioner that solves systems
+// with the matrix factorized in chol. The factorization is not copied and
+// must not be modified while the preconditioner is in use.
+func NewCholeskyPreconditioner(chol *mat.Cholesky) *CholeskyPreconditioner {
+	if chol.IsEmpty() {
+		panic("iterative: empty Cholesky factorization")
+	}
+	return &CholeskyPreconditioner{chol: chol}
+}
+
+// Apply implements the Preconditioner interface.
+func (p *CholeskyPreconditioner) Apply(dst, rhs []float64) error {
+	n := p.chol.SymmetricDim()
+	setVec(&p.dst, dst, n)
+	setVec(&p.rhs, rhs, n)
+	return conditionOK(p.chol.SolveVecTo(&p.dst, &p.rhs))
+}
+
+// ApplyTrans implements the Preconditioner interface. Since M is
+// symmetric, ApplyTrans is equivalent to Apply.
+func (p *CholeskyPreconditioner) ApplyTrans(dst, rhs []float64) error {
+	return p.Apply(dst, rhs)
+}
+
+// LUPreconditioner is a Preconditioner given by an LU factorization of
+// a general non-singular matrix M.
+type LUPreconditioner struct {
+	lu       *mat.LU
+	dst, rhs mat.VecDense
+}
+
+// NewLUPreconditioner returns a Preconditioner that solves systems with
+// the matrix factorized in lu. The factorization is not copied and must not
+// be modified while the preconditioner is in use.
+func NewLUPreconditioner(lu *mat.LU) *LUPreconditioner {
+	r, c := lu.Dims()
+	if r == 0 || r != c {
+		panic("iterative: LU factorization not of a square matrix")
+	}
+	return &LUPreconditioner{lu: lu}
+}
+
+// Apply implements the Preconditioner interface.
+func (p *LUPreconditioner) Apply(dst, rhs []float64) error {
+	return p.solve(dst, rhs, false)
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p *LUPreconditioner) ApplyTrans(dst, rhs []float64) error {
+	return p.solve(dst, rhs, true)
+}
+
+func (p *LUPreconditioner) solve(dst, rhs []float64, trans bool) error {
+	n, _ := p.lu.Dims()
+	setVec(&p.dst, dst, n)
+	setVec(&p.rhs, rhs, n)
+	return conditionOK(p.lu.SolveVecTo(&p.dst, trans, &p.rhs))
+}
+
+// PermutedPreconditioner is the Preconditioner
+//  P^T M P
+// for a matrix A given by a preconditioner M of the reordered matrix
+// P A P^T, for example an incomplete factorization of the matrix reordered
+// by sparse.RCM. It permutes the right-hand side, applies M and permutes
+// the solution back, so it is used with the original matrix A.
+type PermutedPreconditioner struct {
+	p    Preconditioner
+	perm []int
+	work []float64
+}
+
+// NewPermutedPreconditioner returns the preconditioner P^T M P where M is
+// the preconditioner p of the matrix P A P^T reordered by the permutation
+// perm as in sparse.Permute(a, perm, perm). perm is not copied and must not
+// be modified while the preconditioner is in use.
+func NewPermutedPreconditioner(p Preconditioner, perm []int) *PermutedPreconditioner {
+	if len(perm) == 0 {
+		panic("iterative: empty permutation")
+	}
+	return &PermutedPreconditioner{
+		p:    p,
+		perm: perm,
+		work: make([]float64, len(perm)),
+	}
+}
+
+// Apply implements the Preconditioner interface.
+func (p *PermutedPreconditioner) Apply(dst, rhs []float64) error {
+	return p.solve(dst, rhs, p.p.Apply)
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p *PermutedPreconditioner) ApplyTrans(dst, rhs []float64) error {
+	return p.solve(dst, rhs, p.p.ApplyTrans)
+}
+
+func (p *PermutedPreconditioner) solve(dst, rhs []float64, solve func(dst, rhs []float64) error) error {
+	sparse.PermuteVec(p.work, rhs, p.perm)
+	if err := solve(p.work, p.work); err != nil {
+		return err
+	}
+	sparse.UnpermuteVec(dst, p.work, p.perm)
+	return nil
+}
+
+// setVec makes v a view of the slice x of length n without allocating.
+func setVec(v *mat.VecDense, x []float64, n int) {
+	if len(x) != n {
+		panic("iterative: mismatched vector length")
+	}
+	v.SetRawVector(blas64.Vector{N: n, Inc: 1, Data: x})
+}
+
+// conditionOK filters out mat.Condition errors. They only warn that the
+// factorized matrix is ill-conditioned, the solve itself has been done and
+// for a preconditioner that is not a reason to stop the iterative process.
+func conditionOK(err error) error {
+	if _, ok := err.(mat.Condition); ok {
+		return nil
+	}
+	return err
+}
+
+// CheckSymmetricPreconditioner checks using random vectors whether the
+// dim×dim preconditioner p is symmetric and positive definite as required,
+// for example, by CG. It tests that
+//  <M^{-1} x, y> = <x, M^{-1} y>
+// and
+//  <M^{-1} x, x> > 0
+// hold for several random vectors x and y, and returns a descriptive error
+// if they do not. A nil error does not guarantee that p is symmetric
+// positive definite. Only p.Apply is used.
+func CheckSymmetricPreconditioner(p Preconditioner, dim int, rnd *rand.Rand) error {
+	if dim <= 0 {
+		panic("iterative: dimension not positive")
+	}
+
+	const (
+		trials = 3
+		tol    = 1e-8
+	)
+	x := make([]float64, dim)
+	y := make([]float64, dim)
+	mx := make([]float64, dim)
+	my := make([]float64, dim)
+	for k := 0; k < trials; k++ {
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+			y[i] = rnd.NormFloat64()
+		}
+		if err := p.Apply(mx, x); err != nil {
+			return err
+		}
+		if err := p.Apply(my, y); err != nil {
+			return err
+		}
+
+		mxy := floats.Dot(mx, y)
+		xmy := floats.Dot(x, my)
+		scale := math.Max(floats.Norm(mx, 2)*floats.Norm(y, 2), floats.Norm(x, 2)*floats.Norm(my, 2))
+		if math.Abs(mxy-xmy) > tol*scale {
+			return fmt.Errorf("iterative: preconditioner not symmetric: <M^{-1}x,y>=%v, <x,M^{-1}y>=%v", mxy, xmy)
+		}
+		if mxx := floats.Dot(mx, x); mxx <= 0 {
+			return fmt.Errorf("iterative: preconditioner not positive definite: <M^{-1}x,x>=%v", mxx)
+		}
+	}
+	return nil
+}
+
+// psolver adapts the preconditioner solves in Settings to the
+// Preconditioner interface.
+type psolver struct {
+	psolve, psolveTrans func(dst, rhs []float64) error
+}
+
+func (p psolver) Apply(dst, rhs []float64) error      { return p.psolve(dst, rhs) }
+func (p psolver) ApplyTrans(dst, rhs []float64) error { return p.psolveTrans(dst, rhs) }
@@ -0,0 +1,213 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestCholeskyPreconditioner(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 5, 10, 50, 100} {
+		a := mat.NewSymDense(n, nil)
+		for i := 0; i < n; i++ {
+			for j := i; j < n; j++ {
+				a.SetSym(i, j, rnd.Float64())
+			}
+			a.SetSym(i, i, a.At(i, i)+float64(n))
+		}
+		var chol mat.Cholesky
+		if !chol.Factorize(a) {
+			t.Fatalf("n=%v: matrix not positive definite", n)
+		}
+		p := NewCholeskyPreconditioner(&chol)
+
+		want := make([]float64, n)
+		for i := range want {
+			want[i] = 1
+		}
+		b := make([]float64, n)
+		A := MatrixOps{
+			MatVec: func(dst, x []float64) {
+				mat.NewVecDense(n, dst).MulVec(a, mat.NewVecDense(n, x))
+			},
+		}
+		A.MatVec(b, want)
+
+		r, err := LinearSolve(A, b, &CG{}, Settings{
+			Tolerance:   1e-12,
+			PSolve:      p.Apply,
+			PSolveTrans: p.ApplyTrans,
+		})
+		if err != nil {
+			t.Errorf("n=%v: unexpected error %v", n, err)
+			continue
+		}
+		if r.Stats.Iterations != 1 {
+			t.Errorf("n=%v: unexpected number of iterations, want 1, got %v", n, r.Stats.Iterations)
+		}
+		dist := floats.Distance(r.X, want, math.Inf(1))
+		if dist > 1e-12 {
+			t.Errorf("n=%v: unexpected solution, |want-got|=%v", n, dist)
+		}
+	}
+}
+
+func TestLUPreconditioner(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 3, 5, 10, 50, 100} {
+		a := mat.NewDense(n, n, nil)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a.Set(i, j, rnd.NormFloat64())
+			}
+			a.Set(i, i, a.At(i, i)+float64(n))
+		}
+		var lu mat.LU
+		lu.Factorize(a)
+		p := NewLUPreconditioner(&lu)
+
+		// Check that Apply and ApplyTrans solve with A and A^T.
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+		}
+		rhs := make([]float64, n)
+		got := make([]float64, n)
+		for _, trans := range []bool{false, true} {
+			var m mat.Matrix = a
+			apply := p.Apply
+			if trans {
+				m = a.T()
+				apply = p.ApplyTrans
+			}
+			mat.NewVecDense(n, rhs).MulVec(m, mat.NewVecDense(n, x))
+			err := apply(got, rhs)
+			if err != nil {
+				t.Errorf("n=%v,trans=%v: unexpected error %v", n, trans, err)
+				continue
+			}
+			dist := floats.Distance(got, x, math.Inf(1))
+			if dist > 1e-12 {
+				t.Errorf("n=%v,trans=%v: unexpected solution, |want-got|=%v", n, trans, dist)
+			}
+		}
+
+		// BiCG preconditioned with the exact LU of A must converge
+		// in a single iteration.
+		want := make([]float64, n)
+		for i := range want {
+			want[i] = 1
+		}
+		b := make([]float64, n)
+		A := MatrixOps{
+			MatVec: func(dst, x []float64) {
+				mat.NewVecDense(n, dst).MulVec(a, mat.NewVecDense(n, x))
+			},
+			MatTransVec: func(dst, x []float64) {
+				mat.NewVecDense(n, dst).MulVec(a.T(), mat.NewVecDense(n, x))
+			},
+		}
+		A.MatVec(b, want)
+		r, err := LinearSolve(A, b, &BiCG{}, Settings{
+			Tolerance:   1e-12,
+			PSolve:      p.Apply,
+			PSolveTrans: p.ApplyTrans,
+		})
+		if err != nil {
+			t.Errorf("n=%v: unexpected error %v", n, err)
+			continue
+		}
+		if r.Stats.Iterations != 1 {
+			t.Errorf("n=%v: unexpected number of iterations, want 1, got %v", n, r.Stats.Iterations)
+		}
+		dist := floats.Distance(r.X, want, math.Inf(1))
+		if dist > 1e-12 {
+			t.Errorf("n=%v: unexpected solution, |want-got|=%v", n, dist)
+		}
+	}
+}
+
+func TestCheckSymmetricPreconditioner(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const n = 20
+	a := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			a.SetSym(i, j, rnd.Float64())
+		}
+		a.SetSym(i, i, a.At(i, i)+float64(n))
+	}
+
+	var chol mat.Cholesky
+	if !chol.Factorize(a) {
+		t.Fatal("matrix not positive definite")
+	}
+	err := CheckSymmetricPreconditioner(NewCholeskyPreconditioner(&chol), n, rnd)
+	if err != nil {
+		t.Errorf("unexpected error for Cholesky preconditioner: %v", err)
+	}
+
+	// One forward Gauss-Seidel sweep, that is, a solve with the lower
+	// triangle of a, is not symmetric.
+	gs := func(dst, rhs []float64) error {
+		for i := 0; i < n; i++ {
+			sum := rhs[i]
+			for j := 0; j < i; j++ {
+				sum -= a.At(i, j) * dst[j]
+			}
+			dst[i] = sum / a.At(i, i)
+		}
+		return nil
+	}
+	p := psolver{psolve: gs, psolveTrans: gs}
+	err = CheckSymmetricPreconditioner(p, n, rnd)
+	if err == nil || !strings.Contains(err.Error(), "not symmetric") {
+		t.Errorf("unexpected error for Gauss-Seidel preconditioner: %v", err)
+	}
+
+	neg := func(dst, rhs []float64) error {
+		for i, v := range rhs {
+			dst[i] = -v
+		}
+		return nil
+	}
+	err = CheckSymmetricPreconditioner(psolver{psolve: neg, psolveTrans: neg}, n, rnd)
+	if err == nil || !strings.Contains(err.Error(), "not positive definite") {
+		t.Errorf("unexpected error for negative definite preconditioner: %v", err)
+	}
+
+	// With Debug set, LinearSolve must reject the Gauss-Seidel
+	// preconditioner before iterating with the methods that need a
+	// symmetric positive definite preconditioner.
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = 1
+	}
+	A := MatrixOps{
+		MatVec: func(dst, x []float64) {
+			mat.NewVecDense(n, dst).MulVec(a, mat.NewVecDense(n, x))
+		},
+	}
+	for _, method := range []Method{&CG{}, &PipelinedCG{}, &WeightedCG{}, &CR{}, &SteepestDescent{}, &SYMMLQ{}} {
+		r, err := LinearSolve(A, b, method, Settings{
+			PSolve:      gs,
+			PSolveTrans: gs,
+			Debug:       true,
+		})
+		if err == nil || !strings.Contains(err.Error(), "not symmetric") {
+			t.Errorf("%T: unexpected error from LinearSolve with Debug: %v", method, err)
+		}
+		if r.Stats.Iterations != 0 {
+			t.Errorf("%T: unexpected number of iterations, want 0, got %v", method, r.Stats.Iterations)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/methodtest"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestCGProperties(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		p *problem.Problem
+		// Orthogonality of the residuals is
+		// lost on ill-conditioned matrices.
+		orthogonal bool
+	}{
+		{problem.RandomSPD(50, rnd), true},
+		{problem.Poisson2D(10, 10), true},
+		{problem.Poisson3D(6, 5, 4), true},
+		{market("nos4", 1e-8), false},
+	} {
+		p := test.p
+		d := make([]float64, p.Dim)
+		for i := range d {
+			d[i] = 1 + rnd.Float64()
+		}
+		m := iterative.DiagonalInverse(d)
+		for _, psolve := range []func(dst, rhs []float64) error{nil, m.Apply} {
+			settings := iterative.Settings{Tolerance: 1e-10, PSolve: psolve}
+			methodtest.ErrorANormNonIncreasing(t, p, &iterative.CG{}, settings)
+			if test.orthogonal {
+				methodtest.ResidualsOrthogonal(t, p, &iterative.CG{}, settings)
+			}
+		}
+	}
+}
+
+func TestGMRESProperties(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		p *problem.Problem
+		// GMRES(20) does not converge on
+		// some matrices.
+		restart bool
+	}{
+		{problem.RandomSPD(50, rnd), true},
+		{problem.Poisson2D(10, 10), true},
+		{problem.ConvectionDiffusion2D(10, 10, 2, 1), true},
+		{problem.CentralConvectionDiffusion2D(10, 10, 2, 1), true},
+		{problem.RandomSparse(100, 5, 1e2, 0.5, rnd), true},
+		{market("gre__115", 0), true},
+		{market("west0067", 0), false},
+	} {
+		p := test.p
+		settings := iterative.Settings{Tolerance: 1e-8, MaxIterations: 10 * p.Dim}
+		methodtest.ResidualNonIncreasing(t, p, &iterative.GMRES{}, settings)
+		methodtest.FiniteTermination(t, p, &iterative.GMRES{}, settings)
+		if test.restart {
+			methodtest.ResidualNonIncreasing(t, p, &iterative.GMRES{Restart: 20}, settings)
+		}
+	}
+}
+
+func TestResidualCurrent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		p   *problem.Problem
+		spd bool
+	}{
+		{problem.Poisson2D(10, 10), true},
+		{problem.ConvectionDiffusion2D(10, 10, 2, 1), false},
+		{problem.RandomSparse(100, 5, 1e2, 0.5, rnd), false},
+	} {
+		p := test.p
+		m := iterative.DiagonalInverse(diagonal(p.A, p.Dim))
+		for _, psolve := range []func(dst, rhs []float64) error{nil, m.Apply} {
+			settings := iterative.Settings{
+				Tolerance:     1e-10,
+				MaxIterations: 10 * p.Dim,
+				PSolve:        psolve,
+				PSolveTrans:   psolve,
+			}
+			methods := []iterative.Method{
+				&iterative.BiCG{},
+				&iterative.BiCGSTAB{},
+				&iterative.PipelinedBiCGSTAB{Replace: 10},
+				&iterative.GMRES{Restart: 20},
+			}
+			if test.spd {
+				methods = append(methods, &iterative.CG{}, &iterative.PipelinedCG{}, &iterative.SteepestDescent{})
+			}
+			for _, method := range methods {
+				methodtest.ResidualCurrent(t, p, method, settings)
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// QMR implements the quasi-minimal residual iterative method with
+// preconditioning for solving the system of linear equations
+//  Ax = b,
+// where A is a non-symmetric matrix. QMR builds the same Krylov subspaces
+// as BiCG by the coupled two-term Lanczos recurrences without look-ahead,
+// but it chooses the iterates by minimizing the quasi-residual, which
+// smooths the often erratic convergence of BiCG.
+//
+// QMR does not form the residual. The residual norm it reports is the
+// upper bound
+//  sqrt(k+1) τ_k
+// on the norm of the preconditioned residual M^{-1} r_k, where τ_k is the
+// norm of the quasi-residual after k iterations, so Context.Residual is
+// not current at the end of an iteration. M is applied from the left.
+//
+// If the Lanczos process or the recurrences break down, Iterate returns a
+// *BreakdownError.
+//
+// References:
+//  - Freund, R. W., Nachtigal, N. M.: QMR: a quasi-minimal residual method
+//    for non-Hermitian linear systems. Numer. Math. 60, 315-339 (1991)
+//  - Barrett, R. et al.: Templates for the Solution of Linear Systems:
+//    Building Blocks for Iterative Methods. SIAM (1994)
+//
+// QMR needs MatVec, MatTransVec, PSolve, and PSolveTrans matrix operations.
+type QMR struct {
+	first  bool
+	resume int
+	k      int
+
+	rho, rhoPrev float64
+	xi           float64
+	delta        float64
+	epsilon      float64
+	beta         float64
+	theta        float64
+	gamma        float64
+	eta          float64
+	// tau is the norm of the quasi-residual.
+	tau float64
+
+	// v and w are the right and left Lanczos
+	// vectors, y and z the preconditioned v
+	// and w.
+	v, w []float64
+	y, z []float64
+	p, q []float64
+	ap   []float64
+	d    []float64
+}
+
+// Init implements the Method interface.
+func (m *QMR) Init(dim int) {
+	if dim <= 0 {
+		panic("QMR: dimension not positive")
+	}
+
+	m.v = reuse(m.v, dim)
+	m.w = reuse(m.w, dim)
+	m.y = reuse(m.y, dim)
+	m.z = reuse(m.z, dim)
+	m.p = reuse(m.p, dim)
+	m.q = reuse(m.q, dim)
+	m.ap = reuse(m.ap, dim)
+	m.d = reuse(m.d, dim)
+	m.first = true
+	m.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (m *QMR) Iterate(ctx *Context) (Operation, error) {
+	switch m.resume {
+	case 1:
+		ctx.vec.copy(m.v, ctx.Residual)
+		ctx.vec.copy(m.w, ctx.Residual)
+		ctx.Src = m.v
+		ctx.Dst = m.y
+		m.resume = 2
+		return PSolve, nil
+		// Solve M y = v_1.
+	case 2:
+		m.rho = ctx.vec.norm(m.y)
+		m.xi = ctx.vec.norm(m.w)
+		m.gamma = 1
+		m.eta = -1
+		m.tau = m.rho
+		m.k = 0
+		fallthrough
+	case 3:
+		if m.rho == 0 {
+			m.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"QMR", "rho"}
+		}
+		if m.xi == 0 {
+			m.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"QMR", "xi"}
+		}
+		ctx.vec.scale(1/m.rho, m.v)
+		ctx.vec.scale(1/m.rho, m.y)
+		ctx.vec.scale(1/m.xi, m.w)
+		m.delta = ctx.vec.dot(m.w, m.y)
+		if math.Abs(m.delta) < rhoBreakdownTol {
+			m.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"QMR", "delta"}
+		}
+		ctx.Src = m.w
+		ctx.Dst = m.z
+		m.resume = 4
+		return PSolveTrans, nil
+		// Solve M^T z = w_i.
+	case 4:
+		if m.first {
+			ctx.vec.copy(m.p, m.y)
+			ctx.vec.copy(m.q, m.z)
+		} else {
+			// p_i = y - (ξ_i δ_i / ε_{i-1}) p_{i-1}
+			// q_i = z - (ρ_i δ_i / ε_{i-1}) q_{i-1}
+			ctx.vec.addScaledTo(m.p, m.y, -m.xi*m.delta/m.epsilon, m.p)
+			ctx.vec.addScaledTo(m.q, m.z, -m.rho*m.delta/m.epsilon, m.q)
+		}
+		ctx.Src = m.p
+		ctx.Dst = m.ap
+		m.resume = 5
+		return MatVec, nil
+		// Compute A p_i.
+	case 5:
+		m.epsilon = ctx.vec.dot(m.q, m.ap)
+		if math.Abs(m.epsilon) < rhoBreakdownTol {
+			m.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"QMR", "epsilon"}
+		}
+		m.beta = m.epsilon / m.delta
+		ctx.vec.addScaledTo(m.v, m.ap, -m.beta, m.v) // v_{i+1} = A p_i - β_i v_i
+		ctx.Src = m.v
+		ctx.Dst = m.y
+		m.resume = 6
+		return PSolve, nil
+		// Solve M y = v_{i+1}.
+	case 6:
+		m.rhoPrev = m.rho
+		m.rho = ctx.vec.norm(m.y)
+		ctx.Src = m.q
+		ctx.Dst = m.z
+		m.resume = 7
+		return MatTransVec, nil
+		// Compute A^T q_i.
+	case 7:
+		ctx.vec.addScaledTo(m.w, m.z, -m.beta, m.w) // w_{i+1} = A^T q_i - β_i w_i
+		m.xi = ctx.vec.norm(m.w)
+
+		thetaPrev, gammaPrev := m.theta, m.gamma
+		m.theta = m.rho / (gammaPrev * math.Abs(m.beta))
+		m.gamma = 1 / math.Sqrt(1+m.theta*m.theta)
+		if m.gamma == 0 {
+			m.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"QMR", "gamma"}
+		}
+		m.eta = -m.eta * m.rhoPrev * m.gamma * m.gamma / (m.beta * gammaPrev * gammaPrev)
+		if m.first {
+			ctx.vec.copy(m.d, m.p)
+			ctx.vec.scale(m.eta, m.d)
+		} else {
+			// d_i = η_i p_i + (θ_{i-1} γ_i)^2 d_{i-1}
+			c := thetaPrev * m.gamma
+			ctx.vec.scale(c*c, m.d)
+			ctx.vec.addScaled(m.d, m.eta, m.p)
+		}
+		ctx.vec.add(ctx.X, m.d)
+
+		m.k++
+		m.tau *= m.theta * m.gamma
+		ctx.ResidualNorm = math.Sqrt(float64(m.k+1)) * m.tau
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		m.resume = 8
+		return CheckResidualNorm, nil
+	case 8:
+		if ctx.Converged {
+			m.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = false
+			return EndIteration, nil
+		}
+		m.first = false
+		m.resume = 3
+		ctx.ResidualCurrent = false
+		return EndIteration, nil
+
+	default:
+		panic("QMR: Init not called")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestQMR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(3, rnd),
+		problem.RandomSPD(4, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(10, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(50, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.RandomSPD(200, rnd),
+		problem.RandomSPD(500, rnd),
+		market("nos1", 1e-9),
+		market("nos4", 1e-12),
+		market("nos5", 1e-12),
+		market("bcsstm20", 1e-10),
+		market("bcsstm22", 1e-11),
+		market("steam1", 1e-8),
+		market("steam3", 1e-7),
+		market("e05r0000", 1e-11),
+		market("e05r0100", 1e-11),
+		market("e05r0200", 1e-10),
+		market("e05r0300", 1e-10),
+		market("e05r0400", 1e-10),
+		// market("e05r0500", 1e-10),
+		market("impcol_b", 1e-9),
+		market("impcol_c", 1e-10),
+		market("fs_183_4", 1e-3),
+		market("fs_183_6", 1e-4),
+		market("west0067", 1e-11),
+		market("gre__115", 1e-12),
+		market("gre__185", 1e-9),
+		market("gre__343", 1e-12),
+		market("gre_216a", 1e-12),
+		market("arc130", 1e-4),
+	} {
+		_, err := p.Solve(&iterative.QMR{}, iterative.Settings{
+			MaxIterations: 10 * p.MaxIterations,
+			Tolerance:     1e-14,
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestQMRSmoothing(t *testing.T) {
+	for _, name := range []string{
+		"impcol_e",
+		"west0132",
+		"west0479",
+		"west0497",
+		"gre_216b",
+		"lns__131",
+		"nnc261",
+	} {
+		// BiCG does not converge on these matrices and its
+		// residual grows. QMR does not converge either but
+		// the quasi-residual minimization keeps its
+		// residual much smaller.
+		p := market(name, 0)
+		settings := iterative.Settings{
+			MaxIterations: 5 * p.Dim,
+			Tolerance:     1e-14,
+		}
+		bicg, _ := iterative.LinearSolve(p.A, p.B, &iterative.BiCG{}, settings)
+		qmr, err := iterative.LinearSolve(p.A, p.B, &iterative.QMR{}, settings)
+		var breakdown *iterative.BreakdownError
+		if errors.As(err, &breakdown) {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if got, want := residualNorm(p, qmr.X), residualNorm(p, bicg.X); !(got < 0.1*want) {
+			t.Errorf("%v: QMR residual norm %v not smaller than BiCG residual norm %v", name, got, want)
+		}
+	}
+}
+
+func TestQMRBreakdown(t *testing.T) {
+	// A is the rotation by π/2, so b^T A b = 0
+	// for any b.
+	a := iterative.MatrixOps{
+		MatVec: func(dst, x []float64) {
+			dst[0], dst[1] = x[1], -x[0]
+		},
+		MatTransVec: func(dst, x []float64) {
+			dst[0], dst[1] = -x[1], x[0]
+		},
+	}
+	_, err := iterative.LinearSolve(a, []float64{1, 2}, &iterative.QMR{}, iterative.Settings{})
+	var breakdown *iterative.BreakdownError
+	if !errors.As(err, &breakdown) || breakdown.Method != "QMR" {
+		t.Errorf("unexpected error %v, want a QMR breakdown", err)
+	}
+}
+
+// residualNorm returns the norm of b - A x.
+func residualNorm(p *problem.Problem, x []float64) float64 {
+	r := make([]float64, p.Dim)
+	p.A.MatVec(r, x)
+	floats.Sub(r, p.B)
+	return floats.Norm(r, 2)
+}
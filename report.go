@@ -0,0 +1,100 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Format is the file format of a solution written by WriteSolution.
+type Format int
+
+const (
+	// MatrixMarket is the Matrix Market
+	// array format of an n×1 real general
+	// matrix.
+	MatrixMarket Format = iota
+	// CSV is a single column of values
+	// without a header.
+	CSV
+)
+
+// WriteSolution writes the approximate solution r.X to w in the given
+// format. The values are written with the shortest representation that
+// reads back to the same float64.
+func WriteSolution(w io.Writer, r Result, format Format) error {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case MatrixMarket:
+		fmt.Fprintf(bw, "%%%%MatrixMarket matrix array real general\n%d 1\n", len(r.X))
+	case CSV:
+	default:
+		return fmt.Errorf("iterative: unknown format %d", format)
+	}
+	buf := make([]byte, 0, 32)
+	for _, v := range r.X {
+		buf = strconv.AppendFloat(buf[:0], v, 'g', -1, 64)
+		buf = append(buf, '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// report is the JSON representation of a solve written by WriteReport.
+type report struct {
+	Converged bool   `json:"converged"`
+	Error     string `json:"error,omitempty"`
+
+	Iterations int `json:"iterations"`
+	MatVec     int `json:"matvec"`
+	PSolve     int `json:"psolve"`
+
+	// ResidualNorm is nil if the norm is not
+	// finite because JSON has no
+	// representation for NaN and infinities.
+	ResidualNorm *float64 `json:"residual_norm"`
+
+	StartTime time.Time `json:"start_time"`
+	Runtime   float64   `json:"runtime_seconds"`
+}
+
+// WriteReport writes the statistics of a solve to w as a JSON object. err
+// is the error returned by LinearSolve, a nil err is reported as a
+// converged solve. The object holds the fields
+//  converged        bool
+//  error            string, omitted for a converged solve
+//  iterations       int
+//  matvec           int
+//  psolve           int
+//  residual_norm    number, null if not finite
+//  start_time       string in RFC 3339 format
+//  runtime_seconds  number
+// and it is followed by a newline, so the reports of a batch of solves
+// can be written to the same file as JSON lines.
+func WriteReport(w io.Writer, r Result, err error) error {
+	rep := report{
+		Converged:  err == nil,
+		Iterations: r.Stats.Iterations,
+		MatVec:     r.Stats.MatVec,
+		PSolve:     r.Stats.PSolve,
+		StartTime:  r.Stats.StartTime,
+		Runtime:    r.Stats.Runtime.Seconds(),
+	}
+	if err != nil {
+		rep.Error = err.Error()
+	}
+	if rn := r.Stats.ResidualNorm; !math.IsNaN(rn) && !math.IsInf(rn, 0) {
+		rep.ResidualNorm = &rn
+	}
+	return json.NewEncoder(w).Encode(rep)
+}
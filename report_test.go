@@ -0,0 +1,141 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vladimir-ch/iterative/mmarket"
+)
+
+func TestWriteSolution(t *testing.T) {
+	for _, x := range [][]float64{
+		{},
+		{1},
+		{0.1, -2.5e-300, 1e300, math.Pi, -0, math.Inf(1), math.NaN()},
+	} {
+		r := Result{X: x}
+
+		var buf bytes.Buffer
+		if err := WriteSolution(&buf, r, MatrixMarket); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		got, err := mmarket.NewReader(&buf).ReadVector()
+		if err != nil {
+			t.Fatalf("unexpected error reading the written vector: %v", err)
+		}
+		checkSameVec(t, "MatrixMarket", got, x)
+
+		buf.Reset()
+		if err := WriteSolution(&buf, r, CSV); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		lines := strings.Fields(buf.String())
+		got = make([]float64, len(lines))
+		for i, s := range lines {
+			got[i], err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				t.Fatalf("unexpected error reading the written value: %v", err)
+			}
+		}
+		checkSameVec(t, "CSV", got, x)
+	}
+
+	if err := WriteSolution(&bytes.Buffer{}, Result{}, Format(-1)); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+}
+
+func TestWriteSolutionSolve(t *testing.T) {
+	const n = 20
+	ab := make([]float64, 2*n)
+	for i := 0; i < n; i++ {
+		ab[2*i] = 2
+		if i < n-1 {
+			ab[2*i+1] = -1
+		}
+	}
+	a := SymBandedOps(ab, n, 1)
+	b := make([]float64, n)
+	a.MatVec(b, ones(n))
+	res, err := LinearSolve(a, b, &CG{}, Settings{Tolerance: 1e-10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteSolution(&buf, res, MatrixMarket); err != nil {
+		t.Fatal(err)
+	}
+	got, err := mmarket.NewReader(&buf).ReadVector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSameVec(t, "solve", got, res.X)
+}
+
+func TestWriteReport(t *testing.T) {
+	start := time.Date(2017, 7, 18, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		res  Result
+		err  error
+		want string
+	}{
+		{
+			res: Result{Stats: Stats{
+				Iterations:   10,
+				MatVec:       11,
+				PSolve:       10,
+				ResidualNorm: 1.5e-9,
+				StartTime:    start,
+				Runtime:      1500 * time.Millisecond,
+			}},
+			want: `{"converged":true,"iterations":10,"matvec":11,"psolve":10,"residual_norm":1.5e-9,"start_time":"2017-07-18T12:00:00Z","runtime_seconds":1.5}`,
+		},
+		{
+			res: Result{Stats: Stats{
+				Iterations:   3,
+				MatVec:       3,
+				ResidualNorm: math.Inf(1),
+				StartTime:    start,
+			}},
+			err:  errors.New("iterative: iteration limit reached"),
+			want: `{"converged":false,"error":"iterative: iteration limit reached","iterations":3,"matvec":3,"psolve":0,"residual_norm":null,"start_time":"2017-07-18T12:00:00Z","runtime_seconds":0}`,
+		},
+	} {
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, test.res, test.err); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		got := buf.String()
+		if got != test.want+"\n" {
+			t.Errorf("unexpected report\nwant %s\ngot  %s", test.want, got)
+		}
+		if !json.Valid(buf.Bytes()) {
+			t.Errorf("report is not valid JSON")
+		}
+	}
+}
+
+// checkSameVec checks that got and want are equal element by element,
+// NaNs are considered equal.
+func checkSameVec(t *testing.T, name string, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%v: unexpected length %v, want %v", name, len(got), len(want))
+		return
+	}
+	for i, v := range want {
+		if got[i] != v && !(math.IsNaN(got[i]) && math.IsNaN(v)) {
+			t.Errorf("%v: unexpected element %v, want %v, got %v", name, i, v, got[i])
+		}
+	}
+}
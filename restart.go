@@ -0,0 +1,131 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "fmt"
+
+// AutoRestart is a Method that wraps the Method Inner and restarts it from
+// the current approximate solution when its convergence stalls. The
+// restart computes the residual of the current iterate anew and
+// reinitializes Inner, which discards its recurrences, for example the
+// shadow residual and the search directions of BiCGSTAB, whose loss of
+// accuracy is often the reason of the stall.
+//
+// The convergence stalls if the residual norm at the end of an iteration
+// is larger than Factor times the norm Window iterations before. Only the
+// iterations since the last restart are compared, so Inner runs at least
+// Window+1 iterations between restarts.
+//
+// Each restart is counted in Stats.Restarts. AutoRestart needs the
+// operations of Inner and ComputeResidual.
+type AutoRestart struct {
+	// Inner is the wrapped method. It must
+	// not be nil.
+	Inner Method
+	// Window is the number of iterations
+	// over which the progress is measured.
+	// It must be positive.
+	Window int
+	// Factor is the reduction of the
+	// residual norm over Window iterations
+	// below which Inner is restarted. It must
+	// satisfy 0 < Factor < 1.
+	Factor float64
+	// MaxRestarts is the maximum number of
+	// restarts. If the convergence stalls
+	// again after MaxRestarts restarts,
+	// Iterate returns an error. If it is 0,
+	// the number of restarts is limited only
+	// by Settings.MaxIterations. It must not
+	// be negative.
+	MaxRestarts int
+
+	resume   int
+	dim      int
+	restarts int
+
+	// norm is the residual norm of the last
+	// CheckResidualNorm.
+	norm float64
+	// norms holds the residual norms at the
+	// ends of the last Window+1 iterations,
+	// the norm of the iteration k since the
+	// last restart at k%(Window+1).
+	norms []float64
+	k     int
+}
+
+// Init implements the Method interface.
+func (ar *AutoRestart) Init(dim int) {
+	if ar.Inner == nil {
+		panic("AutoRestart: nil Inner")
+	}
+	if ar.Window <= 0 {
+		panic("AutoRestart: Window not positive")
+	}
+	if ar.Factor <= 0 || 1 <= ar.Factor {
+		panic("AutoRestart: invalid value of Factor")
+	}
+	if ar.MaxRestarts < 0 {
+		panic("AutoRestart: negative MaxRestarts")
+	}
+
+	ar.Inner.Init(dim)
+	ar.dim = dim
+	ar.norms = reuse(ar.norms, ar.Window+1)
+	ar.k = 0
+	ar.restarts = 0
+	ar.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (ar *AutoRestart) Iterate(ctx *Context) (Operation, error) {
+	switch ar.resume {
+	case 1:
+		op, err := ar.Inner.Iterate(ctx)
+		if err != nil {
+			ar.resume = 0 // Calling Iterate again without Init will panic.
+			return op, err
+		}
+		switch op {
+		case CheckResidualNorm:
+			ar.norm = ctx.ResidualNorm
+		case EndIteration:
+			if ctx.Converged {
+				ar.resume = 0 // Calling Iterate again without Init will panic.
+				return op, nil
+			}
+			ar.k++
+			w := len(ar.norms)
+			ar.norms[ar.k%w] = ar.norm
+			// The norm Window iterations ago is
+			// at (k-Window)%w = (k+1)%w.
+			if ar.k >= w && ar.norm > ar.Factor*ar.norms[(ar.k+1)%w] {
+				ar.resume = 2
+			}
+		}
+		return op, nil
+	case 2:
+		if ar.MaxRestarts > 0 && ar.restarts == ar.MaxRestarts {
+			ar.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, fmt.Errorf("AutoRestart: stagnation after %d restarts", ar.restarts)
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		ar.resume = 3
+		return ComputeResidual, nil
+		// Compute r = b - A x.
+	case 3:
+		ar.Inner.Init(ar.dim)
+		ar.restarts++
+		ctx.Restarts++
+		ar.k = 0
+		ar.resume = 1
+		return ar.Iterate(ctx)
+
+	default:
+		panic("AutoRestart: Init not called")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// stallingProblem returns a system whose matrix is block diagonal with a
+// well-conditioned diagonal block and an unsymmetric tridiagonal block,
+// together with its solution and an initial estimate whose residual has a
+// tiny component in the second block. The shadow residual of BiCGSTAB,
+// which is the initial residual, then hardly sees the second block and
+// the convergence stalls after the first block is solved.
+func stallingProblem(n int, eps float64) (a *sparse.CSR, b, x, x0 []float64) {
+	t := sparse.NewTriplet(2*n, 2*n)
+	for i := 0; i < n; i++ {
+		t.Append(i, i, 1+float64(i)/float64(n))
+		j := n + i
+		t.Append(j, j, 2)
+		if i > 0 {
+			t.Append(j, j-1, -1.5)
+		}
+		if i < n-1 {
+			t.Append(j, j+1, -0.5)
+		}
+	}
+	a = sparse.NewCSRFromTriplet(t)
+	x = make([]float64, 2*n)
+	x0 = make([]float64, 2*n)
+	for i := range x {
+		x[i] = 1
+		if i >= n {
+			x0[i] = 1 - eps
+		}
+	}
+	b = make([]float64, 2*n)
+	a.MulVec(b, x)
+	return a, b, x, x0
+}
+
+func TestAutoRestart(t *testing.T) {
+	a, b, x, x0 := stallingProblem(100, 1e-6)
+	ops := iterative.MatrixOps{MatVec: a.MulVec}
+	settings := iterative.Settings{
+		X0:            x0,
+		Tolerance:     1e-10,
+		MaxIterations: 95,
+	}
+
+	_, err := iterative.LinearSolve(ops, b, &iterative.BiCGSTAB{}, settings)
+	if err == nil || !strings.Contains(err.Error(), "iteration limit") {
+		t.Fatalf("BiCGSTAB did not stall, error %v", err)
+	}
+
+	method := &iterative.AutoRestart{
+		Inner:  &iterative.BiCGSTAB{},
+		Window: 10,
+		Factor: 0.9,
+	}
+	res, err := iterative.LinearSolve(ops, b, method, settings)
+	if err != nil {
+		t.Fatalf("restarted BiCGSTAB: unexpected error: %v", err)
+	}
+	if res.Stats.Restarts == 0 {
+		t.Errorf("no restarts")
+	}
+	if !floats.EqualApprox(res.X, x, 1e-8) {
+		t.Errorf("restarted BiCGSTAB: inaccurate solution")
+	}
+
+	// A method that converges without
+	// stalling is not restarted.
+	a = tridiagonalCSR(100, -1, 4, -1)
+	ops = iterative.MatrixOps{MatVec: a.MulVec}
+	settings = iterative.Settings{Tolerance: 1e-10}
+	res, err = iterative.LinearSolve(ops, b[:100], method, settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plain, _ := iterative.LinearSolve(ops, b[:100], &iterative.BiCGSTAB{}, settings)
+	if res.Stats.Restarts != 0 || res.Stats.Iterations != plain.Stats.Iterations {
+		t.Errorf("unexpected restarts: %d iterations and %d restarts, want %d iterations",
+			res.Stats.Iterations, res.Stats.Restarts, plain.Stats.Iterations)
+	}
+}
+
+func TestAutoRestartMaxRestarts(t *testing.T) {
+	a, b, _, x0 := stallingProblem(100, 1e-6)
+	ops := iterative.MatrixOps{MatVec: a.MulVec}
+	method := &iterative.AutoRestart{
+		Inner:       &iterative.BiCGSTAB{},
+		Window:      10,
+		Factor:      0.9,
+		MaxRestarts: 1,
+	}
+	res, err := iterative.LinearSolve(ops, b, method, iterative.Settings{
+		X0:            x0,
+		Tolerance:     1e-10,
+		MaxIterations: 1000,
+	})
+	if err == nil || !strings.Contains(err.Error(), "after 1 restarts") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Stats.Restarts != 1 {
+		t.Errorf("unexpected number of restarts: got %d, want 1", res.Stats.Restarts)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// Richardson implements the preconditioned Richardson iteration
+//  x_i = x_{i-1} + τ_i M^{-1} (b - A x_{i-1})
+// for solving the system of linear equations
+//  Ax = b,
+// where M is the preconditioner. With a fixed step size τ_i = Tau the
+// iteration converges if the spectral radius of I - τ M^{-1}A is less than
+// one, for a symmetric positive definite M^{-1}A if
+//  0 < τ < 2/λ_max(M^{-1}A),
+// and diverges otherwise. Divergence is detected when the residual norm
+// overflows and Iterate then returns a *BreakdownError for the step size.
+//
+// If Adaptive is true, τ_i is chosen in each iteration to minimize the
+// residual norm along the direction z = M^{-1} r_{i-1},
+//  τ_i = (r_{i-1} · Az) / (Az · Az),
+// so that the residual norm does not increase. Az is needed to update the
+// residual anyway, so the adaptive step size does not need more matrix
+// operations than the fixed one. If Az is zero, Iterate returns a
+// *BreakdownError.
+//
+// Richardson converges slowly, it is mainly useful as a baseline and as a
+// smoother.
+//
+// Richardson needs MatVec and PSolve matrix operations.
+type Richardson struct {
+	// Tau is the fixed step size τ. If it
+	// is zero, 1 is used. It must not be
+	// negative. Tau is ignored if Adaptive
+	// is true.
+	Tau float64
+
+	// Adaptive specifies whether the step
+	// size minimizes the residual norm in
+	// each iteration.
+	Adaptive bool
+
+	resume int
+	tau    float64
+
+	z  []float64
+	az []float64
+}
+
+// Init implements the Method interface.
+func (r *Richardson) Init(dim int) {
+	if dim <= 0 {
+		panic("Richardson: dimension not positive")
+	}
+	if r.Tau < 0 {
+		panic("Richardson: negative step size")
+	}
+
+	r.tau = r.Tau
+	if r.tau == 0 {
+		r.tau = 1
+	}
+	r.z = reuse(r.z, dim)
+	r.az = reuse(r.az, dim)
+	r.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (r *Richardson) Iterate(ctx *Context) (Operation, error) {
+	switch r.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = r.z
+		r.resume = 2
+		return PSolve, nil
+		// Solve M z = r_{i-1}
+	case 2:
+		ctx.Src = r.z
+		ctx.Dst = r.az
+		r.resume = 3
+		return MatVec, nil
+		// Compute Az
+	case 3:
+		if r.Adaptive {
+			azaz := ctx.vec.dot(r.az, r.az)
+			if azaz == 0 {
+				r.resume = 0 // Calling Iterate again without Init will panic.
+				return NoOperation, &BreakdownError{"Richardson", "Az"}
+			}
+			r.tau = ctx.vec.dot(ctx.Residual, r.az) / azaz
+		}
+		ctx.vec.addScaled(ctx.X, r.tau, r.z)              // x_i = x_{i-1} + τ z
+		rr := ctx.vec.axpyDot(-r.tau, r.az, ctx.Residual) // r_i = r_{i-1} - τ Az
+		ctx.ResidualNorm = math.Sqrt(rr)
+		if math.IsNaN(ctx.ResidualNorm) || math.IsInf(ctx.ResidualNorm, 0) {
+			r.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"Richardson", "step size"}
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		r.resume = 4
+		return CheckResidualNorm, nil
+	case 4:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			r.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		r.resume = 1
+		return EndIteration, nil
+
+	default:
+		panic("Richardson: Init not called")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestRichardson(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name   string
+		a      *sparse.CSR
+		jacobi bool
+		method *iterative.Richardson
+	}{
+		// The eigenvalues of the 1-D Laplacian
+		// are in (0, 4).
+		{"laplacian", tridiagonalCSR(20, -1, 2, -1), false, &iterative.Richardson{Tau: 0.45}},
+		{"laplacian", tridiagonalCSR(20, -1, 2, -1), false, &iterative.Richardson{Adaptive: true}},
+		{"laplacian/jacobi", tridiagonalCSR(20, -1, 2, -1), true, &iterative.Richardson{Tau: 0.9}},
+		{"laplacian/jacobi", tridiagonalCSR(20, -1, 2, -1), true, &iterative.Richardson{Adaptive: true}},
+		{"dominant/jacobi", diagonallyDominant(100, 5, 0.5, rnd), true, &iterative.Richardson{}},
+		{"dominant/jacobi", diagonallyDominant(100, 5, 0.5, rnd), true, &iterative.Richardson{Adaptive: true}},
+	} {
+		n, _ := test.a.Dims()
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = rnd.NormFloat64()
+		}
+		b := make([]float64, n)
+		test.a.MulVec(b, x)
+
+		settings := iterative.Settings{
+			Tolerance:     1e-10,
+			MaxIterations: 100000,
+		}
+		if test.jacobi {
+			settings.PSolve = iterative.DiagonalInverse(sparse.Diagonal(test.a)).Apply
+		}
+		res, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: test.a.MulVec}, b, test.method, settings)
+		if err != nil {
+			t.Errorf("%s,%+v: unexpected error: %v", test.name, *test.method, err)
+			continue
+		}
+		if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-6*floats.Norm(x, math.Inf(1)) {
+			t.Errorf("%s,%+v: solution differs by %v", test.name, *test.method, d)
+		}
+		// One product with A per iteration also
+		// with the adaptive step size.
+		if res.Stats.MatVec != res.Stats.Iterations {
+			t.Errorf("%s,%+v: %d products in %d iterations", test.name, *test.method, res.Stats.MatVec, res.Stats.Iterations)
+		}
+	}
+}
+
+func TestRichardsonDivergence(t *testing.T) {
+	const n = 20
+	a := tridiagonalCSR(n, -1, 2, -1)
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = 1
+	}
+	ops := iterative.MatrixOps{MatVec: a.MulVec}
+
+	// The iteration is stable for 0 < τ < 2/λ_max,
+	// and λ_max is close to 4.
+	for _, tau := range []float64{0.6, 1, 2} {
+		res, err := iterative.LinearSolve(ops, b, &iterative.Richardson{Tau: tau}, iterative.Settings{
+			MaxIterations: 100000,
+		})
+		var breakdown *iterative.BreakdownError
+		if !errors.As(err, &breakdown) || breakdown.Method != "Richardson" || breakdown.Quantity != "step size" {
+			t.Errorf("tau=%v: unexpected error %v", tau, err)
+			continue
+		}
+		if res.Stats.Iterations >= 100000 {
+			t.Errorf("tau=%v: divergence not detected", tau)
+		}
+		for _, v := range res.X {
+			if math.IsNaN(v) {
+				t.Errorf("tau=%v: NaN in the solution", tau)
+				break
+			}
+		}
+	}
+
+	// The adaptive step size does not increase the
+	// residual norm.
+	l := iterative.NewLoop(ops, b, &iterative.Richardson{Adaptive: true}, iterative.Settings{
+		MaxIterations: 500,
+	})
+	prev := floats.Norm(b, 2)
+	for {
+		done, _ := l.Step()
+		if l.Operation() == iterative.EndIteration {
+			rnorm := l.Context().ResidualNorm
+			if rnorm > prev*(1+1e-14) {
+				t.Errorf("adaptive: residual norm increased from %v to %v", prev, rnorm)
+			}
+			prev = rnorm
+		}
+		if done {
+			break
+		}
+	}
+}
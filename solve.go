@@ -6,9 +6,9 @@ package iterative
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
-
-	"github.com/gonum/floats"
 )
 
 // MatrixOps describes the matrix of the linear system in terms of A*x
@@ -24,6 +24,43 @@ type MatrixOps struct {
 	// not command MatTransVec, this can be
 	// nil.
 	MatTransVec func(dst, x []float64)
+
+	// MatMatVec computes A*X for the n×k
+	// matrix X and stores the result into
+	// the n×k matrix dst. Both are stored
+	// column-major, the j-th column of X is
+	// x[j*n:(j+1)*n]. MatMatVec is optional,
+	// if it is nil, the products are
+	// computed column by column with
+	// MatVec. Providing it pays off for
+	// block methods when the k products
+	// can share the traversal of A.
+	MatMatVec func(dst, x []float64, k int)
+
+	// Rows and Cols are the dimensions of
+	// a rectangular m×n matrix A. If both
+	// are zero, A is square and its
+	// dimension is the length of b. A
+	// rectangular system is solved in the
+	// least-squares sense by a RectMethod,
+	// x then has length Cols and b length
+	// Rows.
+	Rows, Cols int
+}
+
+// dims returns the dimensions of A for the right-hand side of length m. It
+// panics if a is rectangular and m is not a.Rows.
+func (a MatrixOps) dims(m int) (rows, cols int) {
+	if a.Rows == 0 && a.Cols == 0 {
+		return m, m
+	}
+	if a.Rows < 0 || a.Cols < 0 {
+		panic("iterative: negative matrix dimension")
+	}
+	if a.Rows != m {
+		panic("iterative: mismatched length of right-hand side")
+	}
+	return a.Rows, a.Cols
 }
 
 // Settings holds various settings for solving a linear system.
@@ -33,9 +70,20 @@ type Settings struct {
 	// used.
 	// If it is not nil, the length of X0 must
 	// be equal to the dimension of the
-	// system.
+	// system, the number of columns of a
+	// rectangular A.
 	X0 []float64
 
+	// InPlace specifies that the iterations
+	// update X0 in place instead of a copy of
+	// it. X0 must not be nil. LinearSolve then
+	// does not allocate the solution, and
+	// Result.X is X0. If the solve fails, X0
+	// holds the last approximate solution
+	// computed by the method, the original
+	// initial guess is not restored.
+	InPlace bool
+
 	// Tolerance specifies error tolerance for
 	// the final approximate solution produced
 	// by the iterative method. Tolerance must
@@ -44,12 +92,45 @@ type Settings struct {
 	//
 	// If NormA is not zero, the stopping
 	// criterion used will be
-	//  |r_i| < Tolerance * (|A|*|x_i| + |b|),
+	//  |r_i| < Tolerance * (|A|*|x| + |b|),
+	// where x is the approximate solution at
+	// the end of the previous iteration,
+	// because methods do not have to update
+	// x before checking the residual norm.
 	// If NormA is zero (not available), the
 	// stopping criterion will be
 	//  |r_i| < Tolerance * |b|.
+	//
+	// For a rectangular A, with CGNR, or with
+	// LSMR with a nonzero Damp, the system is
+	// generally inconsistent, so the solve
+	// also stops at the least-squares
+	// solution when
+	//  |A^T r_i| < Tolerance * |A| * |r_i|,
+	// where |A^T r_i| is reported by the
+	// method in Context.NormalResidualNorm
+	// and |A| is NormA, estimated as with
+	// EstimateNormA if it is zero.
 	Tolerance float64
 
+	// NoiseLevel is the norm |b - b_exact| of
+	// the noise in b, if known. If it is
+	// positive, the stopping criterion is the
+	// discrepancy principle
+	//  |r_i| <= DiscrepancyFactor * NoiseLevel
+	// instead of the one with Tolerance. It is
+	// used with methods such as Landweber for
+	// ill-posed problems where iterating to a
+	// smaller residual fits the noise. It must
+	// not be negative.
+	NoiseLevel float64
+
+	// DiscrepancyFactor is the factor of the
+	// discrepancy principle with NoiseLevel.
+	// If it is zero, 1.1 is used. It must not
+	// be smaller than one.
+	DiscrepancyFactor float64
+
 	// NormA is an estimate of a norm |A| of
 	// A, for example, an approximation of the
 	// largest entry. Zero value means that
@@ -57,10 +138,30 @@ type Settings struct {
 	// used in the stopping criterion.
 	NormA float64
 
+	// EstimateNormA specifies that if NormA
+	// is zero, it is set to the estimate of
+	// the 2-norm of A by EstimateTwoNorm
+	// with 20 steps before iterating, so the
+	// stopping criterion with |A| is used.
+	// The products with A done by the
+	// estimate are counted in Stats. If the
+	// matrix lacks MatTransVec, the estimate
+	// assumes that A is symmetric.
+	EstimateNormA bool
+
+	// RateWindow is the number of the last
+	// iterations over which Stats.RecentRate
+	// is computed. If it is zero, the
+	// residual norms of the iterations are
+	// not recorded and RecentRate is NaN. It
+	// must not be negative.
+	RateWindow int
+
 	// MaxIterations is the limit on the
 	// number of iterations.
 	// If it is zero, it will be set to twice
-	// the dimension of the system.
+	// the dimension of the system, the
+	// number of columns of a rectangular A.
 	MaxIterations int
 
 	// PSolve describes the preconditioner
@@ -78,6 +179,50 @@ type Settings struct {
 	// If it is nil, no preconditioning will
 	// be used (M is the identitify).
 	PSolveTrans func(dst, rhs []float64) error
+
+	// Weight computes B*x for the symmetric
+	// positive definite matrix B that defines
+	// the inner product
+	//  <u, v>_B = u^T B v
+	// of the methods that command WeightVec,
+	// such as WeightedCG, and stores the
+	// result into dst. If it is not nil, |b|
+	// and the norm of the initial residual in
+	// the stopping criterion are B-norms, so
+	// it should not be used with methods that
+	// measure the residual in the Euclidean
+	// norm. If it is nil, B is the identity.
+	Weight func(dst, x []float64)
+
+	// Debug enables additional, potentially
+	// expensive checks of the input. If the
+	// method requires a symmetric positive
+	// definite preconditioner (e.g., CG),
+	// PSolve will be checked with
+	// CheckSymmetricPreconditioner before
+	// iterating. The preconditioner solves
+	// done by the check are not counted in
+	// Stats. Debug also checks that the
+	// method updates ResidualNorm to a
+	// finite non-negative value before each
+	// CheckResidualNorm.
+	Debug bool
+
+	// Threads is the number of goroutines
+	// used for the vector operations of
+	// LinearSolve and of the methods in this
+	// package on vectors of length at least
+	// ParallelThreshold. If it is negative,
+	// runtime.GOMAXPROCS(0) is used. If it is
+	// 0 or 1, the operations are serial.
+	// Inner products and norms computed in
+	// parallel differ from the serial ones
+	// by rounding errors, but they are
+	// reproducible for a fixed number of
+	// threads. The matrix operations and the
+	// preconditioner are not affected, they
+	// are parallelized by their providers.
+	Threads int
 }
 
 func defaultSettings(s *Settings, dim int) {
@@ -87,6 +232,45 @@ func defaultSettings(s *Settings, dim int) {
 	if s.MaxIterations == 0 {
 		s.MaxIterations = 2 * dim
 	}
+	if s.DiscrepancyFactor == 0 {
+		s.DiscrepancyFactor = 1.1
+	}
+}
+
+// checkSettings returns an error for the invalid settings after
+// defaultSettings.
+func checkSettings(s *Settings) error {
+	if s.Tolerance < eps || 1 <= s.Tolerance {
+		return errors.New("iterative: invalid tolerance")
+	}
+	if s.NoiseLevel < 0 {
+		return errors.New("iterative: negative noise level")
+	}
+	if s.DiscrepancyFactor < 1 {
+		return errors.New("iterative: discrepancy factor smaller than one")
+	}
+	if s.RateWindow < 0 {
+		return errors.New("iterative: negative rate window")
+	}
+	return nil
+}
+
+// converged returns whether the residual norm satisfies the stopping
+// criterion of s, where bnorm is the norm of b and xnorm is the norm of the
+// approximate solution.
+func (s *Settings) converged(rnorm, bnorm, xnorm float64) bool {
+	if s.NoiseLevel > 0 {
+		return rnorm <= s.DiscrepancyFactor*s.NoiseLevel
+	}
+	return rnorm/(s.NormA*xnorm+bnorm) < s.Tolerance
+}
+
+// convergedLS returns whether the stopping criterion of s for a
+// rectangular system is satisfied, where arnorm is the norm of A^T r. It
+// is satisfied if the residual norm satisfies the criterion of converged,
+// or if the residual is nearly orthogonal to the range of A.
+func (s *Settings) convergedLS(rnorm, arnorm, bnorm, xnorm float64) bool {
+	return s.converged(rnorm, bnorm, xnorm) || arnorm < s.Tolerance*s.NormA*rnorm
 }
 
 // Result holds the result of an iterative solve.
@@ -111,9 +295,47 @@ type Stats struct {
 	// PSolveTrans operations commanded by
 	// Method.
 	PSolve int
+	// WeightVec is the number of products
+	// with the weight matrix of
+	// Settings.Weight, including those
+	// computing the norms of b and of the
+	// initial residual.
+	WeightVec int
+	// Restarts is the number of restarts of
+	// a restarted method such as GMRES.
+	Restarts int
 	// ResidualNorm is the final norm of the
-	// residual.
+	// residual. In a solve with multiple
+	// right-hand sides, it is the largest
+	// of ResidualNorms.
 	ResidualNorm float64
+	// ResidualNorms are the final norms of
+	// the columns of the residual of a solve
+	// with multiple right-hand sides by
+	// LinearSolveBlock. It is nil for a
+	// single right-hand side.
+	ResidualNorms []float64
+	// ConvergenceRate is the geometric mean
+	// of the reduction factors of the
+	// residual norm per iteration,
+	//  (|r_k| / |r_0|)^(1/k)
+	// after k iterations. It is set when the
+	// solve is done. It is NaN if no
+	// iteration was done, which includes a
+	// zero initial residual, or if a norm is
+	// not finite, and it is zero if the final
+	// residual norm is zero.
+	ConvergenceRate float64
+	// RecentRate is the rate of the form of
+	// ConvergenceRate over the last
+	// Settings.RateWindow iterations, or over
+	// all iterations if fewer were done. A
+	// RecentRate much closer to one than
+	// ConvergenceRate indicates that the
+	// convergence stalled. It is NaN in the
+	// cases of ConvergenceRate and if
+	// RateWindow is zero.
+	RecentRate float64
 	// StartTime is an approximate time when
 	// the solve was started.
 	StartTime time.Time
@@ -128,131 +350,126 @@ type Stats struct {
 // operations in a. The dimension of the problem n is determined by
 // the length of b.
 //
+// If a.Rows and a.Cols describe a rectangular m×n matrix A, LinearSolve
+// solves the least-squares problem
+//  min |b - A*x|
+// with x of length n and b of length m. method must then be a RectMethod
+// and a must have MatTransVec.
+//
 // method is an iterative method used for finding an approximate
 // solution of the linear system. It must not be nil. The operations
 // in a must provide what the method needs.
 //
 // settings provide means for adjusting the iterative process. Zero
 // values of the fields mean default values.
+//
+// If |b| is larger than 2^32 or smaller than 2^-32, LinearSolve solves the
+// system scaled by the power of two closest to 1/|b| from below, so that
+// the inner products computed by the method neither overflow nor underflow
+// its breakdown thresholds. The scaling is exact, the solution and the
+// residual norms in Stats refer to the original system.
+//
+// LinearSolve calls Loop.Step until the solve is done. It allocates the
+// Loop, the residual vector of length n and, unless settings.InPlace is
+// true, the solution vector of length n. method allocates its workspace
+// when it is initialized for the first time. Methods reuse the workspace
+// in subsequent solves of systems of the same or smaller dimension. No
+// allocations are done in the iterations by LinearSolve and the methods
+// in this package unless settings.Threads enables parallel vector
+// operations, which allocate when starting their goroutines.
 func LinearSolve(a MatrixOps, b []float64, method Method, settings Settings) (Result, error) {
-	stats := Stats{StartTime: time.Now()}
-
-	dim := len(b)
-	if a.MatVec == nil {
-		panic("iterative: nil matrix-vector multiplication")
-	}
-	if settings.X0 != nil && len(settings.X0) != dim {
-		panic("iterative: mismatched length of initial guess")
+	l := NewLoop(a, b, method, settings)
+	for {
+		done, err := l.Step()
+		if done {
+			return l.Result(), err
+		}
 	}
+}
 
-	if dim == 0 {
-		return Result{Stats: stats}, nil
+// needsSPDPreconditioner returns whether method requires the preconditioner
+// to be symmetric positive definite.
+func needsSPDPreconditioner(method Method) bool {
+	switch method.(type) {
+	case *CG, *PipelinedCG, *WeightedCG, *CR, *SteepestDescent, *SYMMLQ:
+		return true
 	}
+	return false
+}
 
-	defaultSettings(&settings, dim)
-	if settings.Tolerance < eps || 1 <= settings.Tolerance {
-		panic("iterative: invalid tolerance")
+// solvesLeastSquares returns whether method solves a least-squares problem
+// also for a square A, so that the solve must use the stopping criterion of
+// a rectangular system.
+func solvesLeastSquares(method Method) bool {
+	switch m := method.(type) {
+	case *CGNR:
+		return true
+	case *LSMR:
+		return m.Damp != 0
 	}
+	return false
+}
+
+// unsetNormBits are the bits of the NaN stored in Context.ResidualNorm in
+// debug mode before calling Method.Iterate to detect whether the method
+// updates it.
+const unsetNormBits = 0x7ff8deadbeef0001
 
-	ctx := &Context{
-		X:        make([]float64, dim),
-		Residual: make([]float64, dim),
+// checkOperation returns an error if the vectors in ctx are not valid for
+// the operation op on a system with the rows×cols matrix. The checks are
+// cheap and done for every operation, so that a faulty Method is reported
+// instead of causing a panic in the matrix operations or the
+// preconditioner provided by the user.
+func checkOperation(op Operation, ctx *Context, rows, cols int) error {
+	var src, dst int
+	switch op {
+	case MatVec:
+		src, dst = cols, rows
+	case MatTransVec:
+		src, dst = rows, cols
+	case PSolve, PSolveTrans:
+		src, dst = cols, cols
+	case WeightVec:
+		src, dst = rows, rows
+	case ComputeResidual, EndIteration:
+		if len(ctx.X) != cols {
+			return fmt.Errorf("%v with X of length %d, want %d", op, len(ctx.X), cols)
+		}
+		if len(ctx.Residual) != rows {
+			return fmt.Errorf("%v with Residual of length %d, want %d", op, len(ctx.Residual), rows)
+		}
+		return nil
+	default:
+		return nil
 	}
-	if settings.X0 != nil {
-		copy(ctx.X, settings.X0)
-		a.MatVec(ctx.Residual, ctx.X)
-		stats.MatVec++
-		floats.AddScaledTo(ctx.Residual, b, -1, ctx.Residual) // r = b - Ax
-	} else {
-		copy(ctx.Residual, b) // r = b
+	if ctx.Src == nil {
+		return fmt.Errorf("%v with nil Src", op)
 	}
-
-	ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
-	var err error
-	if ctx.ResidualNorm >= settings.Tolerance {
-		err = iterate(a, b, ctx, settings, method, &stats)
+	if ctx.Dst == nil {
+		return fmt.Errorf("%v with nil Dst", op)
 	}
-
-	stats.Runtime = time.Since(stats.StartTime)
-	return Result{
-		X:     ctx.X,
-		Stats: stats,
-	}, err
+	if len(ctx.Src) != src {
+		return fmt.Errorf("%v with Src of length %d, want %d", op, len(ctx.Src), src)
+	}
+	if len(ctx.Dst) != dst {
+		return fmt.Errorf("%v with Dst of length %d, want %d", op, len(ctx.Dst), dst)
+	}
+	return nil
 }
 
-func iterate(a MatrixOps, b []float64, ctx *Context, settings Settings, method Method, stats *Stats) error {
-	dim := len(ctx.X)
-	bnorm := floats.Norm(b, 2)
-	if bnorm == 0 {
-		bnorm = 1
+// checkResidualNorm returns an error if op is CheckResidualNorm and
+// ResidualNorm has not been updated by the method or is not a finite
+// non-negative number. It is used in debug mode.
+func checkResidualNorm(op Operation, ctx *Context) error {
+	if op != CheckResidualNorm {
+		return nil
 	}
-
-	method.Init(dim)
-
-	for {
-		op, err := method.Iterate(ctx)
-		if err != nil {
-			return err
-		}
-
-		switch op {
-		case NoOperation:
-
-		case ComputeResidual:
-			a.MatVec(ctx.Residual, ctx.X)
-			stats.MatVec++
-			floats.AddScaledTo(ctx.Residual, b, -1, ctx.Residual)
-
-		case MatVec, MatTransVec:
-			if op == MatVec {
-				a.MatVec(ctx.Dst, ctx.Src)
-			} else {
-				a.MatTransVec(ctx.Dst, ctx.Src)
-			}
-			stats.MatVec++
-
-		case PSolve, PSolveTrans:
-			if settings.PSolve == nil {
-				copy(ctx.Dst, ctx.Src)
-				continue
-			}
-			if op == PSolve {
-				err = settings.PSolve(ctx.Dst, ctx.Src)
-			} else {
-				err = settings.PSolveTrans(ctx.Dst, ctx.Src)
-			}
-			if err != nil {
-				return err
-			}
-			stats.PSolve++
-
-		case CheckResidualNorm:
-			// TODO(vladimir-ch): This is currently not
-			// used because ctx.X is not guaranteed to be
-			// valid when this operation is requested.
-			// There is also the question of in which norm
-			// x should be measured (and similarly for b).
-			//
-			// if settings.NormA != 0 {
-			// 	xnorm := floats.Norm(ctx.X, 2)
-			// 	ctx.Converged = ctx.ResidualNorm/(settings.NormA*xnorm+bnorm) < settings.Tolerance
-			// } else {
-			// 	ctx.Converged = ctx.ResidualNorm/bnorm < settings.Tolerance
-			// }
-			ctx.Converged = ctx.ResidualNorm/bnorm < settings.Tolerance
-
-		case EndIteration:
-			stats.Iterations++
-			stats.ResidualNorm = ctx.ResidualNorm
-			if ctx.Converged {
-				return nil
-			}
-			if stats.Iterations == settings.MaxIterations {
-				return errors.New("iterative: iteration limit reached")
-			}
-
-		default:
-			panic("iterate: invalid operation")
-		}
+	norm := ctx.ResidualNorm
+	if math.Float64bits(norm) == unsetNormBits {
+		return fmt.Errorf("%v with stale ResidualNorm", op)
+	}
+	if math.IsNaN(norm) || math.IsInf(norm, 0) || norm < 0 {
+		return fmt.Errorf("%v with invalid ResidualNorm %v", op, norm)
 	}
+	return nil
 }
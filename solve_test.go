@@ -0,0 +1,456 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+func TestLinearSolveInPlace(t *testing.T) {
+	const n = 100
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	b := make([]float64, n)
+	a.MatVec(b, ones(n))
+	for _, test := range []struct {
+		name    string
+		new     func() Method
+		maxIter int
+		fail    bool
+	}{
+		{"CG", func() Method { return &CG{} }, 0, false},
+		{"BiCGSTAB", func() Method { return &BiCGSTAB{} }, 0, false},
+		{"GMRES(5)", func() Method { return &GMRES{Restart: 5} }, 0, false},
+		// Solves that stop at the iteration limit.
+		{"CG", func() Method { return &CG{} }, 3, true},
+		{"BiCG", func() Method { return &BiCG{} }, 3, true},
+		{"GMRES(5)", func() Method { return &GMRES{Restart: 5} }, 7, true},
+	} {
+		x0 := make([]float64, n)
+		for i := range x0 {
+			x0[i] = math.Sin(float64(i))
+		}
+		settings := Settings{
+			X0:            append([]float64(nil), x0...),
+			Tolerance:     1e-10,
+			MaxIterations: test.maxIter,
+		}
+		want, wantErr := LinearSolve(a, b, test.new(), settings)
+
+		settings.InPlace = true
+		got, err := LinearSolve(a, b, test.new(), settings)
+		if (err != nil) != test.fail || (wantErr != nil) != test.fail {
+			t.Errorf("%v: unexpected errors %v and %v", test.name, wantErr, err)
+		}
+		if &got.X[0] != &settings.X0[0] {
+			t.Errorf("%v: Result.X does not share X0", test.name)
+		}
+		// On failure, X0 holds the last
+		// approximate solution, the same as
+		// Result.X of the solve with a copy.
+		if !floats.Equal(settings.X0, want.X) {
+			t.Errorf("%v: X0 differs from the solution of the solve with a copy", test.name)
+		}
+		if got.Stats.Iterations != want.Stats.Iterations || got.Stats.MatVec != want.Stats.MatVec {
+			t.Errorf("%v: unexpected stats: want %+v, got %+v", test.name, want.Stats, got.Stats)
+		}
+	}
+
+	// The solution is not allocated.
+	x0 := make([]float64, n)
+	method := &CG{}
+	allocs := func(inPlace bool) float64 {
+		return testing.AllocsPerRun(5, func() {
+			for i := range x0 {
+				x0[i] = 0
+			}
+			LinearSolve(a, b, method, Settings{X0: x0, InPlace: inPlace})
+		})
+	}
+	if copied, inPlace := allocs(false), allocs(true); inPlace != copied-1 {
+		t.Errorf("unexpected allocations: %v with a copy, %v in place", copied, inPlace)
+	}
+
+	if !panics(func() { LinearSolve(a, b, &CG{}, Settings{InPlace: true}) }) {
+		t.Errorf("expected panic with InPlace and nil X0")
+	}
+}
+
+// faultyMethod commands the operations in ops in turn after calling the
+// corresponding setup function, which can corrupt the Context. Then it
+// commands EndIteration.
+type faultyMethod struct {
+	ops   []Operation
+	setup []func(ctx *Context, buf []float64)
+	buf   []float64
+	i     int
+}
+
+func (m *faultyMethod) Init(dim int) {
+	m.buf = make([]float64, dim)
+	m.i = 0
+}
+
+func (m *faultyMethod) Iterate(ctx *Context) (Operation, error) {
+	if m.i == len(m.ops) {
+		return EndIteration, nil
+	}
+	ctx.Src = nil
+	ctx.Dst = nil
+	m.setup[m.i](ctx, m.buf)
+	op := m.ops[m.i]
+	m.i++
+	return op, nil
+}
+
+func TestLinearSolveFaultyMethod(t *testing.T) {
+	const n = 10
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	b := ones(n)
+	p := DiagonalInverse(ones(n))
+	valid := func(ctx *Context, buf []float64) {
+		ctx.Src = ctx.Residual
+		ctx.Dst = buf
+	}
+	setNorm := func(ctx *Context, _ []float64) {
+		ctx.ResidualNorm = 1
+	}
+	for _, test := range []struct {
+		name  string
+		ops   []Operation
+		setup []func(ctx *Context, buf []float64)
+		debug bool // The fault is detected only in debug mode.
+		want  string
+	}{
+		{
+			name:  "nil Src",
+			ops:   []Operation{MatVec},
+			setup: []func(*Context, []float64){func(ctx *Context, buf []float64) { ctx.Dst = buf }},
+			want:  "iterative: *iterative.faultyMethod: MatVec with nil Src",
+		},
+		{
+			name:  "nil Dst",
+			ops:   []Operation{MatTransVec},
+			setup: []func(*Context, []float64){func(ctx *Context, _ []float64) { ctx.Src = ctx.Residual }},
+			want:  "iterative: *iterative.faultyMethod: MatTransVec with nil Dst",
+		},
+		{
+			name:  "short Src",
+			ops:   []Operation{PSolve},
+			setup: []func(*Context, []float64){func(ctx *Context, buf []float64) { ctx.Src, ctx.Dst = buf[1:], buf }},
+			want:  "iterative: *iterative.faultyMethod: PSolve with Src of length 9, want 10",
+		},
+		{
+			name:  "long Dst",
+			ops:   []Operation{PSolveTrans},
+			setup: []func(*Context, []float64){func(ctx *Context, _ []float64) { ctx.Src, ctx.Dst = ctx.Residual, make([]float64, n+1) }},
+			want:  "iterative: *iterative.faultyMethod: PSolveTrans with Dst of length 11, want 10",
+		},
+		{
+			name:  "short X",
+			ops:   []Operation{ComputeResidual},
+			setup: []func(*Context, []float64){func(ctx *Context, _ []float64) { ctx.X = ctx.X[:n-1] }},
+			want:  "iterative: *iterative.faultyMethod: ComputeResidual with X of length 9, want 10",
+		},
+		{
+			name:  "NaN ResidualNorm",
+			ops:   []Operation{CheckResidualNorm},
+			setup: []func(*Context, []float64){func(ctx *Context, _ []float64) { ctx.ResidualNorm = math.NaN() }},
+			debug: true,
+			want:  "iterative: *iterative.faultyMethod: CheckResidualNorm with invalid ResidualNorm NaN",
+		},
+		{
+			name:  "negative ResidualNorm",
+			ops:   []Operation{CheckResidualNorm},
+			setup: []func(*Context, []float64){func(ctx *Context, _ []float64) { ctx.ResidualNorm = -1 }},
+			debug: true,
+			want:  "iterative: *iterative.faultyMethod: CheckResidualNorm with invalid ResidualNorm -1",
+		},
+		{
+			name:  "stale ResidualNorm",
+			ops:   []Operation{MatVec, CheckResidualNorm, EndIteration, CheckResidualNorm},
+			setup: []func(*Context, []float64){valid, setNorm, func(*Context, []float64) {}, func(*Context, []float64) {}},
+			debug: true,
+			want:  "iterative: *iterative.faultyMethod: CheckResidualNorm with stale ResidualNorm",
+		},
+	} {
+		for _, debug := range []bool{false, true} {
+			method := &faultyMethod{ops: test.ops, setup: test.setup}
+			res, err := LinearSolve(a, b, method, Settings{
+				MaxIterations: 2,
+				PSolve:        p.Apply,
+				PSolveTrans:   p.ApplyTrans,
+				Debug:         debug,
+			})
+			if !debug && test.debug {
+				if err != nil && err.Error() == test.want {
+					t.Errorf("%v: fault detected without debug", test.name)
+				}
+				continue
+			}
+			if err == nil || err.Error() != test.want {
+				t.Errorf("%v, debug=%v: unexpected error: want %q, got %v", test.name, debug, test.want, err)
+			}
+			if res.Stats.MatVec != 0 && test.name != "stale ResidualNorm" {
+				t.Errorf("%v: faulty operation performed", test.name)
+			}
+		}
+	}
+
+	// The methods of the package pass the
+	// debug checks.
+	for _, method := range []Method{&CG{}, &BiCG{}, &BiCGSTAB{}, &PipelinedBiCGSTAB{Replace: 2}, &GMRES{Restart: 3}} {
+		_, err := LinearSolve(a, b, method, Settings{Tolerance: 1e-12, Debug: true})
+		if err != nil {
+			t.Errorf("%T: unexpected error in debug mode: %v", method, err)
+		}
+	}
+}
+
+// TestLinearSolveEstimateNormA checks that Settings.EstimateNormA uses the
+// stopping criterion with the estimated |A| and counts the products of the
+// estimate.
+func TestLinearSolveEstimateNormA(t *testing.T) {
+	const n = 200
+	a := diagDominant(n)
+	b := make([]float64, n)
+	a.MatVec(b, ones(n))
+	settings := Settings{Tolerance: 1e-8}
+
+	plain, err := LinearSolve(a, b, &CG{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings.EstimateNormA = true
+	ins := Instrumented(a)
+	got, err := LinearSolve(ins.MatrixOps, b, &CG{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := ins.Snapshot()
+	if got.Stats.MatVec != calls.MatVec+calls.MatTransVec {
+		t.Errorf("products of the estimate not counted: Stats.MatVec=%d, %d calls", got.Stats.MatVec, calls.MatVec+calls.MatTransVec)
+	}
+
+	// The same solve with NormA set explicitly.
+	ins.Reset()
+	normA := EstimateTwoNorm(ins.MatrixOps, n, 20, rand.New(rand.NewSource(1)))
+	estimate := ins.Snapshot()
+	// The largest eigenvalues of A are clustered
+	// below 6, the estimate is a lower bound.
+	if normA > 6 || normA < 5.5 {
+		t.Errorf("unexpected estimate of |A|: %v, want about 6", normA)
+	}
+	settings.EstimateNormA = false
+	settings.NormA = normA
+	want, err := LinearSolve(a, b, &CG{}, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Stats.Iterations != want.Stats.Iterations || !floats.Equal(got.X, want.X) {
+		t.Errorf("solve differs from the one with NormA set explicitly")
+	}
+	if got.Stats.MatVec != want.Stats.MatVec+estimate.MatVec+estimate.MatTransVec {
+		t.Errorf("unexpected number of products: %d, want %d plus %d of the estimate", got.Stats.MatVec, want.Stats.MatVec, estimate.MatVec+estimate.MatTransVec)
+	}
+
+	// With |A|*|x| = 60 > |b| ≈ 28, the criterion
+	// is looser and the solve stops earlier.
+	if got.Stats.Iterations >= plain.Stats.Iterations {
+		t.Errorf("EstimateNormA did not stop earlier: %d iterations, %d without", got.Stats.Iterations, plain.Stats.Iterations)
+	}
+	bnorm := floats.Norm(b, 2)
+	if r := got.Stats.ResidualNorm; r < settings.Tolerance*bnorm {
+		t.Errorf("residual norm %v satisfies the criterion without |A|", r)
+	}
+}
+
+func TestLinearSolveScaling(t *testing.T) {
+	const n = 100
+	a := diagDominant(n)
+	a.MatTransVec = a.MatVec
+	b1 := make([]float64, n)
+	a.MatVec(b1, ones(n))
+	for _, method := range []struct {
+		name string
+		new  func() Method
+	}{
+		{"CG", func() Method { return &CG{} }},
+		{"BiCG", func() Method { return &BiCG{} }},
+		{"BiCGSTAB", func() Method { return &BiCGSTAB{} }},
+		{"GMRES", func() Method { return &GMRES{} }},
+	} {
+		settings := Settings{Tolerance: 1e-10}
+		want, err := LinearSolve(a, b1, method.new(), settings)
+		if err != nil {
+			t.Fatalf("%v: %v", method.name, err)
+		}
+		for _, mag := range []float64{1e155, 1e-155} {
+			// The solves of the systems with b
+			// scaled by mag must be the same up to
+			// rounding and the scaling.
+			b := make([]float64, n)
+			floats.ScaleTo(b, mag, b1)
+			got, err := LinearSolve(a, b, method.new(), settings)
+			if err != nil {
+				t.Errorf("%v, b scaled by %v: unexpected error: %v", method.name, mag, err)
+				continue
+			}
+			if got.Stats.Iterations != want.Stats.Iterations {
+				t.Errorf("%v, b scaled by %v: %d iterations, want %d", method.name, mag, got.Stats.Iterations, want.Stats.Iterations)
+			}
+			for i, v := range got.X {
+				if math.Abs(v/mag-1) > 1e-8 {
+					t.Errorf("%v, b scaled by %v: inaccurate solution at %d: %v", method.name, mag, i, v)
+					break
+				}
+			}
+			if r := got.Stats.ResidualNorm / mag; math.Abs(r-want.Stats.ResidualNorm) > 1e-6*want.Stats.ResidualNorm {
+				t.Errorf("%v, b scaled by %v: residual norm not in the original scaling: %v, want %v", method.name, mag, got.Stats.ResidualNorm, mag*want.Stats.ResidualNorm)
+			}
+
+			// The initial guess updated in place
+			// is returned in the original scaling.
+			x0 := make([]float64, n)
+			for i := range x0 {
+				x0[i] = mag / 2
+			}
+			got, err = LinearSolve(a, b, method.new(), Settings{X0: x0, InPlace: true, Tolerance: 1e-10})
+			if err != nil {
+				t.Errorf("%v, b scaled by %v, X0: unexpected error: %v", method.name, mag, err)
+				continue
+			}
+			if &got.X[0] != &x0[0] || math.Abs(x0[0]/mag-1) > 1e-8 {
+				t.Errorf("%v, b scaled by %v, X0: solution not in the original scaling: %v", method.name, mag, x0[0])
+			}
+		}
+	}
+}
+
+// rectangular returns the m×n sparse matrix with the elements 3 at
+// (i, i mod n) and random elements elsewhere, and its dense copy.
+func rectangular(m, n int, rnd *rand.Rand) (*sparse.CSR, *mat.Dense) {
+	t := sparse.NewTriplet(m, n)
+	d := mat.NewDense(m, n, nil)
+	for i := 0; i < m; i++ {
+		t.Append(i, i%n, 3)
+		d.Set(i, i%n, 3)
+	}
+	for k := 0; k < 2*max(m, n); k++ {
+		i, j := rnd.Intn(m), rnd.Intn(n)
+		v := rnd.NormFloat64()
+		t.Append(i, j, v)
+		d.Set(i, j, d.At(i, j)+v)
+	}
+	return sparse.NewCSRFromTriplet(t), d
+}
+
+func TestLinearSolveRectangular(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		m, n int
+	}{
+		{40, 10},
+		{10, 40},
+	} {
+		m, n := test.m, test.n
+		a, d := rectangular(m, n, rnd)
+		ops := MatrixOps{MatVec: a.MulVec, MatTransVec: a.MulTransVec, Rows: m, Cols: n}
+		b := make([]float64, m)
+		for i := range b {
+			b[i] = rnd.NormFloat64()
+		}
+
+		// The least-squares solution of the
+		// overdetermined system, the
+		// minimum-norm solution of the
+		// underdetermined one.
+		var want mat.VecDense
+		if m > n {
+			if err := want.SolveVec(d, mat.NewVecDense(m, b)); err != nil {
+				t.Fatal(err)
+			}
+		} else {
+			var aat mat.Dense
+			aat.Mul(d, d.T())
+			var y mat.VecDense
+			if err := y.SolveVec(&aat, mat.NewVecDense(m, b)); err != nil {
+				t.Fatal(err)
+			}
+			want.MulVec(d.T(), &y)
+		}
+
+		res, err := LinearSolve(ops, b, &Landweber{}, Settings{
+			Tolerance:     1e-10,
+			MaxIterations: 10000,
+		})
+		if err != nil {
+			t.Errorf("m=%d,n=%d: unexpected error: %v", m, n, err)
+			continue
+		}
+		if len(res.X) != n {
+			t.Errorf("m=%d,n=%d: solution of length %d", m, n, len(res.X))
+			continue
+		}
+		if dist := floats.Distance(res.X, want.RawVector().Data, math.Inf(1)); dist > 1e-6 {
+			t.Errorf("m=%d,n=%d: solution differs from the expected by %v", m, n, dist)
+		}
+
+		// Shape checks.
+		for _, bad := range []struct {
+			name string
+			f    func()
+		}{
+			{"b of length n", func() { LinearSolve(ops, make([]float64, n), &Landweber{}, Settings{}) }},
+			{"X0 of length m", func() { LinearSolve(ops, b, &Landweber{}, Settings{X0: make([]float64, m)}) }},
+			{"not a RectMethod", func() { LinearSolve(ops, b, &CG{}, Settings{}) }},
+			{"nil MatTransVec", func() {
+				LinearSolve(MatrixOps{MatVec: a.MulVec, Rows: m, Cols: n}, b, &Landweber{}, Settings{})
+			}},
+		} {
+			if !panics(bad.f) {
+				t.Errorf("m=%d,n=%d: no panic with %v", m, n, bad.name)
+			}
+		}
+	}
+
+	// The operations are checked against the
+	// dimensions of the 5×3 matrix.
+	ctx := &Context{X: make([]float64, 3), Residual: make([]float64, 5)}
+	for _, test := range []struct {
+		op       Operation
+		src, dst int
+		want     string
+	}{
+		{MatVec, 3, 5, ""},
+		{MatVec, 5, 5, "MatVec with Src of length 5, want 3"},
+		{MatTransVec, 5, 3, ""},
+		{MatTransVec, 5, 5, "MatTransVec with Dst of length 5, want 3"},
+		{PSolve, 3, 3, ""},
+		{PSolve, 5, 5, "PSolve with Src of length 5, want 3"},
+		{WeightVec, 5, 5, ""},
+		{WeightVec, 3, 3, "WeightVec with Src of length 3, want 5"},
+	} {
+		ctx.Src = make([]float64, test.src)
+		ctx.Dst = make([]float64, test.dst)
+		err := checkOperation(test.op, ctx, 5, 3)
+		if (err == nil) != (test.want == "") || (err != nil && err.Error() != test.want) {
+			t.Errorf("%v: unexpected error %v, want %q", test.op, err, test.want)
+		}
+	}
+	ctx.X = make([]float64, 5)
+	if err := checkOperation(EndIteration, ctx, 5, 3); err == nil {
+		t.Errorf("no error with X of length 5")
+	}
+}
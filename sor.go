@@ -0,0 +1,349 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// SOR implements the successive over-relaxation iteration
+//  x_i = x_{i-1} + (D/ω + L)^{-1} (b - A x_{i-1})
+// for solving the system of linear equations
+//  Ax = b,
+// where A = L + D + U is split into its strictly lower triangle, its
+// diagonal and its strictly upper triangle, and ω is Omega. With ω = 1 it
+// is the Gauss-Seidel iteration.
+//
+// The triangular solve with D/ω + L is not a matrix-vector product, SOR
+// issues it as the PSolve operation, so Settings.PSolve must solve
+//  (D/ω + L) z = r
+// with the same ω, for example the Apply method of the Preconditioner
+// returned by Splitting. With another preconditioner M the method is the
+// iteration x_i = x_{i-1} + M^{-1} r_{i-1}.
+//
+// For a symmetric positive definite A the iteration converges for
+// 0 < ω < 2. On the discrete Laplacian Gauss-Seidel needs half the
+// iterations of Jacobi, and SOR with the optimal ω needs asymptotically
+// the square root of their number.
+//
+// SOR needs MatVec and PSolve matrix operations.
+type SOR struct {
+	// Omega is the relaxation parameter ω.
+	// If it is zero, 1 is used. It must be
+	// in the interval (0, 2).
+	Omega float64
+
+	resume int
+
+	z  []float64
+	az []float64
+}
+
+// Init implements the Method interface.
+func (s *SOR) Init(dim int) {
+	if dim <= 0 {
+		panic("SOR: dimension not positive")
+	}
+	if s.Omega < 0 || 2 <= s.Omega {
+		panic("SOR: relaxation parameter out of range")
+	}
+
+	s.z = reuse(s.z, dim)
+	s.az = reuse(s.az, dim)
+	s.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (s *SOR) Iterate(ctx *Context) (Operation, error) {
+	switch s.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = s.z
+		s.resume = 2
+		return PSolve, nil
+		// Solve (D/ω + L) z = r_{i-1}
+	case 2:
+		ctx.vec.add(ctx.X, s.z) // x_i = x_{i-1} + z
+		ctx.Src = s.z
+		ctx.Dst = s.az
+		s.resume = 3
+		return MatVec, nil
+		// Compute Az
+	case 3:
+		rr := ctx.vec.axpyDot(-1, s.az, ctx.Residual) // r_i = r_{i-1} - Az
+		ctx.ResidualNorm = math.Sqrt(rr)
+		if math.IsNaN(ctx.ResidualNorm) || math.IsInf(ctx.ResidualNorm, 0) {
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, errors.New("SOR: divergence")
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		s.resume = 4
+		return CheckResidualNorm, nil
+	case 4:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		s.resume = 1
+		return EndIteration, nil
+
+	default:
+		panic("SOR: Init not called")
+	}
+}
+
+// Splitting returns the Preconditioner M = D/ω + L of the square matrix a
+// with ω = Omega, whose Apply method is the PSolve needed by SOR. It
+// returns an error if a diagonal element of a is zero. The Preconditioner
+// refers to a, which must not be modified while it is used.
+func (s *SOR) Splitting(a *sparse.CSR) (Preconditioner, error) {
+	omega := s.Omega
+	if omega == 0 {
+		omega = 1
+	}
+	if omega < 0 || 2 <= omega {
+		panic("SOR: relaxation parameter out of range")
+	}
+	return newSORSplitting(a, omega)
+}
+
+// sorSplitting is the lower triangular preconditioner
+//  M = D/ω + L
+// of a matrix A = L + D + U.
+type sorSplitting struct {
+	a *sparse.CSR
+	// diag is D/ω.
+	diag []float64
+}
+
+// newSORSplitting returns the SOR splitting of a with the relaxation
+// parameter omega. It returns an error if a diagonal element of a is zero.
+func newSORSplitting(a *sparse.CSR, omega float64) (*sorSplitting, error) {
+	if r, c := a.Dims(); r != c {
+		panic("iterative: matrix not square")
+	}
+	d := sparse.Diagonal(a)
+	for i, v := range d {
+		if v == 0 {
+			return nil, fmt.Errorf("iterative: diagonal element %d is zero", i)
+		}
+		d[i] = v / omega
+	}
+	return &sorSplitting{a: a, diag: d}, nil
+}
+
+// Apply implements the Preconditioner interface.
+func (p *sorSplitting) Apply(dst, rhs []float64) error {
+	n := len(p.diag)
+	checkLen(dst, rhs, n)
+	// Solve (D/ω + L) z = rhs by forward
+	// substitution.
+	for i := 0; i < n; i++ {
+		ind, data := p.a.RowView(i)
+		s := rhs[i]
+		for k, j := range ind {
+			if j >= i {
+				break
+			}
+			s -= data[k] * dst[j]
+		}
+		dst[i] = s / p.diag[i]
+	}
+	return nil
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p *sorSplitting) ApplyTrans(dst, rhs []float64) error {
+	n := len(p.diag)
+	checkLen(dst, rhs, n)
+	copy(dst, rhs)
+	// Solve (D/ω + L^T) z = rhs by backward
+	// substitution with the rows of L as
+	// columns of L^T.
+	for i := n - 1; i >= 0; i-- {
+		dst[i] /= p.diag[i]
+		ind, data := p.a.RowView(i)
+		for k, j := range ind {
+			if j >= i {
+				break
+			}
+			dst[j] -= data[k] * dst[i]
+		}
+	}
+	return nil
+}
+
+// ColoredSplitting returns the Preconditioner M = D/ω + L_c of the square
+// matrix a with ω = Omega for the multicolor SOR iteration, where L_c holds
+// the elements a[i,j] with colors[i] > colors[j]. colors is a coloring of
+// the rows of a in which coupled rows have different colors, for example
+// computed by sparse.ColorRows. The forward substitution with M is the
+// Gauss-Seidel sweep that updates the colors in increasing order, so the
+// rows within a color are computed concurrently by threads goroutines. If
+// threads is negative, runtime.GOMAXPROCS(0) is used, if it is 0 or 1, the
+// sweeps are serial.
+//
+// With the natural ordering of the colors the multicolor iteration is the
+// SOR iteration on the matrix reordered by colors, so it generally needs
+// somewhat more iterations than with the natural ordering of the rows, but
+// each sweep runs in parallel.
+//
+// ColoredSplitting returns an error if a diagonal element of a is zero or
+// if two coupled rows have the same color. The Preconditioner refers to a,
+// which must not be modified while it is used.
+func (s *SOR) ColoredSplitting(a *sparse.CSR, colors []int, threads int) (Preconditioner, error) {
+	omega := s.Omega
+	if omega == 0 {
+		omega = 1
+	}
+	if omega < 0 || 2 <= omega {
+		panic("SOR: relaxation parameter out of range")
+	}
+	return newColoredSORSplitting(a, omega, colors, threads)
+}
+
+// coloredSORSplitting is the multicolor SOR splitting
+//  M = D/ω + L_c
+// of a matrix A = L_c + D + U_c, where L_c holds the elements of A in the
+// rows of higher colors than their columns.
+type coloredSORSplitting struct {
+	a *sparse.CSR
+	// at is A^T, the rows of L_c^T are
+	// the rows of at restricted to the
+	// columns of higher colors.
+	at *sparse.CSR
+	// diag is D/ω.
+	diag   []float64
+	colors []int
+	// The rows of color c are
+	// rows[start[c]:start[c+1]].
+	rows  []int
+	start []int
+
+	threads int
+}
+
+// newColoredSORSplitting returns the multicolor SOR splitting of a with the
+// relaxation parameter omega. It returns an error if a diagonal element of
+// a is zero or if colors is not a valid coloring of a.
+func newColoredSORSplitting(a *sparse.CSR, omega float64, colors []int, threads int) (*coloredSORSplitting, error) {
+	sor, err := newSORSplitting(a, omega)
+	if err != nil {
+		return nil, err
+	}
+	n := len(sor.diag)
+	if len(colors) != n {
+		panic("iterative: mismatched length of colors")
+	}
+	var numColors int
+	for i, c := range colors {
+		if c < 0 {
+			panic("iterative: negative color")
+		}
+		if c+1 > numColors {
+			numColors = c + 1
+		}
+		ind, _ := a.RowView(i)
+		for _, j := range ind {
+			if j != i && colors[j] == c {
+				return nil, fmt.Errorf("iterative: coupled rows %d and %d of the same color", i, j)
+			}
+		}
+	}
+	if threads < 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	p := &coloredSORSplitting{
+		a:       a,
+		at:      sparse.Transpose(a),
+		diag:    sor.diag,
+		colors:  colors,
+		rows:    make([]int, n),
+		start:   make([]int, numColors+1),
+		threads: threads,
+	}
+	// Sort the rows by color keeping their
+	// order within a color.
+	for _, c := range colors {
+		p.start[c+1]++
+	}
+	for c := 0; c < numColors; c++ {
+		p.start[c+1] += p.start[c]
+	}
+	next := make([]int, numColors)
+	copy(next, p.start)
+	for i, c := range colors {
+		p.rows[next[c]] = i
+		next[c]++
+	}
+	return p, nil
+}
+
+// Apply implements the Preconditioner interface.
+func (p *coloredSORSplitting) Apply(dst, rhs []float64) error {
+	checkLen(dst, rhs, len(p.diag))
+	// Solve (D/ω + L_c) z = rhs color by color
+	// in increasing order.
+	for c := 0; c < len(p.start)-1; c++ {
+		p.sweep(dst, rhs, p.a, c, true)
+	}
+	return nil
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p *coloredSORSplitting) ApplyTrans(dst, rhs []float64) error {
+	checkLen(dst, rhs, len(p.diag))
+	// Solve (D/ω + L_c^T) z = rhs color by color
+	// in decreasing order.
+	for c := len(p.start) - 2; c >= 0; c-- {
+		p.sweep(dst, rhs, p.at, c, false)
+	}
+	return nil
+}
+
+// sweep computes the elements of dst for the rows of color c from the
+// rows of m restricted to the columns of lower colors if lower is true,
+// and of higher colors otherwise.
+func (p *coloredSORSplitting) sweep(dst, rhs []float64, m *sparse.CSR, c int, lower bool) {
+	rows := p.rows[p.start[c]:p.start[c+1]]
+	update := func(rows []int) {
+		for _, i := range rows {
+			ind, data := m.RowView(i)
+			s := rhs[i]
+			for k, j := range ind {
+				if cj := p.colors[j]; (lower && cj < c) || (!lower && cj > c) {
+					s -= data[k] * dst[j]
+				}
+			}
+			dst[i] = s / p.diag[i]
+		}
+	}
+	n := p.threads
+	if n > len(rows) {
+		n = len(rows)
+	}
+	if n <= 1 {
+		update(rows)
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for w := 0; w < n; w++ {
+		go func(w int) {
+			update(rows[w*len(rows)/n : (w+1)*len(rows)/n])
+			wg.Done()
+		}(w)
+	}
+	wg.Wait()
+}
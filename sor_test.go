@@ -0,0 +1,195 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat"
+)
+
+func TestSOR(t *testing.T) {
+	for _, n := range []int{5, 10, 20} {
+		p := testmat.Poisson2D(n, n)
+		a := p.CSR()
+		x, b := p.Manufactured()
+		ops := iterative.MatrixOps{MatVec: a.MulVec}
+		settings := iterative.Settings{
+			Tolerance:     1e-8,
+			MaxIterations: 100000,
+		}
+		solve := func(method iterative.Method, pc iterative.Preconditioner) int {
+			settings.PSolve = pc.Apply
+			res, err := iterative.LinearSolve(ops, b, method, settings)
+			if err != nil {
+				t.Errorf("n=%d: %T: unexpected error: %v", n, method, err)
+				return 0
+			}
+			if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-6 {
+				t.Errorf("n=%d: %T: solution differs by %v", n, method, d)
+			}
+			return res.Stats.Iterations
+		}
+
+		jacobi := solve(&iterative.Jacobi{Damping: 1}, iterative.DiagonalInverse(sparse.Diagonal(a)))
+
+		gs := &iterative.SOR{}
+		pc, err := gs.Splitting(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gaussSeidel := solve(gs, pc)
+		// Gauss-Seidel needs about half the
+		// iterations of Jacobi.
+		if r := float64(gaussSeidel) / float64(jacobi); r < 0.4 || 0.6 < r {
+			t.Errorf("n=%d: Gauss-Seidel needs %d iterations, Jacobi %d", n, gaussSeidel, jacobi)
+		}
+
+		// The optimal relaxation parameter of
+		// the model problem.
+		sor := &iterative.SOR{Omega: 2 / (1 + math.Sin(math.Pi/float64(n+1)))}
+		pc, err = sor.Splitting(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if optimal := solve(sor, pc); 2*optimal >= gaussSeidel {
+			t.Errorf("n=%d: SOR with the optimal ω needs %d iterations, Gauss-Seidel %d", n, optimal, gaussSeidel)
+		}
+	}
+}
+
+func TestSORSplitting(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	a := testmat.RandomSparse(50, 5, 10, 1, rnd)
+	pc, err := (&iterative.SOR{Omega: 1.3}).Splitting(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := sparse.Diagonal(a)
+
+	// M z = x for M = D/ω + L.
+	x := make([]float64, 50)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	z := make([]float64, 50)
+	pc.Apply(z, x)
+	mz := make([]float64, 50)
+	for i := range mz {
+		ind, data := a.RowView(i)
+		for k, j := range ind {
+			if j < i {
+				mz[i] += data[k] * z[j]
+			}
+		}
+		mz[i] += d[i] / 1.3 * z[i]
+	}
+	if dist := floats.Distance(mz, x, math.Inf(1)); dist > 1e-12 {
+		t.Errorf("Apply: residual %v", dist)
+	}
+
+	// y·M^{-1}x = M^{-T}y·x.
+	y := make([]float64, 50)
+	for i := range y {
+		y[i] = rnd.NormFloat64()
+	}
+	w := make([]float64, 50)
+	pc.ApplyTrans(w, y)
+	if got, want := floats.Dot(w, x), floats.Dot(y, z); math.Abs(got-want) > 1e-12*math.Abs(want) {
+		t.Errorf("ApplyTrans: inconsistent with Apply, %v != %v", got, want)
+	}
+
+	zero := sparse.NewTriplet(2, 2)
+	zero.Append(0, 0, 1)
+	zero.Append(1, 0, 1)
+	if _, err := (&iterative.SOR{}).Splitting(sparse.NewCSRFromTriplet(zero)); err == nil {
+		t.Errorf("no error with zero diagonal element")
+	}
+}
+
+// colorOrder returns the permutation that orders the rows by their colors
+// keeping their order within a color.
+func colorOrder(colors []int) []int {
+	perm := make([]int, len(colors))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.SliceStable(perm, func(p, q int) bool { return colors[perm[p]] < colors[perm[q]] })
+	return perm
+}
+
+// testColoredSplitting checks that the multicolor splitting colored
+// applies the splitting plain of the matrix reordered by perm.
+func testColoredSplitting(t *testing.T, name string, colored, plain iterative.Preconditioner, perm []int, rnd *rand.Rand) {
+	n := len(perm)
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	px := make([]float64, n)
+	sparse.PermuteVec(px, x, perm)
+	for _, apply := range []struct {
+		name           string
+		colored, plain func(dst, rhs []float64) error
+	}{
+		{"Apply", colored.Apply, plain.Apply},
+		{"ApplyTrans", colored.ApplyTrans, plain.ApplyTrans},
+	} {
+		pz := make([]float64, n)
+		apply.plain(pz, px)
+		want := make([]float64, n)
+		sparse.UnpermuteVec(want, pz, perm)
+
+		got := make([]float64, n)
+		apply.colored(got, x)
+		if d := floats.Distance(got, want, math.Inf(1)); d > 1e-13*floats.Norm(want, math.Inf(1)) {
+			t.Errorf("%s: %s differs from the reordered splitting by %v", name, apply.name, d)
+		}
+		// In place.
+		copy(got, x)
+		apply.colored(got, got)
+		if d := floats.Distance(got, want, math.Inf(1)); d > 1e-13*floats.Norm(want, math.Inf(1)) {
+			t.Errorf("%s: %s in place differs from the reordered splitting by %v", name, apply.name, d)
+		}
+	}
+}
+
+func TestSORColoredSplitting(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 10, 50, 200} {
+		a := testmat.RandomSparse(n, 5, 10, 0.5, rnd)
+		colors, _ := sparse.ColorRows(a)
+		perm := colorOrder(colors)
+		for _, omega := range []float64{0, 1.3} {
+			s := &iterative.SOR{Omega: omega}
+			plain, err := s.Splitting(sparse.Permute(a, perm, perm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, threads := range []int{1, 4} {
+				colored, err := s.ColoredSplitting(a, colors, threads)
+				if err != nil {
+					t.Fatal(err)
+				}
+				name := fmt.Sprintf("n=%d,omega=%v,threads=%d", n, omega, threads)
+				testColoredSplitting(t, name, colored, plain, perm, rnd)
+			}
+		}
+	}
+
+	// A coloring of another matrix.
+	a := testmat.Poisson2D(5, 5).CSR()
+	if _, err := (&iterative.SOR{}).ColoredSplitting(a, make([]int, 25), 1); err == nil {
+		t.Errorf("no error with coupled rows of the same color")
+	}
+}
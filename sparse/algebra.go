@@ -0,0 +1,121 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+// Transpose returns a new CSR matrix that is the transpose of a.
+func Transpose(a *CSR) *CSR {
+	indptr, ind, data := transpose(a.r, a.c, a.indptr, a.ind, a.data)
+	return &CSR{r: a.c, c: a.r, indptr: indptr, ind: ind, data: data}
+}
+
+// Add returns a new CSR matrix
+//  alpha*a + beta*b.
+// The sparsity pattern of the result is the union of the patterns of a and
+// b, elements that cancel out are stored as explicit zeros. Add panics if a
+// and b do not have the same dimensions.
+func Add(alpha float64, a *CSR, beta float64, b *CSR) *CSR {
+	if a.r != b.r || a.c != b.c {
+		panic("sparse: dimension mismatch")
+	}
+	m := &CSR{
+		r:      a.r,
+		c:      a.c,
+		indptr: make([]int, a.r+1),
+		ind:    make([]int, 0, len(a.ind)+len(b.ind)),
+		data:   make([]float64, 0, len(a.data)+len(b.data)),
+	}
+	for i := 0; i < a.r; i++ {
+		ka, enda := a.indptr[i], a.indptr[i+1]
+		kb, endb := b.indptr[i], b.indptr[i+1]
+		// Merge the sorted rows of a and b.
+		for ka < enda || kb < endb {
+			switch {
+			case kb == endb || (ka < enda && a.ind[ka] < b.ind[kb]):
+				m.ind = append(m.ind, a.ind[ka])
+				m.data = append(m.data, alpha*a.data[ka])
+				ka++
+			case ka == enda || b.ind[kb] < a.ind[ka]:
+				m.ind = append(m.ind, b.ind[kb])
+				m.data = append(m.data, beta*b.data[kb])
+				kb++
+			default:
+				m.ind = append(m.ind, a.ind[ka])
+				m.data = append(m.data, alpha*a.data[ka]+beta*b.data[kb])
+				ka++
+				kb++
+			}
+		}
+		m.indptr[i+1] = len(m.ind)
+	}
+	return m
+}
+
+// Scale returns a new CSR matrix
+//  alpha*a
+// with the same sparsity pattern as a.
+func Scale(alpha float64, a *CSR) *CSR {
+	m := &CSR{
+		r:      a.r,
+		c:      a.c,
+		indptr: make([]int, len(a.indptr)),
+		ind:    make([]int, len(a.ind)),
+		data:   make([]float64, len(a.data)),
+	}
+	copy(m.indptr, a.indptr)
+	copy(m.ind, a.ind)
+	for k, v := range a.data {
+		m.data[k] = alpha * v
+	}
+	return m
+}
+
+// AddDiagonal returns a new CSR matrix
+//  a + diag(d).
+// Diagonal elements that are not stored in a are inserted into the result,
+// so for example
+//  AddDiagonal(a, sigma*ones)
+// computes the shifted matrix a + sigma*I. The length of d must be equal to
+// the smaller of the dimensions of a.
+func AddDiagonal(a *CSR, d []float64) *CSR {
+	n := a.r
+	if a.c < n {
+		n = a.c
+	}
+	if len(d) != n {
+		panic("sparse: dimension mismatch")
+	}
+	m := &CSR{
+		r:      a.r,
+		c:      a.c,
+		indptr: make([]int, a.r+1),
+		ind:    make([]int, 0, len(a.ind)+n),
+		data:   make([]float64, 0, len(a.data)+n),
+	}
+	for i := 0; i < a.r; i++ {
+		inserted := i >= n
+		for k := a.indptr[i]; k < a.indptr[i+1]; k++ {
+			j := a.ind[k]
+			if !inserted && j >= i {
+				if j == i {
+					m.ind = append(m.ind, i)
+					m.data = append(m.data, a.data[k]+d[i])
+					inserted = true
+					continue
+				}
+				m.ind = append(m.ind, i)
+				m.data = append(m.data, d[i])
+				inserted = true
+			}
+			m.ind = append(m.ind, j)
+			m.data = append(m.data, a.data[k])
+		}
+		if !inserted {
+			m.ind = append(m.ind, i)
+			m.data = append(m.data, d[i])
+		}
+		m.indptr[i+1] = len(m.ind)
+	}
+	return m
+}
@@ -0,0 +1,126 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var algebraDims = []struct {
+	r, c, nnz int
+}{
+	{0, 0, 0},
+	{1, 1, 0},
+	{1, 1, 1},
+	{3, 5, 4},
+	{5, 3, 4},
+	{10, 10, 30},
+	{20, 7, 50},
+	{7, 20, 50},
+}
+
+func TestTranspose(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		want := make([]float64, r*c)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				want[j*r+i] = dense[i*c+j]
+			}
+		}
+		if s := testCSRDense(Transpose(a), c, r, want); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected %v of transpose", r, c, s)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, da := randomCSR(r, c, test.nnz, rnd)
+		b, db := randomCSR(r, c, test.nnz, rnd)
+		for _, alpha := range []float64{0, 1, -2.5} {
+			for _, beta := range []float64{0, 1, 0.5} {
+				want := make([]float64, r*c)
+				for k := range want {
+					want[k] = alpha*da[k] + beta*db[k]
+				}
+				m := Add(alpha, a, beta, b)
+				if s := testCSRDense(m, r, c, want); s != "" {
+					t.Errorf("r=%v,c=%v,alpha=%v,beta=%v: unexpected %v of sum", r, c, alpha, beta, s)
+				}
+				if m.NNZ() > a.NNZ()+b.NNZ() {
+					t.Errorf("r=%v,c=%v: too many elements in sum", r, c)
+				}
+			}
+		}
+	}
+	if !panics(func() { Add(1, NewCSRFromTriplet(NewTriplet(2, 3)), 1, NewCSRFromTriplet(NewTriplet(3, 2))) }) {
+		t.Errorf("Add with mismatched dimensions did not panic")
+	}
+}
+
+func TestScale(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		for _, alpha := range []float64{0, 1, -3} {
+			want := make([]float64, r*c)
+			for k := range want {
+				want[k] = alpha * dense[k]
+			}
+			m := Scale(alpha, a)
+			if s := testCSRDense(m, r, c, want); s != "" {
+				t.Errorf("r=%v,c=%v,alpha=%v: unexpected %v of scaled matrix", r, c, alpha, s)
+			}
+			if m.NNZ() != a.NNZ() {
+				t.Errorf("r=%v,c=%v: unexpected NNZ of scaled matrix", r, c)
+			}
+		}
+		if s := testCSRDense(a, r, c, dense); s != "" {
+			t.Errorf("r=%v,c=%v: Scale modified its argument", r, c)
+		}
+	}
+}
+
+func TestAddDiagonal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		n := r
+		if c < n {
+			n = c
+		}
+		d := randomVec(n, rnd)
+		want := make([]float64, r*c)
+		copy(want, dense)
+		for i, v := range d {
+			want[i*c+i] += v
+		}
+		m := AddDiagonal(a, d)
+		if s := testCSRDense(m, r, c, want); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected %v of shifted matrix", r, c, s)
+		}
+		for i := 0; i < n; i++ {
+			ind, _ := m.RowView(i)
+			var found bool
+			for _, j := range ind {
+				found = found || j == i
+			}
+			if !found {
+				t.Errorf("r=%v,c=%v: diagonal element %v not stored", r, c, i)
+			}
+		}
+	}
+	if !panics(func() { AddDiagonal(NewCSRFromTriplet(NewTriplet(2, 3)), make([]float64, 3)) }) {
+		t.Errorf("AddDiagonal with mismatched length did not panic")
+	}
+}
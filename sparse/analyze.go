@@ -0,0 +1,161 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// StructureReport describes the structural and numerical properties of a
+// square sparse matrix that are relevant for the choice of an iterative
+// method and a preconditioner.
+type StructureReport struct {
+	// N is the dimension of the matrix and
+	// NNZ the number of stored elements.
+	N, NNZ int
+
+	// StructurallySymmetric is whether
+	// a[j,i] is stored for every stored
+	// a[i,j].
+	StructurallySymmetric bool
+	// Asymmetry is the relative measure of
+	// numerical asymmetry
+	//  |A-A^T|_F / |A|_F,
+	// zero for a symmetric matrix.
+	Asymmetry float64
+
+	// MinDominance is the minimum over rows
+	// of |a[i,i]| / sum_{j≠i} |a[i,j]|. The
+	// matrix is strictly diagonally dominant
+	// by rows if MinDominance > 1.
+	MinDominance float64
+	// DominantRows is the number of strictly
+	// diagonally dominant rows.
+	DominantRows int
+
+	// LowerBandwidth and UpperBandwidth are
+	// the maximum distances of a stored
+	// element below and above the diagonal.
+	LowerBandwidth, UpperBandwidth int
+
+	// MinRowNNZ, MaxRowNNZ and MeanRowNNZ
+	// describe the distribution of the
+	// number of stored elements per row.
+	MinRowNNZ, MaxRowNNZ int
+	MeanRowNNZ           float64
+
+	// ZeroDiagonals is the number of
+	// diagonal elements that are zero or
+	// not stored.
+	ZeroDiagonals int
+}
+
+// Symmetric returns whether the matrix is numerically symmetric within the
+// relative tolerance tol, that is, whether r.Asymmetry <= tol.
+func (r StructureReport) Symmetric(tol float64) bool {
+	return r.Asymmetry <= tol
+}
+
+// String returns a human-readable summary of the report.
+func (r StructureReport) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d×%d matrix with %d stored elements\n", r.N, r.N, r.NNZ)
+	fmt.Fprintf(&b, "symmetry: structural %v, |A-A^T|_F/|A|_F = %.3g\n", r.StructurallySymmetric, r.Asymmetry)
+	fmt.Fprintf(&b, "diagonal dominance: min ratio %.3g, %d of %d rows dominant\n", r.MinDominance, r.DominantRows, r.N)
+	fmt.Fprintf(&b, "bandwidth: lower %d, upper %d\n", r.LowerBandwidth, r.UpperBandwidth)
+	fmt.Fprintf(&b, "elements per row: min %d, max %d, mean %.3g\n", r.MinRowNNZ, r.MaxRowNNZ, r.MeanRowNNZ)
+	fmt.Fprintf(&b, "zero diagonal elements: %d", r.ZeroDiagonals)
+	return b.String()
+}
+
+// Analyze returns a report on the structure of the square matrix a. The
+// asymmetry is computed without forming A-A^T. Analyze panics if a is not
+// square.
+func Analyze(a *CSR) StructureReport {
+	if a.r != a.c {
+		panic("sparse: matrix not square")
+	}
+	n := a.r
+	r := StructureReport{
+		N:                     n,
+		NNZ:                   len(a.data),
+		StructurallySymmetric: true,
+		MinDominance:          math.Inf(1),
+	}
+	if n == 0 {
+		r.MinDominance = 0
+		return r
+	}
+	r.MinRowNNZ = len(a.data)
+	r.MeanRowNNZ = float64(len(a.data)) / float64(n)
+
+	at := Transpose(a)
+	var normA, normDiff float64
+	for i := 0; i < n; i++ {
+		ind, data := a.RowView(i)
+		if len(ind) < r.MinRowNNZ {
+			r.MinRowNNZ = len(ind)
+		}
+		if len(ind) > r.MaxRowNNZ {
+			r.MaxRowNNZ = len(ind)
+		}
+
+		var diag, off float64
+		for k, j := range ind {
+			v := data[k]
+			normA += v * v
+			if j == i {
+				diag = math.Abs(v)
+				continue
+			}
+			off += math.Abs(v)
+			if i-j > r.LowerBandwidth {
+				r.LowerBandwidth = i - j
+			}
+			if j-i > r.UpperBandwidth {
+				r.UpperBandwidth = j - i
+			}
+		}
+		if diag == 0 {
+			r.ZeroDiagonals++
+		}
+		if diag > off {
+			r.DominantRows++
+		}
+		dom := math.Inf(1)
+		if off > 0 {
+			dom = diag / off
+		}
+		r.MinDominance = math.Min(r.MinDominance, dom)
+
+		// Merge row i of A with row i of A^T.
+		tind, tdata := at.RowView(i)
+		ka, kt := 0, 0
+		for ka < len(ind) || kt < len(tind) {
+			var d float64
+			switch {
+			case kt == len(tind) || (ka < len(ind) && ind[ka] < tind[kt]):
+				d = data[ka]
+				ka++
+				r.StructurallySymmetric = false
+			case ka == len(ind) || tind[kt] < ind[ka]:
+				d = tdata[kt]
+				kt++
+				r.StructurallySymmetric = false
+			default:
+				d = data[ka] - tdata[kt]
+				ka++
+				kt++
+			}
+			normDiff += d * d
+		}
+	}
+	if normA > 0 {
+		r.Asymmetry = math.Sqrt(normDiff / normA)
+	}
+	return r
+}
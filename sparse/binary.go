@@ -0,0 +1,195 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// The binary format of CSR stores, in little-endian byte order,
+//  magic   [4]byte "GCSR"
+//  version uint32
+//  r, c    int64
+//  nnz     int64
+//  indptr  [r+1]int64
+//  ind     [nnz]int64
+//  data    [nnz]float64
+// where the floating-point values are stored as their IEEE 754 bits.
+const (
+	csrMagic   = "GCSR"
+	csrVersion = 1
+
+	csrHeaderSize = 4 + 4 + 3*8
+)
+
+var errBadBinary = errors.New("sparse: bad binary CSR data")
+
+// MarshalBinary encodes the matrix into a binary form and returns the
+// result.
+func (m *CSR) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(csrHeaderSize + 8*(len(m.indptr)+2*len(m.ind)))
+	_, err := m.MarshalBinaryTo(&buf)
+	return buf.Bytes(), err
+}
+
+// MarshalBinaryTo encodes the matrix into a binary form and writes it into
+// w. MarshalBinaryTo returns the number of bytes written into w and an
+// error, if any.
+func (m *CSR) MarshalBinaryTo(w io.Writer) (int, error) {
+	var hdr [csrHeaderSize]byte
+	copy(hdr[:], csrMagic)
+	binary.LittleEndian.PutUint32(hdr[4:], csrVersion)
+	binary.LittleEndian.PutUint64(hdr[8:], uint64(m.r))
+	binary.LittleEndian.PutUint64(hdr[16:], uint64(m.c))
+	binary.LittleEndian.PutUint64(hdr[24:], uint64(len(m.ind)))
+	n, err := w.Write(hdr[:])
+	if err != nil {
+		return n, err
+	}
+
+	// Encode the arrays in chunks to bound the size of the buffer.
+	buf := make([]byte, 8*1024)
+	put := func(k int, get func(int) uint64) error {
+		for start := 0; start < k; start += len(buf) / 8 {
+			end := start + len(buf)/8
+			if end > k {
+				end = k
+			}
+			b := buf[:8*(end-start)]
+			for i := start; i < end; i++ {
+				binary.LittleEndian.PutUint64(b[8*(i-start):], get(i))
+			}
+			nn, err := w.Write(b)
+			n += nn
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err = put(len(m.indptr), func(i int) uint64 { return uint64(m.indptr[i]) }); err != nil {
+		return n, err
+	}
+	if err = put(len(m.ind), func(i int) uint64 { return uint64(m.ind[i]) }); err != nil {
+		return n, err
+	}
+	err = put(len(m.data), func(i int) uint64 { return math.Float64bits(m.data[i]) })
+	return n, err
+}
+
+// UnmarshalBinary decodes the binary form into the receiver. It panics if
+// the receiver is not a zero CSR.
+func (m *CSR) UnmarshalBinary(data []byte) error {
+	_, err := m.UnmarshalBinaryFrom(bytes.NewReader(data))
+	return err
+}
+
+// UnmarshalBinaryFrom decodes the binary form read from r into the
+// receiver. It returns the number of bytes read and an error, if any. The
+// structure of the decoded matrix is validated, so corrupted data results
+// in an error. UnmarshalBinaryFrom panics if the receiver is not a zero
+// CSR.
+func (m *CSR) UnmarshalBinaryFrom(r io.Reader) (int, error) {
+	if m.r != 0 || m.c != 0 || m.indptr != nil {
+		panic("sparse: unmarshal into non-zero matrix")
+	}
+	var hdr [csrHeaderSize]byte
+	n, err := io.ReadFull(r, hdr[:])
+	if err != nil {
+		return n, unexpectedEOF(err)
+	}
+	if string(hdr[:4]) != csrMagic {
+		return n, errors.New("sparse: not a binary CSR")
+	}
+	if v := binary.LittleEndian.Uint32(hdr[4:]); v != csrVersion {
+		return n, errors.New("sparse: unsupported binary CSR version")
+	}
+	rows := int64(binary.LittleEndian.Uint64(hdr[8:]))
+	cols := int64(binary.LittleEndian.Uint64(hdr[16:]))
+	nnz := int64(binary.LittleEndian.Uint64(hdr[24:]))
+	if rows < 0 || cols < 0 || nnz < 0 || rows > math.MaxInt32*math.MaxInt32 || nnz > math.MaxInt32*math.MaxInt32 {
+		return n, errBadBinary
+	}
+
+	// The arrays are grown as they are read, so a corrupted header does
+	// not cause a huge allocation before the data runs out.
+	buf := make([]byte, 8*1024)
+	get := func(k int, add func(uint64)) error {
+		for k > 0 {
+			b := buf
+			if k < len(b)/8 {
+				b = b[:8*k]
+			}
+			nn, err := io.ReadFull(r, b)
+			n += nn
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			for i := 0; i < len(b); i += 8 {
+				add(binary.LittleEndian.Uint64(b[i:]))
+			}
+			k -= len(b) / 8
+		}
+		return nil
+	}
+	indptr := make([]int, 0, capHint(rows+1))
+	if err = get(int(rows+1), func(v uint64) { indptr = append(indptr, int(v)) }); err != nil {
+		return n, err
+	}
+	if indptr[0] != 0 || indptr[rows] != int(nnz) {
+		return n, errBadBinary
+	}
+	for i := 0; i < int(rows); i++ {
+		if indptr[i+1] < indptr[i] {
+			return n, errBadBinary
+		}
+	}
+	ind := make([]int, 0, capHint(nnz))
+	if err = get(int(nnz), func(v uint64) { ind = append(ind, int(v)) }); err != nil {
+		return n, err
+	}
+	for i := 0; i < int(rows); i++ {
+		prev := -1
+		for _, j := range ind[indptr[i]:indptr[i+1]] {
+			if j <= prev || int(cols) <= j {
+				return n, errBadBinary
+			}
+			prev = j
+		}
+	}
+	data := make([]float64, 0, capHint(nnz))
+	if err = get(int(nnz), func(v uint64) { data = append(data, math.Float64frombits(v)) }); err != nil {
+		return n, err
+	}
+
+	m.r = int(rows)
+	m.c = int(cols)
+	m.indptr = indptr
+	m.ind = ind
+	m.data = data
+	return n, nil
+}
+
+// capHint returns the capacity to preallocate for an array of length k
+// given in a binary header.
+func capHint(k int64) int {
+	const max = 1 << 24
+	if k > max {
+		return max
+	}
+	return int(k)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCSRMarshalBinary(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{3, 5, 4},
+		{10, 10, 40},
+		{2000, 300, 5000}, // Spans several encoding chunks.
+	} {
+		m := NewTriplet(test.r, test.c)
+		for k := 0; k < test.nnz; k++ {
+			m.Append(rnd.Intn(test.r), rnd.Intn(test.c), rnd.NormFloat64())
+		}
+		a := NewCSRFromTriplet(m)
+		data, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v×%v: unexpected error %v", test.r, test.c, err)
+		}
+		want := csrHeaderSize + 8*(test.r+1+2*a.NNZ())
+		if len(data) != want {
+			t.Errorf("%v×%v: unexpected length %v, want %v", test.r, test.c, len(data), want)
+		}
+		var got CSR
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v×%v: unexpected error %v", test.r, test.c, err)
+		}
+		if !reflect.DeepEqual(&got, a) {
+			t.Errorf("%v×%v: round trip changed the matrix", test.r, test.c)
+		}
+	}
+}
+
+func TestCSRUnmarshalBinaryErrors(t *testing.T) {
+	a := NewCSRFromTriplet(benchTriplet(10, 30, rand.New(rand.NewSource(1))))
+	data, err := a.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := func(off int, v uint64) []byte {
+		b := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint64(b[off:], v)
+		return b
+	}
+	indStart := csrHeaderSize + 8*11
+	for _, test := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, io.ErrUnexpectedEOF},
+		{"truncated", data[:len(data)-1], io.ErrUnexpectedEOF},
+		{"huge nnz", corrupt(24, 1<<60), errBadBinary},
+		{"huge truncated", corrupt(8, 1<<40), io.ErrUnexpectedEOF},
+		{"bad indptr", corrupt(csrHeaderSize+8, 100), errBadBinary},
+		{"bad column", corrupt(indStart, 10), errBadBinary},
+		{"huge column", corrupt(indStart, 1<<20), errBadBinary},
+	} {
+		var m CSR
+		_, err := m.UnmarshalBinaryFrom(bytes.NewReader(test.data))
+		if err != test.want {
+			t.Errorf("%v: unexpected error, want %v, got %v", test.name, test.want, err)
+		}
+	}
+	for _, test := range []struct {
+		name string
+		data []byte
+	}{
+		{"magic", append([]byte("XCSR"), data[4:]...)},
+		{"version", append(append([]byte(nil), data[:4]...), append([]byte{2, 0, 0, 0}, data[8:]...)...)},
+	} {
+		var m CSR
+		if err := m.UnmarshalBinary(test.data); err == nil {
+			t.Errorf("bad %v: expected error", test.name)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when unmarshaling into non-zero matrix")
+		}
+	}()
+	a.UnmarshalBinary(data)
+}
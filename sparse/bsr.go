@@ -0,0 +1,204 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// BSR is a sparse matrix in the block compressed sparse row format. The
+// matrix is partitioned into dense br×bc blocks and the nonzero blocks are
+// stored in the CSR format. The block column indices and the blocks of
+// block row I are stored in ind[indptr[I]:indptr[I+1]] and
+// data[indptr[I]*br*bc:indptr[I+1]*br*bc], each block in row-major order.
+//
+// BSR is suitable for matrices whose nonzero elements come in small dense
+// blocks, for example, from discretizations of systems of PDEs with
+// several unknowns per node.
+type BSR struct {
+	r, c   int
+	br, bc int
+	indptr []int
+	ind    []int
+	data   []float64
+}
+
+// NewBSRFromCSR returns a new BSR matrix with the elements of a partitioned
+// into br×bc blocks. The dimensions of a must be divisible by the block
+// dimensions. If the density of the nonzero blocks, the ratio of the number
+// of elements of a to the number of elements in the nonzero blocks, is
+// smaller than minDensity, NewBSRFromCSR returns an error because the BSR
+// format would store too many zeros.
+func NewBSRFromCSR(a *CSR, br, bc int, minDensity float64) (*BSR, error) {
+	if br <= 0 || bc <= 0 {
+		return nil, errors.New("sparse: block dimension not positive")
+	}
+	if a.r%br != 0 || a.c%bc != 0 {
+		return nil, fmt.Errorf("sparse: %d×%d matrix not divisible into %d×%d blocks", a.r, a.c, br, bc)
+	}
+	nbr := a.r / br
+	m := &BSR{
+		r:      a.r,
+		c:      a.c,
+		br:     br,
+		bc:     bc,
+		indptr: make([]int, nbr+1),
+	}
+	// Find the nonzero blocks in each block row. The rows of a are sorted
+	// so the block columns of a row are too, but they need to be merged
+	// across the rows of a block row.
+	var cols []int
+	for bi := 0; bi < nbr; bi++ {
+		cols = cols[:0]
+		for i := bi * br; i < (bi+1)*br; i++ {
+			for k := a.indptr[i]; k < a.indptr[i+1]; k++ {
+				cols = append(cols, a.ind[k]/bc)
+			}
+		}
+		sort.Ints(cols)
+		for k, bj := range cols {
+			if k == 0 || bj != cols[k-1] {
+				m.ind = append(m.ind, bj)
+			}
+		}
+		m.indptr[bi+1] = len(m.ind)
+	}
+	size := br * bc
+	if density := float64(len(a.data)) / float64(len(m.ind)*size); len(m.ind) > 0 && density < minDensity {
+		return nil, fmt.Errorf("sparse: block density %.2f smaller than %.2f", density, minDensity)
+	}
+	m.data = make([]float64, len(m.ind)*size)
+	for i := 0; i < a.r; i++ {
+		bi := i / br
+		blocks := m.ind[m.indptr[bi]:m.indptr[bi+1]]
+		for k := a.indptr[i]; k < a.indptr[i+1]; k++ {
+			j := a.ind[k]
+			b := m.indptr[bi] + sort.SearchInts(blocks, j/bc)
+			m.data[b*size+(i%br)*bc+j%bc] = a.data[k]
+		}
+	}
+	return m, nil
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *BSR) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// BlockDims returns the number of rows and columns of the blocks.
+func (m *BSR) BlockDims() (br, bc int) {
+	return m.br, m.bc
+}
+
+// NNZ returns the number of stored elements of the matrix including the
+// zeros in the nonzero blocks.
+func (m *BSR) NNZ() int {
+	return len(m.data)
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *BSR) At(i, j int) float64 {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	bi := i / m.br
+	blocks := m.ind[m.indptr[bi]:m.indptr[bi+1]]
+	k := sort.SearchInts(blocks, j/m.bc)
+	if k == len(blocks) || blocks[k] != j/m.bc {
+		return 0
+	}
+	b := m.indptr[bi] + k
+	return m.data[b*m.br*m.bc+(i%m.br)*m.bc+j%m.bc]
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *BSR) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	br, bc := m.br, m.bc
+	size := br * bc
+	for bi := 0; bi < m.r/br; bi++ {
+		y := dst[bi*br : (bi+1)*br]
+		for i := range y {
+			y[i] = 0
+		}
+		for b := m.indptr[bi]; b < m.indptr[bi+1]; b++ {
+			xb := x[m.ind[b]*bc : (m.ind[b]+1)*bc]
+			block := m.data[b*size : (b+1)*size]
+			// Dense row-major block times vector.
+			var k int
+			for i := range y {
+				sum := y[i]
+				for _, xj := range xb {
+					sum += block[k] * xj
+					k++
+				}
+				y[i] = sum
+			}
+		}
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *BSR) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	for j := range dst {
+		dst[j] = 0
+	}
+	br, bc := m.br, m.bc
+	size := br * bc
+	for bi := 0; bi < m.r/br; bi++ {
+		xb := x[bi*br : (bi+1)*br]
+		for b := m.indptr[bi]; b < m.indptr[bi+1]; b++ {
+			y := dst[m.ind[b]*bc : (m.ind[b]+1)*bc]
+			block := m.data[b*size : (b+1)*size]
+			for i, xi := range xb {
+				row := block[i*bc : (i+1)*bc]
+				for j, v := range row {
+					y[j] += v * xi
+				}
+			}
+		}
+	}
+}
+
+// DiagonalBlocks returns copies of the diagonal blocks of the matrix, for
+// example, for constructing a block Jacobi preconditioner. Blocks that are
+// not stored are returned as zero matrices. DiagonalBlocks panics if the
+// blocks or the matrix are not square.
+func (m *BSR) DiagonalBlocks() []*mat.Dense {
+	if m.br != m.bc || m.r != m.c {
+		panic("sparse: diagonal blocks not square")
+	}
+	nb := m.r / m.br
+	size := m.br * m.bc
+	blocks := make([]*mat.Dense, nb)
+	for bi := range blocks {
+		blocks[bi] = mat.NewDense(m.br, m.bc, nil)
+		cols := m.ind[m.indptr[bi]:m.indptr[bi+1]]
+		k := sort.SearchInts(cols, bi)
+		if k < len(cols) && cols[k] == bi {
+			b := m.indptr[bi] + k
+			copy(blocks[bi].RawMatrix().Data, m.data[b*size:(b+1)*size])
+		}
+	}
+	return blocks
+}
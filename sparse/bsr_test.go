@@ -0,0 +1,144 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomBlockCSR returns a random CSR matrix with nb random br×bc blocks
+// in which elements are nonzero with the given probability.
+func randomBlockCSR(nbr, nbc, br, bc, nb int, prob float64, rnd *rand.Rand) *CSR {
+	r, c := nbr*br, nbc*bc
+	t := NewTriplet(r, c)
+	for k := 0; k < nb; k++ {
+		bi, bj := rnd.Intn(nbr), rnd.Intn(nbc)
+		for i := 0; i < br; i++ {
+			for j := 0; j < bc; j++ {
+				if rnd.Float64() < prob {
+					t.Append(bi*br+i, bj*bc+j, rnd.NormFloat64())
+				}
+			}
+		}
+	}
+	return NewCSRFromTriplet(t)
+}
+
+// denseFromCSR returns the row-major dense representation of a.
+func denseFromCSR(a *CSR) []float64 {
+	r, c := a.Dims()
+	dense := make([]float64, r*c)
+	for i := 0; i < r; i++ {
+		ind, data := a.RowView(i)
+		for k, j := range ind {
+			dense[i*c+j] = data[k]
+		}
+	}
+	return dense
+}
+
+func TestBSR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		nbr, nbc, br, bc, nb int
+	}{
+		{1, 1, 1, 1, 1},
+		{3, 3, 1, 1, 5},
+		{2, 3, 2, 2, 0},
+		{4, 4, 2, 2, 8},
+		{5, 5, 4, 4, 12},
+		{3, 5, 2, 3, 7},
+		{5, 3, 3, 2, 7},
+		{10, 10, 4, 4, 40},
+	} {
+		br, bc := test.br, test.bc
+		r, c := test.nbr*br, test.nbc*bc
+		a := randomBlockCSR(test.nbr, test.nbc, br, bc, test.nb, 0.8, rnd)
+		want := denseFromCSR(a)
+
+		m, err := NewBSRFromCSR(a, br, bc, 0)
+		if err != nil {
+			t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected error: %v", r, c, br, bc, err)
+			continue
+		}
+		if gr, gc := m.Dims(); gr != r || gc != c {
+			t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected dimensions %v×%v", r, c, br, bc, gr, gc)
+		}
+		if gbr, gbc := m.BlockDims(); gbr != br || gbc != bc {
+			t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected block dimensions %v×%v", r, c, br, bc, gbr, gbc)
+		}
+		if m.NNZ() < a.NNZ() || m.NNZ()%(br*bc) != 0 {
+			t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected NNZ %v", r, c, br, bc, m.NNZ())
+		}
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if m.At(i, j) != want[i*c+j] {
+					t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected element at (%v,%v)", r, c, br, bc, i, j)
+				}
+			}
+		}
+		if s := testMulVec(m, want, rnd); s != "" {
+			t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected result of %v", r, c, br, bc, s)
+		}
+		if s := testMulVecPanics(m); s != "" {
+			t.Errorf("r=%v,c=%v,br=%v,bc=%v: %v did not panic", r, c, br, bc, s)
+		}
+
+		if br != bc || r != c {
+			if !panics(func() { m.DiagonalBlocks() }) {
+				t.Errorf("r=%v,c=%v,br=%v,bc=%v: DiagonalBlocks did not panic", r, c, br, bc)
+			}
+			continue
+		}
+		for bi, block := range m.DiagonalBlocks() {
+			for i := 0; i < br; i++ {
+				for j := 0; j < bc; j++ {
+					if block.At(i, j) != want[(bi*br+i)*c+bi*bc+j] {
+						t.Errorf("r=%v,c=%v,br=%v,bc=%v: unexpected element (%v,%v) of diagonal block %v", r, c, br, bc, i, j, bi)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBSRErrors(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	a := randomBlockCSR(4, 4, 2, 2, 6, 0.5, rnd)
+	for _, test := range []struct {
+		name       string
+		br, bc     int
+		minDensity float64
+	}{
+		{"zero block rows", 0, 2, 0},
+		{"negative block columns", 2, -1, 0},
+		{"indivisible rows", 3, 2, 0},
+		{"indivisible columns", 2, 3, 0},
+		{"low density", 2, 2, 0.9},
+	} {
+		if _, err := NewBSRFromCSR(a, test.br, test.bc, test.minDensity); err == nil {
+			t.Errorf("missing error for %v", test.name)
+		}
+	}
+}
+
+func BenchmarkBSRMulVec(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	const nb = 20000
+	a := randomBlockCSR(nb, nb, 4, 4, 10*nb, 1, rnd)
+	m, err := NewBSRFromCSR(a, 4, 4, 0.9)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkMulVec(b, m.MulVec, 4*nb)
+}
+
+func BenchmarkCSRMulVecBlocks(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	const nb = 20000
+	a := randomBlockCSR(nb, nb, 4, 4, 10*nb, 1, rnd)
+	benchmarkMulVec(b, a.MulVec, 4*nb)
+}
@@ -0,0 +1,197 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import "sort"
+
+// CSC is a sparse matrix in the compressed sparse column format. The row
+// indices and values of the nonzero elements in column j are stored in
+// ind[indptr[j]:indptr[j+1]] and data[indptr[j]:indptr[j+1]], respectively,
+// with the row indices in increasing order and without duplicates.
+//
+// CSC is the transpose counterpart of CSR. Its MulTransVec accesses memory
+// sequentially, which makes it preferable to CSR for methods that multiply
+// by A^T as often as by A.
+type CSC struct {
+	r, c   int
+	indptr []int
+	ind    []int
+	data   []float64
+}
+
+// NewCSCFromTriplet returns a new CSC matrix with the elements of t.
+// Triplets with the same row and column are summed in the order in which
+// they were appended to t.
+func NewCSCFromTriplet(t *Triplet) *CSC {
+	return NewCSRFromTriplet(t).ToCSC()
+}
+
+// NewCSCFromDOK returns a new CSC matrix with the elements of d.
+func NewCSCFromDOK(d *DOK) *CSC {
+	return NewCSRFromDOK(d).ToCSC()
+}
+
+// ToCSC returns the matrix m in the CSC format. The data is copied.
+func (m *CSR) ToCSC() *CSC {
+	indptr, ind, data := transpose(m.r, m.c, m.indptr, m.ind, m.data)
+	return &CSC{r: m.r, c: m.c, indptr: indptr, ind: ind, data: data}
+}
+
+// ToCSR returns the matrix m in the CSR format. The data is copied.
+func (m *CSC) ToCSR() *CSR {
+	indptr, ind, data := transpose(m.c, m.r, m.indptr, m.ind, m.data)
+	return &CSR{r: m.r, c: m.c, indptr: indptr, ind: ind, data: data}
+}
+
+// transpose converts a matrix with n compressed rows (or columns) and m
+// columns (or rows) to the m compressed columns (or rows) by a counting
+// sort. The indices in the result are in increasing order.
+func transpose(n, m int, indptr, ind []int, data []float64) (tindptr, tind []int, tdata []float64) {
+	nnz := indptr[n]
+	tindptr = make([]int, m+1)
+	tind = make([]int, nnz)
+	tdata = make([]float64, nnz)
+	for _, j := range ind[:nnz] {
+		tindptr[j+1]++
+	}
+	for j := 0; j < m; j++ {
+		tindptr[j+1] += tindptr[j]
+	}
+	next := make([]int, m)
+	copy(next, tindptr)
+	for i := 0; i < n; i++ {
+		for k := indptr[i]; k < indptr[i+1]; k++ {
+			j := ind[k]
+			tind[next[j]] = i
+			tdata[next[j]] = data[k]
+			next[j]++
+		}
+	}
+	return tindptr, tind, tdata
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *CSC) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// NNZ returns the number of stored elements of the matrix.
+func (m *CSC) NNZ() int {
+	return len(m.data)
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *CSC) At(i, j int) float64 {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	ind, data := m.ColView(j)
+	k := sort.SearchInts(ind, i)
+	if k < len(ind) && ind[k] == i {
+		return data[k]
+	}
+	return 0
+}
+
+// ColView returns the row indices and values of the stored elements in
+// column j of the matrix. The row indices are in increasing order. The
+// returned slices share the storage of m and must not be modified.
+func (m *CSC) ColView(j int) (ind []int, data []float64) {
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	start, end := m.indptr[j], m.indptr[j+1]
+	return m.ind[start:end:end], m.data[start:end:end]
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *CSC) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	for j, xj := range x {
+		for k := m.indptr[j]; k < m.indptr[j+1]; k++ {
+			dst[m.ind[k]] += m.data[k] * xj
+		}
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *CSC) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	for j := range dst {
+		var sum float64
+		for k := m.indptr[j]; k < m.indptr[j+1]; k++ {
+			sum += m.data[k] * x[m.ind[k]]
+		}
+		dst[j] = sum
+	}
+}
+
+// CSRCSC holds a matrix in both the CSR and the CSC format. MulVec is done
+// with the CSR and MulTransVec with the CSC matrix so that both products
+// access memory sequentially at the cost of storing the matrix twice.
+type CSRCSC struct {
+	csr *CSR
+	csc *CSC
+}
+
+// NewCSRCSC returns a new CSRCSC matrix with the elements of m. The CSR
+// matrix m is retained and must not be modified.
+func NewCSRCSC(m *CSR) *CSRCSC {
+	return &CSRCSC{
+		csr: m,
+		csc: m.ToCSC(),
+	}
+}
+
+// CSR returns the matrix in the CSR format.
+func (m *CSRCSC) CSR() *CSR {
+	return m.csr
+}
+
+// CSC returns the matrix in the CSC format.
+func (m *CSRCSC) CSC() *CSC {
+	return m.csc
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *CSRCSC) Dims() (r, c int) {
+	return m.csr.Dims()
+}
+
+// NNZ returns the number of stored elements of the matrix.
+func (m *CSRCSC) NNZ() int {
+	return m.csr.NNZ()
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *CSRCSC) At(i, j int) float64 {
+	return m.csr.At(i, j)
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *CSRCSC) MulVec(dst, x []float64) {
+	m.csr.MulVec(dst, x)
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *CSRCSC) MulTransVec(dst, x []float64) {
+	m.csc.MulTransVec(dst, x)
+}
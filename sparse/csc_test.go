@@ -0,0 +1,140 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCSC(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{1, 1, 3},
+		{3, 5, 4},
+		{5, 3, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+		{7, 20, 100},
+		{50, 50, 200},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := denseFromEntries(r, c, rows, cols, vals)
+
+		csr := NewCSRFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals))
+		for _, src := range []struct {
+			name string
+			m    *CSC
+		}{
+			{"triplet", NewCSCFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals))},
+			{"dok", NewCSCFromDOK(NewDOKFromSlices(r, c, rows, cols, vals))},
+			{"csr", csr.ToCSC()},
+		} {
+			m := src.m
+			if gr, gc := m.Dims(); gr != r || gc != c {
+				t.Errorf("%v,r=%v,c=%v: unexpected dimensions %v×%v", src.name, r, c, gr, gc)
+			}
+			if m.NNZ() != csr.NNZ() {
+				t.Errorf("%v,r=%v,c=%v: unexpected NNZ, want %v, got %v", src.name, r, c, csr.NNZ(), m.NNZ())
+			}
+			for j := 0; j < c; j++ {
+				ind, _ := m.ColView(j)
+				for k := 1; k < len(ind); k++ {
+					if ind[k-1] >= ind[k] {
+						t.Errorf("%v,r=%v,c=%v: row indices in column %v not increasing", src.name, r, c, j)
+						break
+					}
+				}
+				for i := 0; i < r; i++ {
+					if m.At(i, j) != csr.At(i, j) {
+						t.Errorf("%v,r=%v,c=%v: unexpected element at (%v,%v), want %v, got %v",
+							src.name, r, c, i, j, csr.At(i, j), m.At(i, j))
+					}
+				}
+			}
+			if s := testMulVec(m, want, rnd); s != "" {
+				t.Errorf("%v,r=%v,c=%v: unexpected result of %v", src.name, r, c, s)
+			}
+			if s := testMulVecPanics(m); s != "" {
+				t.Errorf("%v,r=%v,c=%v: %v did not panic", src.name, r, c, s)
+			}
+		}
+
+		// The round trip through CSC must give back the same CSR.
+		if back := csr.ToCSC().ToCSR(); !reflect.DeepEqual(back, csr) {
+			t.Errorf("r=%v,c=%v: CSR to CSC round trip mismatch", r, c)
+		}
+
+		both := NewCSRCSC(csr)
+		if both.CSR() != csr {
+			t.Errorf("r=%v,c=%v: CSR not retained", r, c)
+		}
+		if !reflect.DeepEqual(both.CSC(), csr.ToCSC()) {
+			t.Errorf("r=%v,c=%v: unexpected CSC of CSRCSC", r, c)
+		}
+		if gr, gc := both.Dims(); gr != r || gc != c {
+			t.Errorf("r=%v,c=%v: unexpected dimensions of CSRCSC %v×%v", r, c, gr, gc)
+		}
+		if both.NNZ() != csr.NNZ() {
+			t.Errorf("r=%v,c=%v: unexpected NNZ of CSRCSC, want %v, got %v", r, c, csr.NNZ(), both.NNZ())
+		}
+		if r > 0 && c > 0 {
+			i, j := rnd.Intn(r), rnd.Intn(c)
+			if both.At(i, j) != csr.At(i, j) {
+				t.Errorf("r=%v,c=%v: unexpected element of CSRCSC at (%v,%v)", r, c, i, j)
+			}
+		}
+		if s := testMulVec(both, want, rnd); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected result of CSRCSC %v", r, c, s)
+		}
+	}
+}
+
+func TestCSCPanics(t *testing.T) {
+	m := NewCSCFromTriplet(NewTriplet(3, 4))
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"At with negative row", func() { m.At(-1, 0) }},
+		{"At with large row", func() { m.At(3, 0) }},
+		{"At with negative column", func() { m.At(0, -1) }},
+		{"At with large column", func() { m.At(0, 4) }},
+		{"ColView with negative column", func() { m.ColView(-1) }},
+		{"ColView with large column", func() { m.ColView(4) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+// benchUnsymmetric returns a random n×n CSR matrix with about nnz elements
+// scattered over the whole matrix.
+func benchUnsymmetric(n, nnz int, rnd *rand.Rand) *CSR {
+	m := NewTriplet(n, n)
+	for k := 0; k < nnz; k++ {
+		m.Append(rnd.Intn(n), rnd.Intn(n), rnd.NormFloat64())
+	}
+	return NewCSRFromTriplet(m)
+}
+
+const benchLargeN = 1000000
+
+func BenchmarkCSRMulTransVecLarge(b *testing.B) {
+	m := benchUnsymmetric(benchLargeN, 5*benchLargeN, rand.New(rand.NewSource(1)))
+	benchmarkMulVec(b, m.MulTransVec, benchLargeN)
+}
+
+func BenchmarkCSCMulTransVecLarge(b *testing.B) {
+	m := benchUnsymmetric(benchLargeN, 5*benchLargeN, rand.New(rand.NewSource(1))).ToCSC()
+	benchmarkMulVec(b, m.MulTransVec, benchLargeN)
+}
@@ -0,0 +1,387 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CSR is a sparse matrix in the compressed sparse row format. The column
+// indices and values of the nonzero elements in row i are stored in
+// ind[indptr[i]:indptr[i+1]] and data[indptr[i]:indptr[i+1]], respectively,
+// with the column indices in increasing order and without duplicates.
+//
+// The sparsity pattern of a CSR is fixed at construction, elements cannot
+// be inserted or removed, but the stored values can be scaled in place by
+// ScaleRows and ScaleCols. Its matrix-vector products access memory
+// sequentially and are much faster than those of DOK and Triplet.
+type CSR struct {
+	r, c   int
+	indptr []int
+	ind    []int
+	data   []float64
+
+	parallel
+}
+
+// NewCSRFromTriplet returns a new CSR matrix with the elements of t.
+// Triplets with the same row and column are summed in the order in which
+// they were appended to t. See AssembleCSR for details.
+func NewCSRFromTriplet(t *Triplet) *CSR {
+	m, _, _ := AssembleCSR(t, false)
+	return m
+}
+
+// AssembleCSR returns a new CSR matrix with the elements of t and the
+// number of triplets that were merged into another triplet with the same
+// row and column. The assembly is deterministic: the triplets are stably
+// sorted by row and column and the values of triplets with the same row
+// and column are summed in the order in which they were appended to t, so
+// the result depends only on the sequence of triplets in t.
+//
+// If strict is true and t contains more than one triplet with the same row
+// and column, AssembleCSR returns a nil matrix and an error describing the
+// first such element.
+func AssembleCSR(t *Triplet, strict bool) (m *CSR, merged int, err error) {
+	m = &CSR{
+		r:      t.r,
+		c:      t.c,
+		indptr: make([]int, t.r+1),
+		ind:    make([]int, len(t.data)),
+		data:   make([]float64, len(t.data)),
+	}
+	// Distribute the triplets into rows keeping their relative order.
+	for _, aij := range t.data {
+		m.indptr[aij.i+1]++
+	}
+	for i := 0; i < m.r; i++ {
+		m.indptr[i+1] += m.indptr[i]
+	}
+	next := make([]int, m.r)
+	copy(next, m.indptr)
+	for _, aij := range t.data {
+		k := next[aij.i]
+		m.ind[k] = aij.j
+		m.data[k] = aij.v
+		next[aij.i]++
+	}
+	merged, first := m.sortRows()
+	if strict && merged > 0 {
+		return nil, merged, fmt.Errorf("sparse: duplicate element at (%d,%d)", first.row, first.col)
+	}
+	return m, merged, nil
+}
+
+// NewCSRInPlace returns a new r×c CSR matrix whose elements are given by
+// the k-th elements of rows, cols and vals as
+//  m[rows[k],cols[k]] = vals[k].
+// Values of elements that appear more than once are summed in an
+// unspecified order. The slices must have the same length. The conversion
+// is done in place, cols and vals become the storage of the returned matrix
+// and rows is overwritten. Only an additional slice of length r is
+// allocated, which makes NewCSRInPlace suitable for matrices that are too
+// large to be held in memory twice.
+func NewCSRInPlace(r, c int, rows, cols []int, vals []float64) *CSR {
+	if r < 0 || c < 0 {
+		panic("sparse: negative dimension")
+	}
+	if len(rows) != len(vals) || len(cols) != len(vals) {
+		panic("sparse: slice length mismatch")
+	}
+	m := &CSR{
+		r:      r,
+		c:      c,
+		indptr: make([]int, r+1),
+		ind:    cols,
+		data:   vals,
+	}
+	for k, i := range rows {
+		if i < 0 || r <= i {
+			panic("sparse: row index out of range")
+		}
+		if cols[k] < 0 || c <= cols[k] {
+			panic("sparse: column index out of range")
+		}
+		m.indptr[i+1]++
+	}
+	for i := 0; i < r; i++ {
+		m.indptr[i+1] += m.indptr[i]
+	}
+	// Move the elements to their rows by swapping, next[i] is the first
+	// position in the row i not yet holding an element of the row.
+	next := make([]int, r)
+	copy(next, m.indptr)
+	for b := 0; b < r; b++ {
+		for next[b] < m.indptr[b+1] {
+			k := next[b]
+			i := rows[k]
+			if i == b {
+				next[b]++
+				continue
+			}
+			d := next[i]
+			rows[k], rows[d] = rows[d], rows[k]
+			cols[k], cols[d] = cols[d], cols[k]
+			vals[k], vals[d] = vals[d], vals[k]
+			next[i]++
+		}
+	}
+	m.sortRows()
+	return m
+}
+
+// NewCSRFromDOK returns a new CSR matrix with the elements of d. Both
+// triangles of a symmetric d are stored in the returned matrix.
+func NewCSRFromDOK(d *DOK) *CSR {
+	if d.sym {
+		return NewCSRFromTriplet(d.ToTriplet())
+	}
+	m := &CSR{
+		r:      d.r,
+		c:      d.c,
+		indptr: make([]int, d.r+1),
+		ind:    make([]int, len(d.data)),
+		data:   make([]float64, len(d.data)),
+	}
+	for ij := range d.data {
+		m.indptr[ij.row+1]++
+	}
+	for i := 0; i < m.r; i++ {
+		m.indptr[i+1] += m.indptr[i]
+	}
+	next := make([]int, m.r)
+	copy(next, m.indptr)
+	for ij, v := range d.data {
+		k := next[ij.row]
+		m.ind[k] = ij.col
+		m.data[k] = v
+		next[ij.row]++
+	}
+	// The map has no duplicates so sorting the rows fixes the random
+	// iteration order.
+	m.sortRows()
+	return m
+}
+
+// sortRows stably sorts the column indices within each row and sums the
+// values of duplicate elements in their current order. It returns the
+// number of merged elements and the index of the first duplicate element.
+func (m *CSR) sortRows() (merged int, first index) {
+	var nnz int
+	start := 0
+	for i := 0; i < m.r; i++ {
+		end := m.indptr[i+1]
+		sort.Stable(byColumn{m.ind[start:end], m.data[start:end]})
+		m.indptr[i] = nnz
+		for k := start; k < end; k++ {
+			if k > start && m.ind[k] == m.ind[nnz-1] {
+				if merged == 0 {
+					first = index{i, m.ind[k]}
+				}
+				merged++
+				m.data[nnz-1] += m.data[k]
+				continue
+			}
+			m.ind[nnz] = m.ind[k]
+			m.data[nnz] = m.data[k]
+			nnz++
+		}
+		start = end
+	}
+	m.indptr[m.r] = nnz
+	m.ind = m.ind[:nnz:nnz]
+	m.data = m.data[:nnz:nnz]
+	return merged, first
+}
+
+// byColumn sorts the elements of a CSR row by their column index.
+type byColumn struct {
+	ind  []int
+	data []float64
+}
+
+func (r byColumn) Len() int           { return len(r.ind) }
+func (r byColumn) Less(i, j int) bool { return r.ind[i] < r.ind[j] }
+func (r byColumn) Swap(i, j int) {
+	r.ind[i], r.ind[j] = r.ind[j], r.ind[i]
+	r.data[i], r.data[j] = r.data[j], r.data[i]
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *CSR) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// NNZ returns the number of stored elements of the matrix.
+func (m *CSR) NNZ() int {
+	return len(m.data)
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *CSR) At(i, j int) float64 {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	ind, data := m.RowView(i)
+	k := sort.SearchInts(ind, j)
+	if k < len(ind) && ind[k] == j {
+		return data[k]
+	}
+	return 0
+}
+
+// RowView returns the column indices and values of the stored elements in
+// row i of the matrix. The column indices are in increasing order. The
+// returned slices share the storage of m and must not be modified.
+func (m *CSR) RowView(i int) (ind []int, data []float64) {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	start, end := m.indptr[i], m.indptr[i+1]
+	return m.ind[start:end:end], m.data[start:end:end]
+}
+
+// SetThreads sets the number of goroutines used by MulVec and MulTransVec
+// when the matrix has at least as many stored elements as the threshold set
+// by SetParallelThreshold. If n is 0, runtime.GOMAXPROCS(0) at the time of
+// the call is used. If n is 1, the products are computed serially, which is
+// the default.
+func (m *CSR) SetThreads(n int) {
+	m.setThreads(n)
+}
+
+// SetParallelThreshold sets the number of stored elements below which the
+// products are computed serially regardless of SetThreads. If nnz is 0,
+// DefaultParallelThreshold is used.
+func (m *CSR) SetParallelThreshold(nnz int) {
+	m.setThreshold(nnz)
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *CSR) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	n := m.workers(len(m.data))
+	if n == 1 {
+		m.mulVec(dst, x, 0, m.r)
+		return
+	}
+	bounds := m.partition(n)
+	run(n, func(w int) {
+		m.mulVec(dst, x, bounds[w], bounds[w+1])
+	})
+}
+
+// mulVec computes the rows [start, end) of A*x.
+func (m *CSR) mulVec(dst, x []float64, start, end int) {
+	for i := start; i < end; i++ {
+		var sum float64
+		for k := m.indptr[i]; k < m.indptr[i+1]; k++ {
+			sum += m.data[k] * x[m.ind[k]]
+		}
+		dst[i] = sum
+	}
+}
+
+// MulVecs computes A*X for the c×k matrix X and stores the result into the
+// r×k matrix dst. X and dst are stored column-major, the j-th column of X
+// is x[j*c:(j+1)*c] and the j-th column of dst is dst[j*r:(j+1)*r]. The
+// rows of A are traversed once for all k columns, which is faster than k
+// calls to MulVec.
+func (m *CSR) MulVecs(dst, x []float64, k int) {
+	if k < 0 {
+		panic("sparse: negative number of vectors")
+	}
+	if m.c*k != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r*k != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	n := m.workers(k * len(m.data))
+	if n == 1 {
+		m.mulVecs(dst, x, k, 0, m.r)
+		return
+	}
+	bounds := m.partition(n)
+	run(n, func(w int) {
+		m.mulVecs(dst, x, k, bounds[w], bounds[w+1])
+	})
+}
+
+// mulVecs computes the rows [start, end) of A*X.
+func (m *CSR) mulVecs(dst, x []float64, k, start, end int) {
+	for i := start; i < end; i++ {
+		for j := 0; j < k; j++ {
+			dst[j*m.r+i] = 0
+		}
+		for p := m.indptr[i]; p < m.indptr[i+1]; p++ {
+			v := m.data[p]
+			col := m.ind[p]
+			for j := 0; j < k; j++ {
+				dst[j*m.r+i] += v * x[j*m.c+col]
+			}
+		}
+	}
+}
+
+// partition splits the rows into n ranges [bounds[w], bounds[w+1]) with
+// approximately the same number of stored elements.
+func (m *CSR) partition(n int) []int {
+	nnz := len(m.data)
+	bounds := make([]int, n+1)
+	for w := 1; w < n; w++ {
+		bounds[w] = sort.SearchInts(m.indptr, w*nnz/n)
+		if bounds[w] < bounds[w-1] {
+			bounds[w] = bounds[w-1]
+		}
+		if bounds[w] > m.r {
+			bounds[w] = m.r
+		}
+	}
+	bounds[n] = m.r
+	return bounds
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *CSR) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	n := m.workers(len(m.data))
+	if n == 1 {
+		for i := range dst {
+			dst[i] = 0
+		}
+		m.mulTransVec(dst, x, 0, m.r)
+		return
+	}
+	bounds := m.partition(n)
+	transMulVecParallel(dst, n, func(w int, y []float64) {
+		m.mulTransVec(y, x, bounds[w], bounds[w+1])
+	})
+}
+
+// mulTransVec adds the contributions of the rows [start, end) of A^T*x to
+// dst.
+func (m *CSR) mulTransVec(dst, x []float64, start, end int) {
+	for i := start; i < end; i++ {
+		xi := x[i]
+		for k := m.indptr[i]; k < m.indptr[i+1]; k++ {
+			dst[m.ind[k]] += m.data[k] * xi
+		}
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestCSR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{1, 1, 1},
+		{1, 1, 3},
+		{3, 5, 4},
+		{5, 3, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+		{7, 20, 100},
+		{50, 50, 200},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := denseFromEntries(r, c, rows, cols, vals)
+
+		for _, src := range []struct {
+			name string
+			m    *CSR
+		}{
+			{"triplet", NewCSRFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals))},
+			{"dok", NewCSRFromDOK(NewDOKFromSlices(r, c, rows, cols, vals))},
+		} {
+			m := src.m
+			if gr, gc := m.Dims(); gr != r || gc != c {
+				t.Errorf("%v,r=%v,c=%v: unexpected dimensions %v×%v", src.name, r, c, gr, gc)
+			}
+			nnz := make(map[index]bool)
+			for k := range vals {
+				nnz[index{rows[k], cols[k]}] = true
+			}
+			if m.NNZ() != len(nnz) {
+				t.Errorf("%v,r=%v,c=%v: unexpected NNZ, want %v, got %v", src.name, r, c, len(nnz), m.NNZ())
+			}
+			for i := 0; i < r; i++ {
+				ind, _ := m.RowView(i)
+				for k := 1; k < len(ind); k++ {
+					if ind[k-1] >= ind[k] {
+						t.Errorf("%v,r=%v,c=%v: column indices in row %v not increasing", src.name, r, c, i)
+						break
+					}
+				}
+				for j := 0; j < c; j++ {
+					if !equalApprox([]float64{m.At(i, j)}, []float64{want[i*c+j]}, 1e-14) {
+						t.Errorf("%v,r=%v,c=%v: unexpected element at (%v,%v), want %v, got %v",
+							src.name, r, c, i, j, want[i*c+j], m.At(i, j))
+					}
+				}
+			}
+
+			for _, trans := range []bool{false, true} {
+				x := randomVec(r, rnd)
+				dst := make([]float64, c)
+				mul := m.MulTransVec
+				if !trans {
+					x = randomVec(c, rnd)
+					dst = make([]float64, r)
+					mul = m.MulVec
+				}
+				for i := range dst {
+					dst[i] = rnd.NormFloat64()
+				}
+				mul(dst, x)
+				if !equalApprox(dst, denseMulVec(want, r, c, trans, x), 1e-13) {
+					t.Errorf("%v,r=%v,c=%v,trans=%v: unexpected result of product", src.name, r, c, trans)
+				}
+			}
+
+			if s := testMulVecPanics(m); s != "" {
+				t.Errorf("%v,r=%v,c=%v: %v did not panic", src.name, r, c, s)
+			}
+		}
+	}
+}
+
+func TestCSRDuplicates(t *testing.T) {
+	// The duplicates are summed in the order of appending, so
+	// (1e16 + 1) - 1e16 gives 0 while (1e16 - 1e16) + 1 would give 1.
+	tr := NewTriplet(2, 3)
+	tr.Append(1, 2, 5)
+	tr.Append(0, 1, 1e16)
+	tr.Append(0, 0, 2)
+	tr.Append(0, 1, 1)
+	tr.Append(0, 1, -1e16)
+	m := NewCSRFromTriplet(tr)
+	if m.NNZ() != 3 {
+		t.Errorf("unexpected NNZ, want 3, got %v", m.NNZ())
+	}
+	ind, data := m.RowView(0)
+	if len(ind) != 2 || ind[0] != 0 || ind[1] != 1 {
+		t.Errorf("unexpected column indices in row 0: %v", ind)
+	}
+	if data[1] != 0 {
+		t.Errorf("unexpected sum of duplicates, want 0, got %v", data[1])
+	}
+	ind, data = m.RowView(1)
+	if len(ind) != 1 || ind[0] != 2 || data[0] != 5 {
+		t.Errorf("unexpected row 1: %v, %v", ind, data)
+	}
+}
+
+func TestCSRPanics(t *testing.T) {
+	m := NewCSRFromTriplet(NewTriplet(3, 4))
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"At with negative row", func() { m.At(-1, 0) }},
+		{"At with large row", func() { m.At(3, 0) }},
+		{"At with negative column", func() { m.At(0, -1) }},
+		{"At with large column", func() { m.At(0, 4) }},
+		{"RowView with negative row", func() { m.RowView(-1) }},
+		{"RowView with large row", func() { m.RowView(3) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+// benchTriplet returns a random n×n Triplet matrix with about nnz elements
+// and a banded structure typical of discretizations.
+func benchTriplet(n, nnz int, rnd *rand.Rand) *Triplet {
+	const bw = 1000
+	m := NewTriplet(n, n)
+	for k := 0; k < nnz; k++ {
+		i := rnd.Intn(n)
+		j := i + rnd.Intn(2*bw+1) - bw
+		if j < 0 {
+			j = 0
+		}
+		if j >= n {
+			j = n - 1
+		}
+		m.Append(i, j, rnd.NormFloat64())
+	}
+	return m
+}
+
+const (
+	benchN   = 100000
+	benchNNZ = 1000000
+)
+
+func benchmarkMulVec(b *testing.B, mul func(dst, x []float64), n int) {
+	rnd := rand.New(rand.NewSource(1))
+	x := randomVec(n, rnd)
+	dst := make([]float64, n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mul(dst, x)
+	}
+}
+
+func BenchmarkTripletMulVec(b *testing.B) {
+	m := benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1)))
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
+
+func BenchmarkCSRMulVec(b *testing.B) {
+	m := NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1))))
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
+
+func BenchmarkTripletMulTransVec(b *testing.B) {
+	m := benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1)))
+	benchmarkMulVec(b, m.MulTransVec, benchN)
+}
+
+func BenchmarkCSRMulTransVec(b *testing.B) {
+	m := NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1))))
+	benchmarkMulVec(b, m.MulTransVec, benchN)
+}
+
+func TestAssembleCSR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{1, 1, 0},
+		{1, 1, 5},
+		{3, 5, 10},
+		{10, 10, 50},
+		{20, 7, 200},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		distinct := make(map[index]bool)
+		for k := range vals {
+			distinct[index{rows[k], cols[k]}] = true
+		}
+		wantMerged := len(vals) - len(distinct)
+
+		ref, merged, err := AssembleCSR(NewTripletFromSlices(r, c, rows, cols, vals), false)
+		if err != nil {
+			t.Fatalf("r=%v,c=%v: unexpected error: %v", r, c, err)
+		}
+		if merged != wantMerged {
+			t.Errorf("r=%v,c=%v: unexpected number of merged duplicates, want %v, got %v", r, c, wantMerged, merged)
+		}
+		_, merged, err = AssembleCSR(NewTripletFromSlices(r, c, rows, cols, vals), true)
+		if (err != nil) != (wantMerged > 0) || merged != wantMerged {
+			t.Errorf("r=%v,c=%v: unexpected result in strict mode: merged=%v, err=%v", r, c, merged, err)
+		}
+
+		// Permutations of the triplets that keep the relative order of
+		// the duplicates of each element must give bit-identical arrays.
+		for trial := 0; trial < 10; trial++ {
+			perm := rnd.Perm(len(vals))
+			// Restore the original relative order within each group
+			// of duplicates.
+			groups := make(map[index][]int)
+			for k := range vals {
+				ij := index{rows[k], cols[k]}
+				groups[ij] = append(groups[ij], k)
+			}
+			used := make(map[index]int)
+			tr := NewTriplet(r, c)
+			for _, p := range perm {
+				ij := index{rows[p], cols[p]}
+				k := groups[ij][used[ij]]
+				used[ij]++
+				tr.Append(rows[k], cols[k], vals[k])
+			}
+			m, merged, err := AssembleCSR(tr, false)
+			if err != nil || merged != wantMerged {
+				t.Errorf("r=%v,c=%v: unexpected result for permutation: merged=%v, err=%v", r, c, merged, err)
+			}
+			if !reflect.DeepEqual(m, ref) {
+				t.Errorf("r=%v,c=%v: permuted triplets give different CSR arrays", r, c)
+			}
+		}
+	}
+}
+
+func TestCSRMulVecs(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{1, 1, 1},
+		{3, 5, 8},
+		{5, 3, 8},
+		{20, 20, 100},
+	} {
+		r, c := test.r, test.c
+		m, _ := randomCSR(r, c, test.nnz, rnd)
+		for _, k := range []int{0, 1, 3, 8} {
+			for _, threads := range []int{1, 3} {
+				m.SetThreads(threads)
+				m.SetParallelThreshold(1)
+				x := randomVec(c*k, rnd)
+				got := randomVec(r*k, rnd)
+				m.MulVecs(got, x, k)
+				want := make([]float64, r)
+				for j := 0; j < k; j++ {
+					m.MulVec(want, x[j*c:(j+1)*c])
+					if !equalApprox(got[j*r:(j+1)*r], want, 1e-14) {
+						t.Errorf("r=%v,c=%v,k=%v,threads=%v: unexpected column %v", r, c, k, threads, j)
+					}
+				}
+			}
+		}
+		if !panics(func() { m.MulVecs(make([]float64, 2*r), make([]float64, 2*c+1), 2) }) {
+			t.Errorf("r=%v,c=%v: mismatched length did not panic", r, c)
+		}
+	}
+}
+
+func BenchmarkCSRMulVecs(b *testing.B) {
+	m := NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1))))
+	m.SetThreads(1)
+	for _, k := range []int{4, 8, 16} {
+		rnd := rand.New(rand.NewSource(1))
+		x := randomVec(benchN*k, rnd)
+		dst := make([]float64, benchN*k)
+		b.Run(fmt.Sprintf("k=%d/batched", k), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.MulVecs(dst, x, k)
+			}
+		})
+		b.Run(fmt.Sprintf("k=%d/columns", k), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < k; j++ {
+					m.MulVec(dst[j*benchN:(j+1)*benchN], x[j*benchN:(j+1)*benchN])
+				}
+			}
+		})
+	}
+}
+
+func TestNewCSRInPlace(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{1, 1, 3},
+		{3, 5, 10},
+		{5, 3, 10},
+		{50, 40, 400},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := NewCSRFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals))
+		got := NewCSRInPlace(r, c, rows, cols, vals)
+		if !reflect.DeepEqual(got.indptr, want.indptr) || !reflect.DeepEqual(got.ind, want.ind) {
+			t.Errorf("r=%v,c=%v: unexpected structure", r, c)
+			continue
+		}
+		if !equalApprox(got.data, want.data, 1e-14) {
+			t.Errorf("r=%v,c=%v: unexpected values", r, c)
+		}
+	}
+	if !panics(func() { NewCSRInPlace(2, 2, []int{0, 2}, []int{0, 0}, []float64{1, 1}) }) {
+		t.Errorf("row index out of range did not panic")
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DIA is a sparse matrix in the diagonal format. The matrix is stored as a
+// set of diagonals given by their offsets from the main diagonal, the
+// diagonal with offset k holds the elements a[i,i+k]. Each diagonal is
+// stored in a slice of length r indexed by the row, the elements of the
+// slice that fall outside the matrix are not referenced.
+//
+// DIA is suitable for banded matrices with a few nonzero diagonals such as
+// those coming from finite difference stencils on structured grids. Its
+// matrix-vector products have no indirect addressing.
+type DIA struct {
+	r, c    int
+	offsets []int
+	data    [][]float64
+}
+
+// NewDIA returns a new r×c DIA matrix with the diagonals in data at the
+// given offsets. The diagonal data[d] holds the elements
+//  a[i,i+offsets[d]] = data[d][i]
+// for all rows i for which i+offsets[d] is a valid column index. The length
+// of each diagonal must be r, the offsets must be distinct and each
+// diagonal must intersect the matrix. The data is retained and used
+// directly by the matrix.
+func NewDIA(r, c int, offsets []int, data [][]float64) *DIA {
+	if r < 0 || c < 0 {
+		panic("sparse: negative dimension")
+	}
+	if len(offsets) != len(data) {
+		panic("sparse: slice length mismatch")
+	}
+	seen := make(map[int]bool, len(offsets))
+	for d, k := range offsets {
+		if k <= -r || c <= k {
+			panic("sparse: diagonal offset out of range")
+		}
+		if seen[k] {
+			panic("sparse: duplicate diagonal offset")
+		}
+		seen[k] = true
+		if len(data[d]) != r {
+			panic("sparse: diagonal length mismatch")
+		}
+	}
+	return &DIA{
+		r:       r,
+		c:       c,
+		offsets: offsets,
+		data:    data,
+	}
+}
+
+// NewDIAFromCSR returns a new DIA matrix with the elements of a. If the
+// elements of a lie on more than maxDiags distinct diagonals,
+// NewDIAFromCSR returns an error because the DIA format would be
+// inefficient for a. The diagonals are sorted by their offset.
+func NewDIAFromCSR(a *CSR, maxDiags int) (*DIA, error) {
+	pos := make(map[int]int)
+	for i := 0; i < a.r; i++ {
+		for k := a.indptr[i]; k < a.indptr[i+1]; k++ {
+			off := a.ind[k] - i
+			if _, ok := pos[off]; ok {
+				continue
+			}
+			if len(pos) == maxDiags {
+				return nil, fmt.Errorf("sparse: matrix has more than %d diagonals", maxDiags)
+			}
+			pos[off] = 0
+		}
+	}
+	offsets := make([]int, 0, len(pos))
+	for off := range pos {
+		offsets = append(offsets, off)
+	}
+	sort.Ints(offsets)
+	data := make([][]float64, len(offsets))
+	for d, off := range offsets {
+		pos[off] = d
+		data[d] = make([]float64, a.r)
+	}
+	for i := 0; i < a.r; i++ {
+		for k := a.indptr[i]; k < a.indptr[i+1]; k++ {
+			data[pos[a.ind[k]-i]][i] = a.data[k]
+		}
+	}
+	return &DIA{
+		r:       a.r,
+		c:       a.c,
+		offsets: offsets,
+		data:    data,
+	}, nil
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *DIA) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// Offsets returns the offsets of the stored diagonals. The returned slice
+// must not be modified.
+func (m *DIA) Offsets() []int {
+	return m.offsets
+}
+
+// NNZ returns the number of stored elements of the matrix that lie inside
+// the matrix.
+func (m *DIA) NNZ() int {
+	var nnz int
+	for _, k := range m.offsets {
+		start, end := m.rowRange(k)
+		nnz += end - start
+	}
+	return nnz
+}
+
+// rowRange returns the range of rows i for which i+k is a valid column
+// index.
+func (m *DIA) rowRange(k int) (start, end int) {
+	start = 0
+	if k < 0 {
+		start = -k
+	}
+	end = m.r
+	if m.c-k < end {
+		end = m.c - k
+	}
+	return start, end
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *DIA) At(i, j int) float64 {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	for d, k := range m.offsets {
+		if j-i == k {
+			return m.data[d][i]
+		}
+	}
+	return 0
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *DIA) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	for d, k := range m.offsets {
+		start, end := m.rowRange(k)
+		diag := m.data[d][start:end]
+		xd := x[start+k : end+k]
+		dd := dst[start:end]
+		for i, v := range diag {
+			dd[i] += v * xd[i]
+		}
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *DIA) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	for d, k := range m.offsets {
+		start, end := m.rowRange(k)
+		diag := m.data[d][start:end]
+		xd := x[start:end]
+		dd := dst[start+k : end+k]
+		for i, v := range diag {
+			dd[i] += v * xd[i]
+		}
+	}
+}
+
+// ToCSR returns the matrix m in the CSR format. All elements of the stored
+// diagonals that lie inside the matrix are stored, including zeros.
+func (m *DIA) ToCSR() *CSR {
+	// Visit the diagonals in the order of increasing offsets so that
+	// the column indices in each row are sorted.
+	order := make([]int, len(m.offsets))
+	for d := range order {
+		order[d] = d
+	}
+	sort.Slice(order, func(a, b int) bool { return m.offsets[order[a]] < m.offsets[order[b]] })
+
+	nnz := m.NNZ()
+	a := &CSR{
+		r:      m.r,
+		c:      m.c,
+		indptr: make([]int, m.r+1),
+		ind:    make([]int, 0, nnz),
+		data:   make([]float64, 0, nnz),
+	}
+	for i := 0; i < m.r; i++ {
+		for _, d := range order {
+			j := i + m.offsets[d]
+			if j < 0 || m.c <= j {
+				continue
+			}
+			a.ind = append(a.ind, j)
+			a.data = append(a.data, m.data[d][i])
+		}
+		a.indptr[i+1] = len(a.ind)
+	}
+	return a
+}
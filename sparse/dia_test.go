@@ -0,0 +1,145 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDIA(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c    int
+		offsets []int
+	}{
+		{1, 1, []int{0}},
+		{3, 3, []int{-1, 0, 1}},
+		{5, 5, []int{4, -4}},
+		{4, 7, []int{-3, 0, 2, 6}},
+		{7, 4, []int{-6, -1, 0, 3}},
+		{10, 10, []int{-3, -1, 0, 1, 3}},
+		{6, 2, []int{-5, 1}},
+	} {
+		r, c := test.r, test.c
+		data := make([][]float64, len(test.offsets))
+		want := make([]float64, r*c)
+		var nnz int
+		for d, k := range test.offsets {
+			data[d] = randomVec(r, rnd)
+			for i := 0; i < r; i++ {
+				if j := i + k; 0 <= j && j < c {
+					want[i*c+j] = data[d][i]
+					nnz++
+				}
+			}
+		}
+
+		m := NewDIA(r, c, test.offsets, data)
+		if gr, gc := m.Dims(); gr != r || gc != c {
+			t.Errorf("r=%v,c=%v: unexpected dimensions %v×%v", r, c, gr, gc)
+		}
+		if m.NNZ() != nnz {
+			t.Errorf("r=%v,c=%v: unexpected NNZ, want %v, got %v", r, c, nnz, m.NNZ())
+		}
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if m.At(i, j) != want[i*c+j] {
+					t.Errorf("r=%v,c=%v: unexpected element at (%v,%v)", r, c, i, j)
+				}
+			}
+		}
+		if s := testMulVec(m, want, rnd); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected result of %v", r, c, s)
+		}
+		if s := testMulVecPanics(m); s != "" {
+			t.Errorf("r=%v,c=%v: %v did not panic", r, c, s)
+		}
+
+		csr := m.ToCSR()
+		if s := testCSRDense(csr, r, c, want); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected %v of CSR", r, c, s)
+		}
+		if csr.NNZ() != nnz {
+			t.Errorf("r=%v,c=%v: unexpected NNZ of CSR, want %v, got %v", r, c, nnz, csr.NNZ())
+		}
+
+		back, err := NewDIAFromCSR(csr, len(test.offsets))
+		if err != nil {
+			t.Errorf("r=%v,c=%v: unexpected error: %v", r, c, err)
+			continue
+		}
+		for k := 1; k < len(back.Offsets()); k++ {
+			if back.Offsets()[k-1] >= back.Offsets()[k] {
+				t.Errorf("r=%v,c=%v: offsets not sorted", r, c)
+			}
+		}
+		if s := testMulVec(back, want, rnd); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected result of %v after conversion from CSR", r, c, s)
+		}
+		if len(test.offsets) > 1 {
+			_, err = NewDIAFromCSR(csr, len(test.offsets)-1)
+			if err == nil {
+				t.Errorf("r=%v,c=%v: missing error for too many diagonals", r, c)
+			}
+		}
+	}
+}
+
+func TestDIAPanics(t *testing.T) {
+	m := NewDIA(3, 4, []int{0}, [][]float64{{1, 2, 3}})
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"NewDIA with negative rows", func() { NewDIA(-1, 2, nil, nil) }},
+		{"NewDIA with mismatched lengths", func() { NewDIA(2, 2, []int{0}, nil) }},
+		{"NewDIA with large offset", func() { NewDIA(2, 3, []int{3}, [][]float64{{1, 2}}) }},
+		{"NewDIA with small offset", func() { NewDIA(2, 3, []int{-2}, [][]float64{{1, 2}}) }},
+		{"NewDIA with duplicate offset", func() { NewDIA(2, 2, []int{0, 0}, [][]float64{{1, 2}, {1, 2}}) }},
+		{"NewDIA with short diagonal", func() { NewDIA(2, 2, []int{0}, [][]float64{{1}}) }},
+		{"At with large row", func() { m.At(3, 0) }},
+		{"At with large column", func() { m.At(0, 4) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+// poisson2D returns the matrix of the 5-point finite difference
+// discretization of the Laplace operator on an nx×ny grid.
+func poisson2D(nx, ny int) *DIA {
+	n := nx * ny
+	offsets := []int{-nx, -1, 0, 1, nx}
+	data := make([][]float64, len(offsets))
+	for d := range data {
+		data[d] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		data[2][i] = 4
+		if i%nx > 0 {
+			data[1][i] = -1
+		}
+		if i%nx < nx-1 {
+			data[3][i] = -1
+		}
+		data[0][i] = -1
+		data[4][i] = -1
+	}
+	return NewDIA(n, n, offsets, data)
+}
+
+const benchPoissonN = 500
+
+func BenchmarkDIAMulVecPoisson(b *testing.B) {
+	m := poisson2D(benchPoissonN, benchPoissonN)
+	benchmarkMulVec(b, m.MulVec, benchPoissonN*benchPoissonN)
+}
+
+func BenchmarkCSRMulVecPoisson(b *testing.B) {
+	m := poisson2D(benchPoissonN, benchPoissonN).ToCSR()
+	benchmarkMulVec(b, m.MulVec, benchPoissonN*benchPoissonN)
+}
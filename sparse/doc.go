@@ -0,0 +1,13 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sparse provides sparse matrix types for use with the iterative
+// linear solvers. The types provide matrix-vector products with the
+// signatures of iterative.MatrixOps, so a sparse matrix m can be used in
+// a solve as
+//  a := iterative.MatrixOps{
+//  	MatVec:      m.MulVec,
+//  	MatTransVec: m.MulTransVec,
+//  }
+package sparse
@@ -0,0 +1,278 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+)
+
+type index struct {
+	row, col int
+}
+
+// Entry is an element of a sparse matrix given by its row, column and
+// value.
+type Entry struct {
+	I, J int
+	V    float64
+}
+
+// DOK is a sparse matrix in the dictionary of keys format. The nonzero
+// elements are stored in a map indexed by their row and column. DOK is
+// suitable for incremental construction of a matrix with random access to
+// its elements. The matrix-vector products use a CSR snapshot of the matrix
+// that is built by the first product after the matrix has been modified,
+// repeated products of an assembled matrix are therefore as fast as with
+// CSR and their results do not depend on the order of map iteration.
+//
+// A DOK matrix created by NewSymDOK is symmetric and stores only the upper
+// triangle.
+type DOK struct {
+	r, c int
+	data map[index]float64
+
+	sym bool
+
+	mu  sync.Mutex
+	csr *CSR // Snapshot for products, nil if stale.
+}
+
+// NewDOK returns a new r×c DOK matrix with all elements zero.
+func NewDOK(r, c int) *DOK {
+	if r < 0 || c < 0 {
+		panic("sparse: negative dimension")
+	}
+	return &DOK{
+		r:    r,
+		c:    c,
+		data: make(map[index]float64),
+	}
+}
+
+// NewSymDOK returns a new n×n symmetric DOK matrix with all elements zero.
+// Only the upper triangle of the matrix is stored, the indices of elements
+// in the lower triangle passed to At, Set, Add and Delete are swapped, so
+// setting a[i,j] also sets a[j,i]. The matrix-vector products apply both the
+// stored and the mirrored elements.
+func NewSymDOK(n int) *DOK {
+	m := NewDOK(n, n)
+	m.sym = true
+	return m
+}
+
+// NewDOKFromSlices returns a new r×c DOK matrix whose nonzero elements are
+// given by the k-th elements of rows, cols and vals as
+//  m[rows[k],cols[k]] = vals[k].
+// Values of elements that appear more than once are summed. The slices
+// must have the same length and they are not retained.
+func NewDOKFromSlices(r, c int, rows, cols []int, vals []float64) *DOK {
+	if len(rows) != len(vals) || len(cols) != len(vals) {
+		panic("sparse: slice length mismatch")
+	}
+	m := NewDOK(r, c)
+	for k, v := range vals {
+		i, j := rows[k], cols[k]
+		m.Add(i, j, v)
+	}
+	return m
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *DOK) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// Symmetric returns whether the matrix was created by NewSymDOK.
+func (m *DOK) Symmetric() bool {
+	return m.sym
+}
+
+// NNZ returns the number of stored elements of the matrix. Elements that
+// have been explicitly set to zero are included in the count. For a
+// symmetric matrix only the elements of the upper triangle are counted.
+func (m *DOK) NNZ() int {
+	return len(m.data)
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *DOK) At(i, j int) float64 {
+	m.checkIndex(i, j)
+	return m.data[m.key(i, j)]
+}
+
+// Set sets the element of the matrix at row i and column j to v.
+func (m *DOK) Set(i, j int, v float64) {
+	m.checkIndex(i, j)
+	m.data[m.key(i, j)] = v
+	m.csr = nil
+}
+
+// Add adds v to the element of the matrix at row i and column j.
+func (m *DOK) Add(i, j int, v float64) {
+	m.checkIndex(i, j)
+	m.data[m.key(i, j)] += v
+	m.csr = nil
+}
+
+// SetAll sets the elements of the matrix given by entries. If an element
+// appears more than once, the last value is used.
+func (m *DOK) SetAll(entries ...Entry) {
+	for _, e := range entries {
+		m.Set(e.I, e.J, e.V)
+	}
+}
+
+// Delete removes the element at row i and column j from the matrix, making
+// it a structural zero.
+func (m *DOK) Delete(i, j int) {
+	m.checkIndex(i, j)
+	delete(m.data, m.key(i, j))
+	m.csr = nil
+}
+
+// Do calls fn for each stored element of the matrix in the order of
+// increasing rows and, within a row, of increasing columns. The order is
+// deterministic regardless of the order of insertion. For a symmetric
+// matrix only the elements of the upper triangle are visited. fn must not
+// modify the matrix.
+func (m *DOK) Do(fn func(i, j int, v float64)) {
+	for _, ij := range m.sortedKeys() {
+		fn(ij.row, ij.col, m.data[ij])
+	}
+}
+
+// sortedKeys returns the indices of the stored elements sorted by rows and
+// columns.
+func (m *DOK) sortedKeys() []index {
+	keys := make([]index, 0, len(m.data))
+	for ij := range m.data {
+		keys = append(keys, ij)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].row != keys[b].row {
+			return keys[a].row < keys[b].row
+		}
+		return keys[a].col < keys[b].col
+	})
+	return keys
+}
+
+// ToCSR returns the matrix in the CSR format.
+func (m *DOK) ToCSR() *CSR {
+	return NewCSRFromDOK(m)
+}
+
+// ToTriplet returns the matrix in the Triplet format with the triplets
+// sorted by rows and columns. Both triangles of a symmetric matrix are
+// stored in the returned matrix.
+func (m *DOK) ToTriplet() *Triplet {
+	t := &Triplet{
+		r:    m.r,
+		c:    m.c,
+		data: make([]triplet, 0, len(m.data)),
+	}
+	for _, ij := range m.sortedKeys() {
+		v := m.data[ij]
+		t.data = append(t.data, triplet{ij.row, ij.col, v})
+		if m.sym && ij.row != ij.col {
+			t.data = append(t.data, triplet{ij.col, ij.row, v})
+		}
+	}
+	if m.sym {
+		t.SortByRow()
+	} else {
+		t.sorted = true
+	}
+	return t
+}
+
+// ToSymCSR returns the matrix in the SymCSR format. A matrix created by
+// NewSymDOK is converted directly. For other matrices the symmetry is
+// validated with the tolerance tol as in NewSymCSRFromCSR and an error is
+// returned if the matrix is not symmetric.
+func (m *DOK) ToSymCSR(tol float64) (*SymCSR, error) {
+	if !m.sym {
+		if m.r != m.c {
+			return nil, errors.New("sparse: matrix not square")
+		}
+		return NewSymCSRFromCSR(m.ToCSR(), tol)
+	}
+	a := NewCSRFromDOK(&DOK{r: m.r, c: m.c, data: m.data})
+	return &SymCSR{n: a.r, indptr: a.indptr, ind: a.ind, data: a.data}, nil
+}
+
+// IsSymmetric returns whether the matrix is numerically symmetric, that is,
+// whether
+//  |a[i,j] - a[j,i]| <= tol * max(|a[i,j]|, |a[j,i]|)
+// for all i and j. A matrix created by NewSymDOK is always symmetric.
+func (m *DOK) IsSymmetric(tol float64) bool {
+	if m.sym {
+		return true
+	}
+	if m.r != m.c {
+		return false
+	}
+	for ij, aij := range m.data {
+		aji := m.data[index{ij.col, ij.row}]
+		if math.Abs(aij-aji) > tol*math.Max(math.Abs(aij), math.Abs(aji)) {
+			return false
+		}
+	}
+	return true
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *DOK) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	m.snapshot().MulVec(dst, x)
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *DOK) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	m.snapshot().MulTransVec(dst, x)
+}
+
+// snapshot returns the CSR snapshot of the matrix, building it if the
+// matrix has been modified since the last call. Both triangles of a
+// symmetric matrix are stored in the snapshot.
+func (m *DOK) snapshot() *CSR {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.csr == nil {
+		m.csr = m.ToCSR()
+	}
+	return m.csr
+}
+
+// key returns the map key of the element at row i and column j.
+func (m *DOK) key(i, j int) index {
+	if m.sym && i > j {
+		return index{j, i}
+	}
+	return index{i, j}
+}
+
+func (m *DOK) checkIndex(i, j int) {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestDOK(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{1, 1, 0},
+		{1, 1, 1},
+		{3, 5, 4},
+		{5, 3, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+		{7, 20, 100},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := denseFromEntries(r, c, rows, cols, vals)
+
+		m := NewDOKFromSlices(r, c, rows, cols, vals)
+		if gr, gc := m.Dims(); gr != r || gc != c {
+			t.Errorf("r=%v,c=%v: unexpected dimensions %v×%v", r, c, gr, gc)
+		}
+		nnz := make(map[index]bool)
+		for k := range vals {
+			nnz[index{rows[k], cols[k]}] = true
+		}
+		if m.NNZ() != len(nnz) {
+			t.Errorf("r=%v,c=%v: unexpected NNZ, want %v, got %v", r, c, len(nnz), m.NNZ())
+		}
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				if m.At(i, j) != want[i*c+j] {
+					t.Errorf("r=%v,c=%v: unexpected element at (%v,%v), want %v, got %v",
+						r, c, i, j, want[i*c+j], m.At(i, j))
+				}
+			}
+		}
+
+		for _, trans := range []bool{false, true} {
+			x := randomVec(r, rnd)
+			dst := make([]float64, c)
+			mul := m.MulTransVec
+			if !trans {
+				x = randomVec(c, rnd)
+				dst = make([]float64, r)
+				mul = m.MulVec
+			}
+			for i := range dst {
+				dst[i] = rnd.NormFloat64()
+			}
+			mul(dst, x)
+			if !equalApprox(dst, denseMulVec(want, r, c, trans, x), 1e-13) {
+				t.Errorf("r=%v,c=%v,trans=%v: unexpected result of product", r, c, trans)
+			}
+		}
+
+		if s := testMulVecPanics(m); s != "" {
+			t.Errorf("r=%v,c=%v: %v did not panic", r, c, s)
+		}
+	}
+}
+
+func TestDOKSet(t *testing.T) {
+	m := NewDOK(3, 4)
+	m.Set(1, 2, 5)
+	m.Set(1, 2, 3)
+	m.Set(2, 3, -1)
+	if m.At(1, 2) != 3 {
+		t.Errorf("unexpected element at (1,2), want 3, got %v", m.At(1, 2))
+	}
+	if m.At(2, 3) != -1 {
+		t.Errorf("unexpected element at (2,3), want -1, got %v", m.At(2, 3))
+	}
+	if m.At(0, 0) != 0 {
+		t.Errorf("unexpected element at (0,0), want 0, got %v", m.At(0, 0))
+	}
+	if m.NNZ() != 2 {
+		t.Errorf("unexpected NNZ, want 2, got %v", m.NNZ())
+	}
+}
+
+func TestDOKPanics(t *testing.T) {
+	m := NewDOK(3, 4)
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"NewDOK with negative rows", func() { NewDOK(-1, 2) }},
+		{"NewDOK with negative columns", func() { NewDOK(2, -1) }},
+		{"NewDOKFromSlices with short rows", func() { NewDOKFromSlices(2, 2, []int{0}, []int{0, 1}, []float64{1, 2}) }},
+		{"NewDOKFromSlices with short cols", func() { NewDOKFromSlices(2, 2, []int{0, 1}, []int{0}, []float64{1, 2}) }},
+		{"NewDOKFromSlices with bad index", func() { NewDOKFromSlices(2, 2, []int{0, 2}, []int{0, 1}, []float64{1, 2}) }},
+		{"At with negative row", func() { m.At(-1, 0) }},
+		{"At with large row", func() { m.At(3, 0) }},
+		{"At with negative column", func() { m.At(0, -1) }},
+		{"At with large column", func() { m.At(0, 4) }},
+		{"Set with negative row", func() { m.Set(-1, 0, 1) }},
+		{"Set with large row", func() { m.Set(3, 0, 1) }},
+		{"Set with negative column", func() { m.Set(0, -1, 1) }},
+		{"Set with large column", func() { m.Set(0, 4, 1) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+func TestDOKAddDelete(t *testing.T) {
+	m := NewDOK(3, 4)
+	m.Add(1, 2, 5)
+	m.Add(1, 2, -2)
+	m.Add(0, 3, 1)
+	if m.At(1, 2) != 3 {
+		t.Errorf("unexpected element at (1,2), want 3, got %v", m.At(1, 2))
+	}
+	m.SetAll(Entry{0, 0, 7}, Entry{2, 1, 4}, Entry{0, 0, 8})
+	if m.At(0, 0) != 8 || m.At(2, 1) != 4 {
+		t.Errorf("unexpected elements after SetAll")
+	}
+	if m.NNZ() != 4 {
+		t.Errorf("unexpected NNZ, want 4, got %v", m.NNZ())
+	}
+	m.Delete(1, 2)
+	m.Delete(2, 2)
+	if m.At(1, 2) != 0 || m.NNZ() != 3 {
+		t.Errorf("element not deleted")
+	}
+
+	var got []Entry
+	m.Do(func(i, j int, v float64) {
+		got = append(got, Entry{i, j, v})
+	})
+	want := []Entry{{0, 0, 8}, {0, 3, 1}, {2, 1, 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected elements visited by Do, want %v, got %v", want, got)
+	}
+
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"Add with large row", func() { m.Add(3, 0, 1) }},
+		{"Add with negative column", func() { m.Add(0, -1, 1) }},
+		{"Delete with large column", func() { m.Delete(0, 4) }},
+		{"SetAll with negative row", func() { m.SetAll(Entry{-1, 0, 1}) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+func TestDOKConversions(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 1},
+		{3, 5, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := denseFromEntries(r, c, rows, cols, vals)
+		m := NewDOK(r, c)
+		for k, v := range vals {
+			m.Add(rows[k], cols[k], v)
+		}
+
+		tr := m.ToTriplet()
+		if tr.NNZ() != m.NNZ() {
+			t.Errorf("r=%v,c=%v: unexpected NNZ of Triplet, want %v, got %v", r, c, m.NNZ(), tr.NNZ())
+		}
+		if !equalApprox(denseFromTriplet(tr), want, 1e-14) {
+			t.Errorf("r=%v,c=%v: unexpected elements of Triplet", r, c)
+		}
+		csr := m.ToCSR()
+		if s := testCSRDense(csr, r, c, want); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected %v of CSR", r, c, s)
+		}
+
+		// Round trips must give identical data.
+		if !reflect.DeepEqual(NewCSRFromTriplet(tr), csr) {
+			t.Errorf("r=%v,c=%v: DOK to Triplet to CSR differs from DOK to CSR", r, c)
+		}
+		back := NewDOK(r, c)
+		tr.Compact()
+		for _, aij := range tr.data {
+			back.Set(aij.i, aij.j, aij.v)
+		}
+		if !reflect.DeepEqual(back.data, m.data) {
+			t.Errorf("r=%v,c=%v: DOK to Triplet to DOK round trip mismatch", r, c)
+		}
+		back = NewDOK(r, c)
+		for i := 0; i < r; i++ {
+			ind, data := csr.RowView(i)
+			for k, j := range ind {
+				back.Set(i, j, data[k])
+			}
+		}
+		if !reflect.DeepEqual(back.data, m.data) {
+			t.Errorf("r=%v,c=%v: DOK to CSR to DOK round trip mismatch", r, c)
+		}
+	}
+}
+
+// stiffness1D assembles the stiffness matrix of linear finite elements on
+// n elements of random lengths into m. If sym is true, only a[i,j] with
+// the element-local i <= j are added.
+func stiffness1D(m *DOK, n int, sym bool, rnd *rand.Rand) {
+	for e := 0; e < n; e++ {
+		k := 1 / (0.5 + rnd.Float64())
+		m.Add(e, e, k)
+		m.Add(e+1, e+1, k)
+		m.Add(e, e+1, -k)
+		if !sym {
+			m.Add(e+1, e, -k)
+		}
+	}
+}
+
+func TestSymDOK(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 20} {
+		full := NewDOK(n+1, n+1)
+		stiffness1D(full, n, false, rand.New(rand.NewSource(1)))
+		sym := NewSymDOK(n + 1)
+		stiffness1D(sym, n, true, rand.New(rand.NewSource(1)))
+
+		if !sym.Symmetric() || full.Symmetric() {
+			t.Errorf("n=%v: unexpected symmetry mode", n)
+		}
+		if !full.IsSymmetric(0) {
+			t.Errorf("n=%v: full stiffness matrix not symmetric", n)
+		}
+		if sym.NNZ() != n+1+n {
+			t.Errorf("n=%v: unexpected NNZ of symmetric matrix, want %v, got %v", n, 2*n+1, sym.NNZ())
+		}
+		want := make([]float64, (n+1)*(n+1))
+		for i := 0; i <= n; i++ {
+			for j := 0; j <= n; j++ {
+				want[i*(n+1)+j] = full.At(i, j)
+				if sym.At(i, j) != full.At(i, j) {
+					t.Errorf("n=%v: unexpected element at (%v,%v)", n, i, j)
+				}
+			}
+		}
+		rnd := rand.New(rand.NewSource(1))
+		if s := testMulVec(sym, want, rnd); s != "" {
+			t.Errorf("n=%v: unexpected result of %v", n, s)
+		}
+		if !reflect.DeepEqual(sym.ToCSR(), full.ToCSR()) {
+			t.Errorf("n=%v: unexpected conversion to CSR", n)
+		}
+		fromSym, err := sym.ToSymCSR(0)
+		if err != nil {
+			t.Fatalf("n=%v: unexpected error: %v", n, err)
+		}
+		fromFull, err := full.ToSymCSR(0)
+		if err != nil {
+			t.Fatalf("n=%v: unexpected error: %v", n, err)
+		}
+		if !reflect.DeepEqual(fromSym, fromFull) {
+			t.Errorf("n=%v: unexpected conversion to SymCSR", n)
+		}
+		if fromSym.NNZ() != sym.NNZ() {
+			t.Errorf("n=%v: unexpected NNZ of SymCSR", n)
+		}
+
+		// Setting an element in the lower triangle sets the mirrored
+		// element.
+		sym.Set(n, 0, 3)
+		if sym.At(0, n) != 3 || sym.At(n, 0) != 3 {
+			t.Errorf("n=%v: element not mirrored", n)
+		}
+		sym.Delete(n, 0)
+		if sym.At(0, n) != 0 || sym.At(n, 0) != 0 {
+			t.Errorf("n=%v: mirrored element not deleted", n)
+		}
+	}
+
+	a := NewDOK(2, 2)
+	a.Set(0, 1, 1)
+	a.Set(1, 0, 1+1e-10)
+	if !a.IsSymmetric(1e-8) || a.IsSymmetric(1e-12) {
+		t.Errorf("unexpected result of IsSymmetric")
+	}
+	if _, err := a.ToSymCSR(1e-12); err == nil {
+		t.Errorf("missing error for nonsymmetric matrix")
+	}
+	if NewDOK(2, 3).IsSymmetric(0) {
+		t.Errorf("rectangular matrix symmetric")
+	}
+	if _, err := NewDOK(2, 3).ToSymCSR(0); err == nil {
+		t.Errorf("missing error for rectangular matrix")
+	}
+}
+
+func TestDOKSnapshot(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, sym := range []bool{false, true} {
+		const n = 20
+		var m *DOK
+		if sym {
+			m = NewSymDOK(n)
+		} else {
+			m = NewDOK(n, n)
+		}
+		for k := 0; k < 60; k++ {
+			m.Set(rnd.Intn(n), rnd.Intn(n), rnd.NormFloat64())
+		}
+		x := randomVec(n, rnd)
+		check := func(op string) {
+			var want mat.Dense
+			m.ToDense(&want)
+			got := make([]float64, n)
+			m.MulVec(got, x)
+			if !equalApprox(got, denseMulVec(want.RawMatrix().Data, n, n, false, x), 1e-14) {
+				t.Errorf("sym=%v: unexpected MulVec after %v", sym, op)
+			}
+			m.MulTransVec(got, x)
+			if !equalApprox(got, denseMulVec(want.RawMatrix().Data, n, n, true, x), 1e-14) {
+				t.Errorf("sym=%v: unexpected MulTransVec after %v", sym, op)
+			}
+		}
+		check("assembly")
+
+		// Repeated products reuse the snapshot and are bitwise
+		// reproducible.
+		csr := m.snapshot()
+		first := make([]float64, n)
+		second := make([]float64, n)
+		m.MulVec(first, x)
+		m.MulVec(second, x)
+		if m.snapshot() != csr {
+			t.Errorf("sym=%v: snapshot rebuilt without modification", sym)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("sym=%v: repeated products differ", sym)
+		}
+
+		for _, test := range []struct {
+			op string
+			f  func()
+		}{
+			{"Set", func() { m.Set(3, 7, 2.5) }},
+			{"Add", func() { m.Add(7, 3, -1) }},
+			{"Delete", func() { m.Delete(3, 7) }},
+			{"SetAll", func() { m.SetAll(Entry{0, n - 1, 4}, Entry{n - 1, n - 1, 1}) }},
+		} {
+			m.MulVec(first, x)
+			test.f()
+			if m.csr != nil {
+				t.Errorf("sym=%v: snapshot not invalidated by %v", sym, test.op)
+			}
+			check(test.op)
+		}
+	}
+}
+
+func benchDOK() *DOK {
+	t := benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1)))
+	m := NewDOK(benchN, benchN)
+	for _, aij := range t.data {
+		m.Add(aij.i, aij.j, aij.v)
+	}
+	return m
+}
+
+func BenchmarkDOKMulVec(b *testing.B) {
+	m := benchDOK()
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
+
+// BenchmarkDOKMulVecMap measures the product computed by iterating over the
+// map as DOK did before it kept a CSR snapshot.
+func BenchmarkDOKMulVecMap(b *testing.B) {
+	m := benchDOK()
+	benchmarkMulVec(b, func(dst, x []float64) {
+		for i := range dst {
+			dst[i] = 0
+		}
+		for ij, aij := range m.data {
+			dst[ij.row] += aij * x[ij.col]
+		}
+	}, benchN)
+}
@@ -0,0 +1,305 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import "fmt"
+
+// PaddingError is returned when converting to a padded format would store
+// too many explicit zeros. For such matrices CSR or SELL are more
+// suitable.
+type PaddingError struct {
+	// Overhead is the number of padding
+	// elements relative to the number of
+	// stored elements.
+	Overhead float64
+}
+
+func (e *PaddingError) Error() string {
+	return fmt.Sprintf("sparse: padding overhead %.2f too large, use CSR or SELL", e.Overhead)
+}
+
+// ELL is a sparse matrix in the ELLPACK format. Each row stores the same
+// number of elements, the width, and shorter rows are padded with zeros.
+// The column indices and values of row i are stored in
+// ind[i*width:(i+1)*width] and data[i*width:(i+1)*width], respectively.
+//
+// ELL has a regular memory access pattern without branches in its
+// matrix-vector products but it is only efficient when the numbers of
+// nonzero elements in the rows do not vary much.
+type ELL struct {
+	r, c  int
+	width int
+	nnz   int
+	ind   []int
+	data  []float64
+}
+
+// NewELLFromCSR returns a new ELL matrix with the elements of a. If the
+// padding overhead, the number of padding zeros relative to the number of
+// elements of a, exceeds maxOverhead, NewELLFromCSR returns nil and a
+// *PaddingError.
+func NewELLFromCSR(a *CSR, maxOverhead float64) (*ELL, error) {
+	var width int
+	for i := 0; i < a.r; i++ {
+		if w := a.indptr[i+1] - a.indptr[i]; w > width {
+			width = w
+		}
+	}
+	nnz := len(a.data)
+	if overhead := padding(a.r*width, nnz); overhead > maxOverhead {
+		return nil, &PaddingError{Overhead: overhead}
+	}
+	m := &ELL{
+		r:     a.r,
+		c:     a.c,
+		width: width,
+		nnz:   nnz,
+		ind:   make([]int, a.r*width),
+		data:  make([]float64, a.r*width),
+	}
+	for i := 0; i < a.r; i++ {
+		ind := m.ind[i*width : (i+1)*width]
+		data := m.data[i*width : (i+1)*width]
+		n := copy(ind, a.ind[a.indptr[i]:a.indptr[i+1]])
+		copy(data, a.data[a.indptr[i]:a.indptr[i+1]])
+		// Padding zeros point to the last stored column, or to the
+		// first column in empty rows, to keep the accesses valid.
+		var last int
+		if n > 0 {
+			last = ind[n-1]
+		}
+		for k := n; k < width; k++ {
+			ind[k] = last
+		}
+	}
+	return m, nil
+}
+
+// padding returns the padding overhead of storing nnz elements in size
+// slots.
+func padding(size, nnz int) float64 {
+	if nnz == 0 {
+		if size == 0 {
+			return 0
+		}
+		return float64(size)
+	}
+	return float64(size-nnz) / float64(nnz)
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *ELL) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// NNZ returns the number of stored elements of the matrix excluding the
+// padding.
+func (m *ELL) NNZ() int {
+	return m.nnz
+}
+
+// Width returns the number of elements stored in each row.
+func (m *ELL) Width() int {
+	return m.width
+}
+
+// Overhead returns the number of padding zeros relative to the number of
+// stored elements.
+func (m *ELL) Overhead() float64 {
+	return padding(len(m.data), m.nnz)
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *ELL) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	w := m.width
+	for i := range dst {
+		ind := m.ind[i*w : (i+1)*w]
+		data := m.data[i*w : (i+1)*w]
+		var sum float64
+		for k, v := range data {
+			sum += v * x[ind[k]]
+		}
+		dst[i] = sum
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *ELL) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	for j := range dst {
+		dst[j] = 0
+	}
+	w := m.width
+	for i, xi := range x {
+		ind := m.ind[i*w : (i+1)*w]
+		data := m.data[i*w : (i+1)*w]
+		for k, v := range data {
+			dst[ind[k]] += v * xi
+		}
+	}
+}
+
+// SELL is a sparse matrix in the sliced ELLPACK format (SELL-C). The rows
+// are grouped into slices of C consecutive rows and each slice is stored in
+// the ELLPACK format with its own width, which limits the padding for
+// matrices with irregular row lengths. Within a slice the elements are
+// stored column by column, so the k-th elements of the C rows are
+// contiguous in memory.
+type SELL struct {
+	r, c  int
+	chunk int
+	nnz   int
+	// The elements of slice s start at
+	// offset sliceptr[s] and there are
+	// width[s] of them in each row.
+	sliceptr []int
+	width    []int
+	ind      []int
+	data     []float64
+}
+
+// NewSELLFromCSR returns a new SELL-C matrix with the elements of a and
+// slices of chunk rows. chunk must be positive, the last slice may have
+// fewer rows.
+func NewSELLFromCSR(a *CSR, chunk int) *SELL {
+	if chunk <= 0 {
+		panic("sparse: slice height not positive")
+	}
+	ns := (a.r + chunk - 1) / chunk
+	m := &SELL{
+		r:        a.r,
+		c:        a.c,
+		chunk:    chunk,
+		nnz:      len(a.data),
+		sliceptr: make([]int, ns+1),
+		width:    make([]int, ns),
+	}
+	for s := 0; s < ns; s++ {
+		start, end := m.rows(s)
+		var w int
+		for i := start; i < end; i++ {
+			if n := a.indptr[i+1] - a.indptr[i]; n > w {
+				w = n
+			}
+		}
+		m.width[s] = w
+		m.sliceptr[s+1] = m.sliceptr[s] + w*(end-start)
+	}
+	m.ind = make([]int, m.sliceptr[ns])
+	m.data = make([]float64, m.sliceptr[ns])
+	for s := 0; s < ns; s++ {
+		start, end := m.rows(s)
+		h := end - start
+		for i := start; i < end; i++ {
+			ind := a.ind[a.indptr[i]:a.indptr[i+1]]
+			var last int
+			if len(ind) > 0 {
+				last = ind[len(ind)-1]
+			}
+			for k := 0; k < m.width[s]; k++ {
+				p := m.sliceptr[s] + k*h + i - start
+				if k < len(ind) {
+					m.ind[p] = ind[k]
+					m.data[p] = a.data[a.indptr[i]+k]
+				} else {
+					m.ind[p] = last
+				}
+			}
+		}
+	}
+	return m
+}
+
+// rows returns the range of rows in slice s.
+func (m *SELL) rows(s int) (start, end int) {
+	start = s * m.chunk
+	end = start + m.chunk
+	if end > m.r {
+		end = m.r
+	}
+	return start, end
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *SELL) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// NNZ returns the number of stored elements of the matrix excluding the
+// padding.
+func (m *SELL) NNZ() int {
+	return m.nnz
+}
+
+// Overhead returns the number of padding zeros relative to the number of
+// stored elements.
+func (m *SELL) Overhead() float64 {
+	return padding(len(m.data), m.nnz)
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *SELL) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	for s, w := range m.width {
+		start, end := m.rows(s)
+		d := dst[start:end]
+		h := len(d)
+		p := m.sliceptr[s]
+		for k := 0; k < w; k++ {
+			ind := m.ind[p : p+h]
+			data := m.data[p : p+h]
+			for i, v := range data {
+				d[i] += v * x[ind[i]]
+			}
+			p += h
+		}
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *SELL) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	for j := range dst {
+		dst[j] = 0
+	}
+	for s, w := range m.width {
+		start, end := m.rows(s)
+		xs := x[start:end]
+		h := len(xs)
+		p := m.sliceptr[s]
+		for k := 0; k < w; k++ {
+			ind := m.ind[p : p+h]
+			data := m.data[p : p+h]
+			for i, v := range data {
+				dst[ind[i]] += v * xs[i]
+			}
+			p += h
+		}
+	}
+}
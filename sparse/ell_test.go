@@ -0,0 +1,120 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestELL(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{1, 1, 1},
+		{3, 5, 4},
+		{5, 3, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+		{7, 20, 100},
+		{50, 50, 200},
+	} {
+		r, c := test.r, test.c
+		a, want := randomCSR(r, c, test.nnz, rnd)
+
+		m, err := NewELLFromCSR(a, math.Inf(1))
+		if err != nil {
+			t.Errorf("r=%v,c=%v: unexpected error: %v", r, c, err)
+			continue
+		}
+		if gr, gc := m.Dims(); gr != r || gc != c {
+			t.Errorf("r=%v,c=%v: unexpected dimensions of ELL %v×%v", r, c, gr, gc)
+		}
+		if m.NNZ() != a.NNZ() {
+			t.Errorf("r=%v,c=%v: unexpected NNZ of ELL, want %v, got %v", r, c, a.NNZ(), m.NNZ())
+		}
+		if len(m.data) != r*m.Width() {
+			t.Errorf("r=%v,c=%v: unexpected size of ELL", r, c)
+		}
+		if s := testMulVec(m, want, rnd); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected result of ELL %v", r, c, s)
+		}
+		if s := testMulVecPanics(m); s != "" {
+			t.Errorf("r=%v,c=%v: ELL %v did not panic", r, c, s)
+		}
+		if m.Overhead() > 0 {
+			_, err = NewELLFromCSR(a, m.Overhead()/2)
+			if _, ok := err.(*PaddingError); !ok {
+				t.Errorf("r=%v,c=%v: missing padding error", r, c)
+			}
+		}
+
+		for _, chunk := range []int{1, 2, 4, 32} {
+			s := NewSELLFromCSR(a, chunk)
+			if gr, gc := s.Dims(); gr != r || gc != c {
+				t.Errorf("r=%v,c=%v,C=%v: unexpected dimensions of SELL %v×%v", r, c, chunk, gr, gc)
+			}
+			if s.NNZ() != a.NNZ() {
+				t.Errorf("r=%v,c=%v,C=%v: unexpected NNZ of SELL, want %v, got %v", r, c, chunk, a.NNZ(), s.NNZ())
+			}
+			if s.Overhead() > m.Overhead() {
+				t.Errorf("r=%v,c=%v,C=%v: SELL overhead larger than ELL", r, c, chunk)
+			}
+			if chunk == 1 && s.Overhead() != 0 {
+				t.Errorf("r=%v,c=%v: SELL-1 has padding", r, c)
+			}
+			if str := testMulVec(s, want, rnd); str != "" {
+				t.Errorf("r=%v,c=%v,C=%v: unexpected result of SELL %v", r, c, chunk, str)
+			}
+			if str := testMulVecPanics(s); str != "" {
+				t.Errorf("r=%v,c=%v,C=%v: SELL %v did not panic", r, c, chunk, str)
+			}
+		}
+	}
+}
+
+func TestELLOverhead(t *testing.T) {
+	// One full row in an otherwise diagonal matrix.
+	const n = 10
+	tr := NewTriplet(n, n)
+	for i := 0; i < n; i++ {
+		tr.Append(i, i, 1)
+		tr.Append(0, i, 1)
+	}
+	a := NewCSRFromTriplet(tr)
+	// 19 elements stored in 100 slots.
+	want := 81.0 / 19
+	_, err := NewELLFromCSR(a, 1)
+	perr, ok := err.(*PaddingError)
+	if !ok {
+		t.Fatalf("missing padding error")
+	}
+	if math.Abs(perr.Overhead-want) > 1e-14 {
+		t.Errorf("unexpected overhead, want %v, got %v", want, perr.Overhead)
+	}
+	if s := NewSELLFromCSR(a, 2); s.Overhead() >= want {
+		t.Errorf("SELL-2 overhead %v not smaller than ELL overhead %v", s.Overhead(), want)
+	}
+	if !panics(func() { NewSELLFromCSR(a, 0) }) {
+		t.Errorf("NewSELLFromCSR with zero chunk did not panic")
+	}
+}
+
+func BenchmarkELLMulVecPoisson(b *testing.B) {
+	m, err := NewELLFromCSR(poisson2D(benchPoissonN, benchPoissonN).ToCSR(), 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkMulVec(b, m.MulVec, benchPoissonN*benchPoissonN)
+}
+
+func BenchmarkSELLMulVecPoisson(b *testing.B) {
+	m := NewSELLFromCSR(poisson2D(benchPoissonN, benchPoissonN).ToCSR(), 8)
+	benchmarkMulVec(b, m.MulVec, benchPoissonN*benchPoissonN)
+}
@@ -0,0 +1,126 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Extract returns the submatrix A(rows, cols) of a, that is, the matrix
+// whose element at row k and column l is a[rows[k],cols[l]]. The indices in
+// rows and cols must be valid and distinct but they need not be sorted. If
+// they are not, Extract returns an error.
+func Extract(a *CSR, rows, cols []int) (*CSR, error) {
+	if err := checkIndexSet(rows, a.r, "row"); err != nil {
+		return nil, err
+	}
+	// pos is a marker array mapping the columns of a to the columns of
+	// the submatrix, -1 for the columns not in cols.
+	pos := make([]int, a.c)
+	for j := range pos {
+		pos[j] = -1
+	}
+	for l, j := range cols {
+		if j < 0 || a.c <= j {
+			return nil, fmt.Errorf("sparse: column index %d out of range", j)
+		}
+		if pos[j] >= 0 {
+			return nil, fmt.Errorf("sparse: duplicate column index %d", j)
+		}
+		pos[j] = l
+	}
+	sorted := sort.IntsAreSorted(cols)
+
+	m := &CSR{
+		r:      len(rows),
+		c:      len(cols),
+		indptr: make([]int, len(rows)+1),
+	}
+	for k, i := range rows {
+		start := len(m.ind)
+		for p := a.indptr[i]; p < a.indptr[i+1]; p++ {
+			if l := pos[a.ind[p]]; l >= 0 {
+				m.ind = append(m.ind, l)
+				m.data = append(m.data, a.data[p])
+			}
+		}
+		if !sorted {
+			sort.Sort(byColumn{m.ind[start:], m.data[start:]})
+		}
+		m.indptr[k+1] = len(m.ind)
+	}
+	if m.ind == nil {
+		m.ind = []int{}
+		m.data = []float64{}
+	}
+	return m, nil
+}
+
+// checkIndexSet returns an error if the indices in set are not distinct
+// or not in [0, n).
+func checkIndexSet(set []int, n int, kind string) error {
+	seen := make([]bool, n)
+	for _, i := range set {
+		if i < 0 || n <= i {
+			return fmt.Errorf("sparse: %s index %d out of range", kind, i)
+		}
+		if seen[i] {
+			return fmt.Errorf("sparse: duplicate %s index %d", kind, i)
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// ExtractDiagonalBlocks returns the dense diagonal blocks of the square
+// matrix a given by the partition of its rows and columns into contiguous
+// ranges. The k-th block is
+//  A(starts[k]:starts[k+1], starts[k]:starts[k+1])
+// where the last block extends to the end of the matrix. starts must begin
+// with 0 and be strictly increasing and smaller than the dimension of a,
+// otherwise ExtractDiagonalBlocks returns an error.
+func ExtractDiagonalBlocks(a *CSR, starts []int) ([]*mat.Dense, error) {
+	if a.r != a.c {
+		return nil, fmt.Errorf("sparse: %d×%d matrix not square", a.r, a.c)
+	}
+	if a.r == 0 {
+		if len(starts) != 0 {
+			return nil, fmt.Errorf("sparse: block starts for an empty matrix")
+		}
+		return nil, nil
+	}
+	if len(starts) == 0 || starts[0] != 0 {
+		return nil, fmt.Errorf("sparse: first block does not start at 0")
+	}
+	for k := 1; k < len(starts); k++ {
+		if starts[k] <= starts[k-1] {
+			return nil, fmt.Errorf("sparse: block starts not increasing at %d", k)
+		}
+	}
+	if starts[len(starts)-1] >= a.r {
+		return nil, fmt.Errorf("sparse: block start %d out of range", starts[len(starts)-1])
+	}
+	blocks := make([]*mat.Dense, len(starts))
+	for k, start := range starts {
+		end := a.r
+		if k+1 < len(starts) {
+			end = starts[k+1]
+		}
+		n := end - start
+		block := mat.NewDense(n, n, nil)
+		for i := start; i < end; i++ {
+			ind, data := a.RowView(i)
+			p := sort.SearchInts(ind, start)
+			for ; p < len(ind) && ind[p] < end; p++ {
+				block.Set(i-start, ind[p]-start, data[p])
+			}
+		}
+		blocks[k] = block
+	}
+	return blocks, nil
+}
@@ -0,0 +1,136 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 1},
+		{3, 5, 8},
+		{10, 10, 40},
+		{20, 7, 60},
+	} {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		for trial := 0; trial < 10; trial++ {
+			var rows, cols []int
+			switch trial {
+			case 0:
+				// Empty sets.
+			case 1:
+				// Full sets.
+				rows = make([]int, r)
+				cols = make([]int, c)
+				for i := range rows {
+					rows[i] = i
+				}
+				for j := range cols {
+					cols[j] = j
+				}
+			default:
+				rows = rnd.Perm(r)[:rnd.Intn(r+1)]
+				cols = rnd.Perm(c)[:rnd.Intn(c+1)]
+			}
+			want := make([]float64, len(rows)*len(cols))
+			for k, i := range rows {
+				for l, j := range cols {
+					want[k*len(cols)+l] = dense[i*c+j]
+				}
+			}
+			m, err := Extract(a, rows, cols)
+			if err != nil {
+				t.Errorf("r=%v,c=%v: unexpected error: %v", r, c, err)
+				continue
+			}
+			if s := testCSRDense(m, len(rows), len(cols), want); s != "" {
+				t.Errorf("r=%v,c=%v,rows=%v,cols=%v: unexpected %v of submatrix", r, c, rows, cols, s)
+			}
+		}
+	}
+
+	a, _ := randomCSR(4, 5, 10, rnd)
+	for _, test := range []struct {
+		name       string
+		rows, cols []int
+	}{
+		{"negative row", []int{0, -1}, []int{0}},
+		{"large row", []int{4}, []int{0}},
+		{"duplicate row", []int{1, 2, 1}, []int{0}},
+		{"negative column", []int{0}, []int{-1}},
+		{"large column", []int{0}, []int{5}},
+		{"duplicate column", []int{0}, []int{3, 3}},
+	} {
+		if _, err := Extract(a, test.rows, test.cols); err == nil {
+			t.Errorf("missing error for %v", test.name)
+		}
+	}
+}
+
+func TestExtractDiagonalBlocks(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const n = 10
+	a, dense := randomCSR(n, n, 50, rnd)
+	for _, starts := range [][]int{
+		{0},
+		{0, 5},
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{0, 3, 4, 8},
+	} {
+		blocks, err := ExtractDiagonalBlocks(a, starts)
+		if err != nil {
+			t.Errorf("starts=%v: unexpected error: %v", starts, err)
+			continue
+		}
+		if len(blocks) != len(starts) {
+			t.Errorf("starts=%v: unexpected number of blocks", starts)
+			continue
+		}
+		for k, b := range blocks {
+			end := n
+			if k+1 < len(starts) {
+				end = starts[k+1]
+			}
+			if br, bc := b.Dims(); br != end-starts[k] || bc != br {
+				t.Errorf("starts=%v: unexpected dimensions of block %v", starts, k)
+				continue
+			}
+			for i := starts[k]; i < end; i++ {
+				for j := starts[k]; j < end; j++ {
+					if b.At(i-starts[k], j-starts[k]) != dense[i*n+j] {
+						t.Errorf("starts=%v: unexpected element (%v,%v) of block %v", starts, i, j, k)
+					}
+				}
+			}
+		}
+	}
+
+	for _, starts := range [][]int{
+		nil,
+		{1, 5},
+		{0, 5, 5},
+		{0, 6, 3},
+		{0, 10},
+	} {
+		if _, err := ExtractDiagonalBlocks(a, starts); err == nil {
+			t.Errorf("starts=%v: missing error", starts)
+		}
+	}
+	rect, _ := randomCSR(3, 4, 5, rnd)
+	if _, err := ExtractDiagonalBlocks(rect, []int{0}); err == nil {
+		t.Errorf("missing error for rectangular matrix")
+	}
+	empty, _ := randomCSR(0, 0, 0, rnd)
+	if blocks, err := ExtractDiagonalBlocks(empty, nil); err != nil || len(blocks) != 0 {
+		t.Errorf("unexpected result for empty matrix: %v, %v", blocks, err)
+	}
+}
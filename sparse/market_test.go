@@ -0,0 +1,168 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse_test
+
+import (
+	"compress/gzip"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+var marketNames = []string{
+	"nos1", "nos4", "bcsstm20", "gre__115", "impcol_e", "west0167", "fs_183_1",
+}
+
+// readMarket returns a test matrix from the Matrix Market in the testdata
+// directory of the iterative package.
+func readMarket(name string) (*sparse.Triplet, error) {
+	f, err := os.Open("../testdata/" + name + ".mtx.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return mmarket.NewReader(gz).Read()
+}
+
+type matVecer interface {
+	MulVec(dst, x []float64)
+	MulTransVec(dst, x []float64)
+}
+
+func TestMarketFormats(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, name := range marketNames {
+		tr, err := readMarket(name)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		csr := sparse.NewCSRFromTriplet(tr)
+		ell, err := sparse.NewELLFromCSR(csr, math.Inf(1))
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", name, err)
+		}
+		r, c := csr.Dims()
+		for _, test := range []struct {
+			format string
+			m      matVecer
+		}{
+			{"ELL", ell},
+			{"SELL-4", sparse.NewSELLFromCSR(csr, 4)},
+		} {
+			for _, trans := range []bool{false, true} {
+				n, dim := c, r
+				mul, want := test.m.MulVec, csr.MulVec
+				if trans {
+					n, dim = r, c
+					mul, want = test.m.MulTransVec, csr.MulTransVec
+				}
+				x := make([]float64, n)
+				for i := range x {
+					x[i] = rnd.NormFloat64()
+				}
+				got := make([]float64, dim)
+				mul(got, x)
+				ref := make([]float64, dim)
+				want(ref, x)
+				for i, v := range ref {
+					if math.Abs(got[i]-v) > 1e-12*math.Max(1, math.Abs(v)) {
+						t.Errorf("%v,%v,trans=%v: unexpected product", name, test.format, trans)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestAnalyzeMarket(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		symmetric bool
+		zeroDiag  bool
+	}{
+		{name: "nos4", symmetric: true},
+		{name: "west0167", symmetric: false, zeroDiag: true},
+	} {
+		tr, err := readMarket(test.name)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		a := sparse.NewCSRFromTriplet(tr)
+		r := sparse.Analyze(a)
+		n, _ := a.Dims()
+		if r.N != n || r.NNZ != a.NNZ() {
+			t.Errorf("%v: unexpected dimensions in report", test.name)
+		}
+		if r.StructurallySymmetric != test.symmetric {
+			t.Errorf("%v: unexpected structural symmetry %v", test.name, r.StructurallySymmetric)
+		}
+		if r.Symmetric(1e-14) != test.symmetric {
+			t.Errorf("%v: unexpected numerical symmetry, asymmetry %v", test.name, r.Asymmetry)
+		}
+		if (r.ZeroDiagonals > 0) != test.zeroDiag {
+			t.Errorf("%v: unexpected number of zero diagonals %v", test.name, r.ZeroDiagonals)
+		}
+		if test.symmetric && r.LowerBandwidth != r.UpperBandwidth {
+			t.Errorf("%v: bandwidths of symmetric matrix differ", test.name)
+		}
+		if r.MinRowNNZ > r.MaxRowNNZ || r.MeanRowNNZ < float64(r.MinRowNNZ) || r.MeanRowNNZ > float64(r.MaxRowNNZ) {
+			t.Errorf("%v: inconsistent row statistics", test.name)
+		}
+		if r.String() == "" {
+			t.Errorf("%v: empty summary", test.name)
+		}
+	}
+}
+
+func TestRCMMarket(t *testing.T) {
+	// RCM reduces the bandwidth of the nos
+	// matrices after a random reordering to
+	// about the bandwidth of their original
+	// ordering, which is already small for
+	// nos1 and nos4 but not for nos5.
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name   string
+		shrink bool
+	}{
+		{name: "nos1"},
+		{name: "nos4"},
+		{name: "nos5", shrink: true},
+	} {
+		tr, err := readMarket(test.name)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		a := sparse.NewCSRFromTriplet(tr)
+		n, _ := a.Dims()
+		band := sparse.Analyze(a).LowerBandwidth
+		shuffle := rnd.Perm(n)
+		shuffled := sparse.Permute(a, shuffle, shuffle)
+		perm, err := sparse.RCM(shuffled)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.name, err)
+		}
+		before := sparse.Analyze(shuffled).LowerBandwidth
+		after := sparse.Analyze(sparse.Permute(shuffled, perm, perm)).LowerBandwidth
+		if 4*after > before || after > 2*band {
+			t.Errorf("%v: bandwidth %d after RCM of a random ordering with bandwidth %d, original %d", test.name, after, before, band)
+		}
+
+		perm, _ = sparse.RCM(a)
+		after = sparse.Analyze(sparse.Permute(a, perm, perm)).LowerBandwidth
+		if test.shrink && after >= band {
+			t.Errorf("%v: bandwidth %d after RCM of the original ordering with bandwidth %d", test.name, after, band)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+var (
+	_ mat.Matrix = (*DOK)(nil)
+	_ mat.Matrix = (*Triplet)(nil)
+	_ mat.Matrix = (*CSR)(nil)
+)
+
+// T returns the transpose of the matrix as an implicit view that does not
+// copy the data.
+func (m *DOK) T() mat.Matrix {
+	return mat.Transpose{Matrix: m}
+}
+
+// T returns the transpose of the matrix as an implicit view that does not
+// copy the data.
+func (m *Triplet) T() mat.Matrix {
+	return mat.Transpose{Matrix: m}
+}
+
+// T returns the transpose of the matrix as an implicit view that does not
+// copy the data.
+func (m *CSR) T() mat.Matrix {
+	return mat.Transpose{Matrix: m}
+}
+
+// At returns the element of the matrix at row i and column j, the sum of
+// all triplets with row i and column j. At needs a binary search if the
+// triplets are sorted by SortByRow or Compact and a linear scan otherwise.
+func (m *Triplet) At(i, j int) float64 {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	var v float64
+	if !m.sorted {
+		for _, aij := range m.data {
+			if aij.i == i && aij.j == j {
+				v += aij.v
+			}
+		}
+		return v
+	}
+	k := sort.Search(len(m.data), func(k int) bool {
+		aij := m.data[k]
+		return aij.i > i || (aij.i == i && aij.j >= j)
+	})
+	for ; k < len(m.data) && m.data[k].i == i && m.data[k].j == j; k++ {
+		v += m.data[k].v
+	}
+	return v
+}
+
+// ToDense stores the matrix into dst. If dst is empty, it is resized to
+// the dimensions of the matrix, otherwise its dimensions must match.
+func (m *DOK) ToDense(dst *mat.Dense) {
+	reuseZero(dst, m.r, m.c)
+	for ij, v := range m.data {
+		dst.Set(ij.row, ij.col, v)
+		if m.sym && ij.row != ij.col {
+			dst.Set(ij.col, ij.row, v)
+		}
+	}
+}
+
+// ToDense stores the matrix into dst. If dst is empty, it is resized to
+// the dimensions of the matrix, otherwise its dimensions must match.
+func (m *Triplet) ToDense(dst *mat.Dense) {
+	reuseZero(dst, m.r, m.c)
+	raw := dst.RawMatrix()
+	for _, aij := range m.data {
+		raw.Data[aij.i*raw.Stride+aij.j] += aij.v
+	}
+}
+
+// ToDense stores the matrix into dst. If dst is empty, it is resized to
+// the dimensions of the matrix, otherwise its dimensions must match.
+func (m *CSR) ToDense(dst *mat.Dense) {
+	reuseZero(dst, m.r, m.c)
+	for i := 0; i < m.r; i++ {
+		for k := m.indptr[i]; k < m.indptr[i+1]; k++ {
+			dst.Set(i, m.ind[k], m.data[k])
+		}
+	}
+}
+
+// reuseZero resizes an empty dst to r×c or checks its dimensions, and
+// zeroes it.
+func reuseZero(dst *mat.Dense, r, c int) {
+	if dst.IsEmpty() {
+		if r == 0 || c == 0 {
+			panic("sparse: zero dimension")
+		}
+		dst.ReuseAs(r, c)
+	} else if dr, dc := dst.Dims(); dr != r || dc != c {
+		panic("sparse: dimension mismatch")
+	}
+	dst.Zero()
+}
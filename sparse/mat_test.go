@@ -0,0 +1,85 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+type denser interface {
+	mat.Matrix
+	ToDense(*mat.Dense)
+}
+
+func TestMatMatrix(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{1, 1, 0},
+		{1, 1, 3},
+		{3, 5, 10},
+		{5, 3, 10},
+		{10, 10, 50},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := mat.NewDense(r, c, denseFromEntries(r, c, rows, cols, vals))
+
+		compacted := NewTripletFromSlices(r, c, rows, cols, vals)
+		compacted.Compact()
+		sorted := NewTripletFromSlices(r, c, rows, cols, vals)
+		sorted.SortByRow()
+		for _, m := range []struct {
+			name string
+			m    denser
+		}{
+			{"DOK", NewDOKFromSlices(r, c, rows, cols, vals)},
+			{"Triplet", NewTripletFromSlices(r, c, rows, cols, vals)},
+			{"sorted Triplet", sorted},
+			{"compacted Triplet", compacted},
+			{"CSR", NewCSRFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals))},
+		} {
+			if !mat.EqualApprox(m.m, want, 1e-14) {
+				t.Errorf("%v,r=%v,c=%v: matrix not equal to dense", m.name, r, c)
+			}
+			if !mat.EqualApprox(m.m.T(), want.T(), 1e-14) {
+				t.Errorf("%v,r=%v,c=%v: transpose not equal to dense", m.name, r, c)
+			}
+			var got mat.Dense
+			m.m.ToDense(&got)
+			if !mat.EqualApprox(&got, want, 1e-14) {
+				t.Errorf("%v,r=%v,c=%v: unexpected ToDense", m.name, r, c)
+			}
+			// Reuse of a non-empty destination.
+			m.m.ToDense(&got)
+			if !mat.EqualApprox(&got, want, 1e-14) {
+				t.Errorf("%v,r=%v,c=%v: unexpected ToDense into used matrix", m.name, r, c)
+			}
+			if !panics(func() { m.m.ToDense(mat.NewDense(r+1, c, nil)) }) {
+				t.Errorf("%v,r=%v,c=%v: ToDense with wrong dimensions did not panic", m.name, r, c)
+			}
+			gotFmt := fmt.Sprintf("%.6v", mat.Formatted(m.m))
+			wantFmt := fmt.Sprintf("%.6v", mat.Formatted(&got))
+			if gotFmt != wantFmt {
+				t.Errorf("%v,r=%v,c=%v: unexpected formatted output\n%v\nwant\n%v", m.name, r, c, gotFmt, wantFmt)
+			}
+		}
+	}
+
+	sym := NewSymDOK(3)
+	sym.Set(0, 2, 1)
+	sym.Set(1, 1, 2)
+	var got mat.Dense
+	sym.ToDense(&got)
+	want := mat.NewDense(3, 3, []float64{0, 0, 1, 0, 2, 0, 1, 0, 0})
+	if !mat.Equal(&got, want) || !mat.Equal(sym, want) {
+		t.Errorf("unexpected dense symmetric DOK")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import "math"
+
+// RowNorms returns the p-norms of the rows of a. p must be at least 1, the
+// common cases 1, 2 and math.Inf(1) are computed without calls to
+// math.Pow.
+func RowNorms(a *CSR, p float64) []float64 {
+	if p < 1 {
+		panic("sparse: invalid norm")
+	}
+	norms := make([]float64, a.r)
+	for i := range norms {
+		norms[i] = norm(a.data[a.indptr[i]:a.indptr[i+1]], p)
+	}
+	return norms
+}
+
+// ColNorms returns the p-norms of the columns of a. p must be at least 1,
+// the common cases 1, 2 and math.Inf(1) are computed without calls to
+// math.Pow.
+func ColNorms(a *CSR, p float64) []float64 {
+	if p < 1 {
+		panic("sparse: invalid norm")
+	}
+	norms := make([]float64, a.c)
+	switch {
+	case p == 1:
+		for k, v := range a.data {
+			norms[a.ind[k]] += math.Abs(v)
+		}
+	case p == 2:
+		// Scale the sums to avoid overflow.
+		scale := make([]float64, a.c)
+		for k, v := range a.data {
+			j := a.ind[k]
+			if v == 0 {
+				continue
+			}
+			absv := math.Abs(v)
+			if scale[j] < absv {
+				norms[j] = 1 + norms[j]*(scale[j]/absv)*(scale[j]/absv)
+				scale[j] = absv
+			} else {
+				norms[j] += (absv / scale[j]) * (absv / scale[j])
+			}
+		}
+		for j, s := range scale {
+			norms[j] = s * math.Sqrt(norms[j])
+		}
+	case math.IsInf(p, 1):
+		for k, v := range a.data {
+			norms[a.ind[k]] = math.Max(norms[a.ind[k]], math.Abs(v))
+		}
+	default:
+		for k, v := range a.data {
+			norms[a.ind[k]] += math.Pow(math.Abs(v), p)
+		}
+		for j, s := range norms {
+			norms[j] = math.Pow(s, 1/p)
+		}
+	}
+	return norms
+}
+
+// norm returns the p-norm of x.
+func norm(x []float64, p float64) float64 {
+	var sum float64
+	switch {
+	case p == 1:
+		for _, v := range x {
+			sum += math.Abs(v)
+		}
+		return sum
+	case p == 2:
+		var scale float64
+		sum = 1
+		for _, v := range x {
+			if v == 0 {
+				continue
+			}
+			absv := math.Abs(v)
+			if scale < absv {
+				sum = 1 + sum*(scale/absv)*(scale/absv)
+				scale = absv
+			} else {
+				sum += (absv / scale) * (absv / scale)
+			}
+		}
+		return scale * math.Sqrt(sum)
+	case math.IsInf(p, 1):
+		for _, v := range x {
+			sum = math.Max(sum, math.Abs(v))
+		}
+		return sum
+	}
+	for _, v := range x {
+		sum += math.Pow(math.Abs(v), p)
+	}
+	return math.Pow(sum, 1/p)
+}
+
+// Diagonal returns the diagonal of a. The length of the diagonal is the
+// smaller of the dimensions of a, elements that are not stored are zero.
+func Diagonal(a *CSR) []float64 {
+	n := a.r
+	if a.c < n {
+		n = a.c
+	}
+	d := make([]float64, n)
+	for i := range d {
+		d[i] = a.At(i, i)
+	}
+	return d
+}
+
+// ScaleRows scales the rows of a in place, row i is multiplied by s[i].
+// The length of s must be equal to the number of rows of a.
+func ScaleRows(a *CSR, s []float64) {
+	if len(s) != a.r {
+		panic("sparse: dimension mismatch")
+	}
+	for i, si := range s {
+		row := a.data[a.indptr[i]:a.indptr[i+1]]
+		for k := range row {
+			row[k] *= si
+		}
+	}
+}
+
+// ScaleCols scales the columns of a in place, column j is multiplied by
+// s[j]. The length of s must be equal to the number of columns of a.
+func ScaleCols(a *CSR, s []float64) {
+	if len(s) != a.c {
+		panic("sparse: dimension mismatch")
+	}
+	for k, j := range a.ind {
+		a.data[k] *= s[j]
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNorms(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		for _, p := range []float64{1, 1.5, 2, 3, math.Inf(1)} {
+			wantRows := make([]float64, r)
+			wantCols := make([]float64, c)
+			for i := 0; i < r; i++ {
+				for j := 0; j < c; j++ {
+					v := math.Abs(dense[i*c+j])
+					if math.IsInf(p, 1) {
+						wantRows[i] = math.Max(wantRows[i], v)
+						wantCols[j] = math.Max(wantCols[j], v)
+					} else {
+						wantRows[i] += math.Pow(v, p)
+						wantCols[j] += math.Pow(v, p)
+					}
+				}
+			}
+			if !math.IsInf(p, 1) {
+				for i := range wantRows {
+					wantRows[i] = math.Pow(wantRows[i], 1/p)
+				}
+				for j := range wantCols {
+					wantCols[j] = math.Pow(wantCols[j], 1/p)
+				}
+			}
+			if got := RowNorms(a, p); !equalApprox(got, wantRows, 1e-14) {
+				t.Errorf("r=%v,c=%v,p=%v: unexpected row norms, want %v, got %v", r, c, p, wantRows, got)
+			}
+			if got := ColNorms(a, p); !equalApprox(got, wantCols, 1e-14) {
+				t.Errorf("r=%v,c=%v,p=%v: unexpected column norms, want %v, got %v", r, c, p, wantCols, got)
+			}
+		}
+	}
+
+	// The 2-norm must not overflow.
+	tr := NewTriplet(1, 2)
+	tr.Append(0, 0, 1e300)
+	tr.Append(0, 1, 1e300)
+	a := NewCSRFromTriplet(tr)
+	want := math.Sqrt2 * 1e300
+	if got := RowNorms(a, 2)[0]; math.Abs(got-want) > 1e-14*want {
+		t.Errorf("unexpected 2-norm of large row, want %v, got %v", want, got)
+	}
+	if got := ColNorms(Transpose(a), 2)[0]; math.Abs(got-want) > 1e-14*want {
+		t.Errorf("unexpected 2-norm of large column, want %v, got %v", want, got)
+	}
+
+	if !panics(func() { RowNorms(a, 0.5) }) {
+		t.Errorf("RowNorms with p < 1 did not panic")
+	}
+	if !panics(func() { ColNorms(a, 0.5) }) {
+		t.Errorf("ColNorms with p < 1 did not panic")
+	}
+}
+
+func TestDiagonal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		n := r
+		if c < n {
+			n = c
+		}
+		want := make([]float64, n)
+		for i := range want {
+			want[i] = dense[i*c+i]
+		}
+		if got := Diagonal(a); !equalApprox(got, want, 1e-14) {
+			t.Errorf("r=%v,c=%v: unexpected diagonal, want %v, got %v", r, c, want, got)
+		}
+	}
+}
+
+func TestScaleRowsCols(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range algebraDims {
+		r, c := test.r, test.c
+		a, dense := randomCSR(r, c, test.nnz, rnd)
+		sr := randomVec(r, rnd)
+		sc := randomVec(c, rnd)
+		want := make([]float64, r*c)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				want[i*c+j] = sr[i] * dense[i*c+j] * sc[j]
+			}
+		}
+		ScaleRows(a, sr)
+		ScaleCols(a, sc)
+		if s := testCSRDense(a, r, c, want); s != "" {
+			t.Errorf("r=%v,c=%v: unexpected %v of scaled matrix", r, c, s)
+		}
+		if !panics(func() { ScaleRows(a, make([]float64, r+1)) }) {
+			t.Errorf("r=%v,c=%v: ScaleRows with wrong length did not panic", r, c)
+		}
+		if !panics(func() { ScaleCols(a, make([]float64, c+1)) }) {
+			t.Errorf("r=%v,c=%v: ScaleCols with wrong length did not panic", r, c)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RCM returns the reverse Cuthill-McKee ordering of the square matrix a. The
+// ordering is computed from the structure of A+A^T, so it is defined also for
+// matrices that are not structurally symmetric. The k-th row and column of
+// the reordered matrix are the perm[k]-th row and column of a, that is,
+//  Permute(a, perm, perm)
+// returns the reordered matrix. RCM reduces the bandwidth and the profile of
+// the matrix which reduces the fill-in of factorizations and improves the
+// quality of incomplete factorizations. Each connected component is started
+// from a pseudo-peripheral vertex. RCM returns an error if a is not square.
+func RCM(a *CSR) (perm []int, err error) {
+	if a.r != a.c {
+		return nil, fmt.Errorf("sparse: %d×%d matrix not square", a.r, a.c)
+	}
+	n := a.r
+	adj, start := symmetricPattern(a)
+	degree := func(i int) int { return start[i+1] - start[i] }
+
+	perm = make([]int, 0, n)
+	visited := make([]bool, n)
+	level := make([]int, n)
+	for {
+		// Start the next component from its vertex of
+		// minimum degree.
+		root := -1
+		for i := 0; i < n; i++ {
+			if !visited[i] && (root < 0 || degree(i) < degree(root)) {
+				root = i
+			}
+		}
+		if root < 0 {
+			break
+		}
+		root = peripheral(root, adj, start, level)
+
+		// Cuthill-McKee breadth-first search with the
+		// neighbors visited by increasing degree.
+		first := len(perm)
+		perm = append(perm, root)
+		visited[root] = true
+		for k := first; k < len(perm); k++ {
+			i := perm[k]
+			next := len(perm)
+			for _, j := range adj[start[i]:start[i+1]] {
+				if !visited[j] {
+					visited[j] = true
+					perm = append(perm, j)
+				}
+			}
+			nb := perm[next:]
+			sort.SliceStable(nb, func(p, q int) bool { return degree(nb[p]) < degree(nb[q]) })
+		}
+	}
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm, nil
+}
+
+// Permute returns the matrix a with permuted rows and columns, the element
+// at row k and column l of the result is a[rowPerm[k],colPerm[l]]. In
+// matrix notation the result is
+//  P A Q^T
+// with the permutation matrices P and Q of rowPerm and colPerm. A symmetric
+// reordering, for example by the permutation returned by RCM, uses the same
+// permutation for the rows and the columns. Permute panics if rowPerm or
+// colPerm is not a permutation of the rows or columns of a.
+func Permute(a *CSR, rowPerm, colPerm []int) *CSR {
+	if len(rowPerm) != a.r || len(colPerm) != a.c {
+		panic("sparse: permutation length mismatch")
+	}
+	m, err := Extract(a, rowPerm, colPerm)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// PermuteVec stores the permuted vector P x into dst, that is,
+//  dst[k] = x[perm[k]],
+// so that the solution of the system P A P^T y = P b is y = P x for the
+// solution x of A x = b. dst and x must not overlap. PermuteVec panics if
+// the lengths of dst, x and perm differ.
+func PermuteVec(dst, x []float64, perm []int) {
+	if len(dst) != len(perm) || len(x) != len(perm) {
+		panic("sparse: dimension mismatch")
+	}
+	for k, i := range perm {
+		dst[k] = x[i]
+	}
+}
+
+// UnpermuteVec stores the vector P^T y into dst, that is,
+//  dst[perm[k]] = y[k],
+// which undoes PermuteVec. dst and y must not overlap. UnpermuteVec panics
+// if the lengths of dst, y and perm differ.
+func UnpermuteVec(dst, y []float64, perm []int) {
+	if len(dst) != len(perm) || len(y) != len(perm) {
+		panic("sparse: dimension mismatch")
+	}
+	for k, i := range perm {
+		dst[i] = y[k]
+	}
+}
+
+// ColorRows returns a coloring of the rows of the square matrix a such that
+// rows i and j of the same color are independent, the elements a[i,j] and
+// a[j,i] are not stored. The colors are 0, ..., numColors-1, and colors[i]
+// is the color of row i. The rows are colored greedily in their order with
+// the smallest color not taken by a neighbor in the graph of A+A^T, so
+// numColors is at most one more than the largest number of off-diagonal
+// elements in a row of A+A^T. The 5-point Laplacian on a grid gets the
+// red-black coloring with two colors.
+//
+// The unknowns of the rows of a color depend only on the unknowns of the
+// other colors, so a Gauss-Seidel sweep that processes the colors one after
+// another can update the rows within a color concurrently. ColorRows panics
+// if a is not square.
+func ColorRows(a *CSR) (colors []int, numColors int) {
+	if a.r != a.c {
+		panic("sparse: matrix not square")
+	}
+	adj, start := symmetricPattern(a)
+	colors = make([]int, a.r)
+	// mark[c] == i+1 if color c is taken by a
+	// neighbor of row i.
+	mark := make([]int, a.r+1)
+	for i := range colors {
+		for _, j := range adj[start[i]:start[i+1]] {
+			if j < i {
+				mark[colors[j]] = i + 1
+			}
+		}
+		c := 0
+		for mark[c] == i+1 {
+			c++
+		}
+		colors[i] = c
+		if c+1 > numColors {
+			numColors = c + 1
+		}
+	}
+	return colors, numColors
+}
+
+// symmetricPattern returns the adjacency lists of the graph of A+A^T
+// without the diagonal. The neighbors of i are adj[start[i]:start[i+1]].
+func symmetricPattern(a *CSR) (adj, start []int) {
+	n := a.r
+	at := Transpose(a)
+	start = make([]int, n+1)
+	adj = make([]int, 0, 2*len(a.ind))
+	for i := 0; i < n; i++ {
+		// Merge the sorted column indices of row i
+		// of A and A^T.
+		ind := a.ind[a.indptr[i]:a.indptr[i+1]]
+		tind := at.ind[at.indptr[i]:at.indptr[i+1]]
+		ka, kt := 0, 0
+		for ka < len(ind) || kt < len(tind) {
+			var j int
+			switch {
+			case kt == len(tind) || (ka < len(ind) && ind[ka] < tind[kt]):
+				j = ind[ka]
+				ka++
+			case ka == len(ind) || tind[kt] < ind[ka]:
+				j = tind[kt]
+				kt++
+			default:
+				j = ind[ka]
+				ka++
+				kt++
+			}
+			if j != i {
+				adj = append(adj, j)
+			}
+		}
+		start[i+1] = len(adj)
+	}
+	return adj, start
+}
+
+// peripheral returns a pseudo-peripheral vertex of the connected component
+// containing root found by the George-Liu algorithm: starting from root,
+// it moves to a vertex of minimum degree in the last level of the level
+// structure while the number of levels increases. level is used as
+// workspace, it must have the length of the number of vertices.
+func peripheral(root int, adj, start, level []int) int {
+	var queue []int
+	// levels builds the level structure rooted at r
+	// and returns its depth and the vertices in the
+	// last level.
+	levels := func(r int) (depth int, last []int) {
+		for _, i := range queue {
+			level[i] = 0
+		}
+		queue = append(queue[:0], r)
+		level[r] = 1
+		lastStart := 0
+		for k := 0; k < len(queue); k++ {
+			i := queue[k]
+			if level[i] > depth {
+				depth = level[i]
+				lastStart = k
+			}
+			for _, j := range adj[start[i]:start[i+1]] {
+				if level[j] == 0 {
+					level[j] = level[i] + 1
+					queue = append(queue, j)
+				}
+			}
+		}
+		return depth, queue[lastStart:]
+	}
+	depth, last := levels(root)
+	for {
+		next := last[0]
+		for _, i := range last[1:] {
+			if start[i+1]-start[i] < start[next+1]-start[next] {
+				next = i
+			}
+		}
+		d, l := levels(next)
+		if d <= depth {
+			break
+		}
+		root, depth, last = next, d, l
+	}
+	for _, i := range queue {
+		level[i] = 0
+	}
+	return root
+}
@@ -0,0 +1,194 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// shuffledGrid returns the 5-point Laplacian on the nx×ny grid with the
+// vertices numbered randomly.
+func shuffledGrid(nx, ny int, rnd *rand.Rand) *CSR {
+	n := nx * ny
+	p := rnd.Perm(n)
+	t := NewTriplet(n, n)
+	for x := 0; x < nx; x++ {
+		for y := 0; y < ny; y++ {
+			i := p[x*ny+y]
+			t.Append(i, i, 4)
+			if x > 0 {
+				t.Append(i, p[(x-1)*ny+y], -1)
+			}
+			if x < nx-1 {
+				t.Append(i, p[(x+1)*ny+y], -1)
+			}
+			if y > 0 {
+				t.Append(i, p[x*ny+y-1], -1)
+			}
+			if y < ny-1 {
+				t.Append(i, p[x*ny+y+1], -1)
+			}
+		}
+	}
+	return NewCSRFromTriplet(t)
+}
+
+func TestRCM(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name string
+		a    *CSR
+		band int
+	}{
+		{"empty", NewCSRFromTriplet(NewTriplet(0, 0)), 0},
+		{"path", shuffledGrid(1, 50, rnd), 1},
+		{"grid 10×10", shuffledGrid(10, 10, rnd), 10},
+		{"grid 30×8", shuffledGrid(30, 8, rnd), 8},
+		{"random", func() *CSR { a, _ := randomCSR(40, 40, 100, rnd); return a }(), -1},
+	} {
+		perm, err := RCM(test.a)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", test.name, err)
+		}
+		n, _ := test.a.Dims()
+		if err := checkIndexSet(perm, n, "row"); err != nil || len(perm) != n {
+			t.Errorf("%v: invalid permutation %v", test.name, perm)
+			continue
+		}
+		b := Permute(test.a, perm, perm)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if b.At(i, j) != test.a.At(perm[i], perm[j]) {
+					t.Fatalf("%v: reordered matrix differs at (%d,%d)", test.name, i, j)
+				}
+			}
+		}
+		if test.band < 0 {
+			continue
+		}
+		r := Analyze(b)
+		if r.LowerBandwidth > test.band || r.UpperBandwidth > test.band {
+			t.Errorf("%v: bandwidth %d,%d after reordering, want at most %d", test.name, r.LowerBandwidth, r.UpperBandwidth, test.band)
+		}
+	}
+
+	// The components of a block diagonal matrix
+	// are ordered one after another.
+	tr := NewTriplet(6, 6)
+	for _, e := range [][2]int{{0, 3}, {3, 5}, {1, 2}, {2, 4}} {
+		tr.Append(e[0], e[1], 1)
+		tr.Append(e[1], e[0], 1)
+	}
+	perm, _ := RCM(NewCSRFromTriplet(tr))
+	comp := []int{0, 1, 1, 0, 1, 0}
+	for k := 1; k < 3; k++ {
+		if comp[perm[k]] != comp[perm[0]] || comp[perm[k+3]] != comp[perm[3]] {
+			t.Errorf("components interleaved in %v", perm)
+			break
+		}
+	}
+
+	if _, err := RCM(NewCSRFromTriplet(NewTriplet(2, 3))); err == nil {
+		t.Errorf("no error with a rectangular matrix")
+	}
+}
+
+func TestPermute(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const r, c = 7, 5
+	a, dense := randomCSR(r, c, 20, rnd)
+	p := rnd.Perm(r)
+	q := rnd.Perm(c)
+	b := Permute(a, p, q)
+	for k := 0; k < r; k++ {
+		for l := 0; l < c; l++ {
+			if b.At(k, l) != dense[p[k]*c+q[l]] {
+				t.Fatalf("permuted matrix differs at (%d,%d)", k, l)
+			}
+		}
+	}
+
+	// P A Q^T (Q x) = P (A x).
+	x := randomVec(c, rnd)
+	ax := make([]float64, r)
+	a.MulVec(ax, x)
+	qx := make([]float64, c)
+	PermuteVec(qx, x, q)
+	bqx := make([]float64, r)
+	b.MulVec(bqx, qx)
+	got := make([]float64, r)
+	UnpermuteVec(got, bqx, p)
+	if !equalApprox(got, ax, 1e-14) {
+		t.Errorf("product with the permuted matrix differs")
+	}
+	back := make([]float64, c)
+	UnpermuteVec(back, qx, q)
+	if !equalApprox(back, x, 0) {
+		t.Errorf("UnpermuteVec does not undo PermuteVec")
+	}
+
+	for _, f := range []func(){
+		func() { Permute(a, p[:r-1], q) },
+		func() { Permute(a, p, append([]int{q[1]}, q[1:]...)) },
+		func() { PermuteVec(qx, x[:c-1], q) },
+		func() { UnpermuteVec(back[:c-1], qx, q) },
+	} {
+		if !panics(f) {
+			t.Errorf("invalid permutation did not panic")
+		}
+	}
+}
+
+func TestColorRows(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name   string
+		a      *CSR
+		colors int
+	}{
+		{"empty", NewCSRFromTriplet(NewTriplet(0, 0)), 0},
+		{"diagonal", randomTriangularCSR(20, 0, rnd), 1},
+		{"tridiagonal", tridiagonal(30), 2},
+		{"grid 20×10", grid(20, 10), 2},
+		{"shuffled grid 10×10", shuffledGrid(10, 10, rnd), -1},
+		{"random", func() *CSR { a, _ := randomCSR(100, 100, 500, rnd); return a }(), -1},
+		{"unsymmetric", randomTriangularCSR(100, 300, rnd), -1},
+	} {
+		colors, numColors := ColorRows(test.a)
+		n, _ := test.a.Dims()
+		if len(colors) != n {
+			t.Errorf("%v: unexpected length of colors %d", test.name, len(colors))
+			continue
+		}
+		if test.colors >= 0 && numColors != test.colors {
+			t.Errorf("%v: unexpected number of colors %d, want %d", test.name, numColors, test.colors)
+		}
+		used := make([]bool, numColors)
+		for _, c := range colors {
+			if c < 0 || c >= numColors {
+				t.Fatalf("%v: color %d out of range", test.name, c)
+			}
+			used[c] = true
+		}
+		for c, ok := range used {
+			if !ok {
+				t.Errorf("%v: color %d not used", test.name, c)
+			}
+		}
+		// The rows of a color are independent.
+		for i := 0; i < n; i++ {
+			ind, _ := test.a.RowView(i)
+			for _, j := range ind {
+				if j != i && colors[i] == colors[j] {
+					t.Errorf("%v: coupled rows %d and %d have the same color %d", test.name, i, j, colors[i])
+				}
+			}
+		}
+	}
+	if !panics(func() { ColorRows(NewCSRFromTriplet(NewTriplet(3, 2))) }) {
+		t.Errorf("rectangular matrix did not panic")
+	}
+}
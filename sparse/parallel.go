@@ -0,0 +1,96 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultParallelThreshold is the default number of stored elements below
+// which the matrix-vector products are computed serially even if the
+// matrix has been set to use multiple goroutines.
+const DefaultParallelThreshold = 50000
+
+// parallel holds the settings for computing matrix-vector products in
+// parallel. The zero value means serial computation.
+type parallel struct {
+	threads   int
+	threshold int
+}
+
+func (p *parallel) setThreads(n int) {
+	if n < 0 {
+		panic("sparse: negative number of threads")
+	}
+	if n == 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	p.threads = n
+}
+
+func (p *parallel) setThreshold(nnz int) {
+	if nnz < 0 {
+		panic("sparse: negative parallel threshold")
+	}
+	if nnz == 0 {
+		nnz = DefaultParallelThreshold
+	}
+	p.threshold = nnz
+}
+
+// workers returns the number of goroutines to use for a product with a
+// matrix with nnz stored elements.
+func (p *parallel) workers(nnz int) int {
+	threshold := p.threshold
+	if threshold == 0 {
+		threshold = DefaultParallelThreshold
+	}
+	if p.threads <= 1 || nnz < threshold {
+		return 1
+	}
+	return p.threads
+}
+
+// run calls fn(w) for w = 0, ..., n-1 in n goroutines and waits for all of
+// them to finish.
+func run(n int, fn func(w int)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for w := 0; w < n; w++ {
+		go func(w int) {
+			fn(w)
+			wg.Done()
+		}(w)
+	}
+	wg.Wait()
+}
+
+// transMulVecParallel computes a transposed product in n goroutines.
+// mul(w, y) must add the contributions of the w-th part of the matrix to y.
+// The contributions of the first part are accumulated directly in dst, the
+// remaining parts use private buffers that are summed into dst at the end.
+func transMulVecParallel(dst []float64, n int, mul func(w int, y []float64)) {
+	for j := range dst {
+		dst[j] = 0
+	}
+	bufs := make([][]float64, n)
+	bufs[0] = dst
+	for w := 1; w < n; w++ {
+		bufs[w] = make([]float64, len(dst))
+	}
+	run(n, func(w int) {
+		mul(w, bufs[w])
+	})
+	// Reduce the buffers in parallel over blocks of dst.
+	run(n, func(w int) {
+		start, end := w*len(dst)/n, (w+1)*len(dst)/n
+		for _, buf := range bufs[1:] {
+			for j := start; j < end; j++ {
+				dst[j] += buf[j]
+			}
+		}
+	})
+}
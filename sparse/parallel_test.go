@@ -0,0 +1,150 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParallelMulVec(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{1, 1, 0},
+		{1, 1, 5},
+		{3, 5, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+		{7, 20, 100},
+		{100, 100, 1000},
+		{100, 3, 1000},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := denseFromEntries(r, c, rows, cols, vals)
+		for _, threads := range []int{0, 1, 2, 3, 8, 200} {
+			csr := NewCSRFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals))
+			csr.SetThreads(threads)
+			csr.SetParallelThreshold(1)
+			if s := testMulVec(csr, want, rnd); s != "" {
+				t.Errorf("r=%v,c=%v,threads=%v: unexpected result of CSR %v", r, c, threads, s)
+			}
+
+			tr := NewTripletFromSlices(r, c, rows, cols, vals)
+			tr.SetThreads(threads)
+			tr.SetParallelThreshold(1)
+			if s := testMulVec(tr, want, rnd); s != "" {
+				t.Errorf("r=%v,c=%v,threads=%v: unexpected result of unsorted Triplet %v", r, c, threads, s)
+			}
+			tr.SortByRow()
+			if s := testMulVec(tr, want, rnd); s != "" {
+				t.Errorf("r=%v,c=%v,threads=%v: unexpected result of sorted Triplet %v", r, c, threads, s)
+			}
+			tr.Compact()
+			if s := testMulVec(tr, want, rnd); s != "" {
+				t.Errorf("r=%v,c=%v,threads=%v: unexpected result of compacted Triplet %v", r, c, threads, s)
+			}
+		}
+	}
+}
+
+func TestParallelSettings(t *testing.T) {
+	var p parallel
+	if p.workers(1e9) != 1 {
+		t.Errorf("zero value not serial")
+	}
+	p.setThreads(4)
+	if p.workers(DefaultParallelThreshold-1) != 1 {
+		t.Errorf("parallel below default threshold")
+	}
+	if p.workers(DefaultParallelThreshold) != 4 {
+		t.Errorf("serial above default threshold")
+	}
+	p.setThreshold(10)
+	if p.workers(9) != 1 || p.workers(10) != 4 {
+		t.Errorf("threshold not respected")
+	}
+	p.setThreads(0)
+	if p.workers(10) != runtime.GOMAXPROCS(0) {
+		t.Errorf("unexpected number of workers for 0 threads")
+	}
+	if !panics(func() { p.setThreads(-1) }) {
+		t.Errorf("negative threads did not panic")
+	}
+	if !panics(func() { p.setThreshold(-1) }) {
+		t.Errorf("negative threshold did not panic")
+	}
+}
+
+func BenchmarkCSRMulVecParallel(b *testing.B) {
+	m := NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1))))
+	m.SetThreads(0)
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
+
+func BenchmarkCSRMulTransVecParallel(b *testing.B) {
+	m := NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1))))
+	m.SetThreads(0)
+	benchmarkMulVec(b, m.MulTransVec, benchN)
+}
+
+func BenchmarkTripletMulVecParallel(b *testing.B) {
+	m := benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1)))
+	m.Compact()
+	m.SetThreads(0)
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
+
+func TestTune(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	small := NewCSRFromTriplet(benchTriplet(10, 30, rnd))
+	if n := small.Tune(20 * time.Millisecond); n != 1 {
+		t.Errorf("unexpected number of goroutines for a tiny matrix: want 1, got %d", n)
+	}
+	if small.workers(len(small.data)) != 1 {
+		t.Errorf("tuned number of goroutines not set")
+	}
+	if !panics(func() { small.Tune(0) }) {
+		t.Errorf("zero budget did not panic")
+	}
+
+	if testing.Short() {
+		t.Skip("skipping large matrix in short mode")
+	}
+	if runtime.GOMAXPROCS(0) == 1 {
+		t.Skip("skipping parallel tuning on a single processor")
+	}
+	large := NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rnd))
+	n := large.Tune(500 * time.Millisecond)
+	if n == 1 {
+		t.Errorf("unexpected serial MulVec for a large matrix")
+	}
+	if large.workers(len(large.data)) != n {
+		t.Errorf("tuned number of goroutines not set")
+	}
+}
+
+func TestBenchmarkMulVec(t *testing.T) {
+	m := NewCSRFromTriplet(benchTriplet(100, 1000, rand.New(rand.NewSource(1))))
+	m.SetThreads(3)
+	saved := m.parallel
+	res := testing.Benchmark(func(b *testing.B) {
+		BenchmarkMulVec(b, m)
+	})
+	if res.N == 0 {
+		t.Errorf("benchmark did not run")
+	}
+	if m.parallel != saved {
+		t.Errorf("settings not restored")
+	}
+}
+
+func BenchmarkCSRMulVecThreads(b *testing.B) {
+	BenchmarkMulVec(b, NewCSRFromTriplet(benchTriplet(benchN, benchNNZ, rand.New(rand.NewSource(1)))))
+}
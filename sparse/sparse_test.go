@@ -0,0 +1,168 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math"
+	"math/rand"
+)
+
+// panics returns whether f panics.
+func panics(f func()) (b bool) {
+	defer func() {
+		if recover() != nil {
+			b = true
+		}
+	}()
+	f()
+	return false
+}
+
+// randomEntries returns the row indices, column indices and values of nnz
+// random elements of an r×c matrix. Indices may repeat.
+func randomEntries(r, c, nnz int, rnd *rand.Rand) (rows, cols []int, vals []float64) {
+	rows = make([]int, nnz)
+	cols = make([]int, nnz)
+	vals = make([]float64, nnz)
+	for k := range vals {
+		rows[k] = rnd.Intn(r)
+		cols[k] = rnd.Intn(c)
+		vals[k] = rnd.NormFloat64()
+	}
+	return rows, cols, vals
+}
+
+// denseFromEntries returns the r×c matrix in row-major order with the given
+// elements summed.
+func denseFromEntries(r, c int, rows, cols []int, vals []float64) []float64 {
+	a := make([]float64, r*c)
+	for k, v := range vals {
+		a[rows[k]*c+cols[k]] += v
+	}
+	return a
+}
+
+// denseMulVec computes A*x or A^T*x for an r×c row-major matrix a.
+func denseMulVec(a []float64, r, c int, trans bool, x []float64) []float64 {
+	var dst []float64
+	if trans {
+		dst = make([]float64, c)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				dst[j] += a[i*c+j] * x[i]
+			}
+		}
+	} else {
+		dst = make([]float64, r)
+		for i := 0; i < r; i++ {
+			for j := 0; j < c; j++ {
+				dst[i] += a[i*c+j] * x[j]
+			}
+		}
+	}
+	return dst
+}
+
+func randomVec(n int, rnd *rand.Rand) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = rnd.NormFloat64()
+	}
+	return x
+}
+
+func equalApprox(a, b []float64, tol float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if math.Abs(v-b[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// matVecer is a matrix that can multiply vectors.
+type matVecer interface {
+	Dims() (r, c int)
+	MulVec(dst, x []float64)
+	MulTransVec(dst, x []float64)
+}
+
+// testMulVecPanics returns a description of the first dimension check
+// that does not panic in m, or an empty string if all of them do.
+func testMulVecPanics(m matVecer) string {
+	r, c := m.Dims()
+	switch {
+	case !panics(func() { m.MulVec(make([]float64, r), make([]float64, c+1)) }):
+		return "MulVec with long x"
+	case !panics(func() { m.MulVec(make([]float64, r+1), make([]float64, c)) }):
+		return "MulVec with long dst"
+	case !panics(func() { m.MulTransVec(make([]float64, c), make([]float64, r+1)) }):
+		return "MulTransVec with long x"
+	case !panics(func() { m.MulTransVec(make([]float64, c+1), make([]float64, r)) }):
+		return "MulTransVec with long dst"
+	}
+	return ""
+}
+
+// testMulVec returns a description of the first product of m with a random
+// vector that does not match the product with the r×c row-major matrix
+// want, or an empty string if all of them match.
+func testMulVec(m matVecer, want []float64, rnd *rand.Rand) string {
+	r, c := m.Dims()
+	for _, trans := range []bool{false, true} {
+		x := randomVec(r, rnd)
+		dst := randomVec(c, rnd)
+		mul := m.MulTransVec
+		if !trans {
+			x = randomVec(c, rnd)
+			dst = randomVec(r, rnd)
+			mul = m.MulVec
+		}
+		mul(dst, x)
+		if !equalApprox(dst, denseMulVec(want, r, c, trans, x), 1e-13) {
+			if trans {
+				return "MulTransVec"
+			}
+			return "MulVec"
+		}
+	}
+	return ""
+}
+
+// randomCSR returns a random r×c CSR matrix with at most nnz elements and
+// its row-major dense representation.
+func randomCSR(r, c, nnz int, rnd *rand.Rand) (*CSR, []float64) {
+	rows, cols, vals := randomEntries(r, c, nnz, rnd)
+	return NewCSRFromTriplet(NewTripletFromSlices(r, c, rows, cols, vals)), denseFromEntries(r, c, rows, cols, vals)
+}
+
+// testCSRDense returns a description of the first property in which m
+// differs from the r×c row-major matrix want, or an empty string if there
+// is none.
+func testCSRDense(m *CSR, r, c int, want []float64) string {
+	if mr, mc := m.Dims(); mr != r || mc != c {
+		return "dimensions"
+	}
+	if len(m.indptr) != r+1 || m.indptr[0] != 0 || m.indptr[r] != len(m.ind) || len(m.ind) != len(m.data) {
+		return "structure"
+	}
+	for i := 0; i < r; i++ {
+		ind, _ := m.RowView(i)
+		for k := 1; k < len(ind); k++ {
+			if ind[k-1] >= ind[k] {
+				return "column order"
+			}
+		}
+		for j := 0; j < c; j++ {
+			if !equalApprox([]float64{m.At(i, j)}, []float64{want[i*c+j]}, 1e-14) {
+				return "elements"
+			}
+		}
+	}
+	return ""
+}
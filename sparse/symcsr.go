@@ -0,0 +1,210 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// SymCSR is a symmetric sparse matrix in the compressed sparse row format
+// storing only the upper triangle and the diagonal. Row i holds the
+// elements a[i,j] with j >= i in increasing order of j, an off-diagonal
+// element represents both a[i,j] and a[j,i]. SymCSR needs about half of the
+// memory of a CSR matrix with the same elements.
+type SymCSR struct {
+	n      int
+	indptr []int
+	ind    []int
+	data   []float64
+}
+
+// NewSymCSRFromHalfTriplet returns a new symmetric matrix from the triplets
+// in t that give one triangle of the matrix. Each off-diagonal triplet
+// (i, j, v) sets both a[i,j] and a[j,i] to v, so the triplets may come
+// from the upper triangle, the lower triangle or a mix of both. Triplets
+// for the same element are summed in the order in which they were appended
+// to t. NewSymCSRFromHalfTriplet panics if t is not square.
+func NewSymCSRFromHalfTriplet(t *Triplet) *SymCSR {
+	if t.r != t.c {
+		panic("sparse: matrix not square")
+	}
+	upper := &Triplet{r: t.r, c: t.c, data: make([]triplet, len(t.data))}
+	for k, aij := range t.data {
+		if aij.i > aij.j {
+			aij.i, aij.j = aij.j, aij.i
+		}
+		upper.data[k] = aij
+	}
+	m := NewCSRFromTriplet(upper)
+	return &SymCSR{n: m.r, indptr: m.indptr, ind: m.ind, data: m.data}
+}
+
+// NewSymCSRFromFullTriplet returns a new symmetric matrix from the triplets
+// in t that give the whole matrix. The upper triangle of the matrix is
+// retained and the symmetry of each pair of off-diagonal elements is
+// validated as
+//  |a[i,j] - a[j,i]| <= tol * max(|a[i,j]|, |a[j,i]|).
+// If the check fails, NewSymCSRFromFullTriplet returns an error.
+// NewSymCSRFromFullTriplet panics if t is not square.
+func NewSymCSRFromFullTriplet(t *Triplet, tol float64) (*SymCSR, error) {
+	if t.r != t.c {
+		panic("sparse: matrix not square")
+	}
+	return NewSymCSRFromCSR(NewCSRFromTriplet(t), tol)
+}
+
+// NewSymCSRFromCSR returns a new symmetric matrix with the upper triangle
+// of the CSR matrix a. The symmetry of a is validated as in
+// NewSymCSRFromFullTriplet. NewSymCSRFromCSR panics if a is not square.
+func NewSymCSRFromCSR(a *CSR, tol float64) (*SymCSR, error) {
+	if a.r != a.c {
+		panic("sparse: matrix not square")
+	}
+	at := Transpose(a)
+	m := &SymCSR{
+		n:      a.r,
+		indptr: make([]int, a.r+1),
+	}
+	for i := 0; i < a.r; i++ {
+		ind, data := a.RowView(i)
+		tind, tdata := at.RowView(i)
+		// Compare row i of a with row i of a^T, that is, with
+		// column i of a. Missing elements are zero.
+		ka, kt := 0, 0
+		for ka < len(ind) || kt < len(tind) {
+			var j int
+			var aij, aji float64
+			switch {
+			case kt == len(tind) || (ka < len(ind) && ind[ka] < tind[kt]):
+				j, aij = ind[ka], data[ka]
+				ka++
+			case ka == len(ind) || tind[kt] < ind[ka]:
+				j, aji = tind[kt], tdata[kt]
+				kt++
+			default:
+				j, aij, aji = ind[ka], data[ka], tdata[kt]
+				ka++
+				kt++
+			}
+			if math.Abs(aij-aji) > tol*math.Max(math.Abs(aij), math.Abs(aji)) {
+				return nil, fmt.Errorf("sparse: matrix not symmetric: a[%d,%d]=%v, a[%d,%d]=%v", i, j, aij, j, i, aji)
+			}
+		}
+		start := sort.SearchInts(ind, i)
+		m.ind = append(m.ind, ind[start:]...)
+		m.data = append(m.data, data[start:]...)
+		m.indptr[i+1] = len(m.ind)
+	}
+	return m, nil
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *SymCSR) Dims() (r, c int) {
+	return m.n, m.n
+}
+
+// SymmetricDim returns the number of rows and columns of the matrix.
+func (m *SymCSR) SymmetricDim() int {
+	return m.n
+}
+
+// NNZ returns the number of stored elements of the matrix, that is, the
+// number of nonzero elements in the upper triangle including the diagonal.
+func (m *SymCSR) NNZ() int {
+	return len(m.data)
+}
+
+// At returns the element of the matrix at row i and column j.
+func (m *SymCSR) At(i, j int) float64 {
+	if i < 0 || m.n <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.n <= j {
+		panic("sparse: column index out of range")
+	}
+	if i > j {
+		i, j = j, i
+	}
+	ind, data := m.RowView(i)
+	k := sort.SearchInts(ind, j)
+	if k < len(ind) && ind[k] == j {
+		return data[k]
+	}
+	return 0
+}
+
+// RowView returns the column indices and values of the stored elements in
+// row i of the upper triangle of the matrix. The column indices are in
+// increasing order and not smaller than i. The returned slices share the
+// storage of m and must not be modified.
+func (m *SymCSR) RowView(i int) (ind []int, data []float64) {
+	if i < 0 || m.n <= i {
+		panic("sparse: row index out of range")
+	}
+	start, end := m.indptr[i], m.indptr[i+1]
+	return m.ind[start:end:end], m.data[start:end:end]
+}
+
+// MulVec computes A*x and stores the result into dst. The contributions of
+// the stored and the mirrored elements are accumulated in one pass over
+// the data.
+func (m *SymCSR) MulVec(dst, x []float64) {
+	if m.n != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.n != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	for i, xi := range x {
+		sum := dst[i]
+		for k := m.indptr[i]; k < m.indptr[i+1]; k++ {
+			j := m.ind[k]
+			v := m.data[k]
+			sum += v * x[j]
+			if j != i {
+				dst[j] += v * xi
+			}
+		}
+		dst[i] = sum
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst. Since A is
+// symmetric, MulTransVec is equivalent to MulVec.
+func (m *SymCSR) MulTransVec(dst, x []float64) {
+	m.MulVec(dst, x)
+}
+
+// ToCSR returns the matrix m with both triangles stored in the CSR format.
+func (m *SymCSR) ToCSR() *CSR {
+	lower := Transpose(&CSR{r: m.n, c: m.n, indptr: m.indptr, ind: m.ind, data: m.data})
+	// Merge the strictly lower triangle from the transpose with the upper
+	// triangle. The rows of lower only contain columns j <= i.
+	a := &CSR{
+		r:      m.n,
+		c:      m.n,
+		indptr: make([]int, m.n+1),
+		ind:    make([]int, 0, 2*len(m.ind)),
+		data:   make([]float64, 0, 2*len(m.data)),
+	}
+	for i := 0; i < m.n; i++ {
+		for k := lower.indptr[i]; k < lower.indptr[i+1]; k++ {
+			if lower.ind[k] == i {
+				break
+			}
+			a.ind = append(a.ind, lower.ind[k])
+			a.data = append(a.data, lower.data[k])
+		}
+		a.ind = append(a.ind, m.ind[m.indptr[i]:m.indptr[i+1]]...)
+		a.data = append(a.data, m.data[m.indptr[i]:m.indptr[i+1]]...)
+		a.indptr[i+1] = len(a.ind)
+	}
+	return a
+}
@@ -0,0 +1,162 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// randomSymEntries returns the elements of a random n×n symmetric matrix
+// with nnz random elements in the upper triangle and a full diagonal.
+func randomSymEntries(n, nnz int, rnd *rand.Rand) (rows, cols []int, vals []float64) {
+	for i := 0; i < n; i++ {
+		rows = append(rows, i)
+		cols = append(cols, i)
+		vals = append(vals, rnd.NormFloat64())
+	}
+	for k := 0; k < nnz; k++ {
+		i, j := rnd.Intn(n), rnd.Intn(n)
+		if i > j {
+			i, j = j, i
+		}
+		rows = append(rows, i)
+		cols = append(cols, j)
+		vals = append(vals, rnd.NormFloat64())
+	}
+	return rows, cols, vals
+}
+
+func TestSymCSR(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		n, nnz int
+	}{
+		{0, 0},
+		{1, 0},
+		{1, 2},
+		{2, 1},
+		{5, 4},
+		{10, 30},
+		{50, 200},
+	} {
+		n := test.n
+		rows, cols, vals := randomSymEntries(n, test.nnz, rnd)
+
+		// Full storage with both triangles, the lower triangle is
+		// appended in a shuffled order.
+		full := NewTripletFromSlices(n, n, rows, cols, vals)
+		for _, k := range rnd.Perm(len(vals)) {
+			if rows[k] != cols[k] {
+				full.Append(cols[k], rows[k], vals[k])
+			}
+		}
+		want := NewCSRFromTriplet(full)
+		dense := make([]float64, n*n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				dense[i*n+j] = want.At(i, j)
+			}
+		}
+
+		// Half storage with each element in a random triangle.
+		half := NewTriplet(n, n)
+		for k, v := range vals {
+			if rnd.Intn(2) == 0 {
+				half.Append(rows[k], cols[k], v)
+			} else {
+				half.Append(cols[k], rows[k], v)
+			}
+		}
+
+		fromFull, err := NewSymCSRFromFullTriplet(full, 1e-14)
+		if err != nil {
+			t.Errorf("n=%v: unexpected error: %v", n, err)
+			continue
+		}
+		for _, src := range []struct {
+			name string
+			m    *SymCSR
+		}{
+			{"half", NewSymCSRFromHalfTriplet(half)},
+			{"full", fromFull},
+		} {
+			m := src.m
+			if r, c := m.Dims(); r != n || c != n || m.SymmetricDim() != n {
+				t.Errorf("%v,n=%v: unexpected dimensions", src.name, n)
+			}
+			// Only the upper triangle is stored and the diagonal is
+			// full.
+			wantNNZ := (want.NNZ() + n) / 2
+			if m.NNZ() != wantNNZ {
+				t.Errorf("%v,n=%v: unexpected NNZ, want %v, got %v", src.name, n, wantNNZ, m.NNZ())
+			}
+			if len(m.ind) > wantNNZ || len(m.data) > wantNNZ {
+				t.Errorf("%v,n=%v: more than one triangle stored", src.name, n)
+			}
+			for i := 0; i < n; i++ {
+				ind, _ := m.RowView(i)
+				for k, j := range ind {
+					if j < i || (k > 0 && ind[k-1] >= j) {
+						t.Errorf("%v,n=%v: invalid column indices in row %v", src.name, n, i)
+						break
+					}
+				}
+				for j := 0; j < n; j++ {
+					if !equalApprox([]float64{m.At(i, j)}, []float64{dense[i*n+j]}, 1e-14) {
+						t.Errorf("%v,n=%v: unexpected element at (%v,%v)", src.name, n, i, j)
+					}
+				}
+			}
+			if s := testMulVec(m, dense, rnd); s != "" {
+				t.Errorf("%v,n=%v: unexpected result of %v", src.name, n, s)
+			}
+			if s := testMulVecPanics(m); s != "" {
+				t.Errorf("%v,n=%v: %v did not panic", src.name, n, s)
+			}
+			if !reflect.DeepEqual(m.ToCSR(), want) {
+				t.Errorf("%v,n=%v: unexpected conversion to CSR", src.name, n)
+			}
+		}
+	}
+}
+
+func TestSymCSRNotSymmetric(t *testing.T) {
+	a := NewTriplet(3, 3)
+	a.Append(0, 0, 1)
+	a.Append(0, 2, 2)
+	a.Append(2, 0, 2+1e-10)
+	if _, err := NewSymCSRFromFullTriplet(a, 1e-8); err != nil {
+		t.Errorf("unexpected error for a nearly symmetric matrix: %v", err)
+	}
+	if _, err := NewSymCSRFromFullTriplet(a, 1e-12); err == nil {
+		t.Errorf("missing error for a nonsymmetric matrix")
+	}
+	a.Append(1, 2, 1)
+	if _, err := NewSymCSRFromFullTriplet(a, 1e-8); err == nil {
+		t.Errorf("missing error for a structurally nonsymmetric matrix")
+	}
+}
+
+func TestSymCSRPanics(t *testing.T) {
+	m := NewSymCSRFromHalfTriplet(NewTriplet(3, 3))
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"NewSymCSRFromHalfTriplet with non-square", func() { NewSymCSRFromHalfTriplet(NewTriplet(2, 3)) }},
+		{"NewSymCSRFromFullTriplet with non-square", func() { NewSymCSRFromFullTriplet(NewTriplet(2, 3), 0) }},
+		{"At with negative row", func() { m.At(-1, 0) }},
+		{"At with large row", func() { m.At(3, 0) }},
+		{"At with negative column", func() { m.At(0, -1) }},
+		{"At with large column", func() { m.At(0, 3) }},
+		{"RowView with large row", func() { m.RowView(3) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
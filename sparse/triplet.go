@@ -0,0 +1,253 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import "sort"
+
+type triplet struct {
+	i, j int
+	v    float64
+}
+
+// Triplet is a sparse matrix in the coordinate (triplet) format. The
+// elements are stored as an unordered list of (row, column, value)
+// triplets and elements that appear more than once are summed. Triplet is
+// suitable for fast assembly of a matrix, for example, from a finite
+// element discretization.
+type Triplet struct {
+	r, c int
+	data []triplet
+
+	// sorted is whether data is sorted by
+	// rows and columns.
+	sorted bool
+
+	parallel
+}
+
+// NewTriplet returns a new r×c Triplet matrix with no stored elements.
+func NewTriplet(r, c int) *Triplet {
+	if r < 0 || c < 0 {
+		panic("sparse: negative dimension")
+	}
+	return &Triplet{
+		r: r,
+		c: c,
+	}
+}
+
+// NewTripletFromSlices returns a new r×c Triplet matrix whose elements are
+// given by the k-th elements of rows, cols and vals as the triplets
+//  (rows[k], cols[k], vals[k]).
+// The slices must have the same length and they are not retained.
+func NewTripletFromSlices(r, c int, rows, cols []int, vals []float64) *Triplet {
+	if len(rows) != len(vals) || len(cols) != len(vals) {
+		panic("sparse: slice length mismatch")
+	}
+	m := NewTriplet(r, c)
+	m.data = make([]triplet, 0, len(vals))
+	for k, v := range vals {
+		m.Append(rows[k], cols[k], v)
+	}
+	return m
+}
+
+// SortByRow stably sorts the stored triplets by their row and column
+// indices. Triplets with the same row and column keep their relative
+// order and are not summed.
+func (m *Triplet) SortByRow() {
+	if !m.sorted {
+		sort.Stable(byRowCol(m.data))
+		m.sorted = true
+	}
+}
+
+// Compact sorts the stored triplets by their row and column indices and
+// sums the triplets with the same row and column in the order in which
+// they were appended. The backing storage is shrunk to the number of
+// distinct elements. Compact makes the matrix-vector products faster due
+// to sequential access to dst in MulVec and to x in MulTransVec.
+func (m *Triplet) Compact() {
+	m.SortByRow()
+	var n int
+	for k, aij := range m.data {
+		if k > 0 && aij.i == m.data[n-1].i && aij.j == m.data[n-1].j {
+			m.data[n-1].v += aij.v
+			continue
+		}
+		m.data[n] = aij
+		n++
+	}
+	data := make([]triplet, n)
+	copy(data, m.data)
+	m.data = data
+}
+
+// byRowCol sorts triplets by their row and then column index.
+type byRowCol []triplet
+
+func (t byRowCol) Len() int      { return len(t) }
+func (t byRowCol) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t byRowCol) Less(i, j int) bool {
+	if t[i].i != t[j].i {
+		return t[i].i < t[j].i
+	}
+	return t[i].j < t[j].j
+}
+
+// Dims returns the number of rows and columns of the matrix.
+func (m *Triplet) Dims() (r, c int) {
+	return m.r, m.c
+}
+
+// NNZ returns the number of stored triplets. Elements that appear more
+// than once are counted for each appearance unless the matrix has been
+// compacted by Compact since they were appended.
+func (m *Triplet) NNZ() int {
+	return len(m.data)
+}
+
+// Append adds the triplet (i, j, v) to the matrix, that is, it adds v to the
+// element at row i and column j.
+func (m *Triplet) Append(i, j int, v float64) {
+	if i < 0 || m.r <= i {
+		panic("sparse: row index out of range")
+	}
+	if j < 0 || m.c <= j {
+		panic("sparse: column index out of range")
+	}
+	m.data = append(m.data, triplet{i, j, v})
+	m.sorted = false
+}
+
+// SetThreads sets the number of goroutines used by MulVec and MulTransVec
+// when the matrix has at least as many stored triplets as the threshold set
+// by SetParallelThreshold. If n is 0, runtime.GOMAXPROCS(0) at the time of
+// the call is used. If n is 1, the products are computed serially, which is
+// the default. MulVec is computed in parallel only if the triplets have
+// been sorted by SortByRow or Compact after the last call to Append.
+func (m *Triplet) SetThreads(n int) {
+	m.setThreads(n)
+}
+
+// SetParallelThreshold sets the number of stored triplets below which the
+// products are computed serially regardless of SetThreads. If nnz is 0,
+// DefaultParallelThreshold is used.
+func (m *Triplet) SetParallelThreshold(nnz int) {
+	m.setThreshold(nnz)
+}
+
+// MulVec computes A*x and stores the result into dst.
+func (m *Triplet) MulVec(dst, x []float64) {
+	if m.c != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	n := m.workers(len(m.data))
+	if n == 1 || !m.sorted {
+		for i := range dst {
+			dst[i] = 0
+		}
+		mulVecTriplets(dst, x, m.data)
+		return
+	}
+	// Split the sorted triplets at row boundaries so that each goroutine
+	// owns a distinct range of dst.
+	bounds := m.partition(n)
+	rowStart := func(w int) int {
+		if bounds[w] == len(m.data) {
+			return m.r
+		}
+		return m.data[bounds[w]].i
+	}
+	run(n, func(w int) {
+		d := dst[rowStart(w):rowStart(w+1)]
+		for i := range d {
+			d[i] = 0
+		}
+		mulVecTriplets(dst, x, m.data[bounds[w]:bounds[w+1]])
+	})
+	for i := range dst[:rowStart(0)] {
+		dst[i] = 0
+	}
+}
+
+// partition splits the sorted triplets into n ranges
+// [bounds[w], bounds[w+1]) of approximately the same length that do not
+// split rows.
+func (m *Triplet) partition(n int) []int {
+	bounds := make([]int, n+1)
+	for w := 1; w < n; w++ {
+		k := w * len(m.data) / n
+		if k < bounds[w-1] {
+			k = bounds[w-1]
+		}
+		for k > bounds[w-1] && k < len(m.data) && m.data[k].i == m.data[k-1].i {
+			k++
+		}
+		bounds[w] = k
+	}
+	bounds[n] = len(m.data)
+	return bounds
+}
+
+func mulVecTriplets(dst, x []float64, data []triplet) {
+	for _, aij := range data {
+		dst[aij.i] += aij.v * x[aij.j]
+	}
+}
+
+// MulTransVec computes A^T*x and stores the result into dst.
+func (m *Triplet) MulTransVec(dst, x []float64) {
+	if m.c != len(dst) {
+		panic("sparse: dimension mismatch")
+	}
+	if m.r != len(x) {
+		panic("sparse: dimension mismatch")
+	}
+	n := m.workers(len(m.data))
+	if n == 1 {
+		for i := range dst {
+			dst[i] = 0
+		}
+		mulTransVecTriplets(dst, x, m.data)
+		return
+	}
+	transMulVecParallel(dst, n, func(w int, y []float64) {
+		start, end := w*len(m.data)/n, (w+1)*len(m.data)/n
+		mulTransVecTriplets(y, x, m.data[start:end])
+	})
+}
+
+func mulTransVecTriplets(dst, x []float64, data []triplet) {
+	for _, aij := range data {
+		dst[aij.j] += aij.v * x[aij.i]
+	}
+}
+
+// ToBand returns the elements of the matrix in the row-major band storage
+// of gonum's blas64.Band with kl sub-diagonals and ku super-diagonals. The
+// element at row i and column j is stored at
+//  band[i*(kl+ku+1)+j-i+kl]
+// with duplicates summed. ToBand panics if the matrix has an element
+// outside the band. For the band storage of a symmetric matrix, only the
+// upper triangle should be stored in m and ToBand called with kl = 0.
+func (m *Triplet) ToBand(kl, ku int) []float64 {
+	if kl < 0 || ku < 0 {
+		panic("sparse: negative bandwidth")
+	}
+	lda := kl + ku + 1
+	band := make([]float64, m.r*lda)
+	for _, aij := range m.data {
+		d := aij.j - aij.i
+		if d < -kl || ku < d {
+			panic("sparse: element outside band")
+		}
+		band[aij.i*lda+d+kl] += aij.v
+	}
+	return band
+}
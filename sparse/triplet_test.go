@@ -0,0 +1,214 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTriplet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{1, 1, 0},
+		{1, 1, 1},
+		{1, 1, 3},
+		{3, 5, 4},
+		{5, 3, 4},
+		{10, 10, 30},
+		{20, 7, 100},
+		{7, 20, 100},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+		want := denseFromEntries(r, c, rows, cols, vals)
+
+		for _, m := range []*Triplet{
+			NewTripletFromSlices(r, c, rows, cols, vals),
+			func() *Triplet {
+				m := NewTriplet(r, c)
+				for k, v := range vals {
+					m.Append(rows[k], cols[k], v)
+				}
+				return m
+			}(),
+		} {
+			if gr, gc := m.Dims(); gr != r || gc != c {
+				t.Errorf("r=%v,c=%v: unexpected dimensions %v×%v", r, c, gr, gc)
+			}
+			if m.NNZ() != test.nnz {
+				t.Errorf("r=%v,c=%v: unexpected NNZ, want %v, got %v", r, c, test.nnz, m.NNZ())
+			}
+
+			for _, trans := range []bool{false, true} {
+				x := randomVec(r, rnd)
+				dst := make([]float64, c)
+				mul := m.MulTransVec
+				if !trans {
+					x = randomVec(c, rnd)
+					dst = make([]float64, r)
+					mul = m.MulVec
+				}
+				for i := range dst {
+					dst[i] = rnd.NormFloat64()
+				}
+				mul(dst, x)
+				if !equalApprox(dst, denseMulVec(want, r, c, trans, x), 1e-13) {
+					t.Errorf("r=%v,c=%v,trans=%v: unexpected result of product", r, c, trans)
+				}
+			}
+
+			if s := testMulVecPanics(m); s != "" {
+				t.Errorf("r=%v,c=%v: %v did not panic", r, c, s)
+			}
+		}
+	}
+}
+
+func TestTripletPanics(t *testing.T) {
+	m := NewTriplet(3, 4)
+	for _, test := range []struct {
+		name string
+		f    func()
+	}{
+		{"NewTriplet with negative rows", func() { NewTriplet(-1, 2) }},
+		{"NewTriplet with negative columns", func() { NewTriplet(2, -1) }},
+		{"NewTripletFromSlices with short rows", func() { NewTripletFromSlices(2, 2, []int{0}, []int{0, 1}, []float64{1, 2}) }},
+		{"NewTripletFromSlices with short cols", func() { NewTripletFromSlices(2, 2, []int{0, 1}, []int{0}, []float64{1, 2}) }},
+		{"NewTripletFromSlices with bad index", func() { NewTripletFromSlices(2, 2, []int{0, 1}, []int{0, 2}, []float64{1, 2}) }},
+		{"Append with negative row", func() { m.Append(-1, 0, 1) }},
+		{"Append with large row", func() { m.Append(3, 0, 1) }},
+		{"Append with negative column", func() { m.Append(0, -1, 1) }},
+		{"Append with large column", func() { m.Append(0, 4, 1) }},
+	} {
+		if !panics(test.f) {
+			t.Errorf("%v did not panic", test.name)
+		}
+	}
+}
+
+func TestTripletToBand(t *testing.T) {
+	m := NewTriplet(4, 4)
+	m.Append(0, 0, 1)
+	m.Append(0, 1, 2)
+	m.Append(1, 0, 3)
+	m.Append(2, 3, 4)
+	m.Append(2, 3, 1)
+	m.Append(3, 1, 6)
+	got := m.ToBand(2, 1)
+	want := []float64{
+		0, 0, 1, 2,
+		0, 3, 0, 0,
+		0, 0, 0, 5,
+		6, 0, 0, 0,
+	}
+	if !equalApprox(got, want, 0) {
+		t.Errorf("unexpected band storage, want %v, got %v", want, got)
+	}
+	if !panics(func() { m.ToBand(1, 1) }) {
+		t.Errorf("ToBand with element outside band did not panic")
+	}
+	if !panics(func() { m.ToBand(-1, 1) }) {
+		t.Errorf("ToBand with negative bandwidth did not panic")
+	}
+}
+
+func TestTripletCompact(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		r, c, nnz int
+	}{
+		{0, 0, 0},
+		{1, 1, 0},
+		{1, 1, 5},
+		{3, 5, 40},
+		{10, 10, 300},
+		{20, 7, 100},
+	} {
+		r, c := test.r, test.c
+		rows, cols, vals := randomEntries(r, c, test.nnz, rnd)
+
+		// Assembly by accumulation of many contributions ...
+		m := NewTripletFromSlices(r, c, rows, cols, vals)
+		sorted := NewTripletFromSlices(r, c, rows, cols, vals)
+		sorted.SortByRow()
+		if sorted.NNZ() != test.nnz {
+			t.Errorf("r=%v,c=%v: SortByRow changed NNZ", r, c)
+		}
+		for k := 1; k < len(sorted.data); k++ {
+			if byRowCol(sorted.data).Less(k, k-1) {
+				t.Errorf("r=%v,c=%v: triplets not sorted", r, c)
+				break
+			}
+		}
+		m.Compact()
+
+		// ... must give the same matrix as direct construction.
+		direct := NewTriplet(r, c)
+		dense := denseFromEntries(r, c, rows, cols, vals)
+		seen := make(map[index]bool)
+		for k := range vals {
+			i, j := rows[k], cols[k]
+			if !seen[index{i, j}] {
+				seen[index{i, j}] = true
+				direct.Append(i, j, dense[i*c+j])
+			}
+		}
+		if m.NNZ() != direct.NNZ() {
+			t.Errorf("r=%v,c=%v: unexpected NNZ after Compact, want %v, got %v", r, c, direct.NNZ(), m.NNZ())
+		}
+		if cap(m.data) != m.NNZ() {
+			t.Errorf("r=%v,c=%v: storage not shrunk", r, c)
+		}
+		for k := 1; k < len(m.data); k++ {
+			if !byRowCol(m.data).Less(k-1, k) {
+				t.Errorf("r=%v,c=%v: compacted triplets not strictly sorted", r, c)
+				break
+			}
+		}
+		for _, tr := range []*Triplet{m, sorted} {
+			if s := testMulVec(tr, dense, rnd); s != "" {
+				t.Errorf("r=%v,c=%v: unexpected result of %v", r, c, s)
+			}
+		}
+		if !equalApprox(denseFromTriplet(m), denseFromTriplet(direct), 1e-14) {
+			t.Errorf("r=%v,c=%v: compacted matrix differs from direct construction", r, c)
+		}
+	}
+}
+
+// denseFromTriplet returns the row-major dense representation of m.
+func denseFromTriplet(m *Triplet) []float64 {
+	a := make([]float64, m.r*m.c)
+	for _, aij := range m.data {
+		a[aij.i*m.c+aij.j] += aij.v
+	}
+	return a
+}
+
+// benchAssembly returns a Triplet matrix assembled from many duplicate
+// contributions in random order.
+func benchAssembly() *Triplet {
+	rnd := rand.New(rand.NewSource(1))
+	m := benchTriplet(benchN, benchNNZ/4, rnd)
+	for k, n := 0, len(m.data); k < 3*n; k++ {
+		aij := m.data[rnd.Intn(n)]
+		m.Append(aij.i, aij.j, rnd.NormFloat64())
+	}
+	return m
+}
+
+func BenchmarkTripletMulVecAssembled(b *testing.B) {
+	m := benchAssembly()
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
+
+func BenchmarkTripletMulVecCompacted(b *testing.B) {
+	m := benchAssembly()
+	m.Compact()
+	benchmarkMulVec(b, m.MulVec, benchN)
+}
@@ -0,0 +1,339 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import "fmt"
+
+// ZeroDiagonalError is returned by the triangular solves when a diagonal
+// element of the triangular matrix is zero or not stored.
+type ZeroDiagonalError struct {
+	// Row is the row of the zero
+	// diagonal element.
+	Row int
+}
+
+func (e *ZeroDiagonalError) Error() string {
+	return fmt.Sprintf("sparse: zero diagonal element in row %d", e.Row)
+}
+
+// SolveLower solves the system
+//  L x = b
+// by forward substitution and stores x into dst, where L is the lower
+// triangle of the square matrix l. The elements of l above the diagonal are
+// not referenced, so l can hold both triangular factors of an incomplete LU
+// factorization. If unitDiag is true, the diagonal of L is assumed to be
+// ones and it is not referenced either.
+//
+// dst and b may be the same slice. SolveLower does not allocate. It returns
+// a *ZeroDiagonalError if a referenced diagonal element is zero or not
+// stored, the contents of dst are then unspecified.
+func SolveLower(dst, b []float64, l *CSR, unitDiag bool) error {
+	checkTriangular(dst, b, l)
+	for i := 0; i < l.r; i++ {
+		if err := l.lowerRow(dst, b, i, unitDiag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SolveUpper solves the system
+//  U x = b
+// by backward substitution and stores x into dst, where U is the upper
+// triangle of the square matrix u. The elements of u below the diagonal are
+// not referenced. If unitDiag is true, the diagonal of U is assumed to be
+// ones and it is not referenced either.
+//
+// dst and b may be the same slice. SolveUpper does not allocate. It returns
+// a *ZeroDiagonalError if a referenced diagonal element is zero or not
+// stored, the contents of dst are then unspecified.
+func SolveUpper(dst, b []float64, u *CSR, unitDiag bool) error {
+	checkTriangular(dst, b, u)
+	for i := u.r - 1; i >= 0; i-- {
+		if err := u.upperRow(dst, b, i, unitDiag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SolveLowerTrans solves the system
+//  L^T x = b
+// and stores x into dst, where L is the lower triangle of the square matrix
+// l referenced as in SolveLower. The rows of l are traversed as the columns
+// of L^T, as if l were stored in the compressed sparse column format, so
+// SolveLowerTrans is a column-oriented backward substitution.
+//
+// dst and b may be the same slice. SolveLowerTrans does not allocate. It
+// returns a *ZeroDiagonalError if a referenced diagonal element is zero or
+// not stored, the contents of dst are then unspecified.
+func SolveLowerTrans(dst, b []float64, l *CSR, unitDiag bool) error {
+	checkTriangular(dst, b, l)
+	copy(dst, b)
+	for i := l.r - 1; i >= 0; i-- {
+		start, end := l.indptr[i], l.indptr[i+1]
+		// Find the end of the strictly lower part of
+		// the row.
+		k := start
+		for k < end && l.ind[k] < i {
+			k++
+		}
+		if !unitDiag {
+			if k == end || l.ind[k] != i || l.data[k] == 0 {
+				return &ZeroDiagonalError{Row: i}
+			}
+			dst[i] /= l.data[k]
+		}
+		xi := dst[i]
+		for p := start; p < k; p++ {
+			dst[l.ind[p]] -= l.data[p] * xi
+		}
+	}
+	return nil
+}
+
+// SolveUpperTrans solves the system
+//  U^T x = b
+// and stores x into dst, where U is the upper triangle of the square matrix
+// u referenced as in SolveUpper. The rows of u are traversed as the columns
+// of U^T, so SolveUpperTrans is a column-oriented forward substitution.
+//
+// dst and b may be the same slice. SolveUpperTrans does not allocate. It
+// returns a *ZeroDiagonalError if a referenced diagonal element is zero or
+// not stored, the contents of dst are then unspecified.
+func SolveUpperTrans(dst, b []float64, u *CSR, unitDiag bool) error {
+	checkTriangular(dst, b, u)
+	copy(dst, b)
+	for i := 0; i < u.r; i++ {
+		start, end := u.indptr[i], u.indptr[i+1]
+		// Find the start of the strictly upper part of
+		// the row.
+		k := end
+		for k > start && u.ind[k-1] > i {
+			k--
+		}
+		if !unitDiag {
+			if k == start || u.ind[k-1] != i || u.data[k-1] == 0 {
+				return &ZeroDiagonalError{Row: i}
+			}
+			dst[i] /= u.data[k-1]
+		}
+		xi := dst[i]
+		for p := k; p < end; p++ {
+			dst[u.ind[p]] -= u.data[p] * xi
+		}
+	}
+	return nil
+}
+
+// lowerRow computes the i-th element of the solution of the lower
+// triangular system in SolveLower from the previous ones.
+func (m *CSR) lowerRow(dst, b []float64, i int, unitDiag bool) error {
+	s := b[i]
+	var d float64
+	for k := m.indptr[i]; k < m.indptr[i+1]; k++ {
+		j := m.ind[k]
+		if j >= i {
+			if j == i {
+				d = m.data[k]
+			}
+			break
+		}
+		s -= m.data[k] * dst[j]
+	}
+	if unitDiag {
+		dst[i] = s
+		return nil
+	}
+	if d == 0 {
+		return &ZeroDiagonalError{Row: i}
+	}
+	dst[i] = s / d
+	return nil
+}
+
+// upperRow computes the i-th element of the solution of the upper
+// triangular system in SolveUpper from the following ones.
+func (m *CSR) upperRow(dst, b []float64, i int, unitDiag bool) error {
+	s := b[i]
+	var d float64
+	for k := m.indptr[i+1] - 1; k >= m.indptr[i]; k-- {
+		j := m.ind[k]
+		if j <= i {
+			if j == i {
+				d = m.data[k]
+			}
+			break
+		}
+		s -= m.data[k] * dst[j]
+	}
+	if unitDiag {
+		dst[i] = s
+		return nil
+	}
+	if d == 0 {
+		return &ZeroDiagonalError{Row: i}
+	}
+	dst[i] = s / d
+	return nil
+}
+
+// checkTriangular panics if t is not square or the lengths of dst and b
+// do not match its dimension.
+func checkTriangular(dst, b []float64, t *CSR) {
+	if t.r != t.c {
+		panic("sparse: matrix not square")
+	}
+	if len(dst) != t.r || len(b) != t.r {
+		panic("sparse: dimension mismatch")
+	}
+}
+
+// LevelSchedule is the partition of the rows of a sparse triangular matrix
+// into levels such that the unknowns of the rows in a level depend only on
+// the unknowns of the rows in the previous levels. The rows within a level
+// are independent, so a triangular solve can compute them concurrently and
+// synchronize only between the levels. This pays off for matrices with
+// many rows per level, for example those reordered by a multicolor
+// ordering, while a banded matrix has about as many levels as rows.
+type LevelSchedule struct {
+	lower bool
+	n     int
+	// The rows of level k are
+	// rows[start[k]:start[k+1]].
+	rows  []int
+	start []int
+	// nnz[k] is the number of stored
+	// elements in the rows of level k.
+	nnz []int
+
+	parallel
+}
+
+// NewLevelSchedule returns the level schedule of the lower triangle of the
+// square matrix t if lower is true, and of its upper triangle otherwise.
+// Only the sparsity pattern of t is used, so the schedule can be reused for
+// matrices with the same pattern. NewLevelSchedule panics if t is not
+// square.
+func NewLevelSchedule(t *CSR, lower bool) *LevelSchedule {
+	if t.r != t.c {
+		panic("sparse: matrix not square")
+	}
+	n := t.r
+	level := make([]int, n)
+	var levels int
+	for k := 0; k < n; k++ {
+		i := k
+		if !lower {
+			i = n - 1 - k
+		}
+		var l int
+		for p := t.indptr[i]; p < t.indptr[i+1]; p++ {
+			if j := t.ind[p]; (lower && j < i) || (!lower && j > i) {
+				if level[j]+1 > l {
+					l = level[j] + 1
+				}
+			}
+		}
+		level[i] = l
+		if l+1 > levels {
+			levels = l + 1
+		}
+	}
+	s := &LevelSchedule{
+		lower: lower,
+		n:     n,
+		rows:  make([]int, n),
+		start: make([]int, levels+1),
+		nnz:   make([]int, levels),
+	}
+	// Sort the rows by level keeping their
+	// order within a level.
+	for i, l := range level {
+		s.start[l+1]++
+		s.nnz[l] += t.indptr[i+1] - t.indptr[i]
+	}
+	for l := 0; l < levels; l++ {
+		s.start[l+1] += s.start[l]
+	}
+	next := make([]int, levels)
+	copy(next, s.start)
+	for i, l := range level {
+		s.rows[next[l]] = i
+		next[l]++
+	}
+	return s
+}
+
+// Levels returns the number of levels of the schedule.
+func (s *LevelSchedule) Levels() int {
+	return len(s.start) - 1
+}
+
+// SetThreads sets the number of goroutines used by Solve for the levels
+// with at least as many stored elements as the threshold set by
+// SetParallelThreshold. If n is 0, runtime.GOMAXPROCS(0) at the time of the
+// call is used. If n is 1, the solves are computed serially, which is the
+// default.
+func (s *LevelSchedule) SetThreads(n int) {
+	s.setThreads(n)
+}
+
+// SetParallelThreshold sets the number of stored elements of a level below
+// which its rows are computed serially regardless of SetThreads. If nnz is
+// 0, DefaultParallelThreshold is used.
+func (s *LevelSchedule) SetParallelThreshold(nnz int) {
+	s.setThreshold(nnz)
+}
+
+// Solve solves the triangular system with the matrix t like SolveLower or
+// SolveUpper, depending on the triangle for which the schedule was
+// computed, processing the rows level by level. t must have the sparsity
+// pattern from which the schedule was computed. The result is identical to
+// that of SolveLower or SolveUpper.
+//
+// dst and b may be the same slice. Solve returns a *ZeroDiagonalError if a
+// referenced diagonal element is zero or not stored, the contents of dst
+// are then unspecified.
+func (s *LevelSchedule) Solve(dst, b []float64, t *CSR, unitDiag bool) error {
+	checkTriangular(dst, b, t)
+	if t.r != s.n {
+		panic("sparse: dimension mismatch")
+	}
+	row := t.upperRow
+	if s.lower {
+		row = t.lowerRow
+	}
+	for l := 0; l < s.Levels(); l++ {
+		rows := s.rows[s.start[l]:s.start[l+1]]
+		n := s.workers(s.nnz[l])
+		if n > len(rows) {
+			n = len(rows)
+		}
+		if n <= 1 {
+			for _, i := range rows {
+				if err := row(dst, b, i, unitDiag); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		errs := make([]error, n)
+		run(n, func(w int) {
+			for _, i := range rows[w*len(rows)/n : (w+1)*len(rows)/n] {
+				if err := row(dst, b, i, unitDiag); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		})
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
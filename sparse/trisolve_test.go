@@ -0,0 +1,285 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// randomTriangularCSR returns a random n×n CSR matrix with at most nnz
+// off-diagonal elements and a diagonal dominant enough for the triangular
+// solves with its triangles to be well conditioned.
+func randomTriangularCSR(n, nnz int, rnd *rand.Rand) *CSR {
+	rows, cols, vals := randomEntries(n, n, nnz, rnd)
+	for i := 0; i < n; i++ {
+		rows = append(rows, i)
+		cols = append(cols, i)
+		vals = append(vals, 4+rnd.Float64())
+	}
+	return NewCSRFromTriplet(NewTripletFromSlices(n, n, rows, cols, vals))
+}
+
+// denseTriangle returns the lower or upper triangle of a as a dense
+// triangular matrix, with a unit diagonal if unit is true.
+func denseTriangle(a *CSR, uplo mat.TriKind, unit bool) *mat.TriDense {
+	n, _ := a.Dims()
+	t := mat.NewTriDense(n, uplo, nil)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			switch {
+			case i == j && unit:
+				t.SetTri(i, j, 1)
+			case i == j || (uplo == mat.Lower) == (j < i):
+				t.SetTri(i, j, a.At(i, j))
+			}
+		}
+	}
+	return t
+}
+
+func TestTriangularSolve(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		n, nnz int
+	}{
+		{1, 0},
+		{5, 0},
+		{5, 10},
+		{20, 60},
+		{100, 1000},
+	} {
+		a := randomTriangularCSR(test.n, test.nnz, rnd)
+		for _, unit := range []bool{false, true} {
+			for _, solve := range []struct {
+				name  string
+				uplo  mat.TriKind
+				trans bool
+				fn    func(dst, b []float64, t *CSR, unitDiag bool) error
+			}{
+				{"SolveLower", mat.Lower, false, SolveLower},
+				{"SolveUpper", mat.Upper, false, SolveUpper},
+				{"SolveLowerTrans", mat.Lower, true, SolveLowerTrans},
+				{"SolveUpperTrans", mat.Upper, true, SolveUpperTrans},
+			} {
+				name := fmt.Sprintf("%s,n=%d,nnz=%d,unit=%t", solve.name, test.n, test.nnz, unit)
+				var tri mat.Matrix = denseTriangle(a, solve.uplo, unit)
+				if solve.trans {
+					tri = tri.T()
+				}
+				b := randomVec(test.n, rnd)
+				var want mat.VecDense
+				if err := want.SolveVec(tri, mat.NewVecDense(test.n, b)); err != nil {
+					t.Fatalf("%s: dense solve failed: %v", name, err)
+				}
+
+				dst := randomVec(test.n, rnd)
+				if err := solve.fn(dst, b, a, unit); err != nil {
+					t.Errorf("%s: unexpected error: %v", name, err)
+					continue
+				}
+				if !equalApprox(dst, want.RawVector().Data, 1e-12) {
+					t.Errorf("%s: solution differs from the dense solve", name)
+				}
+				// Solve in place.
+				x := make([]float64, test.n)
+				copy(x, b)
+				if err := solve.fn(x, x, a, unit); err != nil {
+					t.Errorf("%s: unexpected error in place: %v", name, err)
+					continue
+				}
+				if !equalApprox(x, dst, 0) {
+					t.Errorf("%s: solution in place differs", name)
+				}
+			}
+		}
+	}
+}
+
+func TestTriangularSolveZeroDiagonal(t *testing.T) {
+	// The diagonal element in row 2 is not stored
+	// and the one in row 3 is an explicit zero.
+	tr := NewTriplet(5, 5)
+	for i := 0; i < 5; i++ {
+		switch i {
+		case 2:
+		case 3:
+			tr.Append(i, i, 0)
+		default:
+			tr.Append(i, i, 2)
+		}
+		if i > 0 {
+			tr.Append(i, i-1, 1)
+			tr.Append(i-1, i, 1)
+		}
+	}
+	a := NewCSRFromTriplet(tr)
+	b := []float64{1, 2, 3, 4, 5}
+	dst := make([]float64, 5)
+	for _, test := range []struct {
+		name string
+		fn   func(dst, b []float64, t *CSR, unitDiag bool) error
+		row  int
+	}{
+		{"SolveLower", SolveLower, 2},
+		{"SolveUpper", SolveUpper, 3},
+		{"SolveLowerTrans", SolveLowerTrans, 3},
+		{"SolveUpperTrans", SolveUpperTrans, 2},
+		{"LevelSchedule lower", NewLevelSchedule(a, true).Solve, 2},
+		{"LevelSchedule upper", NewLevelSchedule(a, false).Solve, 3},
+	} {
+		err := test.fn(dst, b, a, false)
+		var zd *ZeroDiagonalError
+		if !errors.As(err, &zd) || zd.Row != test.row {
+			t.Errorf("%s: unexpected error %v, want zero diagonal in row %d", test.name, err, test.row)
+		}
+		if err := test.fn(dst, b, a, true); err != nil {
+			t.Errorf("%s: unexpected error with unit diagonal: %v", test.name, err)
+		}
+	}
+}
+
+func TestTriangularSolvePanics(t *testing.T) {
+	a := randomTriangularCSR(5, 10, rand.New(rand.NewSource(1)))
+	rect := NewCSRFromTriplet(NewTriplet(5, 4))
+	for _, fn := range []func(dst, b []float64, t *CSR, unitDiag bool) error{
+		SolveLower, SolveUpper, SolveLowerTrans, SolveUpperTrans,
+	} {
+		if !panics(func() { fn(make([]float64, 5), make([]float64, 4), a, false) }) {
+			t.Errorf("short b did not panic")
+		}
+		if !panics(func() { fn(make([]float64, 4), make([]float64, 5), a, false) }) {
+			t.Errorf("short dst did not panic")
+		}
+		if !panics(func() { fn(make([]float64, 5), make([]float64, 5), rect, false) }) {
+			t.Errorf("rectangular matrix did not panic")
+		}
+	}
+}
+
+// tridiagonal returns the n×n tridiagonal matrix with 2 on the diagonal and
+// -1 on the off-diagonals.
+func tridiagonal(n int) *CSR {
+	t := NewTriplet(n, n)
+	for i := 0; i < n; i++ {
+		t.Append(i, i, 2)
+		if i > 0 {
+			t.Append(i, i-1, -1)
+			t.Append(i-1, i, -1)
+		}
+	}
+	return NewCSRFromTriplet(t)
+}
+
+// grid returns the 5-point Laplacian on the nx×ny grid with the vertices
+// numbered by rows.
+func grid(nx, ny int) *CSR {
+	t := NewTriplet(nx*ny, nx*ny)
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			i := y*nx + x
+			t.Append(i, i, 4)
+			if x > 0 {
+				t.Append(i, i-1, -1)
+				t.Append(i-1, i, -1)
+			}
+			if y > 0 {
+				t.Append(i, i-nx, -1)
+				t.Append(i-nx, i, -1)
+			}
+		}
+	}
+	return NewCSRFromTriplet(t)
+}
+
+func TestLevelSchedule(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		name   string
+		a      *CSR
+		levels int
+	}{
+		{"diagonal", randomTriangularCSR(50, 0, rnd), 1},
+		{"tridiagonal", tridiagonal(30), 30},
+		{"grid 20×10", grid(20, 10), 29},
+		{"random", randomTriangularCSR(200, 1000, rnd), -1},
+	} {
+		n, _ := test.a.Dims()
+		for _, lower := range []bool{true, false} {
+			name := fmt.Sprintf("%s,lower=%t", test.name, lower)
+			s := NewLevelSchedule(test.a, lower)
+			if test.levels >= 0 && s.Levels() != test.levels {
+				t.Errorf("%s: unexpected number of levels %d, want %d", name, s.Levels(), test.levels)
+			}
+			// The rows in a level depend only on the
+			// rows in the previous levels.
+			level := make([]int, n)
+			for l := 0; l < s.Levels(); l++ {
+				for _, i := range s.rows[s.start[l]:s.start[l+1]] {
+					level[i] = l
+				}
+			}
+			for i := 0; i < n; i++ {
+				ind, _ := test.a.RowView(i)
+				for _, j := range ind {
+					if (lower && j < i || !lower && j > i) && level[j] >= level[i] {
+						t.Errorf("%s: row %d in level %d depends on row %d in level %d", name, i, level[i], j, level[j])
+					}
+				}
+			}
+
+			b := randomVec(n, rnd)
+			want := make([]float64, n)
+			solve := SolveUpper
+			if lower {
+				solve = SolveLower
+			}
+			if err := solve(want, b, test.a, false); err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			for _, threads := range []int{1, 4} {
+				s.SetThreads(threads)
+				s.SetParallelThreshold(1)
+				got := make([]float64, n)
+				if err := s.Solve(got, b, test.a, false); err != nil {
+					t.Errorf("%s,threads=%d: unexpected error: %v", name, threads, err)
+					continue
+				}
+				if !equalApprox(got, want, 0) {
+					t.Errorf("%s,threads=%d: solution differs from the serial solve", name, threads)
+				}
+			}
+		}
+	}
+}
+
+func benchmarkTriangularSolve(b *testing.B, solve func(dst, b []float64, t *CSR, unitDiag bool) error, t *CSR) {
+	n, _ := t.Dims()
+	x := randomVec(n, rand.New(rand.NewSource(1)))
+	dst := make([]float64, n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		solve(dst, x, t, false)
+	}
+}
+
+func BenchmarkSolveLowerPoisson(b *testing.B) {
+	benchmarkTriangularSolve(b, SolveLower, grid(benchPoissonN, benchPoissonN))
+}
+
+func BenchmarkSolveLowerTransPoisson(b *testing.B) {
+	benchmarkTriangularSolve(b, SolveLowerTrans, grid(benchPoissonN, benchPoissonN))
+}
+
+func BenchmarkLevelScheduleSolveRandom(b *testing.B) {
+	a := randomTriangularCSR(benchN, benchNNZ, rand.New(rand.NewSource(1)))
+	s := NewLevelSchedule(a, true)
+	s.SetThreads(0)
+	benchmarkTriangularSolve(b, s.Solve, a)
+}
@@ -0,0 +1,105 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sparse
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// tuneGain is the factor by which MulVec with more goroutines must be
+// faster to be preferred by Tune. It keeps Tune from picking more
+// goroutines because of noise in the timings.
+const tuneGain = 0.9
+
+// Tune measures MulVec with different numbers of goroutines for about the
+// given total duration, fixes the fastest one and returns it. The numbers
+// of goroutines are the powers of two smaller than runtime.GOMAXPROCS(0)
+// and runtime.GOMAXPROCS(0) itself. A larger number is chosen only if it
+// is at least 10% faster than the best smaller one.
+//
+// Tune sets the number of goroutines with SetThreads and the parallel
+// threshold to one, so the result is used regardless of the number of
+// stored elements. The result can be stored and applied to a matrix with
+// the same structure on the same machine without tuning by
+//  m.SetThreads(n)
+//  m.SetParallelThreshold(1)
+//
+// Tune panics if budget is not positive.
+func (m *CSR) Tune(budget time.Duration) int {
+	if budget <= 0 {
+		panic("sparse: non-positive tuning budget")
+	}
+	x := make([]float64, m.c)
+	for i := range x {
+		x[i] = 1
+	}
+	dst := make([]float64, m.r)
+
+	candidates := workerCandidates()
+	share := budget / time.Duration(len(candidates))
+	best := 1
+	bestTime := time.Duration(-1)
+	for _, n := range candidates {
+		m.setThreads(n)
+		m.setThreshold(1)
+		m.MulVec(dst, x) // Warm up the caches.
+		var ops int
+		start := time.Now()
+		elapsed := time.Duration(0)
+		for ops == 0 || elapsed < share {
+			m.MulVec(dst, x)
+			ops++
+			elapsed = time.Since(start)
+		}
+		t := elapsed / time.Duration(ops)
+		if bestTime < 0 || float64(t) < tuneGain*float64(bestTime) {
+			best = n
+			bestTime = t
+		}
+	}
+	m.setThreads(best)
+	m.setThreshold(1)
+	return best
+}
+
+// BenchmarkMulVec benchmarks m.MulVec with the numbers of goroutines tried
+// by Tune in sub-benchmarks named threads=n. It can be called from the
+// benchmarks of the users of the package, for example
+//  func BenchmarkMatrix(b *testing.B) {
+//  	sparse.BenchmarkMulVec(b, loadMatrix())
+//  }
+// The settings of m are restored when BenchmarkMulVec returns.
+func BenchmarkMulVec(b *testing.B, m *CSR) {
+	saved := m.parallel
+	defer func() { m.parallel = saved }()
+
+	x := make([]float64, m.c)
+	for i := range x {
+		x[i] = 1
+	}
+	dst := make([]float64, m.r)
+	for _, n := range workerCandidates() {
+		b.Run(fmt.Sprintf("threads=%d", n), func(b *testing.B) {
+			m.setThreads(n)
+			m.setThreshold(1)
+			for i := 0; i < b.N; i++ {
+				m.MulVec(dst, x)
+			}
+		})
+	}
+}
+
+// workerCandidates returns the numbers of goroutines tried by Tune.
+func workerCandidates() []int {
+	procs := runtime.GOMAXPROCS(0)
+	var c []int
+	for n := 1; n < procs; n *= 2 {
+		c = append(c, n)
+	}
+	return append(c, procs)
+}
@@ -0,0 +1,214 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// SSOR implements the symmetric successive over-relaxation iteration for
+// solving the system of linear equations
+//  Ax = b,
+// where A = L + D + L^T is a symmetric matrix with a positive diagonal D.
+// An iteration is a forward SOR sweep followed by a backward one, which is
+// the iteration
+//  x_i = x_{i-1} + M^{-1} (b - A x_{i-1})
+// with the symmetric matrix
+//  M = 1/(2-ω) (D/ω + L) (D/ω)^{-1} (D/ω + L^T),
+// where ω is Omega. Because M is symmetric, the iteration can be
+// accelerated, for example by using M as a preconditioner for CG.
+//
+// SSOR issues the sweeps as the PSolve and PSolveTrans operations with the
+// factor
+//  F = (D/ω + L) (D/ω)^{-1/2}
+// of M = 1/(2-ω) F F^T, so Settings.PSolve must solve F y = r and
+// Settings.PSolveTrans must solve F^T z = y, for example with the Apply
+// and ApplyTrans methods of the Preconditioner returned by Splitting. The
+// residual is then updated with a single product with A per iteration.
+//
+// For a symmetric positive definite A the iteration converges for
+// 0 < ω < 2. If it diverges and the residual norm overflows, Iterate
+// returns a *BreakdownError.
+//
+// SSOR needs MatVec, PSolve and PSolveTrans matrix operations.
+type SSOR struct {
+	// Omega is the relaxation parameter ω.
+	// If it is zero, 1 is used, which is the
+	// symmetric Gauss-Seidel iteration. It
+	// must be in the interval (0, 2).
+	Omega float64
+
+	resume int
+	omega  float64
+
+	y  []float64
+	z  []float64
+	az []float64
+}
+
+// Init implements the Method interface.
+func (s *SSOR) Init(dim int) {
+	if dim <= 0 {
+		panic("SSOR: dimension not positive")
+	}
+	s.omega = s.relaxation()
+
+	s.y = reuse(s.y, dim)
+	s.z = reuse(s.z, dim)
+	s.az = reuse(s.az, dim)
+	s.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (s *SSOR) Iterate(ctx *Context) (Operation, error) {
+	switch s.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = s.y
+		s.resume = 2
+		return PSolve, nil
+		// Forward sweep, solve F y = r_{i-1}
+	case 2:
+		ctx.Src = s.y
+		ctx.Dst = s.z
+		s.resume = 3
+		return PSolveTrans, nil
+		// Backward sweep, solve F^T z = y
+	case 3:
+		ctx.vec.scale(2-s.omega, s.z) // z = M^{-1} r_{i-1}
+		ctx.vec.add(ctx.X, s.z)       // x_i = x_{i-1} + z
+		ctx.Src = s.z
+		ctx.Dst = s.az
+		s.resume = 4
+		return MatVec, nil
+		// Compute Az
+	case 4:
+		rr := ctx.vec.axpyDot(-1, s.az, ctx.Residual) // r_i = r_{i-1} - Az
+		ctx.ResidualNorm = math.Sqrt(rr)
+		if math.IsNaN(ctx.ResidualNorm) || math.IsInf(ctx.ResidualNorm, 0) {
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"SSOR", "residual norm"}
+		}
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		s.resume = 5
+		return CheckResidualNorm, nil
+	case 5:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		s.resume = 1
+		return EndIteration, nil
+
+	default:
+		panic("SSOR: Init not called")
+	}
+}
+
+// Splitting returns the Preconditioner F = (D/ω + L) (D/ω)^{-1/2} of the
+// symmetric matrix a with ω = Omega, whose Apply and ApplyTrans methods
+// are the PSolve and PSolveTrans needed by SSOR. Only the lower triangle
+// of a is used. Splitting returns an error if a diagonal element of a is
+// not positive. The Preconditioner refers to a, which must not be modified
+// while it is used.
+func (s *SSOR) Splitting(a *sparse.CSR) (Preconditioner, error) {
+	if err := checkPositiveDiagonal(a); err != nil {
+		return nil, err
+	}
+	sor, err := newSORSplitting(a, s.relaxation())
+	if err != nil {
+		return nil, err
+	}
+	return newSSORSplitting(sor, sor.diag), nil
+}
+
+// ColoredSplitting returns the Preconditioner F = (D/ω + L_c) (D/ω)^{-1/2}
+// of the symmetric matrix a with ω = Omega for the multicolor SSOR
+// iteration, where L_c holds the elements a[i,j] with colors[i] >
+// colors[j]. The forward and backward sweeps update the colors in
+// increasing and decreasing order, and the rows within a color are
+// computed concurrently by threads goroutines as described in
+// SOR.ColoredSplitting. Because L_c has elements from both triangles of a,
+// both must be stored.
+//
+// ColoredSplitting returns an error if a diagonal element of a is not
+// positive or if two coupled rows have the same color. The Preconditioner
+// refers to a, which must not be modified while it is used.
+func (s *SSOR) ColoredSplitting(a *sparse.CSR, colors []int, threads int) (Preconditioner, error) {
+	if err := checkPositiveDiagonal(a); err != nil {
+		return nil, err
+	}
+	sor, err := newColoredSORSplitting(a, s.relaxation(), colors, threads)
+	if err != nil {
+		return nil, err
+	}
+	return newSSORSplitting(sor, sor.diag), nil
+}
+
+// checkPositiveDiagonal returns an error if a diagonal element of a is not
+// positive.
+func checkPositiveDiagonal(a *sparse.CSR) error {
+	for i, v := range sparse.Diagonal(a) {
+		if !(v > 0) {
+			return fmt.Errorf("iterative: diagonal element %d not positive", i)
+		}
+	}
+	return nil
+}
+
+// relaxation returns the relaxation parameter of s.
+func (s *SSOR) relaxation() float64 {
+	if s.Omega < 0 || 2 <= s.Omega {
+		panic("SSOR: relaxation parameter out of range")
+	}
+	if s.Omega == 0 {
+		return 1
+	}
+	return s.Omega
+}
+
+// ssorSplitting is the factor
+//  F = (D/ω + L) (D/ω)^{-1/2}
+// of the SSOR matrix of a symmetric matrix A = L + D + L^T, where sor is
+// the SOR splitting D/ω + L, possibly the multicolor one.
+type ssorSplitting struct {
+	sor Preconditioner
+	// sqrtDiag is (D/ω)^{1/2}.
+	sqrtDiag []float64
+}
+
+// newSSORSplitting returns the SSOR factor of the SOR splitting sor with
+// the diagonal D/ω.
+func newSSORSplitting(sor Preconditioner, diag []float64) *ssorSplitting {
+	sqrtDiag := make([]float64, len(diag))
+	for i, v := range diag {
+		sqrtDiag[i] = math.Sqrt(v)
+	}
+	return &ssorSplitting{sor: sor, sqrtDiag: sqrtDiag}
+}
+
+// Apply implements the Preconditioner interface.
+func (p *ssorSplitting) Apply(dst, rhs []float64) error {
+	p.sor.Apply(dst, rhs)
+	for i, v := range p.sqrtDiag {
+		dst[i] *= v
+	}
+	return nil
+}
+
+// ApplyTrans implements the Preconditioner interface.
+func (p *ssorSplitting) ApplyTrans(dst, rhs []float64) error {
+	checkLen(dst, rhs, len(p.sqrtDiag))
+	for i, v := range p.sqrtDiag {
+		dst[i] = v * rhs[i]
+	}
+	return p.sor.ApplyTrans(dst, dst)
+}
@@ -0,0 +1,317 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat"
+)
+
+func TestSSOR(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		tol   float64
+		omega float64
+	}{
+		{"nos4", 1e-8, 0},
+		{"nos4", 1e-8, 1.5},
+		{"bcsstm22", 1e-8, 0},
+		{"bcsstm22", 1e-8, 1.2},
+	} {
+		p := market(test.name, test.tol)
+		s := &iterative.SSOR{Omega: test.omega}
+		pc, err := s.Splitting(marketCSR(test.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.Solve(s, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 100000,
+			PSolve:        pc.Apply,
+			PSolveTrans:   pc.ApplyTrans,
+		})
+		if err != nil {
+			t.Errorf("Omega=%v: %v", test.omega, err)
+		}
+	}
+}
+
+// ssorSweeps does a forward and a backward SOR sweep with the relaxation
+// parameter omega on x for the dense n×n matrix a.
+func ssorSweeps(a []float64, n int, b, x []float64, omega float64) {
+	sweep := func(i int) {
+		s := b[i]
+		for j := 0; j < n; j++ {
+			if j != i {
+				s -= a[i*n+j] * x[j]
+			}
+		}
+		x[i] = (1-omega)*x[i] + omega*s/a[i*n+i]
+	}
+	for i := 0; i < n; i++ {
+		sweep(i)
+	}
+	for i := n - 1; i >= 0; i-- {
+		sweep(i)
+	}
+}
+
+func TestSSORSweeps(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 2, 5, 10} {
+		// A random symmetric positive definite
+		// matrix with zeros.
+		a := make([]float64, n*n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < i; j++ {
+				if rnd.Intn(2) == 0 {
+					continue
+				}
+				v := rnd.NormFloat64()
+				a[i*n+j] = v
+				a[j*n+i] = v
+			}
+		}
+		for i := 0; i < n; i++ {
+			var s float64
+			for j := 0; j < n; j++ {
+				s += math.Abs(a[i*n+j])
+			}
+			a[i*n+i] = s + 1
+		}
+		trip := sparse.NewTriplet(n, n)
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				if a[i*n+j] != 0 {
+					trip.Append(i, j, a[i*n+j])
+				}
+			}
+		}
+		csr := sparse.NewCSRFromTriplet(trip)
+		b := make([]float64, n)
+		for i := range b {
+			b[i] = rnd.NormFloat64()
+		}
+
+		for _, omega := range []float64{0.5, 1, 1.7} {
+			s := &iterative.SSOR{Omega: omega}
+			pc, err := s.Splitting(csr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := iterative.NewLoop(iterative.MatrixOps{MatVec: csr.MulVec}, b, s, iterative.Settings{
+				Tolerance:     1e-14,
+				MaxIterations: 10,
+				PSolve:        pc.Apply,
+				PSolveTrans:   pc.ApplyTrans,
+			})
+			want := make([]float64, n)
+			for k := 1; ; k++ {
+				done, _ := l.Step()
+				for !done && l.Operation() != iterative.EndIteration {
+					done, _ = l.Step()
+				}
+				if l.Operation() != iterative.EndIteration {
+					break
+				}
+				ssorSweeps(a, n, b, want, omega)
+				if d := floats.Distance(l.Context().X, want, math.Inf(1)); d > 1e-12*floats.Norm(want, math.Inf(1)) {
+					t.Errorf("n=%d,omega=%v: iterate %d differs from the sweeps by %v", n, omega, k, d)
+					break
+				}
+				if done {
+					break
+				}
+			}
+		}
+	}
+
+	if _, err := (&iterative.SSOR{}).Splitting(tridiagonalCSR(5, 1, -2, 1)); err == nil {
+		t.Errorf("no error with a negative diagonal")
+	}
+}
+
+func TestSSORDivergence(t *testing.T) {
+	// The symmetric matrix with a positive diagonal
+	// is indefinite, SSOR diverges.
+	a := tridiagonalCSR(10, 2, 1, 2)
+	s := &iterative.SSOR{}
+	pc, err := s.Splitting(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]float64, 10)
+	for i := range b {
+		b[i] = 1
+	}
+	res, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: a.MulVec}, b, s, iterative.Settings{
+		MaxIterations: 100000,
+		PSolve:        pc.Apply,
+		PSolveTrans:   pc.ApplyTrans,
+	})
+	var breakdown *iterative.BreakdownError
+	if !errors.As(err, &breakdown) || breakdown.Method != "SSOR" {
+		t.Errorf("unexpected error %v, want SSOR breakdown", err)
+	}
+	if res.Stats.Iterations >= 100000 {
+		t.Errorf("divergence not detected")
+	}
+}
+
+func TestSSORColoredSplitting(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, n := range []int{1, 10, 50, 200} {
+		a := testmat.RandomSparse(n, 5, 10, 0, rnd)
+		colors, _ := sparse.ColorRows(a)
+		perm := colorOrder(colors)
+		for _, omega := range []float64{0, 1.3} {
+			s := &iterative.SSOR{Omega: omega}
+			plain, err := s.Splitting(sparse.Permute(a, perm, perm))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, threads := range []int{1, 4} {
+				colored, err := s.ColoredSplitting(a, colors, threads)
+				if err != nil {
+					t.Fatal(err)
+				}
+				name := fmt.Sprintf("n=%d,omega=%v,threads=%d", n, omega, threads)
+				testColoredSplitting(t, name, colored, plain, perm, rnd)
+			}
+		}
+	}
+
+	colors, _ := sparse.ColorRows(tridiagonalCSR(5, 1, -2, 1))
+	if _, err := (&iterative.SSOR{}).ColoredSplitting(tridiagonalCSR(5, 1, -2, 1), colors, 1); err == nil {
+		t.Errorf("no error with a negative diagonal")
+	}
+}
+
+// ssorInverse returns the PSolve with the SSOR matrix M = 1/(2-ω) F F^T of
+// the factor F returned by SSOR.Splitting or SSOR.ColoredSplitting.
+func ssorInverse(f iterative.Preconditioner, omega float64) func(dst, rhs []float64) error {
+	var y []float64
+	return func(dst, rhs []float64) error {
+		if len(y) != len(rhs) {
+			y = make([]float64, len(rhs))
+		}
+		if err := f.Apply(y, rhs); err != nil {
+			return err
+		}
+		if err := f.ApplyTrans(dst, y); err != nil {
+			return err
+		}
+		floats.Scale(2-omega, dst)
+		return nil
+	}
+}
+
+func TestSSORColoredPCG(t *testing.T) {
+	for _, n := range []int{10, 30, 60} {
+		p := testmat.Poisson2D(n, n)
+		a := p.CSR()
+		x, b := p.Manufactured()
+		colors, numColors := sparse.ColorRows(a)
+		if numColors != 2 {
+			t.Errorf("n=%d: unexpected number of colors %d", n, numColors)
+		}
+		solve := func(name string, f iterative.Preconditioner, omega float64) int {
+			res, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: a.MulVec}, b, &iterative.CG{}, iterative.Settings{
+				Tolerance: 1e-10,
+				PSolve:    ssorInverse(f, omega),
+			})
+			if err != nil {
+				t.Errorf("n=%d,%s: unexpected error: %v", n, name, err)
+				return 0
+			}
+			if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-7 {
+				t.Errorf("n=%d,%s: solution differs by %v", n, name, d)
+			}
+			return res.Stats.Iterations
+		}
+		none, err := iterative.LinearSolve(iterative.MatrixOps{MatVec: a.MulVec}, b, &iterative.CG{}, iterative.Settings{Tolerance: 1e-10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The symmetric Gauss-Seidel preconditioner.
+		// Over-relaxation does not speed up the
+		// red-black ordering, so ω > 1 is not tested.
+		s := &iterative.SSOR{}
+		f, err := s.Splitting(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		natural := solve("natural", f, 1)
+		var serial int
+		for _, threads := range []int{1, 4} {
+			f, err := s.ColoredSplitting(a, colors, threads)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := fmt.Sprintf("threads=%d", threads)
+			colored := solve(name, f, 1)
+			if threads == 1 {
+				serial = colored
+			} else if colored != serial {
+				t.Errorf("n=%d,%s: %d iterations, serially %d", n, name, colored, serial)
+			}
+			// The red-black ordering is a weaker
+			// preconditioner than the natural one,
+			// but it still helps.
+			if colored >= none.Stats.Iterations || 2*colored > 3*natural {
+				t.Errorf("n=%d,%s: %d iterations, with the natural ordering %d, without preconditioner %d",
+					n, name, colored, natural, none.Stats.Iterations)
+			}
+		}
+	}
+}
+
+// benchmarkSSORSweeps measures a forward and a backward sweep with the
+// factor returned by splitting for the 2D Poisson matrix.
+func benchmarkSSORSweeps(b *testing.B, splitting func(a *sparse.CSR) (iterative.Preconditioner, error)) {
+	const n = 500
+	a := testmat.Poisson2D(n, n).CSR()
+	f, err := splitting(a)
+	if err != nil {
+		b.Fatal(err)
+	}
+	psolve := ssorInverse(f, 1)
+	rhs := make([]float64, n*n)
+	for i := range rhs {
+		rhs[i] = 1
+	}
+	dst := make([]float64, n*n)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		psolve(dst, rhs)
+	}
+}
+
+func BenchmarkSSORSweepsNatural(b *testing.B) {
+	benchmarkSSORSweeps(b, (&iterative.SSOR{}).Splitting)
+}
+
+func BenchmarkSSORSweepsColored(b *testing.B) {
+	benchmarkSSORSweeps(b, func(a *sparse.CSR) (iterative.Preconditioner, error) {
+		colors, _ := sparse.ColorRows(a)
+		return (&iterative.SSOR{}).ColoredSplitting(a, colors, 1)
+	})
+}
+
+func BenchmarkSSORSweepsColoredParallel(b *testing.B) {
+	benchmarkSSORSweeps(b, func(a *sparse.CSR) (iterative.Preconditioner, error) {
+		colors, _ := sparse.ColorRows(a)
+		return (&iterative.SSOR{}).ColoredSplitting(a, colors, -1)
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+// SteepestDescent implements the method of steepest descent
+//  x_i = x_{i-1} + α_i r_{i-1},  α_i = (r_{i-1}·r_{i-1}) / (r_{i-1}·Ar_{i-1}),
+// for solving the system of linear equations
+//  Ax = b,
+// where A is a symmetric positive definite matrix. Each step minimizes the
+// A-norm of the error along the residual, which is the negative gradient of
+// the quadratic form 1/2 x·Ax - b·x. If SteepestDescent encounters a
+// residual r with r·Ar <= 0, the matrix is not positive definite and
+// Iterate returns a *NotPositiveDefiniteError.
+//
+// The A-norm of the error decreases by a factor of at most (κ-1)/(κ+1) per
+// iteration, where κ is the condition number of A, so the method needs
+// O(κ) iterations where CG needs O(√κ). It is mainly useful as a reference
+// for comparison with other methods.
+//
+// SteepestDescent needs MatVec matrix operation, it does not use a
+// preconditioner.
+type SteepestDescent struct {
+	resume int
+
+	ar []float64
+}
+
+// Init implements the Method interface.
+func (sd *SteepestDescent) Init(dim int) {
+	if dim <= 0 {
+		panic("SteepestDescent: dimension not positive")
+	}
+
+	sd.ar = reuse(sd.ar, dim)
+	sd.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (sd *SteepestDescent) Iterate(ctx *Context) (Operation, error) {
+	switch sd.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = sd.ar
+		sd.resume = 2
+		return MatVec, nil
+		// Compute Ar_{i-1}
+	case 2:
+		rar := ctx.vec.dot(ctx.Residual, sd.ar)
+		if rar <= 0 {
+			sd.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "SteepestDescent"}
+		}
+		alpha := ctx.vec.dot(ctx.Residual, ctx.Residual) / rar
+		// x_i = x_{i-1} + α r_{i-1}
+		// r_i = r_{i-1} - α Ar_{i-1}
+		// cgUpdate reads each element of r before
+		// updating it, so r can be passed as p.
+		ctx.ResidualNorm = ctx.vec.cgUpdate(alpha, ctx.X, ctx.Residual, ctx.Residual, sd.ar)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		sd.resume = 3
+		return CheckResidualNorm, nil
+	case 3:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			sd.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		sd.resume = 1
+		return EndIteration, nil
+
+	default:
+		panic("SteepestDescent: Init not called")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/methodtest"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestSteepestDescent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.Poisson2D(10, 10),
+		market("nos4", 1e-8),
+		market("bcsstm22", 1e-8),
+	} {
+		settings := iterative.Settings{
+			MaxIterations: 100 * p.MaxIterations,
+			Tolerance:     1e-12,
+		}
+		_, err := p.Solve(&iterative.SteepestDescent{}, settings)
+		if err != nil {
+			t.Error(err)
+		}
+		methodtest.ErrorANormNonIncreasing(t, p, &iterative.SteepestDescent{}, settings)
+	}
+}
+
+func TestSteepestDescentNotPositiveDefinite(t *testing.T) {
+	// A is negative definite, so r·Ar < 0 in the
+	// first iteration.
+	const n = 10
+	a := tridiagonalCSR(n, 1, -2, 1)
+	b := make([]float64, n)
+	for i := range b {
+		b[i] = 1
+	}
+	ops := iterative.MatrixOps{MatVec: a.MulVec}
+	_, err := iterative.LinearSolve(ops, b, &iterative.SteepestDescent{}, iterative.Settings{})
+	var npd *iterative.NotPositiveDefiniteError
+	if !errors.As(err, &npd) || npd.Method != "SteepestDescent" {
+		t.Errorf("unexpected error %v, want SteepestDescent: matrix not positive definite", err)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import "math"
+
+// SYMMLQ implements the SYMMLQ iterative method with preconditioning for
+// solving the system of linear equations
+//  Ax = b,
+// where A is a symmetric, possibly indefinite matrix. The preconditioner
+// must be symmetric positive definite.
+//
+// SYMMLQ is based on the Lanczos process like CG, but instead of the
+// Galerkin condition, which may not be satisfiable for an indefinite A,
+// it computes the iterates from the LQ factorization of the Lanczos
+// tridiagonal matrix. The iterates minimize the error in the Euclidean
+// norm, or in the M-norm with a preconditioner M, over a Krylov subspace,
+// and their errors decrease monotonically also for an indefinite A. The
+// CG iterate, if it exists, is a cheap update of the SYMMLQ iterate, and
+// SYMMLQ reports the smaller of their residual norms in the
+// M^{-1}-norm. When the solve converges on the CG iterate, X is moved to
+// it, so on a symmetric positive definite system SYMMLQ computes the same
+// solution as CG in exact arithmetic. SYMMLQ does not form the residual,
+// Context.Residual is not current at the end of an iteration.
+//
+// If the Lanczos process breaks down before the solve converges, Iterate
+// returns a *BreakdownError. If a preconditioned vector has a negative
+// inner product with its right-hand side, it returns a
+// *NotPositiveDefiniteError.
+//
+// References:
+//  - Paige, C. C., Saunders, M. A.: Solution of sparse indefinite systems of
+//    linear equations. SIAM J. Numer. Anal. 12(4), 617-629 (1975)
+//
+// SYMMLQ needs MatVec and PSolve matrix operations.
+type SYMMLQ struct {
+	resume int
+
+	// beta1 is the M^{-1}-norm of the initial
+	// residual, beta and betaPrev are the last
+	// two off-diagonal elements of the Lanczos
+	// tridiagonal matrix and alpha the last
+	// diagonal element.
+	beta1, beta, betaPrev, alpha float64
+	// tnorm is the square of the Frobenius
+	// norm of the tridiagonal matrix, an
+	// estimate of |A|^2.
+	tnorm float64
+	// gbar and dbar are the last diagonal and
+	// subdiagonal elements of the lower
+	// triangular factor L of the LQ
+	// factorization, before the last rotation
+	// is applied.
+	gbar, dbar float64
+	// rhs1 and rhs2 are the right-hand side
+	// elements of the next two steps of the
+	// solution of L z = beta1 e_1.
+	rhs1, rhs2 float64
+	// snprod is the product of the sines of
+	// the rotations.
+	snprod float64
+	// cg indicates whether the CG iterate has
+	// a smaller residual norm than the SYMMLQ
+	// iterate, and zbar is its step along w.
+	cg   bool
+	zbar float64
+
+	// r1 and r2 are the last two unscaled
+	// Lanczos vectors and y the preconditioned
+	// r2.
+	r1, r2, y []float64
+	// v is the current Lanczos vector.
+	v []float64
+	// w is the direction along which X is
+	// updated, the last column of V Q^T.
+	w []float64
+}
+
+// Init implements the Method interface.
+func (s *SYMMLQ) Init(dim int) {
+	if dim <= 0 {
+		panic("SYMMLQ: dimension not positive")
+	}
+
+	s.r1 = reuse(s.r1, dim)
+	s.r2 = reuse(s.r2, dim)
+	s.y = reuse(s.y, dim)
+	s.v = reuse(s.v, dim)
+	s.w = reuse(s.w, dim)
+
+	s.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (s *SYMMLQ) Iterate(ctx *Context) (Operation, error) {
+	switch s.resume {
+	case 1:
+		ctx.vec.copy(s.r1, ctx.Residual)
+		ctx.Src = s.r1
+		ctx.Dst = s.y
+		s.resume = 2
+		return PSolve, nil
+		// Solve M y = r_0
+	case 2:
+		beta1 := ctx.vec.dot(s.r1, s.y)
+		if beta1 <= 0 {
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			if beta1 < 0 {
+				return NoOperation, &NotPositiveDefiniteError{Method: "SYMMLQ", Preconditioner: true}
+			}
+			return NoOperation, &BreakdownError{"SYMMLQ", "beta"}
+		}
+		s.beta1 = math.Sqrt(beta1)
+		// v_1 = y / β_1. The vectors are swapped
+		// instead of copied, the next MatVec
+		// overwrites y.
+		s.v, s.y = s.y, s.v
+		ctx.vec.scale(1/s.beta1, s.v)
+		ctx.vec.copy(s.w, s.v)
+		ctx.Src = s.v
+		ctx.Dst = s.y
+		s.resume = 3
+		return MatVec, nil
+		// y = A v_1
+	case 3:
+		s.alpha = ctx.vec.dot(s.v, s.y)
+		ctx.vec.addScaled(s.y, -s.alpha/s.beta1, s.r1)
+		// Make sure that r_2 is orthogonal to
+		// v_1.
+		ctx.vec.addScaled(s.y, -ctx.vec.dot(s.v, s.y)/ctx.vec.dot(s.v, s.v), s.v)
+		s.r2, s.y = s.y, s.r2
+		ctx.Src = s.r2
+		ctx.Dst = s.y
+		s.resume = 4
+		return PSolve, nil
+		// Solve M y = r_2
+	case 4:
+		s.betaPrev = s.beta1
+		if err := s.nextBeta(ctx); err != nil {
+			return NoOperation, err
+		}
+		s.tnorm = s.alpha*s.alpha + s.beta*s.beta
+		s.gbar = s.alpha
+		s.dbar = s.beta
+		s.rhs1 = s.beta1
+		s.rhs2 = 0
+		s.snprod = 1
+		return s.checkResidualNorm(ctx)
+	case 5:
+		if s.beta <= eps*math.Sqrt(s.tnorm) {
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &BreakdownError{"SYMMLQ", "beta"}
+		}
+		// v_k = y / β_k
+		s.v, s.y = s.y, s.v
+		ctx.vec.scale(1/s.beta, s.v)
+		ctx.Src = s.v
+		ctx.Dst = s.y
+		s.resume = 6
+		return MatVec, nil
+		// y = A v_k
+	case 6:
+		ctx.vec.addScaled(s.y, -s.beta/s.betaPrev, s.r1)
+		s.alpha = ctx.vec.dot(s.v, s.y)
+		ctx.vec.addScaled(s.y, -s.alpha/s.beta, s.r2)
+		s.r1, s.r2, s.y = s.r2, s.y, s.r1
+		ctx.Src = s.r2
+		ctx.Dst = s.y
+		s.resume = 7
+		return PSolve, nil
+		// Solve M y = r_{k+1}
+	case 7:
+		s.betaPrev = s.beta
+		if err := s.nextBeta(ctx); err != nil {
+			return NoOperation, err
+		}
+		s.tnorm += s.alpha*s.alpha + s.betaPrev*s.betaPrev + s.beta*s.beta
+
+		// Compute and apply the next plane
+		// rotation of the LQ factorization.
+		gamma := math.Hypot(s.gbar, s.betaPrev)
+		cs := s.gbar / gamma
+		sn := s.betaPrev / gamma
+		delta := cs*s.dbar + sn*s.alpha
+		s.gbar = sn*s.dbar - cs*s.alpha
+		epsln := sn * s.beta
+		s.dbar = -cs * s.beta
+
+		// Update the SYMMLQ iterate.
+		z := s.rhs1 / gamma
+		ctx.vec.addScaled(ctx.X, z*cs, s.w)
+		ctx.vec.addScaled(ctx.X, z*sn, s.v)
+		ctx.vec.scale(sn, s.w)
+		ctx.vec.addScaled(s.w, -cs, s.v)
+		s.rhs1 = s.rhs2 - delta*z
+		s.rhs2 = -epsln * z
+		s.snprod *= sn
+		return s.checkResidualNorm(ctx)
+	case 8:
+		if ctx.Converged {
+			if s.cg {
+				// Move to the CG iterate.
+				ctx.vec.addScaled(ctx.X, s.zbar, s.w)
+			}
+			s.resume = 0 // Calling Iterate again without Init will panic.
+			ctx.ResidualCurrent = false
+			return EndIteration, nil
+		}
+		s.resume = 5
+		ctx.ResidualCurrent = false
+		return EndIteration, nil
+
+	default:
+		panic("SYMMLQ: Init not called")
+	}
+}
+
+// nextBeta computes the next off-diagonal element of the Lanczos
+// tridiagonal matrix from r2 and the preconditioned y.
+func (s *SYMMLQ) nextBeta(ctx *Context) error {
+	beta := ctx.vec.dot(s.r2, s.y)
+	if beta < 0 {
+		s.resume = 0 // Calling Iterate again without Init will panic.
+		return &NotPositiveDefiniteError{Method: "SYMMLQ", Preconditioner: true}
+	}
+	s.beta = math.Sqrt(beta)
+	return nil
+}
+
+// checkResidualNorm estimates the residual norms of the SYMMLQ and CG
+// iterates and commands CheckResidualNorm with the smaller one.
+func (s *SYMMLQ) checkResidualNorm(ctx *Context) (Operation, error) {
+	diag := s.gbar
+	if diag == 0 {
+		diag = eps * math.Sqrt(s.tnorm)
+	}
+	lqnorm := math.Hypot(s.rhs1, s.rhs2)
+	cgnorm := s.snprod * s.beta1 * s.beta / math.Abs(diag)
+	s.cg = cgnorm <= lqnorm
+	s.zbar = s.rhs1 / diag
+	ctx.ResidualNorm = math.Min(lqnorm, cgnorm)
+	ctx.Src = nil
+	ctx.Dst = nil
+	ctx.Converged = false
+	s.resume = 8
+	return CheckResidualNorm, nil
+}
@@ -0,0 +1,142 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+func TestSYMMLQ(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(1, rnd),
+		problem.RandomSPD(2, rnd),
+		problem.RandomSPD(5, rnd),
+		problem.RandomSPD(20, rnd),
+		problem.RandomSPD(100, rnd),
+		problem.RandomSPD(500, rnd),
+		problem.Poisson2D(20, 20),
+		market("nos4", 1e-12),
+		market("nos5", 1e-9),
+		market("bcsstm22", 1e-11),
+	} {
+		// On symmetric positive definite systems
+		// SYMMLQ converges on the CG iterates.
+		settings := iterative.Settings{Tolerance: 1e-12}
+		want, err := p.Solve(&iterative.CG{}, settings)
+		if err != nil {
+			t.Errorf("CG: %v", err)
+			continue
+		}
+		got, err := p.Solve(&iterative.SYMMLQ{}, settings)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		// Rounding errors shift the convergence of
+		// both methods on ill-conditioned matrices
+		// such as nos5 by a few iterations.
+		slack := 1 + want.Stats.Iterations/100
+		if d := got.Stats.Iterations - want.Stats.Iterations; d < -slack || slack < d {
+			t.Errorf("%v: %d iterations, CG needs %d", p.Name, got.Stats.Iterations, want.Stats.Iterations)
+		}
+		if d := floats.Distance(got.X, want.X, 2); d > 1e-8*floats.Norm(want.X, 2) {
+			t.Errorf("%v: solution differs from CG by %v", p.Name, d)
+		}
+	}
+
+	for _, p := range []*problem.Problem{
+		problem.Helmholtz2D(20, 20, 10),
+		problem.Helmholtz2D(20, 20, 20),
+		problem.Helmholtz2D(30, 30, 30),
+	} {
+		// The matrices are indefinite, CG fails.
+		if _, err := p.Solve(&iterative.CG{}, iterative.Settings{Tolerance: 1e-12}); err == nil {
+			t.Errorf("%v: matrix not indefinite", p.Name)
+		}
+		p.Tolerance = 1e-8
+		res, err := p.Solve(&iterative.SYMMLQ{}, iterative.Settings{Tolerance: 1e-12})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		// The residual norm is estimated without
+		// forming the residual.
+		r := make([]float64, p.Dim)
+		p.A.MatVec(r, res.X)
+		floats.Sub(r, p.B)
+		rnorm := floats.Norm(r, 2)
+		if math.Abs(rnorm-res.Stats.ResidualNorm) > 1e-3*rnorm {
+			t.Errorf("%v: estimated residual norm %v, true %v", p.Name, res.Stats.ResidualNorm, rnorm)
+		}
+	}
+}
+
+func TestSYMMLQPreconditioned(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		tol  float64
+	}{
+		{"nos4", 1e-8},
+		{"nos5", 1e-6},
+		{"bcsstm22", 1e-8},
+	} {
+		// SYMMLQ measures the residual in the
+		// M^{-1}-norm, so it does not stop after
+		// the same iteration as CG, but both
+		// reach the accuracy of the problem.
+		p := market(test.name, test.tol)
+		d := sparse.Diagonal(marketCSR(test.name))
+		settings := iterative.Settings{
+			Tolerance: 1e-12,
+			PSolve:    iterative.DiagonalInverse(d).Apply,
+		}
+		if _, err := p.Solve(&iterative.SYMMLQ{}, settings); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSYMMLQBreakdown(t *testing.T) {
+	p := problem.Poisson2D(10, 10)
+	for _, test := range []struct {
+		name   string
+		psolve func(dst, rhs []float64) error
+		want   error
+	}{
+		{
+			name: "negative definite",
+			psolve: func(dst, rhs []float64) error {
+				floats.ScaleTo(dst, -1, rhs)
+				return nil
+			},
+			want: &iterative.NotPositiveDefiniteError{Method: "SYMMLQ", Preconditioner: true},
+		},
+		{
+			name: "zero",
+			psolve: func(dst, rhs []float64) error {
+				for i := range dst {
+					dst[i] = 0
+				}
+				return nil
+			},
+			want: &iterative.BreakdownError{Method: "SYMMLQ", Quantity: "beta"},
+		},
+	} {
+		_, err := iterative.LinearSolve(p.A, p.B, &iterative.SYMMLQ{}, iterative.Settings{PSolve: test.psolve})
+		if !reflect.DeepEqual(err, test.want) {
+			t.Errorf("%v: unexpected error %v, want %v", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+// ConvectionDiffusion2D returns the discretization of the convection-
+// diffusion operator
+//  -Δu + w·∇u
+// with a constant wind w on an nx×ny grid. The diffusion is discretized by
+// the 5-point stencil and the convection by first-order upwind differences.
+// The matrix is scaled by the square of the grid spacing h. px and py are
+// the mesh Péclet numbers w_x h/2 and w_y h/2, so the off-diagonal
+// elements on the upwind side are -1-2|p| and the diagonal is 4+2|px|+2|py|.
+// The matrix is nonsymmetric for nonzero px or py but it remains an
+// M-matrix for any wind, which makes upwinding robust but diffusive.
+func ConvectionDiffusion2D(nx, ny int, px, py float64) *Problem {
+	p := [2]float64{px, py}
+	diag := 4.0
+	lower := [3]float64{-1, -1, -1}
+	upper := [3]float64{-1, -1, -1}
+	for d, v := range p {
+		if v > 0 {
+			lower[d] -= 2 * v
+			diag += 2 * v
+		} else {
+			upper[d] += 2 * v
+			diag -= 2 * v
+		}
+	}
+	return convectionDiffusion(nx, ny, px, py, diag, lower, upper)
+}
+
+// CentralConvectionDiffusion2D returns the discretization of the same
+// operator as ConvectionDiffusion2D with the convection discretized by
+// second-order central differences. The off-diagonal elements are -1-p
+// and -1+p on the upwind and the downwind side, respectively, and the
+// diagonal is 4. For mesh Péclet numbers above 1 the matrix is no longer
+// an M-matrix and it becomes highly nonnormal, which is the hard regime
+// for nonsymmetric iterative methods.
+func CentralConvectionDiffusion2D(nx, ny int, px, py float64) *Problem {
+	lower := [3]float64{-1 - px, -1 - py, -1}
+	upper := [3]float64{-1 + px, -1 + py, -1}
+	return convectionDiffusion(nx, ny, px, py, 4, lower, upper)
+}
+
+func convectionDiffusion(nx, ny int, px, py, diag float64, lower, upper [3]float64) *Problem {
+	p := newProblem([]int{nx, ny}, diag, lower, upper)
+	if px == 0 && py == 0 {
+		p.setEigenvalues([]float64{1, 1}, 0)
+	}
+	return p
+}
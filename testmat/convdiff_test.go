@@ -0,0 +1,125 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"fmt"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// denseTrans returns the transpose of the matrix of p assembled by
+// applying MatTransVec to the columns of the identity.
+func denseTrans(p *Problem) *mat.Dense {
+	n := p.Dim
+	a := mat.NewDense(n, n, nil)
+	e := make([]float64, n)
+	col := make([]float64, n)
+	for j := 0; j < n; j++ {
+		e[j] = 1
+		p.Ops.MatTransVec(col, e)
+		a.SetCol(j, col)
+		e[j] = 0
+	}
+	return a
+}
+
+func TestConvectionDiffusion2D(t *testing.T) {
+	const nx, ny = 4, 3
+	for _, test := range []struct {
+		central bool
+		px, py  float64
+		// Coefficients of the west, east,
+		// south and north neighbors and the
+		// diagonal.
+		w, e, s, n, c float64
+	}{
+		{false, 0, 0, -1, -1, -1, -1, 4},
+		{false, 0.5, 0, -2, -1, -1, -1, 5},
+		{false, -0.5, 2, -1, -2, -5, -1, 9},
+		{true, 0, 0, -1, -1, -1, -1, 4},
+		{true, 0.5, -3, -1.5, -0.5, 2, -4, 4},
+	} {
+		name := fmt.Sprintf("central=%v,px=%v,py=%v", test.central, test.px, test.py)
+		var p *Problem
+		if test.central {
+			p = CentralConvectionDiffusion2D(nx, ny, test.px, test.py)
+		} else {
+			p = ConvectionDiffusion2D(nx, ny, test.px, test.py)
+		}
+		want := mat.NewDense(p.Dim, p.Dim, nil)
+		for j := 0; j < ny; j++ {
+			for i := 0; i < nx; i++ {
+				k := i + nx*j
+				want.Set(k, k, test.c)
+				if i > 0 {
+					want.Set(k, k-1, test.w)
+				}
+				if i < nx-1 {
+					want.Set(k, k+1, test.e)
+				}
+				if j > 0 {
+					want.Set(k, k-nx, test.s)
+				}
+				if j < ny-1 {
+					want.Set(k, k+nx, test.n)
+				}
+			}
+		}
+		if !mat.Equal(dense(p), want) {
+			t.Errorf("%v: unexpected matrix\n%v", name, mat.Formatted(dense(p)))
+		}
+		if !mat.Equal(denseTrans(p), want.T()) {
+			t.Errorf("%v: unexpected transpose", name)
+		}
+		if !mat.Equal(toDense(p), want) {
+			t.Errorf("%v: unexpected CSR matrix", name)
+		}
+		if test.px == 0 && test.py == 0 {
+			q := Poisson2D(nx, ny)
+			if p.MinEig != q.MinEig || p.MaxEig != q.MaxEig {
+				t.Errorf("%v: eigenvalues differ from Poisson2D", name)
+			}
+		} else if p.MinEig != 0 || p.MaxEig != 0 {
+			t.Errorf("%v: eigenvalues set for a nonsymmetric problem", name)
+		}
+	}
+}
+
+func TestProblemCSR(t *testing.T) {
+	for _, p := range []*Problem{
+		Poisson2D(5, 3),
+		Poisson3D(2, 3, 4),
+		ConvectionDiffusion2D(3, 5, 1, -2),
+	} {
+		if !mat.Equal(toDense(p), dense(p)) {
+			t.Errorf("%v: CSR matrix differs from the operator", p.Grid())
+		}
+		if got := p.CSR().NNZ(); got != nnz(p) {
+			t.Errorf("%v: unexpected number of stored elements %v", p.Grid(), got)
+		}
+	}
+}
+
+func toDense(p *Problem) *mat.Dense {
+	var a mat.Dense
+	p.CSR().ToDense(&a)
+	return &a
+}
+
+// nnz returns the number of nonzero elements of the matrix of p.
+func nnz(p *Problem) int {
+	a := dense(p)
+	var n int
+	for i := 0; i < p.Dim; i++ {
+		for j := 0; j < p.Dim; j++ {
+			if a.At(i, j) != 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,24 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+// Helmholtz2D returns the 5-point discretization of the Helmholtz operator
+//  -Δu - k²u
+// on an nx×ny grid with the grid spacings hx = 1/(nx+1) and hy = 1/(ny+1).
+// Unlike Poisson2D the matrix is not scaled, so that k is the wavenumber
+// of the continuous problem. The matrix is symmetric with the eigenvalues
+//  4 sin^2(iπ/(2(nx+1)))/hx² + 4 sin^2(jπ/(2(ny+1)))/hy² - k²,
+// which approximate π²(i²+j²) - k² for small i and j. It is indefinite
+// when k² lies between its smallest and largest eigenvalue of the
+// Laplacian and singular when k² is equal to one of them. The eigenvalues
+// are returned by the Eigenvalues method.
+func Helmholtz2D(nx, ny int, k float64) *Problem {
+	hx2 := float64((nx + 1) * (nx + 1))
+	hy2 := float64((ny + 1) * (ny + 1))
+	lower := [3]float64{-hx2, -hy2, 0}
+	p := newProblem([]int{nx, ny}, 2*hx2+2*hy2-k*k, lower, lower)
+	p.setEigenvalues([]float64{hx2, hy2}, -k*k)
+	return p
+}
@@ -0,0 +1,81 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestHelmholtz2D(t *testing.T) {
+	for _, test := range []struct {
+		nx, ny int
+		k      float64
+	}{
+		{1, 1, 0},
+		{3, 4, 0},
+		{4, 3, 5},
+		{6, 5, 10},
+		{5, 5, 30},
+	} {
+		p := Helmholtz2D(test.nx, test.ny, test.k)
+		a := dense(p)
+		hx2 := float64((test.nx + 1) * (test.nx + 1))
+		hy2 := float64((test.ny + 1) * (test.ny + 1))
+		for k := 0; k < p.Dim; k++ {
+			for l := 0; l < p.Dim; l++ {
+				var want float64
+				switch {
+				case k == l:
+					want = 2*hx2 + 2*hy2 - test.k*test.k
+				case (l == k-1 || l == k+1) && k/test.nx == l/test.nx:
+					want = -hx2
+				case l == k-test.nx || l == k+test.nx:
+					want = -hy2
+				}
+				if a.At(k, l) != want {
+					t.Errorf("%v×%v,k=%v: unexpected element at (%v,%v): want %v, got %v",
+						test.nx, test.ny, test.k, k, l, want, a.At(k, l))
+				}
+			}
+		}
+		checkEigenvalues(t, p)
+	}
+}
+
+func TestEigenvalues(t *testing.T) {
+	for _, p := range []*Problem{
+		Poisson2D(4, 3),
+		Poisson3D(2, 3, 4),
+		ConvectionDiffusion2D(3, 3, 0, 0),
+	} {
+		checkEigenvalues(t, p)
+	}
+	if ConvectionDiffusion2D(3, 3, 1, 0).Eigenvalues() != nil {
+		t.Errorf("eigenvalues returned for a nonsymmetric problem")
+	}
+}
+
+// checkEigenvalues compares the eigenvalues of p with those of its
+// assembled matrix.
+func checkEigenvalues(t *testing.T, p *Problem) {
+	t.Helper()
+	var eig mat.EigenSym
+	if !eig.Factorize(mat.NewSymDense(p.Dim, dense(p).RawMatrix().Data), false) {
+		t.Fatalf("%v: eigendecomposition failed", p.Grid())
+	}
+	want := eig.Values(nil)
+	got := p.Eigenvalues()
+	tol := 1e-12 * math.Max(1, math.Abs(want[len(want)-1]))
+	if !floats.EqualApprox(got, want, tol) {
+		t.Errorf("%v: unexpected eigenvalues\nwant %v\ngot  %v", p.Grid(), want, got)
+	}
+	if got[0] != p.MinEig || got[len(got)-1] != p.MaxEig {
+		t.Errorf("%v: extreme eigenvalues do not match MinEig and MaxEig", p.Grid())
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package testmat provides scalable test problems for iterative methods
+// whose matrices are applied without being stored.
+package testmat
+
+import (
+	"math"
+	"sort"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// Problem is a linear system arising from the discretization of a partial
+// differential equation on a regular grid of interior points of the unit
+// square or cube with homogeneous Dirichlet boundary conditions. The
+// unknowns are ordered with the x index varying fastest.
+type Problem struct {
+	// Dim is the dimension of the system.
+	Dim int
+	// Ops applies the matrix without
+	// storing it.
+	Ops iterative.MatrixOps
+
+	// MinEig and MaxEig are the smallest
+	// and largest eigenvalues of the
+	// matrix. They are set only if the
+	// eigenvalues are known, see
+	// Eigenvalues.
+	MinEig, MaxEig float64
+
+	grid []int
+
+	// Coefficients of the stencil, the same
+	// at all grid points.
+	diag         float64
+	lower, upper [3]float64
+
+	// The eigenvalues are shift plus the
+	// sum over dimensions of scale[d] times
+	// the eigenvalues of the 1D Laplacian.
+	// scale is nil if the eigenvalues are
+	// not known.
+	scale []float64
+	shift float64
+}
+
+// Grid returns the number of interior grid points in each dimension.
+func (p *Problem) Grid() []int {
+	return append([]int(nil), p.grid...)
+}
+
+// Manufactured returns a solution x of the system and the corresponding
+// right-hand side b = A*x. The solution is the grid function
+//  prod_d 4 t_d (1 - t_d) (1 + t_d)
+// which is not an eigenvector of the matrix, so the methods do not
+// converge prematurely.
+func (p *Problem) Manufactured() (x, b []float64) {
+	x = make([]float64, p.Dim)
+	for k := range x {
+		v := 1.0
+		rem := k
+		for _, n := range p.grid {
+			t := float64(rem%n+1) / float64(n+1)
+			v *= 4 * t * (1 - t) * (1 + t)
+			rem /= n
+		}
+		x[k] = v
+	}
+	b = make([]float64, p.Dim)
+	p.Ops.MatVec(b, x)
+	return x, b
+}
+
+// Poisson2D returns the 5-point discretization of the negative Laplacian
+// on an nx×ny grid. The matrix is scaled by the square of the grid spacing,
+// so it has 4 on the diagonal and -1 for each neighbor. It is symmetric
+// positive definite with the eigenvalues
+//  4 sin^2(iπ/(2(nx+1))) + 4 sin^2(jπ/(2(ny+1))),  1 <= i <= nx, 1 <= j <= ny.
+func Poisson2D(nx, ny int) *Problem {
+	return poisson(nx, ny)
+}
+
+// Poisson3D returns the 7-point discretization of the negative Laplacian
+// on an nx×ny×nz grid scaled as in Poisson2D. It has 6 on the diagonal and
+// -1 for each neighbor, and its eigenvalues are the sums of the three
+// one-dimensional terms.
+func Poisson3D(nx, ny, nz int) *Problem {
+	return poisson(nx, ny, nz)
+}
+
+func poisson(grid ...int) *Problem {
+	off := [3]float64{-1, -1, -1}
+	p := newProblem(grid, 2*float64(len(grid)), off, off)
+	scale := make([]float64, len(grid))
+	for d := range scale {
+		scale[d] = 1
+	}
+	p.setEigenvalues(scale, 0)
+	return p
+}
+
+// setEigenvalues records that the eigenvalues of p are
+//  shift + sum_d scale[d] * 4 sin^2(i_d π/(2(n_d+1))),  1 <= i_d <= n_d,
+// and sets MinEig and MaxEig.
+func (p *Problem) setEigenvalues(scale []float64, shift float64) {
+	p.scale = scale
+	p.shift = shift
+	p.MinEig = shift
+	p.MaxEig = shift
+	for d, n := range p.grid {
+		lo := scale[d] * laplace1DEig(1, n)
+		hi := scale[d] * laplace1DEig(n, n)
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		p.MinEig += lo
+		p.MaxEig += hi
+	}
+}
+
+// Eigenvalues returns the eigenvalues of the matrix in increasing order if
+// they are known in closed form and real, otherwise it returns nil. The
+// eigenvalues of symmetric problems are always known.
+func (p *Problem) Eigenvalues() []float64 {
+	if p.scale == nil {
+		return nil
+	}
+	eig := []float64{p.shift}
+	for d, n := range p.grid {
+		next := make([]float64, 0, len(eig)*n)
+		for i := 1; i <= n; i++ {
+			v := p.scale[d] * laplace1DEig(i, n)
+			for _, e := range eig {
+				next = append(next, e+v)
+			}
+		}
+		eig = next
+	}
+	sort.Float64s(eig)
+	return eig
+}
+
+// newProblem returns a Problem on the given grid with the stencil given by
+// diag, lower and upper as in stencil.
+func newProblem(grid []int, diag float64, lower, upper [3]float64) *Problem {
+	dim := 1
+	for _, n := range grid {
+		if n <= 0 {
+			panic("testmat: grid size not positive")
+		}
+		dim *= n
+	}
+	p := &Problem{
+		Dim:   dim,
+		grid:  grid,
+		diag:  diag,
+		lower: lower,
+		upper: upper,
+	}
+	nx, ny, nz := p.dims()
+	p.Ops = iterative.MatrixOps{
+		MatVec: func(dst, x []float64) {
+			checkLen(dst, x, dim)
+			stencil(dst, x, nx, ny, nz, diag, lower, upper)
+		},
+		MatTransVec: func(dst, x []float64) {
+			checkLen(dst, x, dim)
+			stencil(dst, x, nx, ny, nz, diag, upper, lower)
+		},
+	}
+	return p
+}
+
+// CSR returns the matrix of the problem assembled in the CSR format.
+func (p *Problem) CSR() *sparse.CSR {
+	nx, ny, nz := p.dims()
+	stride := [3]int{1, nx, nx * ny}
+	n := [3]int{nx, ny, nz}
+	t := sparse.NewTriplet(p.Dim, p.Dim)
+	for k := 0; k < p.Dim; k++ {
+		for d := 2; d >= 0; d-- {
+			if k/stride[d]%n[d] > 0 {
+				t.Append(k, k-stride[d], p.lower[d])
+			}
+		}
+		t.Append(k, k, p.diag)
+		for d := 0; d < 3; d++ {
+			if k/stride[d]%n[d] < n[d]-1 {
+				t.Append(k, k+stride[d], p.upper[d])
+			}
+		}
+	}
+	return sparse.NewCSRFromTriplet(t)
+}
+
+// dims returns the grid sizes in the three dimensions, 1 for the missing
+// ones.
+func (p *Problem) dims() (nx, ny, nz int) {
+	n := [3]int{1, 1, 1}
+	copy(n[:], p.grid)
+	return n[0], n[1], n[2]
+}
+
+// laplace1DEig returns the i-th eigenvalue of the n×n tridiagonal matrix
+// with 2 on the diagonal and -1 off the diagonal.
+func laplace1DEig(i, n int) float64 {
+	s := math.Sin(float64(i) * math.Pi / float64(2*(n+1)))
+	return 4 * s * s
+}
+
+// stencil computes dst = A*x for the matrix A on the nx×ny×nz grid that
+// has diag on the diagonal, and lower[d] and upper[d] for the neighbors
+// in the dimension d with a smaller and a larger index, respectively.
+func stencil(dst, x []float64, nx, ny, nz int, diag float64, lower, upper [3]float64) {
+	sy := nx
+	sz := nx * ny
+	for kz := 0; kz < nz; kz++ {
+		for ky := 0; ky < ny; ky++ {
+			base := kz*sz + ky*sy
+			for kx := 0; kx < nx; kx++ {
+				k := base + kx
+				v := diag * x[k]
+				if kx > 0 {
+					v += lower[0] * x[k-1]
+				}
+				if kx < nx-1 {
+					v += upper[0] * x[k+1]
+				}
+				if ky > 0 {
+					v += lower[1] * x[k-sy]
+				}
+				if ky < ny-1 {
+					v += upper[1] * x[k+sy]
+				}
+				if kz > 0 {
+					v += lower[2] * x[k-sz]
+				}
+				if kz < nz-1 {
+					v += upper[2] * x[k+sz]
+				}
+				dst[k] = v
+			}
+		}
+	}
+}
+
+func checkLen(dst, x []float64, n int) {
+	if len(x) != n || len(dst) != n {
+		panic("testmat: dimension mismatch")
+	}
+}
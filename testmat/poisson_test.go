@@ -0,0 +1,126 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// dense returns the matrix of p assembled by applying it to the columns of
+// the identity.
+func dense(p *Problem) *mat.Dense {
+	n := p.Dim
+	a := mat.NewDense(n, n, nil)
+	e := make([]float64, n)
+	col := make([]float64, n)
+	for j := 0; j < n; j++ {
+		e[j] = 1
+		p.Ops.MatVec(col, e)
+		a.SetCol(j, col)
+		e[j] = 0
+	}
+	return a
+}
+
+// wantPoisson returns the element (k,l) of the discrete Laplacian on grid.
+func wantPoisson(grid []int, k, l int) float64 {
+	if k == l {
+		return 2 * float64(len(grid))
+	}
+	// The indices are neighbors if they differ by one in exactly one
+	// coordinate.
+	diff := 0
+	for _, n := range grid {
+		d := k%n - l%n
+		if d < -1 || 1 < d {
+			return 0
+		}
+		if d != 0 {
+			diff++
+		}
+		k /= n
+		l /= n
+	}
+	if diff == 1 {
+		return -1
+	}
+	return 0
+}
+
+func TestPoisson(t *testing.T) {
+	for _, grid := range [][]int{
+		{1, 1},
+		{1, 5},
+		{3, 4},
+		{5, 2},
+		{1, 1, 1},
+		{2, 3, 4},
+		{4, 3, 1},
+		{3, 3, 3},
+	} {
+		var p *Problem
+		if len(grid) == 2 {
+			p = Poisson2D(grid[0], grid[1])
+		} else {
+			p = Poisson3D(grid[0], grid[1], grid[2])
+		}
+		n := 1
+		for _, v := range grid {
+			n *= v
+		}
+		if p.Dim != n || !reflect.DeepEqual(p.Grid(), grid) {
+			t.Errorf("%v: unexpected dimension %v", grid, p.Dim)
+			continue
+		}
+		a := dense(p)
+		for k := 0; k < p.Dim; k++ {
+			for l := 0; l < p.Dim; l++ {
+				if a.At(k, l) != wantPoisson(grid, k, l) {
+					t.Errorf("%v: unexpected element at (%v,%v): want %v, got %v", grid, k, l, wantPoisson(grid, k, l), a.At(k, l))
+				}
+			}
+		}
+
+		var eig mat.EigenSym
+		if !eig.Factorize(mat.NewSymDense(p.Dim, a.RawMatrix().Data), false) {
+			t.Fatalf("%v: eigendecomposition failed", grid)
+		}
+		vals := eig.Values(nil)
+		if math.Abs(vals[0]-p.MinEig) > 1e-12 || math.Abs(vals[len(vals)-1]-p.MaxEig) > 1e-12 {
+			t.Errorf("%v: unexpected extreme eigenvalues, want %v and %v, got %v and %v",
+				grid, vals[0], vals[len(vals)-1], p.MinEig, p.MaxEig)
+		}
+
+		x, b := p.Manufactured()
+		want := make([]float64, p.Dim)
+		p.Ops.MatVec(want, x)
+		if !floats.Equal(b, want) {
+			t.Errorf("%v: right-hand side does not match the solution", grid)
+		}
+		for _, v := range x {
+			if v <= 0 || v >= 4 {
+				t.Errorf("%v: solution element %v out of range", grid, v)
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkPoisson3DMatVec(b *testing.B) {
+	p := Poisson3D(100, 100, 100)
+	x, dst := make([]float64, p.Dim), make([]float64, p.Dim)
+	for i := range x {
+		x[i] = 1
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Ops.MatVec(dst, x)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package problem_test
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+	"gonum.org/v1/gonum/floats"
+)
+
+// MinimalResidual implements the minimal residual iteration
+//  x_{i+1} = x_i + α_i r_i,  α_i = (r_i·Ar_i) / (Ar_i·Ar_i),
+// which converges for matrices with a positive definite symmetric part. It
+// stands for a Method implemented outside of package iterative.
+type MinimalResidual struct {
+	resume int
+	ar     []float64
+}
+
+func (m *MinimalResidual) Init(dim int) {
+	m.ar = make([]float64, dim)
+	m.resume = 1
+}
+
+func (m *MinimalResidual) Iterate(ctx *iterative.Context) (iterative.Operation, error) {
+	switch m.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = m.ar
+		m.resume = 2
+		return iterative.MatVec, nil
+	case 2:
+		alpha := floats.Dot(ctx.Residual, m.ar) / floats.Dot(m.ar, m.ar)
+		floats.AddScaled(ctx.X, alpha, ctx.Residual)
+		floats.AddScaled(ctx.Residual, -alpha, m.ar)
+		ctx.ResidualNorm = floats.Norm(ctx.Residual, 2)
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		m.resume = 3
+		return iterative.CheckResidualNorm, nil
+	case 3:
+		m.resume = 1
+		if ctx.Converged {
+			m.resume = 0
+		}
+		return iterative.EndIteration, nil
+	default:
+		panic("MinimalResidual: Init not called")
+	}
+}
+
+func Example() {
+	// Validate MinimalResidual against a few problems of
+	// the suite. The iteration converges slowly, so the
+	// iteration budget of the problems is increased.
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(20, rand.New(rand.NewSource(1))),
+		problem.Poisson2D(8, 8),
+		problem.ConvectionDiffusion2D(8, 8, 1, 0.5),
+	} {
+		_, err := p.Solve(&MinimalResidual{}, iterative.Settings{
+			Tolerance:     1e-12,
+			MaxIterations: 100 * p.MaxIterations,
+		})
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		fmt.Printf("%s: ok\n", p.Name)
+	}
+
+	// Output:
+	// RandomSPD(20): ok
+	// Poisson2D(8x8): ok
+	// ConvectionDiffusion2D(8x8,1,0.5): ok
+}
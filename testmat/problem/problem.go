@@ -0,0 +1,208 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package problem provides complete linear systems with known solutions for
+// testing iterative methods and preconditioners. It bundles the generators
+// of package testmat and matrices read from Matrix Market files with a
+// right-hand side, the exact solution, a tolerance on the error of the
+// computed solution and an iteration budget.
+package problem
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/testmat"
+	"gonum.org/v1/gonum/blas"
+)
+
+// Problem is a linear system
+//  A x = b
+// with a known solution.
+type Problem struct {
+	// Name identifies the problem in
+	// test output.
+	Name string
+
+	// Dim is the dimension of the system.
+	Dim int
+	// A is the matrix of the system.
+	A iterative.MatrixOps
+	// B is the right-hand side.
+	B []float64
+	// X is the exact solution.
+	X []float64
+
+	// Tolerance is the recommended bound on
+	// the maximum norm of the error of the
+	// computed solution. It can be changed
+	// to match the accuracy expected from
+	// a particular method.
+	Tolerance float64
+
+	// MaxIterations is the recommended
+	// iteration budget.
+	MaxIterations int
+}
+
+// Solve solves the problem by LinearSolve with the given method and
+// settings and checks the computed solution with Check. If
+// settings.MaxIterations is zero, p.MaxIterations is used.
+func (p *Problem) Solve(method iterative.Method, settings iterative.Settings) (iterative.Result, error) {
+	if settings.MaxIterations == 0 {
+		settings.MaxIterations = p.MaxIterations
+	}
+	res, err := iterative.LinearSolve(p.A, p.B, method, settings)
+	if err != nil {
+		return res, fmt.Errorf("problem: %s: %v", p.Name, err)
+	}
+	return res, p.Check(res.X)
+}
+
+// Check returns an error if the maximum norm of the difference between x
+// and the exact solution exceeds p.Tolerance.
+func (p *Problem) Check(x []float64) error {
+	if len(x) != p.Dim {
+		return fmt.Errorf("problem: %s: solution has length %d, want %d", p.Name, len(x), p.Dim)
+	}
+	var d float64
+	for i, v := range x {
+		e := math.Abs(v - p.X[i])
+		if e > d || math.IsNaN(e) {
+			d = e
+		}
+	}
+	if !(d <= p.Tolerance) {
+		return fmt.Errorf("problem: %s: solution error %v exceeds %v", p.Name, d, p.Tolerance)
+	}
+	return nil
+}
+
+// New returns the problem with the matrix a whose solution is x. The
+// right-hand side is computed from x, the tolerance is 1e-8 and the
+// iteration budget is ten times the dimension.
+func New(name string, a iterative.MatrixOps, x []float64) *Problem {
+	n := len(x)
+	b := make([]float64, n)
+	a.MatVec(b, x)
+	return &Problem{
+		Name:          name,
+		Dim:           n,
+		A:             a,
+		B:             b,
+		X:             x,
+		Tolerance:     1e-8,
+		MaxIterations: 10 * n,
+	}
+}
+
+// FromMatrixMarket returns the problem with the matrix read from the
+// Matrix Market file at path, which can be compressed with gzip if its
+// name ends in ".gz". The solution is the vector of all ones. The problem
+// is named after the file, the tolerance is 1e-8 and the iteration budget
+// is ten times the dimension.
+func FromMatrixMarket(path string) (*Problem, error) {
+	m, err := mmarket.LoadMatrix(path)
+	if err != nil {
+		return nil, err
+	}
+	r, c := m.Dims()
+	if r != c {
+		return nil, fmt.Errorf("problem: %s: matrix is not square", path)
+	}
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".mtx")
+	return New(name, iterative.NewMatrixOps(m), ones(r)), nil
+}
+
+// RandomSPD returns a problem with a dense random symmetric positive
+// definite matrix of order n whose diagonal elements dominate. The
+// solution is the vector of all ones.
+func RandomSPD(n int, rnd *rand.Rand) *Problem {
+	a := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			a[i*n+j] = rnd.Float64()
+		}
+	}
+	for i := 0; i < n; i++ {
+		a[i*n+i] += float64(n)
+	}
+	p := New(fmt.Sprintf("RandomSPD(%d)", n), iterative.SymDenseOps(a, n, n, blas.Upper), ones(n))
+	p.Tolerance = 1e-10
+	p.MaxIterations = 2 * n
+	return p
+}
+
+// RandomSparse returns a problem with the matrix generated by
+// testmat.RandomSparse. The solution is the vector of all ones.
+func RandomSparse(n, nnzPerRow int, cond, nonnormality float64, rnd *rand.Rand) *Problem {
+	a := testmat.RandomSparse(n, nnzPerRow, cond, nonnormality, rnd)
+	name := fmt.Sprintf("RandomSparse(%d,%d,%g,%g)", n, nnzPerRow, cond, nonnormality)
+	return New(name, iterative.NewMatrixOps(a), ones(n))
+}
+
+// Poisson2D returns the problem with the matrix testmat.Poisson2D and the
+// manufactured solution of testmat.Problem.
+func Poisson2D(nx, ny int) *Problem {
+	return fromTestmat(fmt.Sprintf("Poisson2D(%dx%d)", nx, ny), testmat.Poisson2D(nx, ny))
+}
+
+// Poisson3D returns the problem with the matrix testmat.Poisson3D and the
+// manufactured solution of testmat.Problem.
+func Poisson3D(nx, ny, nz int) *Problem {
+	return fromTestmat(fmt.Sprintf("Poisson3D(%dx%dx%d)", nx, ny, nz), testmat.Poisson3D(nx, ny, nz))
+}
+
+// ConvectionDiffusion2D returns the problem with the matrix
+// testmat.ConvectionDiffusion2D and the manufactured solution of
+// testmat.Problem.
+func ConvectionDiffusion2D(nx, ny int, px, py float64) *Problem {
+	name := fmt.Sprintf("ConvectionDiffusion2D(%dx%d,%g,%g)", nx, ny, px, py)
+	return fromTestmat(name, testmat.ConvectionDiffusion2D(nx, ny, px, py))
+}
+
+// CentralConvectionDiffusion2D returns the problem with the matrix
+// testmat.CentralConvectionDiffusion2D and the manufactured solution of
+// testmat.Problem.
+func CentralConvectionDiffusion2D(nx, ny int, px, py float64) *Problem {
+	name := fmt.Sprintf("CentralConvectionDiffusion2D(%dx%d,%g,%g)", nx, ny, px, py)
+	return fromTestmat(name, testmat.CentralConvectionDiffusion2D(nx, ny, px, py))
+}
+
+// Helmholtz2D returns the problem with the matrix testmat.Helmholtz2D and
+// the manufactured solution of testmat.Problem.
+func Helmholtz2D(nx, ny int, k float64) *Problem {
+	return fromTestmat(fmt.Sprintf("Helmholtz2D(%dx%d,%g)", nx, ny, k), testmat.Helmholtz2D(nx, ny, k))
+}
+
+// fromTestmat returns the problem with the matrix of p and its manufactured
+// solution.
+func fromTestmat(name string, p *testmat.Problem) *Problem {
+	x, b := p.Manufactured()
+	return &Problem{
+		Name:          name,
+		Dim:           p.Dim,
+		A:             p.Ops,
+		B:             b,
+		X:             x,
+		Tolerance:     1e-6,
+		MaxIterations: 10 * p.Dim,
+	}
+}
+
+// ones returns the vector of length n with all elements equal to one.
+func ones(n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = 1
+	}
+	return x
+}
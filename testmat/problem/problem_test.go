@@ -0,0 +1,75 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package problem
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestGenerators(t *testing.T) {
+	nos4, err := FromMatrixMarket("../../testdata/nos4.mtx.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nos4.Name != "nos4" || nos4.Dim != 100 {
+		t.Errorf("unexpected nos4 problem %v with dimension %v", nos4.Name, nos4.Dim)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for _, p := range []*Problem{
+		nos4,
+		RandomSPD(30, rnd),
+		RandomSparse(100, 5, 10, 0.5, rnd),
+		Poisson2D(10, 7),
+		Poisson3D(5, 4, 3),
+		ConvectionDiffusion2D(10, 10, 2, -1),
+		CentralConvectionDiffusion2D(10, 10, 0.5, 0.5),
+		Helmholtz2D(10, 10, 1),
+	} {
+		if len(p.B) != p.Dim || len(p.X) != p.Dim {
+			t.Errorf("%v: unexpected lengths of b and x", p.Name)
+			continue
+		}
+		ax := make([]float64, p.Dim)
+		p.A.MatVec(ax, p.X)
+		if !floats.EqualApprox(ax, p.B, 1e-14) {
+			t.Errorf("%v: b != A*x", p.Name)
+		}
+		if p.Tolerance <= 0 || p.MaxIterations < p.Dim {
+			t.Errorf("%v: unexpected tolerance %v or iteration budget %v", p.Name, p.Tolerance, p.MaxIterations)
+		}
+		_, err := p.Solve(&iterative.GMRES{}, iterative.Settings{Tolerance: 1e-12})
+		if err != nil {
+			t.Errorf("%v: %v", p.Name, err)
+		}
+	}
+
+	if _, err := FromMatrixMarket("../../testdata/missing.mtx.gz"); err == nil {
+		t.Errorf("expected error for a missing file")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := Poisson2D(3, 3)
+	x := append([]float64(nil), p.X...)
+	if err := p.Check(x); err != nil {
+		t.Errorf("unexpected error for the exact solution: %v", err)
+	}
+	x[4] += 2 * p.Tolerance
+	if err := p.Check(x); err == nil {
+		t.Errorf("expected error for an inaccurate solution")
+	}
+	x[4] = math.NaN()
+	if err := p.Check(x); err == nil {
+		t.Errorf("expected error for NaN in the solution")
+	}
+	if err := p.Check(x[:3]); err == nil {
+		t.Errorf("expected error for a short solution")
+	}
+}
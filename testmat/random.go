@@ -0,0 +1,106 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/vladimir-ch/iterative/sparse"
+)
+
+// RandomSparse returns a random n×n sparse matrix with about nnzPerRow
+// elements in each row, the 2-norm condition number between cond/3 and
+// 3*cond, and the departure from normality controlled by nonnormality.
+//
+// The matrix is constructed as
+//  A = D^{1/2} (I + G) D^{1/2},
+// where D is a diagonal matrix with the elements spaced geometrically
+// between 1 and cond in random order, and G is a random sparse matrix
+// scaled so that its row and column sums of absolute values are at most
+// 1/2. For each element g[i,j] above the diagonal, g[j,i] is set to
+// (1-nonnormality)*g[i,j]. Thus A is symmetric positive definite if
+// nonnormality is 0, and its off-diagonal part is strictly upper
+// triangular if nonnormality is 1, in which case the lower triangle holds
+// no elements and the rows have about half of the off-diagonal elements.
+// For any nonnormality the symmetric part of A is positive definite.
+//
+// For n == 1 the matrix is the identity. The matrix depends only on the
+// arguments and the state of rnd.
+func RandomSparse(n, nnzPerRow int, cond, nonnormality float64, rnd *rand.Rand) *sparse.CSR {
+	if n < 0 {
+		panic("testmat: negative dimension")
+	}
+	if nnzPerRow < 1 {
+		panic("testmat: number of elements per row less than one")
+	}
+	if cond < 1 {
+		panic("testmat: condition number less than one")
+	}
+	if nonnormality < 0 || 1 < nonnormality {
+		panic("testmat: nonnormality out of range [0,1]")
+	}
+
+	d := make([]float64, n)
+	for k, i := range rnd.Perm(n) {
+		d[i] = 1
+		if n > 1 {
+			d[i] = math.Pow(cond, float64(k)/float64(n-1))
+		}
+	}
+
+	// Draw the off-diagonal elements of G in pairs symmetric about the
+	// diagonal.
+	type pair struct {
+		i, j int
+		v    float64
+	}
+	var pairs []pair
+	if n > 1 {
+		pairs = make([]pair, n*(nnzPerRow-1)/2)
+	}
+	for k := range pairs {
+		i := rnd.Intn(n)
+		j := rnd.Intn(n - 1)
+		if j >= i {
+			j++
+		}
+		if i > j {
+			i, j = j, i
+		}
+		pairs[k] = pair{i, j, rnd.NormFloat64()}
+	}
+	lower := 1 - nonnormality
+	rowSum := make([]float64, n)
+	colSum := make([]float64, n)
+	for _, p := range pairs {
+		v := math.Abs(p.v)
+		rowSum[p.i] += v
+		colSum[p.j] += v
+		rowSum[p.j] += lower * v
+		colSum[p.i] += lower * v
+	}
+	var max float64
+	for i := range rowSum {
+		max = math.Max(max, math.Max(rowSum[i], colSum[i]))
+	}
+	scale := 0.0
+	if max > 0 {
+		scale = 0.5 / max
+	}
+
+	t := sparse.NewTriplet(n, n)
+	for i, v := range d {
+		t.Append(i, i, v)
+	}
+	for _, p := range pairs {
+		v := scale * p.v * math.Sqrt(d[p.i]*d[p.j])
+		t.Append(p.i, p.j, v)
+		if lower != 0 {
+			t.Append(p.j, p.i, lower*v)
+		}
+	}
+	return sparse.NewCSRFromTriplet(t)
+}
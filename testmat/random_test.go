@@ -0,0 +1,82 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestRandomSparse(t *testing.T) {
+	for _, test := range []struct {
+		n, nnz       int
+		cond, normal float64
+	}{
+		{1, 1, 1, 0},
+		{1, 5, 1, 1},
+		{2, 3, 10, 0.5},
+		{50, 1, 100, 0},
+		{50, 5, 1, 0},
+		{50, 5, 100, 0},
+		{60, 7, 1e4, 0.5},
+		{80, 9, 1e3, 1},
+	} {
+		name := fmt.Sprintf("n=%v,nnz=%v,cond=%v,nonnormality=%v", test.n, test.nnz, test.cond, test.normal)
+		a := RandomSparse(test.n, test.nnz, test.cond, test.normal, rand.New(rand.NewSource(1)))
+		b := RandomSparse(test.n, test.nnz, test.cond, test.normal, rand.New(rand.NewSource(1)))
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%v: matrix not reproducible", name)
+		}
+		if r, c := a.Dims(); r != test.n || c != test.n {
+			t.Errorf("%v: unexpected dimensions %v×%v", name, r, c)
+			continue
+		}
+		if test.n >= 50 {
+			perRow := float64(a.NNZ()) / float64(test.n)
+			want := float64(test.nnz)
+			if test.normal == 1 {
+				want = 1 + (want-1)/2
+			}
+			if perRow > want || perRow < 0.7*want {
+				t.Errorf("%v: %v elements per row, want about %v", name, perRow, want)
+			}
+		}
+
+		var dense mat.Dense
+		a.ToDense(&dense)
+		var svd mat.SVD
+		if !svd.Factorize(&dense, mat.SVDNone) {
+			t.Fatalf("%v: SVD failed", name)
+		}
+		if c := svd.Cond(); c < test.cond/3 || 3*test.cond < c {
+			t.Errorf("%v: condition number %v out of range", name, c)
+		}
+
+		// The symmetric part must be positive definite.
+		sym := mat.NewSymDense(test.n, nil)
+		for i := 0; i < test.n; i++ {
+			for j := i; j < test.n; j++ {
+				sym.SetSym(i, j, (dense.At(i, j)+dense.At(j, i))/2)
+			}
+		}
+		var chol mat.Cholesky
+		if !chol.Factorize(sym) {
+			t.Errorf("%v: symmetric part not positive definite", name)
+		}
+
+		for i := 0; i < test.n; i++ {
+			for j := 0; j < i; j++ {
+				aij, aji := dense.At(i, j), dense.At(j, i)
+				if want := (1 - test.normal) * aji; aij != want {
+					t.Errorf("%v: unexpected element at (%v,%v), want %v, got %v", name, i, j, want, aij)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/vladimir-ch/iterative"
+)
+
+// reflectors is the number of Householder reflectors whose product forms
+// the orthogonal matrix in RandomSPDSpectrum.
+const reflectors = 4
+
+// RandomSPDSpectrum returns the n×n symmetric positive definite matrix
+//  A = Q Λ Q^T,
+// where Λ is the diagonal matrix with the eigenvalues eigs and Q is a
+// random orthogonal matrix given by a product of Householder reflectors
+// with random normal vectors. The matrix is applied without being formed,
+// in O(n) operations. RandomSPDSpectrum panics if len(eigs) != n or if an
+// eigenvalue is not positive. eigs is copied.
+func RandomSPDSpectrum(n int, eigs []float64, rnd *rand.Rand) iterative.MatrixOps {
+	if len(eigs) != n {
+		panic("testmat: length of eigenvalues not equal to dimension")
+	}
+	lambda := make([]float64, n)
+	for i, v := range eigs {
+		if !(v > 0) {
+			panic("testmat: eigenvalue not positive")
+		}
+		lambda[i] = v
+	}
+	var vs [][]float64
+	if n > 1 {
+		vs = make([][]float64, reflectors)
+		for k := range vs {
+			v := make([]float64, n)
+			var norm float64
+			for i := range v {
+				v[i] = rnd.NormFloat64()
+				norm += v[i] * v[i]
+			}
+			norm = math.Sqrt(norm)
+			for i := range v {
+				v[i] /= norm
+			}
+			vs[k] = v
+		}
+	}
+	mul := func(dst, x []float64) {
+		checkLen(dst, x, n)
+		copy(dst, x)
+		// Q^T = H_m ... H_1 is applied first, then Λ and
+		// Q = H_1 ... H_m.
+		for _, v := range vs {
+			householder(v, dst)
+		}
+		for i, l := range lambda {
+			dst[i] *= l
+		}
+		for k := len(vs) - 1; k >= 0; k-- {
+			householder(vs[k], dst)
+		}
+	}
+	return iterative.MatrixOps{
+		MatVec:      mul,
+		MatTransVec: mul,
+	}
+}
+
+// householder applies the reflector I - 2vv^T with a unit vector v to x.
+func householder(v, x []float64) {
+	var dot float64
+	for i, vi := range v {
+		dot += vi * x[i]
+	}
+	dot *= 2
+	for i, vi := range v {
+		x[i] -= dot * vi
+	}
+}
+
+// GeometricSpectrum returns n eigenvalues spaced geometrically between lo
+// and hi, so that the condition number of a matrix with these eigenvalues
+// is hi/lo. CG converges on such a spectrum at the rate predicted by
+// ChebyshevBound.
+func GeometricSpectrum(n int, lo, hi float64) []float64 {
+	checkSpectrumRange(n, lo, hi)
+	eigs := make([]float64, n)
+	for i := range eigs {
+		eigs[i] = lo
+		if n > 1 {
+			eigs[i] = lo * math.Pow(hi/lo, float64(i)/float64(n-1))
+		}
+	}
+	return eigs
+}
+
+// ClusteredSpectrum returns n eigenvalues in k clusters whose centers are
+// spaced geometrically between lo and hi. The eigenvalues of a cluster
+// with the center c are spaced evenly in [c(1-spread), c(1+spread)]. For a
+// small spread CG converges in little more than k iterations regardless
+// of the condition number.
+func ClusteredSpectrum(n, k int, lo, hi, spread float64) []float64 {
+	checkSpectrumRange(n, lo, hi)
+	if k <= 0 || n < k {
+		panic("testmat: number of clusters out of range")
+	}
+	if spread < 0 || 1 <= spread {
+		panic("testmat: cluster spread out of range [0,1)")
+	}
+	centers := GeometricSpectrum(k, lo, hi)
+	eigs := make([]float64, 0, n)
+	for c, center := range centers {
+		// Distribute the remainder over the first clusters.
+		m := n / k
+		if c < n%k {
+			m++
+		}
+		for i := 0; i < m; i++ {
+			t := 0.0
+			if m > 1 {
+				t = 2*float64(i)/float64(m-1) - 1
+			}
+			eigs = append(eigs, center*(1+spread*t))
+		}
+	}
+	return eigs
+}
+
+func checkSpectrumRange(n int, lo, hi float64) {
+	if n < 0 {
+		panic("testmat: negative dimension")
+	}
+	if !(0 < lo && lo <= hi) {
+		panic("testmat: bad spectrum range")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestRandomSPDSpectrum(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, eigs := range [][]float64{
+		{3},
+		{1, 2},
+		GeometricSpectrum(10, 1, 1e3),
+		ClusteredSpectrum(30, 3, 1, 100, 0.01),
+		{5, 1, 4, 2, 3, 3, 3},
+	} {
+		n := len(eigs)
+		name := fmt.Sprint(eigs)
+		ops := RandomSPDSpectrum(n, eigs, rnd)
+		a := mat.NewDense(n, n, nil)
+		e := make([]float64, n)
+		col := make([]float64, n)
+		for j := 0; j < n; j++ {
+			e[j] = 1
+			ops.MatVec(col, e)
+			a.SetCol(j, col)
+			e[j] = 0
+		}
+		if !mat.EqualApprox(a, a.T(), 1e-12*floats.Max(eigs)) {
+			t.Errorf("%v: matrix not symmetric", name)
+			continue
+		}
+		sym := mat.NewSymDense(n, nil)
+		for i := 0; i < n; i++ {
+			for j := i; j < n; j++ {
+				sym.SetSym(i, j, a.At(i, j))
+			}
+		}
+		var eig mat.EigenSym
+		if !eig.Factorize(sym, false) {
+			t.Fatalf("%v: eigendecomposition failed", name)
+		}
+		want := append([]float64(nil), eigs...)
+		sort.Float64s(want)
+		if got := eig.Values(nil); !floats.EqualApprox(got, want, 1e-10*want[n-1]) {
+			t.Errorf("%v: unexpected eigenvalues %v", name, got)
+		}
+		if n > 1 && a.At(0, 0) == eigs[0] {
+			t.Errorf("%v: matrix not rotated", name)
+		}
+	}
+}
+
+func TestSpectrumPresets(t *testing.T) {
+	g := GeometricSpectrum(5, 1, 16)
+	if !floats.EqualApprox(g, []float64{1, 2, 4, 8, 16}, 1e-14) {
+		t.Errorf("unexpected geometric spectrum %v", g)
+	}
+	c := ClusteredSpectrum(7, 3, 1, 100, 0.1)
+	want := []float64{0.9, 1, 1.1, 9, 11, 90, 110}
+	if !floats.EqualApprox(c, want, 1e-12) {
+		t.Errorf("unexpected clustered spectrum\nwant %v\ngot  %v", want, c)
+	}
+	if g := GeometricSpectrum(1, 2, 3); len(g) != 1 || g[0] != 2 || math.IsNaN(g[0]) {
+		t.Errorf("unexpected spectrum of length one %v", g)
+	}
+}
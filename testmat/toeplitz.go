@@ -0,0 +1,58 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import "math"
+
+// Toeplitz returns the n×n tridiagonal Toeplitz matrix with a on the
+// subdiagonal, b on the diagonal and c on the superdiagonal. If a*c >= 0,
+// the eigenvalues are real and given by
+//  b + 2 sqrt(a*c) cos(iπ/(n+1)),  1 <= i <= n,
+// and they are returned by the Eigenvalues method. The matrix is symmetric
+// if a == c, and positive definite if moreover b > 2|a|.
+func Toeplitz(n int, a, b, c float64) *Problem {
+	p := newProblem([]int{n}, b, [3]float64{a}, [3]float64{c})
+	if a*c >= 0 {
+		// Use cos(θ) = 1 - 2 sin^2(θ/2) to express the
+		// eigenvalues in terms of the 1D Laplacian.
+		s := math.Sqrt(a * c)
+		p.setEigenvalues([]float64{-s}, b+2*s)
+	}
+	return p
+}
+
+// ChebyshevBound returns the bound on the reduction of the A-norm of the
+// error after k iterations of CG, or of the error of the Chebyshev
+// iteration, for a symmetric positive definite matrix with the condition
+// number kappa,
+//  1 / T_k((κ+1)/(κ-1)) = 2 q^k / (1 + q^(2k)),  q = (sqrt(κ)-1)/(sqrt(κ)+1),
+// where T_k is the Chebyshev polynomial of degree k. It is slightly
+// sharper than the commonly quoted 2 q^k.
+func ChebyshevBound(kappa float64, k int) float64 {
+	if kappa < 1 {
+		panic("testmat: condition number less than one")
+	}
+	sk := math.Sqrt(kappa)
+	q := (sk - 1) / (sk + 1)
+	qk := math.Pow(q, float64(k))
+	return 2 * qk / (1 + qk*qk)
+}
+
+// CGIterations returns the number of iterations after which ChebyshevBound
+// guarantees that the A-norm of the error of CG has been reduced by the
+// factor tol for a matrix with the condition number kappa.
+func CGIterations(kappa, tol float64) int {
+	if kappa < 1 {
+		panic("testmat: condition number less than one")
+	}
+	if tol <= 0 || 1 <= tol {
+		panic("testmat: tolerance out of range")
+	}
+	k := 0
+	for ChebyshevBound(kappa, k) > tol {
+		k++
+	}
+	return k
+}
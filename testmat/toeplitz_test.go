@@ -0,0 +1,115 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testmat
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestToeplitz(t *testing.T) {
+	for _, test := range []struct {
+		n       int
+		a, b, c float64
+	}{
+		{1, 1, 2, 3},
+		{5, -1, 2, -1},
+		{6, 1, 4, 1},
+		{7, -0.5, 3, -2},
+		{4, 2, 1, 0.5},
+		{5, 1, 2, -1}, // Complex eigenvalues.
+	} {
+		name := fmt.Sprintf("n=%v,a=%v,b=%v,c=%v", test.n, test.a, test.b, test.c)
+		p := Toeplitz(test.n, test.a, test.b, test.c)
+		a := dense(p)
+		for i := 0; i < test.n; i++ {
+			for j := 0; j < test.n; j++ {
+				var want float64
+				switch j - i {
+				case -1:
+					want = test.a
+				case 0:
+					want = test.b
+				case 1:
+					want = test.c
+				}
+				if a.At(i, j) != want {
+					t.Errorf("%v: unexpected element at (%v,%v): want %v, got %v", name, i, j, want, a.At(i, j))
+				}
+			}
+		}
+
+		got := p.Eigenvalues()
+		if test.a*test.c < 0 {
+			if got != nil {
+				t.Errorf("%v: eigenvalues returned for complex spectrum", name)
+			}
+			continue
+		}
+		var eig mat.Eigen
+		if !eig.Factorize(a, mat.EigenNone) {
+			t.Fatalf("%v: eigendecomposition failed", name)
+		}
+		want := make([]float64, test.n)
+		for i, v := range eig.Values(nil) {
+			if math.Abs(imag(v)) > 1e-12 {
+				t.Fatalf("%v: unexpected complex eigenvalue %v", name, v)
+			}
+			want[i] = real(v)
+		}
+		floats.Argsort(want, make([]int, test.n))
+		if !floats.EqualApprox(got, want, 1e-12) {
+			t.Errorf("%v: unexpected eigenvalues\nwant %v\ngot  %v", name, want, got)
+		}
+		if got[0] != p.MinEig || got[test.n-1] != p.MaxEig {
+			t.Errorf("%v: extreme eigenvalues do not match MinEig and MaxEig", name)
+		}
+	}
+}
+
+func TestChebyshevBound(t *testing.T) {
+	for _, kappa := range []float64{1, 2, 10, 1e4} {
+		if got := ChebyshevBound(kappa, 0); got != 1 {
+			t.Errorf("κ=%v: unexpected bound %v for k=0", kappa, got)
+		}
+		sk := math.Sqrt(kappa)
+		q := (sk - 1) / (sk + 1)
+		prev := 1.0
+		for k := 1; k < 50; k++ {
+			got := ChebyshevBound(kappa, k)
+			// Compare with 1/T_k((κ+1)/(κ-1)) evaluated by the
+			// hyperbolic cosine.
+			if kappa > 1 {
+				want := 1 / math.Cosh(float64(k)*math.Acosh((kappa+1)/(kappa-1)))
+				if math.Abs(got-want) > 1e-12*want {
+					t.Errorf("κ=%v,k=%v: want %v, got %v", kappa, k, want, got)
+				}
+			}
+			if got > prev || got > 2*math.Pow(q, float64(k)) {
+				t.Errorf("κ=%v,k=%v: bound %v not decreasing or above 2q^k", kappa, k, got)
+			}
+			prev = got
+		}
+	}
+	for _, test := range []struct {
+		kappa, tol float64
+		want       int
+	}{
+		{1, 0.5, 1},
+		{100, 1e-6, 73},
+	} {
+		got := CGIterations(test.kappa, test.tol)
+		if got != test.want {
+			t.Errorf("κ=%v,tol=%v: want %v iterations, got %v", test.kappa, test.tol, test.want, got)
+		}
+		if ChebyshevBound(test.kappa, got) > test.tol || ChebyshevBound(test.kappa, got-1) <= test.tol {
+			t.Errorf("κ=%v,tol=%v: %v iterations not minimal", test.kappa, test.tol, got)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/mmarket"
+	"github.com/vladimir-ch/iterative/testmat"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+	"gonum.org/v1/gonum/floats"
+)
+
+// market returns the problem with the Matrix Market matrix name from the
+// testdata directory whose solution is the vector of all ones. If tol is
+// not zero, it replaces the tolerance of the problem.
+func market(name string, tol float64) *problem.Problem {
+	f, err := os.Open("testdata/" + name + ".mtx.gz")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		panic(err)
+	}
+	m, err := mmarket.NewReader(gz).Read()
+	if err != nil {
+		panic(err)
+	}
+	n, _ := m.Dims()
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = 1
+	}
+	p := problem.New(name, iterative.MatrixOps{
+		MatVec:      m.MulVec,
+		MatTransVec: m.MulTransVec,
+	}, x)
+	if tol != 0 {
+		p.Tolerance = tol
+	}
+	return p
+}
+
+func poissonProblems() []*testmat.Problem {
+	return []*testmat.Problem{
+		testmat.Poisson2D(10, 10),
+		testmat.Poisson2D(31, 17),
+		testmat.Poisson3D(8, 8, 8),
+		testmat.Poisson3D(12, 7, 5),
+	}
+}
+
+func TestCGPoisson(t *testing.T) {
+	const tol = 1e-10
+	for _, p := range poissonProblems() {
+		x, b := p.Manufactured()
+		res, err := iterative.LinearSolve(p.Ops, b, &iterative.CG{}, iterative.Settings{Tolerance: tol})
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", p.Grid(), err)
+			continue
+		}
+		// The classical bound on the number of CG iterations.
+		kappa := p.MaxEig / p.MinEig
+		bound := int(math.Ceil(0.5*math.Sqrt(kappa)*math.Log(2/tol))) + 1
+		if res.Stats.Iterations > bound {
+			t.Errorf("%v: too many iterations %v, bound %v", p.Grid(), res.Stats.Iterations, bound)
+		}
+		if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-7 {
+			t.Errorf("%v: solution error %v", p.Grid(), d)
+		}
+	}
+}
+
+func TestGMRESPoisson(t *testing.T) {
+	for _, p := range poissonProblems() {
+		x, b := p.Manufactured()
+		res, err := iterative.LinearSolve(p.Ops, b, &iterative.GMRES{Restart: 30}, iterative.Settings{
+			Tolerance:     1e-10,
+			MaxIterations: 10 * p.Dim,
+		})
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", p.Grid(), err)
+			continue
+		}
+		if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-7 {
+			t.Errorf("%v: solution error %v", p.Grid(), d)
+		}
+	}
+}
+
+func benchmarkPoisson(b *testing.B, p *testmat.Problem, method func() iterative.Method) {
+	_, rhs := p.Manufactured()
+	settings := iterative.Settings{
+		Tolerance:     1e-8,
+		MaxIterations: 100 * p.Dim,
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := iterative.LinearSolve(p.Ops, rhs, method(), settings)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCGPoisson2D(b *testing.B) {
+	for _, n := range []int{32, 128, 512} {
+		b.Run(fmt.Sprintf("%dx%d", n, n), func(b *testing.B) {
+			benchmarkPoisson(b, testmat.Poisson2D(n, n), func() iterative.Method { return &iterative.CG{} })
+		})
+	}
+}
+
+func BenchmarkCGPoisson3D(b *testing.B) {
+	for _, n := range []int{10, 32, 100} {
+		b.Run(fmt.Sprintf("%dx%dx%d", n, n, n), func(b *testing.B) {
+			benchmarkPoisson(b, testmat.Poisson3D(n, n, n), func() iterative.Method { return &iterative.CG{} })
+		})
+	}
+}
+
+func BenchmarkGMRESPoisson2D(b *testing.B) {
+	for _, n := range []int{32, 128} {
+		b.Run(fmt.Sprintf("%dx%d", n, n), func(b *testing.B) {
+			benchmarkPoisson(b, testmat.Poisson2D(n, n), func() iterative.Method { return &iterative.GMRES{Restart: 30} })
+		})
+	}
+}
+
+func BenchmarkGMRESPoisson3D(b *testing.B) {
+	for _, n := range []int{10, 32} {
+		b.Run(fmt.Sprintf("%dx%dx%d", n, n, n), func(b *testing.B) {
+			benchmarkPoisson(b, testmat.Poisson3D(n, n, n), func() iterative.Method { return &iterative.GMRES{Restart: 30} })
+		})
+	}
+}
+
+func TestConvectionDiffusionPeclet(t *testing.T) {
+	const n = 20
+	iters := make(map[string]int)
+	for _, test := range []struct {
+		name string
+		new  func(nx, ny int, px, py float64) *testmat.Problem
+	}{
+		{"upwind", testmat.ConvectionDiffusion2D},
+		{"central", testmat.CentralConvectionDiffusion2D},
+	} {
+		for _, pe := range []float64{0, 0.1, 0.5, 1, 2, 10} {
+			p := test.new(n, n, pe, pe/2)
+			x, b := p.Manufactured()
+			for _, method := range []struct {
+				name string
+				m    iterative.Method
+			}{
+				{"GMRES(30)", &iterative.GMRES{Restart: 30}},
+				{"BiCGSTAB", &iterative.BiCGSTAB{}},
+			} {
+				res, err := iterative.LinearSolve(p.Ops, b, method.m, iterative.Settings{
+					Tolerance:     1e-10,
+					MaxIterations: 10 * p.Dim,
+				})
+				if err != nil {
+					t.Errorf("%v Pe=%v %v: unexpected error %v", test.name, pe, method.name, err)
+					continue
+				}
+				if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-6 {
+					t.Errorf("%v Pe=%v %v: solution error %v", test.name, pe, method.name, d)
+				}
+				t.Logf("%-7s Pe=%-4v %-9s %4d iterations", test.name, pe, method.name, res.Stats.Iterations)
+				iters[fmt.Sprint(test.name, pe, method.name)] = res.Stats.Iterations
+			}
+		}
+	}
+	// Central differences at a high Péclet number make the matrix highly
+	// nonnormal and the methods converge much slower than for diffusion.
+	for _, method := range []string{"GMRES(30)", "BiCGSTAB"} {
+		if iters["central10"+method] < 2*iters["central0"+method] {
+			t.Errorf("%v: convection-dominated problem not harder than diffusion", method)
+		}
+	}
+}
+
+func TestHelmholtz(t *testing.T) {
+	const n = 15
+	eig := testmat.Helmholtz2D(n, n, 0).Eigenvalues()
+	for _, test := range []struct {
+		name string
+		k2   float64 // Square of the wavenumber.
+		spd  bool
+	}{
+		{"below the spectrum", eig[0] / 2, true},
+		{"between eigenvalues 3 and 4", (eig[2] + eig[3]) / 2, false},
+		{"near eigenvalue 10", eig[9] + 1e-3*(eig[10]-eig[9]), false},
+	} {
+		p := testmat.Helmholtz2D(n, n, math.Sqrt(test.k2))
+		x, b := p.Manufactured()
+		if spd := p.MinEig > 0; spd != test.spd {
+			t.Fatalf("%v: unexpected definiteness, smallest eigenvalue %v", test.name, p.MinEig)
+		}
+
+		res, err := iterative.LinearSolve(p.Ops, b, &iterative.GMRES{}, iterative.Settings{
+			Tolerance: 1e-12,
+		})
+		if err != nil {
+			t.Errorf("%v: GMRES: unexpected error %v", test.name, err)
+		} else if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-6 {
+			t.Errorf("%v: GMRES: solution error %v", test.name, d)
+		}
+
+		// CG must detect that an indefinite matrix is not positive
+		// definite instead of returning a wrong solution.
+		res, err = iterative.LinearSolve(p.Ops, b, &iterative.CG{}, iterative.Settings{
+			Tolerance: 1e-12,
+		})
+		if test.spd {
+			if err != nil {
+				t.Errorf("%v: CG: unexpected error %v", test.name, err)
+			} else if d := floats.Distance(res.X, x, math.Inf(1)); d > 1e-6 {
+				t.Errorf("%v: CG: solution error %v", test.name, d)
+			}
+		} else if err == nil || err.Error() != "CG: matrix not positive definite" {
+			t.Errorf("%v: CG: expected error for an indefinite matrix, got %v", test.name, err)
+		}
+	}
+}
+
+func TestCGToeplitzBound(t *testing.T) {
+	for _, test := range []struct {
+		n    int
+		a, b float64
+	}{
+		{50, -1, 2.5},
+		{100, -1, 2.05},
+		{200, 0.5, 1.2},
+		{400, -1, 2.001},
+	} {
+		p := testmat.Toeplitz(test.n, test.a, test.b, test.a)
+		kappa := p.MaxEig / p.MinEig
+		x, b := p.Manufactured()
+
+		// Drive CG directly to observe the error after each iteration.
+		aNorm := func(v []float64) float64 {
+			av := make([]float64, len(v))
+			p.Ops.MatVec(av, v)
+			return math.Sqrt(floats.Dot(v, av))
+		}
+		e := make([]float64, p.Dim)
+		floats.SubTo(e, x, make([]float64, p.Dim))
+		e0 := aNorm(e)
+		ctx := &iterative.Context{
+			X:        make([]float64, p.Dim),
+			Residual: append([]float64(nil), b...),
+		}
+		cg := &iterative.CG{}
+		cg.Init(p.Dim)
+		for k := 0; k < p.Dim; {
+			op, err := cg.Iterate(ctx)
+			if err != nil {
+				t.Fatalf("n=%v: unexpected error %v", test.n, err)
+			}
+			switch op {
+			case iterative.PSolve:
+				copy(ctx.Dst, ctx.Src)
+				continue
+			case iterative.MatVec:
+				p.Ops.MatVec(ctx.Dst, ctx.Src)
+				continue
+			case iterative.CheckResidualNorm:
+				ctx.Converged = ctx.ResidualNorm < 1e-13*floats.Norm(b, 2)
+				continue
+			}
+			k++
+			floats.SubTo(e, x, ctx.X)
+			ratio := aNorm(e) / e0
+			// Allow for the loss of orthogonality in floating-point
+			// arithmetic once the error is tiny.
+			if bound := testmat.ChebyshevBound(kappa, k); ratio > bound && ratio > 1e-10 {
+				t.Errorf("n=%v,κ=%.3g: error reduction %v above the bound %v in iteration %v",
+					test.n, kappa, ratio, bound, k)
+				break
+			}
+			if ctx.Converged {
+				if want := testmat.CGIterations(kappa, 1e-13); k > want+10 {
+					t.Errorf("n=%v,κ=%.3g: %v iterations, bound predicts %v", test.n, kappa, k, want)
+				}
+				break
+			}
+		}
+	}
+}
+
+// randomSparseCases are deterministic nonsymmetric test matrices that stand
+// in for Matrix Market matrices on which the methods do not converge to
+// the required accuracy. The regime of the matrices they replace is noted.
+var randomSparseCases = []struct {
+	name         string
+	n, nnz       int
+	cond, normal float64
+	tol          float64
+}{
+	{"symmetric", 200, 7, 1e2, 0, 1e-10},
+	{"e05r0x00-like", 236, 25, 1e3, 0.5, 1e-9},
+	{"impcol-like", 200, 5, 1e3, 1, 1e-9},
+	{"fs_183-like", 183, 9, 1e4, 0.8, 1e-8},
+	{"west-like", 400, 4, 1e4, 1, 1e-8},
+}
+
+func testRandomSparse(t *testing.T, name string, method func() iterative.Method, maxIter int) {
+	for _, tc := range randomSparseCases {
+		p := problem.RandomSparse(tc.n, tc.nnz, tc.cond, tc.normal, rand.New(rand.NewSource(1)))
+		p.Tolerance = tc.tol
+		_, err := p.Solve(method(), iterative.Settings{
+			MaxIterations: maxIter * tc.n,
+			Tolerance:     1e-14,
+		})
+		if err != nil {
+			t.Errorf("%v %v: %v", name, tc.name, err)
+		}
+	}
+}
+
+func TestBiCGSTABRandomSparse(t *testing.T) {
+	testRandomSparse(t, "BiCGSTAB", func() iterative.Method { return &iterative.BiCGSTAB{} }, 10)
+}
+
+func TestGMRESRandomSparse(t *testing.T) {
+	testRandomSparse(t, "GMRES", func() iterative.Method { return &iterative.GMRES{} }, 10)
+	// Restarted GMRES converges slowly on the ill-conditioned matrices.
+	testRandomSparse(t, "GMRES(30)", func() iterative.Method { return &iterative.GMRES{Restart: 30} }, 100)
+}
+
+func TestCGSpectrum(t *testing.T) {
+	const (
+		n   = 300
+		tol = 1e-10
+	)
+	distinct := make([]float64, n)
+	for i := range distinct {
+		distinct[i] = []float64{1, 3, 10, 30, 100, 300}[i%6]
+	}
+	for _, test := range []struct {
+		name string
+		eigs []float64
+		max  int // Expected maximum number of iterations.
+	}{
+		// In exact arithmetic CG terminates after as many iterations
+		// as there are distinct eigenvalues.
+		{"6 distinct", distinct, 6 + 1},
+		// Tight clusters behave almost like distinct eigenvalues,
+		// the condition number does not matter.
+		{"4 clusters", testmat.ClusteredSpectrum(n, 4, 1, 1e4, 1e-6), 3 * 4},
+		// The residual is reduced by tol if the A-norm of the error is
+		// reduced by tol/sqrt(κ).
+		{"geometric κ=1e2", testmat.GeometricSpectrum(n, 1, 1e2), testmat.CGIterations(1e2, tol/1e1)},
+		{"geometric κ=1e4", testmat.GeometricSpectrum(n, 1, 1e4), testmat.CGIterations(1e4, tol/1e2)},
+	} {
+		ops := testmat.RandomSPDSpectrum(n, test.eigs, rand.New(rand.NewSource(1)))
+		want := make([]float64, n)
+		for i := range want {
+			want[i] = 1
+		}
+		b := make([]float64, n)
+		ops.MatVec(b, want)
+		res, err := iterative.LinearSolve(ops, b, &iterative.CG{}, iterative.Settings{
+			Tolerance:     tol,
+			MaxIterations: 10 * n,
+		})
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", test.name, err)
+			continue
+		}
+		if res.Stats.Iterations > test.max {
+			t.Errorf("%v: %v iterations, want at most %v", test.name, res.Stats.Iterations, test.max)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative
+
+import (
+	"errors"
+	"math"
+)
+
+// WeightedCG implements the preconditioned Conjugate Gradient method in the
+// inner product
+//  <u, v>_B = u^T B v
+// for solving the system of linear equations
+//  Ax = b,
+// where B is the symmetric positive definite weight matrix and A is self-adjoint
+// and positive definite in the B-inner product, that is, B*A is symmetric
+// positive definite. The preconditioner must be self-adjoint and positive
+// definite in the B-inner product as well. A typical example is a
+// generalized problem with the stiffness matrix K and the mass matrix M,
+// where A = M^{-1} K and B = M. The iterates minimize the error in the norm
+// induced by B*A and ResidualNorm is the B-norm of the residual, so the
+// stopping criterion is in the natural metric of the problem.
+//
+// WeightedCG needs MatVec, PSolve and WeightVec matrix operations. The products
+// with B are computed by Settings.Weight, without it WeightedCG computes the
+// same iterates as CG. Each iteration needs two products with B.
+type WeightedCG struct {
+	first  bool
+	resume int
+
+	rho, rhoPrev float64
+
+	z  []float64
+	p  []float64
+	ap []float64
+	bp []float64 // B*p
+	br []float64 // B*r
+}
+
+// Init implements the Method interface.
+func (cg *WeightedCG) Init(dim int) {
+	if dim <= 0 {
+		panic("WeightedCG: dimension not positive")
+	}
+
+	cg.z = reuse(cg.z, dim)
+	cg.p = reuse(cg.p, dim)
+	cg.ap = reuse(cg.ap, dim)
+	cg.bp = reuse(cg.bp, dim)
+	cg.br = reuse(cg.br, dim)
+	cg.first = true
+	cg.resume = 1
+}
+
+// Iterate implements the Method interface.
+func (cg *WeightedCG) Iterate(ctx *Context) (Operation, error) {
+	switch cg.resume {
+	case 1:
+		ctx.Src = ctx.Residual
+		ctx.Dst = cg.br
+		cg.resume = 2
+		return WeightVec, nil
+		// Compute B r_0
+	case 2:
+		ctx.Src = ctx.Residual
+		ctx.Dst = cg.z
+		cg.resume = 3
+		return PSolve, nil
+		// Solve M z = r_{i-1}
+	case 3:
+		cg.rho = ctx.vec.dot(cg.br, cg.z) // ρ_i = <r_{i-1}, z>_B
+		if !cg.first {
+			beta := cg.rho / cg.rhoPrev         // β = ρ_i / ρ_{i-1}
+			ctx.vec.addScaled(cg.z, beta, cg.p) // z = z + β p_{i-1}
+		}
+		cg.p, cg.z = cg.z, cg.p // p_i = z
+
+		ctx.Src = cg.p
+		ctx.Dst = cg.ap
+		cg.resume = 4
+		return MatVec, nil
+		// Compute Ap_i
+	case 4:
+		ctx.Src = cg.p
+		ctx.Dst = cg.bp
+		cg.resume = 5
+		return WeightVec, nil
+		// Compute Bp_i
+	case 5:
+		pap := ctx.vec.dot(cg.bp, cg.ap)
+		if pap <= 0 {
+			cg.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, &NotPositiveDefiniteError{Method: "WeightedCG"}
+		}
+		alpha := cg.rho / pap                          // α = ρ_i / <p_i, Ap_i>_B
+		ctx.vec.addScaled(ctx.X, alpha, cg.p)          // x_i = x_{i-1} + α p_i
+		ctx.vec.addScaled(ctx.Residual, -alpha, cg.ap) // r_i = r_{i-1} - α Ap_i
+
+		ctx.Src = ctx.Residual
+		ctx.Dst = cg.br
+		cg.resume = 6
+		return WeightVec, nil
+		// Compute B r_i
+	case 6:
+		rbr := ctx.vec.dot(ctx.Residual, cg.br)
+		if rbr < 0 {
+			cg.resume = 0 // Calling Iterate again without Init will panic.
+			return NoOperation, errors.New("WeightedCG: weight not positive definite")
+		}
+		ctx.ResidualNorm = math.Sqrt(rbr)
+
+		ctx.Src = nil
+		ctx.Dst = nil
+		ctx.Converged = false
+		cg.resume = 7
+		return CheckResidualNorm, nil
+	case 7:
+		ctx.ResidualCurrent = true
+		if ctx.Converged {
+			cg.resume = 0 // Calling Iterate again without Init will panic.
+			return EndIteration, nil
+		}
+		cg.rhoPrev = cg.rho
+		cg.first = false
+		cg.resume = 2
+		return EndIteration, nil
+
+	default:
+		panic("WeightedCG: Init not called")
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright ©2017 The gonum Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iterative_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+
+	"github.com/vladimir-ch/iterative"
+	"github.com/vladimir-ch/iterative/testmat/problem"
+)
+
+// fem1D returns the stiffness and mass matrices of the linear finite
+// elements for -u'' on n interior nodes of a uniform mesh of [0,1].
+func fem1D(n int) (k, m *mat.SymDense) {
+	h := 1 / float64(n+1)
+	k = mat.NewSymDense(n, nil)
+	m = mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		k.SetSym(i, i, 2/h)
+		m.SetSym(i, i, 4*h/6)
+		if i < n-1 {
+			k.SetSym(i, i+1, -1/h)
+			m.SetSym(i, i+1, h/6)
+		}
+	}
+	return k, m
+}
+
+// TestWeightedCG solves the generalized problem
+//  M^{-1} K x = f
+// in the M-inner product and compares the solution with the dense solution
+// of K x = M f.
+func TestWeightedCG(t *testing.T) {
+	const tol = 1e-10
+	for _, n := range []int{1, 2, 5, 20, 100} {
+		k, m := fem1D(n)
+		var chol mat.Cholesky
+		if !chol.Factorize(m) {
+			t.Fatalf("n=%d: mass matrix not positive definite", n)
+		}
+		a := iterative.MatrixOps{
+			MatVec: func(dst, x []float64) {
+				y := mat.NewVecDense(len(dst), dst)
+				y.MulVec(k, mat.NewVecDense(len(x), x))
+				if err := chol.SolveVecTo(y, y); err != nil {
+					panic(err)
+				}
+			},
+		}
+		weight := func(dst, x []float64) {
+			mat.NewVecDense(len(dst), dst).MulVec(m, mat.NewVecDense(len(x), x))
+		}
+
+		f := make([]float64, n)
+		for i := range f {
+			x := float64(i+1) / float64(n+1)
+			f[i] = math.Sin(math.Pi*x) + x
+		}
+		var want mat.VecDense
+		want.MulVec(m, mat.NewVecDense(n, f))
+		if err := want.SolveVec(k, &want); err != nil {
+			t.Fatalf("n=%d: dense solve failed: %v", n, err)
+		}
+
+		res, err := iterative.LinearSolve(a, f, &iterative.WeightedCG{}, iterative.Settings{
+			Tolerance: tol,
+			Weight:    weight,
+		})
+		if err != nil {
+			t.Errorf("n=%d: %v", n, err)
+			continue
+		}
+		d := floats.Distance(res.X, want.RawVector().Data, math.Inf(1))
+		if d > 1e-8*floats.Norm(want.RawVector().Data, math.Inf(1)) {
+			t.Errorf("n=%d: solution differs from the dense solution by %v", n, d)
+		}
+		// The final residual norm is the M-norm
+		// of the residual.
+		r := make([]float64, n)
+		a.MatVec(r, res.X)
+		floats.SubTo(r, f, r)
+		mr := make([]float64, n)
+		weight(mr, r)
+		mf := make([]float64, n)
+		weight(mf, f)
+		rnorm := math.Sqrt(floats.Dot(r, mr))
+		if rnorm > 10*tol*math.Sqrt(floats.Dot(f, mf)) {
+			t.Errorf("n=%d: M-norm of the residual %v too large", n, rnorm)
+		}
+		if math.Abs(rnorm-res.Stats.ResidualNorm) > 1e-6*math.Sqrt(floats.Dot(f, mf)) {
+			t.Errorf("n=%d: ResidualNorm %v is not the M-norm %v", n, res.Stats.ResidualNorm, rnorm)
+		}
+		// One product for each of the norms of
+		// b and r_0, one for B r_0 in the method
+		// and then two per iteration.
+		if res.Stats.WeightVec != 3+2*res.Stats.Iterations {
+			t.Errorf("n=%d: unexpected number of WeightVec: %d for %d iterations", n, res.Stats.WeightVec, res.Stats.Iterations)
+		}
+	}
+}
+
+// TestWeightedCGIdentity checks that WeightedCG without Settings.Weight
+// computes the iterates of CG.
+func TestWeightedCGIdentity(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, p := range []*problem.Problem{
+		problem.RandomSPD(10, rnd),
+		problem.RandomSPD(100, rnd),
+		market("nos4", 1e-9),
+	} {
+		settings := iterative.Settings{Tolerance: 1e-10}
+		want, err := p.Solve(&iterative.CG{}, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := p.Solve(&iterative.WeightedCG{}, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Stats.Iterations != want.Stats.Iterations {
+			t.Errorf("%v: unexpected number of iterations: want %d, got %d", p.Name, want.Stats.Iterations, got.Stats.Iterations)
+		}
+		if !floats.EqualApprox(got.X, want.X, p.Tolerance) {
+			t.Errorf("%v: solutions of CG and WeightedCG differ", p.Name)
+		}
+		if got.Stats.WeightVec != 0 {
+			t.Errorf("%v: unexpected WeightVec count %d", p.Name, got.Stats.WeightVec)
+		}
+	}
+}